@@ -0,0 +1,165 @@
+// Утилита повторной обработки сообщений из DLQ (топик KAFKA_TOPIC-dlq)
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"test_service/internal/config"
+	"test_service/internal/database"
+	"test_service/internal/kafka"
+	"test_service/internal/logger"
+	"test_service/internal/models"
+	"test_service/internal/retry"
+	"test_service/internal/service"
+)
+
+func main() {
+	os.Exit(run())
+}
+
+// run выполняет повторную обработку DLQ и возвращает код завершения процесса
+func run() int {
+	// Загружаем конфигурацию из окружения
+	cfg, err := config.LoadFromEnv()
+	if err != nil {
+		log.Fatalf("Ошибка загрузки конфигурации: %v", err)
+	}
+
+	// Настраиваем общий логгер сервиса (text/json)
+	logger.Setup(cfg.LogFormat)
+
+	// SASL-аутентификация и TLS для всех подключений к Kafka
+	kafkaTLS, err := kafka.NewTLSConfig(kafka.TLSConfig{
+		Enabled:            cfg.KafkaTLSEnabled,
+		CAFile:             cfg.KafkaTLSCAFile,
+		CertFile:           cfg.KafkaTLSCertFile,
+		KeyFile:            cfg.KafkaTLSKeyFile,
+		InsecureSkipVerify: cfg.KafkaTLSInsecureSkipVerify,
+	})
+	if err != nil {
+		log.Fatalf("Ошибка настройки TLS для Kafka: %v", err)
+	}
+	kafkaConn, err := kafka.NewConnection(kafka.SASLConfig{
+		Mechanism: cfg.KafkaSASLMechanism,
+		Username:  cfg.KafkaSASLUsername,
+		Password:  cfg.KafkaSASLPassword,
+	}, kafkaTLS)
+	if err != nil {
+		log.Fatalf("Ошибка настройки SASL для Kafka: %v", err)
+	}
+
+	// Сжатие и настройки записи сообщений, которые отправляет сервис
+	compression, err := kafka.ParseCompression(cfg.KafkaCompression)
+	if err != nil {
+		log.Fatalf("Некорректное сжатие сообщений Kafka: %v", err)
+	}
+	acks, err := kafka.ParseRequiredAcks(cfg.KafkaRequiredAcks)
+	if err != nil {
+		log.Fatalf("Некорректный уровень подтверждения записи Kafka: %v", err)
+	}
+	writerOpts := kafka.WriterOptions{
+		RequiredAcks: acks,
+		WriteTimeout: cfg.KafkaWriteTimeout,
+		MaxAttempts:  cfg.KafkaMaxAttempts,
+		BatchSize:    cfg.KafkaBatchSize,
+		BatchTimeout: cfg.KafkaBatchTimeout,
+		Compression:  compression,
+		Connection:   kafkaConn,
+	}
+
+	max := flag.Int("max", 0, "обработать не больше N сообщений (0 — без ограничения)")
+	until := flag.String("until", "", "только сообщения, отправленные в DLQ раньше времени (RFC3339, пусто — раньше запуска)")
+	dryRun := flag.Bool("dry-run", false, "только подсчитать сообщения: заказы не обрабатываются, смещения не коммитятся")
+	flag.Parse()
+
+	opts := kafka.DLQReplayOptions{Max: *max, DryRun: *dryRun}
+	if *until != "" {
+		if opts.Until, err = time.Parse(time.RFC3339, *until); err != nil {
+			log.Fatalf("Некорректное время окончания (-until): %q", *until)
+		}
+	}
+
+	// Останавливаем повторную обработку по сигналу
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// В dry-run заказы не обрабатываются: БД не нужна
+	var processFunc func(context.Context, *models.Order) error
+	if !opts.DryRun {
+		svc, closeDB, ok := connect(ctx, cfg)
+		if !ok {
+			return 1
+		}
+		defer closeDB()
+		processFunc = svc.ProcessOrder
+	}
+
+	dlqProducer := kafka.NewDLQProducer(cfg.KafkaBrokers, kafka.DLQTopic(cfg.KafkaTopic), writerOpts)
+	defer func() {
+		if err := dlqProducer.Close(); err != nil {
+			log.Printf("Ошибка при закрытии DLQ producer: %v", err)
+		}
+	}()
+	consumer := kafka.NewDLQConsumer(cfg.KafkaBrokers, cfg.KafkaTopic, cfg.KafkaGroupID, kafkaConn, dlqProducer)
+
+	log.Printf("Повторная обработка DLQ топика %s (dry-run %t)", cfg.KafkaTopic, opts.DryRun)
+	summary, err := consumer.Replay(ctx, opts, processFunc)
+	if opts.DryRun {
+		log.Printf("Итоги: к обработке %d %v, пропущено %d", summary.Pending, summary.Reasons, summary.Skipped)
+	} else {
+		log.Printf("Итоги: обработано %d, с ошибками %d, пропущено %d", summary.Replayed, summary.Failed, summary.Skipped)
+	}
+	if err != nil {
+		log.Printf("Повторная обработка DLQ прервана: %v", err)
+		return 1
+	}
+	return 0
+}
+
+// connect подключается к БД и создает сервис обработки заказов; closeDB освобождает ресурсы
+func connect(ctx context.Context, cfg *config.Config) (svc *service.Service, closeDB func(), ok bool) {
+	// Подключение к базе данных с retry
+	poolCfg := database.PoolConfig{
+		MaxConns:          int32(cfg.DBMaxConns),
+		MinConns:          int32(cfg.DBMinConns),
+		MaxConnLifetime:   cfg.DBMaxConnLifetime,
+		MaxConnIdleTime:   cfg.DBMaxConnIdleTime,
+		HealthCheckPeriod: cfg.DBHealthCheckPeriod,
+		QueryExecMode:     cfg.DBQueryExecMode,
+	}
+	isolation, err := database.ParseTxIsolation(cfg.DBTxIsolation)
+	if err != nil {
+		log.Fatalf("Некорректный уровень изоляции транзакций: %v", err)
+	}
+	var db *database.Postgres
+	err = retry.DoWithContext(ctx, retry.HeavyPolicy(), func(ctx context.Context) error {
+		var dbErr error
+		db, dbErr = database.NewPostgres(ctx, cfg.PostgresDSN, poolCfg)
+		return dbErr
+	})
+	if err != nil {
+		log.Printf("Ошибка подключения к БД после всех попыток: %v", err)
+		return nil, nil, false
+	}
+	db.SetQueryTimeouts(database.QueryTimeouts{
+		Read:   cfg.DBReadTimeout,
+		Write:  cfg.DBWriteTimeout,
+		GetAll: cfg.DBGetAllTimeout,
+	})
+	db.SetSaveIsolation(isolation)
+	db.SetMultiRowItems(cfg.DBItemsMultiRow, cfg.DBItemsPerInsert)
+	if err := db.Init(ctx); err != nil {
+		db.Close()
+		log.Printf("Ошибка инициализации БД: %v", err)
+		return nil, nil, false
+	}
+
+	svc = service.New(db)
+	return svc, svc.Close, true
+}
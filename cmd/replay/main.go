@@ -0,0 +1,186 @@
+// Утилита разовой повторной обработки топика Kafka начиная с заданного времени
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"test_service/internal/config"
+	"test_service/internal/database"
+	"test_service/internal/kafka"
+	"test_service/internal/logger"
+	"test_service/internal/retry"
+	"test_service/internal/service"
+)
+
+func main() {
+	os.Exit(run())
+}
+
+// run выполняет повторную обработку и возвращает код завершения процесса
+func run() int {
+	// Загружаем конфигурацию из окружения
+	cfg, err := config.LoadFromEnv()
+	if err != nil {
+		log.Fatalf("Ошибка загрузки конфигурации: %v", err)
+	}
+
+	// Настраиваем общий логгер сервиса (text/json)
+	logger.Setup(cfg.LogFormat)
+
+	// SASL-аутентификация и TLS для всех подключений к Kafka
+	kafkaTLS, err := kafka.NewTLSConfig(kafka.TLSConfig{
+		Enabled:            cfg.KafkaTLSEnabled,
+		CAFile:             cfg.KafkaTLSCAFile,
+		CertFile:           cfg.KafkaTLSCertFile,
+		KeyFile:            cfg.KafkaTLSKeyFile,
+		InsecureSkipVerify: cfg.KafkaTLSInsecureSkipVerify,
+	})
+	if err != nil {
+		log.Fatalf("Ошибка настройки TLS для Kafka: %v", err)
+	}
+	kafkaConn, err := kafka.NewConnection(kafka.SASLConfig{
+		Mechanism: cfg.KafkaSASLMechanism,
+		Username:  cfg.KafkaSASLUsername,
+		Password:  cfg.KafkaSASLPassword,
+	}, kafkaTLS)
+	if err != nil {
+		log.Fatalf("Ошибка настройки SASL для Kafka: %v", err)
+	}
+
+	// Сжатие и настройки записи сообщений, которые отправляет сервис
+	compression, err := kafka.ParseCompression(cfg.KafkaCompression)
+	if err != nil {
+		log.Fatalf("Некорректное сжатие сообщений Kafka: %v", err)
+	}
+	codec, err := kafka.CodecByName(cfg.KafkaCodec)
+	if err != nil {
+		log.Fatalf("Некорректный формат сообщений Kafka: %v", err)
+	}
+	acks, err := kafka.ParseRequiredAcks(cfg.KafkaRequiredAcks)
+	if err != nil {
+		log.Fatalf("Некорректный уровень подтверждения записи Kafka: %v", err)
+	}
+	writerOpts := kafka.WriterOptions{
+		RequiredAcks: acks,
+		WriteTimeout: cfg.KafkaWriteTimeout,
+		MaxAttempts:  cfg.KafkaMaxAttempts,
+		BatchSize:    cfg.KafkaBatchSize,
+		BatchTimeout: cfg.KafkaBatchTimeout,
+		Compression:  compression,
+		Connection:   kafkaConn,
+	}
+
+	// Флаги переопределяют значения KAFKA_REPLAY_* из окружения
+	from := flag.String("from", formatTime(cfg.KafkaReplayFrom), "время начала повторной обработки (RFC3339)")
+	to := flag.String("to", formatTime(cfg.KafkaReplayTo), "время окончания повторной обработки (RFC3339, пусто — до конца топика)")
+	withDLQ := flag.Bool("dlq", cfg.KafkaReplayDLQ, "отправлять сообщения с ошибками в DLQ")
+	flag.Parse()
+
+	replayCfg := kafka.ReplayConfig{
+		Brokers:    cfg.KafkaBrokers,
+		Topic:      cfg.KafkaTopic,
+		Codec:      codec,
+		Connection: kafkaConn,
+	}
+	if replayCfg.From, err = parseTime(*from); err != nil || replayCfg.From.IsZero() {
+		log.Fatalf("Не задано или некорректно время начала (-from / KAFKA_REPLAY_FROM): %q", *from)
+	}
+	if replayCfg.To, err = parseTime(*to); err != nil {
+		log.Fatalf("Некорректное время окончания (-to / KAFKA_REPLAY_TO): %q", *to)
+	}
+
+	// Останавливаем повторную обработку по сигналу
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Подключение к базе данных с retry
+	poolCfg := database.PoolConfig{
+		MaxConns:          int32(cfg.DBMaxConns),
+		MinConns:          int32(cfg.DBMinConns),
+		MaxConnLifetime:   cfg.DBMaxConnLifetime,
+		MaxConnIdleTime:   cfg.DBMaxConnIdleTime,
+		HealthCheckPeriod: cfg.DBHealthCheckPeriod,
+		QueryExecMode:     cfg.DBQueryExecMode,
+	}
+	isolation, err := database.ParseTxIsolation(cfg.DBTxIsolation)
+	if err != nil {
+		log.Fatalf("Некорректный уровень изоляции транзакций: %v", err)
+	}
+	var db *database.Postgres
+	err = retry.DoWithContext(ctx, retry.HeavyPolicy(), func(ctx context.Context) error {
+		var dbErr error
+		db, dbErr = database.NewPostgres(ctx, cfg.PostgresDSN, poolCfg)
+		return dbErr
+	})
+	if err != nil {
+		log.Fatalf("Ошибка подключения к БД после всех попыток: %v", err)
+	}
+	db.SetQueryTimeouts(database.QueryTimeouts{
+		Read:   cfg.DBReadTimeout,
+		Write:  cfg.DBWriteTimeout,
+		GetAll: cfg.DBGetAllTimeout,
+	})
+	db.SetSaveIsolation(isolation)
+	db.SetMultiRowItems(cfg.DBItemsMultiRow, cfg.DBItemsPerInsert)
+	if err := db.Init(ctx); err != nil {
+		db.Close()
+		log.Fatalf("Ошибка инициализации БД: %v", err)
+	}
+
+	svc := service.New(db)
+	defer svc.Close()
+
+	// DLQ при повторной обработке по умолчанию выключена
+	if *withDLQ {
+		dlqProducer := kafka.NewDLQProducer(cfg.KafkaBrokers, kafka.DLQTopic(cfg.KafkaTopic), writerOpts)
+		defer func() {
+			if err := dlqProducer.Close(); err != nil {
+				log.Printf("Ошибка при закрытии DLQ producer: %v", err)
+			}
+		}()
+		replayCfg.DLQ = dlqProducer
+	}
+
+	replayer, err := kafka.NewReplayer(ctx, replayCfg)
+	if err != nil {
+		log.Printf("Ошибка создания replayer: %v", err)
+		return 1
+	}
+	defer func() {
+		if err := replayer.Close(); err != nil {
+			log.Printf("Ошибка при закрытии replayer: %v", err)
+		}
+	}()
+
+	log.Printf("Повторная обработка топика %s с %s", cfg.KafkaTopic, replayCfg.From.Format(time.RFC3339))
+	summary, err := replayer.Run(ctx, svc.ProcessOrder)
+	log.Printf("Итоги: партиций %d, обработано %d, ошибок %d, отправлено в DLQ %d, время %s",
+		summary.Partitions, summary.Processed, summary.Failed, summary.SentToDLQ, summary.Duration)
+	if err != nil {
+		log.Printf("Повторная обработка прервана: %v", err)
+		return 1
+	}
+	return 0
+}
+
+// parseTime разбирает время в формате RFC3339, пустая строка — нулевое время
+func parseTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// formatTime форматирует время в RFC3339, нулевое время — пустая строка
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
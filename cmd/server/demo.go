@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"test_service/internal/database"
+	"test_service/internal/kafka"
+	"test_service/internal/leader"
+)
+
+// demoProducerLockKey фиксированный ключ advisory-блокировки лидера демо-продюсера
+const demoProducerLockKey int64 = 0x6f72646572 // "order"
+
+// demoProducerLocker захватывает лидерство демо-продюсера через advisory-блокировку PostgreSQL
+func demoProducerLocker(db *database.Postgres) leader.Locker {
+	return advisoryLocker(db, demoProducerLockKey)
+}
+
+// advisoryLocker захватывает лидерство через advisory-блокировку PostgreSQL с ключом key
+func advisoryLocker(db *database.Postgres, key int64) leader.Locker {
+	return func(ctx context.Context) (leader.Lock, bool, error) {
+		lock, acquired, err := db.TryAdvisoryLock(ctx, key)
+		if err != nil || !acquired {
+			return nil, false, err
+		}
+		return lock, true, nil
+	}
+}
+
+// runDemoProducer отправляет тестовые заказы с заданным периодом до отмены ctx.
+// Счетчик общий для всех периодов лидерства экземпляра.
+func runDemoProducer(ctx context.Context, producer *kafka.Producer, interval time.Duration, orderCounter *int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			order := kafka.GenerateTestOrder(*orderCounter)
+			if err := producer.SendOrderWithContext(ctx, order); err != nil {
+				log.Printf("Ошибка отправки тестового заказа: %v", err)
+			} else {
+				log.Printf("Отправлен тестовый заказ в Kafka: %s", order.OrderUID)
+			}
+			*orderCounter++
+		}
+	}
+}
@@ -4,22 +4,33 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io/fs"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
-	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
+	"test_service/internal/cache"
 	"test_service/internal/config"
 	"test_service/internal/database"
 	"test_service/internal/handler"
 	"test_service/internal/kafka"
+	"test_service/internal/leader"
+	"test_service/internal/lifecycle"
+	"test_service/internal/logger"
+	"test_service/internal/outbox"
 	"test_service/internal/retry"
 	"test_service/internal/service"
+	"test_service/web"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 func main() {
@@ -32,12 +43,80 @@ func main() {
 		log.Fatalf("Ошибка загрузки конфигурации: %v", err)
 	}
 
+	// Настраиваем общий логгер сервиса (text/json)
+	logger.Setup(cfg.LogFormat)
+
+	// SASL-аутентификация и TLS для всех подключений к Kafka
+	kafkaTLS, err := kafka.NewTLSConfig(kafka.TLSConfig{
+		Enabled:            cfg.KafkaTLSEnabled,
+		CAFile:             cfg.KafkaTLSCAFile,
+		CertFile:           cfg.KafkaTLSCertFile,
+		KeyFile:            cfg.KafkaTLSKeyFile,
+		InsecureSkipVerify: cfg.KafkaTLSInsecureSkipVerify,
+	})
+	if err != nil {
+		log.Fatalf("Ошибка настройки TLS для Kafka: %v", err)
+	}
+	kafkaConn, err := kafka.NewConnection(kafka.SASLConfig{
+		Mechanism: cfg.KafkaSASLMechanism,
+		Username:  cfg.KafkaSASLUsername,
+		Password:  cfg.KafkaSASLPassword,
+	}, kafkaTLS)
+	if err != nil {
+		log.Fatalf("Ошибка настройки SASL для Kafka: %v", err)
+	}
+
+	// Сжатие и настройки записи сообщений, которые отправляет сервис
+	compression, err := kafka.ParseCompression(cfg.KafkaCompression)
+	if err != nil {
+		log.Fatalf("Некорректное сжатие сообщений Kafka: %v", err)
+	}
+	codec, err := kafka.CodecByName(cfg.KafkaCodec)
+	if err != nil {
+		log.Fatalf("Некорректный формат сообщений Kafka: %v", err)
+	}
+	acks, err := kafka.ParseRequiredAcks(cfg.KafkaRequiredAcks)
+	if err != nil {
+		log.Fatalf("Некорректный уровень подтверждения записи Kafka: %v", err)
+	}
+	writerOpts := kafka.WriterOptions{
+		RequiredAcks: acks,
+		WriteTimeout: cfg.KafkaWriteTimeout,
+		MaxAttempts:  cfg.KafkaMaxAttempts,
+		BatchSize:    cfg.KafkaBatchSize,
+		BatchTimeout: cfg.KafkaBatchTimeout,
+		Compression:  compression,
+		Connection:   kafkaConn,
+	}
+	// Без подтверждений запись считается успешной до того, как брокер ее принял
+	if cfg.KafkaRequiredAcks == "none" && (cfg.DemoProducerEnabled || cfg.OutboxTopic != "" || cfg.KafkaDeliveryMode == "at_least_once") {
+		log.Printf("Предупреждение: KAFKA_REQUIRED_ACKS=none — демо-продюсер, публикатор outbox и запись в DLQ в режиме at_least_once не получают подтверждения брокера, при сбое сообщения могут потеряться")
+	}
+
 	// Подключение к базе данных с retry
-	log.Println("Подключение к БД...")
+	log.Printf("Подключение к БД: %s", database.RedactDSN(cfg.PostgresDSN))
+	poolCfg := database.PoolConfig{
+		MaxConns:          int32(cfg.DBMaxConns),
+		MinConns:          int32(cfg.DBMinConns),
+		MaxConnLifetime:   cfg.DBMaxConnLifetime,
+		MaxConnIdleTime:   cfg.DBMaxConnIdleTime,
+		HealthCheckPeriod: cfg.DBHealthCheckPeriod,
+		QueryExecMode:     cfg.DBQueryExecMode,
+	}
+	if cfg.DBTracing {
+		// Спаны экспортируются синхронно при завершении, поэтому при остановке ничего не теряется
+		tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(database.LogExporter{}))
+		otel.SetTracerProvider(tracerProvider)
+		poolCfg.Tracer = tracerProvider.Tracer(database.TracerName)
+	}
+	isolation, err := database.ParseTxIsolation(cfg.DBTxIsolation)
+	if err != nil {
+		log.Fatalf("Некорректный уровень изоляции транзакций: %v", err)
+	}
 	var db *database.Postgres
 	err = retry.DoWithContext(ctx, retry.HeavyPolicy(), func(ctx context.Context) error {
 		var dbErr error
-		db, dbErr = database.NewPostgres(ctx, cfg.PostgresDSN)
+		db, dbErr = database.NewPostgresWithReplica(ctx, cfg.PostgresDSN, cfg.PostgresReadDSN, poolCfg)
 		if dbErr != nil {
 			log.Printf("Ошибка подключения к БД (попытка будет повторена): %v", dbErr)
 			return dbErr
@@ -48,6 +127,17 @@ func main() {
 		log.Fatalf("Ошибка подключения к БД после всех попыток: %v", err)
 	}
 	defer db.Close()
+	db.SetQueryTimeouts(database.QueryTimeouts{
+		Read:   cfg.DBReadTimeout,
+		Write:  cfg.DBWriteTimeout,
+		GetAll: cfg.DBGetAllTimeout,
+	})
+	db.SetSaveIsolation(isolation)
+	db.SetMultiRowItems(cfg.DBItemsMultiRow, cfg.DBItemsPerInsert)
+	// События сохранения заказов пишутся в outbox, только если их есть кому отправить
+	db.SetOutbox(cfg.OutboxTopic != "")
+	// Секционирование по месяцам включается явно: его миграция переписывает таблицы заказов
+	db.SetPartitioning(cfg.Partitioning)
 
 	// Инициализация базы данных (создание таблиц) с retry
 	err = retry.DoWithContext(ctx, retry.HeavyPolicy(), func(ctx context.Context) error {
@@ -63,7 +153,15 @@ func main() {
 	}
 
 	// Создание сервиса для работы с заказами
-	svc := service.New(db)
+	svc := service.New(db,
+		cache.WithMaxEntries(cfg.CacheMaxEntries),
+		cache.WithMaxBytes(cfg.CacheMaxBytes),
+		cache.WithSlidingTTL(cfg.CacheSlidingTTL),
+		cache.WithMaxLifetime(cfg.CacheMaxLifetime),
+		cache.WithStaleWhileRevalidate(cfg.CacheMaxStale),
+		cache.WithCleanupInterval(cfg.CacheCleanupInterval),
+	)
+	svc.SetSnapshot(cfg.CacheSnapshotPath, cfg.CacheSnapshotMaxAge)
 
 	// Прогрев кэша перед запуском обработчиков с retry
 	err = retry.DoWithContext(ctx, retry.DefaultPolicy(), func(ctx context.Context) error {
@@ -78,17 +176,78 @@ func main() {
 		log.Printf("Ошибка прогрева кэша после всех попыток: %v", err)
 	}
 
-	// Создание DLQ producer для обработки неудачных сообщений
-	dlqTopic := cfg.KafkaTopic + "-dlq" // Используем топик-оригинал с суффиксом DLQ
-	dlqProducer := kafka.NewDLQProducer(cfg.KafkaBrokers, dlqTopic)
-	defer func() {
-		if err := dlqProducer.Close(); err != nil {
-			log.Printf("Ошибка при закрытии DLQ producer: %v", err)
+	// Перенос заказов старше срока хранения в архив (ORDER_RETENTION)
+	svc.StartArchiving(cfg.OrderRetention, cfg.ArchiveInterval)
+
+	// Consumer, DLQ (<топик>-dlq) и топик повторов для каждого топика KAFKA_TOPICS
+	startOffset, err := kafka.ParseStartOffset(cfg.KafkaStartOffset)
+	if err != nil {
+		log.Fatalf("Некорректное начальное смещение Kafka: %v", err)
+	}
+	consumerOpts := kafka.ConsumerOptions{
+		CommitInterval: cfg.KafkaCommitInterval,
+		StartOffset:    startOffset,
+		MinBytes:       cfg.KafkaMinBytes,
+		MaxBytes:       cfg.KafkaMaxBytes,
+		MaxWait:        cfg.KafkaMaxWait,
+		Connection:     kafkaConn,
+	}
+	var (
+		consumers    []*kafka.Consumer
+		retryReaders []*kafka.RetryReader
+		dlqProducer  *kafka.DLQProducer // DLQ топика KAFKA_TOPIC для повторной обработки через API
+	)
+	for i, topic := range cfg.KafkaTopics {
+		// Создание DLQ producer для обработки неудачных сообщений
+		topicDLQ := kafka.NewDLQProducer(cfg.KafkaBrokers, kafka.DLQTopic(topic), writerOpts)
+		topicDLQ.SetConsumerGroup(cfg.KafkaGroupID)
+		defer func() {
+			if err := topicDLQ.Close(); err != nil {
+				log.Printf("Ошибка при закрытии DLQ producer топика %s: %v", topic, err)
+			}
+		}()
+		if topic == cfg.KafkaTopic {
+			dlqProducer = topicDLQ
 		}
-	}()
 
-	// Создание Kafka consumer для обработки новых заказов с DLQ
-	kafkaConsumer := kafka.NewConsumerWithDLQ(cfg.KafkaBrokers, cfg.KafkaTopic, cfg.KafkaGroupID, dlqProducer)
+		// Создание Kafka consumer для обработки новых заказов с DLQ; закрывается через kafkaConsumer
+		consumer := kafka.NewConsumerWithDLQ(cfg.KafkaBrokers, topic, cfg.KafkaGroupID, consumerOpts, topicDLQ)
+		consumer.SetConcurrency(cfg.KafkaConsumerConcurrency)
+		consumer.SetBatch(cfg.KafkaConsumerBatchSize, cfg.KafkaConsumerBatchTimeout, svc.ProcessOrders)
+		consumer.SetCodec(codec)
+		consumer.SetDedup(cfg.KafkaDedupWindow, cfg.KafkaDedupMaxEntries)
+		consumer.SetDelivery(kafka.DeliveryMode(cfg.KafkaDeliveryMode), cfg.KafkaDeliveryBackoff, cfg.KafkaDeliveryMaxFailures)
+		consumer.StartLagReporter(cfg.KafkaLagInterval)
+		consumers = append(consumers, consumer)
+
+		// Топик отложенных повторов: заказы, не обработанные из-за сбоя, повторяются по расписанию
+		// KAFKA_RETRY_DELAYS и только после KAFKA_RETRY_MAX_CYCLES циклов уходят в DLQ
+		if cfg.KafkaRetryMaxCycles > 0 {
+			retryTopic := cfg.KafkaRetryTopics[i]
+			retryProducer := kafka.NewRetryProducer(cfg.KafkaBrokers, retryTopic, cfg.KafkaRetryDelays, writerOpts)
+			defer func() {
+				if err := retryProducer.Close(); err != nil {
+					log.Printf("Ошибка при закрытии producer топика повторов %s: %v", retryTopic, err)
+				}
+			}()
+			consumer.SetRetry(retryProducer)
+			retryReader, err := kafka.NewRetryReader(kafka.RetryReaderConfig{
+				Brokers:    cfg.KafkaBrokers,
+				Topic:      topic,
+				RetryTopic: retryTopic,
+				GroupID:    cfg.KafkaGroupID,
+				MaxCycles:  cfg.KafkaRetryMaxCycles,
+				Retry:      retryProducer,
+				DLQ:        topicDLQ,
+				Connection: kafkaConn,
+			})
+			if err != nil {
+				log.Fatalf("Ошибка создания читателя топика повторов %s: %v", retryTopic, err)
+			}
+			retryReaders = append(retryReaders, retryReader)
+		}
+	}
+	kafkaConsumer := kafka.NewMultiConsumer(consumers...)
 	defer func() {
 		if err := kafkaConsumer.Close(); err != nil {
 			log.Printf("Ошибка при закрытии Kafka consumer: %v", err)
@@ -96,98 +255,149 @@ func main() {
 	}()
 
 	// Создание Kafka producer для демонстрации поступления новых заказов
-	kafkaProducer := kafka.NewProducer(cfg.KafkaBrokers, cfg.KafkaTopic)
+	kafkaProducer := kafka.NewProducer(cfg.KafkaBrokers, cfg.KafkaTopic, writerOpts)
+	kafkaProducer.SetCodec(codec)
 	defer func() {
 		if err := kafkaProducer.Close(); err != nil {
 			log.Printf("Ошибка при закрытии Kafka producer: %v", err)
 		}
 	}()
 
-	// Контекст для управления Kafka consumer
-	consumerCtx, cancelConsumer := context.WithCancel(ctx)
-	defer cancelConsumer()
+	// Жизненный цикл: компоненты запускаются в порядке регистрации и останавливаются в обратном
+	// (HTTP сервер → pprof → демо-продюсер → публикатор outbox → читатели топиков повторов → consumer →
+	// обслуживание секций)
+	lc := lifecycle.New(cfg.ShutdownDrainTimeout)
 
-	// Запуск Kafka consumer в отдельной горутине
-	consumerDone := make(chan struct{})
-	go func() {
-		log.Printf("Начало работы Kafka consumer для: %s", cfg.KafkaTopic)
-		if err := kafkaConsumer.Consume(consumerCtx, svc.ProcessOrder); err != nil {
+	// Месячные секции заказов (если таблицы секционированы): создание на DB_PARTITIONS_AHEAD месяцев
+	// вперед и удаление старше DB_PARTITION_RETENTION; удаленные заказы убираются и из кэша
+	if db.Partitioned() && cfg.PartitionsAhead > 0 {
+		partitions := database.NewPartitionManager(db, database.PartitionConfig{
+			Ahead:     cfg.PartitionsAhead,
+			Retention: cfg.PartitionRetention,
+			Interval:  cfg.PartitionInterval,
+		})
+		partitions.SetOnDropped(svc.EvictOrdersCreatedBefore)
+		lc.Go("partition-manager", partitions.Run)
+	}
+
+	// Kafka consumer
+	lc.Go("kafka-consumer", func(ctx context.Context) {
+		log.Printf("Начало работы Kafka consumer для: %s", strings.Join(cfg.KafkaTopics, ", "))
+		if err := kafkaConsumer.Consume(ctx, svc.ProcessOrder); err != nil {
 			log.Printf("Ошибка работы в Kafka consumer: %v", err)
 		}
-		close(consumerDone)
-	}()
-
-	// Запуск Kafka producer в отдельной горутине для демонстрации поступления заказов
-	producerCtx, cancelProducer := context.WithCancel(ctx)
-	defer cancelProducer()
+	})
+	for i, retryReader := range retryReaders {
+		retryTopic := cfg.KafkaRetryTopics[i]
+		lc.Go("kafka-retry-consumer-"+retryTopic, func(ctx context.Context) {
+			log.Printf("Начало чтения топика повторов: %s", retryTopic)
+			if err := retryReader.Run(ctx, svc.ProcessOrder); err != nil {
+				log.Printf("Ошибка работы читателя топика повторов %s: %v", retryTopic, err)
+			}
+		})
+	}
 
-	producerDone := make(chan struct{})
-	go func() {
-		log.Printf("Начало отправки тестовых заказов в Kafka: %s", cfg.KafkaTopic)
-		ticker := time.NewTicker(5 * time.Second) // Отправляем заказ каждые 5 секунд
-		defer ticker.Stop()
-
-		orderCounter := 1
-		for {
-			select {
-			case <-producerCtx.Done():
-				close(producerDone)
-				return
-			case <-ticker.C:
-				order := kafka.GenerateTestOrder(orderCounter)
-				if err := kafkaProducer.SendOrderWithContext(producerCtx, order); err != nil {
-					log.Printf("Ошибка отправки тестового заказа: %v", err)
-				} else {
-					log.Printf("Отправлен тестовый заказ в Kafka: %s", order.OrderUID)
-				}
-				orderCounter++
+	// Публикатор событий outbox в OUTBOX_TOPIC; события отправляет только лидер
+	if cfg.OutboxTopic != "" {
+		outboxProducer := kafka.NewProducer(cfg.KafkaBrokers, cfg.OutboxTopic, writerOpts)
+		defer func() {
+			if err := outboxProducer.Close(); err != nil {
+				log.Printf("Ошибка при закрытии Kafka producer outbox: %v", err)
 			}
-		}
-	}()
+		}()
+		publisher := outbox.NewPublisher(db, outboxProducer, cfg.OutboxBatchSize, cfg.OutboxPollInterval)
+
+		lc.Go("outbox-publisher", func(ctx context.Context) {
+			log.Printf("Начало публикации событий outbox в Kafka: %s", cfg.OutboxTopic)
+			elector := leader.NewElector("outbox-publisher", outboxPublisherLocker(db), 10*time.Second,
+				outbox.NewMetrics().Leader)
+			elector.Run(ctx, publisher.Run)
+		})
+	}
 
-	// Создание HTTP обработчиков
-	h := handler.New(svc)
+	// Kafka producer для демонстрации поступления заказов
+	if cfg.DemoProducerEnabled {
+		lc.Go("demo-producer", func(ctx context.Context) {
+			log.Printf("Начало отправки тестовых заказов в Kafka: %s", cfg.KafkaTopic)
+
+			// Счетчик начинается со времени старта, чтобы новые лидеры не повторяли UID предыдущих
+			orderCounter := int(time.Now().Unix())
+			// Заказы генерирует только реплика, удерживающая advisory-блокировку
+			elector := leader.NewElector("demo-producer", demoProducerLocker(db), 10*time.Second,
+				kafka.NewKafkaMetrics().DemoProducerLeader)
+			elector.Run(ctx, func(ctx context.Context) {
+				runDemoProducer(ctx, kafkaProducer, cfg.DemoProducerInterval, &orderCounter)
+			})
+		})
+	}
 
-	// Настройка HTTP маршрутов
+	// Маршруты вне API: метрики и статика
 	mux := http.NewServeMux()
-	mux.HandleFunc("/order/", h.GetOrder)      // API для получения заказа
-	mux.HandleFunc("/health", h.HealthCheck)   // Проверка состояния сервиса
-	mux.HandleFunc("/stats", h.Stats)          // Статистика сервиса
 	mux.Handle("/metrics", promhttp.Handler()) // Endpoint для метрик Prometheus (используем глобальный реестр)
 
 	// Статические файлы и корневая страница
-	staticFS := http.Dir(cfg.StaticDir)
-	log.Printf("Обслуживание статических файлов из: %s", cfg.StaticDir)
-	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(staticFS)))
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		// Если запрос корня — сразу index.html
-		if r.URL.Path == "/" {
-			http.ServeFile(w, r, filepath.Join(cfg.StaticDir, "index.html"))
-			return
-		}
-		// Проверяем существование файла в STATIC_DIR безопасно
-		candidate := filepath.Clean(filepath.Join(cfg.StaticDir, r.URL.Path))
-		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
-			http.ServeFile(w, r, candidate)
-			return
+	if static, err := staticFS(cfg); err != nil {
+		if !cfg.StaticOptional {
+			log.Fatalf("Ошибка проверки статики: %v (задайте STATIC_DIR, STATIC_EMBED=true или STATIC_OPTIONAL=true)", err)
 		}
-		// Фоллбэк на index.html
-		http.ServeFile(w, r, filepath.Join(cfg.StaticDir, "index.html"))
+		// Статика необязательна: SPA маршруты отключены, на остальные пути — JSON 404
+		log.Printf("Статика отключена: %v", err)
+		mux.HandleFunc("/", handler.NotFound)
+	} else {
+		mux.Handle("/static/", http.StripPrefix("/static/", http.FileServerFS(static)))
+		mux.Handle("/", handler.SPA(static))
+	}
+
+	// Состояние реплики для чтения в /readyz, только если она настроена
+	var checkReplica func(ctx context.Context) error
+	if db.HasReplica() {
+		checkReplica = db.PingReplica
+	}
+
+	// Маршруты API (/api/v1/) поверх статики; access log для всех маршрутов, включая фоллбэк статики
+	routes := handler.Routes(svc, handler.Options{
+		AdminAPIKey:      cfg.AdminAPIKey,
+		DLQReplayer:      kafka.NewDLQConsumer(cfg.KafkaBrokers, cfg.KafkaTopic, cfg.KafkaGroupID, kafkaConn, dlqProducer),
+		DLQReplayTimeout: cfg.DLQReplayTimeout,
+		Ready:            lc.Ready,
+		CheckDatabase:    svc.HealthStatus,
+		CheckReplica:     checkReplica,
+		Events:           svc.Events(),
+		Fallback:         mux,
 	})
+	rootHandler := lc.Track(handler.AccessLog(routes, cfg.AccessLogSkipPaths...))
 
 	// Создание HTTP сервера
 	server := &http.Server{
-		Addr:    cfg.ServerAddr,
-		Handler: mux,
+		Addr:              cfg.ServerAddr,
+		Handler:           rootHandler,
+		ReadTimeout:       cfg.HTTPReadTimeout,       // Защита от медленных клиентов (slow-loris)
+		ReadHeaderTimeout: cfg.HTTPReadHeaderTimeout, // Ограничение времени чтения заголовков
+		WriteTimeout:      cfg.HTTPWriteTimeout,      // Потоковые маршруты снимают его через handler.WithoutWriteTimeout
+		IdleTimeout:       cfg.HTTPIdleTimeout,       // Закрытие простаивающих keep-alive соединений
+		MaxHeaderBytes:    cfg.HTTPMaxHeaderBytes,    // Ограничение размера заголовков
 	}
 
-	// Запуск HTTP сервера в отдельной горутине
-	go func() {
-		log.Printf("Сервер запущен на %s", cfg.ServerAddr)
-		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			log.Fatalf("Ошибка сервера:%v", err)
-		}
-	}()
+	// Shutdown не ждет WebSocket соединений (они перехвачены у сервера): закрываем их через шину событий
+	server.RegisterOnShutdown(svc.Events().Close)
+
+	// Внутренний сервер pprof, если он включен
+	if pprofServer := newPprofServer(cfg); pprofServer != nil {
+		lc.Append("pprof", func() error {
+			log.Printf("Эндпоинты pprof доступны на %s/debug/pprof/", cfg.PprofAddr)
+			return serve(pprofServer, func(err error) { log.Printf("Ошибка сервера pprof: %v", err) })
+		}, pprofServer.Shutdown)
+	}
+
+	// HTTP сервер запускается последним и останавливается первым, дожидаясь запросов в обработке
+	lc.Append("http", func() error {
+		return serve(server, func(err error) { log.Fatalf("Ошибка сервера:%v", err) })
+	}, server.Shutdown)
+
+	if err := lc.Start(); err != nil {
+		log.Fatalf("Ошибка запуска: %v", err)
+	}
+	log.Printf("Сервер запущен на %s", cfg.ServerAddr)
 
 	// Ожидание сигнала для graceful shutdown
 	signalChan := make(chan os.Signal, 1)
@@ -196,27 +406,49 @@ func main() {
 
 	log.Println("Остановка сервера")
 
-	// Graceful shutdown с таймаутом 30 секунд
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	// Readiness снимается сразу, компоненты останавливаются после окна drain
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.ShutdownDrainTimeout+cfg.ShutdownTimeout)
 	defer shutdownCancel()
 
-	if err := server.Shutdown(shutdownCtx); err != nil {
-		log.Printf("ошибка:%v", err)
-	}
-	cancelConsumer()
-	cancelProducer()
-	// Дожидаемся завершения consumer и producer
-	select {
-	case <-consumerDone:
-	case <-time.After(10 * time.Second):
-		log.Println("Таймаут ожидания остановки consumer")
+	if err := lc.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Ошибка остановки: %v", err)
 	}
 
-	select {
-	case <-producerDone:
-	case <-time.After(5 * time.Second):
-		log.Println("Таймаут ожидания остановки producer")
-	}
+	// Консьюмер остановлен, кэш больше не меняется: сохраняем снимок и закрываем сервис
+	svc.Close()
 
 	log.Println("Сервер остановлен успешно")
 }
+
+// staticFS выбирает источник статики: встроенный в бинарник (STATIC_EMBED=true)
+// или каталог STATIC_DIR для локальной разработки
+func staticFS(cfg *config.Config) (fs.FS, error) {
+	if cfg.StaticEmbed {
+		static := web.Static()
+		if err := handler.ValidateStatic(static); err != nil {
+			return nil, fmt.Errorf("встроенная статика: %w", err)
+		}
+		log.Println("Обслуживание встроенных статических файлов")
+		return static, nil
+	}
+	if err := handler.ValidateStaticDir(cfg.StaticDir); err != nil {
+		return nil, err
+	}
+	log.Printf("Обслуживание статических файлов из: %s", cfg.StaticDir)
+	return os.DirFS(cfg.StaticDir), nil
+}
+
+// serve открывает listener синхронно (ошибка порта возвращается сразу) и обслуживает
+// соединения в отдельной горутине; onError вызывается при аварийном завершении Serve
+func serve(server *http.Server, onError func(error)) error {
+	ln, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			onError(err)
+		}
+	}()
+	return nil
+}
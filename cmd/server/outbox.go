@@ -0,0 +1,15 @@
+package main
+
+import (
+	"test_service/internal/database"
+	"test_service/internal/leader"
+)
+
+// outboxPublisherLockKey фиксированный ключ advisory-блокировки лидера публикатора outbox
+const outboxPublisherLockKey int64 = 0x6f7574626f78 // "outbox"
+
+// outboxPublisherLocker захватывает лидерство публикатора outbox: события отправляет одна реплика,
+// поэтому они уходят в порядке id без блокировки строк
+func outboxPublisherLocker(db *database.Postgres) leader.Locker {
+	return advisoryLocker(db, outboxPublisherLockKey)
+}
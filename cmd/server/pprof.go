@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/http"
+	"net/http/pprof"
+
+	"test_service/internal/config"
+)
+
+// newPprofServer создает внутренний HTTP сервер с эндпоинтами /debug/pprof/.
+// Возвращает nil, если профилирование выключено.
+func newPprofServer(cfg *config.Config) *http.Server {
+	if !cfg.EnablePprof {
+		return nil
+	}
+
+	// Отдельный mux, чтобы профили никогда не были доступны через публичный порт
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	return &http.Server{
+		Addr:              cfg.PprofAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: cfg.HTTPReadHeaderTimeout,
+		IdleTimeout:       cfg.HTTPIdleTimeout,
+		// WriteTimeout не задаем: профили CPU и трассировки пишутся дольше обычного ответа
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"test_service/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPprofServer(t *testing.T) {
+	t.Run("Enabled", func(t *testing.T) {
+		server := newPprofServer(&config.Config{EnablePprof: true, PprofAddr: "localhost:6060"})
+		require.NotNil(t, server)
+		assert.Equal(t, "localhost:6060", server.Addr)
+
+		for _, path := range []string{"/debug/pprof/", "/debug/pprof/heap", "/debug/pprof/cmdline"} {
+			rec := httptest.NewRecorder()
+			server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			assert.Equal(t, http.StatusOK, rec.Code, "эндпоинт %s должен отвечать", path)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		assert.Nil(t, newPprofServer(&config.Config{EnablePprof: false, PprofAddr: "localhost:6060"}))
+	})
+}
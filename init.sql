@@ -28,7 +28,8 @@ CREATE TABLE IF NOT EXISTS orders (
     shardkey VARCHAR(255),
     sm_id INTEGER,
     date_created TIMESTAMP,
-    oof_shard VARCHAR(255)
+    oof_shard VARCHAR(255),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 
 CREATE TABLE IF NOT EXISTS delivery (
@@ -75,7 +76,7 @@ CREATE TABLE IF NOT EXISTS items (
 -- Вставка тестовых данных
 INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard)
 VALUES (
-           'b563feb7b2b84b6test',
+           'b563feb7b2b84b6test0000000000000',
            'WBILMTESTTRACK',
            'WBIL',
            'en',
@@ -90,7 +91,7 @@ VALUES (
 
 INSERT INTO delivery (order_uid, name, phone, zip, city, address, region, email)
 VALUES (
-           'b563feb7b2b84b6test',
+           'b563feb7b2b84b6test0000000000000',
            'Test Testov',
            '+9720000000',
            '2639809',
@@ -102,7 +103,7 @@ VALUES (
 
 INSERT INTO payment (order_uid, transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee)
 VALUES (
-           'b563feb7b2b84b6test',
+           'b563feb7b2b84b6test0000000000000',
            'b563feb7b2b84b6test',
            '',
            'USD',
@@ -117,7 +118,7 @@ VALUES (
 
 INSERT INTO items (order_uid, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status)
 VALUES (
-           'b563feb7b2b84b6test',
+           'b563feb7b2b84b6test0000000000000',
            9934930,
            'WBILMTESTTRACK',
            453,
@@ -2,7 +2,10 @@
 package cache
 
 import (
-	"sync"
+	"container/list"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"test_service/internal/models"
@@ -11,109 +14,725 @@ import (
 // CachedOrderItem кэшированный заказ со сроком жизни
 type CachedOrderItem struct {
 	order      *models.Order
-	expireTime time.Time
+	expireTime time.Time     // Время истечения (нулевое — заказ не истекает)
+	ttl        time.Duration // Время жизни, отсчитываемое заново при продлении (<= 0 — не истекает)
+	deadline   time.Time     // Предельный срок жизни при скользящем TTL (нулевой — без предела)
+	size       int64         // Оценка занимаемой памяти в байтах
 }
 
-// Cache представляет кэш для хранения заказов в памяти
+// expired сообщает, истек ли заказ к моменту now; заказ без срока жизни не истекает
+func (item *CachedOrderItem) expired(now time.Time) bool {
+	return !item.expireTime.IsZero() && now.After(item.expireTime)
+}
+
+// Cache представляет кэш для хранения заказов в памяти.
+// TTL <= 0 (в New или SetWithTTL) означает, что заказ не истекает; такие заказы
+// по-прежнему вытесняются ограничениями WithMaxEntries и WithMaxBytes.
+// Заказы распределены по сегментам (shard) с отдельными блокировками по хешу UID,
+// поэтому запись из Kafka не блокирует чтение заказов из других сегментов.
+// При заданных WithMaxEntries или WithMaxBytes вытесняются давно не использованные заказы (LRU).
+// Ограничение делится поровну между сегментами, и каждый сегмент ведет свой порядок LRU:
+// вытесняется давний заказ того же сегмента, а не всего кэша. Небольшое ограничение
+// (меньше minShardEntries или minShardBytes на сегмент) уменьшает число сегментов, вплоть до одного
+// с точным глобальным порядком LRU.
 type Cache struct {
-	mu     sync.RWMutex                // Мьютекс для безопасного доступа
-	orders map[string]*CachedOrderItem // Словарь заказов по их UID с временем истечения
-	ttl    time.Duration               // Время жизни элемента кэша
+	shards     []*shard      // Сегменты кэша
+	ttl        time.Duration // Время жизни элемента кэша
+	maxEntries int           // Максимум заказов в кэше (0 — без ограничения)
+	maxBytes   int64         // Бюджет памяти в байтах (0 — без ограничения)
+	bytes      atomic.Int64  // Оценка памяти, занятой заказами всех сегментов
+	evicted    atomic.Uint64 // Количество вытесненных заказов
+	metrics    *Metrics      // Метрики кэша
+	shardCount int           // Число сегментов; 0 — выбрать по ограничениям
+
+	shardMaxEntries int   // Максимум заказов в сегменте (0 — без ограничения)
+	shardMaxBytes   int64 // Бюджет памяти сегмента (0 — без ограничения)
+
+	sliding  bool          // Продлевать TTL при каждом успешном Get
+	maxStale time.Duration // Сколько после истечения заказ можно отдавать устаревшим (0 — нельзя)
+	shared   bool          // Хранить и отдавать заказы без копирования
+
+	cleanupInterval time.Duration // Период фоновой очистки, запускаемой в New (0 — не запускать)
+	janitor         janitor       // Фоновая очистка истекших заказов
+	hooks           hooks         // Обработчики удаления заказов (OnEvict)
+	loads           loads         // Выполняющиеся загрузки GetOrSet
+	maxLifetime     time.Duration // Предельный срок жизни заказа со скользящим TTL (0 — без предела)
+	clock           Clock         // Источник текущего времени для проверки сроков жизни
+}
+
+// Option настройка кэша
+type Option func(*Cache)
+
+// WithMaxEntries ограничивает число заказов в кэше; при превышении вытесняются
+// давно не использованные. n <= 0 означает отсутствие ограничения.
+func WithMaxEntries(n int) Option {
+	return func(c *Cache) {
+		if n > 0 {
+			c.maxEntries = n
+		}
+	}
+}
+
+// WithMaxBytes ограничивает приблизительный объем памяти, занятой заказами; при превышении
+// вытесняются давно не использованные. Заказ больше доли бюджета одного сегмента не кэшируется.
+// n <= 0 означает отсутствие ограничения.
+func WithMaxBytes(n int64) Option {
+	return func(c *Cache) {
+		if n > 0 {
+			c.maxBytes = n
+		}
+	}
+}
+
+// WithSlidingTTL включает скользящий TTL: каждый успешный Get продлевает срок жизни
+// заказа на ttl, чтобы часто запрашиваемые заказы не истекали. По умолчанию TTL фиксированный.
+func WithSlidingTTL(enabled bool) Option {
+	return func(c *Cache) {
+		c.sliding = enabled
+	}
+}
+
+// WithMaxLifetime ограничивает срок жизни заказа с момента записи в кэш, сколько бы
+// его ни продлевал скользящий TTL. d <= 0 означает отсутствие предела.
+func WithMaxLifetime(d time.Duration) Option {
+	return func(c *Cache) {
+		if d > 0 {
+			c.maxLifetime = d
+		}
+	}
+}
+
+// WithStaleWhileRevalidate включает режим stale-while-revalidate: истекший заказ хранится
+// еще maxStale и отдается Lookup с признаком stale, пока сервис обновляет его из БД в фоне.
+// Get по-прежнему считает истекшие заказы отсутствующими. maxStale <= 0 отключает режим.
+func WithStaleWhileRevalidate(maxStale time.Duration) Option {
+	return func(c *Cache) {
+		if maxStale > 0 {
+			c.maxStale = maxStale
+		}
+	}
+}
+
+// WithCopies управляет копированием заказов. По умолчанию кэш хранит копии переданных
+// заказов и отдает копии, поэтому изменение полученного заказа не портит данные других
+// читателей. WithCopies(false) убирает стоимость копирования; тогда заказы, переданные в кэш
+// и полученные из него, изменять нельзя.
+func WithCopies(enabled bool) Option {
+	return func(c *Cache) {
+		c.shared = !enabled
+	}
+}
+
+// withShards задает число сегментов (для тестов и бенчмарков); с ограничением размера сегментов
+// не больше, чем заказов или байт в ограничении
+func withShards(n int) Option {
+	return func(c *Cache) {
+		c.shardCount = n
+	}
 }
 
 // New создает новый экземпляр кэша
-func New(ttl time.Duration) *Cache {
-	return &Cache{
-		orders: make(map[string]*CachedOrderItem), // Инициализируем пустой словарь
-		ttl:    ttl,                               // Устанавливаем время жизни
+func New(ttl time.Duration, opts ...Option) *Cache {
+	c := &Cache{
+		ttl:     ttl, // Устанавливаем время жизни
+		metrics: NewMetrics(),
+		clock:   realClock{},
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	c.shardCount = c.shardsFor(c.shardCount)
+	if c.maxEntries > 0 {
+		c.shardMaxEntries = c.maxEntries / c.shardCount
+	}
+	if c.maxBytes > 0 {
+		c.shardMaxBytes = c.maxBytes / int64(c.shardCount)
+	}
+	c.shards = make([]*shard, c.shardCount)
+	for i := range c.shards {
+		c.shards[i] = newShard()
+	}
+
+	c.metrics.BytesBudget.Set(float64(c.maxBytes))
+	c.StartJanitor(c.cleanupInterval)
+	return c
 }
 
-// Set добавляет или обновляет заказ в кэше
+// shardsFor выбирает число сегментов: requested (<= 0 — defaultShards), но с ограничением размера
+// не больше, чем помещается сегментов по minShardEntries заказов и minShardBytes байт. Доли
+// ограничения округляются вниз, поэтому сумма по сегментам не превышает ограничения кэша.
+func (c *Cache) shardsFor(requested int) int {
+	n := requested
+	if n <= 0 {
+		n = defaultShards
+		if c.maxEntries > 0 {
+			n = min(n, c.maxEntries/minShardEntries)
+		}
+		if c.maxBytes > 0 {
+			n = min(n, int(min(c.maxBytes/minShardBytes, int64(defaultShards))))
+		}
+	}
+	// Каждому сегменту нужен хотя бы один заказ и один байт ограничения
+	if c.maxEntries > 0 {
+		n = min(n, c.maxEntries)
+	}
+	if c.maxBytes > 0 {
+		n = int(min(int64(n), c.maxBytes))
+	}
+	return max(n, 1)
+}
+
+// bounded сообщает, задано ли ограничение, для которого нужен порядок использования
+func (c *Cache) bounded() bool {
+	return c.maxEntries > 0 || c.maxBytes > 0
+}
+
+// exclusiveReads сообщает, изменяет ли чтение состояние кэша (порядок LRU или срок жизни)
+func (c *Cache) exclusiveReads() bool {
+	return c.bounded() || c.sliding
+}
+
+// expiry вычисляет срок жизни ttl, отсчитанный от now, с учетом предельного срока;
+// при ttl <= 0 возвращает нулевое время — заказ не истекает
+func expiry(now time.Time, ttl time.Duration, deadline time.Time) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	expire := now.Add(ttl)
+	if !deadline.IsZero() && expire.After(deadline) {
+		return deadline
+	}
+	return expire
+}
+
+// copyOf возвращает копию заказа, если кэш не настроен на работу без копирования
+func (c *Cache) copyOf(order *models.Order) *models.Order {
+	if c.shared {
+		return order
+	}
+	return order.Clone()
+}
+
+// shardFor возвращает сегмент, в котором хранится заказ
+func (c *Cache) shardFor(orderUID string) *shard {
+	return c.shards[shardIndex(orderUID, len(c.shards))]
+}
+
+// addBytes учитывает изменение занятой памяти в общем счетчике и метрике
+func (c *Cache) addBytes(delta int64) {
+	if delta != 0 {
+		c.metrics.Bytes.Set(float64(c.bytes.Add(delta)))
+	}
+}
+
+// Set добавляет или обновляет заказ в кэше со временем жизни кэша
 func (c *Cache) Set(order *models.Order) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.orders[order.OrderUID] = &CachedOrderItem{
-		order:      order,
-		expireTime: time.Now().Add(c.ttl), // Устанавливаем время истечения
-	} // Сохраняем заказ по его UID
+	c.SetWithTTL(order, c.ttl)
 }
 
-// Get получает заказ из кэша по его UID
-func (c *Cache) Get(orderUID string) (*models.Order, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+// SetWithTTL добавляет или обновляет заказ в кэше с собственным временем жизни.
+// ttl <= 0 означает, что заказ не истекает (например, демонстрационные заказы для UI),
+// но при ограничении размера он вытесняется наравне с остальными.
+func (c *Cache) SetWithTTL(order *models.Order, ttl time.Duration) {
+	var removed []eviction
+	defer func() { c.notify(removed) }() // Уведомляем после снятия блокировки
+
+	s := c.shardFor(order.OrderUID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before := s.bytes
+	c.set(s, order, ttl, &removed)
+	c.evict(s, &removed)
+	c.addBytes(s.bytes - before)
+}
 
-	item, exists := c.orders[orderUID] // Проверяем наличие элемента
+// set сохраняет заказ по его UID со временем жизни ttl и помечает его недавно использованным;
+// вызывается под s.mu. Удаленные заказы добавляются в out (nil — не собирать).
+func (c *Cache) set(s *shard, order *models.Order, ttl time.Duration, out *[]eviction) {
+	now := c.clock.Now()
+	order = c.copyOf(order) // Вызывающий может изменить или переиспользовать свой заказ
+	item := &CachedOrderItem{
+		order: order,
+		ttl:   ttl,
+		size:  estimateSize(order),
+	}
+	if c.sliding && c.maxLifetime > 0 && ttl > 0 {
+		item.deadline = now.Add(c.maxLifetime)
+	}
+	item.expireTime = expiry(now, ttl, item.deadline) // Устанавливаем время истечения
+	el, exists := s.orders[order.OrderUID]
+	if c.shardMaxBytes > 0 && item.size > c.shardMaxBytes {
+		// Заказ не поместится даже в пустой сегмент: не вытесняем ради него остальные,
+		// а устаревшую версию удаляем, чтобы не отдавать ее
+		if exists {
+			remove(s, el)
+			c.record(out, order.OrderUID, EvictEvicted)
+		}
+		return
+	}
+	s.bytes += item.size
+	s.index(order)
+	if exists {
+		previous := el.Value.(*CachedOrderItem)
+		s.bytes -= previous.size
+		// Трек-номер мог измениться при пересохранении: старая запись индекса больше не верна
+		if previous.order.TrackNumber != order.TrackNumber {
+			s.unindex(previous.order)
+		}
+		el.Value = item
+		s.lru.MoveToFront(el)
+		return
+	}
+	s.orders[order.OrderUID] = s.lru.PushFront(item)
+}
+
+// evict вытесняет давно не использованные заказы сверх ограничения; вызывается под s.mu.
+// Вытесненные заказы добавляются в out (nil — не собирать).
+func (c *Cache) evict(s *shard, out *[]eviction) {
+	for s.lru.Len() > 0 && c.overLimit(s) {
+		el := s.lru.Back()
+		remove(s, el)
+		c.record(out, el.Value.(*CachedOrderItem).order.OrderUID, EvictEvicted)
+		c.evicted.Add(1)
+		c.metrics.EvictionsTotal.Inc()
+	}
+}
+
+// overLimit проверяет превышение долей ограничений сегмента по числу заказов и памяти;
+// вызывается под s.mu
+func (c *Cache) overLimit(s *shard) bool {
+	return (c.shardMaxEntries > 0 && s.lru.Len() > c.shardMaxEntries) ||
+		(c.shardMaxBytes > 0 && s.bytes > c.shardMaxBytes)
+}
+
+// remove удаляет элемент из словаря, списка и индекса сегмента; вызывается под s.mu
+func remove(s *shard, el *list.Element) {
+	item := el.Value.(*CachedOrderItem)
+	delete(s.orders, item.order.OrderUID)
+	s.unindex(item.order)
+	s.lru.Remove(el)
+	s.bytes -= item.size
+}
+
+// lock захватывает блокировку сегмента для чтения заказа.
+// Без ограничения размера и скользящего TTL чтение ничего не меняет и идет под RLock.
+func (c *Cache) lock(s *shard) (unlock func()) {
+	if c.exclusiveReads() {
+		s.mu.Lock()
+		return s.mu.Unlock
+	}
+	s.mu.RLock()
+	return s.mu.RUnlock
+}
+
+// lookup находит неистекший заказ, помечает его недавно использованным и при скользящем TTL
+// продлевает срок жизни; вызывается под lock(s)
+func (c *Cache) lookup(s *shard, orderUID string, now time.Time) (*models.Order, bool) {
+	el, exists := s.orders[orderUID] // Проверяем наличие элемента
 	if !exists {
 		return nil, false
 	}
+	item := el.Value.(*CachedOrderItem)
 
 	// Проверяем, не истекло ли время жизни
-	if time.Now().After(item.expireTime) {
+	if item.expired(now) {
 		return nil, false // Элемент истек, считаем что не существует
 	}
 
+	if c.bounded() {
+		s.lru.MoveToFront(el)
+	}
+	if c.sliding {
+		item.expireTime = expiry(now, item.ttl, item.deadline)
+	}
 	return item.order, true
 }
 
-// GetAll возвращает все заказы из кэша
-func (c *Cache) GetAll() []*models.Order {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	// Создаем слайс с предварительно выделенной емкостью
-	orders := make([]*models.Order, 0, len(c.orders))
-	now := time.Now()
-	for _, item := range c.orders {
-		// Пропускаем истекшие элементы
-		if now.After(item.expireTime) {
+// Get получает заказ из кэша по его UID
+func (c *Cache) Get(orderUID string) (*models.Order, bool) {
+	s := c.shardFor(orderUID)
+	defer c.lock(s)()
+	order, ok := c.lookup(s, orderUID, c.clock.Now())
+	return c.copyOf(order), ok
+}
+
+// Lookup получает заказ так же, как Get, но в режиме stale-while-revalidate истекший
+// не более maxStale назад заказ возвращается с stale = true. Вызывающий отвечает за обновление.
+func (c *Cache) Lookup(orderUID string) (order *models.Order, stale, ok bool) {
+	s := c.shardFor(orderUID)
+	defer c.lock(s)()
+
+	now := c.clock.Now()
+	if order, ok := c.lookup(s, orderUID, now); ok {
+		return c.copyOf(order), false, true
+	}
+	if c.maxStale <= 0 {
+		return nil, false, false
+	}
+	el, exists := s.orders[orderUID]
+	if !exists || el.Value.(*CachedOrderItem).expired(now.Add(-c.maxStale)) {
+		return nil, false, false
+	}
+	c.metrics.StaleServesTotal.Inc()
+	return c.copyOf(el.Value.(*CachedOrderItem).order), true, true
+}
+
+// Revalidate заменяет истекший заказ свежей версией из БД и сообщает, была ли замена.
+// Если за время обновления заказ записали заново (Set) или удалили, свежая запись
+// не перезаписывается, а удаленный заказ не возвращается в кэш.
+func (c *Cache) Revalidate(order *models.Order) bool {
+	var removed []eviction
+	defer func() { c.notify(removed) }()
+
+	s := c.shardFor(order.OrderUID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, exists := s.orders[order.OrderUID]
+	if !exists || !el.Value.(*CachedOrderItem).expired(c.clock.Now()) {
+		return false
+	}
+	before := s.bytes
+	c.set(s, order, el.Value.(*CachedOrderItem).ttl, &removed)
+	c.evict(s, &removed)
+	c.addBytes(s.bytes - before)
+	return true
+}
+
+// KeepAlive заново отсчитывает время жизни заказа от текущего момента (с учетом предельного
+// срока WithMaxLifetime) и сообщает, был ли заказ в кэше. Истекший заказ не продлевается.
+func (c *Cache) KeepAlive(orderUID string) bool {
+	s := c.shardFor(orderUID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, exists := s.orders[orderUID]
+	if !exists {
+		return false
+	}
+	item := el.Value.(*CachedOrderItem)
+	now := c.clock.Now()
+	if item.expired(now) {
+		return false
+	}
+	item.expireTime = expiry(now, item.ttl, item.deadline)
+	return true
+}
+
+// GetMulti получает несколько заказов, захватывая блокировку каждого нужного сегмента один раз.
+// Истекшие заказы, как и в Get, считаются отсутствующими; missing сохраняет порядок uids без повторов.
+func (c *Cache) GetMulti(uids []string) (found map[string]*models.Order, missing []string) {
+	// Группируем UID по сегментам, сохраняя позиции в запросе
+	byShard := make(map[int][]int)
+	for i, uid := range uids {
+		idx := shardIndex(uid, len(c.shards))
+		byShard[idx] = append(byShard[idx], i)
+	}
+
+	orders := make([]*models.Order, len(uids))
+	now := c.clock.Now()
+	for idx, positions := range byShard {
+		s := c.shards[idx]
+		unlock := c.lock(s)
+		for _, i := range positions {
+			orders[i], _ = c.lookup(s, uids[i], now)
+		}
+		unlock()
+	}
+
+	found = make(map[string]*models.Order, len(uids))
+	seen := make(map[string]struct{}, len(uids))
+	for i, uid := range uids {
+		if _, dup := seen[uid]; dup {
 			continue
 		}
-		orders = append(orders, item.order)
+		seen[uid] = struct{}{}
+		if orders[i] == nil {
+			missing = append(missing, uid)
+			continue
+		}
+		found[uid] = c.copyOf(orders[i])
+	}
+	return found, missing
+}
+
+// GetByTrackNumber получает неистекшие заказы с заданным трек-номером по вторичному индексу,
+// упорядоченные по UID; nil, если таких заказов в кэше нет. Как и Get, помечает заказы
+// недавно использованными и при скользящем TTL продлевает их срок жизни.
+func (c *Cache) GetByTrackNumber(trackNumber string) []*models.Order {
+	var orders []*models.Order
+	now := c.clock.Now()
+	for _, s := range c.shards {
+		unlock := c.lock(s)
+		for uid := range s.tracks[trackNumber] {
+			if order, ok := c.lookup(s, uid, now); ok {
+				orders = append(orders, c.copyOf(order))
+			}
+		}
+		unlock()
 	}
+	slices.SortFunc(orders, func(a, b *models.Order) int {
+		return strings.Compare(a.OrderUID, b.OrderUID)
+	})
 	return orders
 }
 
-// LoadFromSlice загружает заказы из слайса в кэш
-func (c *Cache) LoadFromSlice(orders []models.Order) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// Delete удаляет заказ из кэша по его UID и сообщает, был ли он в кэше.
+// Истекший заказ тоже удаляется, но, как и в Get, считается отсутствующим.
+func (c *Cache) Delete(orderUID string) bool {
+	var removed []eviction
+	defer func() { c.notify(removed) }()
+
+	s := c.shardFor(orderUID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, exists := s.orders[orderUID]
+	if !exists {
+		return false
+	}
+	item := el.Value.(*CachedOrderItem)
+	live := !item.expired(c.clock.Now())
+	remove(s, el)
+	c.record(&removed, orderUID, EvictDeleted)
+	c.addBytes(-item.size)
+	return live
+}
+
+// DeleteCreatedBefore удаляет заказы, созданные раньше cutoff, обходя сегменты по очереди
+// без копирования заказов. Возвращает количество удаленных неистекших заказов;
+// истекшие тоже удаляются, но, как и в Delete, не учитываются.
+func (c *Cache) DeleteCreatedBefore(cutoff time.Time) int {
+	var removed []eviction
+	defer func() { c.notify(removed) }()
 
-	// Добавляем все заказы из слайса в кэш
+	now := c.clock.Now()
+	deleted := 0
+	for _, s := range c.shards {
+		s.mu.Lock()
+		before := s.bytes
+		for uid, el := range s.orders {
+			item := el.Value.(*CachedOrderItem)
+			if !item.order.DateCreated.Before(cutoff) {
+				continue
+			}
+			if !item.expired(now) {
+				deleted++
+			}
+			remove(s, el)
+			c.record(&removed, uid, EvictDeleted)
+		}
+		c.addBytes(s.bytes - before)
+		s.mu.Unlock()
+	}
+	return deleted
+}
+
+// GetAll возвращает все заказы из кэша, обходя сегменты по очереди
+func (c *Cache) GetAll() []*models.Order {
+	var orders []*models.Order
+	now := c.clock.Now()
+	for _, s := range c.shards {
+		s.mu.RLock()
+		for _, el := range s.orders {
+			item := el.Value.(*CachedOrderItem)
+			// Пропускаем истекшие элементы
+			if item.expired(now) {
+				continue
+			}
+			orders = append(orders, c.copyOf(item.order))
+		}
+		s.mu.RUnlock()
+	}
+	if orders == nil {
+		orders = []*models.Order{}
+	}
+	return orders
+}
+
+// NewestDateCreated возвращает наибольшее время создания среди неистекших заказов кэша
+// (нулевое время для пустого кэша). Служит отметкой для догрузки новых заказов из БД.
+func (c *Cache) NewestDateCreated() time.Time {
+	var newest time.Time
+	now := c.clock.Now()
+	for _, s := range c.shards {
+		s.mu.RLock()
+		for _, el := range s.orders {
+			item := el.Value.(*CachedOrderItem)
+			if !item.expired(now) && item.order.DateCreated.After(newest) {
+				newest = item.order.DateCreated
+			}
+		}
+		s.mu.RUnlock()
+	}
+	return newest
+}
+
+// LoadFromSlice загружает заказы из слайса в кэш.
+// При ограничении размера в кэше остаются последние заказы слайса.
+func (c *Cache) LoadFromSlice(orders []models.Order) {
+	// Группируем заказы по сегментам, чтобы захватить блокировку каждого один раз
+	byShard := make(map[int][]int)
 	for i := range orders {
-		c.orders[orders[i].OrderUID] = &CachedOrderItem{
-			order:      &orders[i],
-			expireTime: time.Now().Add(c.ttl), // Устанавливаем время истечения
+		idx := shardIndex(orders[i].OrderUID, len(c.shards))
+		byShard[idx] = append(byShard[idx], i)
+	}
+
+	var removed []eviction
+	for idx, positions := range byShard {
+		s := c.shards[idx]
+		s.mu.Lock()
+		before := s.bytes
+		for _, i := range positions {
+			c.set(s, &orders[i], c.ttl, &removed)
+		}
+		c.evict(s, &removed)
+		c.addBytes(s.bytes - before)
+		s.mu.Unlock()
+	}
+	c.notify(removed)
+}
+
+// ReplaceAll заменяет содержимое кэша заказами из слайса: заказы, которых нет в слайсе,
+// исчезают. Новые сегменты строятся без блокировок и подменяются под блокировками всех
+// сегментов сразу, поэтому читатели видят либо старое содержимое, либо новое, но не пустой кэш.
+func (c *Cache) ReplaceAll(orders []models.Order) {
+	_ = c.ReplaceStream(func(add func(order *models.Order)) error {
+		for i := range orders {
+			add(&orders[i])
+		}
+		return nil
+	})
+}
+
+// ReplaceStream как ReplaceAll, но заказы поступают по одному: fill передает их в add
+// (например, по мере чтения курсора БД), и весь набор не нужно держать в слайсе.
+// Ограничения размера применяются по ходу заполнения. Если fill возвращает ошибку,
+// кэш не меняется и ошибка возвращается. Заказы, сохраненные в кэш во время заполнения,
+// заменяются вместе с остальным содержимым.
+func (c *Cache) ReplaceStream(fill func(add func(order *models.Order)) error) error {
+	fresh := make([]*shard, len(c.shards))
+	for i := range fresh {
+		fresh[i] = newShard()
+	}
+	// Новые заказы, не вошедшие в ограничения, в кэше не появлялись — о них не уведомляем
+	err := fill(func(order *models.Order) {
+		s := fresh[shardIndex(order.OrderUID, len(fresh))]
+		c.set(s, order, c.ttl, nil)
+		c.evict(s, nil)
+	})
+	if err != nil {
+		return err
+	}
+	var total int64
+	for _, s := range fresh {
+		total += s.bytes
+	}
+
+	var removed []eviction
+	defer func() { c.notify(removed) }()
+
+	for _, s := range c.shards {
+		s.mu.Lock()
+	}
+	defer func() {
+		for _, s := range c.shards {
+			s.mu.Unlock()
+		}
+	}()
+
+	var previous int64
+	for i, s := range c.shards {
+		previous += s.bytes
+		if c.hooked() {
+			for uid := range s.orders {
+				if _, kept := fresh[i].orders[uid]; !kept {
+					c.record(&removed, uid, EvictCleared)
+				}
+			}
 		}
+		s.orders, s.lru, s.bytes, s.tracks = fresh[i].orders, fresh[i].lru, fresh[i].bytes, fresh[i].tracks
 	}
+	c.addBytes(total - previous)
+	return nil
 }
 
 // Size возвращает количество заказов в кэше
 func (c *Cache) Size() int {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	now := time.Now()
+	now := c.clock.Now()
 	count := 0
-	for _, item := range c.orders {
-		if now.After(item.expireTime) {
-			continue // Пропускаем истекшие элементы
+	for _, s := range c.shards {
+		s.mu.RLock()
+		for _, el := range s.orders {
+			if el.Value.(*CachedOrderItem).expired(now) {
+				continue // Пропускаем истекшие элементы
+			}
+			count++
 		}
-		count++
+		s.mu.RUnlock()
 	}
 	return count
 }
 
-// Cleanup удаляет истекшие элементы из кэша
+// Evicted возвращает количество заказов, вытесненных из-за ограничения размера
+func (c *Cache) Evicted() uint64 {
+	return c.evicted.Load()
+}
+
+// MemoryUsage возвращает оценку памяти, занятой заказами, и бюджет (0 — без ограничения)
+func (c *Cache) MemoryUsage() (bytes, budget int64) {
+	return c.bytes.Load(), c.maxBytes
+}
+
+// Cleanup удаляет истекшие элементы из кэша (в режиме stale-while-revalidate — истекшие
+// более maxStale назад), обходя сегменты по очереди
 func (c *Cache) Cleanup() {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	now := c.clock.Now()
+	var removed []eviction
+	for _, s := range c.shards {
+		s.mu.Lock()
+		before := s.bytes
+		for uid, el := range s.orders {
+			// В режиме stale-while-revalidate истекшие заказы хранятся еще maxStale
+			if el.Value.(*CachedOrderItem).expired(now.Add(-c.maxStale)) {
+				remove(s, el)
+				c.record(&removed, uid, EvictExpired)
+			}
+		}
+		c.addBytes(s.bytes - before)
+		s.mu.Unlock()
+	}
+	c.notify(removed)
+}
+
+// Clear удаляет все заказы из кэша и возвращает их количество (вместе с истекшими).
+// Блокировки всех сегментов захватываются до очистки, поэтому читатели
+// видят кэш либо целиком до очистки, либо пустым.
+func (c *Cache) Clear() int {
+	var removed []eviction
+	defer func() { c.notify(removed) }()
+
+	for _, s := range c.shards {
+		s.mu.Lock()
+	}
+	defer func() {
+		for _, s := range c.shards {
+			s.mu.Unlock()
+		}
+	}()
 
-	now := time.Now()
-	for key, item := range c.orders {
-		if now.After(item.expireTime) {
-			delete(c.orders, key)
+	total := 0
+	for _, s := range c.shards {
+		if c.hooked() {
+			for uid := range s.orders {
+				c.record(&removed, uid, EvictCleared)
+			}
 		}
+		n, bytes := s.reset()
+		total += n
+		c.addBytes(-bytes)
 	}
+	return total
 }
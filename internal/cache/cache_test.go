@@ -1,12 +1,19 @@
 package cache
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"test_service/internal/models"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCache_SetGet(t *testing.T) {
@@ -36,7 +43,8 @@ func TestCache_GetNonExistent(t *testing.T) {
 }
 
 func TestCache_ExpiredItems(t *testing.T) {
-	cache := New(100 * time.Millisecond) // Очень короткое время TTL
+	clock := newFakeClock()
+	cache := New(100*time.Millisecond, WithClock(clock)) // Очень короткое время TTL
 
 	order := &models.Order{
 		OrderUID: "order-123",
@@ -52,7 +60,7 @@ func TestCache_ExpiredItems(t *testing.T) {
 	assert.Equal(t, order, result)
 
 	// Дожидаемся истечения жизни элемента
-	time.Sleep(200 * time.Millisecond)
+	clock.Advance(200 * time.Millisecond)
 
 	// Подтверждение, что больше не существует
 	result, exists = cache.Get("order-123")
@@ -60,6 +68,73 @@ func TestCache_ExpiredItems(t *testing.T) {
 	assert.Nil(t, result)
 }
 
+func TestCache_Delete(t *testing.T) {
+	t.Run("Existing", func(t *testing.T) {
+		cache := New(30 * time.Minute)
+		cache.Set(&models.Order{OrderUID: "order-123"})
+
+		assert.True(t, cache.Delete("order-123"))
+
+		_, exists := cache.Get("order-123")
+		assert.False(t, exists)
+		assert.Equal(t, 0, cache.Size())
+		assert.False(t, cache.Delete("order-123"), "повторное удаление")
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		cache := New(30 * time.Minute)
+		cache.Set(&models.Order{OrderUID: "order-123"})
+
+		assert.False(t, cache.Delete("missing"))
+		assert.Equal(t, 1, cache.Size(), "другие заказы не затронуты")
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		clock := newFakeClock()
+		cache := New(50*time.Millisecond, WithClock(clock))
+		cache.Set(&models.Order{OrderUID: "order-123"})
+		clock.Advance(100 * time.Millisecond)
+
+		assert.False(t, cache.Delete("order-123"), "истекший заказ считается отсутствующим")
+		bytes, _ := cache.MemoryUsage()
+		assert.Equal(t, int64(0), bytes, "истекшая запись все равно удалена")
+	})
+}
+
+func TestCache_ConcurrentDeleteGet(t *testing.T) {
+	cache := New(30 * time.Minute)
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		deleted int
+	)
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				uid := fmt.Sprintf("order-%d", i%10)
+				cache.Set(&models.Order{OrderUID: uid})
+				cache.Get(uid)
+				if cache.Delete(uid) {
+					mu.Lock()
+					deleted++
+					mu.Unlock()
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	assert.Positive(t, deleted)
+	assert.LessOrEqual(t, deleted, 4*200, "каждая вставка удаляется не более одного раза")
+	for i := 0; i < 10; i++ {
+		cache.Delete(fmt.Sprintf("order-%d", i))
+	}
+	assert.Equal(t, 0, cache.Size())
+}
+
 func TestCache_GetAll(t *testing.T) {
 	cache := New(30 * time.Minute)
 
@@ -90,7 +165,8 @@ func TestCache_GetAll(t *testing.T) {
 }
 
 func TestCache_GetAllWithExpiredItems(t *testing.T) {
-	cache := New(100 * time.Millisecond)
+	clock := newFakeClock()
+	cache := New(100*time.Millisecond, WithClock(clock))
 
 	//Добавление товаров с разным сроком жизни
 	order1 := &models.Order{OrderUID: "order-1", Locale: "en"}
@@ -100,7 +176,7 @@ func TestCache_GetAllWithExpiredItems(t *testing.T) {
 	cache.Set(order2)
 
 	//Дожидаемся пока истечет срок жизни некоторых товаров.
-	time.Sleep(200 * time.Millisecond)
+	clock.Advance(200 * time.Millisecond)
 
 	//Получаем все заказы — должно быть пусто, так как все они просрочены.
 	allOrders := cache.GetAll()
@@ -144,14 +220,16 @@ func TestCache_Size(t *testing.T) {
 	assert.Equal(t, 2, cache.Size())
 
 	// Удаляем, сделав его недействительным
-	shortCache := New(100 * time.Millisecond)
+	clock := newFakeClock()
+	shortCache := New(100*time.Millisecond, WithClock(clock))
 	shortCache.Set(order)
-	time.Sleep(200 * time.Millisecond)
+	clock.Advance(200 * time.Millisecond)
 	assert.Equal(t, 0, shortCache.Size())
 }
 
 func TestCache_SizeWithExpired(t *testing.T) {
-	cache := New(100 * time.Millisecond)
+	clock := newFakeClock()
+	cache := New(100*time.Millisecond, WithClock(clock))
 
 	order1 := &models.Order{OrderUID: "order-1", Locale: "en"}
 	order2 := &models.Order{OrderUID: "order-2", Locale: "ru"}
@@ -163,14 +241,15 @@ func TestCache_SizeWithExpired(t *testing.T) {
 	assert.Equal(t, 2, cache.Size())
 
 	// Дожидаемся истечения
-	time.Sleep(200 * time.Millisecond)
+	clock.Advance(200 * time.Millisecond)
 
 	// Размер должен быть 0 после истечения
 	assert.Equal(t, 0, cache.Size())
 }
 
 func TestCache_Cleanup(t *testing.T) {
-	cache := New(100 * time.Millisecond)
+	clock := newFakeClock()
+	cache := New(100*time.Millisecond, WithClock(clock))
 
 	order1 := &models.Order{OrderUID: "order-1", Locale: "en"}
 	order2 := &models.Order{OrderUID: "order-2", Locale: "ru"}
@@ -179,7 +258,7 @@ func TestCache_Cleanup(t *testing.T) {
 	cache.Set(order2)
 
 	// Ждем истчения жизни заказов
-	time.Sleep(200 * time.Millisecond)
+	clock.Advance(200 * time.Millisecond)
 
 	// Подверждаем что заказы истекли но всё ещё в мапе
 	_, exists1 := cache.Get("order-1")
@@ -228,3 +307,1451 @@ func TestCache_ConcurrentAccess(t *testing.T) {
 	assert.True(t, exists)
 	assert.Equal(t, "final", result.OrderUID)
 }
+
+func TestCache_MaxEntriesEvictsLRU(t *testing.T) {
+	cache := New(30*time.Minute, WithMaxEntries(2))
+
+	cache.Set(&models.Order{OrderUID: "order-1"})
+	cache.Set(&models.Order{OrderUID: "order-2"})
+
+	// Чтение делает order-1 недавно использованным, вытесняется order-2
+	_, exists := cache.Get("order-1")
+	assert.True(t, exists)
+	cache.Set(&models.Order{OrderUID: "order-3"})
+
+	assert.Equal(t, 2, cache.Size())
+	assert.Equal(t, uint64(1), cache.Evicted())
+	_, exists = cache.Get("order-2")
+	assert.False(t, exists, "давно не использованный заказ вытеснен")
+	_, exists = cache.Get("order-1")
+	assert.True(t, exists)
+	_, exists = cache.Get("order-3")
+	assert.True(t, exists)
+
+	// Обновление существующего заказа не вытесняет другие
+	cache.Set(&models.Order{OrderUID: "order-1", Locale: "ru"})
+	assert.Equal(t, 2, cache.Size())
+	assert.Equal(t, uint64(1), cache.Evicted())
+}
+
+func TestCache_MaxEntriesLoadFromSlice(t *testing.T) {
+	cache := New(30*time.Minute, WithMaxEntries(2))
+
+	cache.LoadFromSlice([]models.Order{{OrderUID: "order-1"}, {OrderUID: "order-2"}, {OrderUID: "order-3"}})
+
+	assert.Equal(t, 2, cache.Size())
+	assert.Equal(t, uint64(1), cache.Evicted())
+	_, exists := cache.Get("order-1")
+	assert.False(t, exists, "при прогреве остаются последние заказы")
+}
+
+func TestCache_UnlimitedByDefault(t *testing.T) {
+	for _, c := range []*Cache{New(30 * time.Minute), New(30*time.Minute, WithMaxEntries(0))} {
+		for i := 0; i < 1000; i++ {
+			c.Set(&models.Order{OrderUID: fmt.Sprintf("order-%d", i)})
+		}
+		assert.Equal(t, 1000, c.Size())
+		assert.Equal(t, uint64(0), c.Evicted())
+	}
+}
+
+func TestCache_DeleteAndCleanupKeepLRUConsistent(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(100*time.Millisecond, WithMaxEntries(2), WithClock(clock))
+
+	cache.Set(&models.Order{OrderUID: "order-1"})
+	cache.Set(&models.Order{OrderUID: "order-2"})
+	cache.Delete("order-1")
+	cache.Set(&models.Order{OrderUID: "order-3"})
+	assert.Equal(t, uint64(0), cache.Evicted(), "удаленный заказ освобождает место")
+
+	clock.Advance(150 * time.Millisecond)
+	cache.Cleanup()
+	cache.Set(&models.Order{OrderUID: "order-4"})
+	cache.Set(&models.Order{OrderUID: "order-5"})
+	assert.Equal(t, uint64(0), cache.Evicted(), "очистка истекших освобождает место")
+	assert.Equal(t, 2, cache.Size())
+}
+
+func TestCache_MaxEntriesConcurrentAccess(t *testing.T) {
+	cache := New(30*time.Minute, WithMaxEntries(10))
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				uid := fmt.Sprintf("order-%d-%d", g, i%20)
+				cache.Set(&models.Order{OrderUID: uid})
+				cache.Get(uid)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 10, cache.Size())
+	assert.Len(t, cache.GetAll(), 10)
+}
+
+// largeOrder синтетический заказ с n позициями и длинными строками
+func largeOrder(uid string, n int) *models.Order {
+	order := &models.Order{OrderUID: uid, InternalSignature: strings.Repeat("s", 1024)}
+	for i := 0; i < n; i++ {
+		order.Items = append(order.Items, models.Item{
+			OrderUID: uid,
+			Name:     strings.Repeat("n", 256),
+			Brand:    strings.Repeat("b", 256),
+		})
+	}
+	return order
+}
+
+func TestEstimateSize(t *testing.T) {
+	small := estimateSize(largeOrder("order-1", 1))
+	big := estimateSize(largeOrder("order-1", 100))
+
+	assert.Greater(t, small, int64(1024+512), "строки учитываются в оценке")
+	assert.Greater(t, big, small+99*512, "каждая позиция увеличивает оценку")
+}
+
+func TestCache_MaxBytesRespected(t *testing.T) {
+	orderSize := estimateSize(largeOrder("order-00", 100))
+	budget := orderSize*5 + orderSize/2
+	cache := New(30*time.Minute, WithMaxBytes(budget))
+
+	for i := 0; i < 50; i++ {
+		cache.Set(largeOrder(fmt.Sprintf("order-%02d", i), 100))
+		bytes, limit := cache.MemoryUsage()
+		assert.LessOrEqual(t, bytes, limit, "бюджет соблюдается после каждой вставки")
+	}
+
+	bytes, limit := cache.MemoryUsage()
+	assert.Equal(t, budget, limit)
+	assert.Equal(t, 5*orderSize, bytes)
+	assert.Equal(t, 5, cache.Size())
+	assert.Equal(t, uint64(45), cache.Evicted())
+	_, exists := cache.Get("order-49")
+	assert.True(t, exists, "последние заказы остаются в кэше")
+	_, exists = cache.Get("order-00")
+	assert.False(t, exists, "давние заказы вытеснены")
+}
+
+func TestCache_MaxBytesOversizedOrder(t *testing.T) {
+	budget := estimateSize(largeOrder("order-1", 10)) * 3
+	cache := New(30*time.Minute, WithMaxBytes(budget))
+
+	cache.Set(largeOrder("order-1", 10))
+	cache.Set(largeOrder("order-2", 10))
+	cache.Set(largeOrder("huge", 1000))
+
+	_, exists := cache.Get("huge")
+	assert.False(t, exists, "заказ больше бюджета не кэшируется")
+	assert.Equal(t, 2, cache.Size(), "остальные заказы не вытесняются")
+	assert.Equal(t, uint64(0), cache.Evicted())
+
+	// Устаревшая версия заказа, выросшего сверх бюджета, удаляется
+	cache.Set(largeOrder("order-1", 1000))
+	_, exists = cache.Get("order-1")
+	assert.False(t, exists)
+	bytes, _ := cache.MemoryUsage()
+	assert.Equal(t, estimateSize(largeOrder("order-2", 10)), bytes)
+}
+
+func TestCache_BytesTracking(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(100*time.Millisecond, WithClock(clock))
+
+	cache.Set(largeOrder("order-1", 1))
+	cache.Set(largeOrder("order-2", 1))
+	bytes, budget := cache.MemoryUsage()
+	assert.Equal(t, 2*estimateSize(largeOrder("order-1", 1)), bytes)
+	assert.Equal(t, int64(0), budget, "без бюджета ограничения нет")
+
+	// Замена заказа учитывает новый размер, а не добавляет его к старому
+	cache.Set(largeOrder("order-1", 10))
+	bytes, _ = cache.MemoryUsage()
+	assert.Equal(t, estimateSize(largeOrder("order-1", 10))+estimateSize(largeOrder("order-2", 1)), bytes)
+
+	cache.Delete("order-1")
+	bytes, _ = cache.MemoryUsage()
+	assert.Equal(t, estimateSize(largeOrder("order-2", 1)), bytes)
+
+	clock.Advance(150 * time.Millisecond)
+	cache.Cleanup()
+	bytes, _ = cache.MemoryUsage()
+	assert.Equal(t, int64(0), bytes)
+}
+
+func TestCache_Clear(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(50*time.Millisecond, WithMaxEntries(10), WithClock(clock))
+	cache.Set(&models.Order{OrderUID: "order-1"})
+	clock.Advance(100 * time.Millisecond)
+	cache.Set(&models.Order{OrderUID: "order-2"})
+	cache.Set(&models.Order{OrderUID: "order-3"})
+
+	assert.Equal(t, 3, cache.Clear(), "истекшие заказы тоже удаляются")
+	assert.Equal(t, 0, cache.Size())
+	assert.Empty(t, cache.GetAll())
+	bytes, _ := cache.MemoryUsage()
+	assert.Equal(t, int64(0), bytes)
+	assert.Equal(t, 0, cache.Clear(), "повторная очистка пустого кэша")
+
+	// После очистки кэш работает как новый, включая порядок LRU
+	cache.Set(&models.Order{OrderUID: "order-4"})
+	_, exists := cache.Get("order-4")
+	assert.True(t, exists)
+	assert.True(t, cache.Delete("order-4"))
+}
+
+func TestCache_ConcurrentClear(t *testing.T) {
+	cache := New(30*time.Minute, WithMaxEntries(50))
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				uid := fmt.Sprintf("order-%d-%d", g, i%100)
+				cache.Set(&models.Order{OrderUID: uid})
+				if order, exists := cache.Get(uid); exists {
+					assert.Equal(t, uid, order.OrderUID)
+				}
+			}
+		}(g)
+	}
+
+	assert.Eventually(t, func() bool { return cache.Size() > 0 }, time.Second, time.Millisecond)
+	removed := 0
+	for i := 0; i < 100; i++ {
+		removed += cache.Clear()
+	}
+	close(done)
+	wg.Wait()
+
+	assert.Positive(t, removed)
+	assert.LessOrEqual(t, cache.Size(), 50)
+	assert.Len(t, cache.GetAll(), cache.Size())
+	bytes, _ := cache.MemoryUsage()
+	var expected int64
+	for _, order := range cache.GetAll() {
+		expected += estimateSize(order)
+	}
+	assert.Equal(t, expected, bytes, "учет памяти согласован после очисток")
+}
+
+func TestCache_GetMulti(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(50*time.Millisecond, WithClock(clock))
+	cache.Set(&models.Order{OrderUID: "expired"})
+	clock.Advance(100 * time.Millisecond)
+	cache.Set(&models.Order{OrderUID: "order-1"})
+	cache.Set(&models.Order{OrderUID: "order-2"})
+
+	found, missing := cache.GetMulti([]string{"order-1", "missing", "expired", "order-2", "order-1", "missing"})
+
+	assert.Len(t, found, 2)
+	assert.Equal(t, "order-1", found["order-1"].OrderUID)
+	assert.Equal(t, "order-2", found["order-2"].OrderUID)
+	assert.Equal(t, []string{"missing", "expired"}, missing, "порядок запроса сохраняется, повторы убраны")
+
+	found, missing = cache.GetMulti(nil)
+	assert.Empty(t, found)
+	assert.Empty(t, missing)
+}
+
+func TestCache_GetMultiUpdatesLRU(t *testing.T) {
+	cache := New(30*time.Minute, WithMaxEntries(2))
+	cache.Set(&models.Order{OrderUID: "order-1"})
+	cache.Set(&models.Order{OrderUID: "order-2"})
+
+	// Как и Get, GetMulti делает заказ недавно использованным
+	cache.GetMulti([]string{"order-1"})
+	cache.Set(&models.Order{OrderUID: "order-3"})
+
+	_, exists := cache.Get("order-1")
+	assert.True(t, exists)
+	_, exists = cache.Get("order-2")
+	assert.False(t, exists)
+}
+
+// benchmarkCache кэш с n заказами и их UID
+func benchmarkCache(n int, opts ...Option) (*Cache, []string) {
+	cache := New(30*time.Minute, opts...)
+	uids := make([]string, n)
+	for i := range uids {
+		uids[i] = fmt.Sprintf("order-%d", i)
+		cache.Set(&models.Order{OrderUID: uids[i]})
+	}
+	return cache, uids
+}
+
+func BenchmarkCache_GetLoop1k(b *testing.B) {
+	cache, uids := benchmarkCache(1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		found := make(map[string]*models.Order, len(uids))
+		for _, uid := range uids {
+			if order, exists := cache.Get(uid); exists {
+				found[uid] = order
+			}
+		}
+	}
+}
+
+func BenchmarkCache_GetMulti1k(b *testing.B) {
+	cache, uids := benchmarkCache(1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.GetMulti(uids)
+	}
+}
+
+func BenchmarkCache_GetLoop1kParallel(b *testing.B) {
+	cache, uids := benchmarkCache(1000)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			for _, uid := range uids {
+				cache.Get(uid)
+			}
+		}
+	})
+}
+
+func BenchmarkCache_GetMulti1kParallel(b *testing.B) {
+	cache, uids := benchmarkCache(1000)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			cache.GetMulti(uids)
+		}
+	})
+}
+
+func TestCache_ShardsUnbounded(t *testing.T) {
+	cache := New(30 * time.Minute)
+	assert.Len(t, cache.shards, defaultShards)
+
+	for i := 0; i < 1000; i++ {
+		cache.Set(&models.Order{OrderUID: fmt.Sprintf("order-%d", i)})
+	}
+	assert.Equal(t, 1000, cache.Size())
+	assert.Len(t, cache.GetAll(), 1000)
+
+	// Заказы распределяются по всем сегментам
+	for i, s := range cache.shards {
+		assert.NotEmpty(t, s.orders, "сегмент %d пуст", i)
+	}
+
+	// Небольшое ограничение сохраняет глобальный порядок LRU в одном сегменте
+	assert.Len(t, New(30*time.Minute, WithMaxEntries(10)).shards, 1)
+	assert.Len(t, New(30*time.Minute, WithMaxBytes(minShardBytes)).shards, 1)
+	assert.Len(t, New(30*time.Minute, WithMaxEntries(3), withShards(8)).shards, 3, "сегментов не больше заказов")
+}
+
+func TestCache_ShardsBounded(t *testing.T) {
+	for name, tc := range map[string]struct {
+		opts            []Option
+		shards          int
+		shardMaxEntries int
+		shardMaxBytes   int64
+	}{
+		"Entries":        {[]Option{WithMaxEntries(100000)}, defaultShards, 3125, 0},
+		"FewEntries":     {[]Option{WithMaxEntries(4 * minShardEntries)}, 4, minShardEntries, 0},
+		"Bytes":          {[]Option{WithMaxBytes(256 << 20)}, defaultShards, 0, 8 << 20},
+		"FewBytes":       {[]Option{WithMaxBytes(3*minShardBytes + 1)}, 3, 0, minShardBytes},
+		"SmallestWins":   {[]Option{WithMaxEntries(100000), WithMaxBytes(2 * minShardBytes)}, 2, 50000, minShardBytes},
+		"RoundedDownSum": {[]Option{WithMaxEntries(100001)}, defaultShards, 3125, 0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cache := New(30*time.Minute, tc.opts...)
+			assert.Len(t, cache.shards, tc.shards)
+			assert.Equal(t, tc.shardMaxEntries, cache.shardMaxEntries)
+			assert.Equal(t, tc.shardMaxBytes, cache.shardMaxBytes)
+		})
+	}
+}
+
+func TestCache_ShardedMaxEntries(t *testing.T) {
+	const limit = 100000
+	cache := New(30*time.Minute, WithMaxEntries(limit))
+	require.Len(t, cache.shards, defaultShards)
+
+	for i := 0; i < 2*limit; i++ {
+		cache.Set(&models.Order{OrderUID: fmt.Sprintf("order-%d", i)})
+	}
+	assert.LessOrEqual(t, cache.Size(), limit, "сумма долей сегментов не превышает ограничения")
+	assert.Greater(t, cache.Size(), limit*9/10, "сегменты заполнены почти равномерно")
+	assert.Equal(t, uint64(2*limit-cache.Size()), cache.Evicted())
+	for i, s := range cache.shards {
+		assert.LessOrEqual(t, s.lru.Len(), cache.shardMaxEntries, "сегмент %d", i)
+	}
+
+	// Недавно записанные заказы в кэше, самые давние вытеснены из своих сегментов
+	_, exists := cache.Get(fmt.Sprintf("order-%d", 2*limit-1))
+	assert.True(t, exists)
+	_, exists = cache.Get("order-0")
+	assert.False(t, exists)
+}
+
+// benchmarkMixed смешанная нагрузка: на каждые 10 чтений одна запись
+func benchmarkMixed(b *testing.B, opts ...Option) {
+	cache, uids := benchmarkCache(1000, opts...)
+	var worker atomic.Uint64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		// Каждая горутина начинает со своей позиции, чтобы не делить общий счетчик
+		i := worker.Add(1) * 7919
+		for pb.Next() {
+			i++
+			uid := uids[i%uint64(len(uids))]
+			if i%10 == 0 {
+				cache.Set(&models.Order{OrderUID: uid})
+				continue
+			}
+			cache.Get(uid)
+		}
+	})
+}
+
+func BenchmarkCache_Mixed1Shard(b *testing.B) {
+	benchmarkMixed(b, withShards(1))
+}
+
+func BenchmarkCache_MixedSharded(b *testing.B) {
+	benchmarkMixed(b, withShards(defaultShards))
+}
+
+// С ограничением чтение меняет порядок LRU и берет исключительную блокировку сегмента
+func BenchmarkCache_MixedBounded1Shard(b *testing.B) {
+	benchmarkMixed(b, WithMaxEntries(100000), withShards(1))
+}
+
+func BenchmarkCache_MixedBoundedSharded(b *testing.B) {
+	benchmarkMixed(b, WithMaxEntries(100000))
+}
+
+func TestCache_FixedTTLByDefault(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(30*time.Minute, WithClock(clock))
+	cache.Set(&models.Order{OrderUID: "order-1"})
+
+	// Чтения не продлевают срок жизни
+	for i := 0; i < 5; i++ {
+		clock.Advance(5 * time.Minute)
+		_, exists := cache.Get("order-1")
+		assert.True(t, exists)
+	}
+	clock.Advance(6 * time.Minute)
+	_, exists := cache.Get("order-1")
+	assert.False(t, exists, "заказ истекает через ttl после записи")
+}
+
+func TestCache_SlidingTTL(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(30*time.Minute, WithSlidingTTL(true), WithClock(clock))
+	cache.Set(&models.Order{OrderUID: "hot"})
+	cache.Set(&models.Order{OrderUID: "cold"})
+
+	// Каждое чтение продлевает срок жизни на ttl
+	for i := 0; i < 10; i++ {
+		clock.Advance(20 * time.Minute)
+		_, exists := cache.Get("hot")
+		assert.True(t, exists, "часто запрашиваемый заказ не истекает")
+	}
+	found, missing := cache.GetMulti([]string{"hot", "cold"})
+	assert.Contains(t, found, "hot")
+	assert.Equal(t, []string{"cold"}, missing, "незапрашиваемый заказ истек")
+
+	// Без чтений заказ истекает через ttl после последнего обращения
+	clock.Advance(31 * time.Minute)
+	_, exists := cache.Get("hot")
+	assert.False(t, exists)
+}
+
+func TestCache_SlidingTTLMaxLifetime(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(30*time.Minute, WithSlidingTTL(true), WithMaxLifetime(time.Hour), WithClock(clock))
+	cache.Set(&models.Order{OrderUID: "order-1"})
+
+	for i := 0; i < 3; i++ {
+		clock.Advance(15 * time.Minute)
+		_, exists := cache.Get("order-1")
+		assert.True(t, exists)
+	}
+
+	// Продление не выходит за предельный срок жизни с момента записи
+	clock.Advance(15*time.Minute + time.Second)
+	_, exists := cache.Get("order-1")
+	assert.False(t, exists, "заказ истекает по предельному сроку, несмотря на чтения")
+
+	// Повторная запись начинает отсчет предельного срока заново
+	cache.Set(&models.Order{OrderUID: "order-1"})
+	for i := 0; i < 3; i++ {
+		clock.Advance(15 * time.Minute)
+		_, exists = cache.Get("order-1")
+		assert.True(t, exists)
+	}
+}
+
+func TestCache_MaxLifetimeWithoutSliding(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(30*time.Minute, WithMaxLifetime(10*time.Minute), WithClock(clock))
+	cache.Set(&models.Order{OrderUID: "order-1"})
+
+	// Предел действует только вместе со скользящим TTL
+	clock.Advance(20 * time.Minute)
+	_, exists := cache.Get("order-1")
+	assert.True(t, exists)
+}
+
+func TestCache_LookupStaleWhileRevalidate(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(time.Minute, WithStaleWhileRevalidate(10*time.Minute), WithClock(clock))
+	cache.Set(&models.Order{OrderUID: "order-1"})
+
+	order, stale, ok := cache.Lookup("order-1")
+	assert.True(t, ok)
+	assert.False(t, stale)
+	assert.Equal(t, "order-1", order.OrderUID)
+
+	// Истекший заказ отдается устаревшим, Get по-прежнему его не видит
+	clock.Advance(5 * time.Minute)
+	order, stale, ok = cache.Lookup("order-1")
+	assert.True(t, ok)
+	assert.True(t, stale)
+	assert.Equal(t, "order-1", order.OrderUID)
+	_, exists := cache.Get("order-1")
+	assert.False(t, exists)
+	assert.Equal(t, 0, cache.Size())
+
+	// Очистка не удаляет заказ, пока не прошло окно maxStale
+	cache.Cleanup()
+	_, _, ok = cache.Lookup("order-1")
+	assert.True(t, ok)
+
+	clock.Advance(7 * time.Minute)
+	_, _, ok = cache.Lookup("order-1")
+	assert.False(t, ok, "после окна maxStale заказ не отдается")
+	cache.Cleanup()
+	bytes, _ := cache.MemoryUsage()
+	assert.Equal(t, int64(0), bytes)
+}
+
+func TestCache_LookupWithoutStaleMode(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(time.Minute, WithClock(clock))
+	cache.Set(&models.Order{OrderUID: "order-1"})
+
+	clock.Advance(2 * time.Minute)
+	order, stale, ok := cache.Lookup("order-1")
+	assert.False(t, ok, "без режима stale-while-revalidate Lookup ведет себя как Get")
+	assert.False(t, stale)
+	assert.Nil(t, order)
+}
+
+func TestCache_Revalidate(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(time.Minute, WithStaleWhileRevalidate(10*time.Minute), WithClock(clock))
+
+	t.Run("ReplacesExpired", func(t *testing.T) {
+		cache.Set(&models.Order{OrderUID: "order-1", Locale: "en"})
+		clock.Advance(2 * time.Minute)
+
+		assert.True(t, cache.Revalidate(&models.Order{OrderUID: "order-1", Locale: "ru"}))
+		order, stale, ok := cache.Lookup("order-1")
+		assert.True(t, ok)
+		assert.False(t, stale)
+		assert.Equal(t, "ru", order.Locale)
+	})
+
+	t.Run("KeepsConcurrentSet", func(t *testing.T) {
+		cache.Set(&models.Order{OrderUID: "order-2", Locale: "en"})
+		clock.Advance(2 * time.Minute)
+
+		// Пока шло обновление, пришла новая версия из Kafka
+		cache.Set(&models.Order{OrderUID: "order-2", Locale: "kafka"})
+		assert.False(t, cache.Revalidate(&models.Order{OrderUID: "order-2", Locale: "db"}))
+		order, _ := cache.Get("order-2")
+		assert.Equal(t, "kafka", order.Locale)
+	})
+
+	t.Run("DoesNotResurrectDeleted", func(t *testing.T) {
+		cache.Set(&models.Order{OrderUID: "order-3"})
+		clock.Advance(2 * time.Minute)
+		cache.Delete("order-3")
+
+		assert.False(t, cache.Revalidate(&models.Order{OrderUID: "order-3"}))
+		_, _, ok := cache.Lookup("order-3")
+		assert.False(t, ok)
+	})
+}
+
+func TestCache_RevalidateConcurrentSet(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(time.Minute, WithStaleWhileRevalidate(10*time.Minute), WithClock(clock))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		uid := fmt.Sprintf("order-%d", i%10)
+		cache.Set(&models.Order{OrderUID: uid, Locale: "old"})
+		clock.Advance(2 * time.Minute)
+
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			cache.Revalidate(&models.Order{OrderUID: uid, Locale: "db"})
+		}()
+		go func() {
+			defer wg.Done()
+			cache.Set(&models.Order{OrderUID: uid, Locale: "kafka"})
+		}()
+		wg.Wait()
+
+		// Set всегда побеждает: либо он после обновления, либо обновление видит свежую запись
+		order, _, ok := cache.Lookup(uid)
+		assert.True(t, ok)
+		assert.Equal(t, "kafka", order.Locale)
+	}
+}
+
+func TestCache_ReturnsCopies(t *testing.T) {
+	newOrder := func() *models.Order {
+		return &models.Order{
+			OrderUID: "order-1",
+			Locale:   "en",
+			Delivery: models.Delivery{City: "Moscow"},
+			Items:    []models.Item{{Name: "Mascaras", Price: 100}},
+		}
+	}
+	mutate := func(o *models.Order) {
+		o.Locale = "changed"
+		o.Delivery.City = "changed"
+		o.Items[0].Name = "changed"
+		o.Items = append(o.Items, models.Item{Name: "extra"})
+	}
+	assertIntact := func(t *testing.T, cache *Cache) {
+		t.Helper()
+		order, exists := cache.Get("order-1")
+		require.True(t, exists)
+		assert.Equal(t, newOrder(), order, "изменение полученного заказа не затрагивает кэш")
+	}
+
+	t.Run("Get", func(t *testing.T) {
+		cache := New(30 * time.Minute)
+		cache.Set(newOrder())
+		order, _ := cache.Get("order-1")
+		mutate(order)
+		assertIntact(t, cache)
+	})
+
+	t.Run("Set", func(t *testing.T) {
+		cache := New(30 * time.Minute)
+		order := newOrder()
+		cache.Set(order)
+		mutate(order)
+		assertIntact(t, cache)
+	})
+
+	t.Run("GetAll", func(t *testing.T) {
+		cache := New(30 * time.Minute)
+		cache.Set(newOrder())
+		mutate(cache.GetAll()[0])
+		assertIntact(t, cache)
+	})
+
+	t.Run("GetMultiAndLookup", func(t *testing.T) {
+		cache := New(30 * time.Minute)
+		cache.Set(newOrder())
+		found, _ := cache.GetMulti([]string{"order-1"})
+		mutate(found["order-1"])
+		order, _, _ := cache.Lookup("order-1")
+		mutate(order)
+		assertIntact(t, cache)
+	})
+
+	t.Run("LoadFromSliceReuse", func(t *testing.T) {
+		cache := New(30 * time.Minute)
+		orders := []models.Order{*newOrder()}
+		cache.LoadFromSlice(orders)
+		mutate(&orders[0])
+		assertIntact(t, cache)
+	})
+
+	t.Run("WithoutCopies", func(t *testing.T) {
+		cache := New(30*time.Minute, WithCopies(false))
+		order := newOrder()
+		cache.Set(order)
+		cached, _ := cache.Get("order-1")
+		assert.Same(t, order, cached, "без копирования кэш отдает тот же заказ")
+	})
+}
+
+func BenchmarkCache_Get(b *testing.B) {
+	for _, copies := range []bool{true, false} {
+		b.Run(fmt.Sprintf("copies=%t", copies), func(b *testing.B) {
+			cache := New(30*time.Minute, WithCopies(copies))
+			cache.Set(largeOrder("order-1", 10))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				cache.Get("order-1")
+			}
+		})
+	}
+}
+
+func TestCache_ReplaceAll(t *testing.T) {
+	cache := New(30 * time.Minute)
+	cache.Set(&models.Order{OrderUID: "deleted", Locale: "en"})
+	cache.Set(&models.Order{OrderUID: "kept", Locale: "en"})
+
+	cache.ReplaceAll([]models.Order{{OrderUID: "kept", Locale: "ru"}, {OrderUID: "new"}})
+
+	_, exists := cache.Get("deleted")
+	assert.False(t, exists, "заказа нет в новом слайсе — он удален из кэша")
+	kept, exists := cache.Get("kept")
+	assert.True(t, exists)
+	assert.Equal(t, "ru", kept.Locale)
+	_, exists = cache.Get("new")
+	assert.True(t, exists)
+	assert.Equal(t, 2, cache.Size())
+
+	bytes, _ := cache.MemoryUsage()
+	assert.Equal(t, estimateSize(&models.Order{OrderUID: "kept", Locale: "ru"})+estimateSize(&models.Order{OrderUID: "new"}), bytes)
+
+	cache.ReplaceAll(nil)
+	assert.Equal(t, 0, cache.Size())
+	bytes, _ = cache.MemoryUsage()
+	assert.Equal(t, int64(0), bytes)
+}
+
+func TestCache_ReplaceAllRespectsLimits(t *testing.T) {
+	cache := New(30*time.Minute, WithMaxEntries(2))
+
+	cache.ReplaceAll([]models.Order{{OrderUID: "order-1"}, {OrderUID: "order-2"}, {OrderUID: "order-3"}})
+
+	assert.Equal(t, 2, cache.Size())
+	_, exists := cache.Get("order-1")
+	assert.False(t, exists, "как и при LoadFromSlice, остаются последние заказы")
+}
+
+func TestCache_ReplaceStream(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		cache := New(30*time.Minute, WithMaxEntries(2))
+		cache.Set(&models.Order{OrderUID: "deleted"})
+
+		err := cache.ReplaceStream(func(add func(*models.Order)) error {
+			for i := 1; i <= 3; i++ {
+				add(&models.Order{OrderUID: fmt.Sprintf("order-%d", i)})
+				_, exists := cache.Get("deleted")
+				assert.True(t, exists, "до замены читатели видят прежнее содержимое")
+			}
+			return nil
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, cache.Size(), "ограничения применяются по ходу заполнения")
+		_, exists := cache.Get("deleted")
+		assert.False(t, exists)
+		_, exists = cache.Get("order-3")
+		assert.True(t, exists)
+	})
+
+	t.Run("FillErrorKeepsContent", func(t *testing.T) {
+		cache := New(30 * time.Minute)
+		cache.Set(&models.Order{OrderUID: "cached"})
+		fillErr := errors.New("stream broken")
+
+		err := cache.ReplaceStream(func(add func(*models.Order)) error {
+			add(&models.Order{OrderUID: "order-1"})
+			return fillErr
+		})
+		assert.ErrorIs(t, err, fillErr)
+
+		_, exists := cache.Get("cached")
+		assert.True(t, exists, "при ошибке кэш не меняется")
+		_, exists = cache.Get("order-1")
+		assert.False(t, exists)
+		bytes, _ := cache.MemoryUsage()
+		assert.Equal(t, estimateSize(&models.Order{OrderUID: "cached"}), bytes)
+	})
+}
+
+func TestCache_ReplaceAllConcurrentReaders(t *testing.T) {
+	cache := New(30 * time.Minute)
+
+	batch := func(prefix string) []models.Order {
+		orders := []models.Order{{OrderUID: "stable"}}
+		for i := 0; i < 100; i++ {
+			orders = append(orders, models.Order{OrderUID: fmt.Sprintf("%s-%d", prefix, i)})
+		}
+		return orders
+	}
+	cache.ReplaceAll(batch("a"))
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				_, exists := cache.Get("stable")
+				if !assert.True(t, exists, "читатель увидел промежуточное пустое состояние") {
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 200; i++ {
+		if i%2 == 0 {
+			cache.ReplaceAll(batch("b"))
+		} else {
+			cache.ReplaceAll(batch("a"))
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	assert.Equal(t, 101, cache.Size())
+}
+
+// storedCount число записей в словарях сегментов, включая истекшие
+func storedCount(c *Cache) int {
+	n := 0
+	for _, s := range c.shards {
+		s.mu.RLock()
+		n += len(s.orders)
+		s.mu.RUnlock()
+	}
+	return n
+}
+
+func TestCache_Janitor(t *testing.T) {
+	t.Run("RemovesExpired", func(t *testing.T) {
+		cache := New(20*time.Millisecond, WithCleanupInterval(10*time.Millisecond))
+		defer cache.StopJanitor()
+
+		cache.Set(&models.Order{OrderUID: "order-1"})
+		cache.Set(&models.Order{OrderUID: "order-2"})
+		assert.Equal(t, 2, storedCount(cache))
+
+		// Истекшие заказы удаляются из словаря, а не только скрываются от чтения
+		assert.Eventually(t, func() bool { return storedCount(cache) == 0 }, time.Second, 5*time.Millisecond)
+		bytes, _ := cache.MemoryUsage()
+		assert.Equal(t, int64(0), bytes)
+	})
+
+	t.Run("NotStartedByDefault", func(t *testing.T) {
+		cache := New(time.Millisecond)
+		cache.Set(&models.Order{OrderUID: "order-1"})
+		time.Sleep(20 * time.Millisecond)
+
+		assert.Equal(t, 1, storedCount(cache), "без очистки истекший заказ остается в словаре")
+		cache.StopJanitor() // Остановка незапущенной очистки безопасна
+	})
+
+	t.Run("NoCleanupAfterStop", func(t *testing.T) {
+		cache := New(time.Millisecond)
+		cache.StartJanitor(5 * time.Millisecond)
+		cache.StartJanitor(5 * time.Millisecond) // Повторный запуск не создает вторую задачу
+		cache.StopJanitor()
+		cache.StopJanitor()
+
+		cache.Set(&models.Order{OrderUID: "order-1"})
+		time.Sleep(30 * time.Millisecond)
+		assert.Equal(t, 1, storedCount(cache), "после StopJanitor очистка не срабатывает")
+
+		// После остановки очистку можно запустить снова
+		cache.StartJanitor(5 * time.Millisecond)
+		defer cache.StopJanitor()
+		assert.Eventually(t, func() bool { return storedCount(cache) == 0 }, time.Second, 5*time.Millisecond)
+	})
+}
+
+// newFakeClock часы для тестов сроков жизни: время идет только через Advance
+func newFakeClock() *FakeClock {
+	return NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+}
+
+// evictionRecorder собирает вызовы обработчика OnEvict
+type evictionRecorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *evictionRecorder) record(orderUID string, reason EvictReason) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, orderUID+":"+string(reason))
+}
+
+func (r *evictionRecorder) take() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	events := r.events
+	r.events = nil
+	return events
+}
+
+func TestCache_OnEvict(t *testing.T) {
+	t.Run("Expired", func(t *testing.T) {
+		clock := newFakeClock()
+		cache := New(time.Minute, WithClock(clock))
+		rec := &evictionRecorder{}
+		cache.OnEvict(rec.record)
+
+		cache.Set(&models.Order{OrderUID: "order-1"})
+		clock.Advance(2 * time.Minute)
+		cache.Set(&models.Order{OrderUID: "order-2"})
+		cache.Cleanup()
+
+		assert.Equal(t, []string{"order-1:expired"}, rec.take())
+	})
+
+	t.Run("Evicted", func(t *testing.T) {
+		cache := New(30*time.Minute, WithMaxEntries(2))
+		rec := &evictionRecorder{}
+		cache.OnEvict(rec.record)
+
+		cache.Set(&models.Order{OrderUID: "order-1"})
+		cache.Set(&models.Order{OrderUID: "order-2"})
+		cache.Set(&models.Order{OrderUID: "order-1", Locale: "ru"}) // Обновление — не удаление
+		assert.Empty(t, rec.take())
+
+		cache.Set(&models.Order{OrderUID: "order-3"})
+		assert.Equal(t, []string{"order-2:evicted"}, rec.take())
+
+		cache.LoadFromSlice([]models.Order{{OrderUID: "order-4"}})
+		assert.Equal(t, []string{"order-1:evicted"}, rec.take())
+	})
+
+	t.Run("Deleted", func(t *testing.T) {
+		cache := New(30 * time.Minute)
+		rec := &evictionRecorder{}
+		cache.OnEvict(rec.record)
+
+		cache.Set(&models.Order{OrderUID: "order-1"})
+		cache.Delete("order-1")
+		cache.Delete("missing")
+
+		assert.Equal(t, []string{"order-1:deleted"}, rec.take())
+	})
+
+	t.Run("Cleared", func(t *testing.T) {
+		cache := New(30 * time.Minute)
+		rec := &evictionRecorder{}
+		cache.OnEvict(rec.record)
+
+		cache.Set(&models.Order{OrderUID: "order-1"})
+		cache.Set(&models.Order{OrderUID: "order-2"})
+		cache.Clear()
+		assert.ElementsMatch(t, []string{"order-1:cleared", "order-2:cleared"}, rec.take())
+
+		// При замене содержимого уведомляем только о заказах, которых нет в новом слайсе
+		cache.Set(&models.Order{OrderUID: "kept"})
+		cache.Set(&models.Order{OrderUID: "dropped"})
+		cache.ReplaceAll([]models.Order{{OrderUID: "kept"}, {OrderUID: "new"}})
+		assert.Equal(t, []string{"dropped:cleared"}, rec.take())
+	})
+
+	t.Run("MultipleHandlers", func(t *testing.T) {
+		cache := New(30 * time.Minute)
+		first, second := &evictionRecorder{}, &evictionRecorder{}
+		cache.OnEvict(first.record)
+		cache.OnEvict(second.record)
+		cache.OnEvict(nil)
+
+		cache.Set(&models.Order{OrderUID: "order-1"})
+		cache.Delete("order-1")
+
+		assert.Equal(t, []string{"order-1:deleted"}, first.take())
+		assert.Equal(t, []string{"order-1:deleted"}, second.take())
+	})
+}
+
+func TestCache_OnEvictReentrant(t *testing.T) {
+	cache := New(30*time.Minute, WithMaxEntries(1))
+
+	// Обработчик обращается к кэшу: под удерживаемой блокировкой это была бы взаимоблокировка
+	var reentered []string
+	cache.OnEvict(func(orderUID string, reason EvictReason) {
+		_, exists := cache.Get(orderUID)
+		assert.False(t, exists, "к моменту уведомления заказ уже удален")
+		cache.Size()
+		if reason == EvictEvicted {
+			cache.Delete("order-2") // Изменение кэша из обработчика
+		}
+		reentered = append(reentered, orderUID+":"+string(reason))
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cache.Set(&models.Order{OrderUID: "order-1"})
+		cache.Set(&models.Order{OrderUID: "order-2"})
+		cache.Set(&models.Order{OrderUID: "order-3"})
+		cache.Clear()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("обработчик OnEvict вызван под блокировкой кэша")
+	}
+	assert.Equal(t, []string{"order-2:deleted", "order-1:evicted", "order-3:cleared"}, reentered)
+}
+
+func TestCache_SnapshotRoundTrip(t *testing.T) {
+	clock := newFakeClock()
+	source := New(30*time.Minute, WithClock(clock))
+	source.Set(&models.Order{OrderUID: "expired"})
+	clock.Advance(20 * time.Minute)
+	source.Set(&models.Order{OrderUID: "old", Locale: "en"})
+	clock.Advance(15 * time.Minute) // "expired" истек еще до сохранения
+	source.Set(&models.Order{
+		OrderUID: "fresh",
+		Locale:   "ru",
+		Delivery: models.Delivery{Name: "Test"},
+		Items:    []models.Item{{ChrtID: 1, Name: "item"}},
+	})
+
+	var buf bytes.Buffer
+	require.NoError(t, source.WriteSnapshot(&buf))
+
+	// Сервис стоял 20 минут: у "old" оставалось 15 минут, у "fresh" — 30
+	clock.Advance(20 * time.Minute)
+	restored := New(30*time.Minute, WithClock(clock))
+	n, err := restored.ReadSnapshot(&buf, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n, "заказы, истекшие до сохранения и за время остановки, отбрасываются")
+
+	_, exists := restored.Get("old")
+	assert.False(t, exists)
+	order, exists := restored.Get("fresh")
+	require.True(t, exists)
+	assert.Equal(t, "ru", order.Locale)
+	assert.Equal(t, "Test", order.Delivery.Name)
+	assert.Len(t, order.Items, 1)
+
+	// Загрузка не продлевает срок жизни: осталось 10 минут из 30
+	clock.Advance(11 * time.Minute)
+	_, exists = restored.Get("fresh")
+	assert.False(t, exists)
+}
+
+func TestCache_SnapshotCorrupt(t *testing.T) {
+	cache := New(30 * time.Minute)
+	cache.Set(&models.Order{OrderUID: "order-1"})
+
+	var buf bytes.Buffer
+	require.NoError(t, New(30*time.Minute).WriteSnapshot(&buf))
+
+	for name, data := range map[string][]byte{
+		"Empty":     nil,
+		"Garbage":   []byte("not a snapshot"),
+		"Truncated": buf.Bytes()[:buf.Len()/2],
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := cache.ReadSnapshot(bytes.NewReader(data), time.Hour)
+			assert.Error(t, err)
+			assert.Equal(t, 1, cache.Size(), "поврежденный снимок не меняет кэш")
+		})
+	}
+}
+
+func TestCache_SnapshotTooOld(t *testing.T) {
+	clock := newFakeClock()
+	source := New(30*time.Minute, WithClock(clock))
+	source.Set(&models.Order{OrderUID: "order-1"})
+
+	var buf bytes.Buffer
+	require.NoError(t, source.WriteSnapshot(&buf))
+	data := buf.Bytes()
+
+	clock.Advance(2 * time.Minute)
+	restored := New(30*time.Minute, WithClock(clock))
+	_, err := restored.ReadSnapshot(bytes.NewReader(data), time.Minute)
+	assert.True(t, errors.Is(err, ErrSnapshotTooOld))
+	assert.Equal(t, 0, restored.Size())
+
+	n, err := restored.ReadSnapshot(bytes.NewReader(data), 0)
+	require.NoError(t, err, "0 — возраст снимка не ограничен")
+	assert.Equal(t, 1, n)
+}
+
+// indexedUIDs возвращает UID заказов, записанных в индекс по трек-номеру, во всех сегментах
+func indexedUIDs(c *Cache, trackNumber string) []string {
+	var uids []string
+	for _, s := range c.shards {
+		s.mu.RLock()
+		for uid := range s.tracks[trackNumber] {
+			uids = append(uids, uid)
+		}
+		s.mu.RUnlock()
+	}
+	return uids
+}
+
+func TestCache_GetByTrackNumber(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(30*time.Minute, WithClock(clock))
+	cache.Set(&models.Order{OrderUID: "order-2", TrackNumber: "TRACK-1"})
+	cache.Set(&models.Order{OrderUID: "order-1", TrackNumber: "TRACK-1"})
+	cache.Set(&models.Order{OrderUID: "order-3", TrackNumber: "TRACK-2"})
+
+	orders := cache.GetByTrackNumber("TRACK-1")
+	require.Len(t, orders, 2)
+	assert.Equal(t, "order-1", orders[0].OrderUID, "заказы упорядочены по UID")
+	assert.Equal(t, "order-2", orders[1].OrderUID)
+	assert.Nil(t, cache.GetByTrackNumber("UNKNOWN"))
+
+	// Возвращаются копии, как и в Get
+	orders[0].TrackNumber = "CHANGED"
+	assert.Len(t, cache.GetByTrackNumber("TRACK-1"), 2)
+
+	clock.Advance(31 * time.Minute)
+	assert.Nil(t, cache.GetByTrackNumber("TRACK-1"), "истекшие заказы не возвращаются")
+}
+
+func TestCache_DeleteCreatedBefore(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(30*time.Minute, WithClock(clock))
+	var evicted []string
+	cache.OnEvict(func(uid string, reason EvictReason) {
+		assert.Equal(t, EvictDeleted, reason)
+		evicted = append(evicted, uid)
+	})
+
+	cutoff := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache.Set(&models.Order{OrderUID: "old", TrackNumber: "TRACK", DateCreated: cutoff.Add(-time.Hour)})
+	cache.SetWithTTL(&models.Order{OrderUID: "old-expired", DateCreated: cutoff.Add(-time.Hour)}, time.Minute)
+	cache.Set(&models.Order{OrderUID: "boundary", DateCreated: cutoff})
+	cache.Set(&models.Order{OrderUID: "recent", DateCreated: cutoff.Add(time.Hour)})
+	clock.Advance(2 * time.Minute)
+
+	assert.Equal(t, 1, cache.DeleteCreatedBefore(cutoff), "истекший заказ удаляется, но не учитывается")
+	assert.ElementsMatch(t, []string{"old", "old-expired"}, evicted)
+	assert.Equal(t, 2, cache.Size(), "заказ, созданный ровно в cutoff, остается")
+	assert.Empty(t, cache.GetByTrackNumber("TRACK"), "индекс по трек-номеру обновлен")
+
+	bytes, _ := cache.MemoryUsage()
+	assert.Equal(t, estimateSize(&models.Order{OrderUID: "boundary", DateCreated: cutoff})+
+		estimateSize(&models.Order{OrderUID: "recent", DateCreated: cutoff.Add(time.Hour)}), bytes)
+}
+
+func TestCache_NewestDateCreated(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(30*time.Minute, WithClock(clock))
+	assert.True(t, cache.NewestDateCreated().IsZero(), "пустой кэш")
+
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	cache.Set(&models.Order{OrderUID: "order-1", DateCreated: base})
+	cache.SetWithTTL(&models.Order{OrderUID: "order-2", DateCreated: base.Add(time.Hour)}, time.Minute)
+	assert.Equal(t, base.Add(time.Hour), cache.NewestDateCreated())
+
+	clock.Advance(2 * time.Minute)
+	assert.Equal(t, base, cache.NewestDateCreated(), "истекшие заказы не учитываются")
+}
+
+func TestCache_TrackIndexConsistency(t *testing.T) {
+	t.Run("TrackNumberChanged", func(t *testing.T) {
+		cache := New(30 * time.Minute)
+		cache.Set(&models.Order{OrderUID: "order-1", TrackNumber: "OLD"})
+		cache.Set(&models.Order{OrderUID: "order-1", TrackNumber: "NEW"})
+
+		assert.Nil(t, cache.GetByTrackNumber("OLD"), "старая запись индекса удаляется")
+		assert.Empty(t, indexedUIDs(cache, "OLD"))
+		assert.Len(t, cache.GetByTrackNumber("NEW"), 1)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		cache := New(30 * time.Minute)
+		cache.Set(&models.Order{OrderUID: "order-1", TrackNumber: "TRACK"})
+		cache.Set(&models.Order{OrderUID: "order-2", TrackNumber: "TRACK"})
+		cache.Delete("order-1")
+
+		assert.Equal(t, []string{"order-2"}, indexedUIDs(cache, "TRACK"))
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		clock := newFakeClock()
+		cache := New(30*time.Minute, WithClock(clock))
+		cache.Set(&models.Order{OrderUID: "order-1", TrackNumber: "TRACK"})
+		clock.Advance(31 * time.Minute)
+		cache.Cleanup()
+
+		assert.Empty(t, indexedUIDs(cache, "TRACK"))
+	})
+
+	t.Run("Clear", func(t *testing.T) {
+		cache := New(30 * time.Minute)
+		cache.Set(&models.Order{OrderUID: "order-1", TrackNumber: "TRACK"})
+		cache.Clear()
+
+		assert.Empty(t, indexedUIDs(cache, "TRACK"))
+	})
+
+	t.Run("Evicted", func(t *testing.T) {
+		cache := New(30*time.Minute, WithMaxEntries(1))
+		cache.Set(&models.Order{OrderUID: "order-1", TrackNumber: "TRACK"})
+		cache.Set(&models.Order{OrderUID: "order-2", TrackNumber: "TRACK"})
+
+		assert.Equal(t, []string{"order-2"}, indexedUIDs(cache, "TRACK"))
+	})
+
+	t.Run("ReplaceAll", func(t *testing.T) {
+		cache := New(30 * time.Minute)
+		cache.Set(&models.Order{OrderUID: "order-1", TrackNumber: "OLD"})
+		cache.ReplaceAll([]models.Order{{OrderUID: "order-1", TrackNumber: "NEW"}})
+
+		assert.Empty(t, indexedUIDs(cache, "OLD"))
+		assert.Len(t, cache.GetByTrackNumber("NEW"), 1)
+	})
+}
+
+func TestCache_GetOrSet(t *testing.T) {
+	t.Run("LoadsAndCaches", func(t *testing.T) {
+		cache := New(30 * time.Minute)
+		calls := 0
+		loader := func() (*models.Order, error) {
+			calls++
+			return &models.Order{OrderUID: "order-1", Locale: "en"}, nil
+		}
+
+		order, cached, err := cache.GetOrSet("order-1", loader)
+		require.NoError(t, err)
+		assert.False(t, cached)
+		assert.Equal(t, "en", order.Locale)
+
+		order, cached, err = cache.GetOrSet("order-1", loader)
+		require.NoError(t, err)
+		assert.True(t, cached, "второй вызов отдает заказ из кэша")
+		assert.Equal(t, "en", order.Locale)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("LoaderErrorNotCached", func(t *testing.T) {
+		cache := New(30 * time.Minute)
+		loadErr := errors.New("db down")
+
+		_, _, err := cache.GetOrSet("order-1", func() (*models.Order, error) { return nil, loadErr })
+		assert.ErrorIs(t, err, loadErr)
+		assert.Equal(t, 0, cache.Size())
+
+		order, cached, err := cache.GetOrSet("order-1", func() (*models.Order, error) {
+			return &models.Order{OrderUID: "order-1"}, nil
+		})
+		require.NoError(t, err, "после ошибки следующий вызов снова загружает заказ")
+		assert.False(t, cached)
+		assert.NotNil(t, order)
+	})
+
+	t.Run("ExpiredReloaded", func(t *testing.T) {
+		clock := newFakeClock()
+		cache := New(30*time.Minute, WithClock(clock))
+		cache.Set(&models.Order{OrderUID: "order-1", Locale: "en"})
+		clock.Advance(31 * time.Minute)
+
+		order, cached, err := cache.GetOrSet("order-1", func() (*models.Order, error) {
+			return &models.Order{OrderUID: "order-1", Locale: "ru"}, nil
+		})
+		require.NoError(t, err)
+		assert.False(t, cached, "истекший заказ загружается заново")
+		assert.Equal(t, "ru", order.Locale)
+
+		stored, exists := cache.Get("order-1")
+		require.True(t, exists)
+		assert.Equal(t, "ru", stored.Locale)
+	})
+
+	t.Run("ConcurrentCallersShareLoad", func(t *testing.T) {
+		cache := New(30 * time.Minute)
+		var calls atomic.Int32
+		release := make(chan struct{})
+		loader := func() (*models.Order, error) {
+			calls.Add(1)
+			<-release
+			return &models.Order{OrderUID: "order-1", Locale: "en"}, nil
+		}
+
+		const callers = 10
+		var started, wg sync.WaitGroup
+		results := make([]*models.Order, callers)
+		for i := 0; i < callers; i++ {
+			started.Add(1)
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				started.Done()
+				order, _, err := cache.GetOrSet("order-1", loader)
+				assert.NoError(t, err)
+				results[i] = order
+			}(i)
+		}
+		started.Wait()
+		assert.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, int32(1), calls.Load(), "loader вызывается один раз на UID")
+		for i, order := range results {
+			require.NotNil(t, order)
+			assert.Equal(t, "en", order.Locale)
+			if i > 0 {
+				assert.NotSame(t, results[0], order, "каждый вызывающий получает свою копию")
+			}
+		}
+	})
+
+	t.Run("ConcurrentCallersShareError", func(t *testing.T) {
+		cache := New(30 * time.Minute)
+		loadErr := errors.New("db down")
+		release := make(chan struct{})
+		var calls atomic.Int32
+		loader := func() (*models.Order, error) {
+			calls.Add(1)
+			<-release
+			return nil, loadErr
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, _, err := cache.GetOrSet("order-1", loader)
+				assert.ErrorIs(t, err, loadErr)
+			}()
+		}
+		assert.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+		close(release)
+		wg.Wait()
+		assert.Equal(t, 0, cache.Size())
+	})
+}
+
+func TestCache_ZeroTTLNeverExpires(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(0, WithClock(clock))
+	cache.Set(&models.Order{OrderUID: "order-1"})
+
+	clock.Advance(365 * 24 * time.Hour)
+	cache.Cleanup()
+
+	_, exists := cache.Get("order-1")
+	assert.True(t, exists, "TTL 0 — заказ не истекает")
+	assert.Equal(t, 1, cache.Size())
+	assert.Len(t, cache.GetAll(), 1)
+	assert.Equal(t, 1, storedCount(cache), "Cleanup не удаляет заказы без срока жизни")
+}
+
+func TestCache_SetWithTTL(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(30*time.Minute, WithClock(clock))
+	cache.SetWithTTL(&models.Order{OrderUID: "fixture"}, 0)
+	cache.SetWithTTL(&models.Order{OrderUID: "short"}, time.Minute)
+	cache.Set(&models.Order{OrderUID: "default"})
+
+	clock.Advance(2 * time.Minute)
+	_, exists := cache.Get("short")
+	assert.False(t, exists, "собственный TTL заказа")
+	_, exists = cache.Get("default")
+	assert.True(t, exists)
+
+	clock.Advance(time.Hour)
+	cache.Cleanup()
+	_, exists = cache.Get("fixture")
+	assert.True(t, exists, "TTL <= 0 в SetWithTTL — заказ не истекает")
+	assert.Equal(t, 1, storedCount(cache))
+
+	// Повторный Set возвращает заказу время жизни кэша
+	cache.Set(&models.Order{OrderUID: "fixture"})
+	clock.Advance(31 * time.Minute)
+	_, exists = cache.Get("fixture")
+	assert.False(t, exists)
+}
+
+func TestCache_KeepAlive(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(30*time.Minute, WithClock(clock))
+	cache.Set(&models.Order{OrderUID: "order-1"})
+	cache.SetWithTTL(&models.Order{OrderUID: "order-2"}, 10*time.Minute)
+
+	clock.Advance(25 * time.Minute)
+	assert.True(t, cache.KeepAlive("order-1"))
+	assert.False(t, cache.KeepAlive("order-2"), "истекший заказ не продлевается")
+	assert.False(t, cache.KeepAlive("missing"))
+
+	// Отсчет начался заново: 30 минут от KeepAlive
+	clock.Advance(29 * time.Minute)
+	_, exists := cache.Get("order-1")
+	assert.True(t, exists)
+	clock.Advance(2 * time.Minute)
+	_, exists = cache.Get("order-1")
+	assert.False(t, exists)
+}
+
+func TestCache_KeepAliveRespectsMaxLifetime(t *testing.T) {
+	clock := newFakeClock()
+	cache := New(30*time.Minute, WithClock(clock), WithSlidingTTL(true), WithMaxLifetime(time.Hour))
+	cache.Set(&models.Order{OrderUID: "order-1"})
+
+	clock.Advance(50 * time.Minute)
+	cache.KeepAlive("order-1")
+	clock.Advance(11 * time.Minute)
+	_, exists := cache.Get("order-1")
+	assert.False(t, exists, "KeepAlive не продлевает заказ сверх предельного срока")
+}
+
+func TestCache_ZeroTTLStillEvicted(t *testing.T) {
+	cache := New(30*time.Minute, WithMaxEntries(2))
+	cache.SetWithTTL(&models.Order{OrderUID: "fixture"}, 0)
+	cache.Set(&models.Order{OrderUID: "order-1"})
+	cache.Set(&models.Order{OrderUID: "order-2"})
+
+	_, exists := cache.Get("fixture")
+	assert.False(t, exists, "заказ без срока жизни вытесняется при нехватке места, как и остальные")
+	assert.Equal(t, uint64(1), cache.Evicted())
+}
+
+func TestCache_SnapshotZeroTTL(t *testing.T) {
+	clock := newFakeClock()
+	source := New(30*time.Minute, WithClock(clock))
+	source.SetWithTTL(&models.Order{OrderUID: "fixture"}, 0)
+	source.SetWithTTL(&models.Order{OrderUID: "short"}, 10*time.Minute)
+
+	var buf bytes.Buffer
+	require.NoError(t, source.WriteSnapshot(&buf))
+
+	clock.Advance(5 * time.Minute)
+	restored := New(30*time.Minute, WithClock(clock))
+	n, err := restored.ReadSnapshot(&buf, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	// Заказ восстанавливается со своим TTL: KeepAlive отсчитывает 10 минут, а не 30
+	assert.True(t, restored.KeepAlive("short"))
+	clock.Advance(11 * time.Minute)
+	_, exists := restored.Get("short")
+	assert.False(t, exists)
+
+	clock.Advance(24 * time.Hour)
+	_, exists = restored.Get("fixture")
+	assert.True(t, exists, "заказ без срока жизни остается бессрочным после загрузки")
+}
@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock источник текущего времени для решений об истечении заказов
+type Clock interface {
+	Now() time.Time
+}
+
+// realClock системное время
+type realClock struct{}
+
+// Now возвращает текущее системное время
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// WithClock подменяет источник времени кэша; по умолчанию используется системное время.
+// Фоновая очистка (StartJanitor) срабатывает по реальному таймеру независимо от clock.
+func WithClock(clock Clock) Option {
+	return func(c *Cache) {
+		if clock != nil {
+			c.clock = clock
+		}
+	}
+}
+
+// FakeClock управляемый источник времени для тестов: время идет только через Advance
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFakeClock создает FakeClock, показывающий время start
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+// Now возвращает текущее время часов
+func (f *FakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Advance переводит часы вперед на d
+func (f *FakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// EvictReason причина удаления заказа из кэша
+type EvictReason string
+
+// Причины удаления заказа
+const (
+	EvictExpired EvictReason = "expired" // Истек срок жизни, удален очисткой (Cleanup)
+	EvictEvicted EvictReason = "evicted" // Вытеснен из-за ограничения размера (LRU)
+	EvictDeleted EvictReason = "deleted" // Удален явно (Delete, DeleteCreatedBefore)
+	EvictCleared EvictReason = "cleared" // Удален при очистке или замене всего кэша (Clear, ReplaceAll)
+)
+
+// EvictFunc обработчик удаления заказа из кэша
+type EvictFunc func(orderUID string, reason EvictReason)
+
+// eviction удаленный заказ, о котором нужно уведомить обработчики
+type eviction struct {
+	orderUID string
+	reason   EvictReason
+}
+
+// hooks зарегистрированные обработчики удаления
+type hooks struct {
+	mu  sync.Mutex                  // Сериализует регистрацию
+	fns atomic.Pointer[[]EvictFunc] // Копия при записи: чтение без блокировок
+}
+
+// OnEvict регистрирует обработчик, вызываемый при удалении заказа очисткой, вытеснением,
+// Delete, Clear или ReplaceAll. Обработчики вызываются после снятия блокировок кэша
+// в горутине, удалившей заказ, поэтому могут обращаться к кэшу; долгая работа
+// в обработчике задерживает вызвавшую операцию.
+func (c *Cache) OnEvict(fn EvictFunc) {
+	if fn == nil {
+		return
+	}
+	c.hooks.mu.Lock()
+	defer c.hooks.mu.Unlock()
+
+	var fns []EvictFunc
+	if current := c.hooks.fns.Load(); current != nil {
+		fns = append(fns, *current...)
+	}
+	fns = append(fns, fn)
+	c.hooks.fns.Store(&fns)
+}
+
+// hooked сообщает, есть ли обработчики; без них удаленные заказы не собираются
+func (c *Cache) hooked() bool {
+	return c.hooks.fns.Load() != nil
+}
+
+// record запоминает удаленный заказ для уведомления; вызывается под блокировкой сегмента
+func (c *Cache) record(out *[]eviction, orderUID string, reason EvictReason) {
+	if out != nil && c.hooked() {
+		*out = append(*out, eviction{orderUID: orderUID, reason: reason})
+	}
+}
+
+// notify вызывает обработчики для удаленных заказов; вызывается без блокировок кэша
+func (c *Cache) notify(removed []eviction) {
+	if len(removed) == 0 {
+		return
+	}
+	fns := c.hooks.fns.Load()
+	if fns == nil {
+		return
+	}
+	for _, e := range removed {
+		for _, fn := range *fns {
+			fn(e.orderUID, e.reason)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// janitor фоновая задача, периодически удаляющая истекшие заказы из кэша
+type janitor struct {
+	mu   sync.Mutex    // Защищает stop и done
+	stop chan struct{} // Закрывается для остановки задачи
+	done chan struct{} // Закрывается задачей при завершении
+}
+
+// WithCleanupInterval запускает фоновую очистку истекших заказов с заданным периодом
+// сразу при создании кэша. d <= 0 означает, что очистка не запускается.
+func WithCleanupInterval(d time.Duration) Option {
+	return func(c *Cache) {
+		c.cleanupInterval = d
+	}
+}
+
+// StartJanitor запускает фоновую задачу, которая каждые interval вызывает Cleanup,
+// удаляя истекшие заказы из словаря, а не только скрывая их от чтения.
+// Повторный запуск без StopJanitor ничего не делает; interval <= 0 — тоже.
+func (c *Cache) StartJanitor(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	c.janitor.mu.Lock()
+	defer c.janitor.mu.Unlock()
+	if c.janitor.stop != nil {
+		return
+	}
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	c.janitor.stop, c.janitor.done = stop, done
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				// Остановка имеет приоритет над одновременно сработавшим тикером
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				c.Cleanup()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// StopJanitor останавливает фоновую очистку и дожидается ее завершения:
+// после возврата Cleanup по таймеру больше не вызывается. Повторный вызов безопасен.
+func (c *Cache) StopJanitor() {
+	c.janitor.mu.Lock()
+	stop, done := c.janitor.stop, c.janitor.done
+	c.janitor.stop, c.janitor.done = nil, nil
+	c.janitor.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"sync"
+
+	"test_service/internal/models"
+)
+
+// load загрузка заказа для GetOrSet; вызовы с тем же UID ждут ее результат
+type load struct {
+	done  chan struct{} // Закрывается по завершении загрузки
+	order *models.Order
+	err   error
+}
+
+// loads выполняющиеся загрузки по UID заказа
+type loads struct {
+	mu    sync.Mutex
+	calls map[string]*load
+}
+
+// GetOrSet возвращает заказ из кэша (cached = true), а при его отсутствии или истечении
+// загружает через loader и сохраняет со стандартным TTL. Одновременные вызовы с одним UID
+// вызывают loader один раз и получают его результат; ошибка loader не кэшируется
+// и возвращается всем ожидавшим.
+func (c *Cache) GetOrSet(orderUID string, loader func() (*models.Order, error)) (order *models.Order, cached bool, err error) {
+	if order, ok := c.Get(orderUID); ok {
+		return order, true, nil
+	}
+
+	c.loads.mu.Lock()
+	if l, ok := c.loads.calls[orderUID]; ok {
+		c.loads.mu.Unlock()
+		<-l.done
+		return c.loaded(l)
+	}
+	// Предыдущая загрузка могла завершиться между Get и захватом блокировки
+	if order, ok := c.Get(orderUID); ok {
+		c.loads.mu.Unlock()
+		return order, true, nil
+	}
+	l := &load{done: make(chan struct{})}
+	if c.loads.calls == nil {
+		c.loads.calls = make(map[string]*load)
+	}
+	c.loads.calls[orderUID] = l
+	c.loads.mu.Unlock()
+
+	defer func() {
+		c.loads.mu.Lock()
+		delete(c.loads.calls, orderUID)
+		c.loads.mu.Unlock()
+		close(l.done)
+	}()
+
+	l.order, l.err = loader()
+	if l.err == nil && l.order != nil {
+		c.Set(l.order) // Сохраняем до снятия загрузки, чтобы следующие вызовы попали в кэш
+	}
+	return c.loaded(l)
+}
+
+// loaded возвращает результат завершенной загрузки; каждый вызывающий получает свою копию
+func (c *Cache) loaded(l *load) (*models.Order, bool, error) {
+	if l.err != nil || l.order == nil {
+		return nil, false, l.err
+	}
+	return c.copyOf(l.order), false, nil
+}
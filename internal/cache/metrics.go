@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metrics содержит метрики кэша заказов
+type Metrics struct {
+	EvictionsTotal   prometheus.Counter
+	StaleServesTotal prometheus.Counter
+	Bytes            prometheus.Gauge
+	BytesBudget      prometheus.Gauge
+}
+
+// Global metrics для предотвращения дублирования метрик
+var globalMetrics *Metrics
+
+// NewMetrics создает и регистрирует метрики кэша
+func NewMetrics() *Metrics {
+	// Возвращаем глобальный экземпляр, чтобы избежать дублирования метрик
+	if globalMetrics != nil {
+		return globalMetrics
+	}
+
+	globalMetrics = &Metrics{
+		EvictionsTotal: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "cache_evictions_total",
+			Help: "Количество заказов, вытесненных из кэша из-за ограничения размера",
+		}),
+		StaleServesTotal: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "cache_stale_serves_total",
+			Help: "Количество истекших заказов, отданных в режиме stale-while-revalidate",
+		}),
+		Bytes: promauto.NewGauge(prometheus.GaugeOpts{
+			Name: "cache_bytes",
+			Help: "Приблизительный объем памяти, занятой заказами в кэше, в байтах",
+		}),
+		BytesBudget: promauto.NewGauge(prometheus.GaugeOpts{
+			Name: "cache_bytes_budget",
+			Help: "Бюджет памяти кэша в байтах (0 — без ограничения)",
+		}),
+	}
+
+	return globalMetrics
+}
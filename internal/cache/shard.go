@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"container/list"
+	"hash/fnv"
+	"sync"
+
+	"test_service/internal/models"
+)
+
+// defaultShards число сегментов кэша
+const defaultShards = 32
+
+// Наименьшие доли ограничений размера на сегмент: с меньшими ограничениями сегментов становится
+// меньше, чтобы вытеснение внутри сегмента не уходило далеко от глобального порядка LRU
+const (
+	minShardEntries       = 64
+	minShardBytes   int64 = 1 << 20
+)
+
+// shard сегмент кэша со своей блокировкой: запись в один сегмент не блокирует чтение других
+type shard struct {
+	mu     sync.RWMutex                   // Мьютекс сегмента
+	orders map[string]*list.Element       // Словарь заказов по их UID; значение элемента — *CachedOrderItem
+	lru    *list.List                     // Порядок использования: в начале недавно использованные
+	bytes  int64                          // Оценка памяти, занятой заказами сегмента
+	tracks map[string]map[string]struct{} // Вторичный индекс: трек-номер → UID заказов сегмента
+}
+
+// newShard создает пустой сегмент
+func newShard() *shard {
+	return &shard{
+		orders: make(map[string]*list.Element),
+		lru:    list.New(),
+		tracks: make(map[string]map[string]struct{}),
+	}
+}
+
+// index добавляет заказ в индекс по трек-номеру; вызывается под s.mu
+func (s *shard) index(order *models.Order) {
+	if order.TrackNumber == "" {
+		return
+	}
+	uids, ok := s.tracks[order.TrackNumber]
+	if !ok {
+		uids = make(map[string]struct{}, 1)
+		s.tracks[order.TrackNumber] = uids
+	}
+	uids[order.OrderUID] = struct{}{}
+}
+
+// unindex удаляет заказ из индекса по трек-номеру; вызывается под s.mu
+func (s *shard) unindex(order *models.Order) {
+	uids, ok := s.tracks[order.TrackNumber]
+	if !ok {
+		return
+	}
+	delete(uids, order.OrderUID)
+	if len(uids) == 0 {
+		delete(s.tracks, order.TrackNumber)
+	}
+}
+
+// reset заменяет словарь и список сегмента пустыми; вызывается под s.mu.
+// Возвращает количество удаленных заказов и освобожденную память.
+func (s *shard) reset() (removed int, bytes int64) {
+	removed, bytes = len(s.orders), s.bytes
+	s.orders = make(map[string]*list.Element)
+	s.lru = list.New()
+	s.bytes = 0
+	s.tracks = make(map[string]map[string]struct{})
+	return removed, bytes
+}
+
+// shardIndex выбирает сегмент по FNV-хешу UID заказа
+func shardIndex(orderUID string, n int) int {
+	if n == 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(orderUID))
+	return int(h.Sum32() % uint32(n))
+}
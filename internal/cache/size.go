@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"container/list"
+	"unsafe"
+
+	"test_service/internal/models"
+)
+
+// Накладные расходы, не зависящие от содержимого заказа: сами структуры
+// (заголовки строк, числа, время) и служебные элементы словаря и списка LRU
+const (
+	orderOverhead = int64(unsafe.Sizeof(models.Order{})) + entryOverhead
+	itemOverhead  = int64(unsafe.Sizeof(models.Item{}))
+	entryOverhead = int64(unsafe.Sizeof(CachedOrderItem{})+unsafe.Sizeof(list.Element{})) + mapEntryOverhead
+	// mapEntryOverhead приблизительная стоимость записи словаря (ключ, указатель, служебные поля)
+	mapEntryOverhead = 48
+)
+
+// estimateSize приблизительно оценивает память, занимаемую заказом в кэше:
+// фиксированные накладные расходы плюс длины всех строк
+func estimateSize(o *models.Order) int64 {
+	n := orderOverhead + strLen(
+		o.OrderUID, o.TrackNumber, o.Entry, o.Locale, o.InternalSignature,
+		o.CustomerID, o.DeliveryService, o.ShardKey, o.OOFShard,
+		// Доставка
+		o.Delivery.OrderUID, o.Delivery.Name, o.Delivery.Phone, o.Delivery.Zip,
+		o.Delivery.City, o.Delivery.Address, o.Delivery.Region, o.Delivery.Email,
+		// Оплата
+		o.Payment.OrderUID, o.Payment.Transaction, o.Payment.RequestID,
+		o.Payment.Currency, o.Payment.Provider, o.Payment.Bank,
+	)
+	for i := range o.Items {
+		it := &o.Items[i]
+		n += itemOverhead + strLen(it.OrderUID, it.TrackNumber, it.RID, it.Name, it.Size, it.Brand)
+	}
+	return n
+}
+
+// strLen суммирует длины строк в байтах
+func strLen(values ...string) int64 {
+	var n int64
+	for _, v := range values {
+		n += int64(len(v))
+	}
+	return n
+}
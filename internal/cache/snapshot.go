@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"test_service/internal/models"
+)
+
+// snapshotVersion версия формата снимка; снимок другой версии не загружается
+const snapshotVersion = 2
+
+// ErrSnapshotTooOld снимок старше допустимого возраста
+var ErrSnapshotTooOld = errors.New("снимок кэша устарел")
+
+// snapshot снимок кэша на диске (gob): заказы с оставшимся временем жизни
+type snapshot struct {
+	Version int
+	SavedAt time.Time
+	Entries []snapshotEntry
+}
+
+// snapshotEntry заказ снимка и оставшееся на момент сохранения время жизни
+type snapshotEntry struct {
+	Order    models.Order
+	TTL      time.Duration // Оставшееся время жизни (0 — заказ не истекает)
+	Lifetime time.Duration // Время жизни заказа для продления (KeepAlive, скользящий TTL)
+}
+
+// WriteSnapshot сохраняет неистекшие заказы кэша вместе с оставшимся временем жизни в w.
+// Сегменты обходятся по очереди, как в GetAll.
+func (c *Cache) WriteSnapshot(w io.Writer) error {
+	now := c.clock.Now()
+	snap := snapshot{Version: snapshotVersion, SavedAt: now}
+	for _, s := range c.shards {
+		s.mu.RLock()
+		for _, el := range s.orders {
+			item := el.Value.(*CachedOrderItem)
+			entry := snapshotEntry{Order: *item.order, Lifetime: item.ttl}
+			if !item.expireTime.IsZero() {
+				if !now.Before(item.expireTime) {
+					continue // Истекшие заказы не сохраняем
+				}
+				entry.TTL = item.expireTime.Sub(now)
+			}
+			snap.Entries = append(snap.Entries, entry)
+		}
+		s.mu.RUnlock()
+	}
+
+	if err := gob.NewEncoder(w).Encode(&snap); err != nil {
+		return fmt.Errorf("ошибка записи снимка кэша: %w", err)
+	}
+	return nil
+}
+
+// ReadSnapshot загружает заказы из снимка, сохраненного WriteSnapshot, и возвращает их количество.
+// Время жизни отсчитывается от момента сохранения: заказы, истекшие с тех пор, отбрасываются.
+// Снимок старше maxAge (при maxAge > 0) не загружается и возвращается ErrSnapshotTooOld.
+// Снимок разбирается целиком до загрузки, поэтому поврежденный файл не меняет кэш.
+func (c *Cache) ReadSnapshot(r io.Reader, maxAge time.Duration) (int, error) {
+	var snap snapshot
+	if err := gob.NewDecoder(r).Decode(&snap); err != nil {
+		return 0, fmt.Errorf("снимок кэша поврежден: %w", err)
+	}
+	if snap.Version != snapshotVersion {
+		return 0, fmt.Errorf("неподдерживаемая версия снимка кэша: %d", snap.Version)
+	}
+
+	now := c.clock.Now()
+	age := now.Sub(snap.SavedAt)
+	if maxAge > 0 && age > maxAge {
+		return 0, fmt.Errorf("%w: сохранен %s назад", ErrSnapshotTooOld, age.Round(time.Second))
+	}
+
+	loaded := 0
+	var removed []eviction
+	for i := range snap.Entries {
+		entry := &snap.Entries[i]
+		remaining := entry.TTL - age
+		if entry.TTL > 0 && remaining <= 0 {
+			continue // Истек, пока сервис был остановлен
+		}
+
+		s := c.shardFor(entry.Order.OrderUID)
+		s.mu.Lock()
+		before := s.bytes
+		c.set(s, &entry.Order, entry.Lifetime, &removed)
+		// Не продлеваем жизнь заказа сверх оставшейся на момент сохранения
+		if el, ok := s.orders[entry.Order.OrderUID]; ok && entry.TTL > 0 {
+			item := el.Value.(*CachedOrderItem)
+			if expire := now.Add(remaining); expire.Before(item.expireTime) {
+				item.expireTime = expire
+			}
+		}
+		c.evict(s, &removed)
+		c.addBytes(s.bytes - before)
+		s.mu.Unlock()
+		loaded++
+	}
+	c.notify(removed)
+	return loaded, nil
+}
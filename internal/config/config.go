@@ -2,20 +2,141 @@ package config
 
 import (
 	"errors"
+	"fmt"
+	"math"
 	"os"
+	"strconv"
 	"strings"
+	"time"
+
+	"test_service/internal/database"
 
 	"github.com/joho/godotenv"
 )
 
 // Config содержит конфигурацию сервиса, считанную из переменных окружения
 type Config struct {
-	ServerAddr   string   // Адрес HTTP сервера, например :8081
-	PostgresDSN  string   // Строка подключения к PostgreSQL
-	KafkaBrokers []string // Список брокеров Kafka
-	KafkaTopic   string   // Топик Kafka
-	KafkaGroupID string   // Группа консюмера Kafka
-	StaticDir    string   // Путь к статическим файлам
+	ServerAddr      string   // Адрес HTTP сервера, например :8081
+	PostgresDSN     string   // Строка подключения к PostgreSQL
+	PostgresReadDSN string   // Строка подключения к реплике для чтения (пусто — чтение с основного сервера)
+	KafkaBrokers    []string // Список брокеров Kafka
+	KafkaTopic      string   // Топик Kafka
+	KafkaTopics     []string // Топики заказов, которые читает consumer (по умолчанию — KafkaTopic)
+	KafkaGroupID    string   // Группа консюмера Kafka
+	StaticDir       string   // Путь к статическим файлам
+
+	KafkaConsumerConcurrency int // Количество параллельных обработчиков сообщений consumer
+
+	KafkaConsumerBatchSize    int           // Сообщений в пакете consumer (0 — без пакетов)
+	KafkaConsumerBatchTimeout time.Duration // Ожидание пакета consumer с первого сообщения
+
+	KafkaCodec string // Формат тела сообщений с заказами: json или protobuf
+
+	KafkaDedupWindow     time.Duration // Окно подавления повторов заказов consumer (0 — выключено)
+	KafkaDedupMaxEntries int           // Заказов, которые помнит окно подавления повторов
+
+	KafkaDeliveryMode        string        // Гарантия доставки consumer: at_most_once или at_least_once
+	KafkaDeliveryBackoff     time.Duration // Задержка перед повторной доставкой в режиме at_least_once
+	KafkaDeliveryMaxFailures int           // Неудачных доставок до пропуска сообщения (0 — без ограничения)
+
+	KafkaLagInterval time.Duration // Период публикации отставания consumer по партициям (0 — выключено)
+
+	KafkaCommitInterval time.Duration // Интервал коммита смещений consumer (0 — синхронный коммит)
+	KafkaStartOffset    string        // Начало чтения партиции без закоммиченного смещения: first или last
+	KafkaMinBytes       int           // Минимум байт в ответе fetch consumer
+	KafkaMaxBytes       int           // Максимум байт в ответе fetch consumer
+	KafkaMaxWait        time.Duration // Ожидание KafkaMinBytes в запросе fetch
+
+	KafkaSASLMechanism string // SASL-аутентификация на брокерах: PLAIN, SCRAM-SHA-256, SCRAM-SHA-512 (пусто — без нее)
+	KafkaSASLUsername  string // Имя пользователя SASL
+	KafkaSASLPassword  string // Пароль SASL
+
+	KafkaCompression  string        // Сжатие сообщений producer: none, gzip, snappy, lz4 или zstd
+	KafkaRequiredAcks string        // Подтверждения записи producer: all, one или none
+	KafkaWriteTimeout time.Duration // Таймаут записи producer
+	KafkaMaxAttempts  int           // Попыток записи producer до ошибки
+	KafkaBatchSize    int           // Сообщений в пакете producer
+	KafkaBatchTimeout time.Duration // Ожидание заполнения пакета producer
+
+	KafkaTLSEnabled            bool   // Шифровать подключения к брокерам
+	KafkaTLSCAFile             string // PEM с CA брокеров (пусто — системные CA)
+	KafkaTLSCertFile           string // PEM с сертификатом клиента для mutual TLS
+	KafkaTLSKeyFile            string // PEM с ключом сертификата клиента
+	KafkaTLSInsecureSkipVerify bool   // Не проверять сертификат брокера
+
+	KafkaRetryTopic     string          // Топик отложенных повторов заказов, не обработанных из-за сбоя
+	KafkaRetryTopics    []string        // Топики повторов KafkaTopics по порядку: KafkaRetryTopic или <топик>-retry для нескольких топиков
+	KafkaRetryDelays    []time.Duration // Задержки циклов повтора; дальше расписания — последняя
+	KafkaRetryMaxCycles int             // Циклов повтора до отправки в DLQ (0 — топик повторов отключен)
+
+	DBMaxConns          int           // Максимум соединений пула PostgreSQL (0 — умолчание pgxpool)
+	DBMinConns          int           // Минимум открытых соединений пула (0 — умолчание pgxpool)
+	DBMaxConnLifetime   time.Duration // Время жизни соединения (0 — умолчание pgxpool)
+	DBMaxConnIdleTime   time.Duration // Время простоя соединения до закрытия (0 — умолчание pgxpool)
+	DBHealthCheckPeriod time.Duration // Период проверки простаивающих соединений (0 — умолчание pgxpool)
+	DBQueryExecMode     string        // Режим выполнения запросов pgx: cache_statement, cache_describe, describe_exec, exec, simple_protocol
+	DBTxIsolation       string        // Уровень изоляции транзакции сохранения заказа (пусто — умолчание сервера)
+
+	DBItemsMultiRow  bool // Сохранять товары многострочными INSERT вместо одного запроса по массивам колонок
+	DBItemsPerInsert int  // Товаров в одном многострочном INSERT (DB_ITEMS_MULTIROW)
+
+	DBReadTimeout   time.Duration // Ограничение времени одной попытки чтения из БД
+	DBWriteTimeout  time.Duration // Ограничение времени одной попытки записи в БД
+	DBGetAllTimeout time.Duration // Ограничение времени одной попытки чтения всех заказов
+
+	DBTracing bool // Трассировка запросов к БД: спан на каждый запрос с меткой операции и UID заказа
+
+	StaticOptional bool // Не падать при недоступной статике, а отключить SPA маршруты
+	StaticEmbed    bool // Отдавать статику, встроенную в бинарник, вместо каталога StaticDir
+
+	CacheMaxEntries int   // Максимум заказов в кэше, давно не использованные вытесняются (0 — без ограничения)
+	CacheMaxBytes   int64 // Бюджет памяти кэша в байтах, оценка по содержимому заказов (0 — без ограничения)
+
+	CacheSlidingTTL  bool          // Продлевать срок жизни заказа в кэше при каждом чтении
+	CacheMaxLifetime time.Duration // Предельный срок жизни заказа со скользящим TTL (0 — без предела)
+	CacheMaxStale    time.Duration // Окно stale-while-revalidate после истечения заказа (0 — режим выключен)
+
+	CacheCleanupInterval time.Duration // Период удаления истекших заказов из кэша (0 — без фоновой очистки)
+
+	CacheSnapshotPath   string        // Файл снимка кэша, сохраняемого при остановке (пустой — снимок отключен)
+	CacheSnapshotMaxAge time.Duration // Снимок старше этого возраста не загружается (0 — без ограничения)
+
+	OrderRetention  time.Duration // Срок хранения заказов в основных таблицах, старшие переносятся в архив (0 — архивация отключена)
+	ArchiveInterval time.Duration // Период запуска архивации
+
+	Partitioning       bool          // Секционировать таблицы заказов по месяцам date_created (необязательная миграция)
+	PartitionsAhead    int           // Месяцев вперед, для которых секции заказов создаются заранее (0 — обслуживание секций отключено)
+	PartitionRetention time.Duration // Срок хранения месячных секций заказов, старшие удаляются целиком (0 — не удаляются)
+	PartitionInterval  time.Duration // Период обслуживания секций заказов
+
+	OutboxTopic        string        // Топик событий сохранения заказов (пустой — outbox отключен)
+	OutboxBatchSize    int           // Наибольшее число событий outbox в одном пакете отправки
+	OutboxPollInterval time.Duration // Пауза опроса outbox, когда неотправленных событий нет
+
+	AdminAPIKey      string        // Ключ административного API (пустой — административные маршруты отключены)
+	DLQReplayTimeout time.Duration // Ограничение времени запроса POST /admin/dlq/replay
+
+	HTTPReadTimeout       time.Duration // Таймаут чтения всего запроса
+	HTTPReadHeaderTimeout time.Duration // Таймаут чтения заголовков запроса
+	HTTPWriteTimeout      time.Duration // Таймаут записи ответа
+	HTTPIdleTimeout       time.Duration // Таймаут простоя keep-alive соединения
+	HTTPMaxHeaderBytes    int           // Максимальный размер заголовков запроса в байтах
+
+	ShutdownDrainTimeout time.Duration // Окно между снятием readiness и остановкой сервера
+	ShutdownTimeout      time.Duration // Ограничение времени остановки компонентов
+
+	KafkaReplayFrom time.Time // Время начала повторной обработки топика (cmd/replay)
+	KafkaReplayTo   time.Time // Время окончания повторной обработки (нулевое — до текущего конца топика)
+	KafkaReplayDLQ  bool      // Отправлять ли сообщения с ошибками в DLQ при повторной обработке
+
+	LogFormat          string   // Формат логов: text или json
+	AccessLogSkipPaths []string // Пути, запросы к которым не попадают в access log
+
+	DemoProducerEnabled  bool          // Генерировать тестовые заказы в Kafka
+	DemoProducerInterval time.Duration // Период отправки тестовых заказов
+
+	EnablePprof bool   // Включить эндпоинты /debug/pprof/
+	PprofAddr   string // Адрес внутреннего listener для pprof, например localhost:6060
 }
 
 // LoadFromEnv загружает конфигурацию из переменных окружения
@@ -24,6 +145,7 @@ func LoadFromEnv() (*Config, error) {
 	_ = godotenv.Load()
 
 	cfg := &Config{}
+	var err error
 
 	// HTTP сервер
 	if v := strings.TrimSpace(os.Getenv("SERVER_ADDR")); v != "" {
@@ -39,6 +161,52 @@ func LoadFromEnv() (*Config, error) {
 		cfg.PostgresDSN = "host=localhost port=5433 user=postgres password=postgres dbname=order_db sslmode=disable"
 	}
 
+	// Реплика для чтения (необязательно)
+	cfg.PostgresReadDSN = strings.TrimSpace(os.Getenv("POSTGRES_READ_DSN"))
+
+	// Пул соединений PostgreSQL
+	if cfg.DBMaxConns, err = intFromEnv("DB_MAX_CONNS", 0); err != nil {
+		return nil, err
+	}
+	if cfg.DBMinConns, err = intFromEnv("DB_MIN_CONNS", 0); err != nil {
+		return nil, err
+	}
+	if cfg.DBMaxConnLifetime, err = durationFromEnv("DB_MAX_CONN_LIFETIME", 0); err != nil {
+		return nil, err
+	}
+	if cfg.DBMaxConnIdleTime, err = durationFromEnv("DB_MAX_CONN_IDLE_TIME", 0); err != nil {
+		return nil, err
+	}
+	if cfg.DBHealthCheckPeriod, err = durationFromEnv("DB_HEALTH_CHECK_PERIOD", 0); err != nil {
+		return nil, err
+	}
+	if v := strings.TrimSpace(os.Getenv("DB_QUERY_EXEC_MODE")); v != "" {
+		cfg.DBQueryExecMode = strings.ToLower(v)
+	} else {
+		cfg.DBQueryExecMode = "cache_statement"
+	}
+	cfg.DBTxIsolation = strings.ToLower(strings.TrimSpace(os.Getenv("DB_TX_ISOLATION")))
+	if cfg.DBItemsMultiRow, err = boolFromEnv("DB_ITEMS_MULTIROW", false); err != nil {
+		return nil, err
+	}
+	if cfg.DBItemsPerInsert, err = intFromEnv("DB_ITEMS_PER_INSERT", database.DefaultItemsPerInsert); err != nil {
+		return nil, err
+	}
+
+	// Ограничения времени одной попытки запроса к БД
+	if cfg.DBReadTimeout, err = durationFromEnv("DB_READ_TIMEOUT", 2*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.DBWriteTimeout, err = durationFromEnv("DB_WRITE_TIMEOUT", 5*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.DBGetAllTimeout, err = durationFromEnv("DB_GET_ALL_TIMEOUT", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.DBTracing, err = boolFromEnv("DB_TRACING", false); err != nil {
+		return nil, err
+	}
+
 	// Kafka brokers
 	if v := strings.TrimSpace(os.Getenv("KAFKA_BROKERS")); v != "" {
 		// Разрешаем пробелы после запятой
@@ -61,6 +229,13 @@ func LoadFromEnv() (*Config, error) {
 	} else {
 		cfg.KafkaTopic = "orders"
 	}
+	// Несколько топиков заказов; KAFKA_TOPIC (демо-продюсер, повторная обработка DLQ) по умолчанию — первый из них
+	cfg.KafkaTopics = splitList(os.Getenv("KAFKA_TOPICS"))
+	if len(cfg.KafkaTopics) == 0 {
+		cfg.KafkaTopics = []string{cfg.KafkaTopic}
+	} else if strings.TrimSpace(os.Getenv("KAFKA_TOPIC")) == "" {
+		cfg.KafkaTopic = cfg.KafkaTopics[0]
+	}
 
 	// Kafka group id
 	if v := strings.TrimSpace(os.Getenv("KAFKA_GROUP_ID")); v != "" {
@@ -68,6 +243,136 @@ func LoadFromEnv() (*Config, error) {
 	} else {
 		cfg.KafkaGroupID = "order-service-group"
 	}
+	if cfg.KafkaConsumerConcurrency, err = intFromEnv("KAFKA_CONSUMER_CONCURRENCY", 1); err != nil {
+		return nil, err
+	}
+
+	// Пакетный режим consumer: заказы пакета сохраняются одной транзакцией
+	if cfg.KafkaConsumerBatchSize, err = intFromEnv("KAFKA_CONSUMER_BATCH_SIZE", 0); err != nil {
+		return nil, err
+	}
+	if cfg.KafkaConsumerBatchTimeout, err = durationFromEnv("KAFKA_CONSUMER_BATCH_TIMEOUT", 500*time.Millisecond); err != nil {
+		return nil, err
+	}
+
+	// Формат тела сообщений с заказами: producer кодирует им заказы, consumer — декодирует
+	// сообщения без заголовка content_type
+	if v := strings.TrimSpace(os.Getenv("KAFKA_CODEC")); v != "" {
+		cfg.KafkaCodec = strings.ToLower(v)
+	} else {
+		cfg.KafkaCodec = "json"
+	}
+
+	// Подавление побайтных повторов заказов consumer
+	if cfg.KafkaDedupWindow, err = durationFromEnv("KAFKA_DEDUP_WINDOW", 0); err != nil {
+		return nil, err
+	}
+	if cfg.KafkaDedupMaxEntries, err = intFromEnv("KAFKA_DEDUP_MAX_ENTRIES", 100000); err != nil {
+		return nil, err
+	}
+
+	// Гарантия доставки сообщений consumer
+	if v := strings.TrimSpace(os.Getenv("KAFKA_DELIVERY_MODE")); v != "" {
+		cfg.KafkaDeliveryMode = strings.ToLower(v)
+	} else {
+		cfg.KafkaDeliveryMode = "at_most_once"
+	}
+	if cfg.KafkaDeliveryBackoff, err = durationFromEnv("KAFKA_DELIVERY_BACKOFF", time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.KafkaDeliveryMaxFailures, err = intFromEnv("KAFKA_DELIVERY_MAX_FAILURES", 10); err != nil {
+		return nil, err
+	}
+
+	// Отставание consumer по партициям (kafka_consumer_lag)
+	if cfg.KafkaLagInterval, err = durationFromEnv("KAFKA_LAG_INTERVAL", 15*time.Second); err != nil {
+		return nil, err
+	}
+
+	// Настройки чтения consumer; по умолчанию — как в kafka-go, коммит раз в секунду
+	if cfg.KafkaCommitInterval, err = durationFromEnv("KAFKA_COMMIT_INTERVAL", time.Second); err != nil {
+		return nil, err
+	}
+	if v := strings.TrimSpace(os.Getenv("KAFKA_START_OFFSET")); v != "" {
+		cfg.KafkaStartOffset = strings.ToLower(v)
+	} else {
+		cfg.KafkaStartOffset = "first"
+	}
+	if cfg.KafkaMinBytes, err = intFromEnv("KAFKA_MIN_BYTES", 1); err != nil {
+		return nil, err
+	}
+	if cfg.KafkaMaxBytes, err = intFromEnv("KAFKA_MAX_BYTES", 1000000); err != nil {
+		return nil, err
+	}
+	if cfg.KafkaMaxWait, err = durationFromEnv("KAFKA_MAX_WAIT", 10*time.Second); err != nil {
+		return nil, err
+	}
+
+	// SASL-аутентификация на брокерах
+	cfg.KafkaSASLMechanism = strings.ToUpper(strings.TrimSpace(os.Getenv("KAFKA_SASL_MECHANISM")))
+	cfg.KafkaSASLUsername = strings.TrimSpace(os.Getenv("KAFKA_SASL_USERNAME"))
+	cfg.KafkaSASLPassword = os.Getenv("KAFKA_SASL_PASSWORD")
+
+	// Сжатие сообщений producer
+	if v := strings.TrimSpace(os.Getenv("KAFKA_COMPRESSION")); v != "" {
+		cfg.KafkaCompression = strings.ToLower(v)
+	} else {
+		cfg.KafkaCompression = "snappy"
+	}
+
+	// Настройки записи producer
+	if v := strings.TrimSpace(os.Getenv("KAFKA_REQUIRED_ACKS")); v != "" {
+		cfg.KafkaRequiredAcks = strings.ToLower(v)
+	} else {
+		cfg.KafkaRequiredAcks = "all"
+	}
+	if cfg.KafkaWriteTimeout, err = durationFromEnv("KAFKA_WRITE_TIMEOUT", 10*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.KafkaMaxAttempts, err = intFromEnv("KAFKA_MAX_ATTEMPTS", 3); err != nil {
+		return nil, err
+	}
+	if cfg.KafkaBatchSize, err = intFromEnv("KAFKA_BATCH_SIZE", 100); err != nil {
+		return nil, err
+	}
+	if cfg.KafkaBatchTimeout, err = durationFromEnv("KAFKA_BATCH_TIMEOUT", time.Second); err != nil {
+		return nil, err
+	}
+
+	// TLS-подключение к брокерам
+	if cfg.KafkaTLSEnabled, err = boolFromEnv("KAFKA_TLS_ENABLED", false); err != nil {
+		return nil, err
+	}
+	cfg.KafkaTLSCAFile = strings.TrimSpace(os.Getenv("KAFKA_TLS_CA_FILE"))
+	cfg.KafkaTLSCertFile = strings.TrimSpace(os.Getenv("KAFKA_TLS_CERT_FILE"))
+	cfg.KafkaTLSKeyFile = strings.TrimSpace(os.Getenv("KAFKA_TLS_KEY_FILE"))
+	if cfg.KafkaTLSInsecureSkipVerify, err = boolFromEnv("KAFKA_TLS_INSECURE_SKIP_VERIFY", false); err != nil {
+		return nil, err
+	}
+
+	// Топик отложенных повторов
+	if v := strings.TrimSpace(os.Getenv("KAFKA_RETRY_TOPIC")); v != "" {
+		cfg.KafkaRetryTopic = v
+	} else {
+		cfg.KafkaRetryTopic = cfg.KafkaTopic + "-retry"
+	}
+	if len(cfg.KafkaTopics) > 1 {
+		// У каждого топика свой топик повторов, общий KAFKA_RETRY_TOPIC смешал бы их
+		if strings.TrimSpace(os.Getenv("KAFKA_RETRY_TOPIC")) != "" {
+			return nil, errors.New("KAFKA_RETRY_TOPIC cannot be used with several KAFKA_TOPICS: each topic is retried via <topic>-retry")
+		}
+		for _, topic := range cfg.KafkaTopics {
+			cfg.KafkaRetryTopics = append(cfg.KafkaRetryTopics, topic+"-retry")
+		}
+	} else {
+		cfg.KafkaRetryTopics = []string{cfg.KafkaRetryTopic}
+	}
+	if cfg.KafkaRetryDelays, err = durationsFromEnv("KAFKA_RETRY_DELAYS", []time.Duration{30 * time.Second, 2 * time.Minute, 10 * time.Minute}); err != nil {
+		return nil, err
+	}
+	if cfg.KafkaRetryMaxCycles, err = intFromEnv("KAFKA_RETRY_MAX_CYCLES", 3); err != nil {
+		return nil, err
+	}
 
 	// Static dir
 	if v := strings.TrimSpace(os.Getenv("STATIC_DIR")); v != "" {
@@ -76,6 +381,173 @@ func LoadFromEnv() (*Config, error) {
 		cfg.StaticDir = "./web/static"
 	}
 
+	if cfg.StaticOptional, err = boolFromEnv("STATIC_OPTIONAL", false); err != nil {
+		return nil, err
+	}
+	if cfg.StaticEmbed, err = boolFromEnv("STATIC_EMBED", false); err != nil {
+		return nil, err
+	}
+
+	// Ограничение размера кэша
+	if cfg.CacheMaxEntries, err = intFromEnv("CACHE_MAX_ENTRIES", 0); err != nil {
+		return nil, err
+	}
+	maxBytes, err := intFromEnv("CACHE_MAX_BYTES", 0)
+	if err != nil {
+		return nil, err
+	}
+	cfg.CacheMaxBytes = int64(maxBytes)
+
+	// Скользящий TTL кэша
+	if cfg.CacheSlidingTTL, err = boolFromEnv("CACHE_SLIDING_TTL", false); err != nil {
+		return nil, err
+	}
+	if cfg.CacheMaxLifetime, err = durationFromEnv("CACHE_MAX_LIFETIME", 0); err != nil {
+		return nil, err
+	}
+
+	// Stale-while-revalidate: истекший заказ отдается сразу и обновляется из БД в фоне
+	if cfg.CacheMaxStale, err = durationFromEnv("CACHE_MAX_STALE", 0); err != nil {
+		return nil, err
+	}
+
+	// Фоновая очистка истекших заказов
+	if cfg.CacheCleanupInterval, err = durationFromEnv("CACHE_CLEANUP_INTERVAL", 10*time.Minute); err != nil {
+		return nil, err
+	}
+
+	// Снимок кэша на диске: сохраняется при остановке и загружается вместо прогрева из БД
+	cfg.CacheSnapshotPath = strings.TrimSpace(os.Getenv("CACHE_SNAPSHOT_PATH"))
+	if cfg.CacheSnapshotMaxAge, err = durationFromEnv("CACHE_SNAPSHOT_MAX_AGE", time.Hour); err != nil {
+		return nil, err
+	}
+
+	// Архивация заказов старше срока хранения
+	if cfg.OrderRetention, err = durationFromEnv("ORDER_RETENTION", 0); err != nil {
+		return nil, err
+	}
+	if cfg.ArchiveInterval, err = durationFromEnv("ARCHIVE_INTERVAL", time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.ArchiveInterval == 0 {
+		return nil, errors.New("ARCHIVE_INTERVAL must be positive")
+	}
+
+	// Секционирование таблиц заказов по месяцам и обслуживание секций
+	if cfg.Partitioning, err = boolFromEnv("DB_PARTITIONING", false); err != nil {
+		return nil, err
+	}
+	if cfg.PartitionsAhead, err = intFromEnv("DB_PARTITIONS_AHEAD", database.DefaultPartitionsAhead); err != nil {
+		return nil, err
+	}
+	if cfg.PartitionRetention, err = durationFromEnv("DB_PARTITION_RETENTION", 0); err != nil {
+		return nil, err
+	}
+	if cfg.PartitionInterval, err = durationFromEnv("DB_PARTITION_INTERVAL", database.DefaultPartitionInterval); err != nil {
+		return nil, err
+	}
+	if cfg.PartitionInterval == 0 {
+		return nil, errors.New("DB_PARTITION_INTERVAL must be positive")
+	}
+
+	// События сохранения заказов (transactional outbox)
+	cfg.OutboxTopic = strings.TrimSpace(os.Getenv("OUTBOX_TOPIC"))
+	if cfg.OutboxBatchSize, err = intFromEnv("OUTBOX_BATCH_SIZE", 100); err != nil {
+		return nil, err
+	}
+	if cfg.OutboxBatchSize == 0 {
+		return nil, errors.New("OUTBOX_BATCH_SIZE must be positive")
+	}
+	if cfg.OutboxPollInterval, err = durationFromEnv("OUTBOX_POLL_INTERVAL", time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.OutboxPollInterval == 0 {
+		return nil, errors.New("OUTBOX_POLL_INTERVAL must be positive")
+	}
+
+	// Ключ административного API (секрет из окружения)
+	cfg.AdminAPIKey = strings.TrimSpace(os.Getenv("ADMIN_API_KEY"))
+
+	// Таймауты HTTP сервера
+	if cfg.HTTPReadTimeout, err = durationFromEnv("HTTP_READ_TIMEOUT", 10*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.HTTPReadHeaderTimeout, err = durationFromEnv("HTTP_READ_HEADER_TIMEOUT", 5*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.HTTPWriteTimeout, err = durationFromEnv("HTTP_WRITE_TIMEOUT", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.HTTPIdleTimeout, err = durationFromEnv("HTTP_IDLE_TIMEOUT", 60*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.HTTPMaxHeaderBytes, err = intFromEnv("HTTP_MAX_HEADER_BYTES", 1<<20); err != nil {
+		return nil, err
+	}
+
+	// Graceful shutdown
+	if cfg.ShutdownDrainTimeout, err = durationFromEnv("SHUTDOWN_DRAIN_TIMEOUT", 5*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.ShutdownTimeout, err = durationFromEnv("SHUTDOWN_TIMEOUT", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.ShutdownTimeout == 0 {
+		return nil, errors.New("SHUTDOWN_TIMEOUT must be positive")
+	}
+
+	// Повторная обработка топика по времени
+	if cfg.KafkaReplayFrom, err = timeFromEnv("KAFKA_REPLAY_FROM"); err != nil {
+		return nil, err
+	}
+	if cfg.KafkaReplayTo, err = timeFromEnv("KAFKA_REPLAY_TO"); err != nil {
+		return nil, err
+	}
+	if cfg.KafkaReplayDLQ, err = boolFromEnv("KAFKA_REPLAY_DLQ", false); err != nil {
+		return nil, err
+	}
+
+	// Логирование
+	if v := strings.TrimSpace(os.Getenv("LOG_FORMAT")); v != "" {
+		cfg.LogFormat = strings.ToLower(v)
+	} else {
+		cfg.LogFormat = "text"
+	}
+	if v, ok := os.LookupEnv("ACCESS_LOG_SKIP_PATHS"); ok {
+		cfg.AccessLogSkipPaths = splitList(v)
+	} else {
+		cfg.AccessLogSkipPaths = []string{"/health", "/api/v1/health", "/readyz"}
+	}
+
+	// Повторная обработка DLQ через административное API
+	if cfg.DLQReplayTimeout, err = durationFromEnv("DLQ_REPLAY_TIMEOUT", 60*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.DLQReplayTimeout == 0 {
+		return nil, errors.New("DLQ_REPLAY_TIMEOUT must be positive")
+	}
+
+	// Демо-продюсер тестовых заказов
+	if cfg.DemoProducerEnabled, err = boolFromEnv("DEMO_PRODUCER_ENABLED", true); err != nil {
+		return nil, err
+	}
+	if cfg.DemoProducerInterval, err = durationFromEnv("DEMO_PRODUCER_INTERVAL", 5*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.DemoProducerInterval == 0 {
+		return nil, errors.New("DEMO_PRODUCER_INTERVAL must be positive")
+	}
+
+	// Профилирование (pprof) на отдельном внутреннем адресе
+	if cfg.EnablePprof, err = boolFromEnv("ENABLE_PPROF", false); err != nil {
+		return nil, err
+	}
+	if v := strings.TrimSpace(os.Getenv("PPROF_ADDR")); v != "" {
+		cfg.PprofAddr = v
+	} else {
+		cfg.PprofAddr = "localhost:6060"
+	}
+
 	// Валидация
 	if len(cfg.KafkaBrokers) == 0 {
 		return nil, errors.New("KAFKA_BROKERS must not be empty")
@@ -86,6 +558,242 @@ func LoadFromEnv() (*Config, error) {
 	if strings.TrimSpace(cfg.KafkaGroupID) == "" {
 		return nil, errors.New("KAFKA_GROUP_ID must not be empty")
 	}
+	if cfg.DBMaxConns > math.MaxInt32 || cfg.DBMinConns > math.MaxInt32 {
+		return nil, errors.New("DB_MAX_CONNS and DB_MIN_CONNS must fit in int32")
+	}
+	if cfg.DBMaxConns > 0 && cfg.DBMinConns > cfg.DBMaxConns {
+		return nil, fmt.Errorf("DB_MIN_CONNS (%d) must not exceed DB_MAX_CONNS (%d)", cfg.DBMinConns, cfg.DBMaxConns)
+	}
+	switch cfg.DBQueryExecMode {
+	case "cache_statement", "cache_describe", "describe_exec", "exec", "simple_protocol":
+	default:
+		return nil, fmt.Errorf("DB_QUERY_EXEC_MODE must be cache_statement, cache_describe, describe_exec, exec or simple_protocol, got %q", cfg.DBQueryExecMode)
+	}
+	switch cfg.DBTxIsolation {
+	case "", "read_committed", "repeatable_read", "serializable":
+	default:
+		return nil, fmt.Errorf("DB_TX_ISOLATION must be read_committed, repeatable_read or serializable, got %q", cfg.DBTxIsolation)
+	}
+	if cfg.KafkaRetryMaxCycles > 0 && cfg.KafkaRetryTopic == cfg.KafkaTopic {
+		return nil, errors.New("KAFKA_RETRY_TOPIC must differ from KAFKA_TOPIC")
+	}
+	topics := make(map[string]bool, len(cfg.KafkaTopics))
+	for _, topic := range cfg.KafkaTopics {
+		if topics[topic] {
+			return nil, fmt.Errorf("KAFKA_TOPICS must not contain duplicates, got %q twice", topic)
+		}
+		topics[topic] = true
+	}
+	if !topics[cfg.KafkaTopic] {
+		return nil, fmt.Errorf("KAFKA_TOPIC %q must be one of KAFKA_TOPICS", cfg.KafkaTopic)
+	}
+	for i, topic := range cfg.KafkaTopics {
+		if topics[topic+"-dlq"] {
+			return nil, fmt.Errorf("KAFKA_TOPICS must not contain the DLQ topic %q of %q", topic+"-dlq", topic)
+		}
+		if cfg.KafkaRetryMaxCycles > 0 && topics[cfg.KafkaRetryTopics[i]] {
+			return nil, fmt.Errorf("KAFKA_TOPICS must not contain the retry topic %q of %q", cfg.KafkaRetryTopics[i], topic)
+		}
+	}
+	if cfg.KafkaConsumerConcurrency < 1 {
+		return nil, errors.New("KAFKA_CONSUMER_CONCURRENCY must be at least 1")
+	}
+	if cfg.KafkaConsumerBatchSize < 0 {
+		return nil, errors.New("KAFKA_CONSUMER_BATCH_SIZE must not be negative")
+	}
+	if cfg.KafkaConsumerBatchTimeout == 0 {
+		return nil, errors.New("KAFKA_CONSUMER_BATCH_TIMEOUT must be positive")
+	}
+	if cfg.KafkaConsumerBatchSize > 1 && cfg.KafkaConsumerConcurrency > 1 {
+		return nil, errors.New("KAFKA_CONSUMER_BATCH_SIZE cannot be combined with KAFKA_CONSUMER_CONCURRENCY greater than 1")
+	}
+	switch cfg.KafkaCodec {
+	case "json", "protobuf":
+	default:
+		return nil, fmt.Errorf("KAFKA_CODEC must be json or protobuf, got %q", cfg.KafkaCodec)
+	}
+	if cfg.KafkaDedupMaxEntries < 1 {
+		return nil, errors.New("KAFKA_DEDUP_MAX_ENTRIES must be at least 1")
+	}
+	switch cfg.KafkaDeliveryMode {
+	case "at_most_once", "at_least_once":
+	default:
+		return nil, fmt.Errorf("KAFKA_DELIVERY_MODE must be at_most_once or at_least_once, got %q", cfg.KafkaDeliveryMode)
+	}
+	if cfg.KafkaDeliveryBackoff == 0 {
+		return nil, errors.New("KAFKA_DELIVERY_BACKOFF must be positive")
+	}
+	if cfg.KafkaDeliveryMaxFailures < 0 {
+		return nil, errors.New("KAFKA_DELIVERY_MAX_FAILURES must not be negative")
+	}
+	switch cfg.KafkaStartOffset {
+	case "first", "last":
+	default:
+		return nil, fmt.Errorf("KAFKA_START_OFFSET must be first or last, got %q", cfg.KafkaStartOffset)
+	}
+	if cfg.KafkaMinBytes < 1 {
+		return nil, errors.New("KAFKA_MIN_BYTES must be at least 1")
+	}
+	if cfg.KafkaMaxBytes < cfg.KafkaMinBytes {
+		return nil, errors.New("KAFKA_MAX_BYTES must not be less than KAFKA_MIN_BYTES")
+	}
+	if cfg.KafkaMaxWait == 0 {
+		return nil, errors.New("KAFKA_MAX_WAIT must be positive")
+	}
+	switch cfg.KafkaSASLMechanism {
+	case "":
+	case "PLAIN", "SCRAM-SHA-256", "SCRAM-SHA-512":
+		if cfg.KafkaSASLUsername == "" || cfg.KafkaSASLPassword == "" {
+			return nil, fmt.Errorf("KAFKA_SASL_USERNAME and KAFKA_SASL_PASSWORD are required for KAFKA_SASL_MECHANISM %s", cfg.KafkaSASLMechanism)
+		}
+	default:
+		return nil, fmt.Errorf("KAFKA_SASL_MECHANISM must be PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512, got %q", cfg.KafkaSASLMechanism)
+	}
+	switch cfg.KafkaCompression {
+	case "none", "gzip", "snappy", "lz4", "zstd":
+	default:
+		return nil, fmt.Errorf("KAFKA_COMPRESSION must be none, gzip, snappy, lz4 or zstd, got %q", cfg.KafkaCompression)
+	}
+	switch cfg.KafkaRequiredAcks {
+	case "all", "one", "none":
+	default:
+		return nil, fmt.Errorf("KAFKA_REQUIRED_ACKS must be all, one or none, got %q", cfg.KafkaRequiredAcks)
+	}
+	if cfg.KafkaWriteTimeout == 0 {
+		return nil, errors.New("KAFKA_WRITE_TIMEOUT must be positive")
+	}
+	if cfg.KafkaMaxAttempts < 1 {
+		return nil, errors.New("KAFKA_MAX_ATTEMPTS must be at least 1")
+	}
+	if cfg.KafkaBatchSize < 1 {
+		return nil, errors.New("KAFKA_BATCH_SIZE must be at least 1")
+	}
+	if cfg.KafkaBatchTimeout == 0 {
+		return nil, errors.New("KAFKA_BATCH_TIMEOUT must be positive")
+	}
+	if (cfg.KafkaTLSCertFile == "") != (cfg.KafkaTLSKeyFile == "") {
+		return nil, errors.New("KAFKA_TLS_CERT_FILE and KAFKA_TLS_KEY_FILE must be set together")
+	}
+	if !cfg.KafkaTLSEnabled && (cfg.KafkaTLSCAFile != "" || cfg.KafkaTLSCertFile != "" || cfg.KafkaTLSInsecureSkipVerify) {
+		return nil, errors.New("KAFKA_TLS_CA_FILE, KAFKA_TLS_CERT_FILE and KAFKA_TLS_INSECURE_SKIP_VERIFY require KAFKA_TLS_ENABLED")
+	}
+	if cfg.DBItemsPerInsert < 1 || cfg.DBItemsPerInsert > database.MaxItemsPerInsert {
+		return nil, fmt.Errorf("DB_ITEMS_PER_INSERT must be between 1 and %d, got %d", database.MaxItemsPerInsert, cfg.DBItemsPerInsert)
+	}
+	if cfg.LogFormat != "text" && cfg.LogFormat != "json" {
+		return nil, fmt.Errorf("LOG_FORMAT must be text or json, got %q", cfg.LogFormat)
+	}
+	if cfg.EnablePprof && cfg.PprofAddr == cfg.ServerAddr {
+		return nil, errors.New("PPROF_ADDR must differ from SERVER_ADDR")
+	}
+	if !cfg.KafkaReplayTo.IsZero() && !cfg.KafkaReplayTo.After(cfg.KafkaReplayFrom) {
+		return nil, errors.New("KAFKA_REPLAY_TO must be after KAFKA_REPLAY_FROM")
+	}
 
 	return cfg, nil
 }
+
+// String описывает конфигурацию для логов: пароли строк подключения и ключ
+// административного API заменены на ***
+func (c Config) String() string {
+	// plain — Config без метода String, чтобы %+v не вызвал его рекурсивно
+	type plain Config
+	redacted := plain(c)
+	redacted.PostgresDSN = database.RedactDSN(c.PostgresDSN)
+	redacted.PostgresReadDSN = database.RedactDSN(c.PostgresReadDSN)
+	if redacted.AdminAPIKey != "" {
+		redacted.AdminAPIKey = "***"
+	}
+	return fmt.Sprintf("%+v", redacted)
+}
+
+// durationFromEnv читает длительность (например, 30s) из переменной окружения
+func durationFromEnv(key string, def time.Duration) (time.Duration, error) {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s: invalid duration %q: %w", key, v, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("%s must not be negative", key)
+	}
+	return d, nil
+}
+
+// durationsFromEnv читает список положительных длительностей через запятую (например, 30s,2m)
+func durationsFromEnv(key string, def []time.Duration) ([]time.Duration, error) {
+	items := splitList(os.Getenv(key))
+	if len(items) == 0 {
+		return def, nil
+	}
+	durations := make([]time.Duration, 0, len(items))
+	for _, item := range items {
+		d, err := time.ParseDuration(item)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid duration %q: %w", key, item, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("%s: durations must be positive, got %q", key, item)
+		}
+		durations = append(durations, d)
+	}
+	return durations, nil
+}
+
+// intFromEnv читает неотрицательное целое число из переменной окружения
+func intFromEnv(key string, def int) (int, error) {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s: invalid integer %q: %w", key, v, err)
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("%s must not be negative", key)
+	}
+	return n, nil
+}
+
+// timeFromEnv читает время в формате RFC3339 из переменной окружения
+func timeFromEnv(key string) (time.Time, error) {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s: invalid RFC3339 time %q: %w", key, v, err)
+	}
+	return t, nil
+}
+
+// boolFromEnv читает логический флаг из переменной окружения
+func boolFromEnv(key string, def bool) (bool, error) {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%s: invalid boolean %q: %w", key, v, err)
+	}
+	return b, nil
+}
+
+// splitList разбивает строку по запятым, отбрасывая пробелы и пустые элементы
+func splitList(v string) []string {
+	parts := strings.Split(v, ",")
+	items := make([]string, 0, len(parts))
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			items = append(items, p)
+		}
+	}
+	return items
+}
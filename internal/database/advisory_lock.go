@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AdvisoryLock сессионная advisory-блокировка PostgreSQL.
+// Блокировка живет, пока удерживается соединение пула, на котором она взята.
+type AdvisoryLock struct {
+	conn *pgxpool.Conn // Выделенное соединение, удерживающее блокировку
+	key  int64         // Ключ блокировки
+}
+
+// TryAdvisoryLock пытается без ожидания взять сессионную advisory-блокировку с заданным ключом.
+// Если блокировка занята другим процессом, возвращает (nil, false, nil).
+func (p *Postgres) TryAdvisoryLock(ctx context.Context, key int64) (*AdvisoryLock, bool, error) {
+	conn, err := p.pool.Acquire(ctx)
+	if err != nil {
+		p.metrics.ConnectionErrorsTotal.Inc()
+		return nil, false, fmt.Errorf("Ошибка получения соединения для advisory-блокировки: %v", err)
+	}
+
+	var acquired bool
+	if err := conn.QueryRow(ctx, TryAdvisoryLockQuery, key).Scan(&acquired); err != nil {
+		conn.Release()
+		p.metrics.QueryErrorsTotal.Inc()
+		p.metrics.QueryErrors.WithLabelValues("try_advisory_lock").Inc()
+		return nil, false, fmt.Errorf("Ошибка захвата advisory-блокировки: %v", err)
+	}
+	if !acquired {
+		conn.Release()
+		return nil, false, nil
+	}
+
+	return &AdvisoryLock{conn: conn, key: key}, true, nil
+}
+
+// Ping проверяет, что соединение с блокировкой живо (иначе блокировка потеряна)
+func (l *AdvisoryLock) Ping(ctx context.Context) error {
+	return l.conn.Ping(ctx)
+}
+
+// Unlock освобождает блокировку и возвращает соединение в пул
+func (l *AdvisoryLock) Unlock(ctx context.Context) error {
+	defer l.conn.Release()
+
+	if _, err := l.conn.Exec(ctx, AdvisoryUnlockQuery, l.key); err != nil {
+		// Закрываем соединение: вместе с сессией PostgreSQL снимет и блокировку
+		_ = l.conn.Conn().Close(ctx)
+		return fmt.Errorf("Ошибка освобождения advisory-блокировки: %v", err)
+	}
+	return nil
+}
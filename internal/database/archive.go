@@ -0,0 +1,116 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"test_service/internal/retry"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// DefaultArchiveBatchSize размер пакета ArchiveOrdersBefore при batchSize <= 0
+const DefaultArchiveBatchSize = 500
+
+// archiveBatch пакет запросов переноса заказов uids в архив; moved получает число
+// удаленных из основных таблиц заказов
+func archiveBatch(uids []string, moved *int64) *writeBatch {
+	b := &writeBatch{label: "archive_batch"}
+	b.queue(batchStep{label: "archive_orders", errMsg: "Ошибка копирования заказов в архив", result: execResult},
+		ArchiveOrdersQuery, uids)
+	b.queue(batchStep{label: "archive_delivery", errMsg: "Ошибка копирования доставки в архив", result: execResult},
+		ArchiveDeliveryQuery, uids)
+	b.queue(batchStep{label: "archive_payment", errMsg: "Ошибка копирования платежей в архив", result: execResult},
+		ArchivePaymentQuery, uids)
+	b.queue(batchStep{label: "archive_items", errMsg: "Ошибка копирования товаров в архив", result: execResult},
+		ArchiveItemsQuery, uids)
+	b.queue(batchStep{label: "delete_archived_orders", errMsg: "Ошибка удаления архивированных заказов", result: func(results pgx.BatchResults) error {
+		tag, err := results.Exec()
+		*moved = tag.RowsAffected()
+		return err
+	}}, DeleteArchivedOrdersQuery, uids)
+	return b
+}
+
+// ArchiveOrdersBefore переносит заказы, созданные раньше cutoff, вместе с доставкой, платежом
+// и товарами в архивные таблицы (orders_archive, delivery_archive, payment_archive, items_archive)
+// и удаляет их из основных. Заказы переносятся пакетами по batchSize (<= 0 — DefaultArchiveBatchSize),
+// каждый пакет в своей транзакции, поэтому блокировки держатся только на время пакета.
+// Возвращает число перенесенных заказов, в том числе при ошибке на одном из пакетов:
+// уже зафиксированные пакеты остаются в архиве.
+func (p *Postgres) ArchiveOrdersBefore(ctx context.Context, cutoff time.Time, batchSize int) (int64, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultArchiveBatchSize
+	}
+	startTime := time.Now()
+	defer func() {
+		p.metrics.ArchiveDuration.Observe(time.Since(startTime).Seconds())
+	}()
+
+	var total int64
+	for {
+		var moved int64
+		err := retry.DoWithContext(ctx, retry.DefaultPolicy(), func(ctx context.Context) error {
+			var err error
+			moved, err = p.archiveBatchAttempt(ctx, cutoff, batchSize)
+			return retryTransient(err)
+		})
+		if err != nil {
+			return total, classify(err)
+		}
+		total += moved
+		p.metrics.ArchivedOrdersTotal.Add(float64(moved))
+
+		// Неполный пакет: заказов старше cutoff больше нет (кроме заблокированных сейчас)
+		if moved < int64(batchSize) {
+			if total > 0 {
+				log.Printf("Архивировано заказов, созданных до %s: %d", cutoff.Format(time.RFC3339), total)
+			}
+			return total, nil
+		}
+	}
+}
+
+// archiveBatchAttempt одна попытка переноса пакета: выбор UID и перенос в одной транзакции
+func (p *Postgres) archiveBatchAttempt(ctx context.Context, cutoff time.Time, batchSize int) (int64, error) {
+	ctx, cancel := p.writeContext(ctx)
+	defer cancel()
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		p.metrics.TransactionErrorsTotal.Inc()
+		return 0, fmt.Errorf("Ошибка начала транзакции архивации: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	queryStartTime := time.Now()
+	rows, err := tx.Query(ctx, SelectArchiveBatchQuery, cutoff, batchSize)
+	if err != nil {
+		p.metrics.QueryErrorsTotal.Inc()
+		p.metrics.QueryErrors.WithLabelValues("select_archive_batch").Inc()
+		return 0, fmt.Errorf("Ошибка выбора заказов для архивации: %w", err)
+	}
+	uids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		p.metrics.QueryErrorsTotal.Inc()
+		p.metrics.QueryErrors.WithLabelValues("select_archive_batch").Inc()
+		return 0, fmt.Errorf("Ошибка чтения заказов для архивации: %w", err)
+	}
+	p.metrics.QueryDuration.WithLabelValues("select_archive_batch").Observe(time.Since(queryStartTime).Seconds())
+	if len(uids) == 0 {
+		return 0, nil
+	}
+
+	var moved int64
+	if err := p.sendBatch(ctx, tx, archiveBatch(uids, &moved)); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		p.metrics.TransactionErrorsTotal.Inc()
+		return 0, fmt.Errorf("Ошибка коммита транзакции архивации: %w", err)
+	}
+	return moved, nil
+}
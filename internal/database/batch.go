@@ -0,0 +1,190 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"test_service/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// batchStep запрос пакета: метка метрик, текст ошибки и чтение результата
+type batchStep struct {
+	label  string
+	errMsg string
+	result func(pgx.BatchResults) error
+}
+
+// execResult читает результат запроса без возвращаемых строк
+func execResult(results pgx.BatchResults) error {
+	_, err := results.Exec()
+	return err
+}
+
+// writeBatch запросы пакета в порядке отправки
+type writeBatch struct {
+	label string // Метка метрик пакета целиком
+	batch pgx.Batch
+	steps []batchStep
+}
+
+// queue добавляет запрос в пакет
+func (b *writeBatch) queue(step batchStep, query string, args ...any) {
+	b.batch.Queue(query, args...)
+	b.steps = append(b.steps, step)
+}
+
+// savedOrder значения, которые БД возвращает при сохранении заказа
+type savedOrder struct {
+	updatedAt time.Time
+	deletedAt *time.Time         // Отметка мягкого удаления; UPSERT ее не снимает
+	status    models.OrderStatus // Статус заказа; UPSERT его не меняет
+}
+
+// orderLockClass пространство advisory-блокировок заказов по UID (LockOrderQuery)
+const orderLockClass int32 = 0x6f726472 // "ordr"
+
+// saveOptions параметры сохранения заказов
+type saveOptions struct {
+	outbox         bool // Записывать событие в таблицу outbox (SetOutbox)
+	multiRowItems  bool // Товары многострочными INSERT вместо массивов колонок (SetMultiRowItems)
+	itemsPerInsert int  // Товаров в одном многострочном INSERT (0 — DefaultItemsPerInsert)
+	partitioned    bool // Таблицы секционированы по date_created: запросы Save*Partitioned* (Init)
+}
+
+// saveOrderBatch пакет запросов сохранения заказа; saved получает значения, возвращенные БД
+func saveOrderBatch(order *models.Order, saved *savedOrder, opts saveOptions) *writeBatch {
+	b := &writeBatch{label: "save_order_batch"}
+	b.queueSaveOrder(order, saved, opts)
+	return b
+}
+
+// queueSaveOrder добавляет в пакет запросы сохранения заказа.
+// Товары с известным chrt_id обновляются на месте (id и порядок сохраняются, новые товары
+// добавляются в конец), товары, которых больше нет в заказе, удаляются. Из товаров
+// с повторяющимся chrt_id сохраняется последний (uniqueItems).
+// В секционированных таблицах сохранение сначала берет блокировку заказа (LockOrderQuery),
+// а дочерние таблицы получают date_created заказа последним параметром.
+func (b *writeBatch) queueSaveOrder(order *models.Order, saved *savedOrder, opts saveOptions) {
+	items := uniqueItems(order.Items)
+
+	saveOrder, saveDelivery, savePayment, upsertItems := SaveOrderQuery, SaveDeliveryQuery, SavePaymentQuery, UpsertItemsQuery
+	var partitionKey []any // Параметр date_created дочерних таблиц
+	if opts.partitioned {
+		saveOrder, saveDelivery, savePayment, upsertItems = SavePartitionedOrderQuery, SavePartitionedDeliveryQuery,
+			SavePartitionedPaymentQuery, UpsertPartitionedItemsQuery
+		partitionKey = []any{order.DateCreated}
+
+		b.queue(batchStep{label: "lock_order", errMsg: "Ошибка блокировки заказа", result: execResult},
+			LockOrderQuery, orderLockClass, order.OrderUID)
+	}
+
+	b.queue(batchStep{label: "save_order", errMsg: "Ошибка при записи заказа", result: func(results pgx.BatchResults) error {
+		return results.QueryRow().Scan(&saved.updatedAt, &saved.deletedAt, &saved.status)
+	}}, saveOrder, order.OrderUID, order.TrackNumber, order.Entry, order.Locale, order.InternalSignature,
+		order.CustomerID, order.DeliveryService, order.ShardKey, order.SMID, order.DateCreated, order.OOFShard)
+
+	b.queue(batchStep{label: "save_delivery", errMsg: "Ошибка при записи доставки", result: execResult},
+		saveDelivery, append([]any{order.OrderUID, order.Delivery.Name, order.Delivery.Phone, order.Delivery.Zip,
+			order.Delivery.City, order.Delivery.Address, order.Delivery.Region, order.Delivery.Email}, partitionKey...)...)
+
+	b.queue(batchStep{label: "save_payment", errMsg: "Ошибка при записи payment", result: execResult},
+		savePayment, append([]any{order.OrderUID, order.Payment.Transaction, order.Payment.RequestID, order.Payment.Currency,
+			order.Payment.Provider, order.Payment.Amount, order.Payment.PaymentDT, order.Payment.Bank,
+			order.Payment.DeliveryCost, order.Payment.GoodsTotal, order.Payment.CustomFee}, partitionKey...)...)
+
+	if opts.multiRowItems {
+		inserts := buildItemsInsert(order.OrderUID, items, opts.itemsPerInsert)
+		if opts.partitioned {
+			inserts = buildPartitionedItemsInsert(order.OrderUID, order.DateCreated, items, opts.itemsPerInsert)
+		}
+		for _, insert := range inserts {
+			b.queue(batchStep{label: "upsert_item", errMsg: "Ошибка сохранения позиций", result: execResult},
+				insert.sql, insert.args...)
+		}
+	}
+
+	// Значения колонок массивами: один запрос на все товары заказа
+	n := len(items)
+	var (
+		chrtIDs      = make([]int, n)
+		trackNumbers = make([]string, n)
+		prices       = make([]int, n)
+		rids         = make([]string, n)
+		names        = make([]string, n)
+		sales        = make([]int, n)
+		sizes        = make([]string, n)
+		totalPrices  = make([]int, n)
+		nmIDs        = make([]int, n)
+		brands       = make([]string, n)
+		statuses     = make([]int, n)
+	)
+	for i, item := range items {
+		chrtIDs[i] = item.ChrtID
+		trackNumbers[i] = item.TrackNumber
+		prices[i] = item.Price
+		rids[i] = item.RID
+		names[i] = item.Name
+		sales[i] = item.Sale
+		sizes[i] = item.Size
+		totalPrices[i] = item.TotalPrice
+		nmIDs[i] = item.NMID
+		brands[i] = item.Brand
+		statuses[i] = item.Status
+	}
+	if n > 0 && !opts.multiRowItems {
+		b.queue(batchStep{label: "upsert_item", errMsg: "Ошибка сохранения позиций", result: execResult},
+			upsertItems, append([]any{order.OrderUID, chrtIDs, trackNumbers, prices, rids, names,
+				sales, sizes, totalPrices, nmIDs, brands, statuses}, partitionKey...)...)
+	}
+	b.queue(batchStep{label: "delete_stale_items", errMsg: "Ошибка удаления исключенных позиций", result: execResult},
+		DeleteStaleItemsQuery, order.OrderUID, chrtIDs)
+
+	if opts.outbox {
+		// Событие фиксируется вместе с заказом: публикатор не потеряет его при сбое после коммита
+		payload, _ := json.Marshal(order)
+		b.queue(batchStep{label: "insert_outbox", errMsg: "Ошибка записи события в outbox", result: execResult},
+			InsertOutboxQuery, order.OrderUID, payload)
+	}
+}
+
+// sendBatch отправляет пакет в транзакции tx и читает результаты по порядку.
+// Ошибка учитывается в метриках с меткой запроса, на котором пакет остановился;
+// время выполнения учитывается для пакета целиком (метка пакета, например save_order_batch).
+func (p *Postgres) sendBatch(ctx context.Context, tx pgx.Tx, b *writeBatch) error {
+	queryStartTime := time.Now()
+	results := tx.SendBatch(ctx, &b.batch)
+	for _, step := range b.steps {
+		if err := step.result(results); err != nil {
+			_ = results.Close()
+			p.metrics.QueryErrorsTotal.Inc()
+			p.metrics.QueryErrors.WithLabelValues(step.label).Inc()
+			return fmt.Errorf("%s: %w", step.errMsg, err)
+		}
+	}
+	if err := results.Close(); err != nil {
+		p.metrics.QueryErrorsTotal.Inc()
+		p.metrics.QueryErrors.WithLabelValues(b.label).Inc()
+		return fmt.Errorf("Ошибка завершения пакета запросов: %w", err)
+	}
+	p.metrics.QueryDuration.WithLabelValues(b.label).Observe(time.Since(queryStartTime).Seconds())
+	return nil
+}
+
+// saveOrdersBatch пакет запросов сохранения нескольких заказов; saved[i] получает значения,
+// возвращенные БД для orders[i]. Запросы заказов идут в порядке orders, поэтому из повторов
+// одного order_uid остается последний. Сообщения об ошибках указывают заказ.
+func saveOrdersBatch(orders []*models.Order, saved []savedOrder, opts saveOptions) *writeBatch {
+	b := &writeBatch{label: "save_orders_batch"}
+	for i, order := range orders {
+		first := len(b.steps)
+		b.queueSaveOrder(order, &saved[i], opts)
+		for j := first; j < len(b.steps); j++ {
+			b.steps[j].errMsg = fmt.Sprintf("%s (заказ %s)", b.steps[j].errMsg, order.OrderUID)
+		}
+	}
+	return b
+}
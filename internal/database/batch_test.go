@@ -0,0 +1,175 @@
+package database
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"test_service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// batchLabels возвращает метки запросов пакета в порядке отправки
+func batchLabels(b *writeBatch) []string {
+	labels := make([]string, 0, len(b.steps))
+	for _, step := range b.steps {
+		labels = append(labels, step.label)
+	}
+	return labels
+}
+
+func TestSaveOrderBatch(t *testing.T) {
+	var saved savedOrder
+	order := &models.Order{OrderUID: "batch", Items: []models.Item{{ChrtID: 1, Name: "a"}, {ChrtID: 2, Name: "b"}}}
+
+	b := saveOrderBatch(order, &saved, saveOptions{})
+	assert.Equal(t, []string{"save_order", "save_delivery", "save_payment", "upsert_item", "delete_stale_items"}, batchLabels(b))
+	require.Equal(t, len(b.steps), b.batch.Len(), "каждому запросу пакета соответствует шаг чтения результата")
+
+	queued := b.batch.QueuedQueries
+	assert.Equal(t, SaveOrderQuery, queued[0].SQL)
+	assert.Equal(t, UpsertItemsQuery, queued[3].SQL)
+	assert.Equal(t, []int{1, 2}, queued[3].Arguments[1], "chrt_id товаров в порядке заказа")
+	assert.Equal(t, []string{"a", "b"}, queued[3].Arguments[5])
+	assert.Equal(t, []any{"batch", []int{1, 2}}, queued[4].Arguments)
+}
+
+func TestSaveOrderBatch_NoItems(t *testing.T) {
+	var saved savedOrder
+	b := saveOrderBatch(&models.Order{OrderUID: "empty"}, &saved, saveOptions{})
+
+	// Без товаров UPSERT не отправляется, а удаление убирает все товары заказа
+	assert.Equal(t, []string{"save_order", "save_delivery", "save_payment", "delete_stale_items"}, batchLabels(b))
+	assert.Equal(t, []any{"empty", []int{}}, b.batch.QueuedQueries[3].Arguments)
+}
+
+func TestSaveOrderBatch_MultiRowItems(t *testing.T) {
+	var saved savedOrder
+	order := &models.Order{OrderUID: "multirow", Items: testItems(3)}
+	b := saveOrderBatch(order, &saved, saveOptions{multiRowItems: true, itemsPerInsert: 2})
+
+	// Товары — многострочными INSERT по два, удаление исключенных — как обычно
+	assert.Equal(t, []string{"save_order", "save_delivery", "save_payment", "upsert_item", "upsert_item", "delete_stale_items"}, batchLabels(b))
+	require.Equal(t, len(b.steps), b.batch.Len())
+	queued := b.batch.QueuedQueries
+	assert.Len(t, queued[3].Arguments, 2*itemColumns)
+	assert.Len(t, queued[4].Arguments, itemColumns)
+	assert.NotEqual(t, UpsertItemsQuery, queued[3].SQL)
+	assert.Equal(t, []any{"multirow", []int{1, 2, 3}}, queued[5].Arguments)
+}
+
+func TestSaveOrderBatch_DuplicateChrtID(t *testing.T) {
+	order := &models.Order{OrderUID: "dup", Items: []models.Item{
+		{ChrtID: 1, Name: "old"}, {ChrtID: 2, Name: "b"}, {ChrtID: 1, Name: "new"},
+	}}
+
+	// В обоих режимах один UPSERT не затрагивает строку дважды: остается последний товар с chrt_id
+	for name, opts := range map[string]saveOptions{"Unnest": {}, "MultiRow": {multiRowItems: true}} {
+		t.Run(name, func(t *testing.T) {
+			var saved savedOrder
+			queued := saveOrderBatch(order, &saved, opts).batch.QueuedQueries
+			if opts.multiRowItems {
+				require.Len(t, queued[3].Arguments, 2*itemColumns)
+				assert.Equal(t, []any{2, "b"}, []any{queued[3].Arguments[1], queued[3].Arguments[5]})
+				assert.Equal(t, []any{1, "new"}, []any{queued[3].Arguments[itemColumns+1], queued[3].Arguments[itemColumns+5]})
+			} else {
+				assert.Equal(t, []int{2, 1}, queued[3].Arguments[1])
+				assert.Equal(t, []string{"b", "new"}, queued[3].Arguments[5])
+			}
+			assert.Equal(t, []any{"dup", []int{2, 1}}, queued[4].Arguments)
+		})
+	}
+	assert.Len(t, order.Items, 3, "заказ вызывающего не меняется")
+}
+
+func TestSaveOrderBatch_Partitioned(t *testing.T) {
+	var saved savedOrder
+	created := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	order := &models.Order{OrderUID: "batch", DateCreated: created, Items: []models.Item{{ChrtID: 1, Name: "a"}, {ChrtID: 2, Name: "b"}}}
+
+	b := saveOrderBatch(order, &saved, saveOptions{partitioned: true})
+	assert.Equal(t, []string{"lock_order", "save_order", "save_delivery", "save_payment", "upsert_item", "delete_stale_items"}, batchLabels(b))
+	require.Equal(t, len(b.steps), b.batch.Len())
+
+	queued := b.batch.QueuedQueries
+	assert.Equal(t, LockOrderQuery, queued[0].SQL)
+	assert.Equal(t, []any{orderLockClass, "batch"}, queued[0].Arguments)
+	assert.Equal(t, []string{SavePartitionedOrderQuery, SavePartitionedDeliveryQuery, SavePartitionedPaymentQuery, UpsertPartitionedItemsQuery},
+		[]string{queued[1].SQL, queued[2].SQL, queued[3].SQL, queued[4].SQL})
+	assert.Equal(t, []int{1, 2}, queued[4].Arguments[1])
+	assert.Equal(t, []any{"batch", []int{1, 2}}, queued[5].Arguments)
+
+	// Доставка, платеж и товары записываются в секцию даты создания заказа
+	assert.Len(t, queued[2].Arguments, 9)
+	assert.Len(t, queued[3].Arguments, 12)
+	assert.Len(t, queued[4].Arguments, 13)
+	for _, q := range queued[2:5] {
+		assert.Equal(t, created, q.Arguments[len(q.Arguments)-1], q.SQL)
+	}
+
+	// Многострочные INSERT товаров тоже получают date_created
+	b = saveOrderBatch(order, &saved, saveOptions{partitioned: true, multiRowItems: true})
+	queued = b.batch.QueuedQueries
+	require.Len(t, queued[4].Arguments, 2*partitionedItemColumns)
+	assert.True(t, strings.HasPrefix(queued[4].SQL, insertPartitionedItemsPrefix))
+	assert.Equal(t, created, queued[4].Arguments[partitionedItemColumns-1])
+}
+
+func TestSaveOrderBatch_Outbox(t *testing.T) {
+	var saved savedOrder
+	order := &models.Order{OrderUID: "outbox", Items: []models.Item{{ChrtID: 1}}}
+	b := saveOrderBatch(order, &saved, saveOptions{outbox: true})
+
+	// Событие пишется последним запросом той же транзакции
+	labels := batchLabels(b)
+	assert.Equal(t, "insert_outbox", labels[len(labels)-1])
+	require.Equal(t, len(b.steps), b.batch.Len())
+	queued := b.batch.QueuedQueries[len(labels)-1]
+	assert.Equal(t, InsertOutboxQuery, queued.SQL)
+	assert.Equal(t, "outbox", queued.Arguments[0])
+
+	var payload models.Order
+	require.NoError(t, json.Unmarshal(queued.Arguments[1].([]byte), &payload))
+	assert.Equal(t, "outbox", payload.OrderUID)
+}
+
+func TestSaveOrdersBatch(t *testing.T) {
+	orders := []*models.Order{
+		{OrderUID: "first", Items: []models.Item{{ChrtID: 1}}},
+		{OrderUID: "second"},
+	}
+	saved := make([]savedOrder, len(orders))
+	b := saveOrdersBatch(orders, saved, saveOptions{})
+
+	// Запросы заказов идут подряд в порядке orders
+	assert.Equal(t, "save_orders_batch", b.label)
+	assert.Equal(t, []string{
+		"save_order", "save_delivery", "save_payment", "upsert_item", "delete_stale_items",
+		"save_order", "save_delivery", "save_payment", "delete_stale_items",
+	}, batchLabels(b))
+	require.Equal(t, len(b.steps), b.batch.Len())
+	assert.Equal(t, "first", b.batch.QueuedQueries[0].Arguments[0])
+	assert.Equal(t, "second", b.batch.QueuedQueries[5].Arguments[0])
+
+	// Ошибка шага указывает заказ
+	assert.Contains(t, b.steps[2].errMsg, "(заказ first)")
+	assert.Contains(t, b.steps[7].errMsg, "(заказ second)")
+}
+
+func TestArchiveBatch(t *testing.T) {
+	var moved int64
+	uids := []string{"a", "b"}
+	b := archiveBatch(uids, &moved)
+
+	// Копии в архив отправляются до удаления, которое каскадно удаляет дочерние записи
+	assert.Equal(t, []string{"archive_orders", "archive_delivery", "archive_payment", "archive_items", "delete_archived_orders"}, batchLabels(b))
+	assert.Equal(t, "archive_batch", b.label)
+	require.Equal(t, len(b.steps), b.batch.Len())
+	for _, queued := range b.batch.QueuedQueries {
+		assert.Equal(t, []any{uids}, queued.Arguments)
+	}
+	assert.Equal(t, DeleteArchivedOrdersQuery, b.batch.QueuedQueries[4].SQL)
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"test_service/internal/interfaces"
+	"test_service/internal/models"
+	"test_service/internal/retry"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrNoContact не указан ни email, ни телефон для FindOrdersByContact
+var ErrNoContact = errors.New("требуется email или телефон покупателя")
+
+// FindOrdersByContact возвращает заказы с товарами, в доставке которых указан email или телефон
+// покупателя, от новых к старым. Email сравнивается без учета регистра, телефон — без оформления
+// (models.NormalizeEmail, models.NormalizePhone); пустой после нормализации контакт не участвует
+// в поиске, а если пусты оба — возвращается ErrNoContact.
+// limit <= 0 заменяется на DefaultPageSize, limit больше MaxPageSize — на MaxPageSize.
+func (p *Postgres) FindOrdersByContact(ctx context.Context, email, phone string, limit int, opts ...interfaces.ReadOption) ([]models.Order, error) {
+	options := interfaces.ApplyReadOptions(opts)
+	switch {
+	case limit <= 0:
+		limit = DefaultPageSize
+	case limit > MaxPageSize:
+		limit = MaxPageSize
+	}
+
+	// Незаданный контакт передается как NULL: сравнение с ним ничего не находит
+	var emailArg, phoneArg *string
+	if v := models.NormalizeEmail(email); v != "" {
+		emailArg = &v
+	}
+	if v := models.NormalizePhone(phone); v != "" {
+		phoneArg = &v
+	}
+	if emailArg == nil && phoneArg == nil {
+		return nil, ErrNoContact
+	}
+
+	var orders []models.Order
+
+	err := retry.DoWithContext(ctx, retry.DefaultPolicy(), p.readingFrom(options, "find_orders_by_contact", func(ctx context.Context, db *pgxpool.Pool) error {
+		ctx, cancel := p.readContext(ctx)
+		defer cancel()
+
+		queryStartTime := time.Now()
+		rows, err := db.Query(ctx, FindOrdersByContactQuery, emailArg, phoneArg, limit, options.IncludeDeleted)
+		if err != nil {
+			p.metrics.QueryErrorsTotal.Inc()
+			p.metrics.QueryErrors.WithLabelValues("find_orders_by_contact").Inc()
+			return fmt.Errorf("Ошибка при поиске заказов по контактам: %w", err)
+		}
+		defer rows.Close()
+
+		orders = make([]models.Order, 0)
+		for rows.Next() {
+			var order models.Order
+			if err := scanOrder(rows, &order); err != nil {
+				p.metrics.QueryErrorsTotal.Inc()
+				p.metrics.QueryErrors.WithLabelValues("find_orders_by_contact").Inc()
+				return fmt.Errorf("Ошибка при чтении заказа: %w", err)
+			}
+			orders = append(orders, order)
+		}
+		if err := rows.Err(); err != nil {
+			p.metrics.QueryErrorsTotal.Inc()
+			p.metrics.QueryErrors.WithLabelValues("find_orders_by_contact").Inc()
+			return fmt.Errorf("Ошибка перебора заказов: %w", err)
+		}
+		rows.Close()
+		p.metrics.QueryDuration.WithLabelValues("find_orders_by_contact").Observe(time.Since(queryStartTime).Seconds())
+
+		// Товары всех найденных заказов читаем одним запросом (ANY($1))
+		return p.loadItems(ctx, db, orders)
+	}))
+	if err != nil {
+		return nil, classify(err)
+	}
+	return orders, nil
+}
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"test_service/internal/retry"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrUnavailable БД недоступна: ошибка соединения, а не ошибка запроса или данных
+var ErrUnavailable = errors.New("база данных недоступна")
+
+// ErrDuplicateItem в заказе несколько товаров с одним chrt_id; товар заказа определяется chrt_id
+var ErrDuplicateItem = errors.New("повторяющийся chrt_id товара в заказе")
+
+// ErrConstraintViolation данные нарушают ограничение схемы БД (NOT NULL, CHECK, длина строки):
+// это ошибка данных, а не инфраструктуры, и повтор ее не исправит
+var ErrConstraintViolation = errors.New("данные нарушают ограничение схемы БД")
+
+// IsUnavailable определяет ошибки класса «нет соединения с БД»: отказ в подключении,
+// обрыв соединения, таймаут, остановка сервера PostgreSQL (SQLSTATE 08xxx, 57P01–57P03).
+// Такие ошибки временные; ошибки запросов и отсутствие данных к ним не относятся.
+func IsUnavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrUnavailable) {
+		return true
+	}
+
+	var connectErr *pgconn.ConnectError
+	if errors.As(err, &connectErr) {
+		return true
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return strings.HasPrefix(pgErr.Code, "08") || // connection_exception
+			pgErr.Code == "57P01" || // admin_shutdown
+			pgErr.Code == "57P02" || // crash_shutdown
+			pgErr.Code == "57P03" // cannot_connect_now
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	return pgconn.Timeout(err) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// isTransient определяет ошибки, которые имеет смысл повторить: недоступность БД,
+// а также конфликты параллельных транзакций (SQLSTATE 40001, 40P01) и занятая блокировка (55P03)
+func isTransient(err error) bool {
+	if IsUnavailable(err) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "40001" || // serialization_failure
+			pgErr.Code == "40P01" || // deadlock_detected
+			pgErr.Code == "55P03" // lock_not_available
+	}
+	return false
+}
+
+// retryTransient оставляет повторным попыткам только временные ошибки (isTransient): недоступность
+// БД, таймауты и конфликты параллельных транзакций. Ошибки запроса (синтаксис, ограничения схемы,
+// права доступа) повтор не исправит, поэтому они помечаются retry.Permanent и возвращаются сразу.
+func retryTransient(err error) error {
+	if err == nil || isTransient(err) {
+		return err
+	}
+	return retry.Permanent(err)
+}
+
+// isConstraintViolation определяет нарушения ограничений схемы: NOT NULL (SQLSTATE 23502),
+// CHECK (23514) и превышение длины строки (22001)
+func isConstraintViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "23502" || // not_null_violation
+			pgErr.Code == "23514" || // check_violation
+			pgErr.Code == "22001" // string_data_right_truncation
+	}
+	return false
+}
+
+// classify оборачивает ошибку соединения в ErrUnavailable, а нарушение ограничения схемы —
+// в ErrConstraintViolation, сохраняя исходную причину
+func classify(err error) error {
+	if err == nil || errors.Is(err, ErrUnavailable) || errors.Is(err, ErrConstraintViolation) {
+		return err
+	}
+	if isConstraintViolation(err) {
+		return fmt.Errorf("%w: %w", ErrConstraintViolation, err)
+	}
+	if IsUnavailable(err) {
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	return err
+}
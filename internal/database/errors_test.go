@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"test_service/internal/models"
+	"test_service/internal/retry"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsUnavailable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"Nil", nil, false},
+		{"NotFound", fmt.Errorf("%w: no rows", models.ErrOrderNotFound), false},
+		{"QueryError", &pgconn.PgError{Code: "23505"}, false},
+		{"Canceled", context.Canceled, false},
+		{"ConnectionException", &pgconn.PgError{Code: "08006"}, true},
+		{"AdminShutdown", &pgconn.PgError{Code: "57P01"}, true},
+		{"NetError", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{"Deadline", fmt.Errorf("запрос: %w", context.DeadlineExceeded), true},
+		{"EOF", io.ErrUnexpectedEOF, true},
+		{"Sentinel", ErrUnavailable, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsUnavailable(tt.err))
+		})
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"Nil", nil, false},
+		{"NotFound", models.ErrOrderNotFound, false},
+		{"ForeignKey", &pgconn.PgError{Code: "23503"}, false},
+		{"SerializationFailure", &pgconn.PgError{Code: "40001"}, true},
+		{"Deadlock", fmt.Errorf("удаление: %w", &pgconn.PgError{Code: "40P01"}), true},
+		{"LockNotAvailable", &pgconn.PgError{Code: "55P03"}, true},
+		{"Unavailable", &pgconn.PgError{Code: "08006"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isTransient(tt.err))
+		})
+	}
+}
+
+func TestRetryTransient(t *testing.T) {
+	policy := retry.Policy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, BackoffFactor: 1}
+	attempts := func(err error) int {
+		n := 0
+		_ = retry.DoWithContext(context.Background(), policy, func(context.Context) error {
+			n++
+			return retryTransient(err)
+		})
+		return n
+	}
+
+	serialization := fmt.Errorf("Ошибка коммита транзакции: %w", &pgconn.PgError{Code: "40001"})
+	assert.Equal(t, 3, attempts(serialization), "конфликт сериализации повторяется")
+	assert.Equal(t, 3, attempts(&pgconn.PgError{Code: "40P01"}), "взаимоблокировка повторяется")
+	assert.Equal(t, 3, attempts(&pgconn.PgError{Code: "08006"}), "обрыв соединения повторяется")
+	assert.Equal(t, 3, attempts(fmt.Errorf("запрос: %w", context.DeadlineExceeded)), "таймаут повторяется")
+	assert.Equal(t, 1, attempts(&pgconn.PgError{Code: "42601"}), "синтаксическая ошибка не повторяется")
+	assert.Equal(t, 1, attempts(&pgconn.PgError{Code: "23505"}), "нарушение уникальности не повторяется")
+	assert.Equal(t, 1, attempts(&pgconn.PgError{Code: "22001"}), "ошибка данных не повторяется")
+	assert.Equal(t, 1, attempts(models.ErrOrderNotFound), "отсутствие заказа не повторяется")
+	assert.Equal(t, 1, attempts(nil))
+
+	// Уже помеченная ошибка возвращается без повторной обертки
+	permanent := retry.Permanent(models.ErrOrderNotFound)
+	assert.Same(t, permanent, retryTransient(permanent))
+}
+
+func TestClassify(t *testing.T) {
+	cause := &pgconn.PgError{Code: "08001"}
+	err := classify(cause)
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.ErrorIs(t, err, cause, "исходная причина сохраняется")
+
+	queryErr := errors.New("syntax error")
+	assert.Same(t, queryErr, classify(queryErr), "прочие ошибки не оборачиваются")
+	assert.NoError(t, classify(nil))
+}
+
+func TestClassify_ConstraintViolation(t *testing.T) {
+	for _, code := range []string{"23502", "23514", "22001"} {
+		cause := &pgconn.PgError{Code: code, ConstraintName: "payment_amount_check"}
+		err := classify(fmt.Errorf("Ошибка при записи payment: %w", cause))
+		assert.ErrorIs(t, err, ErrConstraintViolation, code)
+		assert.ErrorIs(t, err, cause, "исходная причина сохраняется")
+		assert.False(t, IsUnavailable(err), "ошибка данных не считается недоступностью БД")
+		assert.False(t, isTransient(err), "ошибка данных не повторяется")
+	}
+
+	// Внешний ключ и уникальность — не ошибки данных заказа
+	assert.NotErrorIs(t, classify(&pgconn.PgError{Code: "23503"}), ErrConstraintViolation)
+	assert.NotErrorIs(t, classify(&pgconn.PgError{Code: "23505"}), ErrConstraintViolation)
+
+	// Повторная классификация не оборачивает ошибку еще раз
+	once := classify(&pgconn.PgError{Code: "23514"})
+	assert.Same(t, once, classify(once))
+}
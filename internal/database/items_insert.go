@@ -0,0 +1,123 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"test_service/internal/models"
+)
+
+const (
+	// itemColumns число колонок (параметров) одного товара в многострочном INSERT
+	itemColumns = 12
+
+	// partitionedItemColumns то же для секционированных таблиц: колонки товара и date_created заказа
+	partitionedItemColumns = itemColumns + 1
+
+	// maxQueryParams предел числа параметров одного запроса в протоколе PostgreSQL
+	maxQueryParams = 65535
+
+	// MaxItemsPerInsert наибольшее число товаров в одном многострочном INSERT:
+	// параметры всех строк должны уложиться в maxQueryParams
+	MaxItemsPerInsert = maxQueryParams / itemColumns
+
+	// maxPartitionedItemsPerInsert то же для секционированных таблиц; больший itemsPerInsert
+	// (SetMultiRowItems) уменьшается до него
+	maxPartitionedItemsPerInsert = maxQueryParams / partitionedItemColumns
+
+	// DefaultItemsPerInsert число товаров в одном многострочном INSERT по умолчанию
+	DefaultItemsPerInsert = 1000
+)
+
+// itemsInsert один многострочный INSERT товаров и его параметры
+type itemsInsert struct {
+	sql  string
+	args []any
+}
+
+// buildItemsInsert строит многострочные UPSERT товаров заказа: VALUES ($1..$12),($13..$24),...
+// не больше maxRows строк на запрос, остальные товары — следующими запросами в порядке заказа.
+// maxRows <= 0 заменяется на DefaultItemsPerInsert, больше MaxItemsPerInsert — на MaxItemsPerInsert.
+// Без товаров запросов нет.
+func buildItemsInsert(orderUID string, items []models.Item, maxRows int) []itemsInsert {
+	return buildItemsInserts(insertItemsPrefix, upsertItemsConflict, orderUID, items, maxRows)
+}
+
+// buildPartitionedItemsInsert то же для секционированных таблиц: 13-й параметр каждого товара —
+// date_created заказа (ключ секции), поэтому maxRows больше maxPartitionedItemsPerInsert уменьшается до него
+func buildPartitionedItemsInsert(orderUID string, dateCreated time.Time, items []models.Item, maxRows int) []itemsInsert {
+	return buildItemsInserts(insertPartitionedItemsPrefix, upsertPartitionedItemsConflict, orderUID, items, maxRows, dateCreated)
+}
+
+// buildItemsInserts строит многострочные INSERT prefix ... conflict; после колонок каждого товара
+// идут параметры rowTail
+func buildItemsInserts(prefix, conflict, orderUID string, items []models.Item, maxRows int, rowTail ...any) []itemsInsert {
+	columns := itemColumns + len(rowTail)
+	switch {
+	case maxRows <= 0:
+		maxRows = DefaultItemsPerInsert
+	case maxRows > maxQueryParams/columns:
+		maxRows = maxQueryParams / columns
+	}
+
+	inserts := make([]itemsInsert, 0, (len(items)+maxRows-1)/maxRows)
+	for start := 0; start < len(items); start += maxRows {
+		chunk := items[start:min(start+maxRows, len(items))]
+
+		var sql strings.Builder
+		sql.WriteString(prefix)
+		sql.WriteString("\n\t\tVALUES ")
+		args := make([]any, 0, len(chunk)*columns)
+		for i, item := range chunk {
+			if i > 0 {
+				sql.WriteString(", ")
+			}
+			sql.WriteByte('(')
+			for col := 1; col <= columns; col++ {
+				if col > 1 {
+					sql.WriteString(", ")
+				}
+				fmt.Fprintf(&sql, "$%d", i*columns+col)
+			}
+			sql.WriteByte(')')
+			args = append(args, orderUID, item.ChrtID, item.TrackNumber, item.Price, item.RID, item.Name,
+				item.Sale, item.Size, item.TotalPrice, item.NMID, item.Brand, item.Status)
+			args = append(args, rowTail...)
+		}
+		sql.WriteString(conflict)
+
+		inserts = append(inserts, itemsInsert{sql: sql.String(), args: args})
+	}
+	return inserts
+}
+
+// uniqueItems оставляет по одному товару на chrt_id — последний, на его месте в заказе.
+// Повтор chrt_id в одном UPSERT PostgreSQL отклоняет ("ON CONFLICT DO UPDATE command cannot
+// affect row a second time"), а товары с одним chrt_id и так сохраняются в одну строку.
+// Без повторов возвращает items без копирования.
+func uniqueItems(items []models.Item) []models.Item {
+	last := make(map[int]int, len(items))
+	for i, item := range items {
+		last[item.ChrtID] = i
+	}
+	if len(last) == len(items) {
+		return items
+	}
+	unique := make([]models.Item, 0, len(last))
+	for i, item := range items {
+		if last[item.ChrtID] == i {
+			unique = append(unique, item)
+		}
+	}
+	return unique
+}
+
+// SetMultiRowItems переключает сохранение товаров заказа с одного запроса по массивам колонок
+// (unnest) на многострочные INSERT по itemsPerInsert товаров (buildItemsInsert).
+// Нужен, когда массивы параметров недоступны или нежелательны, например за PgBouncer
+// с выполнением без подготовки; вызывается до начала работы с БД.
+func (p *Postgres) SetMultiRowItems(enabled bool, itemsPerInsert int) {
+	p.save.multiRowItems = enabled
+	p.save.itemsPerInsert = itemsPerInsert
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"test_service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testItems возвращает n товаров с chrt_id 1..n
+func testItems(n int) []models.Item {
+	items := make([]models.Item, n)
+	for i := range items {
+		items[i] = models.Item{ChrtID: i + 1, Name: fmt.Sprintf("item-%d", i+1)}
+	}
+	return items
+}
+
+func TestBuildItemsInsert(t *testing.T) {
+	const maxRows = 3
+
+	tests := []struct {
+		name  string
+		items int
+		rows  []int // Число строк в каждом запросе
+	}{
+		{"NoItems", 0, nil},
+		{"OneItem", 1, []int{1}},
+		{"FullChunk", maxRows, []int{maxRows}},
+		{"OverChunk", maxRows + 1, []int{maxRows, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inserts := buildItemsInsert("uid", testItems(tt.items), maxRows)
+			require.Len(t, inserts, len(tt.rows))
+
+			chrtID := 1
+			for i, insert := range inserts {
+				rows := tt.rows[i]
+				require.Len(t, insert.args, rows*itemColumns)
+				assert.Equal(t, rows, strings.Count(insert.sql, "($"), "строк VALUES")
+				assert.True(t, strings.HasPrefix(insert.sql, insertItemsPrefix+"\n\t\tVALUES ($1, $2, "))
+				assert.True(t, strings.HasSuffix(insert.sql, upsertItemsConflict))
+
+				// Нумерация параметров начинается заново в каждом запросе и не имеет пропусков
+				last := fmt.Sprintf("$%d)", rows*itemColumns)
+				assert.Contains(t, insert.sql, last)
+				assert.NotContains(t, insert.sql, fmt.Sprintf("$%d", rows*itemColumns+1))
+
+				// Товары идут в порядке заказа, каждый — order_uid и затем значения колонок
+				for row := 0; row < rows; row++ {
+					assert.Equal(t, "uid", insert.args[row*itemColumns])
+					assert.Equal(t, chrtID, insert.args[row*itemColumns+1])
+					assert.Equal(t, fmt.Sprintf("item-%d", chrtID), insert.args[row*itemColumns+5])
+					chrtID++
+				}
+			}
+			assert.Equal(t, tt.items+1, chrtID, "сохраняются все товары")
+		})
+	}
+}
+
+func TestBuildPartitionedItemsInsert(t *testing.T) {
+	created := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	inserts := buildPartitionedItemsInsert("uid", created, testItems(3), 2)
+	require.Len(t, inserts, 2)
+
+	insert := inserts[0]
+	require.Len(t, insert.args, 2*partitionedItemColumns)
+	assert.True(t, strings.HasPrefix(insert.sql, insertPartitionedItemsPrefix+"\n\t\tVALUES ($1, $2, "))
+	assert.True(t, strings.HasSuffix(insert.sql, upsertPartitionedItemsConflict))
+	assert.Contains(t, insert.sql, "($14, $15, ")
+	assert.Contains(t, insert.sql, "$26)")
+
+	// Каждый товар — order_uid, значения колонок и date_created заказа (ключ секции)
+	for row := 0; row < 2; row++ {
+		assert.Equal(t, "uid", insert.args[row*partitionedItemColumns])
+		assert.Equal(t, row+1, insert.args[row*partitionedItemColumns+1])
+		assert.Equal(t, created, insert.args[(row+1)*partitionedItemColumns-1])
+	}
+	assert.Len(t, inserts[1].args, partitionedItemColumns)
+
+	// Лишний параметр товара уменьшает предел товаров в запросе
+	assert.LessOrEqual(t, maxPartitionedItemsPerInsert*partitionedItemColumns, maxQueryParams)
+	inserts = buildPartitionedItemsInsert("uid", created, testItems(maxPartitionedItemsPerInsert+1), MaxItemsPerInsert)
+	require.Len(t, inserts, 2)
+	assert.Len(t, inserts[0].args, maxPartitionedItemsPerInsert*partitionedItemColumns)
+}
+
+func TestUniqueItems(t *testing.T) {
+	items := testItems(3)
+	assert.Same(t, &items[0], &uniqueItems(items)[0], "без повторов товары не копируются")
+
+	dup := []models.Item{{ChrtID: 1, Name: "a"}, {ChrtID: 2, Name: "b"}, {ChrtID: 1, Name: "c"}, {ChrtID: 2, Name: "d"}}
+	assert.Equal(t, []models.Item{{ChrtID: 1, Name: "c"}, {ChrtID: 2, Name: "d"}}, uniqueItems(dup))
+	assert.Empty(t, uniqueItems(nil))
+}
+
+func TestBuildItemsInsert_MaxRows(t *testing.T) {
+	// Предел параметров запроса не превышается и при слишком большом maxRows
+	assert.LessOrEqual(t, MaxItemsPerInsert*itemColumns, maxQueryParams)
+	inserts := buildItemsInsert("uid", testItems(MaxItemsPerInsert+1), MaxItemsPerInsert*2)
+	require.Len(t, inserts, 2)
+	assert.Len(t, inserts[0].args, MaxItemsPerInsert*itemColumns)
+	assert.Len(t, inserts[1].args, itemColumns)
+
+	// maxRows <= 0 — значение по умолчанию
+	inserts = buildItemsInsert("uid", testItems(DefaultItemsPerInsert+1), 0)
+	require.Len(t, inserts, 2)
+	assert.Len(t, inserts[0].args, DefaultItemsPerInsert*itemColumns)
+}
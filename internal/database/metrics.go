@@ -7,30 +7,50 @@ import (
 
 // DBMetrics содержит все метрики, связанные с базой данных
 type DBMetrics struct {
-	SuccessfulSavesTotal  prometheus.Counter
-	FailedSavesTotal      prometheus.Counter
-	SuccessfulGetsTotal   prometheus.Counter
-	FailedGetsTotal       prometheus.Counter
-	SuccessfulGetAllTotal prometheus.Counter
-	FailedGetAllTotal     prometheus.Counter
-
-	SaveDuration   prometheus.Histogram
-	GetDuration    prometheus.Histogram
-	GetAllDuration prometheus.Histogram
-	InitDuration   prometheus.Histogram
+	SuccessfulSavesTotal   prometheus.Counter
+	FailedSavesTotal       prometheus.Counter
+	SuccessfulGetsTotal    prometheus.Counter
+	FailedGetsTotal        prometheus.Counter
+	SuccessfulGetAllTotal  prometheus.Counter
+	FailedGetAllTotal      prometheus.Counter
+	DeletedOrdersTotal     prometheus.Counter
+	SoftDeletedOrdersTotal prometheus.Counter
+	OrdersNotFoundTotal    prometheus.Counter
+	ArchivedOrdersTotal    prometheus.Counter
+	StatusUpdatesTotal     *prometheus.CounterVec
+
+	Partitions             prometheus.Gauge
+	PartitionsCreatedTotal prometheus.Counter
+	PartitionsDroppedTotal prometheus.Counter
+
+	SaveDuration    prometheus.Histogram
+	GetDuration     prometheus.Histogram
+	GetAllDuration  prometheus.Histogram
+	InitDuration    prometheus.Histogram
+	ArchiveDuration prometheus.Histogram
+
+	SaveOrdersDuration prometheus.Histogram
+
+	SaveItemsBatchSize  prometheus.Histogram
+	SaveOrdersBatchSize prometheus.Histogram
 
 	ConnectionErrorsTotal  prometheus.Counter
 	TransactionErrorsTotal prometheus.Counter
 	QueryErrorsTotal       prometheus.Counter
 
-	ConnectionOpen            prometheus.Gauge
-	ConnectionAcquireCount    prometheus.Counter
-	ConnectionAcquireDuration prometheus.Histogram
-	ConnectionMaxOpen         prometheus.Gauge
+	ConnectionOpen                 prometheus.Gauge
+	ConnectionAcquireCount         prometheus.Counter
+	ConnectionAcquireDuration      prometheus.Counter
+	ConnectionEmptyAcquireCount    prometheus.Counter
+	ConnectionCanceledAcquireCount prometheus.Counter
+	ConnectionMaxOpen              prometheus.Gauge
 
 	QueryDuration *prometheus.HistogramVec
 	QueryErrors   *prometheus.CounterVec
 
+	ReadQueries    *prometheus.CounterVec
+	ReplicaHealthy prometheus.Gauge
+
 	ConnectionEstablishDuration prometheus.Histogram
 }
 
@@ -69,6 +89,38 @@ func NewDBMetrics() *DBMetrics {
 			Name: "db_failed_get_all_total",
 			Help: "Общее количество неудачных операций получения всех записей из БД",
 		}),
+		DeletedOrdersTotal: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "db_deleted_orders_total",
+			Help: "Общее количество удаленных заказов",
+		}),
+		SoftDeletedOrdersTotal: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "db_soft_deleted_orders_total",
+			Help: "Общее количество заказов, отмеченных удаленными без удаления данных",
+		}),
+		OrdersNotFoundTotal: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "db_orders_not_found_total",
+			Help: "Количество запросов заказа, не нашедших его в БД (не считаются ошибками)",
+		}),
+		StatusUpdatesTotal: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "db_order_status_updates_total",
+			Help: "Количество смен статуса заказа по новому статусу",
+		}, []string{"status"}),
+		ArchivedOrdersTotal: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "db_archived_orders_total",
+			Help: "Общее количество заказов, перенесенных в архивные таблицы",
+		}),
+		Partitions: promauto.NewGauge(prometheus.GaugeOpts{
+			Name: "db_order_partitions",
+			Help: "Количество месячных секций заказов (без секции DEFAULT)",
+		}),
+		PartitionsCreatedTotal: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "db_order_partitions_created_total",
+			Help: "Количество созданных месячных секций заказов",
+		}),
+		PartitionsDroppedTotal: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "db_order_partitions_dropped_total",
+			Help: "Количество удаленных по сроку хранения месячных секций заказов",
+		}),
 		SaveDuration: promauto.NewHistogram(prometheus.HistogramOpts{
 			Name:    "db_save_duration_seconds",
 			Help:    "Время выполнения операции сохранения в БД в секундах",
@@ -89,6 +141,26 @@ func NewDBMetrics() *DBMetrics {
 			Help:    "Время выполнения инициализации БД в секундах",
 			Buckets: []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1.0, 2.5, 5.0, 10.0, 30.0},
 		}),
+		ArchiveDuration: promauto.NewHistogram(prometheus.HistogramOpts{
+			Name:    "db_archive_duration_seconds",
+			Help:    "Время выполнения архивации заказов (всех пакетов одного запуска) в секундах",
+			Buckets: []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1.0, 2.5, 5.0, 10.0, 30.0, 60.0, 300.0},
+		}),
+		SaveOrdersDuration: promauto.NewHistogram(prometheus.HistogramOpts{
+			Name:    "db_save_orders_duration_seconds",
+			Help:    "Время пакетного сохранения заказов (SaveOrders, SaveOrdersPartial) в секундах",
+			Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1.0, 2.5, 5.0, 10.0, 30.0},
+		}),
+		SaveOrdersBatchSize: promauto.NewHistogram(prometheus.HistogramOpts{
+			Name:    "db_save_orders_batch_size",
+			Help:    "Количество заказов, сохраненных одной транзакцией SaveOrders или SaveOrdersPartial",
+			Buckets: []float64{1, 2, 5, 10, 25, 50, 100, 250, 500, 1000},
+		}),
+		SaveItemsBatchSize: promauto.NewHistogram(prometheus.HistogramOpts{
+			Name:    "db_save_items_batch_size",
+			Help:    "Количество товаров, сохраненных одним запросом UPSERT при сохранении заказа",
+			Buckets: []float64{1, 2, 5, 10, 25, 50, 100, 250, 500},
+		}),
 		ConnectionErrorsTotal: promauto.NewCounter(prometheus.CounterOpts{
 			Name: "db_connection_errors_total",
 			Help: "Общее количество ошибок подключения к БД",
@@ -107,12 +179,19 @@ func NewDBMetrics() *DBMetrics {
 		}),
 		ConnectionAcquireCount: promauto.NewCounter(prometheus.CounterOpts{
 			Name: "db_connection_acquire_total",
-			Help: "Количество попыток получения соединения из пула",
+			Help: "Количество успешных получений соединения из пула",
+		}),
+		ConnectionAcquireDuration: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "db_connection_acquire_duration_seconds_total",
+			Help: "Суммарное время получения соединений из пула в секундах",
 		}),
-		ConnectionAcquireDuration: promauto.NewHistogram(prometheus.HistogramOpts{
-			Name:    "db_connection_acquire_duration_seconds",
-			Help:    "Время ожидания получения соединения из пула в секундах",
-			Buckets: []float64{0.0005, 0.001, 0.002, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1.0},
+		ConnectionEmptyAcquireCount: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "db_connection_empty_acquire_total",
+			Help: "Количество получений соединения, ожидавших освобождения или открытия соединения (пул был пуст)",
+		}),
+		ConnectionCanceledAcquireCount: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "db_connection_canceled_acquire_total",
+			Help: "Количество получений соединения, прерванных отменой контекста",
 		}),
 		ConnectionMaxOpen: promauto.NewGauge(prometheus.GaugeOpts{
 			Name: "db_connections_max_open",
@@ -133,6 +212,17 @@ func NewDBMetrics() *DBMetrics {
 			},
 			[]string{"operation"},
 		),
+		ReadQueries: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "db_read_queries_total",
+				Help: "Попытки операций чтения по пулу: primary или replica",
+			},
+			[]string{"operation", "target"},
+		),
+		ReplicaHealthy: promauto.NewGauge(prometheus.GaugeOpts{
+			Name: "db_replica_healthy",
+			Help: "Реплика для чтения исправна и принимает запросы чтения (1) или чтение идет на основной сервер (0)",
+		}),
 		ConnectionEstablishDuration: promauto.NewHistogram(prometheus.HistogramOpts{
 			Name:    "db_connection_establish_duration_seconds",
 			Help:    "Время установления подключения к БД в секундах",
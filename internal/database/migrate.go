@@ -0,0 +1,192 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"test_service/internal/retry"
+)
+
+// migrationFiles SQL-файлы миграций, встроенные в бинарник
+//
+//go:embed migrations/*.sql migrations/partitioning/*.sql
+var migrationFiles embed.FS
+
+// partitioningMigrationsDir каталог необязательных миграций секционирования: применяются
+// только после SetPartitioning(true)
+const partitioningMigrationsDir = "migrations/partitioning"
+
+// migrationLockKey ключ advisory-блокировки, сериализующей Init нескольких экземпляров сервиса
+const migrationLockKey int64 = 0x6d696772617465 // "migrate"
+
+// ErrMigrationChecksum файл уже примененной миграции изменен после применения
+var ErrMigrationChecksum = errors.New("контрольная сумма примененной миграции не совпадает")
+
+// migration миграция схемы из файла migrations/<id>.sql
+type migration struct {
+	id       string // Имя файла без расширения; задает порядок применения
+	sql      string // Содержимое файла
+	checksum string // SHA-256 содержимого в hex
+}
+
+// loadMigrations читает миграции *.sql из каталога dir в лексическом порядке имен
+func loadMigrations(fsys fs.FS, dir string) ([]migration, error) {
+	names, err := fs.Glob(fsys, path.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, fmt.Errorf("Ошибка поиска файлов миграций: %w", err)
+	}
+	sort.Strings(names)
+
+	migrations := make([]migration, 0, len(names))
+	for _, name := range names {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, fmt.Errorf("Ошибка чтения миграции %s: %w", name, err)
+		}
+		sum := sha256.Sum256(data)
+		migrations = append(migrations, migration{
+			id:       strings.TrimSuffix(path.Base(name), ".sql"),
+			sql:      string(data),
+			checksum: hex.EncodeToString(sum[:]),
+		})
+	}
+	return migrations, nil
+}
+
+// embeddedMigrations возвращает встроенные миграции для применения: каталог migrations и,
+// если включено секционирование, partitioningMigrationsDir — в общем лексическом порядке имен
+func (p *Postgres) embeddedMigrations() ([]migration, error) {
+	migrations, err := loadMigrations(migrationFiles, "migrations")
+	if err != nil || !p.partitioning {
+		return migrations, err
+	}
+	optional, err := loadMigrations(migrationFiles, partitioningMigrationsDir)
+	if err != nil {
+		return nil, err
+	}
+	migrations = append(migrations, optional...)
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].id < migrations[j].id })
+	return migrations, nil
+}
+
+// migrate применяет еще не примененные встроенные миграции в одной транзакции.
+// Транзакционная advisory-блокировка не дает двум экземплярам применять миграции одновременно:
+// второй дождется фиксации первого и увидит его миграции примененными.
+// Измененный файл уже примененной миграции — ошибка ErrMigrationChecksum, повтор не выполняется.
+// Записи о миграциях, файлов которых больше нет (например, до перехода на файлы) или которые
+// не применяются (секционирование выключено после включения), не проверяются.
+func (p *Postgres) migrate(ctx context.Context) error {
+	migrations, err := p.embeddedMigrations()
+	if err != nil {
+		return retry.Permanent(err)
+	}
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		p.metrics.ConnectionErrorsTotal.Inc()
+		return fmt.Errorf("Ошибка начала транзакции миграций: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Блокировка снимается вместе с завершением транзакции
+	queryStartTime := time.Now()
+	if _, err := tx.Exec(ctx, MigrationXactLockQuery, migrationLockKey); err != nil {
+		p.metrics.QueryErrorsTotal.Inc()
+		p.metrics.QueryErrors.WithLabelValues("init_migration_lock").Inc()
+		return fmt.Errorf("Ошибка захвата блокировки миграций: %w", err)
+	}
+	p.metrics.QueryDuration.WithLabelValues("init_migration_lock").Observe(time.Since(queryStartTime).Seconds())
+
+	queryStartTime = time.Now()
+	for _, query := range []string{CreateMigrationsTableQuery, AddMigrationsChecksumQuery} {
+		if _, err := tx.Exec(ctx, query); err != nil {
+			p.metrics.QueryErrorsTotal.Inc()
+			p.metrics.QueryErrors.WithLabelValues("init_create_migrations_table").Inc()
+			return fmt.Errorf("Ошибка подготовки schema_migrations: %w", err)
+		}
+	}
+	p.metrics.QueryDuration.WithLabelValues("init_create_migrations_table").Observe(time.Since(queryStartTime).Seconds())
+
+	queryStartTime = time.Now()
+	applied := make(map[string]*string)
+	rows, err := tx.Query(ctx, GetAppliedMigrationsQuery)
+	if err != nil {
+		p.metrics.QueryErrorsTotal.Inc()
+		p.metrics.QueryErrors.WithLabelValues("init_check_migration").Inc()
+		return fmt.Errorf("Ошибка чтения примененных миграций: %w", err)
+	}
+	for rows.Next() {
+		var id string
+		var checksum *string
+		if err := rows.Scan(&id, &checksum); err != nil {
+			rows.Close()
+			p.metrics.QueryErrorsTotal.Inc()
+			p.metrics.QueryErrors.WithLabelValues("init_check_migration").Inc()
+			return fmt.Errorf("Ошибка чтения примененной миграции: %w", err)
+		}
+		applied[id] = checksum
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		p.metrics.QueryErrorsTotal.Inc()
+		p.metrics.QueryErrors.WithLabelValues("init_check_migration").Inc()
+		return fmt.Errorf("Ошибка перебора примененных миграций: %w", err)
+	}
+	p.metrics.QueryDuration.WithLabelValues("init_check_migration").Observe(time.Since(queryStartTime).Seconds())
+
+	var done []string
+	for _, m := range migrations {
+		if checksum, ok := applied[m.id]; ok {
+			if checksum == nil {
+				// Миграция записана до появления контрольных сумм: запоминаем текущую
+				if _, err := tx.Exec(ctx, BackfillChecksumQuery, m.id, m.checksum); err != nil {
+					p.metrics.QueryErrorsTotal.Inc()
+					p.metrics.QueryErrors.WithLabelValues("init_record_migration").Inc()
+					return fmt.Errorf("Ошибка записи контрольной суммы миграции %s: %w", m.id, err)
+				}
+				continue
+			}
+			if *checksum != m.checksum {
+				return retry.Permanent(fmt.Errorf("%w: %s", ErrMigrationChecksum, m.id))
+			}
+			continue
+		}
+
+		queryStartTime = time.Now()
+		if _, err := tx.Exec(ctx, m.sql); err != nil {
+			p.metrics.QueryErrorsTotal.Inc()
+			p.metrics.QueryErrors.WithLabelValues("init_apply_migration").Inc()
+			return fmt.Errorf("Ошибка применения миграции %s: %w", m.id, err)
+		}
+		p.metrics.QueryDuration.WithLabelValues("init_apply_migration").Observe(time.Since(queryStartTime).Seconds())
+
+		queryStartTime = time.Now()
+		if _, err := tx.Exec(ctx, RecordMigrationQuery, m.id, m.checksum); err != nil {
+			p.metrics.QueryErrorsTotal.Inc()
+			p.metrics.QueryErrors.WithLabelValues("init_record_migration").Inc()
+			return fmt.Errorf("Ошибка записи миграции %s: %w", m.id, err)
+		}
+		p.metrics.QueryDuration.WithLabelValues("init_record_migration").Observe(time.Since(queryStartTime).Seconds())
+		done = append(done, m.id)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		p.metrics.QueryErrorsTotal.Inc()
+		p.metrics.QueryErrors.WithLabelValues("init_commit_migrations").Inc()
+		return fmt.Errorf("Ошибка фиксации миграций: %w", err)
+	}
+	for _, id := range done {
+		log.Printf("Применена миграция: %s", id)
+	}
+	return nil
+}
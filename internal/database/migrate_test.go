@@ -0,0 +1,69 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMigrations_Order(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/0010_later.sql":  {Data: []byte("SELECT 10")},
+		"migrations/0002_second.sql": {Data: []byte("SELECT 2")},
+		"migrations/0001_first.sql":  {Data: []byte("SELECT 1")},
+		"migrations/README.md":       {Data: []byte("не миграция")},
+	}
+
+	migrations, err := loadMigrations(fsys, "migrations")
+	require.NoError(t, err)
+
+	ids := make([]string, 0, len(migrations))
+	for _, m := range migrations {
+		ids = append(ids, m.id)
+	}
+	assert.Equal(t, []string{"0001_first", "0002_second", "0010_later"}, ids)
+
+	sum := sha256.Sum256([]byte("SELECT 1"))
+	assert.Equal(t, "SELECT 1", migrations[0].sql)
+	assert.Equal(t, hex.EncodeToString(sum[:]), migrations[0].checksum)
+}
+
+func TestLoadMigrations_Embedded(t *testing.T) {
+	migrations, err := loadMigrations(migrationFiles, "migrations")
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	assert.Equal(t, "0001_initial_schema", migrations[0].id)
+	assert.Contains(t, migrations[0].sql, "CREATE TABLE IF NOT EXISTS orders")
+	seen := make(map[string]bool)
+	for _, m := range migrations {
+		assert.False(t, seen[m.id], "повторный id миграции %s", m.id)
+		seen[m.id] = true
+		assert.Len(t, m.checksum, 64)
+	}
+}
+
+func TestEmbeddedMigrations_Partitioning(t *testing.T) {
+	ids := func(p *Postgres) []string {
+		migrations, err := p.embeddedMigrations()
+		require.NoError(t, err)
+		ids := make([]string, 0, len(migrations))
+		for _, m := range migrations {
+			ids = append(ids, m.id)
+		}
+		return ids
+	}
+
+	// Секционирование по умолчанию выключено: его миграция не применяется
+	p := &Postgres{}
+	assert.NotContains(t, ids(p), "0009_monthly_partitions")
+
+	p.SetPartitioning(true)
+	withPartitioning := ids(p)
+	assert.Contains(t, withPartitioning, "0009_monthly_partitions")
+	assert.IsIncreasing(t, withPartitioning, "необязательные миграции в общем порядке имен")
+}
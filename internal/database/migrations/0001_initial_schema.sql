@@ -0,0 +1,70 @@
+-- Исходная схема: заказы, доставка, платежи, товары и индексы.
+-- Все операторы идемпотентны, чтобы миграция применялась и к базам,
+-- созданным до перехода на файловые миграции.
+
+CREATE TABLE IF NOT EXISTS orders (
+	order_uid VARCHAR(255) PRIMARY KEY,
+	track_number VARCHAR(255),
+	entry VARCHAR(255),
+	locale VARCHAR(10),
+	internal_signature VARCHAR(255),
+	customer_id VARCHAR(255),
+	delivery_service VARCHAR(255),
+	shardkey VARCHAR(255),
+	sm_id INTEGER,
+	date_created TIMESTAMP,
+	oof_shard VARCHAR(255),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Базы, созданные до появления updated_at
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+CREATE TABLE IF NOT EXISTS delivery (
+	order_uid VARCHAR(255) PRIMARY KEY REFERENCES orders(order_uid) ON DELETE CASCADE,
+	name VARCHAR(255),
+	phone VARCHAR(255),
+	zip VARCHAR(255),
+	city VARCHAR(255),
+	address VARCHAR(255),
+	region VARCHAR(255),
+	email VARCHAR(255)
+);
+
+CREATE TABLE IF NOT EXISTS payment (
+	order_uid VARCHAR(255) PRIMARY KEY REFERENCES orders(order_uid) ON DELETE CASCADE,
+	transaction VARCHAR(255),
+	request_id VARCHAR(255),
+	currency VARCHAR(10),
+	provider VARCHAR(255),
+	amount INTEGER,
+	payment_dt BIGINT,
+	bank VARCHAR(255),
+	delivery_cost INTEGER,
+	goods_total INTEGER,
+	custom_fee INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS items (
+	id SERIAL PRIMARY KEY,
+	order_uid VARCHAR(255) REFERENCES orders(order_uid) ON DELETE CASCADE,
+	chrt_id INTEGER,
+	track_number VARCHAR(255),
+	price INTEGER,
+	rid VARCHAR(255),
+	name VARCHAR(255),
+	sale INTEGER,
+	size VARCHAR(255),
+	total_price INTEGER,
+	nm_id INTEGER,
+	brand VARCHAR(255),
+	status INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS idx_items_order_uid ON items(order_uid);
+CREATE INDEX IF NOT EXISTS idx_orders_track_number ON orders(track_number);
+CREATE INDEX IF NOT EXISTS idx_orders_date_created ON orders(date_created);
+CREATE INDEX IF NOT EXISTS idx_orders_customer_id ON orders(customer_id);
+
+-- Ключ пагинации GetOrdersPage
+CREATE INDEX IF NOT EXISTS idx_orders_date_created_uid ON orders(date_created DESC, order_uid DESC);
@@ -0,0 +1,9 @@
+-- Товар заказа определяется chrt_id: SaveOrder обновляет товары по (order_uid, chrt_id)
+-- вместо удаления и повторной вставки всех товаров.
+
+-- Повторы, сохраненные до появления ограничения: оставляем последнюю запись
+DELETE FROM items a
+	USING items b
+	WHERE a.order_uid = b.order_uid AND a.chrt_id = b.chrt_id AND a.id < b.id;
+
+ALTER TABLE items ADD CONSTRAINT items_order_uid_chrt_id_key UNIQUE (order_uid, chrt_id);
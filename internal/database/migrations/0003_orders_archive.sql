@@ -0,0 +1,16 @@
+-- Архив заказов старше срока хранения (ArchiveOrdersBefore). Таблицы повторяют колонки основных
+-- без внешних ключей и ограничений уникальности: заказ с тем же UID, сохраненный снова после
+-- архивации, может попасть в архив повторно. Новые колонки основных таблиц нужно добавлять и сюда.
+
+CREATE TABLE orders_archive (LIKE orders);
+ALTER TABLE orders_archive ADD COLUMN archived_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+CREATE INDEX idx_orders_archive_order_uid ON orders_archive(order_uid);
+
+CREATE TABLE delivery_archive (LIKE delivery);
+CREATE INDEX idx_delivery_archive_order_uid ON delivery_archive(order_uid);
+
+CREATE TABLE payment_archive (LIKE payment);
+CREATE INDEX idx_payment_archive_order_uid ON payment_archive(order_uid);
+
+CREATE TABLE items_archive (LIKE items);
+CREATE INDEX idx_items_archive_order_uid ON items_archive(order_uid);
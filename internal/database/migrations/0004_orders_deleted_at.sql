@@ -0,0 +1,4 @@
+-- Мягкое удаление (SoftDeleteOrder): заказ скрывается из выборок, данные остаются в БД.
+-- Архив хранит отметку вместе с заказом.
+ALTER TABLE orders ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE orders_archive ADD COLUMN deleted_at TIMESTAMP;
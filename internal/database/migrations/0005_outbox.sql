@@ -0,0 +1,10 @@
+-- Исходящие события (transactional outbox): пишутся в одной транзакции с заказом и
+-- отправляются в Kafka публикатором (internal/outbox). published_at NULL — событие еще не отправлено.
+CREATE TABLE outbox (
+    id BIGSERIAL PRIMARY KEY,
+    aggregate_id VARCHAR(255) NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    published_at TIMESTAMPTZ
+);
+CREATE INDEX idx_outbox_unpublished ON outbox(id) WHERE published_at IS NULL;
@@ -0,0 +1,4 @@
+-- Поиск заказов по контактам покупателя (FindOrdersByContact). Выражения индексов совпадают
+-- с нормализацией models.NormalizeEmail и models.NormalizePhone.
+CREATE INDEX idx_delivery_email_lower ON delivery (lower(email));
+CREATE INDEX idx_delivery_phone_digits ON delivery (regexp_replace(phone, '[^0-9+]', '', 'g'));
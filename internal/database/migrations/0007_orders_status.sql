@@ -0,0 +1,5 @@
+-- Статус жизненного цикла заказа (UpdateOrderStatus). Набор значений совпадает с models.OrderStatuses;
+-- существующие заказы получают статус 'new'. Архив хранит статус вместе с заказом.
+ALTER TABLE orders ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'new'
+	CONSTRAINT orders_status_check CHECK (status IN ('new', 'accepted', 'packed', 'shipped', 'delivered', 'cancelled'));
+ALTER TABLE orders_archive ADD COLUMN status VARCHAR(16);
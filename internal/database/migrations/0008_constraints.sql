@@ -0,0 +1,62 @@
+-- Ограничения схемы повторяют строгую проверку models.Order.Validate, чтобы некорректные строки
+-- не появлялись в БД в обход валидатора. Нарушение отклоняется с SQLSTATE 23502, 23514 или 22001
+-- и возвращается как database.ErrConstraintViolation.
+-- Если существующие строки нарушают ограничения, миграция прерывается и сервис не стартует:
+-- такие строки нужно исправить или удалить вручную.
+
+ALTER TABLE orders
+	ALTER COLUMN track_number SET NOT NULL,
+	ALTER COLUMN entry SET NOT NULL,
+	ALTER COLUMN locale SET NOT NULL,
+	ALTER COLUMN internal_signature SET NOT NULL,
+	ALTER COLUMN customer_id SET NOT NULL,
+	ALTER COLUMN delivery_service SET NOT NULL,
+	ALTER COLUMN shardkey SET NOT NULL,
+	ALTER COLUMN sm_id SET NOT NULL,
+	ALTER COLUMN date_created SET NOT NULL,
+	ALTER COLUMN oof_shard SET NOT NULL,
+	ADD CONSTRAINT orders_sm_id_check CHECK (sm_id > 0);
+
+ALTER TABLE delivery
+	ALTER COLUMN name SET NOT NULL,
+	ALTER COLUMN phone SET NOT NULL,
+	ALTER COLUMN phone TYPE VARCHAR(32),
+	ALTER COLUMN zip SET NOT NULL,
+	ALTER COLUMN zip TYPE VARCHAR(32),
+	ALTER COLUMN city SET NOT NULL,
+	ALTER COLUMN address SET NOT NULL,
+	ALTER COLUMN region SET NOT NULL,
+	ALTER COLUMN email SET NOT NULL;
+
+ALTER TABLE payment
+	ALTER COLUMN transaction SET NOT NULL,
+	ALTER COLUMN request_id SET NOT NULL,
+	ALTER COLUMN currency SET NOT NULL,
+	ALTER COLUMN provider SET NOT NULL,
+	ALTER COLUMN amount SET NOT NULL,
+	ALTER COLUMN payment_dt SET NOT NULL,
+	ALTER COLUMN bank SET NOT NULL,
+	ALTER COLUMN delivery_cost SET NOT NULL,
+	ALTER COLUMN goods_total SET NOT NULL,
+	ALTER COLUMN custom_fee SET NOT NULL,
+	ADD CONSTRAINT payment_amount_check CHECK (amount >= 0),
+	ADD CONSTRAINT payment_payment_dt_check CHECK (payment_dt > 0),
+	ADD CONSTRAINT payment_delivery_cost_check CHECK (delivery_cost >= 0),
+	ADD CONSTRAINT payment_goods_total_check CHECK (goods_total >= 0),
+	ADD CONSTRAINT payment_custom_fee_check CHECK (custom_fee >= 0);
+
+ALTER TABLE items
+	ALTER COLUMN order_uid SET NOT NULL,
+	ALTER COLUMN chrt_id SET NOT NULL,
+	ALTER COLUMN track_number SET NOT NULL,
+	ALTER COLUMN price SET NOT NULL,
+	ALTER COLUMN rid SET NOT NULL,
+	ALTER COLUMN name SET NOT NULL,
+	ALTER COLUMN sale SET NOT NULL,
+	ALTER COLUMN size SET NOT NULL,
+	ALTER COLUMN total_price SET NOT NULL,
+	ALTER COLUMN nm_id SET NOT NULL,
+	ALTER COLUMN brand SET NOT NULL,
+	ALTER COLUMN status SET NOT NULL,
+	ADD CONSTRAINT items_price_check CHECK (price >= 0),
+	ADD CONSTRAINT items_total_price_check CHECK (total_price >= 0);
@@ -0,0 +1,83 @@
+-- Необязательная миграция: применяется только при включенном секционировании (Postgres.SetPartitioning).
+-- Секционирование orders, delivery, payment и items по месяцам date_created (RANGE): заказы старше
+-- срока хранения удаляются целыми секциями (PartitionManager) вместо DELETE по строкам.
+-- Ключ секционирования входит в каждое ограничение уникальности, поэтому заказ определяется парой
+-- (order_uid, date_created): дочерние таблицы хранят date_created заказа, ссылаются на него составным
+-- внешним ключом и секционируются по тем же месяцам, чтобы секции месяца удалялись вместе.
+-- Схема не запрещает два заказа с одним order_uid и разной date_created: один заказ на order_uid
+-- обеспечивает только SaveOrder (LockOrderQuery и перенос при смене date_created), запись в обход
+-- него может создать дубликат.
+-- Создаются секции от месяца самого старого заказа (не раньше чем за три года) до трех месяцев вперед
+-- и секции DEFAULT для остальных дат; следующие месяцы заранее создает PartitionManager.
+-- Миграция переписывает все строки четырех таблиц в одной транзакции: на большой базе
+-- ее нужно запускать в окно обслуживания.
+
+CREATE TABLE orders_partitioned (LIKE orders INCLUDING DEFAULTS INCLUDING CONSTRAINTS)
+	PARTITION BY RANGE (date_created);
+CREATE TABLE delivery_partitioned (LIKE delivery INCLUDING DEFAULTS INCLUDING CONSTRAINTS,
+	date_created TIMESTAMP NOT NULL) PARTITION BY RANGE (date_created);
+CREATE TABLE payment_partitioned (LIKE payment INCLUDING DEFAULTS INCLUDING CONSTRAINTS,
+	date_created TIMESTAMP NOT NULL) PARTITION BY RANGE (date_created);
+CREATE TABLE items_partitioned (LIKE items INCLUDING DEFAULTS INCLUDING CONSTRAINTS,
+	date_created TIMESTAMP NOT NULL) PARTITION BY RANGE (date_created);
+
+-- Имена секций совпадают с partitionName: <таблица>_pГГГГ_ММ
+DO $$
+DECLARE
+	m DATE := date_trunc('month', GREATEST(
+		LEAST((SELECT min(date_created) FROM orders), localtimestamp),
+		localtimestamp - interval '3 years'));
+	tbl TEXT;
+BEGIN
+	WHILE m <= date_trunc('month', localtimestamp) + interval '3 months' LOOP
+		FOREACH tbl IN ARRAY ARRAY['orders', 'delivery', 'payment', 'items'] LOOP
+			EXECUTE format('CREATE TABLE %I PARTITION OF %I FOR VALUES FROM (%L) TO (%L)',
+				tbl || '_p' || to_char(m, 'YYYY_MM'), tbl || '_partitioned',
+				m::timestamp, (m + interval '1 month')::timestamp);
+		END LOOP;
+		m := m + interval '1 month';
+	END LOOP;
+END $$;
+
+CREATE TABLE orders_default PARTITION OF orders_partitioned DEFAULT;
+CREATE TABLE delivery_default PARTITION OF delivery_partitioned DEFAULT;
+CREATE TABLE payment_default PARTITION OF payment_partitioned DEFAULT;
+CREATE TABLE items_default PARTITION OF items_partitioned DEFAULT;
+
+INSERT INTO orders_partitioned SELECT * FROM orders;
+INSERT INTO delivery_partitioned SELECT d.*, o.date_created FROM delivery d JOIN orders o ON o.order_uid = d.order_uid;
+INSERT INTO payment_partitioned SELECT p.*, o.date_created FROM payment p JOIN orders o ON o.order_uid = p.order_uid;
+INSERT INTO items_partitioned SELECT i.*, o.date_created FROM items i JOIN orders o ON o.order_uid = i.order_uid;
+
+-- Последовательность id товаров переходит к новой таблице
+ALTER SEQUENCE items_id_seq OWNED BY NONE;
+DROP TABLE items, payment, delivery, orders;
+
+ALTER TABLE orders_partitioned RENAME TO orders;
+ALTER TABLE delivery_partitioned RENAME TO delivery;
+ALTER TABLE payment_partitioned RENAME TO payment;
+ALTER TABLE items_partitioned RENAME TO items;
+ALTER SEQUENCE items_id_seq OWNED BY items.id;
+
+ALTER TABLE orders ADD CONSTRAINT orders_pkey PRIMARY KEY (order_uid, date_created);
+ALTER TABLE delivery
+	ADD CONSTRAINT delivery_pkey PRIMARY KEY (order_uid, date_created),
+	ADD CONSTRAINT delivery_order_fkey FOREIGN KEY (order_uid, date_created)
+		REFERENCES orders (order_uid, date_created) ON DELETE CASCADE;
+ALTER TABLE payment
+	ADD CONSTRAINT payment_pkey PRIMARY KEY (order_uid, date_created),
+	ADD CONSTRAINT payment_order_fkey FOREIGN KEY (order_uid, date_created)
+		REFERENCES orders (order_uid, date_created) ON DELETE CASCADE;
+ALTER TABLE items
+	ADD CONSTRAINT items_pkey PRIMARY KEY (id, date_created),
+	ADD CONSTRAINT items_order_uid_date_created_chrt_id_key UNIQUE (order_uid, date_created, chrt_id),
+	ADD CONSTRAINT items_order_fkey FOREIGN KEY (order_uid, date_created)
+		REFERENCES orders (order_uid, date_created) ON DELETE CASCADE;
+
+-- Индексы прежних таблиц; поиск по order_uid обслуживают первичные ключи
+CREATE INDEX idx_orders_track_number ON orders(track_number);
+CREATE INDEX idx_orders_date_created ON orders(date_created);
+CREATE INDEX idx_orders_customer_id ON orders(customer_id);
+CREATE INDEX idx_orders_date_created_uid ON orders(date_created DESC, order_uid DESC);
+CREATE INDEX idx_delivery_email_lower ON delivery (lower(email));
+CREATE INDEX idx_delivery_phone_digits ON delivery (regexp_replace(phone, '[^0-9+]', '', 'g'));
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"test_service/internal/models"
+	"test_service/internal/retry"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SetOutbox включает запись события в таблицу outbox при каждом сохранении заказа
+// (SaveOrder, SaveOrders, SaveOrdersPartial) в той же транзакции. Без публикатора
+// (internal/outbox) события накапливаются, поэтому по умолчанию запись выключена.
+func (p *Postgres) SetOutbox(enabled bool) {
+	p.save.outbox = enabled
+}
+
+// FetchOutbox возвращает до limit неотправленных событий outbox в порядке id.
+// Строки не блокируются: публикатор должен быть один (выбор лидера).
+func (p *Postgres) FetchOutbox(ctx context.Context, limit int) ([]models.OutboxEvent, error) {
+	var events []models.OutboxEvent
+	err := retry.DoWithContext(ctx, retry.DefaultPolicy(), func(ctx context.Context) error {
+		ctx, cancel := p.readContext(ctx)
+		defer cancel()
+
+		queryStartTime := time.Now()
+		rows, err := p.pool.Query(ctx, SelectOutboxBatchQuery, limit)
+		if err != nil {
+			p.metrics.QueryErrorsTotal.Inc()
+			p.metrics.QueryErrors.WithLabelValues("select_outbox_batch").Inc()
+			return retryTransient(fmt.Errorf("Ошибка выбора событий outbox: %w", err))
+		}
+		events, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.OutboxEvent, error) {
+			var event models.OutboxEvent
+			err := row.Scan(&event.ID, &event.AggregateID, &event.Payload, &event.CreatedAt)
+			return event, err
+		})
+		if err != nil {
+			p.metrics.QueryErrorsTotal.Inc()
+			p.metrics.QueryErrors.WithLabelValues("select_outbox_batch").Inc()
+			return retryTransient(fmt.Errorf("Ошибка чтения событий outbox: %w", err))
+		}
+		p.metrics.QueryDuration.WithLabelValues("select_outbox_batch").Observe(time.Since(queryStartTime).Seconds())
+		return nil
+	})
+	if err != nil {
+		return nil, classify(err)
+	}
+	return events, nil
+}
+
+// MarkOutboxPublished отмечает события ids отправленными. Повторная отметка ничего не меняет.
+func (p *Postgres) MarkOutboxPublished(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	err := retry.DoWithContext(ctx, retry.DefaultPolicy(), func(ctx context.Context) error {
+		ctx, cancel := p.writeContext(ctx)
+		defer cancel()
+
+		queryStartTime := time.Now()
+		if _, err := p.pool.Exec(ctx, MarkOutboxPublishedQuery, ids); err != nil {
+			p.metrics.QueryErrorsTotal.Inc()
+			p.metrics.QueryErrors.WithLabelValues("mark_outbox_published").Inc()
+			return retryTransient(fmt.Errorf("Ошибка отметки событий outbox: %w", err))
+		}
+		p.metrics.QueryDuration.WithLabelValues("mark_outbox_published").Observe(time.Since(queryStartTime).Seconds())
+		return nil
+	})
+	return classify(err)
+}
+
+// OutboxLag возвращает число неотправленных событий outbox и время записи самого старого
+// из них (нулевое, если неотправленных нет)
+func (p *Postgres) OutboxLag(ctx context.Context) (int64, time.Time, error) {
+	var (
+		pending int64
+		oldest  *time.Time
+	)
+	err := retry.DoWithContext(ctx, retry.DefaultPolicy(), func(ctx context.Context) error {
+		ctx, cancel := p.readContext(ctx)
+		defer cancel()
+
+		if err := p.pool.QueryRow(ctx, OutboxLagQuery).Scan(&pending, &oldest); err != nil {
+			p.metrics.QueryErrorsTotal.Inc()
+			p.metrics.QueryErrors.WithLabelValues("outbox_lag").Inc()
+			return retryTransient(fmt.Errorf("Ошибка подсчета событий outbox: %w", err))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, time.Time{}, classify(err)
+	}
+	if oldest == nil {
+		return pending, time.Time{}, nil
+	}
+	return pending, *oldest, nil
+}
@@ -0,0 +1,125 @@
+package database
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"test_service/internal/interfaces"
+	"test_service/internal/models"
+	"test_service/internal/retry"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Размер страницы GetOrdersPage
+const (
+	DefaultPageSize = 100  // Используется при limit <= 0
+	MaxPageSize     = 1000 // Верхняя граница limit
+)
+
+// ErrInvalidCursor курсор страницы поврежден или получен не от GetOrdersPage
+var ErrInvalidCursor = errors.New("некорректный курсор страницы")
+
+// pageCursor ключи последнего заказа страницы; следующая страница начинается после них
+type pageCursor struct {
+	DateCreated time.Time `json:"d"`
+	OrderUID    string    `json:"u"`
+}
+
+// encodeCursor кодирует ключи заказа в непрозрачную для клиента строку
+func encodeCursor(order *models.Order) string {
+	data, _ := json.Marshal(pageCursor{DateCreated: order.DateCreated, OrderUID: order.OrderUID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor разбирает курсор, выданный encodeCursor
+func decodeCursor(cursor string) (pageCursor, error) {
+	var c pageCursor
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return c, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if c.OrderUID == "" || c.DateCreated.IsZero() {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// GetOrdersPage возвращает страницу заказов с товарами, от новых к старым, и курсор следующей
+// страницы (пустой, если страница последняя). Пустой cursor — первая страница.
+// Пагинация по ключу (date_created, order_uid): глубокие страницы не требуют OFFSET,
+// а заказы, добавленные между запросами, не сдвигают уже выданные страницы.
+// limit <= 0 заменяется на DefaultPageSize, limit больше MaxPageSize — на MaxPageSize.
+func (p *Postgres) GetOrdersPage(ctx context.Context, cursor string, limit int, opts ...interfaces.ReadOption) ([]models.Order, string, error) {
+	options := interfaces.ApplyReadOptions(opts)
+	switch {
+	case limit <= 0:
+		limit = DefaultPageSize
+	case limit > MaxPageSize:
+		limit = MaxPageSize
+	}
+
+	// Запрашиваем на один заказ больше, чтобы узнать, есть ли следующая страница
+	query, args := GetOrdersFirstPageQuery, []any{limit + 1, options.IncludeDeleted}
+	if cursor != "" {
+		after, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		query, args = GetOrdersPageAfterQuery, []any{after.DateCreated, after.OrderUID, limit + 1, options.IncludeDeleted}
+	}
+
+	var orders []models.Order
+	var next string
+
+	err := retry.DoWithContext(ctx, retry.DefaultPolicy(), p.readingFrom(options, "get_orders_page", func(ctx context.Context, db *pgxpool.Pool) error {
+		ctx, cancel := p.readContext(ctx)
+		defer cancel()
+
+		queryStartTime := time.Now()
+		rows, err := db.Query(ctx, query, args...)
+		if err != nil {
+			p.metrics.QueryErrorsTotal.Inc()
+			p.metrics.QueryErrors.WithLabelValues("get_orders_page").Inc()
+			return fmt.Errorf("Ошибка при запросе страницы заказов: %w", err)
+		}
+		defer rows.Close()
+
+		orders = make([]models.Order, 0, limit)
+		next = ""
+		for rows.Next() {
+			if len(orders) == limit {
+				next = encodeCursor(&orders[len(orders)-1])
+				break
+			}
+			var order models.Order
+			if err := scanOrder(rows, &order); err != nil {
+				p.metrics.QueryErrorsTotal.Inc()
+				p.metrics.QueryErrors.WithLabelValues("get_orders_page").Inc()
+				return fmt.Errorf("Ошибка при чтении заказа: %w", err)
+			}
+			orders = append(orders, order)
+		}
+		if err := rows.Err(); err != nil {
+			p.metrics.QueryErrorsTotal.Inc()
+			p.metrics.QueryErrors.WithLabelValues("get_orders_page").Inc()
+			return fmt.Errorf("Ошибка перебора заказов: %w", err)
+		}
+		rows.Close()
+		p.metrics.QueryDuration.WithLabelValues("get_orders_page").Observe(time.Since(queryStartTime).Seconds())
+
+		// Товары всех заказов страницы читаем одним запросом
+		return p.loadItems(ctx, db, orders)
+	}))
+	if err != nil {
+		return nil, "", classify(err)
+	}
+	return orders, next, nil
+}
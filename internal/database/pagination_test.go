@@ -0,0 +1,48 @@
+package database
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"test_service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursor_RoundTrip(t *testing.T) {
+	order := &models.Order{
+		OrderUID:    "b563feb7b2b84b6test",
+		DateCreated: time.Date(2021, 11, 26, 6, 22, 19, 123456000, time.UTC),
+	}
+
+	cursor := encodeCursor(order)
+	assert.NotContains(t, cursor, order.OrderUID, "курсор непрозрачен для клиента")
+
+	decoded, err := decodeCursor(cursor)
+	require.NoError(t, err)
+	assert.Equal(t, order.OrderUID, decoded.OrderUID)
+	assert.True(t, order.DateCreated.Equal(decoded.DateCreated), "время сохраняется с точностью до микросекунд")
+}
+
+func TestCursor_Invalid(t *testing.T) {
+	for name, cursor := range map[string]string{
+		"NotBase64":   "%%%",
+		"NotJSON":     base64.RawURLEncoding.EncodeToString([]byte("order-1")),
+		"MissingKeys": base64.RawURLEncoding.EncodeToString([]byte(`{}`)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := decodeCursor(cursor)
+			assert.ErrorIs(t, err, ErrInvalidCursor)
+		})
+	}
+}
+
+func TestGetOrdersPage_InvalidCursor(t *testing.T) {
+	// Курсор проверяется до обращения к БД
+	p := &Postgres{metrics: NewDBMetrics()}
+	_, _, err := p.GetOrdersPage(context.Background(), "not a cursor", 10)
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
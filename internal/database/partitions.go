@@ -0,0 +1,422 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// partitionLockKey ключ advisory-блокировки обслуживания секций: экземпляры сервиса
+// создают и удаляют секции по очереди
+const partitionLockKey int64 = 0x706172746974 // "partit"
+
+// partitionedTables таблицы, секционированные по месяцам date_created заказа (миграция
+// 0009_monthly_partitions), в порядке удаления: секции дочерних таблиц раньше секции заказов,
+// на которую они ссылаются
+var partitionedTables = []string{"items", "delivery", "payment", "orders"}
+
+// SetPartitioning включает необязательные миграции секционирования (migrations/partitioning):
+// Init секционирует orders, delivery, payment и items по месяцам date_created. Вызывается до Init.
+// Выключение не отменяет уже примененную миграцию: режим сохранения выбирается по схеме (Partitioned).
+func (p *Postgres) SetPartitioning(enabled bool) {
+	p.partitioning = enabled
+}
+
+// Partitioned сообщает, секционированы ли таблицы заказов по месяцам; известно после Init
+func (p *Postgres) Partitioned() bool {
+	return p.save.partitioned
+}
+
+// detectPartitioning выбирает запросы сохранения по схеме: секционированы ли таблицы заказов
+func (p *Postgres) detectPartitioning(ctx context.Context) error {
+	queryStartTime := time.Now()
+	var partitioned bool
+	if err := p.pool.QueryRow(ctx, OrdersPartitionedQuery).Scan(&partitioned); err != nil {
+		p.metrics.QueryErrorsTotal.Inc()
+		p.metrics.QueryErrors.WithLabelValues("init_check_partitioning").Inc()
+		return fmt.Errorf("Ошибка проверки секционирования заказов: %w", err)
+	}
+	p.metrics.QueryDuration.WithLabelValues("init_check_partitioning").Observe(time.Since(queryStartTime).Seconds())
+
+	if partitioned && !p.partitioning {
+		log.Println("Таблицы заказов уже секционированы по месяцам: секционирование остается включенным")
+	}
+	p.save.partitioned = partitioned
+	return nil
+}
+
+// partitionMonthLayout формат месяца в имени секции
+const partitionMonthLayout = "2006_01"
+
+// monthStart начало месяца t (UTC)
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// partitionName имя секции таблицы table за месяц month: <таблица>_pГГГГ_ММ
+func partitionName(table string, month time.Time) string {
+	return table + "_p" + month.Format(partitionMonthLayout)
+}
+
+// partitionMonth месяц секции name таблицы table; false — не месячная секция (например, DEFAULT)
+func partitionMonth(table, name string) (time.Time, bool) {
+	suffix, ok := strings.CutPrefix(name, table+"_p")
+	if !ok {
+		return time.Time{}, false
+	}
+	month, err := time.Parse(partitionMonthLayout, suffix)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return month, true
+}
+
+// MonthlyPartitions возвращает месяцы секций таблицы table по возрастанию (без секции DEFAULT)
+func (p *Postgres) MonthlyPartitions(ctx context.Context, table string) ([]time.Time, error) {
+	ctx, cancel := p.readContext(ctx)
+	defer cancel()
+	return p.monthlyPartitions(ctx, p.pool, table)
+}
+
+// queryer общая часть пула и транзакции, нужная для чтения списка секций
+type queryer interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+func (p *Postgres) monthlyPartitions(ctx context.Context, q queryer, table string) ([]time.Time, error) {
+	queryStartTime := time.Now()
+	rows, err := q.Query(ctx, ListPartitionsQuery, table)
+	if err != nil {
+		p.metrics.QueryErrorsTotal.Inc()
+		p.metrics.QueryErrors.WithLabelValues("list_partitions").Inc()
+		return nil, classify(fmt.Errorf("Ошибка получения секций таблицы %s: %w", table, err))
+	}
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		p.metrics.QueryErrorsTotal.Inc()
+		p.metrics.QueryErrors.WithLabelValues("list_partitions").Inc()
+		return nil, classify(fmt.Errorf("Ошибка чтения секций таблицы %s: %w", table, err))
+	}
+	p.metrics.QueryDuration.WithLabelValues("list_partitions").Observe(time.Since(queryStartTime).Seconds())
+
+	months := make([]time.Time, 0, len(names))
+	for _, name := range names {
+		if month, ok := partitionMonth(table, name); ok {
+			months = append(months, month)
+		}
+	}
+	sort.Slice(months, func(i, j int) bool { return months[i].Before(months[j]) })
+	return months, nil
+}
+
+// CreateMonthlyPartitions создает недостающие секции всех секционированных таблиц за месяцы
+// с from по to включительно и возвращает число месяцев, для которых создана секция заказов.
+// Каждый месяц создается в своей транзакции: ошибка одного месяца не мешает создать остальные,
+// ошибки всех месяцев возвращаются вместе. Строки месяца, уже попавшие в секции DEFAULT,
+// переносятся в новые секции (createMonthlyPartition).
+func (p *Postgres) CreateMonthlyPartitions(ctx context.Context, from, to time.Time) (int, error) {
+	ctx = withOperation(ctx, "create_partitions", "")
+
+	created := 0
+	var errs []error
+	for month := monthStart(from); !month.After(monthStart(to)); month = month.AddDate(0, 1, 0) {
+		ok, err := p.createMonthlyPartition(ctx, month)
+		if err != nil {
+			p.metrics.QueryErrorsTotal.Inc()
+			p.metrics.QueryErrors.WithLabelValues("create_partitions").Inc()
+			errs = append(errs, classify(err))
+			continue
+		}
+		if ok {
+			created++
+			p.metrics.PartitionsCreatedTotal.Inc()
+		}
+	}
+	return created, errors.Join(errs...)
+}
+
+// createMonthlyPartition создает недостающие секции месяца month и сообщает, создана ли секция заказов.
+// Секцию нельзя создать, пока в секции DEFAULT есть строки ее диапазона (заказы с date_created вне
+// созданных ранее секций). Такие строки всех четырех таблиц за месяц переносятся через временные
+// таблицы: запись в таблицы заказов на время переноса блокируется, а удаление заказов каскадно
+// удаляет строки дочерних таблиц, которые затем вставляются обратно уже в новые секции.
+func (p *Postgres) createMonthlyPartition(ctx context.Context, month time.Time) (bool, error) {
+	ctx, cancel := p.writeContext(ctx)
+	defer cancel()
+
+	queryStartTime := time.Now()
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		p.metrics.TransactionErrorsTotal.Inc()
+		return false, fmt.Errorf("Ошибка начала транзакции создания секций: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, PartitionXactLockQuery, partitionLockKey); err != nil {
+		return false, fmt.Errorf("Ошибка блокировки обслуживания секций: %w", err)
+	}
+
+	from, to := month, month.AddDate(0, 1, 0)
+	var missing []string
+	stranded := 0 // Строк месяца в секциях DEFAULT таблиц без секции месяца
+	for _, table := range partitionedTables {
+		var exists bool
+		if err := tx.QueryRow(ctx, PartitionExistsQuery, partitionName(table, month)).Scan(&exists); err != nil {
+			return false, fmt.Errorf("Ошибка проверки секции %s: %w", partitionName(table, month), err)
+		}
+		if exists {
+			continue
+		}
+		missing = append(missing, table)
+
+		var n int
+		sql := fmt.Sprintf("SELECT count(*) FROM %s WHERE date_created >= $1 AND date_created < $2",
+			pgx.Identifier{table + "_default"}.Sanitize())
+		if err := tx.QueryRow(ctx, sql, from, to).Scan(&n); err != nil {
+			return false, fmt.Errorf("Ошибка проверки секции %s_default: %w", table, err)
+		}
+		stranded += n
+	}
+	if len(missing) == 0 {
+		return false, nil
+	}
+
+	var moved int64
+	if stranded > 0 {
+		if moved, err = stashMonthRows(ctx, tx, from, to); err != nil {
+			return false, err
+		}
+	}
+
+	for _, table := range missing {
+		sql := fmt.Sprintf("CREATE TABLE %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')",
+			pgx.Identifier{partitionName(table, month)}.Sanitize(), pgx.Identifier{table}.Sanitize(),
+			from.Format(time.DateTime), to.Format(time.DateTime))
+		if _, err := tx.Exec(ctx, sql); err != nil {
+			return false, fmt.Errorf("Ошибка создания секции %s: %w", partitionName(table, month), err)
+		}
+	}
+
+	if stranded > 0 {
+		// Заказы раньше дочерних строк: внешние ключи проверяются при вставке
+		for i := len(partitionedTables) - 1; i >= 0; i-- {
+			table := partitionedTables[i]
+			sql := fmt.Sprintf("INSERT INTO %s SELECT * FROM %s",
+				pgx.Identifier{table}.Sanitize(), pgx.Identifier{"moving_" + table}.Sanitize())
+			if _, err := tx.Exec(ctx, sql); err != nil {
+				return false, fmt.Errorf("Ошибка переноса строк в секцию %s: %w", partitionName(table, month), err)
+			}
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		p.metrics.TransactionErrorsTotal.Inc()
+		return false, fmt.Errorf("Ошибка коммита транзакции создания секций: %w", err)
+	}
+	p.metrics.QueryDuration.WithLabelValues("create_partitions").Observe(time.Since(queryStartTime).Seconds())
+	if moved > 0 {
+		log.Printf("Заказы за %s перенесены из секций DEFAULT в новые секции: %d", month.Format("2006-01"), moved)
+	}
+	return slices.Contains(missing, "orders"), nil
+}
+
+// stashMonthRows блокирует запись в секционированные таблицы до конца транзакции tx, копирует
+// их строки с date_created в [from, to) во временные таблицы moving_<таблица> и удаляет заказы
+// этого диапазона (дочерние строки удаляются каскадно). Возвращает число перенесенных заказов.
+func stashMonthRows(ctx context.Context, tx pgx.Tx, from, to time.Time) (int64, error) {
+	if _, err := tx.Exec(ctx, "LOCK TABLE orders, delivery, payment, items IN EXCLUSIVE MODE"); err != nil {
+		return 0, fmt.Errorf("Ошибка блокировки таблиц заказов для переноса из секций DEFAULT: %w", err)
+	}
+	for _, table := range partitionedTables {
+		moving, name := pgx.Identifier{"moving_" + table}.Sanitize(), pgx.Identifier{table}.Sanitize()
+		if _, err := tx.Exec(ctx, fmt.Sprintf("CREATE TEMP TABLE %s (LIKE %s) ON COMMIT DROP", moving, name)); err != nil {
+			return 0, fmt.Errorf("Ошибка создания временной таблицы %s: %w", moving, err)
+		}
+		sql := fmt.Sprintf("INSERT INTO %s SELECT * FROM %s WHERE date_created >= $1 AND date_created < $2", moving, name)
+		if _, err := tx.Exec(ctx, sql, from, to); err != nil {
+			return 0, fmt.Errorf("Ошибка копирования строк %s для переноса из секций DEFAULT: %w", table, err)
+		}
+	}
+	tag, err := tx.Exec(ctx, "DELETE FROM orders WHERE date_created >= $1 AND date_created < $2", from, to)
+	if err != nil {
+		return 0, fmt.Errorf("Ошибка удаления заказов для переноса из секций DEFAULT: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// DropMonthlyPartitionsBefore удаляет секции всех секционированных таблиц за месяцы, целиком
+// лежащие раньше cutoff, вместе с заказами, доставкой, платежами и товарами в них, и возвращает
+// число удаленных месяцев. Каждый месяц удаляется в своей транзакции: секция заказов сначала
+// отсоединяется (DETACH), потому что на нее ссылаются внешние ключи дочерних таблиц.
+// Возвращает число удаленных месяцев и при ошибке: уже удаленные месяцы не восстанавливаются.
+func (p *Postgres) DropMonthlyPartitionsBefore(ctx context.Context, cutoff time.Time) (int, error) {
+	ctx = withOperation(ctx, "drop_partitions", "")
+	months, err := p.MonthlyPartitions(ctx, "orders")
+	if err != nil {
+		return 0, err
+	}
+
+	dropped := 0
+	for _, month := range months {
+		if month.AddDate(0, 1, 0).After(cutoff) {
+			break
+		}
+		if err := p.dropMonthlyPartition(ctx, month); err != nil {
+			p.metrics.QueryErrorsTotal.Inc()
+			p.metrics.QueryErrors.WithLabelValues("drop_partitions").Inc()
+			return dropped, classify(err)
+		}
+		dropped++
+		p.metrics.PartitionsDroppedTotal.Inc()
+	}
+	return dropped, nil
+}
+
+// dropMonthlyPartition удаляет секции месяца month всех секционированных таблиц
+func (p *Postgres) dropMonthlyPartition(ctx context.Context, month time.Time) error {
+	ctx, cancel := p.writeContext(ctx)
+	defer cancel()
+
+	queryStartTime := time.Now()
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		p.metrics.TransactionErrorsTotal.Inc()
+		return fmt.Errorf("Ошибка начала транзакции удаления секций: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, PartitionXactLockQuery, partitionLockKey); err != nil {
+		return fmt.Errorf("Ошибка блокировки обслуживания секций: %w", err)
+	}
+
+	for _, table := range partitionedTables {
+		name := pgx.Identifier{partitionName(table, month)}.Sanitize()
+		if table == "orders" {
+			sql := fmt.Sprintf("ALTER TABLE orders DETACH PARTITION %s", name)
+			if _, err := tx.Exec(ctx, sql); err != nil {
+				return fmt.Errorf("Ошибка отсоединения секции %s: %w", partitionName(table, month), err)
+			}
+		}
+		if _, err := tx.Exec(ctx, "DROP TABLE IF EXISTS "+name); err != nil {
+			return fmt.Errorf("Ошибка удаления секции %s: %w", partitionName(table, month), err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		p.metrics.TransactionErrorsTotal.Inc()
+		return fmt.Errorf("Ошибка коммита транзакции удаления секций: %w", err)
+	}
+	p.metrics.QueryDuration.WithLabelValues("drop_partitions").Observe(time.Since(queryStartTime).Seconds())
+	return nil
+}
+
+// Параметры обслуживания секций по умолчанию
+const (
+	DefaultPartitionsAhead   = 3         // Месяцев вперед, для которых секции создаются заранее
+	DefaultPartitionInterval = time.Hour // Используется при Interval <= 0
+)
+
+// PartitionConfig параметры PartitionManager
+type PartitionConfig struct {
+	Ahead     int           // Сколько месяцев после текущего держать созданными заранее
+	Retention time.Duration // Срок хранения: месяцы старше удаляются целиком (0 — не удалять)
+	Interval  time.Duration // Период обслуживания
+}
+
+// partitionStore операции с секциями, нужные PartitionManager (*Postgres)
+type partitionStore interface {
+	MonthlyPartitions(ctx context.Context, table string) ([]time.Time, error)
+	CreateMonthlyPartitions(ctx context.Context, from, to time.Time) (int, error)
+	DropMonthlyPartitionsBefore(ctx context.Context, cutoff time.Time) (int, error)
+}
+
+// PartitionManager обслуживает месячные секции заказов: заранее создает секции текущего
+// и Ahead следующих месяцев и удаляет секции старше срока хранения. Удаление секции —
+// DROP TABLE, а не DELETE по строкам, поэтому его цена не зависит от числа заказов в месяце.
+type PartitionManager struct {
+	store     partitionStore
+	cfg       PartitionConfig
+	metrics   *DBMetrics
+	onDropped func(cutoff time.Time) // Вызывается после удаления секций с началом первого оставшегося месяца
+	now       func() time.Time       // Часы; подменяются в тестах
+}
+
+// NewPartitionManager создает обслуживание секций db; cfg.Interval <= 0 заменяется
+// DefaultPartitionInterval
+func NewPartitionManager(db *Postgres, cfg PartitionConfig) *PartitionManager {
+	return newPartitionManager(db, cfg)
+}
+
+func newPartitionManager(store partitionStore, cfg PartitionConfig) *PartitionManager {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultPartitionInterval
+	}
+	return &PartitionManager{
+		store:   store,
+		cfg:     cfg,
+		metrics: NewDBMetrics(),
+		now:     time.Now,
+	}
+}
+
+// SetOnDropped задает обработчик удаления секций (например, очистку кэша от удаленных заказов);
+// вызывается до Run
+func (m *PartitionManager) SetOnDropped(fn func(cutoff time.Time)) {
+	m.onDropped = fn
+}
+
+// Maintain создает недостающие секции с текущего месяца на Ahead месяцев вперед и, если задан
+// срок хранения, удаляет секции, целиком лежащие раньше now-Retention. Ошибка создания секций
+// не мешает удалению: возвращаются ошибки обоих шагов.
+func (m *PartitionManager) Maintain(ctx context.Context) error {
+	now := m.now()
+	created, createErr := m.store.CreateMonthlyPartitions(ctx, monthStart(now), monthStart(now).AddDate(0, m.cfg.Ahead, 0))
+	if created > 0 {
+		log.Printf("Создано месячных секций заказов: %d", created)
+	}
+
+	var dropErr error
+	if m.cfg.Retention > 0 {
+		cutoff := now.Add(-m.cfg.Retention)
+		var dropped int
+		dropped, dropErr = m.store.DropMonthlyPartitionsBefore(ctx, cutoff)
+		if dropped > 0 {
+			log.Printf("Удалено месячных секций заказов, созданных до %s: %d",
+				monthStart(cutoff).Format(time.DateOnly), dropped)
+			if m.onDropped != nil {
+				m.onDropped(monthStart(cutoff))
+			}
+		}
+	}
+
+	months, err := m.store.MonthlyPartitions(ctx, "orders")
+	if err == nil {
+		m.metrics.Partitions.Set(float64(len(months)))
+	}
+	return errors.Join(createErr, dropErr, err)
+}
+
+// Run обслуживает секции сразу и затем каждые Interval до отмены ctx
+func (m *PartitionManager) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.Maintain(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Ошибка обслуживания секций заказов: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartitionName(t *testing.T) {
+	month := monthStart(time.Date(2024, time.March, 31, 23, 59, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC), month)
+	assert.Equal(t, "orders_p2024_03", partitionName("orders", month))
+
+	got, ok := partitionMonth("orders", "orders_p2024_03")
+	require.True(t, ok)
+	assert.Equal(t, month, got)
+
+	for _, name := range []string{"orders_default", "items_p2024_03", "orders_p2024_13", "orders_p2024"} {
+		_, ok := partitionMonth("orders", name)
+		assert.False(t, ok, name)
+	}
+}
+
+// fakePartitionStore запоминает вызовы PartitionManager
+type fakePartitionStore struct {
+	months      []time.Time
+	createFrom  time.Time
+	createTo    time.Time
+	created     int
+	createErr   error
+	dropCutoffs []time.Time
+	dropped     int
+	dropErr     error
+}
+
+func (s *fakePartitionStore) MonthlyPartitions(context.Context, string) ([]time.Time, error) {
+	return s.months, nil
+}
+
+func (s *fakePartitionStore) CreateMonthlyPartitions(_ context.Context, from, to time.Time) (int, error) {
+	s.createFrom, s.createTo = from, to
+	return s.created, s.createErr
+}
+
+func (s *fakePartitionStore) DropMonthlyPartitionsBefore(_ context.Context, cutoff time.Time) (int, error) {
+	s.dropCutoffs = append(s.dropCutoffs, cutoff)
+	return s.dropped, s.dropErr
+}
+
+func TestPartitionManager_Maintain(t *testing.T) {
+	now := time.Date(2024, time.November, 15, 10, 0, 0, 0, time.UTC)
+	newManager := func(store *fakePartitionStore, cfg PartitionConfig) *PartitionManager {
+		m := newPartitionManager(store, cfg)
+		m.now = func() time.Time { return now }
+		return m
+	}
+
+	t.Run("CreatesAheadAndDropsExpired", func(t *testing.T) {
+		store := &fakePartitionStore{dropped: 2, months: make([]time.Time, 5)}
+		m := newManager(store, PartitionConfig{Ahead: 3, Retention: 90 * 24 * time.Hour})
+		var evictedBefore []time.Time
+		m.SetOnDropped(func(cutoff time.Time) { evictedBefore = append(evictedBefore, cutoff) })
+
+		require.NoError(t, m.Maintain(context.Background()))
+		assert.Equal(t, time.Date(2024, time.November, 1, 0, 0, 0, 0, time.UTC), store.createFrom)
+		assert.Equal(t, time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC), store.createTo, "секции переходят через год")
+		assert.Equal(t, []time.Time{now.Add(-90 * 24 * time.Hour)}, store.dropCutoffs)
+		assert.Equal(t, []time.Time{time.Date(2024, time.August, 1, 0, 0, 0, 0, time.UTC)}, evictedBefore,
+			"из кэша удаляются заказы до начала первого оставшегося месяца")
+		assert.Equal(t, float64(5), testutil.ToFloat64(m.metrics.Partitions))
+	})
+
+	t.Run("NothingDropped", func(t *testing.T) {
+		store := &fakePartitionStore{}
+		m := newManager(store, PartitionConfig{Ahead: 1, Retention: time.Hour})
+		m.SetOnDropped(func(time.Time) { t.Error("без удаленных секций кэш не очищается") })
+
+		require.NoError(t, m.Maintain(context.Background()))
+		assert.Len(t, store.dropCutoffs, 1)
+	})
+
+	t.Run("NoRetention", func(t *testing.T) {
+		store := &fakePartitionStore{}
+		m := newManager(store, PartitionConfig{Ahead: 0})
+
+		require.NoError(t, m.Maintain(context.Background()))
+		assert.Equal(t, store.createFrom, store.createTo, "создается только текущий месяц")
+		assert.Empty(t, store.dropCutoffs, "без срока хранения секции не удаляются")
+	})
+
+	t.Run("CreateError", func(t *testing.T) {
+		store := &fakePartitionStore{created: 2, createErr: errors.New("lock timeout"), months: make([]time.Time, 4)}
+		m := newManager(store, PartitionConfig{Ahead: 3, Retention: time.Hour})
+
+		// Месяц, который не удалось создать, не мешает удалению по сроку хранения и метрике
+		assert.ErrorIs(t, m.Maintain(context.Background()), store.createErr)
+		assert.Len(t, store.dropCutoffs, 1)
+		assert.Equal(t, float64(4), testutil.ToFloat64(m.metrics.Partitions))
+	})
+
+	t.Run("CreateAndDropErrors", func(t *testing.T) {
+		store := &fakePartitionStore{createErr: errors.New("create"), dropErr: errors.New("drop")}
+		m := newManager(store, PartitionConfig{Ahead: 1, Retention: time.Hour})
+
+		err := m.Maintain(context.Background())
+		assert.ErrorIs(t, err, store.createErr)
+		assert.ErrorIs(t, err, store.dropErr)
+	})
+
+	t.Run("DropErrorAfterPartialDrop", func(t *testing.T) {
+		store := &fakePartitionStore{dropped: 1, dropErr: errors.New("lock timeout")}
+		m := newManager(store, PartitionConfig{Ahead: 3, Retention: time.Hour})
+		evicted := false
+		m.SetOnDropped(func(time.Time) { evicted = true })
+
+		assert.ErrorIs(t, m.Maintain(context.Background()), store.dropErr)
+		assert.True(t, evicted, "удаленные до ошибки месяцы удаляются и из кэша")
+	})
+}
+
+func TestNewPartitionManager_DefaultInterval(t *testing.T) {
+	m := newPartitionManager(&fakePartitionStore{}, PartitionConfig{})
+	assert.Equal(t, DefaultPartitionInterval, m.cfg.Interval)
+}
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// queryExecModes режимы выполнения запросов по имени, как default_query_exec_mode в строке подключения
+var queryExecModes = map[string]pgx.QueryExecMode{
+	"cache_statement": pgx.QueryExecModeCacheStatement, // Подготовленные выражения кэшируются на соединении
+	"cache_describe":  pgx.QueryExecModeCacheDescribe,  // Кэшируется описание параметров и результата
+	"describe_exec":   pgx.QueryExecModeDescribeExec,   // Описание запрашивается перед каждым выполнением
+	"exec":            pgx.QueryExecModeExec,           // Без подготовки и кэша, совместим с PgBouncer
+	"simple_protocol": pgx.QueryExecModeSimpleProtocol, // Простой протокол, параметры подставляет клиент
+}
+
+// ParseQueryExecMode возвращает режим выполнения запросов pgx по имени
+// (cache_statement, cache_describe, describe_exec, exec, simple_protocol)
+func ParseQueryExecMode(name string) (pgx.QueryExecMode, error) {
+	mode, ok := queryExecModes[name]
+	if !ok {
+		return 0, fmt.Errorf("неизвестный режим выполнения запросов %q", name)
+	}
+	return mode, nil
+}
+
+// PoolConfig настройки пула соединений; нулевые значения оставляют умолчания pgxpool
+type PoolConfig struct {
+	MaxConns          int32         // Максимум соединений пула (на под)
+	MinConns          int32         // Минимум открытых соединений
+	MaxConnLifetime   time.Duration // Время жизни соединения, после которого оно закрывается
+	MaxConnIdleTime   time.Duration // Время простоя, после которого соединение закрывается
+	HealthCheckPeriod time.Duration // Период проверки простаивающих соединений
+
+	// Режим выполнения запросов (см. ParseQueryExecMode); пустой — из строки подключения,
+	// по умолчанию cache_statement
+	QueryExecMode string
+
+	// Трассировка запросов: спан OpenTelemetry на каждый запрос и пакет запросов (nil — без трассировки)
+	Tracer trace.Tracer
+}
+
+// apply переносит заданные настройки в разобранную конфигурацию пула
+func (c PoolConfig) apply(config *pgxpool.Config) error {
+	if c.MaxConns > 0 {
+		config.MaxConns = c.MaxConns
+	}
+	if c.MinConns > 0 {
+		config.MinConns = c.MinConns
+	}
+	if c.MaxConnLifetime > 0 {
+		config.MaxConnLifetime = c.MaxConnLifetime
+	}
+	if c.MaxConnIdleTime > 0 {
+		config.MaxConnIdleTime = c.MaxConnIdleTime
+	}
+	if c.HealthCheckPeriod > 0 {
+		config.HealthCheckPeriod = c.HealthCheckPeriod
+	}
+	if c.QueryExecMode != "" {
+		mode, err := ParseQueryExecMode(c.QueryExecMode)
+		if err != nil {
+			return err
+		}
+		config.ConnConfig.DefaultQueryExecMode = mode
+	}
+	if c.Tracer != nil {
+		config.ConnConfig.Tracer = &queryTracer{tracer: c.Tracer}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"time"
+
+	"test_service/internal/interfaces"
+)
+
+// poolStatsInterval период переноса статистики пула в метрики
+const poolStatsInterval = 15 * time.Second
+
+// Stats возвращает снимок статистики пула соединений основного сервера (без реплики)
+func (p *Postgres) Stats() interfaces.PoolStats {
+	stat := p.pool.Stat()
+	return interfaces.PoolStats{
+		AcquiredConns:     stat.AcquiredConns(),
+		IdleConns:         stat.IdleConns(),
+		TotalConns:        stat.TotalConns(),
+		MaxConns:          stat.MaxConns(),
+		AcquireCount:      stat.AcquireCount(),
+		EmptyAcquireCount: stat.EmptyAcquireCount(),
+	}
+}
+
+// poolStats статистика пула соединений; *pgxpool.Stat в работе, подмена в тестах
+type poolStats interface {
+	AcquiredConns() int32
+	AcquireCount() int64
+	AcquireDuration() time.Duration
+	EmptyAcquireCount() int64
+	CanceledAcquireCount() int64
+}
+
+// poolStatsCollector переносит статистику пула в метрики. Счетчики пула накопительные
+// с момента его создания, поэтому метрики увеличиваются на прирост с предыдущего снятия.
+type poolStatsCollector struct {
+	metrics *DBMetrics
+
+	// Значения счетчиков пула при предыдущем снятии
+	acquires        int64
+	acquireDuration time.Duration
+	emptyAcquires   int64
+	canceled        int64
+}
+
+// collect снимает статистику пула и добавляет прирост счетчиков к метрикам
+func (c *poolStatsCollector) collect(stat poolStats) {
+	c.metrics.ConnectionOpen.Set(float64(stat.AcquiredConns()))
+
+	acquires := stat.AcquireCount()
+	c.metrics.ConnectionAcquireCount.Add(float64(counterDelta(acquires, c.acquires)))
+	c.acquires = acquires
+
+	acquireDuration := stat.AcquireDuration()
+	c.metrics.ConnectionAcquireDuration.Add(time.Duration(counterDelta(int64(acquireDuration), int64(c.acquireDuration))).Seconds())
+	c.acquireDuration = acquireDuration
+
+	emptyAcquires := stat.EmptyAcquireCount()
+	c.metrics.ConnectionEmptyAcquireCount.Add(float64(counterDelta(emptyAcquires, c.emptyAcquires)))
+	c.emptyAcquires = emptyAcquires
+
+	canceled := stat.CanceledAcquireCount()
+	c.metrics.ConnectionCanceledAcquireCount.Add(float64(counterDelta(canceled, c.canceled)))
+	c.canceled = canceled
+}
+
+// counterDelta прирост накопительного счетчика; если счетчик уменьшился (пул пересоздан),
+// весь текущий счет считается приростом
+func counterDelta(current, previous int64) int64 {
+	if current < previous {
+		return current
+	}
+	return current - previous
+}
+
+// run переносит статистику пула в метрики каждые interval до закрытия stop
+func (c *poolStatsCollector) run(stat func() poolStats, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			c.collect(stat()) // Последнее снятие, чтобы не потерять прирост с предыдущего
+			return
+		case <-ticker.C:
+			c.collect(stat())
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakePoolStats статистика пула, задаваемая тестом
+type fakePoolStats struct {
+	acquired        int32
+	acquires        int64
+	acquireDuration time.Duration
+	emptyAcquires   int64
+	canceled        int64
+}
+
+func (s *fakePoolStats) AcquiredConns() int32           { return s.acquired }
+func (s *fakePoolStats) AcquireCount() int64            { return s.acquires }
+func (s *fakePoolStats) AcquireDuration() time.Duration { return s.acquireDuration }
+func (s *fakePoolStats) EmptyAcquireCount() int64       { return s.emptyAcquires }
+func (s *fakePoolStats) CanceledAcquireCount() int64    { return s.canceled }
+
+// acquireMetrics текущие значения счетчиков получения соединений
+func acquireMetrics(m *DBMetrics) [4]float64 {
+	return [4]float64{
+		testutil.ToFloat64(m.ConnectionAcquireCount),
+		testutil.ToFloat64(m.ConnectionAcquireDuration),
+		testutil.ToFloat64(m.ConnectionEmptyAcquireCount),
+		testutil.ToFloat64(m.ConnectionCanceledAcquireCount),
+	}
+}
+
+// grown прирост счетчиков относительно before
+func grown(m *DBMetrics, before [4]float64) [4]float64 {
+	after := acquireMetrics(m)
+	for i := range after {
+		after[i] -= before[i]
+	}
+	return after
+}
+
+func TestPoolStatsCollector_Deltas(t *testing.T) {
+	metrics := NewDBMetrics()
+	c := &poolStatsCollector{metrics: metrics}
+	stats := &fakePoolStats{acquired: 3, acquires: 10, acquireDuration: 2 * time.Second, emptyAcquires: 4, canceled: 1}
+
+	before := acquireMetrics(metrics)
+	c.collect(stats)
+	assert.Equal(t, [4]float64{10, 2, 4, 1}, grown(metrics, before), "первое снятие переносит весь накопленный счет")
+	assert.Equal(t, float64(3), testutil.ToFloat64(metrics.ConnectionOpen))
+
+	before = acquireMetrics(metrics)
+	c.collect(stats)
+	assert.Equal(t, [4]float64{}, grown(metrics, before), "без новых получений счетчики не растут")
+
+	stats.acquired, stats.acquires, stats.acquireDuration, stats.emptyAcquires = 1, 15, 2500*time.Millisecond, 6
+	before = acquireMetrics(metrics)
+	c.collect(stats)
+	assert.Equal(t, [4]float64{5, 0.5, 2, 0}, grown(metrics, before))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.ConnectionOpen))
+}
+
+func TestPoolStatsCollector_Reset(t *testing.T) {
+	metrics := NewDBMetrics()
+	c := &poolStatsCollector{metrics: metrics}
+	c.collect(&fakePoolStats{acquires: 100, acquireDuration: time.Second, emptyAcquires: 10, canceled: 5})
+
+	// Счетчики пула уменьшились — пул пересоздан, текущий счет целиком новый
+	before := acquireMetrics(metrics)
+	c.collect(&fakePoolStats{acquires: 7, acquireDuration: 250 * time.Millisecond, emptyAcquires: 2, canceled: 1})
+	assert.Equal(t, [4]float64{7, 0.25, 2, 1}, grown(metrics, before))
+}
+
+func TestPoolStatsCollector_RunStops(t *testing.T) {
+	c := &poolStatsCollector{metrics: NewDBMetrics()}
+	stats := &fakePoolStats{acquires: 1}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		c.run(func() poolStats { return stats }, time.Hour, stop)
+		close(done)
+	}()
+
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("сбор статистики не остановился")
+	}
+	assert.Equal(t, int64(1), c.acquires, "при остановке выполняется последнее снятие")
+}
+
+func TestPostgres_Stats(t *testing.T) {
+	pool := newUnreachablePool(t)
+	p := &Postgres{pool: pool, metrics: NewDBMetrics()}
+
+	stats := p.Stats()
+	assert.Equal(t, pool.Config().MaxConns, stats.MaxConns)
+	assert.Zero(t, stats.AcquiredConns, "соединения не открывались")
+	assert.Zero(t, stats.TotalConns)
+	assert.Zero(t, stats.AcquireCount)
+}
@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"sync"
+	"test_service/internal/interfaces"
 	"test_service/internal/models"
 	"test_service/internal/retry"
 	"time"
@@ -16,19 +18,45 @@ import (
 
 // Postgres представляет подключение к базе данных PostgreSQL
 type Postgres struct {
-	pool    *pgxpool.Pool // Пул соединений с базой данных
-	metrics *DBMetrics    // Метрики для мониторинга
+	pool     *pgxpool.Pool // Пул соединений с базой данных
+	metrics  *DBMetrics    // Метрики для мониторинга
+	timeouts QueryTimeouts // Ограничения времени одной попытки операции
+	saveTx   pgx.TxOptions // Параметры транзакции SaveOrder (уровень изоляции)
+	save     saveOptions   // Параметры сохранения заказов (SetOutbox, SetMultiRowItems)
+
+	partitioning bool // Применять необязательные миграции секционирования (SetPartitioning)
+
+	replica *replica // Реплика для чтения (nil — чтение с основного сервера)
+
+	stop      chan struct{} // Закрывается в Close, останавливает сбор статистики пула и проверку реплики
+	closeOnce sync.Once
+}
+
+// NewPostgres создает новое подключение к базе данных PostgreSQL.
+// opts задает ограничения пула соединений поверх параметров строки подключения.
+func NewPostgres(ctx context.Context, connectStr string, opts PoolConfig) (*Postgres, error) {
+	return NewPostgresWithReplica(ctx, connectStr, "", opts)
+}
+
+// NewPostgresWithReplica создает подключение к основному серверу и, если readConnectStr не пуст,
+// пул реплики для операций чтения (заказ, все заказы, страницы и выборки заказов, подсчеты).
+// Запись всегда идет на основной сервер. Недоступная реплика не мешает запуску:
+// чтение идет на основной сервер, пока реплика не ответит на проверку.
+// Пароли из строк подключения в тексты ошибок и логи не попадают (RedactDSN).
+func NewPostgresWithReplica(ctx context.Context, connectStr, readConnectStr string, opts PoolConfig) (*Postgres, error) {
+	passwords := append(dsnPasswords(connectStr), dsnPasswords(readConnectStr)...)
+	p, err := newPostgres(ctx, connectStr, readConnectStr, opts, passwords)
+	return p, redactError(err, passwords...)
 }
 
-// NewPostgres создает новое подключение к базе данных PostgreSQL
-func NewPostgres(ctx context.Context, connectStr string) (*Postgres, error) {
+// newPostgres создает подключение для NewPostgresWithReplica; passwords вырезаются из логов
+func newPostgres(ctx context.Context, connectStr, readConnectStr string, opts PoolConfig, passwords []string) (*Postgres, error) {
 	// Засекаем время установления подключения
 	startTime := time.Now()
 
-	// Парсим строку подключения
-	config, err := pgxpool.ParseConfig(connectStr)
+	config, err := poolConfig(connectStr, opts)
 	if err != nil {
-		return nil, fmt.Errorf("Ошибка при анализе строки для подключения:%v", err)
+		return nil, err
 	}
 
 	// Создаем пул соединений
@@ -43,30 +71,62 @@ func NewPostgres(ctx context.Context, connectStr string) (*Postgres, error) {
 		return nil, fmt.Errorf("Ошибка соединения с БД:%v", err)
 	}
 
-	// Инициализируем метрики
-	metrics := NewDBMetrics()
+	p := NewPostgresFromPool(pool)
 
-	// Запускаем сбор метрик пула соединений в отдельной горутине
-	go func() {
-		ticker := time.NewTicker(15 * time.Second) // Обновляем каждые 15 секунд
-		defer ticker.Stop()
-		for range ticker.C {
-			if pool == nil {
-				return // Пул закрыт
-			}
-			connStats := pool.Stat()
-			metrics.ConnectionOpen.Set(float64(connStats.AcquiredConns()))
-			metrics.ConnectionMaxOpen.Set(float64(connStats.MaxConns()))
+	if readConnectStr != "" {
+		replicaConfig, err := poolConfig(readConnectStr, opts)
+		if err != nil {
+			pool.Close()
+			return nil, fmt.Errorf("Реплика: %w", err)
 		}
-	}()
+		replicaPool, err := pgxpool.NewWithConfig(ctx, replicaConfig)
+		if err != nil {
+			pool.Close()
+			return nil, fmt.Errorf("Ошибка при создании подключения к реплике:%v", err)
+		}
+		p.replica = &replica{pool: replicaPool}
+		if err := p.checkReplica(ctx); err != nil {
+			log.Printf("Реплика БД недоступна при запуске, чтение идет на основной сервер: %v", redactError(err, passwords...))
+		}
+		go p.watchReplica(p.stop)
+	}
 
 	// Зафиксируем время установления подключения
-	metrics.ConnectionEstablishDuration.Observe(time.Since(startTime).Seconds())
+	p.metrics.ConnectionEstablishDuration.Observe(time.Since(startTime).Seconds())
 
-	return &Postgres{
+	return p, nil
+}
+
+// NewPostgresFromPool создает Postgres поверх готового пула соединений (например, в тестах
+// с собственным трассировщиком или search_path). Пул не проверяется; Close закрывает его.
+func NewPostgresFromPool(pool *pgxpool.Pool) *Postgres {
+	// Инициализируем метрики; предел пула известен из конфигурации
+	metrics := NewDBMetrics()
+	metrics.ConnectionMaxOpen.Set(float64(pool.Config().MaxConns))
+
+	p := &Postgres{
 		pool:    pool,
-		metrics: metrics, // Инициализируем метрики
-	}, nil
+		metrics: metrics,
+		stop:    make(chan struct{}),
+	}
+
+	// Переносим статистику пула (соединения, ожидание получения) в метрики до закрытия пула
+	collector := &poolStatsCollector{metrics: metrics}
+	go collector.run(func() poolStats { return pool.Stat() }, poolStatsInterval, p.stop)
+
+	return p
+}
+
+// poolConfig разбирает строку подключения и применяет к ней настройки пула
+func poolConfig(connectStr string, opts PoolConfig) (*pgxpool.Config, error) {
+	config, err := pgxpool.ParseConfig(connectStr)
+	if err != nil {
+		return nil, fmt.Errorf("Ошибка при анализе строки для подключения:%v", err)
+	}
+	if err := opts.apply(config); err != nil {
+		return nil, err
+	}
+	return config, nil
 }
 
 // Init инициализирует базу данных, создавая необходимые таблицы и индексы
@@ -79,82 +139,13 @@ func (p *Postgres) Init(ctx context.Context) error {
 	retryPolicy := retry.HeavyPolicy() // Используем тяжелую политику для критических операций инициализации
 
 	err = retry.DoWithContext(ctx, retryPolicy, func(ctx context.Context) error {
-		// SQL запросы для создания таблиц и индексов
-		queries := []string{
-			// Таблица заказов
-			CreateOrdersTable,
-
-			// Таблица доставки
-			CreateDeliveryTable,
-
-			// Таблица платежей
-			CreatePaymentTable,
-
-			// Таблица товаров
-			CreateItemsTable,
-
-			// Индексы для оптимизации запросов
-			CreateItemsIndex,
-			`CREATE INDEX IF NOT EXISTS idx_orders_date_created ON orders(date_created)`,
+		// Схема создается встроенными миграциями (migrations/*.sql)
+		// Ошибка в миграции или измененный файл миграции повтором не исправить
+		if err := p.migrate(ctx); err != nil {
+			return retryTransient(err)
 		}
-
-		// Выполняем все SQL запросы
-		for _, query := range queries {
-			queryStartTime := time.Now()
-			_, err := p.pool.Exec(ctx, query)
-			p.metrics.QueryDuration.WithLabelValues("init_create_table").Observe(time.Since(queryStartTime).Seconds())
-			if err != nil {
-				p.metrics.QueryErrorsTotal.Inc()
-				p.metrics.QueryErrors.WithLabelValues("init_create_table").Inc()
-				return fmt.Errorf("Ошибка выполнения запроса %s: %v", query, err)
-			}
-		}
-
-		// Простейшая миграционная таблица для детерминированных миграций
-		queryStartTime := time.Now()
-		if _, err := p.pool.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (id TEXT PRIMARY KEY, applied_at TIMESTAMP NOT NULL DEFAULT NOW())`); err != nil {
-			p.metrics.QueryDuration.WithLabelValues("init_create_migrations_table").Observe(time.Since(queryStartTime).Seconds())
-			p.metrics.QueryErrorsTotal.Inc()
-			p.metrics.QueryErrors.WithLabelValues("init_create_migrations_table").Inc()
-			return fmt.Errorf("Ошибка создания schema_migrations: %v", err)
-		} else {
-			p.metrics.QueryDuration.WithLabelValues("init_create_migrations_table").Observe(time.Since(queryStartTime).Seconds())
-		}
-
-		type migration struct{ id, sql string }
-		migrations := []migration{}
-		for _, m := range migrations {
-			queryStartTime = time.Now()
-			var exists bool
-			err := p.pool.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE id=$1)`, m.id).Scan(&exists)
-			p.metrics.QueryDuration.WithLabelValues("init_check_migration").Observe(time.Since(queryStartTime).Seconds())
-			if err != nil {
-				p.metrics.QueryErrorsTotal.Inc()
-				p.metrics.QueryErrors.WithLabelValues("init_check_migration").Inc()
-				return fmt.Errorf("Ошибка проверки миграции %s: %v", m.id, err)
-			}
-			if exists {
-				continue
-			}
-			queryStartTime = time.Now()
-			if _, err := p.pool.Exec(ctx, m.sql); err != nil {
-				p.metrics.QueryDuration.WithLabelValues("init_apply_migration").Observe(time.Since(queryStartTime).Seconds())
-				p.metrics.QueryErrorsTotal.Inc()
-				p.metrics.QueryErrors.WithLabelValues("init_apply_migration").Inc()
-				return fmt.Errorf("Ошибка применения миграции %s: %v", m.id, err)
-			} else {
-				p.metrics.QueryDuration.WithLabelValues("init_apply_migration").Observe(time.Since(queryStartTime).Seconds())
-			}
-			queryStartTime = time.Now()
-			if _, err := p.pool.Exec(ctx, `INSERT INTO schema_migrations (id) VALUES ($1)`, m.id); err != nil {
-				p.metrics.QueryDuration.WithLabelValues("init_record_migration").Observe(time.Since(queryStartTime).Seconds())
-				p.metrics.QueryErrorsTotal.Inc()
-				p.metrics.QueryErrors.WithLabelValues("init_record_migration").Inc()
-				return fmt.Errorf("Ошибка записи миграции %s: %v", m.id, err)
-			} else {
-				p.metrics.QueryDuration.WithLabelValues("init_record_migration").Observe(time.Since(queryStartTime).Seconds())
-			}
-			log.Printf("Применена миграция: %s", m.id)
+		if err := p.detectPartitioning(ctx); err != nil {
+			return retryTransient(err)
 		}
 
 		log.Println("БД инициализирована")
@@ -167,137 +158,136 @@ func (p *Postgres) Init(ctx context.Context) error {
 		p.metrics.InitDuration.Observe(time.Since(startTime).Seconds())
 	}
 
-	return err
+	return p.redact(err)
+}
+
+// redact вырезает пароль пула из текста ошибки
+func (p *Postgres) redact(err error) error {
+	if err == nil || p.pool == nil {
+		return err
+	}
+	return redactError(err, p.pool.Config().ConnConfig.Password)
 }
 
-// SaveOrder сохраняет заказ в базу данных в рамках транзакции
+// SaveOrder сохраняет заказ в базу данных в рамках транзакции (уровень изоляции — SetSaveIsolation).
+// Повторяются только временные сбои, включая конфликты сериализации.
 func (p *Postgres) SaveOrder(ctx context.Context, order *models.Order) error {
 	var err error
+	ctx = withOperation(ctx, "save_order", order.OrderUID)
 
 	startTime := time.Now()
 
+	if err := checkDuplicateItems(order); err != nil {
+		p.metrics.FailedSavesTotal.Inc()
+		return err
+	}
+
 	// Используем retry механизм для операции сохранения
 	retryPolicy := retry.HeavyPolicy() // Используем тяжелую политику для критических операций
 
 	err = retry.DoWithContext(ctx, retryPolicy, func(ctx context.Context) error {
-		// Начинаем транзакцию
-		tx, err := p.pool.BeginTx(ctx, pgx.TxOptions{})
-		if err != nil {
-			p.metrics.TransactionErrorsTotal.Inc()
-			return fmt.Errorf("Ошибка начала транзакции: %v", err)
-		}
+		// Конфликты сериализации (40001) и прочие временные сбои повторяются, остальные ошибки — нет
+		return retryTransient(p.saveOrderAttempt(ctx, order))
+	})
 
-		// Откатываем транзакцию только в случае ошибки
-		shouldRollback := true
-		defer func() {
-			if shouldRollback {
-				if err := tx.Rollback(ctx); err != nil {
-					log.Printf("Ошибка при откате транзакции: %v", err)
-				}
-			}
-		}()
+	if err != nil {
+		p.metrics.FailedSavesTotal.Inc()
+	} else {
+		p.metrics.SuccessfulSavesTotal.Inc()
+		p.metrics.SaveDuration.Observe(time.Since(startTime).Seconds())
+	}
 
-		// Сохраняем основную информацию о заказе (UPSERT)
-		queryStartTime := time.Now()
-		_, err = tx.Exec(ctx, SaveOrderQuery, order.OrderUID, order.TrackNumber, order.Entry, order.Locale, order.InternalSignature,
-			order.CustomerID, order.DeliveryService, order.ShardKey, order.SMID, order.DateCreated, order.OOFShard)
-		p.metrics.QueryDuration.WithLabelValues("save_order").Observe(time.Since(queryStartTime).Seconds())
-		if err != nil {
-			p.metrics.QueryErrorsTotal.Inc()
-			p.metrics.QueryErrors.WithLabelValues("save_order").Inc()
-			return fmt.Errorf("Ошибка при записи заказа: %v", err)
-		}
+	return classify(err)
+}
 
-		// Сохраняем информацию о доставке (UPSERT)
-		queryStartTime = time.Now()
-		_, err = tx.Exec(ctx, SaveDeliveryQuery, order.OrderUID, order.Delivery.Name, order.Delivery.Phone, order.Delivery.Zip,
-			order.Delivery.City, order.Delivery.Address, order.Delivery.Region, order.Delivery.Email)
-		p.metrics.QueryDuration.WithLabelValues("save_delivery").Observe(time.Since(queryStartTime).Seconds())
-		if err != nil {
-			p.metrics.QueryErrorsTotal.Inc()
-			p.metrics.QueryErrors.WithLabelValues("save_delivery").Inc()
-			return fmt.Errorf("Ошибка при записи доставки: %v", err)
+// checkDuplicateItems проверяет, что chrt_id товаров заказа не повторяются.
+// Повтор не исправится повторной попыткой: UPSERT не может обновить строку дважды.
+func checkDuplicateItems(order *models.Order) error {
+	seen := make(map[int]struct{}, len(order.Items))
+	for _, item := range order.Items {
+		if _, ok := seen[item.ChrtID]; ok {
+			return fmt.Errorf("%w: заказ %s, chrt_id %d", ErrDuplicateItem, order.OrderUID, item.ChrtID)
 		}
+		seen[item.ChrtID] = struct{}{}
+	}
+	return nil
+}
 
-		// Сохраняем информацию о платеже (UPSERT)
-		queryStartTime = time.Now()
-		_, err = tx.Exec(ctx, SavePaymentQuery, order.OrderUID, order.Payment.Transaction, order.Payment.RequestID, order.Payment.Currency,
-			order.Payment.Provider, order.Payment.Amount, order.Payment.PaymentDT, order.Payment.Bank,
-			order.Payment.DeliveryCost, order.Payment.GoodsTotal, order.Payment.CustomFee)
-		p.metrics.QueryDuration.WithLabelValues("save_payment").Observe(time.Since(queryStartTime).Seconds())
-		if err != nil {
-			p.metrics.QueryErrorsTotal.Inc()
-			p.metrics.QueryErrors.WithLabelValues("save_payment").Inc()
-			return fmt.Errorf("Ошибка при записи payment: %v", err)
-		}
+// saveOrderAttempt одна попытка SaveOrder: заказ сохраняется в отдельной транзакции
+func (p *Postgres) saveOrderAttempt(ctx context.Context, order *models.Order) error {
+	ctx, cancel := p.writeContext(ctx) // Дедлайн попытки, а не всей операции
+	defer cancel()
 
-		// Удаляем старые товары заказа (для обновления)
-		queryStartTime = time.Now()
-		_, err = tx.Exec(ctx, DeleteItemsQuery, order.OrderUID)
-		p.metrics.QueryDuration.WithLabelValues("delete_items").Observe(time.Since(queryStartTime).Seconds())
-		if err != nil {
-			p.metrics.QueryErrorsTotal.Inc()
-			p.metrics.QueryErrors.WithLabelValues("delete_items").Inc()
-			return fmt.Errorf("Ошибка удаления позиций: %v", err)
-		}
+	// Начинаем транзакцию
+	tx, err := p.pool.BeginTx(ctx, p.saveTx)
+	if err != nil {
+		p.metrics.TransactionErrorsTotal.Inc()
+		return fmt.Errorf("Ошибка начала транзакции: %w", err)
+	}
 
-		// Добавляем новые товары заказа
-		for _, items := range order.Items {
-			queryStartTime = time.Now()
-			_, err = tx.Exec(ctx, SaveItemQuery, order.OrderUID, items.ChrtID, items.TrackNumber, items.Price, items.RID, items.Name,
-				items.Sale, items.Size, items.TotalPrice, items.NMID, items.Brand, items.Status)
-			p.metrics.QueryDuration.WithLabelValues("save_item").Observe(time.Since(queryStartTime).Seconds())
-			if err != nil {
-				p.metrics.QueryErrorsTotal.Inc()
-				p.metrics.QueryErrors.WithLabelValues("save_item").Inc()
-				return fmt.Errorf("Ошибка добавления позиции: %v", err)
+	// Откатываем транзакцию только в случае ошибки
+	shouldRollback := true
+	defer func() {
+		if shouldRollback {
+			if err := tx.Rollback(ctx); err != nil {
+				log.Printf("Ошибка при откате транзакции: %v", err)
 			}
 		}
+	}()
 
-		// Коммитим транзакцию
-		queryStartTime = time.Now()
-		if err := tx.Commit(ctx); err != nil {
-			p.metrics.QueryDuration.WithLabelValues("commit_transaction").Observe(time.Since(queryStartTime).Seconds())
-			p.metrics.TransactionErrorsTotal.Inc()
-			return fmt.Errorf("Ошибка коммита транзакции: %v", err)
-		} else {
-			p.metrics.QueryDuration.WithLabelValues("commit_transaction").Observe(time.Since(queryStartTime).Seconds())
-		}
-
-		// Успешно закоммиченная транзакция не нуждается в откате
-		shouldRollback = false
-		return nil
-	})
-
-	if err != nil {
-		p.metrics.FailedSavesTotal.Inc()
+	// Заказ, доставка, платеж и товары отправляются одним пакетом: один сетевой обмен
+	// вместо отдельного на каждый запрос
+	var saved savedOrder
+	if err := p.sendBatch(ctx, tx, saveOrderBatch(order, &saved, p.save)); err != nil {
+		return err
+	}
+	p.metrics.SaveItemsBatchSize.Observe(float64(len(order.Items)))
+
+	// Коммитим транзакцию
+	queryStartTime := time.Now()
+	if err := tx.Commit(ctx); err != nil {
+		p.metrics.QueryDuration.WithLabelValues("commit_transaction").Observe(time.Since(queryStartTime).Seconds())
+		p.metrics.TransactionErrorsTotal.Inc()
+		return fmt.Errorf("Ошибка коммита транзакции: %w", err)
 	} else {
-		p.metrics.SuccessfulSavesTotal.Inc()
-		p.metrics.SaveDuration.Observe(time.Since(startTime).Seconds())
+		p.metrics.QueryDuration.WithLabelValues("commit_transaction").Observe(time.Since(queryStartTime).Seconds())
 	}
 
-	return err
+	// Успешно закоммиченная транзакция не нуждается в откате
+	shouldRollback = false
+	order.UpdatedAt = saved.updatedAt
+	order.DeletedAt = saved.deletedAt
+	order.Status = saved.status
+	return nil
 }
 
-// GetOrder получает заказ из базы данных по его UID
-func (p *Postgres) GetOrder(ctx context.Context, orderUID string) (*models.Order, error) {
+// GetOrder получает заказ из базы данных по его UID.
+// Если заказа нет, сразу, без повторных попыток, возвращает models.ErrOrderNotFound.
+// Мягко удаленный заказ (SoftDeleteOrder) не находится, если не передан interfaces.IncludeDeleted;
+// так же мягко удаленные заказы пропускают и остальные выборки заказов.
+func (p *Postgres) GetOrder(ctx context.Context, orderUID string, opts ...interfaces.ReadOption) (*models.Order, error) {
+	options := interfaces.ApplyReadOptions(opts)
 	var order *models.Order
 	var err error
+	ctx = withOperation(ctx, "get_order", orderUID)
 
 	startTime := time.Now()
 
 	// Используем retry механизм для операции получения заказа
 	retryPolicy := retry.DefaultPolicy() // Используем стандартную политику для операций чтения
 
-	err = retry.DoWithContext(ctx, retryPolicy, func(ctx context.Context) error {
+	err = retry.DoWithContext(ctx, retryPolicy, p.readingFrom(options, "get_order", func(ctx context.Context, db *pgxpool.Pool) error {
+		ctx, cancel := p.readContext(ctx)
+		defer cancel()
+
 		var tempOrder models.Order
 
 		// Получаем все данные заказа за один запрос
 		queryStartTime := time.Now()
-		row := p.pool.QueryRow(ctx, GetOrderByUIDQuery, orderUID)
+		row := db.QueryRow(ctx, GetOrderByUIDQuery, orderUID, options.IncludeDeleted)
 		err := row.Scan(
 			&tempOrder.OrderUID, &tempOrder.TrackNumber, &tempOrder.Entry, &tempOrder.Locale, &tempOrder.InternalSignature,
-			&tempOrder.CustomerID, &tempOrder.DeliveryService, &tempOrder.ShardKey, &tempOrder.SMID, &tempOrder.DateCreated, &tempOrder.OOFShard,
+			&tempOrder.CustomerID, &tempOrder.DeliveryService, &tempOrder.ShardKey, &tempOrder.SMID, &tempOrder.DateCreated, &tempOrder.OOFShard, &tempOrder.UpdatedAt, &tempOrder.DeletedAt, &tempOrder.Status,
 			&tempOrder.Delivery.Name, &tempOrder.Delivery.Phone, &tempOrder.Delivery.Zip, &tempOrder.Delivery.City,
 			&tempOrder.Delivery.Address, &tempOrder.Delivery.Region, &tempOrder.Delivery.Email,
 			&tempOrder.Payment.Transaction, &tempOrder.Payment.RequestID, &tempOrder.Payment.Currency, &tempOrder.Payment.Provider,
@@ -305,23 +295,25 @@ func (p *Postgres) GetOrder(ctx context.Context, orderUID string) (*models.Order
 			&tempOrder.Payment.GoodsTotal, &tempOrder.Payment.CustomFee,
 		)
 		p.metrics.QueryDuration.WithLabelValues("get_order_by_uid").Observe(time.Since(queryStartTime).Seconds())
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Отсутствие заказа — ответ, а не сбой: повтор его не изменит
+			p.metrics.OrdersNotFoundTotal.Inc()
+			return retry.Permanent(fmt.Errorf("%w: %s", models.ErrOrderNotFound, orderUID))
+		}
 		if err != nil {
 			p.metrics.QueryErrorsTotal.Inc()
 			p.metrics.QueryErrors.WithLabelValues("get_order_by_uid").Inc()
-			if errors.Is(err, pgx.ErrNoRows) {
-				return fmt.Errorf("Заказ не найден: %v", err) // Не возвращаем как ошибку для повторных попыток
-			}
-			return fmt.Errorf("Ошибка получения заказа: %v", err)
+			return fmt.Errorf("Ошибка получения заказа: %w", err)
 		}
 
 		// Получаем список товаров заказа
 		queryStartTime = time.Now()
-		rows, err := p.pool.Query(ctx, GetItemsByOrderUIDQuery, orderUID)
+		rows, err := db.Query(ctx, GetItemsByOrderUIDQuery, orderUID)
 		p.metrics.QueryDuration.WithLabelValues("get_items_by_order_uid").Observe(time.Since(queryStartTime).Seconds())
 		if err != nil {
 			p.metrics.QueryErrorsTotal.Inc()
 			p.metrics.QueryErrors.WithLabelValues("get_items_by_order_uid").Inc()
-			return fmt.Errorf("Не удалось запросить items: %v", err)
+			return fmt.Errorf("Не удалось запросить items: %w", err)
 		}
 		defer rows.Close()
 
@@ -334,7 +326,7 @@ func (p *Postgres) GetOrder(ctx context.Context, orderUID string) (*models.Order
 			if err != nil {
 				p.metrics.QueryErrorsTotal.Inc()
 				p.metrics.QueryErrors.WithLabelValues("get_items_by_order_uid").Inc()
-				return fmt.Errorf("Ошибка при чтении items:%v", err)
+				return fmt.Errorf("Ошибка при чтении items:%w", err)
 			}
 			tempOrder.Items = append(tempOrder.Items, item)
 		}
@@ -343,29 +335,34 @@ func (p *Postgres) GetOrder(ctx context.Context, orderUID string) (*models.Order
 		if err := rows.Err(); err != nil {
 			p.metrics.QueryErrorsTotal.Inc()
 			p.metrics.QueryErrors.WithLabelValues("get_items_by_order_uid").Inc()
-			return fmt.Errorf("Ошибка при переборе items: %v", err)
+			return fmt.Errorf("Ошибка при переборе items: %w", err)
 		}
 
 		order = &tempOrder
 		return nil
-	})
+	}))
 
-	if err != nil {
+	switch {
+	case errors.Is(err, models.ErrOrderNotFound):
+		// Учтено в OrdersNotFoundTotal, не является неудачным чтением
+	case err != nil:
 		p.metrics.FailedGetsTotal.Inc()
-	} else {
+	default:
 		p.metrics.SuccessfulGetsTotal.Inc()
 		p.metrics.GetDuration.Observe(time.Since(startTime).Seconds())
 	}
 
 	if err != nil {
-		return nil, err
+		return nil, classify(err)
 	}
 
 	return order, nil
 }
 
-// GetAllOrders получает все заказы из базы данных
-func (p *Postgres) GetAllOrders(ctx context.Context) ([]models.Order, error) {
+// GetAllOrders получает все заказы из базы данных от новых к старым (date_created, при равенстве —
+// по order_uid); товары каждого заказа идут в порядке добавления
+func (p *Postgres) GetAllOrders(ctx context.Context, opts ...interfaces.ReadOption) ([]models.Order, error) {
+	options := interfaces.ApplyReadOptions(opts)
 	var orders []models.Order
 	var err error
 
@@ -374,98 +371,645 @@ func (p *Postgres) GetAllOrders(ctx context.Context) ([]models.Order, error) {
 	// Используем retry механизм для операции получения всех заказов
 	retryPolicy := retry.DefaultPolicy() // Используем стандартную политику для операций чтения
 
-	err = retry.DoWithContext(ctx, retryPolicy, func(ctx context.Context) error {
-		// Получаем все данные всех заказов за один запрос
+	err = retry.DoWithContext(ctx, retryPolicy, p.readingFrom(options, "get_all_orders", func(ctx context.Context, db *pgxpool.Pool) error {
+		ctx, cancel := p.getAllContext(ctx)
+		defer cancel()
+
+		// Заказы и товары читаются двумя запросами в одном снимке данных (REPEATABLE READ),
+		// поэтому заказ, сохраненный между запросами, не окажется без товаров
+		tx, err := db.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+		if err != nil {
+			p.metrics.TransactionErrorsTotal.Inc()
+			return fmt.Errorf("Ошибка начала транзакции: %w", err)
+		}
+		// Транзакция только читает данные: откат просто завершает ее
+		defer func() { _ = tx.Rollback(ctx) }()
+
+		// Получаем данные всех заказов за один запрос
 		queryStartTime := time.Now()
-		rows, err := p.pool.Query(ctx, GetAllOrdersQuery)
-		p.metrics.QueryDuration.WithLabelValues("get_all_orders").Observe(time.Since(queryStartTime).Seconds())
+		rows, err := tx.Query(ctx, GetAllOrdersQuery, options.IncludeDeleted)
 		if err != nil {
 			p.metrics.QueryErrorsTotal.Inc()
 			p.metrics.QueryErrors.WithLabelValues("get_all_orders").Inc()
-			return fmt.Errorf("Ошибка при запросе заказов: %v", err)
+			return fmt.Errorf("Ошибка при запросе заказов: %w", err)
 		}
 		defer rows.Close()
 
 		// Обрабатываем результаты запроса
-		orders = make([]models.Order, 0)           // Инициализируем слайс
-		orderMap := make(map[string]*models.Order) // To group orders by UID
-
+		orders = make([]models.Order, 0)
 		for rows.Next() {
 			var order models.Order
-			err := rows.Scan(
-				&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale, &order.InternalSignature,
-				&order.CustomerID, &order.DeliveryService, &order.ShardKey, &order.SMID, &order.DateCreated, &order.OOFShard,
-				&order.Delivery.Name, &order.Delivery.Phone, &order.Delivery.Zip, &order.Delivery.City,
-				&order.Delivery.Address, &order.Delivery.Region, &order.Delivery.Email,
-				&order.Payment.Transaction, &order.Payment.RequestID, &order.Payment.Currency, &order.Payment.Provider,
-				&order.Payment.Amount, &order.Payment.PaymentDT, &order.Payment.Bank, &order.Payment.DeliveryCost,
-				&order.Payment.GoodsTotal, &order.Payment.CustomFee,
-			)
-			if err != nil {
+			if err := scanOrder(rows, &order); err != nil {
 				p.metrics.QueryErrorsTotal.Inc()
 				p.metrics.QueryErrors.WithLabelValues("get_all_orders").Inc()
-				return fmt.Errorf("Ошибка при чтении заказа: %v", err)
+				return fmt.Errorf("Ошибка при чтении заказа: %w", err)
 			}
-
-			orderMap[order.OrderUID] = &order
 			orders = append(orders, order)
 		}
 
 		if err := rows.Err(); err != nil {
 			p.metrics.QueryErrorsTotal.Inc()
 			p.metrics.QueryErrors.WithLabelValues("get_all_orders").Inc()
-			return fmt.Errorf("Ошибка перебора заказов: %v", err)
+			return fmt.Errorf("Ошибка перебора заказов: %w", err)
 		}
+		rows.Close()
+		p.metrics.QueryDuration.WithLabelValues("get_all_orders").Observe(time.Since(queryStartTime).Seconds())
 
-		for i := range orders {
-			order := &orders[i]
-			queryStartTime = time.Now()
-			itemsRows, err := p.pool.Query(ctx, GetItemsByOrderUIDQuery, order.OrderUID)
-			p.metrics.QueryDuration.WithLabelValues("get_items_by_order_uid").Observe(time.Since(queryStartTime).Seconds())
-			if err != nil {
+		// Товары только прочитанных заказов — одним запросом (ANY($1)) в том же снимке
+		return p.loadItems(ctx, tx, orders)
+	}))
+
+	if err != nil {
+		p.metrics.FailedGetAllTotal.Inc()
+	} else {
+		p.metrics.SuccessfulGetAllTotal.Inc()
+		p.metrics.GetAllDuration.Observe(time.Since(startTime).Seconds())
+	}
+
+	if err != nil {
+		return nil, classify(err)
+	}
+
+	return orders, nil
+}
+
+// GetOrdersByCustomerID возвращает заказы покупателя с товарами от новых к старым,
+// пропуская первые offset. limit <= 0 заменяется на DefaultPageSize, limit больше MaxPageSize — на MaxPageSize.
+func (p *Postgres) GetOrdersByCustomerID(ctx context.Context, customerID string, limit, offset int, opts ...interfaces.ReadOption) ([]models.Order, error) {
+	options := interfaces.ApplyReadOptions(opts)
+	switch {
+	case limit <= 0:
+		limit = DefaultPageSize
+	case limit > MaxPageSize:
+		limit = MaxPageSize
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	var orders []models.Order
+
+	err := retry.DoWithContext(ctx, retry.DefaultPolicy(), p.readingFrom(options, "get_orders_by_customer_id", func(ctx context.Context, db *pgxpool.Pool) error {
+		ctx, cancel := p.readContext(ctx)
+		defer cancel()
+
+		queryStartTime := time.Now()
+		rows, err := db.Query(ctx, GetOrdersByCustomerIDQuery, customerID, limit, offset, options.IncludeDeleted)
+		if err != nil {
+			p.metrics.QueryErrorsTotal.Inc()
+			p.metrics.QueryErrors.WithLabelValues("get_orders_by_customer_id").Inc()
+			return fmt.Errorf("Ошибка при запросе заказов покупателя: %w", err)
+		}
+		defer rows.Close()
+
+		orders = make([]models.Order, 0)
+		for rows.Next() {
+			var order models.Order
+			if err := scanOrder(rows, &order); err != nil {
 				p.metrics.QueryErrorsTotal.Inc()
-				p.metrics.QueryErrors.WithLabelValues("get_items_by_order_uid").Inc()
-				log.Printf("Ошибка при запросе товаров для заказа %s: %v", order.OrderUID, err)
-				continue
+				p.metrics.QueryErrors.WithLabelValues("get_orders_by_customer_id").Inc()
+				return fmt.Errorf("Ошибка при чтении заказа: %w", err)
 			}
+			orders = append(orders, order)
+		}
+		if err := rows.Err(); err != nil {
+			p.metrics.QueryErrorsTotal.Inc()
+			p.metrics.QueryErrors.WithLabelValues("get_orders_by_customer_id").Inc()
+			return fmt.Errorf("Ошибка перебора заказов: %w", err)
+		}
+		rows.Close()
+		p.metrics.QueryDuration.WithLabelValues("get_orders_by_customer_id").Observe(time.Since(queryStartTime).Seconds())
 
-			// Обрабатываем результаты запроса товаров
-			for itemsRows.Next() {
-				var item models.Item
-				err := itemsRows.Scan(&item.ChrtID, &item.TrackNumber, &item.Price, &item.RID, &item.Name, &item.Sale,
-					&item.Size, &item.TotalPrice, &item.NMID, &item.Brand, &item.Status)
-				if err != nil {
-					p.metrics.QueryErrorsTotal.Inc()
-					p.metrics.QueryErrors.WithLabelValues("get_items_by_order_uid").Inc()
-					log.Printf("Ошибка при чтении товара для заказа %s: %v", order.OrderUID, err)
-					itemsRows.Close()
-					break
-				}
-				order.Items = append(order.Items, item)
+		// Товары всех найденных заказов читаем одним запросом
+		return p.loadItems(ctx, db, orders)
+	}))
+	if err != nil {
+		return nil, classify(err)
+	}
+	return orders, nil
+}
+
+// GetOrdersSince возвращает заказы с товарами, созданные начиная с since включительно,
+// от старых к новым. Граница включена, чтобы не потерять заказы с тем же date_created,
+// что и у последнего заказа в кэше; повторно загруженные заказы просто перезапишутся.
+func (p *Postgres) GetOrdersSince(ctx context.Context, since time.Time, opts ...interfaces.ReadOption) ([]models.Order, error) {
+	options := interfaces.ApplyReadOptions(opts)
+	var orders []models.Order
+
+	err := retry.DoWithContext(ctx, retry.DefaultPolicy(), p.readingFrom(options, "get_orders_since", func(ctx context.Context, db *pgxpool.Pool) error {
+		ctx, cancel := p.getAllContext(ctx)
+		defer cancel()
+
+		queryStartTime := time.Now()
+		rows, err := db.Query(ctx, GetOrdersSinceQuery, since.UTC(), options.IncludeDeleted)
+		if err != nil {
+			p.metrics.QueryErrorsTotal.Inc()
+			p.metrics.QueryErrors.WithLabelValues("get_orders_since").Inc()
+			return fmt.Errorf("Ошибка при запросе новых заказов: %w", err)
+		}
+		defer rows.Close()
+
+		orders = make([]models.Order, 0)
+		for rows.Next() {
+			var order models.Order
+			if err := scanOrder(rows, &order); err != nil {
+				p.metrics.QueryErrorsTotal.Inc()
+				p.metrics.QueryErrors.WithLabelValues("get_orders_since").Inc()
+				return fmt.Errorf("Ошибка при чтении заказа: %w", err)
 			}
-			itemsRows.Close()
+			orders = append(orders, order)
 		}
+		if err := rows.Err(); err != nil {
+			p.metrics.QueryErrorsTotal.Inc()
+			p.metrics.QueryErrors.WithLabelValues("get_orders_since").Inc()
+			return fmt.Errorf("Ошибка перебора заказов: %w", err)
+		}
+		rows.Close()
+		p.metrics.QueryDuration.WithLabelValues("get_orders_since").Observe(time.Since(queryStartTime).Seconds())
+
+		return p.loadItems(ctx, db, orders)
+	}))
+	if err != nil {
+		return nil, classify(err)
+	}
+	return orders, nil
+}
+
+// GetOrderByTrackNumber возвращает все заказы с трек-номером вместе с доставкой, платежом
+// и товарами, от новых к старым. Если заказов нет, возвращает models.ErrOrderNotFound.
+func (p *Postgres) GetOrderByTrackNumber(ctx context.Context, trackNumber string, opts ...interfaces.ReadOption) ([]models.Order, error) {
+	options := interfaces.ApplyReadOptions(opts)
+	var orders []models.Order
+
+	err := retry.DoWithContext(ctx, retry.DefaultPolicy(), p.readingFrom(options, "get_orders_by_track_number", func(ctx context.Context, db *pgxpool.Pool) error {
+		ctx, cancel := p.readContext(ctx)
+		defer cancel()
+
+		queryStartTime := time.Now()
+		rows, err := db.Query(ctx, GetOrdersByTrackNumberQuery, trackNumber, options.IncludeDeleted)
+		if err != nil {
+			p.metrics.QueryErrorsTotal.Inc()
+			p.metrics.QueryErrors.WithLabelValues("get_orders_by_track_number").Inc()
+			return fmt.Errorf("Ошибка при запросе заказов по трек-номеру: %w", err)
+		}
+		defer rows.Close()
+
+		orders = make([]models.Order, 0)
+		for rows.Next() {
+			var order models.Order
+			if err := scanOrder(rows, &order); err != nil {
+				p.metrics.QueryErrorsTotal.Inc()
+				p.metrics.QueryErrors.WithLabelValues("get_orders_by_track_number").Inc()
+				return fmt.Errorf("Ошибка при чтении заказа: %w", err)
+			}
+			orders = append(orders, order)
+		}
+		if err := rows.Err(); err != nil {
+			p.metrics.QueryErrorsTotal.Inc()
+			p.metrics.QueryErrors.WithLabelValues("get_orders_by_track_number").Inc()
+			return fmt.Errorf("Ошибка перебора заказов: %w", err)
+		}
+		rows.Close()
+		p.metrics.QueryDuration.WithLabelValues("get_orders_by_track_number").Observe(time.Since(queryStartTime).Seconds())
+
+		return p.loadItems(ctx, db, orders)
+	}))
+	if err != nil {
+		return nil, classify(err)
+	}
+	if len(orders) == 0 {
+		p.metrics.OrdersNotFoundTotal.Inc()
+		return nil, models.ErrOrderNotFound
+	}
+	return orders, nil
+}
+
+// scanOrder читает заказ с доставкой и платежом из строки выборки ordersSelect
+func scanOrder(rows pgx.Rows, order *models.Order) error {
+	return rows.Scan(
+		&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale, &order.InternalSignature,
+		&order.CustomerID, &order.DeliveryService, &order.ShardKey, &order.SMID, &order.DateCreated, &order.OOFShard, &order.UpdatedAt, &order.DeletedAt, &order.Status,
+		&order.Delivery.Name, &order.Delivery.Phone, &order.Delivery.Zip, &order.Delivery.City,
+		&order.Delivery.Address, &order.Delivery.Region, &order.Delivery.Email,
+		&order.Payment.Transaction, &order.Payment.RequestID, &order.Payment.Currency, &order.Payment.Provider,
+		&order.Payment.Amount, &order.Payment.PaymentDT, &order.Payment.Bank, &order.Payment.DeliveryCost,
+		&order.Payment.GoodsTotal, &order.Payment.CustomFee,
+	)
+}
+
+// rowsQuerier выполняет запрос с набором строк: *pgxpool.Pool или pgx.Tx
+type rowsQuerier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// loadItems загружает товары заказов одним запросом по списку UID и раскладывает их по заказам.
+// Товары заказа идут в порядке добавления (id); товары, чей заказ не передан, не читаются.
+func (p *Postgres) loadItems(ctx context.Context, db rowsQuerier, orders []models.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	index := make(map[string]int, len(orders))
+	uids := make([]string, len(orders))
+	for i := range orders {
+		orders[i].Items = []models.Item{}
+		index[orders[i].OrderUID] = i
+		uids[i] = orders[i].OrderUID
+	}
+
+	queryStartTime := time.Now()
+	rows, err := db.Query(ctx, GetItemsByOrderUIDsQuery, uids)
+	if err != nil {
+		p.metrics.QueryErrorsTotal.Inc()
+		p.metrics.QueryErrors.WithLabelValues("get_items_by_order_uids").Inc()
+		return fmt.Errorf("Ошибка при запросе товаров: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var orderUID string
+		var item models.Item
+		err := rows.Scan(&orderUID, &item.ChrtID, &item.TrackNumber, &item.Price, &item.RID, &item.Name, &item.Sale,
+			&item.Size, &item.TotalPrice, &item.NMID, &item.Brand, &item.Status)
+		if err != nil {
+			p.metrics.QueryErrorsTotal.Inc()
+			p.metrics.QueryErrors.WithLabelValues("get_items_by_order_uids").Inc()
+			return fmt.Errorf("Ошибка при чтении товара: %w", err)
+		}
+		if i, ok := index[orderUID]; ok {
+			orders[i].Items = append(orders[i].Items, item)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		p.metrics.QueryErrorsTotal.Inc()
+		p.metrics.QueryErrors.WithLabelValues("get_items_by_order_uids").Inc()
+		return fmt.Errorf("Ошибка перебора товаров: %w", err)
+	}
+	p.metrics.QueryDuration.WithLabelValues("get_items_by_order_uids").Observe(time.Since(queryStartTime).Seconds())
+	return nil
+}
+
+// OrderExists проверяет наличие заказа по первичному ключу, не читая связанные таблицы.
+// Используется на горячих путях, поэтому повторяется по облегченной политике.
+func (p *Postgres) OrderExists(ctx context.Context, orderUID string) (bool, error) {
+	var exists bool
+	ctx = withOperation(ctx, "order_exists", orderUID)
+
+	err := retry.DoWithContext(ctx, retry.LightPolicy(), func(ctx context.Context) error {
+		ctx, cancel := p.readContext(ctx)
+		defer cancel()
 
+		queryStartTime := time.Now()
+		err := p.pool.QueryRow(ctx, OrderExistsQuery, orderUID).Scan(&exists)
+		p.metrics.QueryDuration.WithLabelValues("order_exists").Observe(time.Since(queryStartTime).Seconds())
+		if err != nil {
+			p.metrics.QueryErrorsTotal.Inc()
+			p.metrics.QueryErrors.WithLabelValues("order_exists").Inc()
+			return retryTransient(fmt.Errorf("Ошибка проверки существования заказа: %w", err))
+		}
 		return nil
 	})
+	if err != nil {
+		return false, classify(err)
+	}
+	return exists, nil
+}
+
+// CountOrders возвращает количество сохраненных заказов
+func (p *Postgres) CountOrders(ctx context.Context) (int64, error) {
+	return p.count(ctx, "count_orders", CountOrdersQuery)
+}
+
+// CountOrdersSince возвращает количество заказов, созданных (date_created) начиная с since
+func (p *Postgres) CountOrdersSince(ctx context.Context, since time.Time) (int64, error) {
+	return p.count(ctx, "count_orders_since", CountOrdersSinceQuery, since.UTC())
+}
+
+// count выполняет запрос count(*) с облегченной политикой повторов и метриками под меткой label
+func (p *Postgres) count(ctx context.Context, label, query string, args ...any) (int64, error) {
+	var n int64
 
+	err := retry.DoWithContext(ctx, retry.LightPolicy(), p.reading(label, func(ctx context.Context, db *pgxpool.Pool) error {
+		ctx, cancel := p.readContext(ctx)
+		defer cancel()
+
+		queryStartTime := time.Now()
+		err := db.QueryRow(ctx, query, args...).Scan(&n)
+		p.metrics.QueryDuration.WithLabelValues(label).Observe(time.Since(queryStartTime).Seconds())
+		if err != nil {
+			p.metrics.QueryErrorsTotal.Inc()
+			p.metrics.QueryErrors.WithLabelValues(label).Inc()
+			return fmt.Errorf("Ошибка подсчета заказов: %w", err)
+		}
+		return nil
+	}))
 	if err != nil {
-		p.metrics.FailedGetAllTotal.Inc()
-	} else {
-		p.metrics.SuccessfulGetAllTotal.Inc()
-		p.metrics.GetAllDuration.Observe(time.Since(startTime).Seconds())
+		return 0, classify(err)
 	}
+	return n, nil
+}
+
+// DeleteOrder удаляет заказ; доставка, платеж и товары удаляются каскадно (ON DELETE CASCADE).
+// Возвращает models.ErrOrderNotFound, если заказа не существует.
+// Повторяются только временные сбои (isTransient), ошибки запроса возвращаются сразу.
+func (p *Postgres) DeleteOrder(ctx context.Context, orderUID string) error {
+	var deleted bool
+	ctx = withOperation(ctx, "delete_order", orderUID)
 
+	// Используем retry механизм для операции удаления
+	retryPolicy := retry.DefaultPolicy()
+
+	err := retry.DoWithContext(ctx, retryPolicy, func(ctx context.Context) error {
+		ctx, cancel := p.writeContext(ctx)
+		defer cancel()
+
+		queryStartTime := time.Now()
+		tag, err := p.pool.Exec(ctx, DeleteOrderQuery, orderUID)
+		p.metrics.QueryDuration.WithLabelValues("delete_order").Observe(time.Since(queryStartTime).Seconds())
+		if err != nil {
+			p.metrics.QueryErrorsTotal.Inc()
+			p.metrics.QueryErrors.WithLabelValues("delete_order").Inc()
+			return retryTransient(fmt.Errorf("Ошибка удаления заказа: %w", err))
+		}
+		// Отсутствие заказа не является ошибкой для повторных попыток
+		deleted = tag.RowsAffected() > 0
+		return nil
+	})
 	if err != nil {
-		return nil, err
+		return classify(err)
+	}
+	if !deleted {
+		p.metrics.OrdersNotFoundTotal.Inc()
+		return models.ErrOrderNotFound
 	}
 
-	return orders, nil
+	p.metrics.DeletedOrdersTotal.Inc()
+	return nil
+}
+
+// SoftDeleteOrder отмечает заказ удаленным (deleted_at), не удаляя его данные: заказ перестает
+// возвращаться выборками без interfaces.IncludeDeleted. Если заказа нет или он уже отмечен,
+// возвращает models.ErrOrderNotFound. Повторное сохранение заказа отметку не снимает.
+func (p *Postgres) SoftDeleteOrder(ctx context.Context, orderUID string) error {
+	var deleted bool
+	ctx = withOperation(ctx, "soft_delete_order", orderUID)
+
+	err := retry.DoWithContext(ctx, retry.DefaultPolicy(), func(ctx context.Context) error {
+		ctx, cancel := p.writeContext(ctx)
+		defer cancel()
+
+		queryStartTime := time.Now()
+		tag, err := p.pool.Exec(ctx, SoftDeleteOrderQuery, orderUID)
+		p.metrics.QueryDuration.WithLabelValues("soft_delete_order").Observe(time.Since(queryStartTime).Seconds())
+		if err != nil {
+			p.metrics.QueryErrorsTotal.Inc()
+			p.metrics.QueryErrors.WithLabelValues("soft_delete_order").Inc()
+			return retryTransient(fmt.Errorf("Ошибка мягкого удаления заказа: %w", err))
+		}
+		deleted = tag.RowsAffected() > 0
+		return nil
+	})
+	if err != nil {
+		return classify(err)
+	}
+	if !deleted {
+		p.metrics.OrdersNotFoundTotal.Inc()
+		return models.ErrOrderNotFound
+	}
+
+	p.metrics.SoftDeletedOrdersTotal.Inc()
+	return nil
+}
+
+// UpdateOrderStatus устанавливает статус заказа. Допустимость перехода не проверяется —
+// это делает сервис (models.OrderStatus.CanTransitionTo); недопустимое значение отклоняет
+// ограничение колонки. Если заказа нет или он мягко удален, возвращает models.ErrOrderNotFound.
+func (p *Postgres) UpdateOrderStatus(ctx context.Context, orderUID string, status models.OrderStatus) error {
+	var updated bool
+	ctx = withOperation(ctx, "update_order_status", orderUID)
+
+	err := retry.DoWithContext(ctx, retry.DefaultPolicy(), func(ctx context.Context) error {
+		ctx, cancel := p.writeContext(ctx)
+		defer cancel()
+
+		queryStartTime := time.Now()
+		tag, err := p.pool.Exec(ctx, UpdateOrderStatusQuery, orderUID, string(status))
+		p.metrics.QueryDuration.WithLabelValues("update_order_status").Observe(time.Since(queryStartTime).Seconds())
+		if err != nil {
+			p.metrics.QueryErrorsTotal.Inc()
+			p.metrics.QueryErrors.WithLabelValues("update_order_status").Inc()
+			return retryTransient(fmt.Errorf("Ошибка смены статуса заказа: %w", err))
+		}
+		updated = tag.RowsAffected() > 0
+		return nil
+	})
+	if err != nil {
+		return classify(err)
+	}
+	if !updated {
+		p.metrics.OrdersNotFoundTotal.Inc()
+		return models.ErrOrderNotFound
+	}
+
+	p.metrics.StatusUpdatesTotal.WithLabelValues(string(status)).Inc()
+	return nil
+}
+
+// StreamOrders последовательно передает в fn все заказы с товарами, читая их одним курсором
+// (на реплике, если она исправна). Заказы не накапливаются в памяти; ошибка fn или отмена ctx
+// прерывают запрос. Ожидание первой строки ограничено временем попытки чтения (QueryTimeouts.Read),
+// и до нее временный сбой повторяется, как в остальных чтениях; после первого переданного
+// заказа повторные попытки не выполняются, так как часть заказов уже передана.
+func (p *Postgres) StreamOrders(ctx context.Context, fn func(*models.Order) error, opts ...interfaces.ReadOption) error {
+	options := interfaces.ApplyReadOptions(opts)
+	ctx = withOperation(ctx, "stream_orders", "")
+	startTime := time.Now()
+
+	var fnErr error // Ошибка fn возвращается как есть: это не ошибка БД
+	streamed := false
+	err := retry.DoWithContext(ctx, retry.DefaultPolicy(), p.readingFrom(options, "stream_orders", func(ctx context.Context, db *pgxpool.Pool) error {
+		ctx, firstRow, cancel := p.cursorContext(ctx)
+		defer cancel()
+
+		rows, err := db.Query(ctx, StreamOrdersQuery, options.IncludeDeleted)
+		if err != nil {
+			p.metrics.QueryErrorsTotal.Inc()
+			p.metrics.QueryErrors.WithLabelValues("stream_orders").Inc()
+			return firstRow.wrap(fmt.Errorf("Ошибка при запросе заказов: %w", err))
+		}
+		defer rows.Close()
+
+		// emit передает заказ в fn; после первого переданного заказа ошибки не повторяются
+		emit := func(order *models.Order) error {
+			streamed = true
+			if err := fn(order); err != nil {
+				fnErr = err
+				return retry.Permanent(err)
+			}
+			return nil
+		}
+		// stop прерывает выгрузку ошибкой err, не повторяя ее, если заказы уже переданы
+		stop := func(err error) error {
+			p.metrics.QueryErrorsTotal.Inc()
+			p.metrics.QueryErrors.WithLabelValues("stream_orders").Inc()
+			if streamed {
+				return retry.Permanent(err)
+			}
+			return firstRow.wrap(err)
+		}
+
+		var current *models.Order
+		for rows.Next() {
+			firstRow.received()
+			var order models.Order
+			var (
+				itemID                                      *int64
+				chrtID, price, sale, totalPrice, nmID, stat *int
+				trackNumber, rid, name, size, brand         *string
+			)
+			err := rows.Scan(
+				&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale, &order.InternalSignature,
+				&order.CustomerID, &order.DeliveryService, &order.ShardKey, &order.SMID, &order.DateCreated, &order.OOFShard, &order.UpdatedAt, &order.DeletedAt, &order.Status,
+				&order.Delivery.Name, &order.Delivery.Phone, &order.Delivery.Zip, &order.Delivery.City,
+				&order.Delivery.Address, &order.Delivery.Region, &order.Delivery.Email,
+				&order.Payment.Transaction, &order.Payment.RequestID, &order.Payment.Currency, &order.Payment.Provider,
+				&order.Payment.Amount, &order.Payment.PaymentDT, &order.Payment.Bank, &order.Payment.DeliveryCost,
+				&order.Payment.GoodsTotal, &order.Payment.CustomFee,
+				&itemID, &chrtID, &trackNumber, &price, &rid, &name, &sale, &size, &totalPrice, &nmID, &brand, &stat,
+			)
+			if err != nil {
+				return stop(fmt.Errorf("Ошибка при чтении заказа: %w", err))
+			}
+
+			// Начался следующий заказ — отдаем накопленный
+			if current == nil || current.OrderUID != order.OrderUID {
+				if current != nil {
+					if err := emit(current); err != nil {
+						return err
+					}
+				}
+				order.Items = []models.Item{}
+				current = &order
+			}
+
+			if itemID != nil {
+				current.Items = append(current.Items, models.Item{
+					ChrtID:      derefInt(chrtID),
+					TrackNumber: derefString(trackNumber),
+					Price:       derefInt(price),
+					RID:         derefString(rid),
+					Name:        derefString(name),
+					Sale:        derefInt(sale),
+					Size:        derefString(size),
+					TotalPrice:  derefInt(totalPrice),
+					NMID:        derefInt(nmID),
+					Brand:       derefString(brand),
+					Status:      derefInt(stat),
+				})
+			}
+		}
+
+		if err := rows.Err(); err != nil {
+			return stop(fmt.Errorf("Ошибка перебора заказов: %w", err))
+		}
+		if current != nil {
+			return emit(current)
+		}
+		return nil
+	}))
+	if fnErr != nil {
+		return fnErr
+	}
+	if err != nil {
+		return classify(err)
+	}
+
+	p.metrics.QueryDuration.WithLabelValues("stream_orders").Observe(time.Since(startTime).Seconds())
+	return nil
+}
+
+// ListOrderUIDs последовательно передает в fn UID всех заказов от старых к новым, читая их
+// одним курсором, — для задач, которым не нужны сами заказы. Ошибка fn или отмена ctx
+// прерывают запрос и закрывают курсор. Повторные попытки не выполняются.
+func (p *Postgres) ListOrderUIDs(ctx context.Context, fn func(uid string) error, opts ...interfaces.ReadOption) error {
+	options := interfaces.ApplyReadOptions(opts)
+	startTime := time.Now()
+	rows, err := p.pool.Query(ctx, ListOrderUIDsQuery, options.IncludeDeleted)
+	if err != nil {
+		p.metrics.QueryErrorsTotal.Inc()
+		p.metrics.QueryErrors.WithLabelValues("list_order_uids").Inc()
+		return classify(fmt.Errorf("Ошибка при запросе UID заказов: %w", err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var uid string
+		if err := rows.Scan(&uid); err != nil {
+			p.metrics.QueryErrorsTotal.Inc()
+			p.metrics.QueryErrors.WithLabelValues("list_order_uids").Inc()
+			return fmt.Errorf("Ошибка при чтении UID заказа: %w", err)
+		}
+		// Строки могли быть уже прочитаны из сети, поэтому отмену проверяем сами
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(uid); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		p.metrics.QueryErrorsTotal.Inc()
+		p.metrics.QueryErrors.WithLabelValues("list_order_uids").Inc()
+		return classify(fmt.Errorf("Ошибка перебора UID заказов: %w", err))
+	}
+
+	p.metrics.QueryDuration.WithLabelValues("list_order_uids").Observe(time.Since(startTime).Seconds())
+	return nil
+}
+
+// derefInt возвращает значение или 0 для NULL
+func derefInt(v *int) int {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+// derefString возвращает значение или пустую строку для NULL
+func derefString(v *string) string {
+	if v == nil {
+		return ""
+	}
+	return *v
+}
+
+// Ping проверяет соединение с БД; ошибки соединения оборачиваются в ErrUnavailable.
+// Используется проверкой готовности, поэтому повторяется по облегченной политике.
+func (p *Postgres) Ping(ctx context.Context) error {
+	err := retry.DoWithContext(ctx, retry.LightPolicy(), func(ctx context.Context) error {
+		ctx, cancel := p.readContext(ctx)
+		defer cancel()
+
+		if err := p.pool.Ping(ctx); err != nil {
+			p.metrics.ConnectionErrorsTotal.Inc()
+			return retryTransient(fmt.Errorf("Ошибка проверки соединения с БД: %w", err))
+		}
+		return nil
+	})
+	return classify(err)
 }
 
 // Close закрывает соединение с базой данных
 func (p *Postgres) Close() {
+	p.closeOnce.Do(func() {
+		if p.stop != nil {
+			close(p.stop)
+		}
+	})
 	p.pool.Close()
+	if p.replica != nil {
+		p.replica.pool.Close()
+	}
 	// Сбрасываем метрики соединений при закрытии
 	p.metrics.ConnectionOpen.Set(0)
 }
@@ -0,0 +1,1776 @@
+//go:build integration
+
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"test_service/internal/interfaces"
+	"test_service/internal/models"
+	"test_service/internal/outbox"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// queryCounter считает запросы, отправленные в БД через пул, и запоминает их параметры
+type queryCounter struct {
+	n atomic.Int64
+
+	mu   sync.Mutex
+	args map[string][]any // Параметры последнего выполнения по тексту запроса
+}
+
+func (c *queryCounter) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	c.n.Add(1)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.args == nil {
+		c.args = make(map[string][]any)
+	}
+	c.args[data.SQL] = data.Args
+	return ctx
+}
+
+// lastArgs возвращает параметры последнего выполнения запроса sql
+func (c *queryCounter) lastArgs(sql string) []any {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.args[sql]
+}
+
+func (c *queryCounter) TraceQueryEnd(context.Context, *pgx.Conn, pgx.TraceQueryEndData) {}
+
+// postgresDSN возвращает строку подключения к отдельной тестовой базе из POSTGRES_DSN.
+// Без нее тесты с тегом integration падают, а не пропускаются, чтобы прогон без базы
+// не выглядел успешным; -short пропускает их.
+func postgresDSN(tb testing.TB) string {
+	tb.Helper()
+	if testing.Short() {
+		tb.Skip("интеграционные тесты БД пропущены с -short")
+	}
+	dsn := os.Getenv("POSTGRES_DSN")
+	if dsn == "" {
+		tb.Fatal("POSTGRES_DSN не задан: интеграционным тестам БД нужна отдельная база PostgreSQL")
+	}
+	return dsn
+}
+
+// newCountingPostgres подключается к POSTGRES_DSN с подсчетом запросов
+func newCountingPostgres(t *testing.T, ctx context.Context) (*Postgres, *queryCounter) {
+	t.Helper()
+	dsn := postgresDSN(t)
+
+	config, err := pgxpool.ParseConfig(dsn)
+	require.NoError(t, err)
+	counter := &queryCounter{}
+	config.ConnConfig.Tracer = counter
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	p := NewPostgresFromPool(pool)
+	require.NoError(t, p.Init(ctx))
+	return p, counter
+}
+
+// seedOrders сохраняет n заказов с двумя товарами и удаляет их по завершении теста
+func seedOrders(t *testing.T, ctx context.Context, p *Postgres, prefix string, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		uid := fmt.Sprintf("%s-%03d", prefix, i)
+		order := testOrder(models.Order{
+			OrderUID:    uid,
+			TrackNumber: "TRACK-" + uid,
+			DateCreated: time.Now(),
+			Delivery:    models.Delivery{Name: "Test"},
+			Payment:     models.Payment{Transaction: uid},
+			Items: []models.Item{
+				{ChrtID: 1, TrackNumber: "TRACK-" + uid, Name: "first"},
+				{ChrtID: 2, TrackNumber: "TRACK-" + uid, Name: "second"},
+			},
+		})
+		require.NoError(t, p.SaveOrder(ctx, order))
+		t.Cleanup(func() { _ = p.DeleteOrder(context.Background(), uid) })
+	}
+}
+
+func TestPostgres_GetAllOrdersQueryCount(t *testing.T) {
+	ctx := context.Background()
+	p, counter := newCountingPostgres(t, ctx)
+	prefix := fmt.Sprintf("getall-%d", time.Now().UnixNano())
+
+	// Число запросов не зависит от числа заказов (раньше — один запрос товаров на заказ)
+	queries := func() int64 {
+		counter.n.Store(0)
+		orders, err := p.GetAllOrders(ctx)
+		require.NoError(t, err)
+		for _, order := range orders {
+			if strings.HasPrefix(order.OrderUID, prefix) {
+				require.Len(t, order.Items, 2, "товары привязаны к своему заказу")
+				assert.Equal(t, "first", order.Items[0].Name, "порядок товаров сохраняется")
+			}
+		}
+		return counter.n.Load()
+	}
+
+	seedOrders(t, ctx, p, prefix+"-a", 5)
+	few := queries()
+	seedOrders(t, ctx, p, prefix+"-b", 50)
+	many := queries()
+
+	assert.Equal(t, few, many)
+	assert.LessOrEqual(t, many, int64(4), "BEGIN, заказы, товары и ROLLBACK")
+}
+
+func TestPostgres_GetAllOrdersItems(t *testing.T) {
+	ctx := context.Background()
+	p := newIsolatedPostgres(t, ctx)
+	created := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	item := func(uid string, chrtID int) models.Item {
+		return models.Item{ChrtID: chrtID, TrackNumber: "T", RID: fmt.Sprintf("%s-%d", uid, chrtID), Name: uid, Brand: "b"}
+	}
+
+	// Товары заказов добавляются вперемешку, поэтому их id в таблице чередуются;
+	// у b и c одинаковое время создания
+	orders := map[string]*models.Order{
+		"a": testOrder(models.Order{OrderUID: "a", DateCreated: created.Add(time.Hour), Items: []models.Item{item("a", 3)}}),
+		"b": testOrder(models.Order{OrderUID: "b", DateCreated: created, Items: []models.Item{item("b", 2)}}),
+		"c": testOrder(models.Order{OrderUID: "c", DateCreated: created, Items: []models.Item{item("c", 1)}}),
+		"d": testOrder(models.Order{OrderUID: "d", DateCreated: created.Add(-time.Hour)}),
+	}
+	for _, uid := range []string{"a", "b", "c", "d"} {
+		require.NoError(t, p.SaveOrder(ctx, orders[uid]))
+	}
+	for _, uid := range []string{"c", "a", "b"} {
+		order := orders[uid]
+		order.Items = append(order.Items, item(uid, 10), item(uid, 5))
+		require.NoError(t, p.SaveOrder(ctx, order))
+	}
+	require.NoError(t, p.SoftDeleteOrder(ctx, "d"))
+
+	for i := 0; i < 3; i++ {
+		got, err := p.GetAllOrders(ctx)
+		require.NoError(t, err)
+
+		uids := make([]string, len(got))
+		for j, order := range got {
+			uids[j] = order.OrderUID
+			assert.Equal(t, orders[order.OrderUID].Items, order.Items, "товары заказа %s", order.OrderUID)
+		}
+		assert.Equal(t, []string{"a", "b", "c"}, uids, "от новых к старым, при равном времени — по UID")
+	}
+
+	all, err := p.GetAllOrders(ctx, interfaces.IncludeDeleted())
+	require.NoError(t, err)
+	require.Len(t, all, 4)
+	assert.Equal(t, "d", all[3].OrderUID)
+	assert.Equal(t, []models.Item{}, all[3].Items, "заказ без товаров получает пустой, а не nil список")
+}
+
+// saveSequential выполняет запросы пакета сохранения по одному, каждый отдельным сетевым обменом —
+// прежний способ, для сравнения с пакетом
+func saveSequential(ctx context.Context, tx pgx.Tx, b *writeBatch) error {
+	for _, q := range b.batch.QueuedQueries {
+		if _, err := tx.Exec(ctx, q.SQL, q.Arguments...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestPostgres_SaveOrderReplacesItems(t *testing.T) {
+	ctx := context.Background()
+	p, _ := newCountingPostgres(t, ctx)
+	uid := fmt.Sprintf("copy-%d", time.Now().UnixNano())
+	seedOrders(t, ctx, p, uid, 1)
+	uid += "-000"
+
+	order, err := p.GetOrder(ctx, uid)
+	require.NoError(t, err)
+	order.Items = []models.Item{{ChrtID: 3, Name: "third", Brand: "b", Status: 202}}
+	require.NoError(t, p.SaveOrder(ctx, order))
+
+	saved, err := p.GetOrder(ctx, uid)
+	require.NoError(t, err)
+	assert.Equal(t, order.Items, saved.Items, "старые товары удалены, новые записаны в порядке колонок")
+}
+
+// itemIDs возвращает id строк товаров заказа по chrt_id
+func itemIDs(t *testing.T, ctx context.Context, p *Postgres, uid string) map[int]int {
+	t.Helper()
+	rows, err := p.pool.Query(ctx, `SELECT chrt_id, id FROM items WHERE order_uid = $1`, uid)
+	require.NoError(t, err)
+	defer rows.Close()
+	ids := make(map[int]int)
+	for rows.Next() {
+		var chrtID, id int
+		require.NoError(t, rows.Scan(&chrtID, &id))
+		ids[chrtID] = id
+	}
+	require.NoError(t, rows.Err())
+	return ids
+}
+
+func TestPostgres_SaveOrderUpsertsItems(t *testing.T) {
+	ctx := context.Background()
+	p := newIsolatedPostgres(t, ctx)
+	item := func(chrtID int, name string) models.Item {
+		return models.Item{ChrtID: chrtID, TrackNumber: "TRACK", RID: fmt.Sprintf("rid-%d", chrtID), Name: name, Brand: "b"}
+	}
+	order := testOrder(models.Order{OrderUID: "upsert", DateCreated: time.Now().UTC().Truncate(time.Microsecond),
+		Items: []models.Item{item(1, "kept"), item(2, "modified"), item(3, "removed")}})
+	require.NoError(t, p.SaveOrder(ctx, order))
+	before := itemIDs(t, ctx, p, order.OrderUID)
+
+	// Второе сохранение: 1 без изменений, 2 изменен, 3 удален, 4 добавлен
+	order.Items = []models.Item{item(1, "kept"), item(2, "changed"), item(4, "added")}
+	require.NoError(t, p.SaveOrder(ctx, order))
+
+	saved, err := p.GetOrder(ctx, order.OrderUID)
+	require.NoError(t, err)
+	assert.Equal(t, order.Items, saved.Items)
+
+	after := itemIDs(t, ctx, p, order.OrderUID)
+	assert.Equal(t, before[1], after[1], "неизменный товар не переписывается")
+	assert.Equal(t, before[2], after[2], "измененный товар обновлен на месте")
+	assert.NotContains(t, after, 3)
+	assert.Greater(t, after[4], before[3], "новый товар добавлен в конец")
+
+	// Заказ без товаров удаляет все товары
+	order.Items = nil
+	require.NoError(t, p.SaveOrder(ctx, order))
+	assert.Empty(t, itemIDs(t, ctx, p, order.OrderUID))
+}
+
+func TestPostgres_SaveOrderDuplicateChrtID(t *testing.T) {
+	ctx := context.Background()
+	for name, multiRow := range map[string]bool{"Unnest": false, "MultiRow": true} {
+		t.Run(name, func(t *testing.T) {
+			p := newIsolatedPostgres(t, ctx)
+			p.SetMultiRowItems(multiRow, 0)
+			order := testOrder(models.Order{OrderUID: "dup", DateCreated: time.Now(), Items: []models.Item{
+				{ChrtID: 1, Name: "first"}, {ChrtID: 2, Name: "other"}, {ChrtID: 1, Name: "last"},
+			}})
+
+			// Повтор chrt_id не превращается в постоянную ошибку UPSERT: сохраняется последний товар
+			require.NoError(t, p.SaveOrder(ctx, order))
+			saved, err := p.GetOrder(ctx, "dup")
+			require.NoError(t, err)
+			assert.Equal(t, []models.Item{{ChrtID: 2, Name: "other"}, {ChrtID: 1, Name: "last"}}, saved.Items)
+		})
+	}
+}
+
+// BenchmarkPostgres_SaveOrder сравнивает запросы сохранения заказа по одному и одним пакетом.
+// Разница растет с задержкой сети: на удаленной БД пакет экономит RTT на каждый запрос после первого.
+func BenchmarkPostgres_SaveOrder(b *testing.B) {
+	dsn := postgresDSN(b)
+	ctx := context.Background()
+	p, err := NewPostgres(ctx, dsn, PoolConfig{})
+	require.NoError(b, err)
+	defer p.Close()
+	require.NoError(b, p.Init(ctx))
+
+	uid := fmt.Sprintf("bench-%d", time.Now().UnixNano())
+	require.NoError(b, p.SaveOrder(ctx, testOrder(models.Order{OrderUID: uid, DateCreated: time.Now()})))
+	defer func() { _ = p.DeleteOrder(ctx, uid) }()
+
+	var saved savedOrder
+	strategies := []struct {
+		name string
+		save func(context.Context, pgx.Tx, *writeBatch) error
+	}{
+		{"Sequential", saveSequential},
+		{"Batch", p.sendBatch},
+	}
+	for _, n := range []int{1, 10, 100} {
+		order := testOrder(models.Order{OrderUID: uid, DateCreated: time.Now(), Items: make([]models.Item, n)})
+		for i := range order.Items {
+			order.Items[i] = models.Item{ChrtID: i, TrackNumber: "TRACK", Name: fmt.Sprintf("item-%d", i)}
+		}
+		for _, strategy := range strategies {
+			b.Run(fmt.Sprintf("%s/items=%d", strategy.name, n), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					tx, err := p.pool.Begin(ctx)
+					require.NoError(b, err)
+					require.NoError(b, strategy.save(ctx, tx, saveOrderBatch(order, &saved, saveOptions{})))
+					require.NoError(b, tx.Commit(ctx))
+				}
+			})
+		}
+	}
+}
+
+// testOrder дополняет заказ значениями, которых требуют ограничения схемы (sm_id > 0, payment_dt > 0),
+// если тест их не задал
+func testOrder(order models.Order) *models.Order {
+	if order.SMID == 0 {
+		order.SMID = 1
+	}
+	if order.Payment.PaymentDT == 0 {
+		order.Payment.PaymentDT = 1637907727
+	}
+	return &order
+}
+
+// newIsolatedPostgres подключается к POSTGRES_DSN с отдельной пустой схемой, удаляемой после теста
+func newIsolatedPostgres(t *testing.T, ctx context.Context) *Postgres {
+	t.Helper()
+	p := NewPostgresFromPool(newIsolatedPool(t, ctx))
+	require.NoError(t, p.Init(ctx))
+	return p
+}
+
+// newPartitionedPostgres то же, что newIsolatedPostgres, с секционированием таблиц заказов по месяцам
+func newPartitionedPostgres(t *testing.T, ctx context.Context) *Postgres {
+	t.Helper()
+	p := NewPostgresFromPool(newIsolatedPool(t, ctx))
+	p.SetPartitioning(true)
+	require.NoError(t, p.Init(ctx))
+	require.True(t, p.Partitioned())
+	return p
+}
+
+// newIsolatedPool подключается к POSTGRES_DSN с отдельной пустой схемой, удаляемой после теста
+func newIsolatedPool(t *testing.T, ctx context.Context) *pgxpool.Pool {
+	t.Helper()
+	dsn := postgresDSN(t)
+
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	admin, err := pgx.Connect(ctx, dsn)
+	require.NoError(t, err)
+	defer admin.Close(ctx)
+	_, err = admin.Exec(ctx, "CREATE SCHEMA "+schema)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		conn, err := pgx.Connect(context.Background(), dsn)
+		if err != nil {
+			return
+		}
+		defer conn.Close(context.Background())
+		_, _ = conn.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE")
+	})
+
+	config, err := pgxpool.ParseConfig(dsn)
+	require.NoError(t, err)
+	config.ConnConfig.RuntimeParams["search_path"] = schema
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+func TestPostgres_GetOrdersByCustomerID(t *testing.T) {
+	ctx := context.Background()
+	p, counter := newCountingPostgres(t, ctx)
+	prefix := fmt.Sprintf("customer-%d", time.Now().UnixNano())
+	customer := prefix + "-c1"
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		uid := fmt.Sprintf("%s-%d", prefix, i)
+		order := testOrder(models.Order{
+			OrderUID:    uid,
+			TrackNumber: "TRACK",
+			CustomerID:  customer,
+			DateCreated: base.Add(time.Duration(i) * time.Minute),
+			Delivery:    models.Delivery{Name: "Test", City: "Moscow"},
+			Payment:     models.Payment{Transaction: uid, Amount: 100 * (i + 1)},
+			Items:       []models.Item{{ChrtID: i, Name: "first"}, {ChrtID: i, Name: "second"}},
+		})
+		require.NoError(t, p.SaveOrder(ctx, order))
+		t.Cleanup(func() { _ = p.DeleteOrder(context.Background(), uid) })
+	}
+	other := prefix + "-other"
+	require.NoError(t, p.SaveOrder(ctx, testOrder(models.Order{OrderUID: other, CustomerID: prefix + "-c2", DateCreated: base})))
+	t.Cleanup(func() { _ = p.DeleteOrder(context.Background(), other) })
+
+	orders, err := p.GetOrdersByCustomerID(ctx, customer, 2, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []any{customer, 2, 0, false}, counter.lastArgs(GetOrdersByCustomerIDQuery), "параметры привязаны по порядку")
+	require.Equal(t, []string{prefix + "-2", prefix + "-1"}, pageUIDs(orders), "от новых к старым")
+
+	order := orders[0]
+	assert.Equal(t, customer, order.CustomerID)
+	assert.Equal(t, "Moscow", order.Delivery.City)
+	assert.Equal(t, 300, order.Payment.Amount)
+	assert.True(t, base.Add(2*time.Minute).Equal(order.DateCreated))
+	require.Len(t, order.Items, 2)
+	assert.Equal(t, "first", order.Items[0].Name)
+	assert.Equal(t, 2, order.Items[0].ChrtID)
+
+	orders, err = p.GetOrdersByCustomerID(ctx, customer, 2, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{prefix + "-0"}, pageUIDs(orders), "offset пропускает первые заказы")
+
+	orders, err = p.GetOrdersByCustomerID(ctx, prefix+"-missing", 10, 0)
+	require.NoError(t, err)
+	assert.Empty(t, orders)
+}
+
+func TestPostgres_GetOrderByTrackNumber(t *testing.T) {
+	ctx := context.Background()
+	p := newIsolatedPostgres(t, ctx)
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i, uid := range []string{"track-old", "track-new"} {
+		require.NoError(t, p.SaveOrder(ctx, testOrder(models.Order{
+			OrderUID:    uid,
+			TrackNumber: "WBILTRACK",
+			DateCreated: base.Add(time.Duration(i) * time.Minute),
+			Delivery:    models.Delivery{City: "Moscow"},
+			Payment:     models.Payment{Transaction: uid, Amount: 100},
+			Items:       []models.Item{{ChrtID: i, Name: uid}},
+		})))
+	}
+	require.NoError(t, p.SaveOrder(ctx, testOrder(models.Order{OrderUID: "track-other", TrackNumber: "OTHER", DateCreated: base})))
+
+	var indexed bool
+	require.NoError(t, p.pool.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM pg_indexes WHERE schemaname = current_schema() AND indexname = 'idx_orders_track_number')`,
+	).Scan(&indexed))
+	assert.True(t, indexed, "Init создает индекс по трек-номеру")
+
+	orders, err := p.GetOrderByTrackNumber(ctx, "WBILTRACK")
+	require.NoError(t, err)
+	require.Equal(t, []string{"track-new", "track-old"}, pageUIDs(orders), "от новых к старым")
+	assert.Equal(t, "Moscow", orders[0].Delivery.City)
+	assert.Equal(t, 100, orders[0].Payment.Amount)
+	require.Len(t, orders[0].Items, 1)
+	assert.Equal(t, "track-new", orders[0].Items[0].Name)
+
+	_, err = p.GetOrderByTrackNumber(ctx, "MISSING")
+	assert.ErrorIs(t, err, models.ErrOrderNotFound)
+}
+
+// fullOrder заказ, в котором заполнено каждое сохраняемое поле; товары не упорядочены по chrt_id
+func fullOrder(uid string) *models.Order {
+	return &models.Order{
+		OrderUID:          uid,
+		TrackNumber:       "WBILMTESTTRACK",
+		Entry:             "WBIL",
+		Locale:            "en",
+		InternalSignature: "signature",
+		CustomerID:        "customer",
+		DeliveryService:   "meest",
+		ShardKey:          "9",
+		SMID:              99,
+		DateCreated:       time.Date(2021, 11, 26, 6, 22, 19, 123456000, time.UTC),
+		OOFShard:          "1",
+		Delivery: models.Delivery{
+			Name: "Test Testov", Phone: "+9720000000", Zip: "2639809", City: "Kiryat Mozkin",
+			Address: "Ploshad Mira 15", Region: "Kraiot", Email: "test@gmail.com",
+		},
+		Payment: models.Payment{
+			Transaction: uid, RequestID: "request", Currency: "USD", Provider: "wbpay", Amount: 1817,
+			PaymentDT: 1637907727, Bank: "alpha", DeliveryCost: 1500, GoodsTotal: 317, CustomFee: 7,
+		},
+		Items: []models.Item{
+			{ChrtID: 30, TrackNumber: "WBILMTESTTRACK", Price: 453, RID: "rid-30", Name: "Mascaras", Sale: 30,
+				Size: "0", TotalPrice: 317, NMID: 2389212, Brand: "Vivienne Sabo", Status: 202},
+			{ChrtID: 10, TrackNumber: "WBILMTESTTRACK", Price: 100, RID: "rid-10", Name: "Lipstick", Sale: 10,
+				Size: "S", TotalPrice: 90, NMID: 2389213, Brand: "Sabo", Status: 200},
+			{ChrtID: 20, TrackNumber: "WBILMTESTTRACK", Price: 50, RID: "rid-20", Name: "Brush", Sale: 0,
+				Size: "M", TotalPrice: 50, NMID: 2389214, Brand: "Brand", Status: 201},
+		},
+	}
+}
+
+// assertSameOrder сравнивает заказ из БД с сохраненным; моменты времени сравниваются без учета зоны
+func assertSameOrder(t *testing.T, want *models.Order, got models.Order) {
+	t.Helper()
+	assert.True(t, want.DateCreated.Equal(got.DateCreated), "date_created: %v != %v", want.DateCreated, got.DateCreated)
+	assert.True(t, want.UpdatedAt.Equal(got.UpdatedAt), "updated_at: %v != %v", want.UpdatedAt, got.UpdatedAt)
+	got.DateCreated, got.UpdatedAt = want.DateCreated, want.UpdatedAt
+	assert.Equal(t, *want, got)
+}
+
+func TestPostgres_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	p := newIsolatedPostgres(t, ctx)
+	order := fullOrder("round-trip")
+
+	t.Run("Insert", func(t *testing.T) {
+		require.NoError(t, p.SaveOrder(ctx, order))
+		assert.False(t, order.UpdatedAt.IsZero())
+		assert.Equal(t, models.StatusNew, order.Status)
+
+		saved, err := p.GetOrder(ctx, order.OrderUID)
+		require.NoError(t, err)
+		assertSameOrder(t, order, *saved)
+	})
+
+	t.Run("Upsert", func(t *testing.T) {
+		updated := fullOrder(order.OrderUID)
+		updated.TrackNumber, updated.Entry, updated.Locale = "NEWTRACK", "WBIL2", "ru"
+		updated.InternalSignature, updated.CustomerID, updated.DeliveryService = "", "customer-2", "cdek"
+		updated.ShardKey, updated.SMID, updated.OOFShard = "3", 7, "2"
+		updated.DateCreated = updated.DateCreated.Add(time.Hour)
+		updated.Delivery = models.Delivery{Name: "Ivan", Phone: "+79990000000", Zip: "101000", City: "Moscow",
+			Address: "Tverskaya 1", Region: "Moscow", Email: "ivan@example.com"}
+		updated.Payment.Amount, updated.Payment.Currency, updated.Payment.CustomFee = 500, "RUB", 0
+		// Товар 10 удален, 30 изменен, 40 добавлен; оставшиеся сохраняют порядок вставки
+		added := updated.Items[1]
+		added.ChrtID, added.RID = 40, "rid-40"
+		updated.Items = []models.Item{updated.Items[0], updated.Items[2], added}
+		updated.Items[0].Price = 75
+
+		require.NoError(t, p.SaveOrder(ctx, updated))
+		assert.False(t, updated.UpdatedAt.Before(order.UpdatedAt))
+
+		saved, err := p.GetOrder(ctx, order.OrderUID)
+		require.NoError(t, err)
+		assertSameOrder(t, updated, *saved)
+		order = updated
+	})
+
+	t.Run("GetAllOrders", func(t *testing.T) {
+		orders, err := p.GetAllOrders(ctx)
+		require.NoError(t, err)
+		require.Len(t, orders, 1)
+		assertSameOrder(t, order, orders[0])
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		require.NoError(t, p.DeleteOrder(ctx, order.OrderUID))
+
+		_, err := p.GetOrder(ctx, order.OrderUID)
+		assert.ErrorIs(t, err, models.ErrOrderNotFound)
+		assert.ErrorIs(t, p.DeleteOrder(ctx, order.OrderUID), models.ErrOrderNotFound)
+
+		orders, err := p.GetAllOrders(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, orders)
+	})
+}
+
+func TestPostgres_DeleteOrder(t *testing.T) {
+	ctx := context.Background()
+	p := newIsolatedPostgres(t, ctx)
+
+	// related количество связанных с заказом записей в каждой дочерней таблице
+	related := func(t *testing.T, uid string) map[string]int {
+		t.Helper()
+		counts := make(map[string]int)
+		for _, table := range []string{"delivery", "payment", "items"} {
+			var n int
+			require.NoError(t, p.pool.QueryRow(ctx, "SELECT count(*) FROM "+table+" WHERE order_uid = $1", uid).Scan(&n))
+			counts[table] = n
+		}
+		return counts
+	}
+
+	t.Run("Found", func(t *testing.T) {
+		uid := "delete-found"
+		require.NoError(t, p.SaveOrder(ctx, testOrder(models.Order{
+			OrderUID: uid,
+			Delivery: models.Delivery{City: "Moscow"},
+			Payment:  models.Payment{Transaction: uid},
+			Items:    []models.Item{{ChrtID: 1, Name: "first"}, {ChrtID: 2, Name: "second"}},
+		})))
+		require.Equal(t, map[string]int{"delivery": 1, "payment": 1, "items": 2}, related(t, uid))
+
+		require.NoError(t, p.DeleteOrder(ctx, uid))
+		assert.Equal(t, map[string]int{"delivery": 0, "payment": 0, "items": 0}, related(t, uid), "связанные записи удалены каскадно")
+		_, err := p.GetOrder(ctx, uid)
+		assert.ErrorIs(t, err, models.ErrOrderNotFound)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		assert.ErrorIs(t, p.DeleteOrder(ctx, "delete-missing"), models.ErrOrderNotFound)
+	})
+
+	t.Run("ForeignKeyIntegrity", func(t *testing.T) {
+		kept := "delete-kept"
+		require.NoError(t, p.SaveOrder(ctx, testOrder(models.Order{OrderUID: kept, Items: []models.Item{{ChrtID: 1}}})))
+		require.NoError(t, p.SaveOrder(ctx, testOrder(models.Order{OrderUID: "delete-other", Items: []models.Item{{ChrtID: 1}}})))
+		require.NoError(t, p.DeleteOrder(ctx, "delete-other"))
+
+		// Соседний заказ не затронут
+		assert.Equal(t, map[string]int{"delivery": 1, "payment": 1, "items": 1}, related(t, kept))
+
+		// Товар удаленного заказа вставить нельзя: внешний ключ по-прежнему действует
+		_, err := p.pool.Exec(ctx, `INSERT INTO items (order_uid, chrt_id, track_number, price, rid, name, sale, size,
+			total_price, nm_id, brand, status) VALUES ($1, 1, 'T', 0, 'r', 'n', 0, 's', 0, 1, 'b', 0)`, "delete-other")
+		var pgErr *pgconn.PgError
+		require.ErrorAs(t, err, &pgErr)
+		assert.Equal(t, "23503", pgErr.Code, "foreign_key_violation")
+	})
+}
+
+func TestPostgres_GetOrderNotFound(t *testing.T) {
+	ctx := context.Background()
+	p, counter := newCountingPostgres(t, ctx)
+	notFound := testutil.ToFloat64(p.metrics.OrdersNotFoundTotal)
+	queryErrors := testutil.ToFloat64(p.metrics.QueryErrors.WithLabelValues("get_order_by_uid"))
+
+	counter.n.Store(0)
+	start := time.Now()
+	order, err := p.GetOrder(ctx, fmt.Sprintf("missing-%d", time.Now().UnixNano()))
+
+	assert.Nil(t, order)
+	assert.ErrorIs(t, err, models.ErrOrderNotFound)
+	assert.Equal(t, int64(1), counter.n.Load(), "отсутствующий заказ не запрашивается повторно")
+	assert.Less(t, time.Since(start), 100*time.Millisecond, "без пауз между попытками")
+	assert.Equal(t, notFound+1, testutil.ToFloat64(p.metrics.OrdersNotFoundTotal))
+	assert.Equal(t, queryErrors, testutil.ToFloat64(p.metrics.QueryErrors.WithLabelValues("get_order_by_uid")))
+}
+
+func TestPostgres_OrderExists(t *testing.T) {
+	ctx := context.Background()
+	p := newIsolatedPostgres(t, ctx)
+	require.NoError(t, p.SaveOrder(ctx, testOrder(models.Order{OrderUID: "exists-present"})))
+
+	t.Run("Present", func(t *testing.T) {
+		exists, err := p.OrderExists(ctx, "exists-present")
+		require.NoError(t, err)
+		assert.True(t, exists)
+	})
+
+	t.Run("Absent", func(t *testing.T) {
+		exists, err := p.OrderExists(ctx, "exists-absent")
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("QueryError", func(t *testing.T) {
+		closed := newIsolatedPostgres(t, ctx)
+		closed.pool.Close()
+
+		exists, err := closed.OrderExists(ctx, "exists-present")
+		assert.Error(t, err)
+		assert.False(t, exists)
+	})
+}
+
+func TestPostgres_CountOrders(t *testing.T) {
+	ctx := context.Background()
+	p := newIsolatedPostgres(t, ctx)
+	now := time.Now().UTC()
+
+	total, err := p.CountOrders(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, total)
+
+	saveAt(t, ctx, p, "count-old", now.Add(-48*time.Hour))
+	saveAt(t, ctx, p, "count-new", now.Add(-time.Hour))
+
+	total, err = p.CountOrders(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+
+	since, err := p.CountOrdersSince(ctx, now.Add(-24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), since)
+}
+
+func TestPostgres_GetOrdersSince(t *testing.T) {
+	ctx := context.Background()
+	p := newIsolatedPostgres(t, ctx)
+	since := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	saveAt(t, ctx, p, "since-before", since.Add(-time.Second))
+	saveAt(t, ctx, p, "since-equal-b", since)
+	saveAt(t, ctx, p, "since-equal-a", since)
+	saveAt(t, ctx, p, "since-after", since.Add(time.Second))
+
+	orders, err := p.GetOrdersSince(ctx, since)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"since-equal-a", "since-equal-b", "since-after"}, pageUIDs(orders),
+		"заказы с тем же временем создания включаются, от старых к новым")
+	require.Len(t, orders[0].Items, 1)
+	assert.Equal(t, "since-equal-a", orders[0].Items[0].Name)
+
+	orders, err = p.GetOrdersSince(ctx, since.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, orders)
+}
+
+// saveAt сохраняет заказ с товаром и заданным временем создания
+func saveAt(t *testing.T, ctx context.Context, p *Postgres, uid string, created time.Time) {
+	t.Helper()
+	require.NoError(t, p.SaveOrder(ctx, testOrder(models.Order{
+		OrderUID:    uid,
+		DateCreated: created,
+		Items:       []models.Item{{ChrtID: 1, Name: uid}},
+	})))
+}
+
+// pageUIDs возвращает UID заказов страницы
+func pageUIDs(orders []models.Order) []string {
+	uids := make([]string, len(orders))
+	for i := range orders {
+		uids[i] = orders[i].OrderUID
+	}
+	return uids
+}
+
+func TestPostgres_GetOrdersPage(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("EmptyTable", func(t *testing.T) {
+		p := newIsolatedPostgres(t, ctx)
+		orders, next, err := p.GetOrdersPage(ctx, "", 10)
+		require.NoError(t, err)
+		assert.Empty(t, orders)
+		assert.Empty(t, next)
+	})
+
+	t.Run("ExactPageBoundary", func(t *testing.T) {
+		p := newIsolatedPostgres(t, ctx)
+		for i := 0; i < 4; i++ {
+			saveAt(t, ctx, p, fmt.Sprintf("order-%d", i), base.Add(time.Duration(i)*time.Minute))
+		}
+
+		orders, next, err := p.GetOrdersPage(ctx, "", 2)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"order-3", "order-2"}, pageUIDs(orders), "от новых к старым")
+		require.NotEmpty(t, next)
+		require.Len(t, orders[0].Items, 1, "товары загружаются для страницы")
+		assert.Equal(t, "order-3", orders[0].Items[0].Name)
+
+		orders, next, err = p.GetOrdersPage(ctx, next, 2)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"order-1", "order-0"}, pageUIDs(orders))
+		assert.Empty(t, next, "страница ровно до конца таблицы — последняя")
+	})
+
+	t.Run("StableAcrossInserts", func(t *testing.T) {
+		p := newIsolatedPostgres(t, ctx)
+		// Одинаковое время создания: порядок задает order_uid
+		for _, uid := range []string{"order-a", "order-b", "order-c"} {
+			saveAt(t, ctx, p, uid, base)
+		}
+
+		orders, next, err := p.GetOrdersPage(ctx, "", 2)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"order-c", "order-b"}, pageUIDs(orders))
+
+		// Новые заказы между запросами не сдвигают следующую страницу
+		saveAt(t, ctx, p, "order-new", base.Add(time.Hour))
+		saveAt(t, ctx, p, "order-d", base)
+
+		orders, next, err = p.GetOrdersPage(ctx, next, 2)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"order-a"}, pageUIDs(orders))
+		assert.Empty(t, next)
+	})
+}
+
+// migrationChecksums возвращает записанные контрольные суммы миграций по id
+func migrationChecksums(t *testing.T, ctx context.Context, p *Postgres) map[string]*string {
+	t.Helper()
+	rows, err := p.pool.Query(ctx, GetAppliedMigrationsQuery)
+	require.NoError(t, err)
+	defer rows.Close()
+	sums := make(map[string]*string)
+	for rows.Next() {
+		var id string
+		var sum *string
+		require.NoError(t, rows.Scan(&id, &sum))
+		sums[id] = sum
+	}
+	require.NoError(t, rows.Err())
+	return sums
+}
+
+func TestPostgres_InitMigrations(t *testing.T) {
+	ctx := context.Background()
+	migrations, err := loadMigrations(migrationFiles, "migrations")
+	require.NoError(t, err)
+
+	t.Run("Idempotent", func(t *testing.T) {
+		p := newIsolatedPostgres(t, ctx)
+		require.NoError(t, p.Init(ctx))
+
+		sums := migrationChecksums(t, ctx, p)
+		require.Len(t, sums, len(migrations))
+		for _, m := range migrations {
+			require.NotNil(t, sums[m.id], m.id)
+			assert.Equal(t, m.checksum, *sums[m.id])
+		}
+		assert.False(t, p.Partitioned(), "секционирование по умолчанию выключено")
+	})
+
+	t.Run("EnablePartitioning", func(t *testing.T) {
+		p := newIsolatedPostgres(t, ctx)
+		existing := fullOrder("before-partitioning")
+		require.NoError(t, p.SaveOrder(ctx, existing))
+
+		// Включение на инициализированной базе применяет только миграцию секционирования
+		partitioned := NewPostgresFromPool(p.pool)
+		partitioned.SetPartitioning(true)
+		require.NoError(t, partitioned.Init(ctx))
+		assert.True(t, partitioned.Partitioned())
+		assert.Len(t, migrationChecksums(t, ctx, p), len(migrations)+1)
+
+		got, err := partitioned.GetOrder(ctx, existing.OrderUID)
+		require.NoError(t, err)
+		assertSameOrder(t, existing, *got)
+
+		// Выключение не отменяет секционирование: запросы сохранения выбираются по схеме
+		again := NewPostgresFromPool(p.pool)
+		require.NoError(t, again.Init(ctx))
+		assert.True(t, again.Partitioned())
+		existing.Items = existing.Items[:1]
+		require.NoError(t, again.SaveOrder(ctx, existing))
+		got, err = again.GetOrder(ctx, existing.OrderUID)
+		require.NoError(t, err)
+		assertSameOrder(t, existing, *got)
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		p := newIsolatedPostgres(t, ctx)
+		_, err := p.pool.Exec(ctx, `DROP TABLE items, payment, delivery, orders, schema_migrations`)
+		require.NoError(t, err)
+
+		// Второй «под» с отдельным пулом в той же схеме
+		pool, err := pgxpool.NewWithConfig(ctx, p.pool.Config())
+		require.NoError(t, err)
+		t.Cleanup(pool.Close)
+		other := NewPostgresFromPool(pool)
+
+		var wg sync.WaitGroup
+		errs := make([]error, 2)
+		for i, db := range []*Postgres{p, other} {
+			wg.Add(1)
+			go func(i int, db *Postgres) {
+				defer wg.Done()
+				errs[i] = db.Init(ctx)
+			}(i, db)
+		}
+		wg.Wait()
+		require.NoError(t, errs[0])
+		require.NoError(t, errs[1])
+
+		assert.Len(t, migrationChecksums(t, ctx, p), len(migrations))
+		require.NoError(t, p.SaveOrder(ctx, testOrder(models.Order{OrderUID: "migrated", DateCreated: time.Now()})))
+	})
+
+	t.Run("ChecksumMismatch", func(t *testing.T) {
+		p := newIsolatedPostgres(t, ctx)
+		_, err := p.pool.Exec(ctx, `UPDATE schema_migrations SET checksum = 'changed' WHERE id = $1`, migrations[0].id)
+		require.NoError(t, err)
+
+		err = p.Init(ctx)
+		require.ErrorIs(t, err, ErrMigrationChecksum)
+		assert.Contains(t, err.Error(), migrations[0].id)
+	})
+
+	t.Run("LegacyRecords", func(t *testing.T) {
+		// База, инициализированная до файловых миграций: записи без контрольных сумм
+		p := newIsolatedPostgres(t, ctx)
+		_, err := p.pool.Exec(ctx, `UPDATE schema_migrations SET checksum = NULL`)
+		require.NoError(t, err)
+		_, err = p.pool.Exec(ctx, `INSERT INTO schema_migrations (id) VALUES ('0001_orders_updated_at')`)
+		require.NoError(t, err)
+
+		require.NoError(t, p.Init(ctx))
+
+		sums := migrationChecksums(t, ctx, p)
+		require.NotNil(t, sums[migrations[0].id])
+		assert.Equal(t, migrations[0].checksum, *sums[migrations[0].id])
+		assert.Nil(t, sums["0001_orders_updated_at"])
+	})
+}
+
+func TestPostgres_SaveOrderBatchError(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	p := newIsolatedPostgres(t, ctx)
+
+	// currency VARCHAR(10): пакет останавливается на платеже, транзакция откатывается целиком
+	order := testOrder(models.Order{OrderUID: "batch-error", DateCreated: time.Now(),
+		Payment: models.Payment{Currency: strings.Repeat("X", 11)}, Items: []models.Item{{ChrtID: 1}}})
+	before := testutil.ToFloat64(p.metrics.QueryErrors.WithLabelValues("save_payment"))
+	err := p.SaveOrder(ctx, order)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Ошибка при записи payment")
+	assert.Greater(t, testutil.ToFloat64(p.metrics.QueryErrors.WithLabelValues("save_payment")), before)
+
+	exists, err := p.OrderExists(ctx, order.OrderUID)
+	require.NoError(t, err)
+	assert.False(t, exists)
+	assert.Empty(t, itemIDs(t, ctx, p, order.OrderUID))
+}
+
+func TestPostgres_QueryExecModes(t *testing.T) {
+	ctx := context.Background()
+	base := newIsolatedPostgres(t, ctx)
+
+	for name := range queryExecModes {
+		t.Run(name, func(t *testing.T) {
+			config := base.pool.Config()
+			require.NoError(t, PoolConfig{QueryExecMode: name}.apply(config))
+			pool, err := pgxpool.NewWithConfig(ctx, config)
+			require.NoError(t, err)
+			t.Cleanup(pool.Close)
+			p := NewPostgresFromPool(pool)
+
+			// Дважды: при кэшировании второй раз используются подготовленные выражения
+			uid := "mode-" + name
+			for i := 0; i < 2; i++ {
+				order := testOrder(models.Order{OrderUID: uid, DateCreated: time.Now().UTC().Truncate(time.Microsecond),
+					Items: []models.Item{{ChrtID: 1, Name: "first"}, {ChrtID: i + 2, Name: "second"}}})
+				require.NoError(t, p.SaveOrder(ctx, order))
+
+				saved, err := p.GetOrder(ctx, uid)
+				require.NoError(t, err)
+				assert.Equal(t, order.Items, saved.Items)
+			}
+		})
+	}
+}
+
+func TestPostgres_SaveOrderSerializable(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	p := newIsolatedPostgres(t, ctx)
+	p.SetSaveIsolation(pgx.Serializable)
+
+	// Одновременные сохранения одного заказа конфликтуют при serializable;
+	// конфликт (40001) повторяется и все сохранения завершаются успешно
+	const writers = 4
+	var wg sync.WaitGroup
+	errs := make([]error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = p.SaveOrder(ctx, testOrder(models.Order{OrderUID: "serializable", DateCreated: time.Now(),
+				Items: []models.Item{{ChrtID: 1, Name: fmt.Sprintf("writer-%d", i)}}}))
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+
+	saved, err := p.GetOrder(ctx, "serializable")
+	require.NoError(t, err)
+	require.Len(t, saved.Items, 1)
+}
+
+func TestPostgres_ReadReplica(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	p := newIsolatedPostgres(t, ctx)
+
+	// Второй пул к той же схеме изображает реплику без отставания
+	replicaPool, err := pgxpool.NewWithConfig(ctx, p.pool.Config())
+	require.NoError(t, err)
+	t.Cleanup(replicaPool.Close)
+	p.replica = &replica{pool: replicaPool}
+	require.NoError(t, p.PingReplica(ctx))
+
+	require.NoError(t, p.SaveOrder(ctx, testOrder(models.Order{OrderUID: "replica", DateCreated: time.Now(),
+		Items: []models.Item{{ChrtID: 1, Name: "item"}}})))
+
+	replicaReads := p.metrics.ReadQueries.WithLabelValues("get_order", TargetReplica)
+	before := testutil.ToFloat64(replicaReads)
+	saved, err := p.GetOrder(ctx, "replica")
+	require.NoError(t, err)
+	require.Len(t, saved.Items, 1)
+	assert.Equal(t, before+1, testutil.ToFloat64(replicaReads))
+
+	// Без исправной реплики чтение идет на основной сервер
+	p.setReplicaHealthy(false, nil)
+	primaryReads := p.metrics.ReadQueries.WithLabelValues("get_order", TargetPrimary)
+	before = testutil.ToFloat64(primaryReads)
+	_, err = p.GetOrder(ctx, "replica")
+	require.NoError(t, err)
+	assert.Equal(t, before+1, testutil.ToFloat64(primaryReads))
+}
+
+func TestPostgres_ArchiveOrdersBefore(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	cutoff := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// count возвращает число строк таблицы в схеме теста
+	count := func(t *testing.T, p *Postgres, table string) int {
+		var n int
+		require.NoError(t, p.pool.QueryRow(ctx, "SELECT count(*) FROM "+table).Scan(&n))
+		return n
+	}
+
+	for _, tt := range []struct {
+		name      string
+		old       int
+		batchSize int
+	}{
+		{"PartialLastBatch", 5, 2},
+		{"ExactBatches", 4, 2},
+		{"SingleBatch", 3, 10},
+		{"NothingToArchive", 0, 2},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newIsolatedPostgres(t, ctx)
+			for i := 0; i < tt.old; i++ {
+				saveAt(t, ctx, p, fmt.Sprintf("old-%d", i), cutoff.Add(-time.Duration(i+1)*time.Hour))
+			}
+			saveAt(t, ctx, p, "at-cutoff", cutoff)
+			saveAt(t, ctx, p, "recent", cutoff.Add(time.Hour))
+
+			archived, err := p.ArchiveOrdersBefore(ctx, cutoff, tt.batchSize)
+			require.NoError(t, err)
+			assert.Equal(t, int64(tt.old), archived)
+
+			// Заказы до cutoff перенесены вместе с дочерними записями, остальные на месте
+			for _, table := range []string{"orders", "delivery", "payment", "items"} {
+				assert.Equal(t, 2, count(t, p, table), table)
+				assert.Equal(t, tt.old, count(t, p, table+"_archive"), table+"_archive")
+			}
+			_, err = p.GetOrder(ctx, "recent")
+			require.NoError(t, err)
+			if tt.old > 0 {
+				_, err = p.GetOrder(ctx, "old-0")
+				assert.ErrorIs(t, err, models.ErrOrderNotFound)
+			}
+
+			// Повторный запуск ничего не переносит
+			archived, err = p.ArchiveOrdersBefore(ctx, cutoff, tt.batchSize)
+			require.NoError(t, err)
+			assert.Zero(t, archived)
+		})
+	}
+}
+
+func TestPostgres_SoftDeleteOrder(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	p := newIsolatedPostgres(t, ctx)
+	created := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, uid := range []string{"visible", "hidden"} {
+		require.NoError(t, p.SaveOrder(ctx, testOrder(models.Order{OrderUID: uid, TrackNumber: "TRACK", CustomerID: "customer",
+			DateCreated: created, Items: []models.Item{{ChrtID: 1, Name: uid}}})))
+	}
+	require.NoError(t, p.SoftDeleteOrder(ctx, "hidden"))
+	assert.ErrorIs(t, p.SoftDeleteOrder(ctx, "hidden"), models.ErrOrderNotFound, "повторное удаление")
+	assert.ErrorIs(t, p.SoftDeleteOrder(ctx, "missing"), models.ErrOrderNotFound)
+
+	// uids возвращает UID заказов выборки
+	uids := func(orders []models.Order, err error) []string {
+		require.NoError(t, err)
+		return pageUIDs(orders)
+	}
+	all := interfaces.IncludeDeleted()
+
+	t.Run("Hidden", func(t *testing.T) {
+		_, err := p.GetOrder(ctx, "hidden")
+		assert.ErrorIs(t, err, models.ErrOrderNotFound)
+		_, err = p.GetOrder(ctx, "visible")
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"visible"}, uids(p.GetAllOrders(ctx)))
+		assert.Equal(t, []string{"visible"}, uids(p.GetOrdersSince(ctx, created)))
+		assert.Equal(t, []string{"visible"}, uids(p.GetOrdersByCustomerID(ctx, "customer", 10, 0)))
+		assert.Equal(t, []string{"visible"}, uids(p.GetOrderByTrackNumber(ctx, "TRACK")))
+		page, _, err := p.GetOrdersPage(ctx, "", 10)
+		assert.Equal(t, []string{"visible"}, uids(page, err))
+
+		var streamed []string
+		require.NoError(t, p.StreamOrders(ctx, func(o *models.Order) error {
+			streamed = append(streamed, o.OrderUID)
+			return nil
+		}))
+		assert.Equal(t, []string{"visible"}, streamed)
+
+		n, err := p.CountOrders(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+	})
+
+	t.Run("IncludeDeleted", func(t *testing.T) {
+		order, err := p.GetOrder(ctx, "hidden", all)
+		require.NoError(t, err)
+		require.NotNil(t, order.DeletedAt)
+		assert.Len(t, order.Items, 1, "данные заказа сохранены")
+
+		assert.ElementsMatch(t, []string{"visible", "hidden"}, uids(p.GetAllOrders(ctx, all)))
+		assert.ElementsMatch(t, []string{"visible", "hidden"}, uids(p.GetOrdersSince(ctx, created, all)))
+		assert.ElementsMatch(t, []string{"visible", "hidden"}, uids(p.GetOrdersByCustomerID(ctx, "customer", 10, 0, all)))
+		assert.ElementsMatch(t, []string{"visible", "hidden"}, uids(p.GetOrderByTrackNumber(ctx, "TRACK", all)))
+		page, _, err := p.GetOrdersPage(ctx, "", 10, all)
+		assert.ElementsMatch(t, []string{"visible", "hidden"}, uids(page, err))
+	})
+
+	t.Run("SaveKeepsHidden", func(t *testing.T) {
+		order := testOrder(models.Order{OrderUID: "hidden", DateCreated: created, Items: []models.Item{{ChrtID: 1, Name: "again"}}})
+		require.NoError(t, p.SaveOrder(ctx, order))
+		assert.NotNil(t, order.DeletedAt, "SaveOrder сообщает об отметке удаления")
+
+		_, err := p.GetOrder(ctx, "hidden")
+		assert.ErrorIs(t, err, models.ErrOrderNotFound)
+	})
+}
+
+func TestPostgres_SaveOrders(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	p := newIsolatedPostgres(t, ctx)
+
+	t.Run("DuplicateUIDLastWins", func(t *testing.T) {
+		first := testOrder(models.Order{OrderUID: "bulk-dup", Items: []models.Item{{ChrtID: 1, Name: "first"}, {ChrtID: 2, Name: "first"}}})
+		last := testOrder(models.Order{OrderUID: "bulk-dup", Items: []models.Item{{ChrtID: 2, Name: "last"}}})
+		other := testOrder(models.Order{OrderUID: "bulk-other", Items: []models.Item{{ChrtID: 1}}})
+		require.NoError(t, p.SaveOrders(ctx, []*models.Order{first, other, last}))
+		assert.False(t, last.UpdatedAt.IsZero(), "SaveOrders заполняет updated_at")
+
+		order, err := p.GetOrder(ctx, "bulk-dup")
+		require.NoError(t, err)
+		require.Len(t, order.Items, 1)
+		assert.Equal(t, "last", order.Items[0].Name)
+
+		exists, err := p.OrderExists(ctx, "bulk-other")
+		require.NoError(t, err)
+		assert.True(t, exists)
+	})
+
+	t.Run("AllOrNothing", func(t *testing.T) {
+		// currency VARCHAR(10): ошибка второго заказа откатывает и первый
+		good := testOrder(models.Order{OrderUID: "bulk-good", Items: []models.Item{{ChrtID: 1}}})
+		bad := testOrder(models.Order{OrderUID: "bulk-bad", Payment: models.Payment{Currency: strings.Repeat("X", 11)}})
+		err := p.SaveOrders(ctx, []*models.Order{good, bad})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "bulk-bad")
+
+		exists, err := p.OrderExists(ctx, "bulk-good")
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		assert.NoError(t, p.SaveOrders(ctx, nil))
+	})
+}
+
+func TestPostgres_SaveOrdersPartial(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	p := newIsolatedPostgres(t, ctx)
+
+	orders := []*models.Order{
+		testOrder(models.Order{OrderUID: "partial-first", Items: []models.Item{{ChrtID: 1}}}),
+		testOrder(models.Order{OrderUID: "partial-bad", Payment: models.Payment{Currency: strings.Repeat("X", 11)}}),
+		testOrder(models.Order{OrderUID: "partial-dup-item", Items: []models.Item{{ChrtID: 1}, {ChrtID: 1}}}),
+		testOrder(models.Order{OrderUID: "partial-last", Items: []models.Item{{ChrtID: 1}}}),
+	}
+	errs, err := p.SaveOrdersPartial(ctx, orders)
+	require.NoError(t, err)
+	require.Len(t, errs, len(orders))
+	assert.NoError(t, errs[0])
+	assert.ErrorContains(t, errs[1], "partial-bad")
+	assert.ErrorIs(t, errs[2], ErrDuplicateItem)
+	assert.NoError(t, errs[3], "ошибка предыдущих заказов не мешает следующим")
+
+	for uid, want := range map[string]bool{"partial-first": true, "partial-bad": false, "partial-dup-item": false, "partial-last": true} {
+		exists, err := p.OrderExists(ctx, uid)
+		require.NoError(t, err)
+		assert.Equal(t, want, exists, uid)
+	}
+	assert.False(t, orders[3].UpdatedAt.IsZero())
+}
+
+func TestPostgres_ListOrderUIDs(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	p := newIsolatedPostgres(t, ctx)
+	created := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i, uid := range []string{"uids-c", "uids-a", "uids-b"} {
+		saveAt(t, ctx, p, uid, created.Add(time.Duration(i)*time.Hour))
+	}
+	require.NoError(t, p.SoftDeleteOrder(ctx, "uids-b"))
+
+	t.Run("All", func(t *testing.T) {
+		var uids []string
+		require.NoError(t, p.ListOrderUIDs(ctx, func(uid string) error {
+			uids = append(uids, uid)
+			return nil
+		}))
+		assert.Equal(t, []string{"uids-c", "uids-a"}, uids, "от старых к новым, без удаленных")
+
+		uids = nil
+		require.NoError(t, p.ListOrderUIDs(ctx, func(uid string) error {
+			uids = append(uids, uid)
+			return nil
+		}, interfaces.IncludeDeleted()))
+		assert.Equal(t, []string{"uids-c", "uids-a", "uids-b"}, uids)
+	})
+
+	t.Run("AbortMidStream", func(t *testing.T) {
+		goroutines := runtime.NumGoroutine()
+		stop := errors.New("stop")
+
+		var uids []string
+		err := p.ListOrderUIDs(ctx, func(uid string) error {
+			uids = append(uids, uid)
+			return stop
+		})
+		assert.ErrorIs(t, err, stop)
+		assert.Equal(t, []string{"uids-c"}, uids)
+
+		// Курсор закрыт: соединение вернулось в пул, лишних горутин не осталось
+		assert.Zero(t, p.pool.Stat().AcquiredConns())
+		assert.Eventually(t, func() bool { return runtime.NumGoroutine() <= goroutines },
+			time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		err := p.ListOrderUIDs(ctx, func(string) error {
+			cancel()
+			return nil
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Zero(t, p.pool.Stat().AcquiredConns())
+	})
+}
+
+// crashingSender доставляет события и «падает» посреди пакета crashBatch: успевает доставить
+// половину пакета и отменяет публикатор до отметки
+type crashingSender struct {
+	mu         sync.Mutex
+	delivered  []models.OutboxEvent
+	batches    int
+	crashBatch int // Номер пакета (с 1), на котором происходит сбой; 0 — без сбоя
+	crash      context.CancelFunc
+}
+
+func (s *crashingSender) SendEvents(_ context.Context, events []models.OutboxEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches++
+	if s.batches == s.crashBatch {
+		s.delivered = append(s.delivered, events[:len(events)/2]...)
+		s.crash()
+		return errors.New("publisher killed")
+	}
+	s.delivered = append(s.delivered, events...)
+	return nil
+}
+
+func TestPostgres_Outbox(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	p := newIsolatedPostgres(t, ctx)
+
+	outboxCount := func(t *testing.T) int {
+		t.Helper()
+		var n int
+		require.NoError(t, p.pool.QueryRow(ctx, "SELECT count(*) FROM outbox").Scan(&n))
+		return n
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		require.NoError(t, p.SaveOrder(ctx, testOrder(models.Order{OrderUID: "outbox-off"})))
+		assert.Zero(t, outboxCount(t))
+	})
+
+	p.SetOutbox(true)
+	defer p.SetOutbox(false)
+
+	t.Run("RolledBackWithOrder", func(t *testing.T) {
+		bad := testOrder(models.Order{OrderUID: "outbox-bad", Payment: models.Payment{Currency: strings.Repeat("X", 11)}})
+		require.Error(t, p.SaveOrder(ctx, bad))
+		assert.Zero(t, outboxCount(t), "событие не пережило откат заказа")
+	})
+
+	t.Run("PublisherKilledMidBatch", func(t *testing.T) {
+		const orders = 10
+		for i := 0; i < orders; i++ {
+			require.NoError(t, p.SaveOrder(ctx, testOrder(models.Order{OrderUID: fmt.Sprintf("outbox-%02d", i)})))
+		}
+		require.Equal(t, orders, outboxCount(t))
+
+		// Первый публикатор падает посреди второго пакета
+		crashCtx, crash := context.WithCancel(ctx)
+		first := &crashingSender{crashBatch: 2, crash: crash}
+		outbox.NewPublisher(p, first, 4, 10*time.Millisecond).Run(crashCtx)
+		require.Len(t, first.delivered, 4+2)
+
+		// Второй публикатор дочитывает outbox с первого неотмеченного события
+		runCtx, stop := context.WithCancel(ctx)
+		second := &crashingSender{}
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			outbox.NewPublisher(p, second, 4, 10*time.Millisecond).Run(runCtx)
+		}()
+		require.Eventually(t, func() bool {
+			pending, _, err := p.OutboxLag(ctx)
+			return err == nil && pending == 0
+		}, 10*time.Second, 10*time.Millisecond)
+		stop()
+		<-done
+
+		// Ни одно событие не потеряно; повторно отправлены только события упавшего пакета
+		seen := make(map[int64]int)
+		var lastID int64
+		for _, event := range append(first.delivered, second.delivered...) {
+			seen[event.ID]++
+		}
+		for _, event := range second.delivered {
+			assert.Greater(t, event.ID, lastID, "события отправляются по порядку id")
+			lastID = event.ID
+		}
+		require.Len(t, seen, orders)
+		for _, event := range first.delivered[4:] {
+			assert.Equal(t, 2, seen[event.ID], "событие упавшего пакета отправлено повторно")
+			delete(seen, event.ID)
+		}
+		for id, n := range seen {
+			assert.Equal(t, 1, n, "событие %d", id)
+		}
+
+		var order models.Order
+		require.NoError(t, json.Unmarshal(second.delivered[len(second.delivered)-1].Payload, &order))
+		assert.Equal(t, second.delivered[len(second.delivered)-1].AggregateID, order.OrderUID)
+	})
+}
+
+func TestPostgres_FindOrdersByContact(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	p := newIsolatedPostgres(t, ctx)
+	created := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	contacts := []struct {
+		uid, email, phone string
+	}{
+		{"contact-old", "Test@Gmail.com", "+972 000-0000"},
+		{"contact-new", "test@gmail.com", "+7 (999) 123-45-67"},
+		{"contact-other", "other@example.com", "+9720000000"},
+	}
+	for i, c := range contacts {
+		require.NoError(t, p.SaveOrder(ctx, testOrder(models.Order{OrderUID: c.uid, DateCreated: created.Add(time.Duration(i) * time.Hour),
+			Delivery: models.Delivery{Email: c.email, Phone: c.phone}, Items: []models.Item{{ChrtID: 1, Name: c.uid}}})))
+	}
+
+	find := func(email, phone string, limit int) []string {
+		t.Helper()
+		orders, err := p.FindOrdersByContact(ctx, email, phone, limit)
+		require.NoError(t, err)
+		for _, order := range orders {
+			require.Len(t, order.Items, 1, "товары загружены")
+			assert.Equal(t, order.OrderUID, order.Items[0].Name)
+		}
+		return pageUIDs(orders)
+	}
+
+	assert.Equal(t, []string{"contact-new", "contact-old"}, find(" TEST@gmail.COM ", "", 10), "email без учета регистра")
+	assert.Equal(t, []string{"contact-other", "contact-old"}, find("", "+972-000-00-00", 10), "телефон без оформления")
+	assert.Equal(t, []string{"contact-other", "contact-new"}, find("test@gmail.com", "+9720000000", 2), "email или телефон, limit")
+	assert.Empty(t, find("missing@example.com", "", 10))
+
+	_, err := p.FindOrdersByContact(ctx, " ", "()", 10)
+	assert.ErrorIs(t, err, ErrNoContact)
+
+	require.NoError(t, p.SoftDeleteOrder(ctx, "contact-new"))
+	assert.Equal(t, []string{"contact-old"}, find("test@gmail.com", "", 10), "мягко удаленные пропускаются")
+}
+
+func TestPostgres_UpdateOrderStatus(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	p := newIsolatedPostgres(t, ctx)
+
+	order := testOrder(models.Order{OrderUID: "order", DateCreated: time.Now(), Items: []models.Item{{ChrtID: 1}}})
+	require.NoError(t, p.SaveOrder(ctx, order))
+	assert.Equal(t, models.StatusNew, order.Status, "статус по умолчанию")
+
+	require.NoError(t, p.UpdateOrderStatus(ctx, "order", models.StatusAccepted))
+	got, err := p.GetOrder(ctx, "order")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusAccepted, got.Status)
+	assert.True(t, got.UpdatedAt.After(order.UpdatedAt) || got.UpdatedAt.Equal(order.UpdatedAt))
+
+	// Повторное сохранение заказа статус не сбрасывает
+	require.NoError(t, p.SaveOrder(ctx, order))
+	assert.Equal(t, models.StatusAccepted, order.Status)
+
+	// Значение вне набора отклоняет ограничение колонки
+	assert.Error(t, p.UpdateOrderStatus(ctx, "order", "returned"))
+
+	assert.ErrorIs(t, p.UpdateOrderStatus(ctx, "missing", models.StatusAccepted), models.ErrOrderNotFound)
+	require.NoError(t, p.SoftDeleteOrder(ctx, "order"))
+	assert.ErrorIs(t, p.UpdateOrderStatus(ctx, "order", models.StatusPacked), models.ErrOrderNotFound, "мягко удаленный заказ")
+}
+
+func TestPostgres_StreamAllOrders(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	p := newIsolatedPostgres(t, ctx)
+
+	// Больше двух пакетов, последний неполный
+	total := 2*StreamBatchSize + 1
+	seedOrders(t, ctx, p, "stream", total)
+
+	var streamed []*models.Order
+	require.NoError(t, p.StreamAllOrders(ctx, func(o *models.Order) error {
+		streamed = append(streamed, o)
+		return nil
+	}))
+	require.Len(t, streamed, total)
+	for i, o := range streamed {
+		assert.Len(t, o.Items, 2, o.OrderUID)
+		if i > 0 {
+			prev := streamed[i-1]
+			assert.True(t, prev.DateCreated.Before(o.DateCreated) ||
+				prev.DateCreated.Equal(o.DateCreated) && prev.OrderUID < o.OrderUID, "от старых к новым без повторов")
+		}
+	}
+
+	t.Run("CallbackErrorStops", func(t *testing.T) {
+		stop := errors.New("stop")
+		n := 0
+		err := p.StreamAllOrders(ctx, func(*models.Order) error {
+			n++
+			if n == 3 {
+				return stop
+			}
+			return nil
+		})
+		assert.ErrorIs(t, err, stop)
+		assert.Equal(t, 3, n)
+	})
+}
+
+func TestPostgres_ConstraintViolations(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	p := newIsolatedPostgres(t, ctx)
+
+	tests := []struct {
+		name       string
+		order      *models.Order
+		constraint string
+	}{
+		{"NegativeAmount", testOrder(models.Order{OrderUID: "negative-amount", Payment: models.Payment{Amount: -1}}), "payment_amount_check"},
+		{"ZeroSMID", &models.Order{OrderUID: "zero-sm-id", Payment: models.Payment{PaymentDT: 1}}, "orders_sm_id_check"},
+		{"ZeroPaymentDT", &models.Order{OrderUID: "zero-payment-dt", SMID: 1}, "payment_payment_dt_check"},
+		{"NegativeItemPrice", testOrder(models.Order{OrderUID: "negative-price", Items: []models.Item{{ChrtID: 1, Price: -5}}}), "items_price_check"},
+		{"LongPhone", testOrder(models.Order{OrderUID: "long-phone", Delivery: models.Delivery{Phone: strings.Repeat("1", 33)}}), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.SaveOrder(ctx, tt.order)
+			require.ErrorIs(t, err, ErrConstraintViolation)
+			assert.False(t, IsUnavailable(err))
+			if tt.constraint != "" {
+				var pgErr *pgconn.PgError
+				require.ErrorAs(t, err, &pgErr)
+				assert.Equal(t, tt.constraint, pgErr.ConstraintName)
+			}
+
+			exists, err := p.OrderExists(ctx, tt.order.OrderUID)
+			require.NoError(t, err)
+			assert.False(t, exists, "транзакция откатывается целиком")
+		})
+	}
+
+	t.Run("Partial", func(t *testing.T) {
+		errs, err := p.SaveOrdersPartial(ctx, []*models.Order{
+			testOrder(models.Order{OrderUID: "partial-valid"}),
+			testOrder(models.Order{OrderUID: "partial-negative", Payment: models.Payment{GoodsTotal: -1}}),
+		})
+		require.NoError(t, err)
+		assert.NoError(t, errs[0])
+		assert.ErrorIs(t, errs[1], ErrConstraintViolation)
+	})
+
+	t.Run("UnknownStatus", func(t *testing.T) {
+		require.NoError(t, p.SaveOrder(ctx, testOrder(models.Order{OrderUID: "status"})))
+		assert.ErrorIs(t, p.UpdateOrderStatus(ctx, "status", "returned"), ErrConstraintViolation)
+	})
+}
+
+func TestPostgres_Tracing(t *testing.T) {
+	ctx := context.Background()
+	base := newIsolatedPostgres(t, ctx)
+
+	tracer, recorder := newRecordingTracer()
+	config := base.pool.Config()
+	require.NoError(t, PoolConfig{Tracer: tracer}.apply(config))
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	require.NoError(t, err)
+	p := NewPostgresFromPool(pool)
+	t.Cleanup(p.Close)
+
+	// Запросы выполняются внутри спана обработки сообщения и становятся его дочерними спанами
+	parent, parentSpan := tracer.Start(ctx, "consume orders")
+	order := testOrder(models.Order{OrderUID: "traced", Items: []models.Item{{ChrtID: 1}}})
+	require.NoError(t, p.SaveOrder(parent, order))
+	_, err = p.GetOrder(parent, order.OrderUID)
+	require.NoError(t, err)
+	parentSpan.End()
+
+	for _, name := range []string{"save_order", "get_order"} {
+		spans := endedNamed(recorder, name)
+		require.NotEmpty(t, spans, name)
+		for _, span := range spans {
+			assert.Equal(t, parentSpan.SpanContext().TraceID(), span.SpanContext().TraceID(), name)
+			assert.Equal(t, parentSpan.SpanContext().SpanID(), span.Parent().SpanID(), name)
+			assert.Equal(t, order.OrderUID, spanAttrs(span)[SpanAttrOrderUID], name)
+			assert.Equal(t, "postgresql", spanAttrs(span)[SpanAttrDBSystem], name)
+			assert.Equal(t, codes.Unset, span.Status().Code, name)
+		}
+	}
+	// begin, пакет запросов и commit
+	assert.GreaterOrEqual(t, len(endedNamed(recorder, "save_order")), 3)
+}
+
+func TestPostgres_MultiRowItems(t *testing.T) {
+	ctx := context.Background()
+	p := newIsolatedPostgres(t, ctx)
+	// По два товара в запросе: три товара заказа уходят двумя INSERT
+	p.SetMultiRowItems(true, 2)
+
+	order := fullOrder("multirow")
+	require.NoError(t, p.SaveOrder(ctx, order))
+	saved, err := p.GetOrder(ctx, order.OrderUID)
+	require.NoError(t, err)
+	assertSameOrder(t, order, *saved)
+
+	// Повторное сохранение обновляет товары на месте и удаляет исключенные
+	updated := fullOrder(order.OrderUID)
+	updated.Items = updated.Items[:2]
+	updated.Items[1].Price = 75
+	require.NoError(t, p.SaveOrder(ctx, updated))
+	saved, err = p.GetOrder(ctx, order.OrderUID)
+	require.NoError(t, err)
+	assertSameOrder(t, updated, *saved)
+}
+
+func TestPostgres_MonthlyPartitions(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	p := newPartitionedPostgres(t, ctx)
+	current := monthStart(time.Now())
+	// Середина месяца: граница секции не зависит от часового пояса сервера
+	midMonth := func(month time.Time) time.Time { return month.Add(14*24*time.Hour + 12*time.Hour) }
+
+	// partitionsOf возвращает секции таблицы table со строками заказа uid
+	partitionsOf := func(t *testing.T, table, uid string) []string {
+		t.Helper()
+		rows, err := p.pool.Query(ctx, "SELECT DISTINCT tableoid::regclass::text FROM "+table+" WHERE order_uid = $1", uid)
+		require.NoError(t, err)
+		names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		require.NoError(t, err)
+		return names
+	}
+
+	t.Run("CreatedByMigration", func(t *testing.T) {
+		months, err := p.MonthlyPartitions(ctx, "orders")
+		require.NoError(t, err)
+		assert.Contains(t, months, current)
+		assert.Contains(t, months, current.AddDate(0, 3, 0))
+	})
+
+	t.Run("InsertRoutesToMonth", func(t *testing.T) {
+		order := fullOrder("routed")
+		order.DateCreated = midMonth(current)
+		require.NoError(t, p.SaveOrder(ctx, order))
+
+		for _, table := range partitionedTables {
+			assert.Equal(t, []string{partitionName(table, current)}, partitionsOf(t, table, "routed"), table)
+		}
+		got, err := p.GetOrder(ctx, "routed")
+		require.NoError(t, err)
+		assertSameOrder(t, order, *got)
+	})
+
+	t.Run("OutOfRangeToDefault", func(t *testing.T) {
+		saveAt(t, ctx, p, "ancient", time.Date(2001, 5, 10, 0, 0, 0, 0, time.UTC))
+		for _, table := range partitionedTables {
+			assert.Equal(t, []string{table + "_default"}, partitionsOf(t, table, "ancient"), table)
+		}
+	})
+
+	t.Run("CreateMovesDefaultRows", func(t *testing.T) {
+		may, june := time.Date(2001, 5, 1, 0, 0, 0, 0, time.UTC), time.Date(2001, 6, 1, 0, 0, 0, 0, time.UTC)
+		stranded := fullOrder("stranded")
+		stranded.DateCreated = midMonth(may)
+		require.NoError(t, p.SaveOrder(ctx, stranded))
+		require.NoError(t, p.SoftDeleteOrder(ctx, "stranded"))
+		before, err := p.GetOrder(ctx, "stranded", interfaces.IncludeDeleted())
+		require.NoError(t, err)
+		saveAt(t, ctx, p, "june", midMonth(june))
+
+		// Строки месяца в секциях DEFAULT переносятся в созданные секции; соседний месяц остается в DEFAULT
+		created, err := p.CreateMonthlyPartitions(ctx, may, may)
+		require.NoError(t, err)
+		assert.Equal(t, 1, created)
+		for _, table := range partitionedTables {
+			assert.Equal(t, []string{partitionName(table, may)}, partitionsOf(t, table, "ancient"), table)
+			assert.Equal(t, []string{partitionName(table, may)}, partitionsOf(t, table, "stranded"), table)
+			assert.Equal(t, []string{table + "_default"}, partitionsOf(t, table, "june"), table)
+		}
+
+		// Заказ перенесен целиком, вместе с отметкой мягкого удаления
+		got, err := p.GetOrder(ctx, "stranded", interfaces.IncludeDeleted())
+		require.NoError(t, err)
+		require.NotNil(t, got.DeletedAt)
+		assert.True(t, before.DeletedAt.Equal(*got.DeletedAt))
+		assertSameOrder(t, before, *got)
+
+		// Следующие месяцы создаются и после месяца со строками в DEFAULT
+		created, err = p.CreateMonthlyPartitions(ctx, may, june)
+		require.NoError(t, err)
+		assert.Equal(t, 1, created)
+		for _, table := range partitionedTables {
+			assert.Equal(t, []string{partitionName(table, june)}, partitionsOf(t, table, "june"), table)
+		}
+	})
+
+	t.Run("UniquenessTradeOff", func(t *testing.T) {
+		// Схема гарантирует уникальность только (order_uid, date_created): запись в обход SaveOrder
+		// может создать второй заказ с тем же UID и другой датой
+		saveAt(t, ctx, p, "bypass", midMonth(current))
+		_, err := p.pool.Exec(ctx, `INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature,
+			customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard)
+			SELECT order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service,
+				shardkey, sm_id, $2, oof_shard FROM orders WHERE order_uid = $1`, "bypass", midMonth(current.AddDate(0, 1, 0)))
+		require.NoError(t, err)
+		var n int
+		require.NoError(t, p.pool.QueryRow(ctx, "SELECT count(*) FROM orders WHERE order_uid = $1", "bypass").Scan(&n))
+		assert.Equal(t, 2, n)
+
+		// SaveOrder оставляет один заказ на UID: остальные даты переносятся в сохраняемую
+		saveAt(t, ctx, p, "bypass", midMonth(current))
+		require.NoError(t, p.pool.QueryRow(ctx, "SELECT count(*) FROM orders WHERE order_uid = $1", "bypass").Scan(&n))
+		assert.Equal(t, 1, n)
+	})
+
+	t.Run("DateChangeMovesOrder", func(t *testing.T) {
+		order := fullOrder("moving")
+		order.DateCreated = midMonth(current)
+		require.NoError(t, p.SaveOrder(ctx, order))
+		require.NoError(t, p.UpdateOrderStatus(ctx, "moving", models.StatusAccepted))
+
+		next := current.AddDate(0, 1, 0)
+		order.DateCreated = midMonth(next)
+		require.NoError(t, p.SaveOrder(ctx, order))
+		assert.Equal(t, models.StatusAccepted, order.Status, "статус переносится вместе с заказом")
+
+		for _, table := range partitionedTables {
+			assert.Equal(t, []string{partitionName(table, next)}, partitionsOf(t, table, "moving"), table)
+		}
+		var items int
+		require.NoError(t, p.pool.QueryRow(ctx, "SELECT count(*) FROM items WHERE order_uid = $1", "moving").Scan(&items))
+		assert.Equal(t, len(order.Items), items)
+		got, err := p.GetOrder(ctx, "moving")
+		require.NoError(t, err)
+		assert.True(t, order.DateCreated.Equal(got.DateCreated))
+	})
+
+	t.Run("ConcurrentDateChange", func(t *testing.T) {
+		var wg sync.WaitGroup
+		errs := make([]error, 4)
+		for i := range errs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = p.SaveOrder(ctx, testOrder(models.Order{OrderUID: "racing",
+					DateCreated: midMonth(current.AddDate(0, i%2, 0)), Items: []models.Item{{ChrtID: 1}}}))
+			}(i)
+		}
+		wg.Wait()
+		for _, err := range errs {
+			require.NoError(t, err)
+		}
+
+		var n int
+		require.NoError(t, p.pool.QueryRow(ctx, "SELECT count(*) FROM orders WHERE order_uid = $1", "racing").Scan(&n))
+		assert.Equal(t, 1, n, "один заказ на UID")
+	})
+
+	t.Run("CreateAndDrop", func(t *testing.T) {
+		from := current.AddDate(0, -3, 0)
+		created, err := p.CreateMonthlyPartitions(ctx, from, current.AddDate(0, 4, 0))
+		require.NoError(t, err)
+		assert.Equal(t, 4, created, "три прошлых месяца и один после созданных миграцией")
+
+		created, err = p.CreateMonthlyPartitions(ctx, from, current.AddDate(0, 4, 0))
+		require.NoError(t, err)
+		assert.Zero(t, created, "повторный вызов ничего не создает")
+
+		expired := fullOrder("expired")
+		expired.DateCreated = midMonth(from.AddDate(0, 1, 0))
+		require.NoError(t, p.SaveOrder(ctx, expired))
+		for _, table := range partitionedTables {
+			assert.Equal(t, []string{partitionName(table, from.AddDate(0, 1, 0))}, partitionsOf(t, table, "expired"), table)
+		}
+
+		// Месяц, в который попадает cutoff, остается; удаляются и секции 2001 года из CreateMovesDefaultRows
+		dropped, err := p.DropMonthlyPartitionsBefore(ctx, midMonth(from.AddDate(0, 2, 0)))
+		require.NoError(t, err)
+		assert.Equal(t, 4, dropped)
+
+		_, err = p.GetOrder(ctx, "expired")
+		assert.ErrorIs(t, err, models.ErrOrderNotFound)
+		for _, table := range partitionedTables {
+			assert.Empty(t, partitionsOf(t, table, "expired"), table)
+			var exists bool
+			require.NoError(t, p.pool.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", partitionName(table, from)).Scan(&exists))
+			assert.False(t, exists, partitionName(table, from))
+		}
+		months, err := p.MonthlyPartitions(ctx, "orders")
+		require.NoError(t, err)
+		require.NotEmpty(t, months)
+		assert.Equal(t, from.AddDate(0, 2, 0), months[0])
+
+		_, err = p.GetOrder(ctx, "ancient")
+		assert.ErrorIs(t, err, models.ErrOrderNotFound)
+
+		// Заказы остальных секций не затронуты
+		_, err = p.GetOrder(ctx, "routed")
+		require.NoError(t, err)
+	})
+}
+
+func TestPostgres_PartitionManager(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	p := newPartitionedPostgres(t, ctx)
+	current := monthStart(time.Now())
+
+	_, err := p.CreateMonthlyPartitions(ctx, current.AddDate(0, -2, 0), current)
+	require.NoError(t, err)
+	saveAt(t, ctx, p, "old", current.AddDate(0, -2, 0).Add(time.Hour))
+
+	m := NewPartitionManager(p, PartitionConfig{Ahead: 6, Retention: 28 * 24 * time.Hour})
+	var evictedBefore time.Time
+	m.SetOnDropped(func(cutoff time.Time) { evictedBefore = cutoff })
+	require.NoError(t, m.Maintain(ctx))
+
+	months, err := p.MonthlyPartitions(ctx, "orders")
+	require.NoError(t, err)
+	require.NotEmpty(t, months)
+	assert.Equal(t, current.AddDate(0, 6, 0), months[len(months)-1])
+	assert.True(t, months[0].After(current.AddDate(0, -2, 0)), "секция старше срока хранения удалена")
+	assert.False(t, evictedBefore.IsZero())
+	assert.Equal(t, float64(len(months)), testutil.ToFloat64(m.metrics.Partitions))
+
+	_, err = p.GetOrder(ctx, "old")
+	assert.ErrorIs(t, err, models.ErrOrderNotFound)
+}
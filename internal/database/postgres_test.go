@@ -1,10 +1,12 @@
 package database
 
 import (
+	"context"
 	"testing"
 
 	"test_service/internal/models"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -64,3 +66,30 @@ func TestOrderStructure(t *testing.T) {
 	assert.Len(t, order.Items, 1)
 	assert.Equal(t, "Test Item", order.Items[0].Name)
 }
+
+func TestSaveOrder_DuplicateChrtID(t *testing.T) {
+	// Повтор chrt_id отклоняется до обращения к БД
+	p := &Postgres{metrics: NewDBMetrics()}
+	order := &models.Order{OrderUID: "dup", Items: []models.Item{{ChrtID: 7}, {ChrtID: 8}, {ChrtID: 7}}}
+	err := p.SaveOrder(context.Background(), order)
+	assert.ErrorIs(t, err, ErrDuplicateItem)
+	assert.Contains(t, err.Error(), "7")
+}
+
+func TestNewPostgresFromPool(t *testing.T) {
+	pool := newUnreachablePool(t)
+	p := NewPostgresFromPool(pool)
+
+	assert.Same(t, pool, p.pool)
+	assert.Nil(t, p.replica)
+	assert.Equal(t, float64(pool.Config().MaxConns), testutil.ToFloat64(p.metrics.ConnectionMaxOpen))
+
+	// Close останавливает сбор статистики и закрывает пул; повторный вызов безопасен
+	p.Close()
+	p.Close()
+	select {
+	case <-p.stop:
+	default:
+		t.Fatal("сбор статистики пула не остановлен")
+	}
+}
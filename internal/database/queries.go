@@ -3,66 +3,6 @@ package database
 
 // SQL Queries
 const (
-	// Создание таблиц
-	CreateOrdersTable = `CREATE TABLE IF NOT EXISTS orders (
-		order_uid VARCHAR(255) PRIMARY KEY,
-		track_number VARCHAR(255),
-		entry VARCHAR(255),
-		locale VARCHAR(10),
-		internal_signature VARCHAR(255),
-		customer_id VARCHAR(255),
-		delivery_service VARCHAR(255),
-		shardkey VARCHAR(255),
-		sm_id INTEGER,
-		date_created TIMESTAMP,
-		oof_shard VARCHAR(255)
-	)`
-
-	CreateDeliveryTable = `CREATE TABLE IF NOT EXISTS delivery (
-		order_uid VARCHAR(255) PRIMARY KEY REFERENCES orders(order_uid) ON DELETE CASCADE,
-		name VARCHAR(255),
-		phone VARCHAR(255),
-		zip VARCHAR(255),
-		city VARCHAR(255),
-		address VARCHAR(255),
-		region VARCHAR(255),
-		email VARCHAR(255)
-	)`
-
-	CreatePaymentTable = `CREATE TABLE IF NOT EXISTS payment (
-		order_uid VARCHAR(255) PRIMARY KEY REFERENCES orders(order_uid) ON DELETE CASCADE,
-		transaction VARCHAR(255),
-		request_id VARCHAR(255),
-		currency VARCHAR(10),
-		provider VARCHAR(255),
-		amount INTEGER,
-		payment_dt BIGINT,
-		bank VARCHAR(255),
-		delivery_cost INTEGER,
-		goods_total INTEGER,
-		custom_fee INTEGER
-	)`
-
-	CreateItemsTable = `CREATE TABLE IF NOT EXISTS items (
-		id SERIAL PRIMARY KEY,
-		order_uid VARCHAR(255) REFERENCES orders(order_uid) ON DELETE CASCADE,
-		chrt_id INTEGER,
-		track_number VARCHAR(255),
-		price INTEGER,
-		rid VARCHAR(255),
-		name VARCHAR(255),
-		sale INTEGER,
-		size VARCHAR(255),
-		total_price INTEGER,
-		nm_id INTEGER,
-		brand VARCHAR(255),
-		status INTEGER
-	)`
-
-	// Индексы
-	CreateOrdersIndex = `CREATE INDEX IF NOT EXISTS idx_orders_track_number ON orders(track_number)`
-	CreateItemsIndex = `CREATE INDEX IF NOT EXISTS idx_items_order_uid ON items(order_uid)`
-
 	// Сохранение заказа (UPSERT)
 	SaveOrderQuery = `INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature, 
 			customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard)
@@ -77,7 +17,9 @@ const (
 			shardkey = EXCLUDED.shardkey,
 			sm_id = EXCLUDED.sm_id,
 			date_created = EXCLUDED.date_created,
-			oof_shard = EXCLUDED.oof_shard`
+			oof_shard = EXCLUDED.oof_shard,
+			updated_at = NOW()
+		RETURNING updated_at, deleted_at, status`
 
 	// Сохранение доставки (UPSERT)
 	SaveDeliveryQuery = `INSERT INTO delivery (order_uid, name, phone, zip, city, address, region, email)
@@ -107,24 +49,115 @@ const (
 			goods_total = EXCLUDED.goods_total,
 			custom_fee = EXCLUDED.custom_fee`
 
-	// Удаление товаров заказа
-	DeleteItemsQuery = `DELETE FROM items WHERE order_uid = $1`
+	// Сессионные advisory-блокировки
+	TryAdvisoryLockQuery = `SELECT pg_try_advisory_lock($1)`
+	AdvisoryUnlockQuery  = `SELECT pg_advisory_unlock($1)`
+
+	// Месячные секции таблицы $1 (PartitionManager) и блокировка обслуживания секций до конца транзакции
+	ListPartitionsQuery = `SELECT c.relname FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = $1::text::regclass`
+	PartitionXactLockQuery = `SELECT pg_advisory_xact_lock($1)`
+	PartitionExistsQuery   = `SELECT to_regclass($1) IS NOT NULL`
+
+	// Секционирована ли таблица orders (необязательная миграция 0009_monthly_partitions)
+	OrdersPartitionedQuery = `SELECT relkind = 'p' FROM pg_class WHERE oid = 'orders'::regclass`
+
+	// Учет примененных миграций (migrate.go)
+	MigrationXactLockQuery     = `SELECT pg_advisory_xact_lock($1)`
+	CreateMigrationsTableQuery = `CREATE TABLE IF NOT EXISTS schema_migrations (id TEXT PRIMARY KEY, applied_at TIMESTAMP NOT NULL DEFAULT NOW())`
+	AddMigrationsChecksumQuery = `ALTER TABLE schema_migrations ADD COLUMN IF NOT EXISTS checksum TEXT`
+	GetAppliedMigrationsQuery  = `SELECT id, checksum FROM schema_migrations`
+	RecordMigrationQuery       = `INSERT INTO schema_migrations (id, checksum) VALUES ($1, $2)`
+	BackfillChecksumQuery      = `UPDATE schema_migrations SET checksum = $2 WHERE id = $1 AND checksum IS NULL`
+
+	// Количество неудаленных заказов: всего и созданных начиная с момента (индекс idx_orders_date_created)
+	CountOrdersQuery      = `SELECT count(*) FROM orders WHERE deleted_at IS NULL`
+	CountOrdersSinceQuery = `SELECT count(*) FROM orders WHERE date_created >= $1 AND deleted_at IS NULL`
+
+	// Проверка существования заказа без чтения его данных
+	OrderExistsQuery = `SELECT EXISTS(SELECT 1 FROM orders WHERE order_uid = $1)`
+
+	// Удаление заказа; доставка, платеж и товары удаляются каскадно (ON DELETE CASCADE)
+	DeleteOrderQuery = `DELETE FROM orders WHERE order_uid = $1`
+
+	// Мягкое удаление: заказ отмечается удаленным и пропускается выборками без IncludeDeleted
+	SoftDeleteOrderQuery = `UPDATE orders SET deleted_at = NOW(), updated_at = NOW()
+		WHERE order_uid = $1 AND deleted_at IS NULL`
+
+	// Смена статуса заказа; переход проверяет сервис (models.OrderStatus.CanTransitionTo)
+	UpdateOrderStatusQuery = `UPDATE orders SET status = $2, updated_at = NOW()
+		WHERE order_uid = $1 AND deleted_at IS NULL`
+
+	// Сохранение товаров заказа одним запросом (UPSERT по (order_uid, chrt_id)): $1 — UID заказа,
+	// остальные параметры — массивы значений колонок товаров в порядке товаров заказа
+	UpsertItemsQuery = insertItemsPrefix + `
+		SELECT $1::varchar, * FROM unnest($2::integer[], $3::varchar[], $4::integer[], $5::varchar[], $6::varchar[],
+			$7::integer[], $8::varchar[], $9::integer[], $10::integer[], $11::varchar[], $12::integer[])` + upsertItemsConflict
 
-	// Сохранение товара
-	SaveItemQuery = `INSERT INTO items (order_uid, chrt_id, track_number, price, rid, name, sale, size,
+	// Начало INSERT товаров: таблица и колонки в порядке параметров UpsertItemsQuery и buildItemsInsert
+	insertItemsPrefix = `INSERT INTO items (order_uid, chrt_id, track_number, price, rid, name, sale, size,
+			total_price, nm_id, brand, status)`
+
+	// Обновление существующего товара заказа (по (order_uid, chrt_id)) при сохранении товаров
+	upsertItemsConflict = `
+		ON CONFLICT (order_uid, chrt_id) DO UPDATE SET
+			track_number = EXCLUDED.track_number,
+			price = EXCLUDED.price,
+			rid = EXCLUDED.rid,
+			name = EXCLUDED.name,
+			sale = EXCLUDED.sale,
+			size = EXCLUDED.size,
+			total_price = EXCLUDED.total_price,
+			nm_id = EXCLUDED.nm_id,
+			brand = EXCLUDED.brand,
+			status = EXCLUDED.status`
+
+	// Удаление товаров заказа, которых нет в сохраняемом наборе chrt_id ($2)
+	DeleteStaleItemsQuery = `DELETE FROM items
+		WHERE order_uid = $1 AND (chrt_id IS NULL OR chrt_id <> ALL($2::integer[]))`
+
+	// Архивация заказов (ArchiveOrdersBefore): UID пакета заказов, созданных раньше $1.
+	// Заказы, заблокированные сохранением, пропускаются до следующего пакета или запуска.
+	SelectArchiveBatchQuery = `SELECT order_uid FROM orders
+		WHERE date_created < $1
+		ORDER BY date_created, order_uid
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED`
+
+	// Копирование заказов пакета ($1 — массив UID) в архивные таблицы
+	ArchiveOrdersQuery = `INSERT INTO orders_archive (order_uid, track_number, entry, locale, internal_signature,
+			customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, updated_at, deleted_at, status)
+		SELECT order_uid, track_number, entry, locale, internal_signature,
+			customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, updated_at, deleted_at, status
+		FROM orders WHERE order_uid = ANY($1)`
+	ArchiveDeliveryQuery = `INSERT INTO delivery_archive (order_uid, name, phone, zip, city, address, region, email)
+		SELECT order_uid, name, phone, zip, city, address, region, email
+		FROM delivery WHERE order_uid = ANY($1)`
+	ArchivePaymentQuery = `INSERT INTO payment_archive (order_uid, transaction, request_id, currency, provider,
+			amount, payment_dt, bank, delivery_cost, goods_total, custom_fee)
+		SELECT order_uid, transaction, request_id, currency, provider,
+			amount, payment_dt, bank, delivery_cost, goods_total, custom_fee
+		FROM payment WHERE order_uid = ANY($1)`
+	ArchiveItemsQuery = `INSERT INTO items_archive (id, order_uid, chrt_id, track_number, price, rid, name, sale, size,
 			total_price, nm_id, brand, status)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+		SELECT id, order_uid, chrt_id, track_number, price, rid, name, sale, size,
+			total_price, nm_id, brand, status
+		FROM items WHERE order_uid = ANY($1)`
+
+	// Удаление архивированных заказов; доставка, платеж и товары удаляются каскадно
+	DeleteArchivedOrdersQuery = `DELETE FROM orders WHERE order_uid = ANY($1)`
 
 	// Получение заказа по UID
 	GetOrderByUIDQuery = `SELECT o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature,
-			o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard,
+			o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard, o.updated_at, o.deleted_at, o.status,
 			d.name, d.phone, d.zip, d.city, d.address, d.region, d.email,
 			p.transaction, p.request_id, p.currency, p.provider, p.amount, p.payment_dt, 
 			p.bank, p.delivery_cost, p.goods_total, p.custom_fee
 		FROM orders o
 		JOIN delivery d ON o.order_uid = d.order_uid
 		JOIN payment p ON o.order_uid = p.order_uid
-		WHERE o.order_uid = $1`
+		WHERE o.order_uid = $1 AND ($2::boolean OR o.deleted_at IS NULL)`
 
 	// Получение товаров заказа
 	GetItemsByOrderUIDQuery = `SELECT chrt_id, track_number, price, rid, name, sale, size,
@@ -135,12 +168,200 @@ const (
 
 	// Получение всех заказов
 	GetAllOrdersQuery = `SELECT o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature,
-			o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard,
+			o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard, o.updated_at, o.deleted_at, o.status,
 			d.name, d.phone, d.zip, d.city, d.address, d.region, d.email,
 			p.transaction, p.request_id, p.currency, p.provider, p.amount, p.payment_dt, 
 			p.bank, p.delivery_cost, p.goods_total, p.custom_fee
 		FROM orders o
 		JOIN delivery d ON o.order_uid = d.order_uid
 		JOIN payment p ON o.order_uid = p.order_uid
-		ORDER BY o.date_created DESC`
-)
\ No newline at end of file
+		WHERE $1::boolean OR o.deleted_at IS NULL
+		ORDER BY o.date_created DESC, o.order_uid`
+
+	// Заказы с доставкой и платежом; основа запросов выборок заказов
+	ordersSelect = `SELECT o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature,
+			o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard, o.updated_at, o.deleted_at, o.status,
+			d.name, d.phone, d.zip, d.city, d.address, d.region, d.email,
+			p.transaction, p.request_id, p.currency, p.provider, p.amount, p.payment_dt,
+			p.bank, p.delivery_cost, p.goods_total, p.custom_fee
+		FROM orders o
+		JOIN delivery d ON o.order_uid = d.order_uid
+		JOIN payment p ON o.order_uid = p.order_uid`
+	// Страницы заказов от новых к старым; порядок совпадает с индексом idx_orders_date_created_uid
+	GetOrdersFirstPageQuery = ordersSelect + `
+		WHERE $2::boolean OR o.deleted_at IS NULL
+		ORDER BY o.date_created DESC, o.order_uid DESC
+		LIMIT $1`
+	// Страница после ключа ($1, $2) последнего заказа предыдущей страницы
+	GetOrdersPageAfterQuery = ordersSelect + `
+		WHERE (o.date_created, o.order_uid) < ($1, $2) AND ($4::boolean OR o.deleted_at IS NULL)
+		ORDER BY o.date_created DESC, o.order_uid DESC
+		LIMIT $3`
+
+	// Пакеты StreamAllOrders от старых заказов к новым (индекс idx_orders_date_created_uid)
+	StreamAllOrdersFirstQuery = ordersSelect + `
+		WHERE $2::boolean OR o.deleted_at IS NULL
+		ORDER BY o.date_created, o.order_uid
+		LIMIT $1`
+	// Пакет после ключа ($1, $2) последнего заказа предыдущего пакета
+	StreamAllOrdersAfterQuery = ordersSelect + `
+		WHERE (o.date_created, o.order_uid) > ($1, $2) AND ($4::boolean OR o.deleted_at IS NULL)
+		ORDER BY o.date_created, o.order_uid
+		LIMIT $3`
+
+	// Заказы покупателя от новых к старым
+	GetOrdersByCustomerIDQuery = ordersSelect + `
+		WHERE o.customer_id = $1 AND ($4::boolean OR o.deleted_at IS NULL)
+		ORDER BY o.date_created DESC, o.order_uid DESC
+		LIMIT $2 OFFSET $3`
+
+	// Заказы по нормализованным email ($1) или телефону ($2) из доставки; NULL — контакт не задан.
+	// Индексы idx_delivery_email_lower и idx_delivery_phone_digits
+	FindOrdersByContactQuery = ordersSelect + `
+		WHERE (lower(d.email) = $1 OR regexp_replace(d.phone, '[^0-9+]', '', 'g') = $2)
+			AND ($4::boolean OR o.deleted_at IS NULL)
+		ORDER BY o.date_created DESC, o.order_uid DESC
+		LIMIT $3`
+
+	// Заказы, созданные начиная с момента, для догрузки кэша (индекс idx_orders_date_created)
+	GetOrdersSinceQuery = ordersSelect + `
+		WHERE o.date_created >= $1 AND ($2::boolean OR o.deleted_at IS NULL)
+		ORDER BY o.date_created, o.order_uid`
+
+	// Заказы по трек-номеру (индекс idx_orders_track_number)
+	GetOrdersByTrackNumberQuery = ordersSelect + `
+		WHERE o.track_number = $1 AND ($2::boolean OR o.deleted_at IS NULL)
+		ORDER BY o.date_created DESC, o.order_uid DESC`
+
+	// Товары нескольких заказов одним запросом
+	GetItemsByOrderUIDsQuery = `SELECT order_uid, chrt_id, track_number, price, rid, name, sale, size,
+			total_price, nm_id, brand, status
+		FROM items
+		WHERE order_uid = ANY($1)
+		ORDER BY order_uid, id`
+
+	// Потоковая выгрузка UID заказов от старых к новым
+	ListOrderUIDsQuery = `SELECT order_uid FROM orders
+		WHERE ($1::boolean OR deleted_at IS NULL)
+		ORDER BY date_created, order_uid`
+
+	// Потоковая выгрузка заказов вместе с товарами одним курсором.
+	// Строки одного заказа идут подряд, заказы без товаров дают одну строку с NULL в колонках товара.
+	StreamOrdersQuery = `SELECT o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature,
+			o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard, o.updated_at, o.deleted_at, o.status,
+			d.name, d.phone, d.zip, d.city, d.address, d.region, d.email,
+			p.transaction, p.request_id, p.currency, p.provider, p.amount, p.payment_dt,
+			p.bank, p.delivery_cost, p.goods_total, p.custom_fee,
+			i.id, i.chrt_id, i.track_number, i.price, i.rid, i.name, i.sale, i.size,
+			i.total_price, i.nm_id, i.brand, i.status
+		FROM orders o
+		JOIN delivery d ON o.order_uid = d.order_uid
+		JOIN payment p ON o.order_uid = p.order_uid
+		LEFT JOIN items i ON o.order_uid = i.order_uid
+		WHERE $1::boolean OR o.deleted_at IS NULL
+		ORDER BY o.date_created DESC, o.order_uid, i.id`
+
+	// Событие сохранения заказа в outbox (в транзакции сохранения)
+	InsertOutboxQuery = `INSERT INTO outbox (aggregate_id, payload) VALUES ($1, $2)`
+
+	// Неотправленные события outbox по порядку записи
+	SelectOutboxBatchQuery = `SELECT id, aggregate_id, payload, created_at
+		FROM outbox
+		WHERE published_at IS NULL
+		ORDER BY id
+		LIMIT $1`
+
+	// Отметка отправленных событий; уже отмеченные не меняются
+	MarkOutboxPublishedQuery = `UPDATE outbox SET published_at = NOW()
+		WHERE id = ANY($1) AND published_at IS NULL`
+
+	// Число неотправленных событий и время записи самого старого из них
+	OutboxLagQuery = `SELECT count(*), min(created_at) FROM outbox WHERE published_at IS NULL`
+)
+
+// Запросы сохранения заказа в секционированные по месяцам date_created таблицы (SetPartitioning).
+// Ключ секционирования входит в каждое ограничение уникальности секционированной таблицы, поэтому
+// ON CONFLICT (order_uid) в них невозможен: UPSERT идет по (order_uid, date_created), а дочерние
+// таблицы получают date_created заказа последним параметром. Запросы чтения у обоих режимов общие.
+const (
+	// Блокировка заказа $2 до конца транзакции сохранения ($1 — orderLockClass). Схема гарантирует
+	// уникальность только пары (order_uid, date_created): блокировка не дает параллельным сохранениям
+	// одного заказа с разной date_created записать его дважды
+	LockOrderQuery = `SELECT pg_advisory_xact_lock($1, hashtext($2))`
+
+	// Сохранение заказа (UPSERT по (order_uid, date_created)). Заказ с тем же UID и другой date_created
+	// удаляется вместе с доставкой, платежом и товарами (каскадно) и вставляется в секцию новой даты
+	// с прежними статусом и отметкой мягкого удаления
+	SavePartitionedOrderQuery = `WITH moved AS (
+			DELETE FROM orders WHERE order_uid = $1 AND date_created <> $10
+			RETURNING deleted_at, status
+		)
+		INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature,
+			customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, deleted_at, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11,
+			(SELECT deleted_at FROM moved), COALESCE((SELECT status FROM moved), 'new'))
+		ON CONFLICT (order_uid, date_created) DO UPDATE SET
+			track_number = EXCLUDED.track_number,
+			entry = EXCLUDED.entry,
+			locale = EXCLUDED.locale,
+			internal_signature = EXCLUDED.internal_signature,
+			customer_id = EXCLUDED.customer_id,
+			delivery_service = EXCLUDED.delivery_service,
+			shardkey = EXCLUDED.shardkey,
+			sm_id = EXCLUDED.sm_id,
+			oof_shard = EXCLUDED.oof_shard,
+			updated_at = NOW()
+		RETURNING updated_at, deleted_at, status`
+
+	// Сохранение доставки (UPSERT); $9 — date_created заказа
+	SavePartitionedDeliveryQuery = `INSERT INTO delivery (order_uid, name, phone, zip, city, address, region, email, date_created)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (order_uid, date_created) DO UPDATE SET
+			name = EXCLUDED.name,
+			phone = EXCLUDED.phone,
+			zip = EXCLUDED.zip,
+			city = EXCLUDED.city,
+			address = EXCLUDED.address,
+			region = EXCLUDED.region,
+			email = EXCLUDED.email`
+
+	// Сохранение платежа (UPSERT); $12 — date_created заказа
+	SavePartitionedPaymentQuery = `INSERT INTO payment (order_uid, transaction, request_id, currency, provider,
+			amount, payment_dt, bank, delivery_cost, goods_total, custom_fee, date_created)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (order_uid, date_created) DO UPDATE SET
+			transaction = EXCLUDED.transaction,
+			request_id = EXCLUDED.request_id,
+			currency = EXCLUDED.currency,
+			provider = EXCLUDED.provider,
+			amount = EXCLUDED.amount,
+			payment_dt = EXCLUDED.payment_dt,
+			bank = EXCLUDED.bank,
+			delivery_cost = EXCLUDED.delivery_cost,
+			goods_total = EXCLUDED.goods_total,
+			custom_fee = EXCLUDED.custom_fee`
+
+	// Сохранение товаров заказа одним запросом (UPSERT по (order_uid, date_created, chrt_id)): параметры
+	// UpsertItemsQuery и $13 — date_created заказа
+	UpsertPartitionedItemsQuery = insertPartitionedItemsPrefix + `
+		SELECT $1::varchar, *, $13::timestamp FROM unnest($2::integer[], $3::varchar[], $4::integer[], $5::varchar[], $6::varchar[],
+			$7::integer[], $8::varchar[], $9::integer[], $10::integer[], $11::varchar[], $12::integer[])` + upsertPartitionedItemsConflict
+
+	// Начало INSERT товаров: колонки insertItemsPrefix и date_created
+	insertPartitionedItemsPrefix = `INSERT INTO items (order_uid, chrt_id, track_number, price, rid, name, sale, size,
+			total_price, nm_id, brand, status, date_created)`
+
+	// Обновление существующего товара заказа (по (order_uid, date_created, chrt_id)) при сохранении товаров
+	upsertPartitionedItemsConflict = `
+		ON CONFLICT (order_uid, date_created, chrt_id) DO UPDATE SET
+			track_number = EXCLUDED.track_number,
+			price = EXCLUDED.price,
+			rid = EXCLUDED.rid,
+			name = EXCLUDED.name,
+			sale = EXCLUDED.sale,
+			size = EXCLUDED.size,
+			total_price = EXCLUDED.total_price,
+			nm_id = EXCLUDED.nm_id,
+			brand = EXCLUDED.brand,
+			status = EXCLUDED.status`
+)
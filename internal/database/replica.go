@@ -0,0 +1,110 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"test_service/internal/interfaces"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Пулы, на которых выполняются запросы (метка target метрик)
+const (
+	TargetPrimary = "primary" // Основной сервер: запись и чтение без реплики
+	TargetReplica = "replica" // Реплика для чтения (POSTGRES_READ_DSN)
+)
+
+// replicaCheckInterval период проверки реплики, отмеченной неисправной или исправной
+const replicaCheckInterval = 5 * time.Second
+
+// replica пул реплики для чтения и признак ее исправности
+type replica struct {
+	pool    *pgxpool.Pool
+	healthy atomic.Bool // Чтение идет на реплику, только пока она исправна
+}
+
+// reading оборачивает попытку чтения fn: она выполняется на исправной реплике, а без нее —
+// на основном пуле. Ошибка соединения с репликой отмечает ее неисправной, поэтому
+// следующая попытка той же операции уже идет на основной пул.
+func (p *Postgres) reading(operation string, fn func(ctx context.Context, db *pgxpool.Pool) error) func(context.Context) error {
+	return p.readingFrom(interfaces.ReadOptions{}, operation, fn)
+}
+
+// readingFrom как reading, но с options.Primary попытка всегда идет на основной пул
+func (p *Postgres) readingFrom(options interfaces.ReadOptions, operation string, fn func(ctx context.Context, db *pgxpool.Pool) error) func(context.Context) error {
+	return func(ctx context.Context) error {
+		if options.Primary || p.replica == nil || !p.replica.healthy.Load() {
+			p.metrics.ReadQueries.WithLabelValues(operation, TargetPrimary).Inc()
+			return retryTransient(fn(ctx, p.pool))
+		}
+		p.metrics.ReadQueries.WithLabelValues(operation, TargetReplica).Inc()
+		err := fn(ctx, p.replica.pool)
+		if IsUnavailable(err) && ctx.Err() == nil {
+			p.setReplicaHealthy(false, err)
+		}
+		return retryTransient(err)
+	}
+}
+
+// setReplicaHealthy обновляет признак исправности реплики и сообщает о его изменении
+func (p *Postgres) setReplicaHealthy(healthy bool, cause error) {
+	if p.replica.healthy.Swap(healthy) != healthy {
+		if healthy {
+			log.Println("Реплика БД доступна, чтение переключено на реплику")
+		} else {
+			log.Printf("Реплика БД недоступна, чтение переключено на основной сервер: %v", cause)
+		}
+	}
+	if healthy {
+		p.metrics.ReplicaHealthy.Set(1)
+	} else {
+		p.metrics.ReplicaHealthy.Set(0)
+	}
+}
+
+// checkReplica проверяет соединение с репликой и обновляет признак ее исправности
+func (p *Postgres) checkReplica(ctx context.Context) error {
+	ctx, cancel := p.readContext(ctx)
+	defer cancel()
+
+	err := p.replica.pool.Ping(ctx)
+	if err != nil {
+		p.metrics.ConnectionErrorsTotal.Inc()
+		err = fmt.Errorf("Ошибка проверки соединения с репликой БД: %w", err)
+	}
+	p.setReplicaHealthy(err == nil, err)
+	return classify(err)
+}
+
+// watchReplica проверяет реплику каждые replicaCheckInterval до закрытия stop:
+// неисправная реплика возвращается к чтению, как только снова отвечает
+func (p *Postgres) watchReplica(stop <-chan struct{}) {
+	ticker := time.NewTicker(replicaCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			_ = p.checkReplica(context.Background())
+		}
+	}
+}
+
+// PingReplica проверяет соединение с репликой для /readyz; без реплики возвращает nil.
+// Недоступная реплика не мешает работе: чтение идет на основной сервер.
+func (p *Postgres) PingReplica(ctx context.Context) error {
+	if p.replica == nil {
+		return nil
+	}
+	return p.checkReplica(ctx)
+}
+
+// HasReplica сообщает, настроена ли реплика для чтения
+func (p *Postgres) HasReplica() bool {
+	return p.replica != nil
+}
@@ -0,0 +1,135 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"test_service/internal/interfaces"
+	"test_service/internal/retry"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newUnreachablePool пул без соединений к адресу, на котором никто не слушает
+func newUnreachablePool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	pool, err := pgxpool.New(context.Background(), "postgres://postgres@127.0.0.1:1/order_db?connect_timeout=1")
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+func TestReading(t *testing.T) {
+	metrics := NewDBMetrics()
+	primary := newUnreachablePool(t)
+
+	// target возвращает пул, на котором выполнена попытка чтения
+	target := func(p *Postgres, fnErr error) (*pgxpool.Pool, error) {
+		var used *pgxpool.Pool
+		err := p.reading("test_reading", func(_ context.Context, db *pgxpool.Pool) error {
+			used = db
+			return fnErr
+		})(context.Background())
+		return used, err
+	}
+
+	t.Run("WithoutReplica", func(t *testing.T) {
+		p := &Postgres{pool: primary, metrics: metrics}
+		before := testutil.ToFloat64(metrics.ReadQueries.WithLabelValues("test_reading", TargetPrimary))
+
+		used, err := target(p, nil)
+		require.NoError(t, err)
+		assert.Same(t, primary, used)
+		assert.Equal(t, before+1, testutil.ToFloat64(metrics.ReadQueries.WithLabelValues("test_reading", TargetPrimary)))
+	})
+
+	t.Run("HealthyReplica", func(t *testing.T) {
+		p := &Postgres{pool: primary, metrics: metrics, replica: &replica{pool: newUnreachablePool(t)}}
+		p.replica.healthy.Store(true)
+		before := testutil.ToFloat64(metrics.ReadQueries.WithLabelValues("test_reading", TargetReplica))
+
+		used, err := target(p, nil)
+		require.NoError(t, err)
+		assert.Same(t, p.replica.pool, used)
+		assert.Equal(t, before+1, testutil.ToFloat64(metrics.ReadQueries.WithLabelValues("test_reading", TargetReplica)))
+	})
+
+	t.Run("FromPrimaryBypassesHealthyReplica", func(t *testing.T) {
+		p := &Postgres{pool: primary, metrics: metrics, replica: &replica{pool: newUnreachablePool(t)}}
+		p.replica.healthy.Store(true)
+
+		var used *pgxpool.Pool
+		err := p.readingFrom(interfaces.ReadOptions{Primary: true}, "test_reading", func(_ context.Context, db *pgxpool.Pool) error {
+			used = db
+			return nil
+		})(context.Background())
+		require.NoError(t, err)
+		assert.Same(t, primary, used)
+		assert.True(t, p.replica.healthy.Load(), "реплика остается исправной")
+	})
+
+	t.Run("UnavailableReplicaFallsBackToPrimary", func(t *testing.T) {
+		p := &Postgres{pool: primary, metrics: metrics, replica: &replica{pool: newUnreachablePool(t)}}
+		p.replica.healthy.Store(true)
+
+		used, err := target(p, ErrUnavailable)
+		assert.ErrorIs(t, err, ErrUnavailable)
+		assert.Same(t, p.replica.pool, used)
+		assert.False(t, p.replica.healthy.Load())
+		assert.Equal(t, 0.0, testutil.ToFloat64(metrics.ReplicaHealthy))
+
+		// Повтор той же операции уже идет на основной пул
+		used, err = target(p, nil)
+		require.NoError(t, err)
+		assert.Same(t, primary, used)
+	})
+
+	t.Run("QueryErrorKeepsReplica", func(t *testing.T) {
+		p := &Postgres{pool: primary, metrics: metrics, replica: &replica{pool: newUnreachablePool(t)}}
+		p.replica.healthy.Store(true)
+
+		_, err := target(p, errors.New("syntax error"))
+		assert.Error(t, err)
+		assert.True(t, p.replica.healthy.Load())
+	})
+
+	t.Run("RetriesOnlyTransient", func(t *testing.T) {
+		p := &Postgres{pool: primary, metrics: metrics}
+		policy := retry.Policy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, BackoffFactor: 1}
+		attempts := func(fnErr error) int {
+			n := 0
+			_ = retry.DoWithContext(context.Background(), policy, p.reading("test_reading", func(context.Context, *pgxpool.Pool) error {
+				n++
+				return fnErr
+			}))
+			return n
+		}
+
+		assert.Equal(t, 3, attempts(&pgconn.PgError{Code: "08006"}), "обрыв соединения повторяется")
+		assert.Equal(t, 1, attempts(&pgconn.PgError{Code: "42P01"}), "отсутствующая таблица не повторяется")
+		assert.Equal(t, 1, attempts(&pgconn.PgError{Code: "42501"}), "нехватка прав не повторяется")
+	})
+}
+
+func TestPingReplica(t *testing.T) {
+	t.Run("NotConfigured", func(t *testing.T) {
+		p := &Postgres{metrics: NewDBMetrics()}
+		assert.False(t, p.HasReplica())
+		assert.NoError(t, p.PingReplica(context.Background()))
+	})
+
+	t.Run("Unreachable", func(t *testing.T) {
+		p := &Postgres{metrics: NewDBMetrics(), replica: &replica{pool: newUnreachablePool(t)}}
+		p.replica.healthy.Store(true)
+
+		err := p.PingReplica(context.Background())
+		assert.True(t, IsUnavailable(err), "%v", err)
+		assert.False(t, p.replica.healthy.Load())
+	})
+}
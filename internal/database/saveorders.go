@@ -0,0 +1,195 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"test_service/internal/models"
+	"test_service/internal/retry"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Запросы точки сохранения заказа в SaveOrdersPartial
+const (
+	SavepointOrderQuery         = "SAVEPOINT save_order"
+	ReleaseSavepointOrderQuery  = "RELEASE SAVEPOINT save_order"
+	RollbackSavepointOrderQuery = "ROLLBACK TO SAVEPOINT save_order"
+)
+
+// SaveOrders сохраняет заказы одной транзакцией и одним пакетом запросов: либо все, либо ни одного.
+// Заказы с одинаковым order_uid сохраняются по порядку, поэтому остается последний.
+// Повтор chrt_id в любом заказе отклоняет весь пакет до обращения к БД. Пустой orders — no-op.
+func (p *Postgres) SaveOrders(ctx context.Context, orders []*models.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+	startTime := time.Now()
+	ctx = withOperation(ctx, "save_orders", "")
+
+	for _, order := range orders {
+		if err := checkDuplicateItems(order); err != nil {
+			p.metrics.FailedSavesTotal.Add(float64(len(orders)))
+			return err
+		}
+	}
+
+	err := retry.DoWithContext(ctx, retry.HeavyPolicy(), func(ctx context.Context) error {
+		return retryTransient(p.saveOrdersAttempt(ctx, orders))
+	})
+
+	if err != nil {
+		p.metrics.FailedSavesTotal.Add(float64(len(orders)))
+	} else {
+		p.metrics.SuccessfulSavesTotal.Add(float64(len(orders)))
+		p.metrics.SaveOrdersBatchSize.Observe(float64(len(orders)))
+		p.metrics.SaveOrdersDuration.Observe(time.Since(startTime).Seconds())
+	}
+
+	return classify(err)
+}
+
+// saveOrdersAttempt одна попытка SaveOrders
+func (p *Postgres) saveOrdersAttempt(ctx context.Context, orders []*models.Order) error {
+	ctx, cancel := p.writeContext(ctx)
+	defer cancel()
+
+	tx, err := p.pool.BeginTx(ctx, p.saveTx)
+	if err != nil {
+		p.metrics.TransactionErrorsTotal.Inc()
+		return fmt.Errorf("Ошибка начала транзакции: %w", err)
+	}
+	defer rollbackUnlessCommitted(ctx, tx)
+
+	saved := make([]savedOrder, len(orders))
+	if err := p.sendBatch(ctx, tx, saveOrdersBatch(orders, saved, p.save)); err != nil {
+		return err
+	}
+	for _, order := range orders {
+		p.metrics.SaveItemsBatchSize.Observe(float64(len(order.Items)))
+	}
+
+	if err := p.commitSave(ctx, tx); err != nil {
+		return err
+	}
+	for i, order := range orders {
+		order.UpdatedAt = saved[i].updatedAt
+		order.DeletedAt = saved[i].deletedAt
+		order.Status = saved[i].status
+	}
+	return nil
+}
+
+// SaveOrdersPartial сохраняет заказы одной транзакцией, но каждый заказ — под своей точкой
+// сохранения: ошибка заказа откатывает только его. Возвращает ошибки по заказам (errs[i] для
+// orders[i], nil — сохранен) и ошибку всей транзакции; при ней не сохранен ни один заказ.
+// Каждый заказ отправляется отдельным пакетом: после ошибки PostgreSQL пропускает остаток пакета.
+func (p *Postgres) SaveOrdersPartial(ctx context.Context, orders []*models.Order) ([]error, error) {
+	if len(orders) == 0 {
+		return nil, nil
+	}
+	startTime := time.Now()
+	ctx = withOperation(ctx, "save_orders_partial", "")
+
+	var errs []error
+	err := retry.DoWithContext(ctx, retry.HeavyPolicy(), func(ctx context.Context) error {
+		var err error
+		errs, err = p.saveOrdersPartialAttempt(ctx, orders)
+		return retryTransient(err)
+	})
+	if err != nil {
+		p.metrics.FailedSavesTotal.Add(float64(len(orders)))
+		return nil, classify(err)
+	}
+
+	failed := 0
+	for _, orderErr := range errs {
+		if orderErr != nil {
+			failed++
+		}
+	}
+	p.metrics.FailedSavesTotal.Add(float64(failed))
+	p.metrics.SuccessfulSavesTotal.Add(float64(len(orders) - failed))
+	p.metrics.SaveOrdersBatchSize.Observe(float64(len(orders)))
+	p.metrics.SaveOrdersDuration.Observe(time.Since(startTime).Seconds())
+	return errs, nil
+}
+
+// saveOrdersPartialAttempt одна попытка SaveOrdersPartial. Ошибка соединения или коммита
+// возвращается как ошибка попытки; ошибки отдельных заказов — в errs.
+func (p *Postgres) saveOrdersPartialAttempt(ctx context.Context, orders []*models.Order) ([]error, error) {
+	ctx, cancel := p.writeContext(ctx)
+	defer cancel()
+
+	tx, err := p.pool.BeginTx(ctx, p.saveTx)
+	if err != nil {
+		p.metrics.TransactionErrorsTotal.Inc()
+		return nil, fmt.Errorf("Ошибка начала транзакции: %w", err)
+	}
+	defer rollbackUnlessCommitted(ctx, tx)
+
+	errs := make([]error, len(orders))
+	saved := make([]savedOrder, len(orders))
+	for i, order := range orders {
+		if err := checkDuplicateItems(order); err != nil {
+			errs[i] = err
+			continue
+		}
+
+		b := &writeBatch{label: "save_order_savepoint_batch"}
+		b.queue(batchStep{label: "savepoint", errMsg: "Ошибка создания точки сохранения", result: execResult},
+			SavepointOrderQuery)
+		b.queueSaveOrder(order, &saved[i], p.save)
+		b.queue(batchStep{label: "release_savepoint", errMsg: "Ошибка освобождения точки сохранения", result: execResult},
+			ReleaseSavepointOrderQuery)
+
+		err := p.sendBatch(ctx, tx, b)
+		if err == nil {
+			p.metrics.SaveItemsBatchSize.Observe(float64(len(order.Items)))
+			continue
+		}
+		if IsUnavailable(classify(err)) || ctx.Err() != nil {
+			return nil, err
+		}
+		errs[i] = classify(fmt.Errorf("заказ %s: %w", order.OrderUID, err))
+
+		if _, err := tx.Exec(ctx, RollbackSavepointOrderQuery); err != nil {
+			p.metrics.TransactionErrorsTotal.Inc()
+			return nil, fmt.Errorf("Ошибка отката к точке сохранения: %w", err)
+		}
+	}
+
+	if err := p.commitSave(ctx, tx); err != nil {
+		return nil, err
+	}
+	for i, order := range orders {
+		if errs[i] == nil {
+			order.UpdatedAt = saved[i].updatedAt
+			order.DeletedAt = saved[i].deletedAt
+			order.Status = saved[i].status
+		}
+	}
+	return errs, nil
+}
+
+// commitSave коммитит транзакцию сохранения заказов
+func (p *Postgres) commitSave(ctx context.Context, tx pgx.Tx) error {
+	queryStartTime := time.Now()
+	err := tx.Commit(ctx)
+	p.metrics.QueryDuration.WithLabelValues("commit_transaction").Observe(time.Since(queryStartTime).Seconds())
+	if err != nil {
+		p.metrics.TransactionErrorsTotal.Inc()
+		return fmt.Errorf("Ошибка коммита транзакции: %w", err)
+	}
+	return nil
+}
+
+// rollbackUnlessCommitted откатывает транзакцию; после коммита откат ничего не делает
+func rollbackUnlessCommitted(ctx context.Context, tx pgx.Tx) {
+	if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+		log.Printf("Ошибка при откате транзакции: %v", err)
+	}
+}
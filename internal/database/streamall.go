@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"test_service/internal/interfaces"
+	"test_service/internal/models"
+	"test_service/internal/retry"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// StreamBatchSize число заказов в пакете StreamAllOrders: столько заказов с товарами
+// одновременно находится в памяти
+const StreamBatchSize = 500
+
+// StreamAllOrders последовательно передает в fn все заказы с товарами от старых к новым.
+// В отличие от GetAllOrders, заказы не накапливаются: они читаются пакетами по StreamBatchSize
+// по ключу (date_created, order_uid), товары пакета — одним запросом, и пакет освобождается
+// после передачи в fn. Каждый пакет — короткий запрос с повторными попытками, поэтому сбой
+// соединения не прерывает выгрузку. Заказы, сохраненные во время выгрузки с ключом меньше
+// уже пройденного, не передаются. Ошибка fn или отмена ctx прерывают выгрузку.
+func (p *Postgres) StreamAllOrders(ctx context.Context, fn func(*models.Order) error, opts ...interfaces.ReadOption) error {
+	options := interfaces.ApplyReadOptions(opts)
+	startTime := time.Now()
+
+	var after *models.Order
+	for {
+		if err := ctx.Err(); err != nil {
+			p.metrics.FailedGetAllTotal.Inc()
+			return err
+		}
+
+		batch, err := p.streamAllOrdersBatch(ctx, after, options)
+		if err != nil {
+			p.metrics.FailedGetAllTotal.Inc()
+			return classify(err)
+		}
+		for i := range batch {
+			if err := fn(&batch[i]); err != nil {
+				return err
+			}
+		}
+		if len(batch) < StreamBatchSize {
+			break
+		}
+		after = &batch[len(batch)-1]
+	}
+
+	p.metrics.SuccessfulGetAllTotal.Inc()
+	p.metrics.GetAllDuration.Observe(time.Since(startTime).Seconds())
+	return nil
+}
+
+// streamAllOrdersBatch читает пакет StreamAllOrders после заказа after (nil — первый пакет)
+func (p *Postgres) streamAllOrdersBatch(ctx context.Context, after *models.Order, options interfaces.ReadOptions) ([]models.Order, error) {
+	query, args := StreamAllOrdersFirstQuery, []any{StreamBatchSize, options.IncludeDeleted}
+	if after != nil {
+		query, args = StreamAllOrdersAfterQuery, []any{after.DateCreated, after.OrderUID, StreamBatchSize, options.IncludeDeleted}
+	}
+
+	var orders []models.Order
+	err := retry.DoWithContext(ctx, retry.DefaultPolicy(), p.readingFrom(options, "stream_all_orders", func(ctx context.Context, db *pgxpool.Pool) error {
+		ctx, cancel := p.getAllContext(ctx)
+		defer cancel()
+
+		queryStartTime := time.Now()
+		rows, err := db.Query(ctx, query, args...)
+		if err != nil {
+			p.metrics.QueryErrorsTotal.Inc()
+			p.metrics.QueryErrors.WithLabelValues("stream_all_orders").Inc()
+			return fmt.Errorf("Ошибка при запросе пакета заказов: %w", err)
+		}
+		defer rows.Close()
+
+		orders = make([]models.Order, 0, StreamBatchSize)
+		for rows.Next() {
+			var order models.Order
+			if err := scanOrder(rows, &order); err != nil {
+				p.metrics.QueryErrorsTotal.Inc()
+				p.metrics.QueryErrors.WithLabelValues("stream_all_orders").Inc()
+				return fmt.Errorf("Ошибка при чтении заказа: %w", err)
+			}
+			orders = append(orders, order)
+		}
+		if err := rows.Err(); err != nil {
+			p.metrics.QueryErrorsTotal.Inc()
+			p.metrics.QueryErrors.WithLabelValues("stream_all_orders").Inc()
+			return fmt.Errorf("Ошибка перебора заказов: %w", err)
+		}
+		rows.Close()
+		p.metrics.QueryDuration.WithLabelValues("stream_all_orders").Observe(time.Since(queryStartTime).Seconds())
+
+		// Товары всех заказов пакета читаем одним запросом (ANY($1))
+		return p.loadItems(ctx, db, orders)
+	}))
+	if err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Ограничения времени одной попытки операции по умолчанию
+const (
+	DefaultReadTimeout   = 2 * time.Second  // Чтение заказа, страницы, проверки и подсчеты
+	DefaultWriteTimeout  = 5 * time.Second  // Сохранение и удаление заказа
+	DefaultGetAllTimeout = 30 * time.Second // Чтение всех заказов и догрузка кэша
+)
+
+// QueryTimeouts ограничения времени одной попытки операции с БД. Зависший запрос
+// завершается по дедлайну попытки, а не держит цикл повторов весь контекст вызывающего;
+// повторять ли его, решает политика retry. Нулевые значения заменяются умолчаниями.
+type QueryTimeouts struct {
+	Read   time.Duration // Чтение (DefaultReadTimeout)
+	Write  time.Duration // Запись (DefaultWriteTimeout)
+	GetAll time.Duration // Чтение всей таблицы (DefaultGetAllTimeout)
+}
+
+// SetQueryTimeouts задает ограничения времени попыток; вызывается до начала работы с БД
+func (p *Postgres) SetQueryTimeouts(t QueryTimeouts) {
+	p.timeouts = t
+}
+
+// readContext контекст одной попытки чтения
+func (p *Postgres) readContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, p.timeouts.Read, DefaultReadTimeout)
+}
+
+// writeContext контекст одной попытки записи
+func (p *Postgres) writeContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, p.timeouts.Write, DefaultWriteTimeout)
+}
+
+// getAllContext контекст одной попытки чтения всей таблицы
+func (p *Postgres) getAllContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, p.timeouts.GetAll, DefaultGetAllTimeout)
+}
+
+// cursorTimeout ограничение ожидания первой строки курсора (cursorContext)
+type cursorTimeout struct {
+	timer   *time.Timer
+	expired atomic.Bool
+}
+
+// cursorContext контекст попытки чтения курсором: ожидание первой строки ограничено временем
+// попытки чтения, как у readContext, а перебор строк — только ctx. Ограничение снимается
+// вызовом received после получения первой строки.
+func (p *Postgres) cursorContext(ctx context.Context) (context.Context, *cursorTimeout, context.CancelFunc) {
+	d := p.timeouts.Read
+	if d <= 0 {
+		d = DefaultReadTimeout
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	t := &cursorTimeout{}
+	t.timer = time.AfterFunc(d, func() {
+		t.expired.Store(true)
+		cancel()
+	})
+	return ctx, t, func() {
+		t.timer.Stop()
+		cancel()
+	}
+}
+
+// received снимает ограничение: первая строка получена
+func (t *cursorTimeout) received() {
+	t.timer.Stop()
+}
+
+// wrap отмечает ошибку запроса, прерванного ограничением, как истекший дедлайн попытки
+// (IsUnavailable), а не отмену вызывающим
+func (t *cursorTimeout) wrap(err error) error {
+	if err == nil || !t.expired.Load() {
+		return err
+	}
+	return fmt.Errorf("%w: %w", context.DeadlineExceeded, err)
+}
+
+// withTimeout ограничивает ctx временем d (def, если d не задано)
+func withTimeout(ctx context.Context, d, def time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		d = def
+	}
+	return context.WithTimeout(ctx, d)
+}
@@ -0,0 +1,114 @@
+package database
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"test_service/internal/models"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryTimeouts_Defaults(t *testing.T) {
+	p := &Postgres{}
+
+	for name, tc := range map[string]struct {
+		ctx  func(context.Context) (context.Context, context.CancelFunc)
+		want time.Duration
+	}{
+		"Read":   {p.readContext, DefaultReadTimeout},
+		"Write":  {p.writeContext, DefaultWriteTimeout},
+		"GetAll": {p.getAllContext, DefaultGetAllTimeout},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := tc.ctx(context.Background())
+			defer cancel()
+			deadline, ok := ctx.Deadline()
+			require.True(t, ok)
+			assert.WithinDuration(t, time.Now().Add(tc.want), deadline, 100*time.Millisecond)
+		})
+	}
+
+	p.SetQueryTimeouts(QueryTimeouts{Read: time.Second})
+	ctx, cancel := p.readContext(context.Background())
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+	assert.WithinDuration(t, time.Now().Add(time.Second), deadline, 100*time.Millisecond)
+
+	// Более ранний дедлайн вызывающего сохраняется
+	parent, cancelParent := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelParent()
+	ctx, cancel = p.writeContext(parent)
+	defer cancel()
+	parentDeadline, _ := parent.Deadline()
+	deadline, _ = ctx.Deadline()
+	assert.Equal(t, parentDeadline, deadline)
+}
+
+// newHungPool пул к серверу, который принимает соединения и ничего не отвечает:
+// запрос зависает до дедлайна попытки
+func newHungPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { _ = conn.Close() })
+		}
+	}()
+
+	config, err := pgxpool.ParseConfig("postgres://user:pass@" + ln.Addr().String() + "/db?sslmode=disable")
+	require.NoError(t, err)
+	pool, err := pgxpool.NewWithConfig(context.Background(), config)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+func TestQueryTimeouts_HungServerFailsFast(t *testing.T) {
+	p := &Postgres{pool: newHungPool(t), metrics: NewDBMetrics()}
+	p.SetQueryTimeouts(QueryTimeouts{Read: 100 * time.Millisecond})
+
+	start := time.Now()
+	_, err := p.OrderExists(context.Background(), "order-1")
+	elapsed := time.Since(start)
+
+	require.Error(t, err)
+	assert.True(t, IsUnavailable(err), "истекший дедлайн попытки считается недоступностью БД: %v", err)
+	assert.Less(t, elapsed, 2*time.Second, "вызывающий без дедлайна не ждет дольше попыток")
+}
+
+func TestStreamOrders_Unavailable(t *testing.T) {
+	fastPolicy := func(p *Postgres) { p.SetQueryTimeouts(QueryTimeouts{Read: 100 * time.Millisecond}) }
+
+	t.Run("Unreachable", func(t *testing.T) {
+		p := &Postgres{pool: newUnreachablePool(t), metrics: NewDBMetrics()}
+		fastPolicy(p)
+
+		err := p.StreamOrders(context.Background(), func(*models.Order) error {
+			t.Error("заказов нет")
+			return nil
+		})
+		assert.ErrorIs(t, err, ErrUnavailable, "недоступность БД при выгрузке распознается: %v", err)
+	})
+
+	t.Run("HungServerFailsFast", func(t *testing.T) {
+		p := &Postgres{pool: newHungPool(t), metrics: NewDBMetrics()}
+		fastPolicy(p)
+
+		start := time.Now()
+		err := p.StreamOrders(context.Background(), func(*models.Order) error { return nil })
+		require.Error(t, err)
+		assert.True(t, IsUnavailable(err), "ожидание первой строки ограничено временем попытки чтения: %v", err)
+		assert.Less(t, time.Since(start), 2*time.Second)
+	})
+}
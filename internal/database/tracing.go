@@ -0,0 +1,129 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName имя трассировщика запросов к БД (instrumentation scope OpenTelemetry)
+const TracerName = "test_service/internal/database"
+
+// Атрибуты спанов запросов
+const (
+	SpanAttrDBSystem = "db.system" // Всегда postgresql
+	SpanAttrOrderUID = "order_uid" // UID заказа операции, если он известен
+)
+
+// spanNameQuery имя спана запроса вне операции с меткой (withOperation)
+const spanNameQuery = "query"
+
+// operationKey ключ контекста с меткой операции и UID заказа
+type operationKey struct{}
+
+// operation метка операции (как в метриках) и UID заказа, к которому она относится
+type operation struct {
+	label    string
+	orderUID string
+}
+
+// withOperation помечает запросы контекста операцией label над заказом orderUID (пустой — без заказа).
+// Метка становится именем спана, UID — атрибутом; текст запроса и значения параметров в спан не попадают.
+func withOperation(ctx context.Context, label, orderUID string) context.Context {
+	return context.WithValue(ctx, operationKey{}, operation{label: label, orderUID: orderUID})
+}
+
+// spanKey ключ контекста с открытым спаном запроса или пакета
+type spanKey struct{}
+
+// queryTracer трассировщик pgx поверх OpenTelemetry: каждый запрос и пакет запросов — отдельный
+// клиентский спан. Спан начинается из контекста запроса, поэтому становится дочерним для спана
+// HTTP-запроса или сообщения Kafka, если он есть в контексте.
+type queryTracer struct {
+	tracer trace.Tracer
+}
+
+func (t *queryTracer) start(ctx context.Context) context.Context {
+	op, _ := ctx.Value(operationKey{}).(operation)
+	name := op.label
+	if name == "" {
+		name = spanNameQuery
+	}
+	attrs := []attribute.KeyValue{attribute.String(SpanAttrDBSystem, "postgresql")}
+	if op.orderUID != "" {
+		attrs = append(attrs, attribute.String(SpanAttrOrderUID, op.orderUID))
+	}
+	ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+func (t *queryTracer) end(ctx context.Context, err error) {
+	// Спан берем по своему ключу, а не trace.SpanFromContext: без start это был бы спан родителя
+	span, ok := ctx.Value(spanKey{}).(trace.Span)
+	if !ok {
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (t *queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceQueryStartData) context.Context {
+	return t.start(ctx)
+}
+
+func (t *queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	t.end(ctx, data.Err)
+}
+
+func (t *queryTracer) TraceBatchStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceBatchStartData) context.Context {
+	return t.start(ctx)
+}
+
+// TraceBatchQuery ничего не делает: пакет трассируется одним спаном
+func (t *queryTracer) TraceBatchQuery(context.Context, *pgx.Conn, pgx.TraceBatchQueryData) {}
+
+func (t *queryTracer) TraceBatchEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchEndData) {
+	t.end(ctx, data.Err)
+}
+
+// LogExporter экспортер OpenTelemetry, который пишет каждый завершенный спан в лог
+// (slog: Info, спаны с ошибкой — Warn) с trace_id и span_id родителя.
+// Используется при DB_TRACING=true, пока в сервисе нет экспортера во внешнюю систему трассировки.
+type LogExporter struct{}
+
+// ExportSpans пишет спаны в лог
+func (LogExporter) ExportSpans(_ context.Context, spans []sdktrace.ReadOnlySpan) error {
+	for _, s := range spans {
+		attrs := []any{
+			"span", s.Name(),
+			"trace_id", s.SpanContext().TraceID().String(),
+			"span_id", s.SpanContext().SpanID().String(),
+			"duration_ms", s.EndTime().Sub(s.StartTime()).Milliseconds(),
+		}
+		if s.Parent().IsValid() {
+			attrs = append(attrs, "parent_span_id", s.Parent().SpanID().String())
+		}
+		for _, kv := range s.Attributes() {
+			attrs = append(attrs, string(kv.Key), kv.Value.Emit())
+		}
+		if s.Status().Code == codes.Error {
+			slog.Warn("db span", append(attrs, "error", s.Status().Description)...)
+			continue
+		}
+		slog.Info("db span", attrs...)
+	}
+	return nil
+}
+
+// Shutdown ничего не делает: LogExporter не держит ресурсов
+func (LogExporter) Shutdown(context.Context) error {
+	return nil
+}
@@ -0,0 +1,125 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newRecordingTracer трассировщик OpenTelemetry, записывающий спаны в память
+func newRecordingTracer() (trace.Tracer, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	return provider.Tracer(TracerName), recorder
+}
+
+// endedNamed возвращает завершенные спаны с именем name
+func endedNamed(recorder *tracetest.SpanRecorder, name string) []sdktrace.ReadOnlySpan {
+	var spans []sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.Name() == name {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+// spanAttrs атрибуты спана в виде строк
+func spanAttrs(span sdktrace.ReadOnlySpan) map[string]string {
+	attrs := make(map[string]string)
+	for _, kv := range span.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	return attrs
+}
+
+func TestQueryTracer(t *testing.T) {
+	t.Run("Query", func(t *testing.T) {
+		otelTracer, recorder := newRecordingTracer()
+		tracer := &queryTracer{tracer: otelTracer}
+		parent, parentSpan := otelTracer.Start(context.Background(), "GET /api/v1/orders/{uid}")
+
+		ctx := tracer.TraceQueryStart(withOperation(parent, "get_order", "uid-1"), nil,
+			pgx.TraceQueryStartData{SQL: GetOrderByUIDQuery, Args: []any{"uid-1"}})
+		tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+		assert.True(t, parentSpan.IsRecording(), "завершение запроса не завершает родительский спан")
+		parentSpan.End()
+
+		spans := endedNamed(recorder, "get_order")
+		require.Len(t, spans, 1)
+		span := spans[0]
+		assert.Equal(t, parentSpan.SpanContext().TraceID(), span.SpanContext().TraceID())
+		assert.Equal(t, parentSpan.SpanContext().SpanID(), span.Parent().SpanID(), "спан запроса — дочерний для спана из контекста")
+		assert.Equal(t, trace.SpanKindClient, span.SpanKind())
+		assert.Equal(t, map[string]string{SpanAttrDBSystem: "postgresql", SpanAttrOrderUID: "uid-1"}, spanAttrs(span),
+			"текст запроса и параметры в спан не попадают")
+		assert.Equal(t, codes.Unset, span.Status().Code)
+	})
+
+	t.Run("BatchWithError", func(t *testing.T) {
+		otelTracer, recorder := newRecordingTracer()
+		tracer := &queryTracer{tracer: otelTracer}
+		batchErr := errors.New("batch failed")
+
+		ctx := tracer.TraceBatchStart(withOperation(context.Background(), "save_order", "uid-2"), nil, pgx.TraceBatchStartData{})
+		for i := 0; i < 3; i++ {
+			tracer.TraceBatchQuery(ctx, nil, pgx.TraceBatchQueryData{})
+		}
+		tracer.TraceBatchEnd(ctx, nil, pgx.TraceBatchEndData{Err: batchErr})
+
+		require.Len(t, recorder.Ended(), 1, "пакет — один спан")
+		span := recorder.Ended()[0]
+		assert.Equal(t, "save_order", span.Name())
+		assert.Equal(t, "uid-2", spanAttrs(span)[SpanAttrOrderUID])
+		assert.Equal(t, codes.Error, span.Status().Code)
+		assert.Equal(t, "batch failed", span.Status().Description)
+		require.Len(t, span.Events(), 1, "ошибка записана событием спана")
+	})
+
+	t.Run("WithoutOperation", func(t *testing.T) {
+		otelTracer, recorder := newRecordingTracer()
+		tracer := &queryTracer{tracer: otelTracer}
+
+		ctx := tracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "SELECT 1"})
+		tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+
+		require.Len(t, recorder.Ended(), 1)
+		span := recorder.Ended()[0]
+		assert.Equal(t, spanNameQuery, span.Name())
+		assert.NotContains(t, spanAttrs(span), SpanAttrOrderUID)
+		assert.False(t, span.Parent().IsValid(), "без спана в контексте спан запроса корневой")
+	})
+}
+
+func TestLogExporter(t *testing.T) {
+	var buf bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(LogExporter{}))
+	otelTracer := provider.Tracer(TracerName)
+	tracer := &queryTracer{tracer: otelTracer}
+
+	parent, parentSpan := otelTracer.Start(context.Background(), "consume orders")
+	ctx := tracer.TraceQueryStart(withOperation(parent, "delete_order", "uid-3"), nil, pgx.TraceQueryStartData{})
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{Err: errors.New("connection reset")})
+
+	out := buf.String()
+	assert.Contains(t, out, "level=WARN")
+	assert.Contains(t, out, "span=delete_order")
+	assert.Contains(t, out, "order_uid=uid-3")
+	assert.Contains(t, out, `error="connection reset"`)
+	assert.Contains(t, out, "trace_id="+parentSpan.SpanContext().TraceID().String())
+	assert.Contains(t, out, "parent_span_id="+parentSpan.SpanContext().SpanID().String())
+}
@@ -0,0 +1,32 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// txIsolationLevels уровни изоляции транзакции сохранения заказа по имени
+var txIsolationLevels = map[string]pgx.TxIsoLevel{
+	"read_committed":  pgx.ReadCommitted,
+	"repeatable_read": pgx.RepeatableRead,
+	"serializable":    pgx.Serializable,
+}
+
+// ParseTxIsolation возвращает уровень изоляции pgx по имени (read_committed, repeatable_read,
+// serializable); пустое имя — уровень по умолчанию сервера (default_transaction_isolation)
+func ParseTxIsolation(name string) (pgx.TxIsoLevel, error) {
+	if name == "" {
+		return "", nil
+	}
+	level, ok := txIsolationLevels[name]
+	if !ok {
+		return "", fmt.Errorf("неизвестный уровень изоляции транзакции %q", name)
+	}
+	return level, nil
+}
+
+// SetSaveIsolation задает уровень изоляции транзакции SaveOrder; вызывается до начала работы с БД
+func (p *Postgres) SetSaveIsolation(level pgx.TxIsoLevel) {
+	p.saveTx.IsoLevel = level
+}
@@ -0,0 +1,32 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTxIsolation(t *testing.T) {
+	cases := map[string]pgx.TxIsoLevel{
+		"":                "",
+		"read_committed":  pgx.ReadCommitted,
+		"repeatable_read": pgx.RepeatableRead,
+		"serializable":    pgx.Serializable,
+	}
+	for name, want := range cases {
+		level, err := ParseTxIsolation(name)
+		require.NoError(t, err, "%q", name)
+		assert.Equal(t, want, level, "%q", name)
+	}
+
+	_, err := ParseTxIsolation("read_uncommitted")
+	assert.Error(t, err)
+}
+
+func TestSetSaveIsolation(t *testing.T) {
+	p := &Postgres{}
+	p.SetSaveIsolation(pgx.Serializable)
+	assert.Equal(t, pgx.TxOptions{IsoLevel: pgx.Serializable}, p.saveTx)
+}
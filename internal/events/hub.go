@@ -0,0 +1,116 @@
+// Package events содержит внутреннюю шину событий заказов для живых обновлений
+package events
+
+import (
+	"sync"
+	"time"
+
+	"test_service/internal/models"
+)
+
+// Типы событий
+const (
+	OrderProcessed = "order.processed" // Заказ сохранен в БД и добавлен в кэш
+)
+
+// subscriberBuffer размер очереди событий одного подписчика
+const subscriberBuffer = 64
+
+// Event событие заказа
+type Event struct {
+	Type  string        `json:"type"`
+	Order *models.Order `json:"order"`
+	Time  time.Time     `json:"time"`
+}
+
+// Hub рассылает события всем подписчикам. Публикация не блокируется:
+// если очередь подписчика заполнена, событие для него отбрасывается.
+type Hub struct {
+	mu      sync.RWMutex
+	subs    map[*Subscription]struct{}
+	closed  bool
+	metrics *Metrics
+}
+
+// Subscription подписка на события; канал Events закрывается при Close или остановке Hub
+type Subscription struct {
+	hub  *Hub
+	ch   chan Event
+	once sync.Once
+}
+
+// NewHub создает шину событий
+func NewHub() *Hub {
+	return &Hub{
+		subs:    make(map[*Subscription]struct{}),
+		metrics: NewMetrics(),
+	}
+}
+
+// Subscribe регистрирует подписчика. После Close возвращает подписку с уже закрытым каналом.
+func (h *Hub) Subscribe() *Subscription {
+	s := &Subscription{hub: h, ch: make(chan Event, subscriberBuffer)}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		s.once.Do(func() { close(s.ch) })
+		return s
+	}
+	h.subs[s] = struct{}{}
+	h.metrics.Subscribers.Inc()
+	return s
+}
+
+// Publish отправляет событие всем подписчикам без ожидания
+func (h *Hub) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for s := range h.subs {
+		select {
+		case s.ch <- e:
+		default:
+			// Медленный подписчик не задерживает обработку заказов
+			h.metrics.DroppedTotal.Inc()
+		}
+	}
+}
+
+// Close закрывает каналы всех подписчиков; повторный вызов безопасен
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+	h.closed = true
+	for s := range h.subs {
+		h.remove(s)
+	}
+}
+
+// remove удаляет подписчика и закрывает его канал; вызывается под h.mu
+func (h *Hub) remove(s *Subscription) {
+	if _, ok := h.subs[s]; !ok {
+		return
+	}
+	delete(h.subs, s)
+	h.metrics.Subscribers.Dec()
+	s.once.Do(func() { close(s.ch) })
+}
+
+// Events канал событий подписки
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+// Close отменяет подписку
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
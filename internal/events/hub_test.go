@@ -0,0 +1,73 @@
+package events
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"test_service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHub_Publish(t *testing.T) {
+	hub := NewHub()
+	defer hub.Close()
+
+	a, b := hub.Subscribe(), hub.Subscribe()
+	hub.Publish(Event{Type: OrderProcessed, Order: &models.Order{OrderUID: "order-1"}})
+
+	for _, s := range []*Subscription{a, b} {
+		select {
+		case e := <-s.Events():
+			assert.Equal(t, OrderProcessed, e.Type)
+			assert.Equal(t, "order-1", e.Order.OrderUID)
+			assert.False(t, e.Time.IsZero(), "время события заполняется при публикации")
+		case <-time.After(time.Second):
+			t.Fatal("событие не доставлено")
+		}
+	}
+}
+
+func TestHub_SlowSubscriberDoesNotBlock(t *testing.T) {
+	hub := NewHub()
+	defer hub.Close()
+
+	slow := hub.Subscribe()
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < subscriberBuffer*2; i++ {
+			hub.Publish(Event{Type: OrderProcessed})
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Publish заблокирован медленным подписчиком")
+	}
+	assert.Len(t, slow.Events(), subscriberBuffer)
+}
+
+func TestHub_Close(t *testing.T) {
+	hub := NewHub()
+	s := hub.Subscribe()
+
+	// Отписка и остановка в любом порядке не паникуют
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() { defer wg.Done(); s.Close() }()
+	go func() { defer wg.Done(); hub.Close() }()
+	wg.Wait()
+	hub.Close()
+
+	_, ok := <-s.Events()
+	assert.False(t, ok, "канал подписки закрыт")
+
+	late := hub.Subscribe()
+	_, ok = <-late.Events()
+	require.False(t, ok, "подписка после остановки сразу закрыта")
+	hub.Publish(Event{Type: OrderProcessed})
+}
@@ -0,0 +1,36 @@
+package events
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metrics содержит метрики шины событий
+type Metrics struct {
+	Subscribers  prometheus.Gauge
+	DroppedTotal prometheus.Counter
+}
+
+// Global metrics для предотвращения дублирования метрик
+var globalMetrics *Metrics
+
+// NewMetrics создает и регистрирует метрики шины событий
+func NewMetrics() *Metrics {
+	// Возвращаем глобальный экземпляр, чтобы избежать дублирования метрик
+	if globalMetrics != nil {
+		return globalMetrics
+	}
+
+	globalMetrics = &Metrics{
+		Subscribers: promauto.NewGauge(prometheus.GaugeOpts{
+			Name: "events_subscribers",
+			Help: "Количество подписчиков на события заказов",
+		}),
+		DroppedTotal: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "events_dropped_total",
+			Help: "Количество событий, отброшенных из-за заполненной очереди подписчика",
+		}),
+	}
+
+	return globalMetrics
+}
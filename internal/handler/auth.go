@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// AdminKeyHeader заголовок с ключом административного API
+const AdminKeyHeader = "X-Admin-Key"
+
+// AdminAuth пропускает запрос только с верным ключом администратора
+// (заголовок X-Admin-Key или Authorization: Bearer <key>).
+// Если ключ не задан, административные маршруты отключены.
+func AdminAuth(apiKey string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if apiKey == "" {
+			writeJSONError(w, r, http.StatusForbidden, "Административное API отключено")
+			return
+		}
+
+		provided := r.Header.Get(AdminKeyHeader)
+		if provided == "" {
+			provided = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		}
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(apiKey)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSONError(w, r, http.StatusUnauthorized, "Требуется ключ администратора")
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminAuth(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }
+
+	cases := []struct {
+		name    string
+		apiKey  string
+		headers map[string]string
+		status  int
+	}{
+		{"DisabledWithoutKey", "", map[string]string{AdminKeyHeader: ""}, http.StatusForbidden},
+		{"MissingKey", "secret", nil, http.StatusUnauthorized},
+		{"WrongKey", "secret", map[string]string{AdminKeyHeader: "wrong"}, http.StatusUnauthorized},
+		{"HeaderKey", "secret", map[string]string{AdminKeyHeader: "secret"}, http.StatusNoContent},
+		{"BearerKey", "secret", map[string]string{"Authorization": "Bearer secret"}, http.StatusNoContent},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/export", nil)
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			AdminAuth(tc.apiKey, ok)(rec, req)
+			assert.Equal(t, tc.status, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"test_service/internal/kafka"
+	"test_service/internal/models"
+)
+
+const (
+	dlqReplayDefaultMax = 100  // Количество сообщений за запуск, если max не указан
+	dlqReplayMaxLimit   = 1000 // Верхняя граница параметра max
+)
+
+// DLQReplayer повторно обрабатывает сообщения из DLQ
+type DLQReplayer interface {
+	Replay(ctx context.Context, opts kafka.DLQReplayOptions, processFunc func(context.Context, *models.Order) error) (kafka.DLQReplaySummary, error)
+}
+
+// dlqReplayHandler обрабатывает POST /admin/dlq/replay?max=N&until=RFC3339&dry_run=true.
+// Одновременно выполняется только один запуск; запрос ограничен timeout,
+// по истечении которого возвращаются итоги по уже обработанным сообщениям.
+type dlqReplayHandler struct {
+	service  OrderService
+	replayer DLQReplayer
+	timeout  time.Duration
+	running  sync.Mutex // Занят на время запуска
+}
+
+// ServeHTTP запускает повторную обработку и возвращает JSON {replayed, failed, skipped}
+func (h *dlqReplayHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	max := dlqReplayDefaultMax
+	if v := r.URL.Query().Get("max"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > dlqReplayMaxLimit {
+			writeJSONError(w, r, http.StatusBadRequest, "Параметр max должен быть числом от 1 до "+strconv.Itoa(dlqReplayMaxLimit))
+			return
+		}
+		max = n
+	}
+	opts := kafka.DLQReplayOptions{Max: max}
+	if v := r.URL.Query().Get("until"); v != "" {
+		until, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeJSONError(w, r, http.StatusBadRequest, "Параметр until должен быть временем в формате RFC3339")
+			return
+		}
+		opts.Until = until
+	}
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		dryRun, err := strconv.ParseBool(v)
+		if err != nil {
+			writeJSONError(w, r, http.StatusBadRequest, "Параметр dry_run должен быть true или false")
+			return
+		}
+		opts.DryRun = dryRun
+	}
+
+	if !h.running.TryLock() {
+		writeJSONError(w, r, http.StatusConflict, "Повторная обработка DLQ уже выполняется")
+		return
+	}
+	defer h.running.Unlock()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	summary, err := h.replayer.Replay(ctx, opts, h.service.ProcessOrder)
+	log.Printf("Повторная обработка DLQ (dry-run %t): обработано %d, с ошибками %d, пропущено %d, к обработке %d",
+		opts.DryRun, summary.Replayed, summary.Failed, summary.Skipped, summary.Pending)
+
+	response := map[string]interface{}{
+		"replayed": summary.Replayed,
+		"failed":   summary.Failed,
+		"skipped":  summary.Skipped,
+	}
+	if opts.DryRun {
+		response["dry_run"] = true
+		response["pending"] = summary.Pending
+		response["reasons"] = summary.Reasons
+	}
+	status := http.StatusOK
+	if err != nil && ctx.Err() == nil {
+		// Ошибка Kafka: частичные итоги возвращаются вместе с ошибкой
+		log.Printf("Ошибка повторной обработки DLQ: %v", err)
+		response["error"] = err.Error()
+		status = http.StatusBadGateway
+	} else if err != nil {
+		// Истек таймаут: оставшиеся сообщения обработает следующий запуск
+		response["timed_out"] = true
+	}
+
+	writeJSON(w, r, status, response)
+}
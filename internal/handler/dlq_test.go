@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"test_service/internal/kafka"
+	"test_service/internal/mocks"
+	"test_service/internal/models"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDLQReplayer возвращает заданный результат и запоминает параметры вызова
+type fakeDLQReplayer struct {
+	summary kafka.DLQReplaySummary
+	err     error
+	opts    kafka.DLQReplayOptions
+	block   bool // Ждать отмены контекста (таймаут запроса)
+}
+
+func (f *fakeDLQReplayer) Replay(ctx context.Context, opts kafka.DLQReplayOptions, processFunc func(context.Context, *models.Order) error) (kafka.DLQReplaySummary, error) {
+	f.opts = opts
+	if processFunc == nil {
+		return kafka.DLQReplaySummary{}, errors.New("processFunc is nil")
+	}
+	if f.block {
+		<-ctx.Done()
+		return f.summary, ctx.Err()
+	}
+	return f.summary, f.err
+}
+
+func TestDLQReplay(t *testing.T) {
+	newRoutes := func(t *testing.T, replayer *fakeDLQReplayer) http.Handler {
+		ctrl := gomock.NewController(t)
+		t.Cleanup(ctrl.Finish)
+		return Routes(mocks.NewMockOrderService(ctrl), Options{
+			AdminAPIKey:      "secret",
+			DLQReplayer:      replayer,
+			DLQReplayTimeout: 50 * time.Millisecond,
+		})
+	}
+
+	replay := func(h http.Handler, query string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		req := httptest.NewRequest(http.MethodPost, "/admin/dlq/replay"+query, nil)
+		req.Header.Set(AdminKeyHeader, "secret")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		var body map[string]interface{}
+		_ = json.Unmarshal(rec.Body.Bytes(), &body)
+		return rec, body
+	}
+
+	t.Run("Summary", func(t *testing.T) {
+		replayer := &fakeDLQReplayer{summary: kafka.DLQReplaySummary{Replayed: 3, Failed: 1, Skipped: 2}}
+
+		rec, body := replay(newRoutes(t, replayer), "?max=10")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, kafka.DLQReplayOptions{Max: 10}, replayer.opts)
+		assert.Equal(t, float64(3), body["replayed"])
+		assert.Equal(t, float64(1), body["failed"])
+		assert.Equal(t, float64(2), body["skipped"])
+	})
+
+	t.Run("DefaultMax", func(t *testing.T) {
+		replayer := &fakeDLQReplayer{}
+
+		rec, _ := replay(newRoutes(t, replayer), "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, dlqReplayDefaultMax, replayer.opts.Max)
+	})
+
+	t.Run("DryRunUntil", func(t *testing.T) {
+		replayer := &fakeDLQReplayer{summary: kafka.DLQReplaySummary{Pending: 4, Reasons: map[string]int{kafka.DLQReasonProcessing: 4}}}
+
+		rec, body := replay(newRoutes(t, replayer), "?dry_run=true&until=2024-05-01T03:00:00Z")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.True(t, replayer.opts.DryRun)
+		assert.Equal(t, time.Date(2024, 5, 1, 3, 0, 0, 0, time.UTC), replayer.opts.Until.UTC())
+		assert.Equal(t, true, body["dry_run"])
+		assert.Equal(t, float64(4), body["pending"])
+		assert.Equal(t, map[string]interface{}{"processing": float64(4)}, body["reasons"])
+	})
+
+	t.Run("InvalidParams", func(t *testing.T) {
+		routes := newRoutes(t, &fakeDLQReplayer{})
+
+		for _, query := range []string{"?max=0", "?max=abc", "?max=100000", "?until=yesterday", "?dry_run=maybe"} {
+			rec, _ := replay(routes, query)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+		}
+	})
+
+	t.Run("TimeoutReturnsPartialSummary", func(t *testing.T) {
+		replayer := &fakeDLQReplayer{summary: kafka.DLQReplaySummary{Replayed: 1}, block: true}
+
+		rec, body := replay(newRoutes(t, replayer), "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, float64(1), body["replayed"])
+		assert.Equal(t, true, body["timed_out"])
+	})
+
+	t.Run("KafkaError", func(t *testing.T) {
+		replayer := &fakeDLQReplayer{err: errors.New("broker unavailable")}
+
+		rec, body := replay(newRoutes(t, replayer), "")
+		assert.Equal(t, http.StatusBadGateway, rec.Code)
+		assert.Contains(t, body["error"], "broker unavailable")
+	})
+
+	t.Run("RequiresAdminKey", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		newRoutes(t, &fakeDLQReplayer{}).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/dlq/replay", nil))
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"test_service/internal/models"
+)
+
+// fieldSelection выбранные поля: ключ — поле верхнего уровня,
+// значение — вложенные поля (nil — поле целиком)
+type fieldSelection map[string][]string
+
+// orderFields допустимые поля заказа, полученные из json тегов models.Order:
+// поле верхнего уровня → допустимые вложенные поля (для объектов и массивов объектов)
+var orderFields = jsonFields(reflect.TypeOf(models.Order{}))
+
+// jsonFields собирает имена json полей структуры и ее вложенных структур
+func jsonFields(t reflect.Type) map[string]map[string]bool {
+	fields := make(map[string]map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := jsonName(f)
+		if name == "" {
+			continue
+		}
+		ft := f.Type
+		if ft.Kind() == reflect.Slice {
+			ft = ft.Elem()
+		}
+		var nested map[string]bool
+		if ft.Kind() == reflect.Struct && ft.NumField() > 0 && ft.PkgPath() == t.PkgPath() {
+			nested = make(map[string]bool)
+			for j := 0; j < ft.NumField(); j++ {
+				if n := jsonName(ft.Field(j)); n != "" {
+					nested[n] = true
+				}
+			}
+		}
+		fields[name] = nested
+	}
+	return fields
+}
+
+// jsonName возвращает имя поля в JSON или пустую строку для скрытых полей
+func jsonName(f reflect.StructField) string {
+	if !f.IsExported() {
+		return ""
+	}
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+	if name, _, _ := strings.Cut(tag, ","); name != "" {
+		return name
+	}
+	return f.Name
+}
+
+// validOrderFields возвращает отсортированный список допустимых полей, включая вложенные
+func validOrderFields() []string {
+	names := make([]string, 0, len(orderFields))
+	for name, nested := range orderFields {
+		names = append(names, name)
+		for sub := range nested {
+			names = append(names, name+"."+sub)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseFields разбирает параметр ?fields=a,b,delivery.city.
+// Возвращает nil, если параметр не задан, и список неизвестных полей при ошибке.
+func parseFields(r *http.Request) (fieldSelection, []string) {
+	raw := strings.TrimSpace(r.URL.Query().Get("fields"))
+	if raw == "" {
+		return nil, nil
+	}
+
+	sel := make(fieldSelection)
+	var unknown []string
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		top, sub, nested := strings.Cut(name, ".")
+		allowed, ok := orderFields[top]
+		if !ok || (nested && !allowed[sub]) {
+			unknown = append(unknown, name)
+			continue
+		}
+		if !nested {
+			// Поле целиком перекрывает ранее выбранные вложенные поля
+			sel[top] = nil
+			continue
+		}
+		if subs, exists := sel[top]; !exists || subs != nil {
+			sel[top] = append(subs, sub)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, unknown
+	}
+	if len(sel) == 0 {
+		return nil, nil
+	}
+	return sel, nil
+}
+
+// writeUnknownFields возвращает 400 со списком допустимых полей
+func writeUnknownFields(w http.ResponseWriter, r *http.Request, unknown []string) {
+	writeJSON(w, r, http.StatusBadRequest, map[string]interface{}{
+		"error":        fmt.Sprintf("Неизвестные поля: %s", strings.Join(unknown, ", ")),
+		"valid_fields": validOrderFields(),
+	})
+}
+
+// project оставляет в JSON представлении заказа только выбранные поля
+func project(order *models.Order, sel fieldSelection) (interface{}, error) {
+	if sel == nil {
+		return order, nil
+	}
+
+	data, err := json.Marshal(order)
+	if err != nil {
+		return nil, err
+	}
+	var full map[string]json.RawMessage
+	if err := json.Unmarshal(data, &full); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]interface{}, len(sel))
+	for top, subs := range sel {
+		value, ok := full[top]
+		if !ok {
+			continue
+		}
+		if subs == nil {
+			result[top] = value
+			continue
+		}
+		projected, err := projectNested(value, subs)
+		if err != nil {
+			return nil, err
+		}
+		result[top] = projected
+	}
+	return result, nil
+}
+
+// projectNested оставляет выбранные поля во вложенном объекте или в каждом элементе массива объектов
+func projectNested(value json.RawMessage, subs []string) (interface{}, error) {
+	pick := func(obj map[string]json.RawMessage) map[string]json.RawMessage {
+		out := make(map[string]json.RawMessage, len(subs))
+		for _, sub := range subs {
+			if v, ok := obj[sub]; ok {
+				out[sub] = v
+			}
+		}
+		return out
+	}
+
+	if trimmed := strings.TrimSpace(string(value)); strings.HasPrefix(trimmed, "[") {
+		var list []map[string]json.RawMessage
+		if err := json.Unmarshal(value, &list); err != nil {
+			return nil, err
+		}
+		out := make([]map[string]json.RawMessage, 0, len(list))
+		for _, obj := range list {
+			out = append(out, pick(obj))
+		}
+		return out, nil
+	}
+
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(value, &obj); err != nil {
+		return nil, err
+	}
+	return pick(obj), nil
+}
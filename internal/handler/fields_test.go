@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"test_service/internal/interfaces"
+	"test_service/internal/mocks"
+	"test_service/internal/models"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_GetOrderFields(t *testing.T) {
+	order := &models.Order{
+		OrderUID:    testOrderUID,
+		TrackNumber: "TRACK123",
+		DateCreated: time.Date(2024, 5, 1, 3, 0, 0, 0, time.UTC),
+		Delivery:    models.Delivery{Name: "Test", City: "Moscow"},
+		Items:       []models.Item{{Name: "Case", Price: 100}, {Name: "Phone", Price: 900}},
+	}
+
+	get := func(t *testing.T, query string, expectCall bool) *httptest.ResponseRecorder {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockService := mocks.NewMockOrderService(ctrl)
+		if expectCall {
+			mockService.EXPECT().GetOrderWithSource(gomock.Any(), testOrderUID).Return(order, interfaces.SourceCache, nil)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/"+testOrderUID+query, nil)
+		req.SetPathValue("uid", testOrderUID)
+		rec := httptest.NewRecorder()
+		New(mockService).GetOrder(rec, req)
+		return rec
+	}
+
+	decode := func(t *testing.T, rec *httptest.ResponseRecorder) map[string]interface{} {
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		return body
+	}
+
+	t.Run("TopLevelFields", func(t *testing.T) {
+		rec := get(t, "?fields=order_uid,track_number,date_created", true)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		body := decode(t, rec)
+		assert.Len(t, body, 3)
+		assert.Equal(t, testOrderUID, body["order_uid"])
+		assert.Equal(t, "TRACK123", body["track_number"])
+		assert.Equal(t, "2024-05-01T03:00:00Z", body["date_created"])
+	})
+
+	t.Run("NestedFields", func(t *testing.T) {
+		rec := get(t, "?fields=delivery.city,items.name", true)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		body := decode(t, rec)
+		assert.Equal(t, map[string]interface{}{"city": "Moscow"}, body["delivery"])
+		assert.Equal(t, []interface{}{
+			map[string]interface{}{"name": "Case"},
+			map[string]interface{}{"name": "Phone"},
+		}, body["items"])
+	})
+
+	t.Run("WholeFieldWinsOverNested", func(t *testing.T) {
+		rec := get(t, "?fields=delivery.city,delivery", true)
+		body := decode(t, rec)
+		assert.Equal(t, "Test", body["delivery"].(map[string]interface{})["name"])
+	})
+
+	t.Run("UnknownField", func(t *testing.T) {
+		rec := get(t, "?fields=order_uid,secret,delivery.planet", false)
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+
+		body := decode(t, rec)
+		assert.Contains(t, body["error"], "secret")
+		assert.Contains(t, body["error"], "delivery.planet")
+		assert.Contains(t, body["valid_fields"], "track_number")
+		assert.Contains(t, body["valid_fields"], "delivery.city")
+		assert.NotContains(t, body["valid_fields"], "delivery.OrderUID")
+	})
+
+	t.Run("NoFieldsReturnsFullOrder", func(t *testing.T) {
+		rec := get(t, "", true)
+		body := decode(t, rec)
+		assert.Contains(t, body, "payment")
+		assert.Contains(t, body, "items")
+	})
+}
@@ -2,69 +2,498 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"test_service/internal/database"
+	"test_service/internal/interfaces"
 	"test_service/internal/models"
 )
 
+// CacheHeader заголовок с источником заказа: HIT — из кэша, MISS — из БД
+const CacheHeader = "X-Cache"
+
+// retryAfterSeconds подсказка клиенту, через сколько повторить запрос при недоступной БД
+const retryAfterSeconds = 5
+
+// readinessCheckTimeout ограничение времени проверки БД в /readyz
+const readinessCheckTimeout = 2 * time.Second
+
 // OrderService определяет интерфейс для работы с заказами
 type OrderService interface {
-	GetOrder(orderUID string) (*models.Order, error) // Получить заказ по UID
-	GetCacheStats() map[string]interface{}           // Получить статистику кэша
+	// Получить заказ по UID и источник (кэш или БД)
+	GetOrderWithSource(ctx context.Context, orderUID string) (*models.Order, interfaces.Source, error)
+
+	// Найти заказы по трек-номеру и источник (кэш или БД)
+	GetOrdersByTrackNumber(ctx context.Context, trackNumber string) ([]*models.Order, interfaces.Source, error)
+
+	// Найти заказы по email или телефону покупателя
+	FindOrdersByContact(ctx context.Context, email, phone string, limit int) ([]models.Order, error)
+
+	ProcessOrder(ctx context.Context, order *models.Order) error // Сохранить заказ в БД и кэш
+	DeleteOrder(ctx context.Context, orderUID string) error      // Удалить заказ из БД и кэша
+	SoftDeleteOrder(ctx context.Context, orderUID string) error  // Скрыть заказ, сохранив данные в БД
+	GetCacheStats() map[string]interface{}                       // Получить статистику кэша
+
+	StreamOrders(ctx context.Context, fn func(*models.Order) error) error // Потоково перебрать все заказы
+
+	// Сменить статус заказа с проверкой перехода
+	UpdateOrderStatus(ctx context.Context, orderUID string, status models.OrderStatus) (*models.Order, error)
 }
 
+// exportFlushEvery количество заказов между сбросами буфера при выгрузке
+const exportFlushEvery = 100
+
 // Handler содержит HTTP обработчики для API
 type Handler struct {
-	service OrderService // Сервис для работы с заказами
+	service OrderService    // Сервис для работы с заказами
+	metrics *HandlerMetrics // Метрики обработчиков
 }
 
 // New создает новый экземпляр HTTP обработчика
 func New(service OrderService) *Handler {
-	return &Handler{service: service}
+	return &Handler{service: service, metrics: NewHandlerMetrics()}
+}
+
+// WithoutWriteTimeout снимает серверный WriteTimeout для долгих потоковых ответов
+func WithoutWriteTimeout(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Нулевое время означает отсутствие дедлайна записи для этого соединения
+		if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+			log.Printf("Не удалось снять дедлайн записи для %s: %v", r.URL.Path, err)
+		}
+		next(w, r)
+	}
 }
 
 // GetOrder обрабатывает HTTP запрос для получения заказа по UID
 func (h *Handler) GetOrder(w http.ResponseWriter, r *http.Request) {
-	// Извлекаем order_uid из URL пути (убираем префикс "/order/")
-	path := strings.TrimPrefix(r.URL.Path, "/order/")
+	// Извлекаем order_uid из шаблона маршрута {uid}
+	path := r.PathValue("uid")
 	if path == "" {
 		http.Error(w, "Требуется идентификатор заказа", http.StatusBadRequest)
 		return
 	}
 
+	// Заведомо неверный идентификатор не доходит до кэша и БД
+	if err := models.ValidateOrderUID(path); err != nil {
+		h.metrics.InvalidOrderUIDTotal.Inc()
+		writeJSONError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Проекция полей (?fields=order_uid,track_number)
+	fields, unknown := parseFields(r)
+	if unknown != nil {
+		writeUnknownFields(w, r, unknown)
+		return
+	}
+
+	// Формат ответа по заголовку Accept: JSON по умолчанию, XML для старых интеграций
+	w.Header().Set("Vary", "Accept")
+	mediaType := negotiate(r)
+	if mediaType != mediaJSON && fields != nil {
+		writeJSONError(w, r, http.StatusNotAcceptable, "Параметр fields поддерживается только для JSON")
+		return
+	}
+
 	// Получаем заказ через сервис
-	order, err := h.service.GetOrder(path)
+	// Контекст запроса: отключение клиента прерывает запрос к БД
+	order, source, err := h.service.GetOrderWithSource(r.Context(), path)
 	if err != nil {
-		http.Error(w, "Заказ не найден", http.StatusNotFound)
+		switch {
+		case errors.Is(err, models.ErrOrderNotFound):
+			writeJSONError(w, r, http.StatusNotFound, "Заказ не найден")
+		case database.IsUnavailable(err):
+			// Промах кэша при недоступной БД: отсутствие заказа не подтверждено, просим повторить позже
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds))
+			writeJSONError(w, r, http.StatusServiceUnavailable, "Сервис временно недоступен, повторите запрос позже")
+		default:
+			log.Printf("Ошибка получения заказа %s: %v", path, err)
+			writeJSONError(w, r, http.StatusInternalServerError, "Не удалось получить заказ")
+		}
+		return
+	}
+
+	// Источник ответа для диагностики задержек
+	if source == interfaces.SourceCache {
+		w.Header().Set(CacheHeader, "HIT")
+	} else {
+		w.Header().Set(CacheHeader, "MISS")
+	}
+
+	// Валидаторы для условных запросов
+	if !order.UpdatedAt.IsZero() {
+		w.Header().Set("ETag", orderETag(order))
+		w.Header().Set("Last-Modified", order.UpdatedAt.UTC().Format(http.TimeFormat))
+	}
+	if notModified(r, order) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
-	// Возвращаем заказ в формате JSON
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(order); err != nil {
+	// Кодируем один раз: длина нужна и GET, и HEAD
+	data, err := marshalOrder(r, mediaType, order, fields)
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Возвращаем заказ в согласованном формате
+	w.Header().Set("Content-Type", contentType(mediaType))
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	if r.Method == http.MethodHead {
+		// Те же заголовки, но без тела
+		w.WriteHeader(http.StatusOK)
+		return
 	}
+	if _, err := w.Write(data); err != nil {
+		log.Printf("Ошибка записи ответа: %v", err)
+	}
+}
+
+// SearchOrders обрабатывает HTTP запрос поиска заказов по трек-номеру (?track_number=)
+func (h *Handler) SearchOrders(w http.ResponseWriter, r *http.Request) {
+	track := r.URL.Query().Get("track_number")
+	if track == "" {
+		writeJSONError(w, r, http.StatusBadRequest, "Требуется параметр track_number")
+		return
+	}
+
+	// Проекция полей применяется к каждому найденному заказу
+	fields, unknown := parseFields(r)
+	if unknown != nil {
+		writeUnknownFields(w, r, unknown)
+		return
+	}
+
+	orders, source, err := h.service.GetOrdersByTrackNumber(r.Context(), track)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrOrderNotFound):
+			writeJSONError(w, r, http.StatusNotFound, "Заказы не найдены")
+		case database.IsUnavailable(err):
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds))
+			writeJSONError(w, r, http.StatusServiceUnavailable, "Сервис временно недоступен, повторите запрос позже")
+		default:
+			log.Printf("Ошибка поиска заказов по трек-номеру %s: %v", track, err)
+			writeJSONError(w, r, http.StatusInternalServerError, "Не удалось найти заказы")
+		}
+		return
+	}
+
+	body := make([]interface{}, 0, len(orders))
+	for _, order := range orders {
+		projected, err := project(order, fields)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		body = append(body, projected)
+	}
+
+	if source == interfaces.SourceCache {
+		w.Header().Set(CacheHeader, "HIT")
+	} else {
+		w.Header().Set(CacheHeader, "MISS")
+	}
+	writeJSON(w, r, http.StatusOK, body)
+}
+
+// FindOrdersByContact обрабатывает GET /api/v1/orders/by-contact?email=...&phone=...&limit=N —
+// поиск заказов по контактам покупателя для поддержки. Email сравнивается без учета регистра,
+// телефон — без оформления; достаточно одного из них. Ничего не найдено — пустой массив.
+func (h *Handler) FindOrdersByContact(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	email, phone := query.Get("email"), query.Get("phone")
+	if models.NormalizeEmail(email) == "" && models.NormalizePhone(phone) == "" {
+		writeJSONError(w, r, http.StatusBadRequest, "Требуется параметр email или phone")
+		return
+	}
+
+	limit := database.DefaultPageSize
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > database.MaxPageSize {
+			writeJSONError(w, r, http.StatusBadRequest, "Параметр limit должен быть числом от 1 до "+strconv.Itoa(database.MaxPageSize))
+			return
+		}
+		limit = n
+	}
+
+	fields, unknown := parseFields(r)
+	if unknown != nil {
+		writeUnknownFields(w, r, unknown)
+		return
+	}
+
+	orders, err := h.service.FindOrdersByContact(r.Context(), email, phone, limit)
+	if err != nil {
+		if database.IsUnavailable(err) {
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds))
+			writeJSONError(w, r, http.StatusServiceUnavailable, "Сервис временно недоступен, повторите запрос позже")
+			return
+		}
+		log.Printf("Ошибка поиска заказов по контактам: %v", err)
+		writeJSONError(w, r, http.StatusInternalServerError, "Не удалось найти заказы")
+		return
+	}
+
+	body := make([]interface{}, 0, len(orders))
+	for i := range orders {
+		projected, err := project(&orders[i], fields)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		body = append(body, projected)
+	}
+	writeJSON(w, r, http.StatusOK, body)
+}
+
+// marshalOrder кодирует заказ в выбранном формате; проекция полей применяется только к JSON
+func marshalOrder(r *http.Request, mediaType string, order *models.Order, fields fieldSelection) ([]byte, error) {
+	if mediaType != mediaJSON {
+		return marshalXML(r, order)
+	}
+	body, err := project(order, fields)
+	if err != nil {
+		return nil, err
+	}
+	return marshalJSON(r, body)
+}
+
+// DeleteOrder обрабатывает HTTP запрос на удаление заказа по UID.
+// С ?soft=true заказ только скрывается (мягкое удаление), данные остаются в БД.
+func (h *Handler) DeleteOrder(w http.ResponseWriter, r *http.Request) {
+	uid := r.PathValue("uid")
+	if err := models.ValidateOrderUID(uid); err != nil {
+		h.metrics.InvalidOrderUIDTotal.Inc()
+		writeJSONError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	del := h.service.DeleteOrder
+	if v := r.URL.Query().Get("soft"); v != "" {
+		soft, err := strconv.ParseBool(v)
+		if err != nil {
+			writeJSONError(w, r, http.StatusBadRequest, "Параметр soft должен быть true или false")
+			return
+		}
+		if soft {
+			del = h.service.SoftDeleteOrder
+		}
+	}
+
+	if err := del(r.Context(), uid); err != nil {
+		if errors.Is(err, models.ErrOrderNotFound) {
+			writeJSONError(w, r, http.StatusNotFound, "Заказ не найден")
+			return
+		}
+		log.Printf("Ошибка удаления заказа %s: %v", uid, err)
+		writeJSONError(w, r, http.StatusInternalServerError, "Не удалось удалить заказ")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// statusBodyLimit наибольший размер тела запроса смены статуса
+const statusBodyLimit = 1 << 10
+
+// UpdateOrderStatus обрабатывает PATCH /api/v1/orders/{uid}/status с телом {"status": "..."}
+// и возвращает заказ с новым статусом. Неизвестный статус — 400, недопустимый переход
+// (например, shipped → accepted) — 409.
+func (h *Handler) UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
+	uid := r.PathValue("uid")
+	if err := models.ValidateOrderUID(uid); err != nil {
+		h.metrics.InvalidOrderUIDTotal.Inc()
+		writeJSONError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var req struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, statusBodyLimit)).Decode(&req); err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, "Тело запроса должно быть JSON вида {\"status\": \"...\"}")
+		return
+	}
+	status, err := models.ParseOrderStatus(req.Status)
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	order, err := h.service.UpdateOrderStatus(r.Context(), uid, status)
+	if err != nil {
+		var transitionErr *models.StatusTransitionError
+		switch {
+		case errors.As(err, &transitionErr):
+			writeJSONError(w, r, http.StatusConflict, err.Error())
+		case errors.Is(err, models.ErrOrderNotFound):
+			writeJSONError(w, r, http.StatusNotFound, "Заказ не найден")
+		case database.IsUnavailable(err):
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds))
+			writeJSONError(w, r, http.StatusServiceUnavailable, "Сервис временно недоступен, повторите запрос позже")
+		default:
+			log.Printf("Ошибка смены статуса заказа %s: %v", uid, err)
+			writeJSONError(w, r, http.StatusInternalServerError, "Не удалось изменить статус заказа")
+		}
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, order)
+}
+
+// ExportOrders выгружает все заказы в формате NDJSON (один JSON заказ на строку).
+// Заказы читаются из БД курсором и отправляются клиенту по мере чтения;
+// отключение клиента отменяет контекст запроса и останавливает запрос к БД.
+func (h *Handler) ExportOrders(w http.ResponseWriter, r *http.Request) {
+	// Проекция полей применяется к каждой строке выгрузки
+	fields, unknown := parseFields(r)
+	if unknown != nil {
+		writeUnknownFields(w, r, unknown)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="orders.ndjson"`)
+
+	// HEAD: только заголовки, без чтения заказов из БД (длина потока заранее неизвестна)
+	if r.Method == http.MethodHead {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// NDJSON: одна строка на заказ, поэтому ?pretty здесь не применяется
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+	count := 0
+	err := h.service.StreamOrders(r.Context(), func(order *models.Order) error {
+		body, err := project(order, fields)
+		if err != nil {
+			return err
+		}
+		if err := enc.Encode(body); err != nil {
+			return err
+		}
+		count++
+		if count%exportFlushEvery == 0 {
+			return rc.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		// Заголовки уже могли быть отправлены, поэтому ошибку только логируем
+		if count == 0 && r.Context().Err() == nil {
+			writeJSONError(w, r, http.StatusInternalServerError, "Ошибка выгрузки заказов")
+		}
+		log.Printf("Выгрузка заказов прервана после %d заказов: %v", count, err)
+		return
+	}
+	if err := rc.Flush(); err != nil {
+		log.Printf("Ошибка сброса буфера выгрузки: %v", err)
+	}
+}
+
+// orderETag вычисляет слабый ETag заказа по времени его последнего изменения
+func orderETag(order *models.Order) string {
+	return fmt.Sprintf(`W/"%s-%x"`, order.OrderUID, order.UpdatedAt.UnixNano())
+}
+
+// notModified проверяет условные заголовки запроса.
+// If-None-Match имеет приоритет: при его наличии If-Modified-Since игнорируется (RFC 9110, 13.2.2).
+func notModified(r *http.Request, order *models.Order) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if order.UpdatedAt.IsZero() {
+		return false
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		etag := orderETag(order)
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			// Слабое сравнение: префикс W/ не учитывается
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" {
+		return false
+	}
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	// HTTP-даты имеют секундную точность
+	return !order.UpdatedAt.Truncate(time.Second).After(since)
 }
 
 // HealthCheck обрабатывает запрос проверки состояния сервиса
 func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+	writeJSON(w, r, http.StatusOK, map[string]interface{}{
 		"status":    "healthy",        // Статус сервиса
 		"timestamp": time.Now().UTC(), // Текущее время
-	}); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	})
+}
+
+// Readiness возвращает обработчик /readyz: 200, пока ready() истинно, иначе 503.
+// В отличие от /health сигнализирует балансировщику, что трафик на экземпляр больше не нужен.
+// checkDB (если задан) сообщает состояние БД в поле database; недоступная БД не снимает
+// готовность, так как заказы из кэша продолжают отдаваться.
+// checkReplica (если задан) так же сообщает состояние реплики для чтения в поле database_replica.
+func Readiness(ready func() bool, checkDB, checkReplica func(ctx context.Context) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status, code := "ready", http.StatusOK
+		if ready != nil && !ready() {
+			status, code = "shutting_down", http.StatusServiceUnavailable
+		}
+		body := map[string]interface{}{
+			"status":    status,
+			"timestamp": time.Now().UTC(),
+		}
+		if checkDB != nil {
+			ctx, cancel := context.WithTimeout(r.Context(), readinessCheckTimeout)
+			body["database"] = databaseStatus(checkDB(ctx))
+			cancel()
+		}
+		if checkReplica != nil {
+			ctx, cancel := context.WithTimeout(r.Context(), readinessCheckTimeout)
+			body["database_replica"] = databaseStatus(checkReplica(ctx))
+			cancel()
+		}
+		writeJSON(w, r, code, body)
+	}
+}
+
+// databaseStatus описывает результат проверки БД для /readyz
+func databaseStatus(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case database.IsUnavailable(err):
+		return "unavailable"
+	default:
+		return "error"
 	}
 }
 
 // Stats обрабатывает запрос для получения статистики сервиса
 func (h *Handler) Stats(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	stats := h.service.GetCacheStats() // Получаем статистику от сервиса
-	if err := json.NewEncoder(w).Encode(stats); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	} // Возвращаем статистику в формате JSON
+	stats := h.service.GetCacheStats()    // Получаем статистику от сервиса
+	writeJSON(w, r, http.StatusOK, stats) // Возвращаем статистику в формате JSON
 }
@@ -0,0 +1,337 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"test_service/internal/database"
+	"test_service/internal/interfaces"
+	"test_service/internal/mocks"
+	"test_service/internal/models"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testOrderUID идентификатор заказа в допустимом формате
+const testOrderUID = "testorderuid1234567890123456abcd"
+
+func TestHandler_GetOrderConditional(t *testing.T) {
+	updatedAt := time.Date(2024, 5, 1, 3, 0, 0, 500_000_000, time.UTC)
+	order := &models.Order{OrderUID: testOrderUID, Locale: "en", UpdatedAt: updatedAt}
+
+	newRequest := func(headers map[string]string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/"+testOrderUID, nil)
+		req.SetPathValue("uid", testOrderUID)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		return req
+	}
+
+	serve := func(t *testing.T, req *http.Request) *httptest.ResponseRecorder {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockService := mocks.NewMockOrderService(ctrl)
+		mockService.EXPECT().GetOrderWithSource(gomock.Any(), testOrderUID).Return(order, interfaces.SourceCache, nil)
+
+		rec := httptest.NewRecorder()
+		New(mockService).GetOrder(rec, req)
+		return rec
+	}
+
+	t.Run("SetsLastModified", func(t *testing.T) {
+		rec := serve(t, newRequest(nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "Wed, 01 May 2024 03:00:00 GMT", rec.Header().Get("Last-Modified"))
+		assert.NotEmpty(t, rec.Header().Get("ETag"))
+	})
+
+	t.Run("NotModifiedSinceSameSecond", func(t *testing.T) {
+		rec := serve(t, newRequest(map[string]string{"If-Modified-Since": "Wed, 01 May 2024 03:00:00 GMT"}))
+
+		assert.Equal(t, http.StatusNotModified, rec.Code)
+		assert.Empty(t, rec.Body.String())
+	})
+
+	t.Run("ModifiedAfterSince", func(t *testing.T) {
+		rec := serve(t, newRequest(map[string]string{"If-Modified-Since": "Wed, 01 May 2024 02:59:59 GMT"}))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), testOrderUID)
+	})
+
+	t.Run("InvalidIfModifiedSinceIgnored", func(t *testing.T) {
+		rec := serve(t, newRequest(map[string]string{"If-Modified-Since": "yesterday"}))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("IfNoneMatchTakesPrecedence", func(t *testing.T) {
+		// If-Modified-Since сам по себе дал бы 304, но несовпавший ETag требует полного ответа
+		rec := serve(t, newRequest(map[string]string{
+			"If-None-Match":     `W/"stale"`,
+			"If-Modified-Since": "Wed, 01 May 2024 04:00:00 GMT",
+		}))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("IfNoneMatchMatches", func(t *testing.T) {
+		rec := serve(t, newRequest(map[string]string{
+			"If-None-Match":     orderETag(order),
+			"If-Modified-Since": "Wed, 01 May 2024 02:00:00 GMT",
+		}))
+
+		assert.Equal(t, http.StatusNotModified, rec.Code)
+	})
+}
+
+func TestHandler_GetOrderInvalidUID(t *testing.T) {
+	for _, uid := range []string{
+		"short",
+		"../../etc/passwd",
+		"testorderuid1234567890123456abc-",  // недопустимый символ
+		"testorderuid1234567890123456abcde", // 33 символа
+	} {
+		t.Run(uid, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// Сервис не вызывается: мок без ожиданий упадет при любом обращении
+			mockService := mocks.NewMockOrderService(ctrl)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/x", nil)
+			req.SetPathValue("uid", uid)
+			rec := httptest.NewRecorder()
+			New(mockService).GetOrder(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			assert.Contains(t, rec.Body.String(), "32")
+		})
+	}
+}
+
+func TestHandler_GetOrderErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{"NotFound", fmt.Errorf("%w: no rows", models.ErrOrderNotFound), http.StatusNotFound},
+		{"DatabaseUnavailable", fmt.Errorf("%w: connection refused", database.ErrUnavailable), http.StatusServiceUnavailable},
+		{"Internal", errors.New("scan failed"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockService := mocks.NewMockOrderService(ctrl)
+			mockService.EXPECT().GetOrderWithSource(gomock.Any(), testOrderUID).Return(nil, interfaces.SourceDatabase, tt.err)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/"+testOrderUID, nil)
+			req.SetPathValue("uid", testOrderUID)
+			rec := httptest.NewRecorder()
+			New(mockService).GetOrder(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			if tt.wantStatus == http.StatusServiceUnavailable {
+				assert.Equal(t, "5", rec.Header().Get("Retry-After"))
+			} else {
+				assert.Empty(t, rec.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
+
+func TestHandler_GetOrderXCache(t *testing.T) {
+	for source, want := range map[interfaces.Source]string{
+		interfaces.SourceCache:    "HIT",
+		interfaces.SourceDatabase: "MISS",
+	} {
+		t.Run(want, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			order := &models.Order{OrderUID: testOrderUID, UpdatedAt: time.Now()}
+			mockService := mocks.NewMockOrderService(ctrl)
+			mockService.EXPECT().GetOrderWithSource(gomock.Any(), testOrderUID).Return(order, source, nil).Times(2)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/"+testOrderUID, nil)
+			req.SetPathValue("uid", testOrderUID)
+			rec := httptest.NewRecorder()
+			New(mockService).GetOrder(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, want, rec.Header().Get(CacheHeader))
+
+			// Ответ 304 тоже сообщает источник
+			req.Header.Set("If-None-Match", orderETag(order))
+			rec = httptest.NewRecorder()
+			New(mockService).GetOrder(rec, req)
+
+			assert.Equal(t, http.StatusNotModified, rec.Code)
+			assert.Equal(t, want, rec.Header().Get(CacheHeader))
+		})
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	tests := []struct {
+		name         string
+		ready        bool
+		checkDB      func(context.Context) error
+		checkReplica func(context.Context) error
+		wantStatus   int
+		wantDatabase interface{}
+		wantReplica  interface{}
+	}{
+		{"Ready", true, nil, nil, http.StatusOK, nil, nil},
+		{"DatabaseOK", true, func(context.Context) error { return nil }, nil, http.StatusOK, "ok", nil},
+		{"DatabaseDownStillReady", true, func(context.Context) error { return database.ErrUnavailable }, nil, http.StatusOK, "unavailable", nil},
+		{"ShuttingDown", false, func(context.Context) error { return nil }, nil, http.StatusServiceUnavailable, "ok", nil},
+		{"ReplicaOK", true, func(context.Context) error { return nil }, func(context.Context) error { return nil }, http.StatusOK, "ok", "ok"},
+		{"ReplicaDownStillReady", true, func(context.Context) error { return nil }, func(context.Context) error { return database.ErrUnavailable }, http.StatusOK, "ok", "unavailable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			Readiness(func() bool { return tt.ready }, tt.checkDB, tt.checkReplica).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			var body map[string]interface{}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, tt.wantDatabase, body["database"])
+			assert.Equal(t, tt.wantReplica, body["database_replica"])
+		})
+	}
+}
+
+func TestHandler_HeadOrder(t *testing.T) {
+	updatedAt := time.Date(2024, 5, 1, 3, 0, 0, 0, time.UTC)
+	order := &models.Order{OrderUID: testOrderUID, Locale: "en", UpdatedAt: updatedAt}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mocks.NewMockOrderService(ctrl)
+	mockService.EXPECT().GetOrderWithSource(gomock.Any(), testOrderUID).Return(order, interfaces.SourceCache, nil).Times(2)
+	mockService.EXPECT().GetOrderWithSource(gomock.Any(), "missingorder00000000000000000000").Return(nil, interfaces.SourceDatabase, models.ErrOrderNotFound)
+
+	server := httptest.NewServer(Routes(mockService, Options{AdminAPIKey: "secret"}))
+	defer server.Close()
+
+	do := func(t *testing.T, method, path string) (*http.Response, []byte) {
+		req, err := http.NewRequest(method, server.URL+path, nil)
+		require.NoError(t, err)
+		req.Header.Set(AdminKeyHeader, "secret")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, body
+	}
+
+	t.Run("ExistingOrder", func(t *testing.T) {
+		getResp, getBody := do(t, http.MethodGet, "/api/v1/orders/"+testOrderUID)
+		headResp, headBody := do(t, http.MethodHead, "/api/v1/orders/"+testOrderUID)
+
+		assert.Equal(t, http.StatusOK, headResp.StatusCode)
+		assert.Empty(t, headBody)
+		assert.Equal(t, "application/json", headResp.Header.Get("Content-Type"))
+		assert.Equal(t, getResp.Header.Get("ETag"), headResp.Header.Get("ETag"))
+		assert.Equal(t, int64(len(getBody)), headResp.ContentLength, "Content-Length совпадает с телом GET")
+	})
+
+	t.Run("MissingOrder", func(t *testing.T) {
+		resp, body := do(t, http.MethodHead, "/api/v1/orders/missingorder00000000000000000000")
+
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		assert.Empty(t, body)
+	})
+
+	t.Run("ExportDoesNotStream", func(t *testing.T) {
+		// StreamOrders не ожидается: мок упадет при вызове
+		resp, body := do(t, http.MethodHead, "/api/v1/orders/export")
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+		assert.Empty(t, body)
+	})
+}
+
+func TestHandler_SearchOrders(t *testing.T) {
+	newRoutes := func(t *testing.T) (http.Handler, *mocks.MockOrderService) {
+		ctrl := gomock.NewController(t)
+		t.Cleanup(ctrl.Finish)
+		mockService := mocks.NewMockOrderService(ctrl)
+		return Routes(mockService, Options{}), mockService
+	}
+
+	serve := func(h http.Handler, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	t.Run("Found", func(t *testing.T) {
+		routes, mockService := newRoutes(t)
+		orders := []*models.Order{
+			{OrderUID: testOrderUID, TrackNumber: "WBILTRACK", Locale: "en"},
+			{OrderUID: "secondorder000000000000000000000", TrackNumber: "WBILTRACK", Locale: "ru"},
+		}
+		mockService.EXPECT().GetOrdersByTrackNumber(gomock.Any(), "WBILTRACK").Return(orders, interfaces.SourceDatabase, nil)
+
+		rec := serve(routes, "/api/v1/orders/search?track_number=WBILTRACK&fields=order_uid")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "MISS", rec.Header().Get(CacheHeader))
+
+		var body []map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Equal(t, []map[string]interface{}{
+			{"order_uid": testOrderUID},
+			{"order_uid": "secondorder000000000000000000000"},
+		}, body)
+	})
+
+	t.Run("MissingTrackNumber", func(t *testing.T) {
+		// Сервис не вызывается
+		routes, _ := newRoutes(t)
+
+		rec := serve(routes, "/api/v1/orders/search")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		routes, mockService := newRoutes(t)
+		mockService.EXPECT().GetOrdersByTrackNumber(gomock.Any(), "UNKNOWN").Return(nil, interfaces.SourceDatabase, models.ErrOrderNotFound)
+
+		rec := serve(routes, "/api/v1/orders/search?track_number=UNKNOWN")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("DatabaseUnavailable", func(t *testing.T) {
+		routes, mockService := newRoutes(t)
+		mockService.EXPECT().GetOrdersByTrackNumber(gomock.Any(), "WBILTRACK").
+			Return(nil, interfaces.SourceDatabase, fmt.Errorf("поиск: %w", database.ErrUnavailable))
+
+		rec := serve(routes, "/api/v1/orders/search?track_number=WBILTRACK")
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, strconv.Itoa(retryAfterSeconds), rec.Header().Get("Retry-After"))
+	})
+}
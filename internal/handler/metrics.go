@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// HandlerMetrics содержит метрики HTTP обработчиков
+type HandlerMetrics struct {
+	InvalidOrderUIDTotal prometheus.Counter
+	WebSocketConnections prometheus.Gauge
+}
+
+// Global metrics для предотвращения дублирования метрик
+var globalHandlerMetrics *HandlerMetrics
+
+// NewHandlerMetrics создает и регистрирует метрики HTTP обработчиков
+func NewHandlerMetrics() *HandlerMetrics {
+	// Возвращаем глобальный экземпляр, чтобы избежать дублирования метрик
+	if globalHandlerMetrics != nil {
+		return globalHandlerMetrics
+	}
+
+	globalHandlerMetrics = &HandlerMetrics{
+		InvalidOrderUIDTotal: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "http_invalid_order_uid_total",
+			Help: "Количество запросов заказа, отклоненных из-за неверного формата идентификатора",
+		}),
+		WebSocketConnections: promauto.NewGauge(prometheus.GaugeOpts{
+			Name: "websocket_connections",
+			Help: "Количество открытых WebSocket соединений живых обновлений",
+		}),
+	}
+
+	return globalHandlerMetrics
+}
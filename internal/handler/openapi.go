@@ -0,0 +1,360 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"test_service/internal/database"
+	"test_service/internal/models"
+)
+
+// OpenAPIPath путь документа OpenAPI
+const OpenAPIPath = APIPrefix + "/openapi.json"
+
+// openAPIVersion версия описываемого API
+const openAPIVersion = "1.0.0"
+
+var (
+	openAPIOnce sync.Once
+	openAPIJSON []byte
+)
+
+// OpenAPI отдает OpenAPI 3 описание API. Схемы моделей строятся рефлексией
+// по структурам models, поэтому переименование поля сразу отражается в документе.
+func OpenAPI(w http.ResponseWriter, r *http.Request) {
+	openAPIOnce.Do(func() {
+		var err error
+		if openAPIJSON, err = json.Marshal(openAPIDocument()); err != nil {
+			log.Printf("Ошибка построения OpenAPI документа: %v", err)
+		}
+	})
+	if openAPIJSON == nil {
+		writeJSONError(w, r, http.StatusInternalServerError, "OpenAPI документ недоступен")
+		return
+	}
+	data := openAPIJSON
+	if prettyRequested(r) {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, openAPIJSON, "", "  "); err == nil {
+			data = buf.Bytes()
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(data); err != nil {
+		log.Printf("Ошибка записи OpenAPI документа: %v", err)
+	}
+}
+
+// object сокращение для фрагментов документа
+type object = map[string]interface{}
+
+// openAPIDocument собирает документ: пути описаны вручную рядом с маршрутами, схемы — из структур
+func openAPIDocument() object {
+	schemas := object{
+		"Error": object{
+			"type":     "object",
+			"required": []string{"error"},
+			"properties": object{
+				"error":        object{"type": "string"},
+				"valid_fields": object{"type": "array", "items": object{"type": "string"}},
+			},
+		},
+		"Stats": object{
+			"type":                 "object",
+			"additionalProperties": true,
+		},
+		"Status": object{
+			"type": "object",
+			"properties": object{
+				"status":    object{"type": "string"},
+				"database":  object{"type": "string", "enum": []string{"ok", "unavailable", "error"}},
+				"timestamp": object{"type": "string", "format": "date-time"},
+			},
+		},
+		"DLQReplaySummary": object{
+			"type": "object",
+			"properties": object{
+				"replayed":  object{"type": "integer"},
+				"failed":    object{"type": "integer"},
+				"skipped":   object{"type": "integer"},
+				"dry_run":   object{"type": "boolean"},
+				"pending":   object{"type": "integer"},
+				"reasons":   object{"type": "object", "additionalProperties": object{"type": "integer"}},
+				"timed_out": object{"type": "boolean"},
+				"error":     object{"type": "string"},
+			},
+		},
+	}
+	orderSchema := schemaFor(reflect.TypeOf(models.Order{}), schemas)
+
+	uidParam := object{
+		"name": "uid", "in": "path", "required": true,
+		"schema": object{"type": "string", "pattern": models.OrderUIDPattern()},
+	}
+	fieldsParam := object{
+		"name": "fields", "in": "query",
+		"description": "Поля ответа через запятую, например order_uid,delivery.city",
+		"schema":      object{"type": "string"},
+	}
+	adminSecurity := []object{{"adminKey": []string{}}, {"bearer": []string{}}}
+
+	orderResponse := jsonResponse("Заказ (XML при Accept: application/xml)", orderSchema)
+	orderResponse["content"].(object)[mediaXML] = object{"schema": orderSchema}
+	orderResponse["headers"] = object{
+		CacheHeader: object{"description": "HIT — заказ из кэша, MISS — из БД", "schema": object{"type": "string", "enum": []string{"HIT", "MISS"}}},
+	}
+	getOrder := operation("Получить заказ", orderResponse,
+		"400", errorResponse("Неверный идентификатор или параметр fields"),
+		"404", errorResponse("Заказ не найден"),
+		"406", errorResponse("Параметр fields запрошен для XML"),
+		"503", unavailableResponse())
+	getOrder["parameters"] = []object{uidParam, fieldsParam}
+
+	headOrder := operation("Заголовки ответа GET без тела", object{"description": "Заказ существует"},
+		"400", object{"description": "Неверный идентификатор или параметр fields"},
+		"404", object{"description": "Заказ не найден"},
+		"503", object{"description": "БД временно недоступна", "headers": unavailableResponse()["headers"]})
+	headOrder["parameters"] = []object{uidParam, fieldsParam}
+
+	searchOrders := operation("Найти заказы по трек-номеру", jsonResponse("Заказы от новых к старым", object{"type": "array", "items": orderSchema}),
+		"400", errorResponse("Не указан track_number или неверный параметр fields"),
+		"404", errorResponse("Заказы не найдены"),
+		"503", unavailableResponse())
+	searchOrders["parameters"] = []object{{
+		"name": "track_number", "in": "query", "required": true,
+		"schema": object{"type": "string"},
+	}, fieldsParam}
+
+	deleteOrder := operation("Удалить заказ из БД и кэша", object{"description": "Заказ удален"},
+		"400", errorResponse("Неверный параметр soft"),
+		"401", errorResponse("Требуется ключ администратора"),
+		"404", errorResponse("Заказ не найден"))
+	deleteOrder["parameters"] = []object{uidParam, {
+		"name": "soft", "in": "query", "description": "Скрыть заказ, сохранив данные в БД (мягкое удаление)",
+		"schema": object{"type": "boolean", "default": false},
+	}}
+	deleteOrder["security"] = adminSecurity
+
+	exportOrders := operation("Выгрузить все заказы (NDJSON, один заказ на строку)", object{
+		"description": "Поток заказов",
+		"content":     object{"application/x-ndjson": object{"schema": orderSchema}},
+	}, "401", errorResponse("Требуется ключ администратора"))
+	exportOrders["parameters"] = []object{fieldsParam}
+	exportOrders["security"] = adminSecurity
+
+	findByContact := operation("Найти заказы по email или телефону покупателя", jsonResponse("Заказы от новых к старым (пустой массив — не найдены)", object{"type": "array", "items": orderSchema}),
+		"400", errorResponse("Не указан email или phone, неверный limit или параметр fields"),
+		"401", errorResponse("Требуется ключ администратора"),
+		"503", unavailableResponse())
+	findByContact["parameters"] = []object{{
+		"name": "email", "in": "query", "description": "Email без учета регистра",
+		"schema": object{"type": "string"},
+	}, {
+		"name": "phone", "in": "query", "description": "Телефон; пробелы, скобки, дефисы и точки не учитываются",
+		"schema": object{"type": "string"},
+	}, {
+		"name": "limit", "in": "query",
+		"schema": object{"type": "integer", "minimum": 1, "maximum": database.MaxPageSize, "default": database.DefaultPageSize},
+	}, fieldsParam}
+	findByContact["security"] = adminSecurity
+
+	updateStatus := operation("Сменить статус заказа", jsonResponse("Заказ с новым статусом", orderSchema),
+		"400", errorResponse("Неверный идентификатор, тело запроса или статус"),
+		"401", errorResponse("Требуется ключ администратора"),
+		"404", errorResponse("Заказ не найден"),
+		"409", errorResponse("Недопустимый переход статуса"),
+		"503", unavailableResponse())
+	updateStatus["parameters"] = []object{uidParam}
+	updateStatus["requestBody"] = object{
+		"required": true,
+		"content": object{"application/json": object{"schema": object{
+			"type":       "object",
+			"required":   []string{"status"},
+			"properties": object{"status": schemaFor(reflect.TypeOf(models.OrderStatus("")), schemas)},
+		}}},
+	}
+	updateStatus["security"] = adminSecurity
+
+	replayDLQ := operation("Повторно обработать сообщения из DLQ", jsonResponse("Итоги", ref("DLQReplaySummary")),
+		"400", errorResponse("Неверный параметр max, until или dry_run"),
+		"401", errorResponse("Требуется ключ администратора"),
+		"409", errorResponse("Повторная обработка уже выполняется"),
+		"502", jsonResponse("Ошибка Kafka, частичные итоги", ref("DLQReplaySummary")))
+	replayDLQ["parameters"] = []object{{
+		"name": "max", "in": "query",
+		"schema": object{"type": "integer", "minimum": 1, "maximum": dlqReplayMaxLimit, "default": dlqReplayDefaultMax},
+	}, {
+		"name": "until", "in": "query",
+		"schema": object{"type": "string", "format": "date-time"},
+	}, {
+		"name": "dry_run", "in": "query",
+		"schema": object{"type": "boolean", "default": false},
+	}}
+	replayDLQ["security"] = adminSecurity
+
+	health := operation("Проверка здоровья", jsonResponse("Сервис работает", ref("Status")))
+	stats := operation("Статистика сервиса", jsonResponse("Статистика", ref("Stats")))
+	readyz := operation("Готовность принимать трафик", jsonResponse("Готов", ref("Status")),
+		"503", jsonResponse("Экземпляр останавливается", ref("Status")))
+	openapi := operation("Этот документ", object{"description": "OpenAPI 3 документ"})
+
+	return object{
+		"openapi": "3.0.3",
+		"info": object{
+			"title":   "Order Service API",
+			"version": openAPIVersion,
+		},
+		"paths": object{
+			APIPrefix + "/orders/{uid}":        object{"get": getOrder, "head": headOrder, "delete": deleteOrder},
+			APIPrefix + "/orders/{uid}/status": object{"patch": updateStatus},
+			APIPrefix + "/orders/export":       object{"get": exportOrders},
+			APIPrefix + "/orders/by-contact":   object{"get": findByContact},
+			APIPrefix + "/orders/search":       object{"get": searchOrders},
+			APIPrefix + "/health":              object{"get": health},
+			APIPrefix + "/stats":               object{"get": stats},
+			OpenAPIPath:                        object{"get": openapi},
+			"/readyz":                          object{"get": readyz},
+			"/admin/dlq/replay":                object{"post": replayDLQ},
+			"/order/{uid}":                     object{"get": deprecatedOperation(getOrder)},
+			"/health":                          object{"get": deprecatedOperation(health)},
+			"/stats":                           object{"get": deprecatedOperation(stats)},
+		},
+		"components": object{
+			"schemas": schemas,
+			"securitySchemes": object{
+				"adminKey": object{"type": "apiKey", "in": "header", "name": AdminKeyHeader},
+				"bearer":   object{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+}
+
+// operation описывает операцию с ответом 200 и дополнительными ответами (код, ответ, ...)
+func operation(summary string, ok object, extra ...interface{}) object {
+	responses := object{"200": ok}
+	for i := 0; i+1 < len(extra); i += 2 {
+		responses[extra[i].(string)] = extra[i+1]
+	}
+	return object{"summary": summary, "responses": responses}
+}
+
+// deprecatedOperation копия операции с пометкой deprecated
+func deprecatedOperation(op object) object {
+	cp := make(object, len(op)+1)
+	for k, v := range op {
+		cp[k] = v
+	}
+	cp["deprecated"] = true
+	return cp
+}
+
+// jsonResponse ответ application/json со схемой
+func jsonResponse(description string, schema object) object {
+	return object{
+		"description": description,
+		"content":     object{"application/json": object{"schema": schema}},
+	}
+}
+
+// errorResponse ответ с конвертом ошибки {"error": "..."}
+func errorResponse(description string) object {
+	return jsonResponse(description, ref("Error"))
+}
+
+// unavailableResponse ответ 503 при недоступной БД с заголовком Retry-After
+func unavailableResponse() object {
+	resp := errorResponse("БД временно недоступна, заказа нет в кэше")
+	resp["headers"] = object{
+		"Retry-After": object{"description": "Через сколько секунд повторить запрос", "schema": object{"type": "integer"}},
+	}
+	return resp
+}
+
+// ref ссылка на схему из components
+func ref(name string) object {
+	return object{"$ref": "#/components/schemas/" + name}
+}
+
+// schemaFor строит схему типа; структуры регистрируются в schemas и возвращаются ссылкой
+func schemaFor(t reflect.Type, schemas object) object {
+	if t == reflect.TypeOf(time.Time{}) {
+		return object{"type": "string", "format": "date-time"}
+	}
+	if t == reflect.TypeOf(models.OrderStatus("")) {
+		return object{"type": "string", "enum": models.OrderStatuses()}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return object{"type": "string"}
+	case reflect.Bool:
+		return object{"type": "boolean"}
+	case reflect.Int, reflect.Int32:
+		return object{"type": "integer", "format": "int32"}
+	case reflect.Int64:
+		return object{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return object{"type": "number"}
+	case reflect.Slice:
+		return object{"type": "array", "items": schemaFor(t.Elem(), schemas)}
+	case reflect.Struct:
+		if _, ok := schemas[t.Name()]; !ok {
+			schemas[t.Name()] = nil // Защита от рекурсии
+			schemas[t.Name()] = structSchema(t, schemas)
+		}
+		return ref(t.Name())
+	default:
+		return object{}
+	}
+}
+
+// structSchema строит схему объекта по json и validate тегам полей
+func structSchema(t reflect.Type, schemas object) object {
+	properties := object{}
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := jsonName(f)
+		if name == "" {
+			continue
+		}
+		schema := schemaFor(f.Type, schemas)
+		for _, rule := range strings.Split(f.Tag.Get("validate"), ",") {
+			key, value, _ := strings.Cut(rule, "=")
+			switch key {
+			case "required":
+				required = append(required, name)
+			case "order_uid":
+				schema["pattern"] = models.OrderUIDPattern()
+			case "email":
+				schema["format"] = "email"
+			case "min":
+				if n, err := strconv.Atoi(value); err == nil {
+					if f.Type.Kind() == reflect.Slice {
+						schema["minItems"] = n
+					} else {
+						schema["minimum"] = n
+					}
+				}
+			case "gt":
+				if n, err := strconv.Atoi(value); err == nil {
+					schema["minimum"] = n + 1
+				}
+			}
+		}
+		properties[name] = schema
+	}
+
+	s := object{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
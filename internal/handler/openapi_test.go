@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"test_service/internal/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPI(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	rec := httptest.NewRecorder()
+	Routes(mocks.NewMockOrderService(ctrl), Options{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, OpenAPIPath, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &doc))
+
+	t.Run("Structure", func(t *testing.T) {
+		assert.True(t, strings.HasPrefix(doc["openapi"].(string), "3."))
+		info := doc["info"].(map[string]interface{})
+		assert.NotEmpty(t, info["title"])
+		assert.NotEmpty(t, info["version"])
+
+		methods := map[string]bool{"get": true, "post": true, "put": true, "delete": true, "head": true, "patch": true}
+		paths := doc["paths"].(map[string]interface{})
+		require.NotEmpty(t, paths)
+		for path, item := range paths {
+			assert.True(t, strings.HasPrefix(path, "/"), path)
+			for method, op := range item.(map[string]interface{}) {
+				assert.True(t, methods[method], "%s: неизвестный метод %s", path, method)
+				responses := op.(map[string]interface{})["responses"].(map[string]interface{})
+				assert.NotEmpty(t, responses, "%s %s без ответов", method, path)
+				for _, resp := range responses {
+					assert.NotEmpty(t, resp.(map[string]interface{})["description"], "%s %s: ответ без description", method, path)
+				}
+			}
+		}
+	})
+
+	t.Run("RefsResolve", func(t *testing.T) {
+		schemas := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+		var walk func(v interface{})
+		walk = func(v interface{}) {
+			switch v := v.(type) {
+			case map[string]interface{}:
+				if r, ok := v["$ref"].(string); ok {
+					name := strings.TrimPrefix(r, "#/components/schemas/")
+					assert.Contains(t, schemas, name, "неразрешимая ссылка %s", r)
+				}
+				for _, child := range v {
+					walk(child)
+				}
+			case []interface{}:
+				for _, child := range v {
+					walk(child)
+				}
+			}
+		}
+		walk(doc)
+	})
+
+	t.Run("OrderSchemaMatchesStructs", func(t *testing.T) {
+		schemas := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+		for _, name := range []string{"Order", "Delivery", "Payment", "Item", "Error"} {
+			assert.Contains(t, schemas, name)
+		}
+
+		order := schemas["Order"].(map[string]interface{})["properties"].(map[string]interface{})
+		assert.Len(t, order, len(orderFields), "все json поля models.Order описаны")
+		for field := range orderFields {
+			assert.Contains(t, order, field)
+		}
+		assert.Equal(t, "integer", order["sm_id"].(map[string]interface{})["type"])
+		assert.Equal(t, "date-time", order["date_created"].(map[string]interface{})["format"])
+		assert.NotEmpty(t, order["order_uid"].(map[string]interface{})["pattern"])
+
+		delivery := schemas["Delivery"].(map[string]interface{})["properties"].(map[string]interface{})
+		assert.NotContains(t, delivery, "OrderUID", "поля с json:\"-\" не попадают в схему")
+		assert.Equal(t, "email", delivery["email"].(map[string]interface{})["format"])
+	})
+}
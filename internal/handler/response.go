@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Форматы ответа, между которыми выбирает negotiate
+const (
+	mediaJSON    = "application/json"
+	mediaXML     = "application/xml"
+	mediaTextXML = "text/xml"
+)
+
+// negotiate выбирает формат ответа по заголовку Accept с учетом q.
+// XML отдается, только если клиент предпочитает его JSON; при равенстве,
+// без заголовка и для неизвестных типов остается JSON.
+func negotiate(r *http.Request) string {
+	jsonQ, wildcardQ := -1.0, 0.0
+	xmlQ, xmlType := 0.0, mediaXML
+	for _, part := range strings.Split(strings.Join(r.Header.Values("Accept"), ","), ",") {
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		switch mediaType {
+		case mediaJSON:
+			jsonQ = max(jsonQ, q)
+		case mediaXML, mediaTextXML:
+			if q > xmlQ {
+				xmlQ, xmlType = q, mediaType
+			}
+		case "*/*", "application/*":
+			wildcardQ = max(wildcardQ, q)
+		}
+	}
+	// Явно указанный application/json важнее масок
+	if jsonQ < 0 {
+		jsonQ = wildcardQ
+	}
+	if xmlQ > jsonQ {
+		return xmlType
+	}
+	return mediaJSON
+}
+
+// contentType значение заголовка Content-Type для выбранного формата
+func contentType(mediaType string) string {
+	if mediaType == mediaJSON {
+		return mediaJSON
+	}
+	return mediaType + "; charset=utf-8"
+}
+
+// prettyRequested проверяет параметр ?pretty=1 (или true); неверное значение игнорируется
+func prettyRequested(r *http.Request) bool {
+	pretty, err := strconv.ParseBool(r.URL.Query().Get("pretty"))
+	return err == nil && pretty
+}
+
+// marshalJSON кодирует ответ с завершающим переводом строки.
+// По умолчанию компактно; с ?pretty — с отступом в два пробела для чтения в терминале.
+func marshalJSON(r *http.Request, v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	if prettyRequested(r) {
+		enc.SetIndent("", "  ")
+	}
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// marshalXML кодирует ответ в XML с прологом; ?pretty включает отступы, как для JSON
+func marshalXML(r *http.Request, v interface{}) ([]byte, error) {
+	buf := bytes.NewBufferString(xml.Header)
+	enc := xml.NewEncoder(buf)
+	if prettyRequested(r) {
+		enc.Indent("", "  ")
+	}
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// writeJSON возвращает v в формате JSON с заданным кодом ответа
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	data, err := marshalJSON(r, v)
+	if err != nil {
+		log.Printf("Ошибка кодирования JSON ответа: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(data); err != nil {
+		log.Printf("Ошибка записи ответа: %v", err)
+	}
+}
+
+// writeJSONError возвращает ошибку в формате JSON с заданным кодом ответа
+func writeJSONError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	writeJSON(w, r, status, map[string]string{"error": message})
+}
@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"test_service/internal/interfaces"
+	"test_service/internal/mocks"
+	"test_service/internal/models"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrettyRequested(t *testing.T) {
+	for query, want := range map[string]bool{
+		"":             false,
+		"?pretty=1":    true,
+		"?pretty=true": true,
+		"?pretty=0":    false,
+		"?pretty=yes":  false, // Неверное значение игнорируется, а не приводит к ошибке
+	} {
+		assert.Equal(t, want, prettyRequested(httptest.NewRequest(http.MethodGet, "/"+query, nil)), query)
+	}
+}
+
+func TestPrettyResponses(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	order := &models.Order{OrderUID: testOrderUID, Locale: "en"}
+	mockService := mocks.NewMockOrderService(ctrl)
+	mockService.EXPECT().GetOrderWithSource(gomock.Any(), testOrderUID).Return(order, interfaces.SourceCache, nil).AnyTimes()
+	mockService.EXPECT().GetCacheStats().Return(map[string]interface{}{"cache_size": 1}).AnyTimes()
+	mockService.EXPECT().StreamOrders(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, fn func(*models.Order) error) error {
+			return fn(order)
+		}).AnyTimes()
+	routes := Routes(mockService, Options{AdminAPIKey: "secret"})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(AdminKeyHeader, "secret")
+		rec := httptest.NewRecorder()
+		routes.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, path := range []string{
+		"/api/v1/orders/" + testOrderUID,
+		"/api/v1/orders/" + testOrderUID + "?fields=order_uid,delivery.city",
+		"/api/v1/stats",
+		"/api/v1/health",
+		"/api/v1/orders/short", // Ошибка валидации
+		"/api/v1/unknown",      // JSON 404
+		OpenAPIPath,
+	} {
+		t.Run(path, func(t *testing.T) {
+			sep := "?"
+			if strings.Contains(path, "?") {
+				sep = "&"
+			}
+			compact := get(path)
+			pretty := get(path + sep + "pretty=1")
+
+			require.Equal(t, compact.Code, pretty.Code)
+			assert.Equal(t, "application/json", pretty.Header().Get("Content-Type"))
+			assert.NotContains(t, strings.TrimSpace(compact.Body.String()), "\n", "по умолчанию ответ компактный")
+			assert.Contains(t, pretty.Body.String(), "\n  \"", "с ?pretty ответ с отступами")
+
+			// Содержимое совпадает, меняется только форматирование
+			var a, b interface{}
+			require.NoError(t, json.Unmarshal(compact.Body.Bytes(), &a))
+			require.NoError(t, json.Unmarshal(pretty.Body.Bytes(), &b))
+			if path != "/api/v1/health" {
+				assert.Equal(t, a, b)
+			}
+		})
+	}
+
+	t.Run("ContentLengthMatchesPrettyBody", func(t *testing.T) {
+		rec := get("/api/v1/orders/" + testOrderUID + "?pretty=true")
+		assert.Equal(t, strconv.Itoa(rec.Body.Len()), rec.Header().Get("Content-Length"))
+	})
+
+	t.Run("NDJSONIgnoresPretty", func(t *testing.T) {
+		compact := get("/api/v1/orders/export")
+		pretty := get("/api/v1/orders/export?pretty=1")
+
+		assert.Equal(t, http.StatusOK, pretty.Code)
+		assert.Equal(t, compact.Body.String(), pretty.Body.String())
+		assert.Equal(t, 1, strings.Count(pretty.Body.String(), "\n"), "один заказ — одна строка")
+	})
+}
+
+func TestNegotiate(t *testing.T) {
+	for accept, want := range map[string]string{
+		"":                                  mediaJSON,
+		"*/*":                               mediaJSON,
+		"application/json":                  mediaJSON,
+		"text/html":                         mediaJSON, // Неизвестный тип — JSON
+		"application/xml":                   mediaXML,
+		"text/xml":                          mediaTextXML,
+		"application/json, application/xml": mediaJSON, // Равенство — JSON
+		"application/json;q=0.5, application/xml":          mediaXML,
+		"application/xml;q=0.9, */*;q=0.8":                 mediaXML,
+		"application/xml;q=0.5, */*":                       mediaJSON,
+		"application/json;q=0, application/xml;q=0.1, */*": mediaXML,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		assert.Equal(t, want, negotiate(req), accept)
+	}
+}
+
+func TestHandler_GetOrderXML(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	order := &models.Order{
+		OrderUID:  testOrderUID,
+		Delivery:  models.Delivery{City: "Moscow"},
+		Items:     []models.Item{{ChrtID: 1, Name: "first"}, {ChrtID: 2, Name: "second"}},
+		UpdatedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	}
+	mockService := mocks.NewMockOrderService(ctrl)
+	mockService.EXPECT().GetOrderWithSource(gomock.Any(), testOrderUID).Return(order, interfaces.SourceCache, nil).AnyTimes()
+	routes := Routes(mockService, Options{})
+
+	get := func(accept, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/"+testOrderUID+query, nil)
+		req.Header.Set("Accept", accept)
+		rec := httptest.NewRecorder()
+		routes.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("XML", func(t *testing.T) {
+		rec := get("application/xml", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/xml; charset=utf-8", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "Accept", rec.Header().Get("Vary"))
+		assert.Equal(t, strconv.Itoa(rec.Body.Len()), rec.Header().Get("Content-Length"))
+		assert.True(t, strings.HasPrefix(rec.Body.String(), xml.Header))
+
+		var decoded models.Order
+		require.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &decoded))
+		assert.Equal(t, testOrderUID, decoded.OrderUID)
+		assert.Equal(t, "Moscow", decoded.Delivery.City)
+		require.Len(t, decoded.Items, 2)
+		assert.Equal(t, "second", decoded.Items[1].Name)
+	})
+
+	t.Run("UnknownAcceptIsJSON", func(t *testing.T) {
+		rec := get("text/csv", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		assert.True(t, json.Valid(rec.Body.Bytes()))
+	})
+
+	t.Run("FieldsNotAcceptableForXML", func(t *testing.T) {
+		rec := get("application/xml", "?fields=order_uid")
+		assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+	})
+
+	t.Run("NotModifiedKeepsVary", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/"+testOrderUID, nil)
+		req.Header.Set("Accept", "application/xml")
+		req.Header.Set("If-None-Match", orderETag(order))
+		rec := httptest.NewRecorder()
+		routes.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNotModified, rec.Code)
+		assert.Equal(t, "Accept", rec.Header().Get("Vary"))
+	})
+}
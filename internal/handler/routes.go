@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"test_service/internal/events"
+)
+
+// APIPrefix префикс версионированного JSON API
+const APIPrefix = "/api/v1"
+
+// Options настройки маршрутизации
+type Options struct {
+	AdminAPIKey      string                          // Ключ административных маршрутов (пустой — маршруты отключены)
+	DLQReplayer      DLQReplayer                     // Повторная обработка DLQ (nil — маршрут не регистрируется)
+	DLQReplayTimeout time.Duration                   // Ограничение времени одного запуска повторной обработки DLQ
+	Ready            func() bool                     // Готовность принимать трафик для /readyz (nil — всегда готов)
+	CheckDatabase    func(ctx context.Context) error // Проверка БД для /readyz (nil — не проверяется)
+	CheckReplica     func(ctx context.Context) error // Проверка реплики для чтения для /readyz (nil — реплика не настроена)
+	Events           *events.Hub                     // События заказов для WebSocket (nil — маршрут не регистрируется)
+	Fallback         http.Handler                    // Обработчик путей вне API (статика, метрики); nil — JSON 404
+}
+
+// Routes регистрирует маршруты API и возвращает корневой обработчик.
+// Все JSON эндпоинты живут под /api/v1/; старые пути (/order/, /stats, /health)
+// сохранены как устаревшие псевдонимы на один релиз. Остальные запросы передаются
+// в opts.Fallback.
+func Routes(svc OrderService, opts Options) http.Handler {
+	h := New(svc)
+	fallback := opts.Fallback
+	if fallback == nil {
+		fallback = http.HandlerFunc(NotFound)
+	}
+
+	mux := http.NewServeMux()
+
+	// Версионированное API
+	mux.HandleFunc("GET "+APIPrefix+"/orders/{uid}", h.GetOrder)                                // Получение заказа
+	mux.HandleFunc("GET "+APIPrefix+"/orders/search", h.SearchOrders)                           // Поиск заказов по трек-номеру
+	mux.HandleFunc("GET "+APIPrefix+"/health", h.HealthCheck)                                   // Проверка состояния сервиса
+	mux.HandleFunc("GET "+APIPrefix+"/stats", h.Stats)                                          // Статистика сервиса
+	mux.HandleFunc("/api/", NotFound)                                                           // Неизвестные пути API не уходят в SPA
+	mux.HandleFunc("GET /readyz", Readiness(opts.Ready, opts.CheckDatabase, opts.CheckReplica)) // Готовность к трафику (503 во время остановки)
+	mux.HandleFunc("GET "+OpenAPIPath, OpenAPI)                                                 // OpenAPI описание
+	if opts.Events != nil {
+		mux.Handle("GET "+APIPrefix+"/ws", &wsHandler{hub: opts.Events, metrics: h.metrics}) // Живые обновления заказов
+	}
+
+	// Административные маршруты
+	mux.HandleFunc("GET "+APIPrefix+"/orders/export", AdminAuth(opts.AdminAPIKey, WithoutWriteTimeout(h.ExportOrders))) // Выгрузка NDJSON
+	mux.HandleFunc("DELETE "+APIPrefix+"/orders/{uid}", AdminAuth(opts.AdminAPIKey, h.DeleteOrder))                     // Удаление заказа
+	mux.HandleFunc("GET "+APIPrefix+"/orders/by-contact", AdminAuth(opts.AdminAPIKey, h.FindOrdersByContact))           // Поиск по email/телефону
+	mux.HandleFunc("PATCH "+APIPrefix+"/orders/{uid}/status", AdminAuth(opts.AdminAPIKey, h.UpdateOrderStatus))         // Смена статуса
+	if opts.DLQReplayer != nil {
+		replay := &dlqReplayHandler{service: svc, replayer: opts.DLQReplayer, timeout: opts.DLQReplayTimeout}
+		mux.HandleFunc("POST /admin/dlq/replay", AdminAuth(opts.AdminAPIKey, WithoutWriteTimeout(replay.ServeHTTP))) // Повторная обработка DLQ
+	}
+
+	// Устаревшие пути, сохранены на один релиз
+	mux.HandleFunc("GET /order/{uid}", deprecated(h.GetOrder, func(r *http.Request) string {
+		return APIPrefix + "/orders/" + r.PathValue("uid")
+	}))
+	mux.HandleFunc("GET /health", deprecated(h.HealthCheck, successor(APIPrefix+"/health")))
+	mux.HandleFunc("GET /stats", deprecated(h.Stats, successor(APIPrefix+"/stats")))
+
+	// Статика, метрики и прочее
+	mux.Handle("/", fallback)
+
+	return mux
+}
+
+// deprecated помечает ответ устаревшего маршрута заголовками Deprecation и Link на новый путь
+func deprecated(next http.HandlerFunc, successorPath func(*http.Request) string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+successorPath(r)+">; rel=\"successor-version\"")
+		next(w, r)
+	}
+}
+
+// successor возвращает функцию с фиксированным новым путем
+func successor(path string) func(*http.Request) string {
+	return func(*http.Request) string { return path }
+}
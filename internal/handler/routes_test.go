@@ -0,0 +1,262 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"test_service/internal/interfaces"
+	"test_service/internal/mocks"
+	"test_service/internal/models"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoutes(t *testing.T) {
+	order := &models.Order{OrderUID: testOrderUID, Locale: "en"}
+	const (
+		missingUID = "missingorder00000000000000000000" // Заказа нет
+		hardUID    = "hardorder00000000000000000000000" // Удаляется полностью
+	)
+
+	newRoutes := func(t *testing.T) (http.Handler, *mocks.MockOrderService) {
+		ctrl := gomock.NewController(t)
+		t.Cleanup(ctrl.Finish)
+		mockService := mocks.NewMockOrderService(ctrl)
+		fallback := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("fallback"))
+		})
+		return Routes(mockService, Options{AdminAPIKey: "secret", Fallback: fallback}), mockService
+	}
+
+	serve := func(h http.Handler, method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec
+	}
+
+	t.Run("VersionedOrder", func(t *testing.T) {
+		routes, mockService := newRoutes(t)
+		mockService.EXPECT().GetOrderWithSource(gomock.Any(), testOrderUID).Return(order, interfaces.SourceCache, nil)
+
+		rec := serve(routes, http.MethodGet, "/api/v1/orders/"+testOrderUID)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), testOrderUID)
+		assert.Empty(t, rec.Header().Get("Deprecation"))
+	})
+
+	t.Run("LegacyOrderAlias", func(t *testing.T) {
+		routes, mockService := newRoutes(t)
+		mockService.EXPECT().GetOrderWithSource(gomock.Any(), testOrderUID).Return(order, interfaces.SourceCache, nil)
+
+		rec := serve(routes, http.MethodGet, "/order/"+testOrderUID)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "true", rec.Header().Get("Deprecation"))
+		assert.Contains(t, rec.Header().Get("Link"), "/api/v1/orders/"+testOrderUID)
+	})
+
+	t.Run("StatsAndHealth", func(t *testing.T) {
+		routes, mockService := newRoutes(t)
+		mockService.EXPECT().GetCacheStats().Return(map[string]interface{}{"cache_size": 1}).Times(2)
+
+		assert.Equal(t, http.StatusOK, serve(routes, http.MethodGet, "/api/v1/stats").Code)
+		assert.Equal(t, http.StatusOK, serve(routes, http.MethodGet, "/stats").Code)
+		assert.Equal(t, http.StatusOK, serve(routes, http.MethodGet, "/api/v1/health").Code)
+		assert.Equal(t, "true", serve(routes, http.MethodGet, "/health").Header().Get("Deprecation"))
+	})
+
+	t.Run("Readiness", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		ready := true
+		routes := Routes(mocks.NewMockOrderService(ctrl), Options{Ready: func() bool { return ready }})
+
+		assert.Equal(t, http.StatusOK, serve(routes, http.MethodGet, "/readyz").Code)
+
+		ready = false
+		rec := serve(routes, http.MethodGet, "/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Contains(t, rec.Body.String(), "shutting_down")
+	})
+
+	t.Run("UnknownAPIPathIsJSON404", func(t *testing.T) {
+		routes, _ := newRoutes(t)
+
+		rec := serve(routes, http.MethodGet, "/api/v1/unknown")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	})
+
+	t.Run("ExportRequiresAdminKey", func(t *testing.T) {
+		routes, _ := newRoutes(t)
+
+		rec := serve(routes, http.MethodGet, "/api/v1/orders/export")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("ExportStreamsNDJSON", func(t *testing.T) {
+		routes, mockService := newRoutes(t)
+		mockService.EXPECT().StreamOrders(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ context.Context, fn func(*models.Order) error) error {
+				for _, uid := range []string{"order-1", "order-2"} {
+					if err := fn(&models.Order{OrderUID: uid}); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/export", nil)
+		req.Header.Set(AdminKeyHeader, "secret")
+		rec := httptest.NewRecorder()
+		routes.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+		lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+		require.Len(t, lines, 2)
+		assert.Contains(t, lines[0], `"order_uid":"order-1"`)
+		assert.Contains(t, lines[1], `"order_uid":"order-2"`)
+	})
+
+	t.Run("ExportStopsOnCancelledRequest", func(t *testing.T) {
+		routes, mockService := newRoutes(t)
+		mockService.EXPECT().StreamOrders(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, fn func(*models.Order) error) error {
+				// Запрос к БД отменяется вместе с контекстом запроса
+				return ctx.Err()
+			})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/export", nil).WithContext(ctx)
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		routes.ServeHTTP(rec, req)
+
+		assert.Empty(t, rec.Body.String())
+	})
+
+	t.Run("DeleteOrder", func(t *testing.T) {
+		routes, mockService := newRoutes(t)
+		mockService.EXPECT().DeleteOrder(gomock.Any(), testOrderUID).Return(nil)
+		mockService.EXPECT().DeleteOrder(gomock.Any(), missingUID).Return(models.ErrOrderNotFound)
+
+		del := func(uid, key string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodDelete, "/api/v1/orders/"+uid, nil)
+			if key != "" {
+				req.Header.Set(AdminKeyHeader, key)
+			}
+			rec := httptest.NewRecorder()
+			routes.ServeHTTP(rec, req)
+			return rec
+		}
+
+		assert.Equal(t, http.StatusUnauthorized, del(testOrderUID, "").Code)
+		assert.Equal(t, http.StatusNoContent, del(testOrderUID, "secret").Code)
+		assert.Equal(t, http.StatusNotFound, del(missingUID, "secret").Code)
+
+		// Неверный UID не доходит до сервиса: ответ в JSON, как у GetOrder
+		rec := del("not-an-order", "secret")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		assert.Contains(t, rec.Body.String(), `"error"`)
+	})
+
+	t.Run("SoftDeleteOrder", func(t *testing.T) {
+		routes, mockService := newRoutes(t)
+		mockService.EXPECT().SoftDeleteOrder(gomock.Any(), testOrderUID).Return(nil)
+		mockService.EXPECT().SoftDeleteOrder(gomock.Any(), missingUID).Return(models.ErrOrderNotFound)
+		mockService.EXPECT().DeleteOrder(gomock.Any(), hardUID).Return(nil)
+
+		del := func(path string) int {
+			req := httptest.NewRequest(http.MethodDelete, "/api/v1/orders/"+path, nil)
+			req.Header.Set(AdminKeyHeader, "secret")
+			rec := httptest.NewRecorder()
+			routes.ServeHTTP(rec, req)
+			return rec.Code
+		}
+
+		assert.Equal(t, http.StatusNoContent, del(testOrderUID+"?soft=true"))
+		assert.Equal(t, http.StatusNotFound, del(missingUID+"?soft=1"))
+		assert.Equal(t, http.StatusNoContent, del(hardUID+"?soft=false"), "soft=false — полное удаление")
+		assert.Equal(t, http.StatusBadRequest, del(testOrderUID+"?soft=maybe"))
+	})
+
+	t.Run("FindOrdersByContact", func(t *testing.T) {
+		routes, mockService := newRoutes(t)
+		found := []models.Order{{OrderUID: "order-2"}, {OrderUID: "order-1"}}
+		// Значения передаются как есть: нормализация — в БД
+		mockService.EXPECT().FindOrdersByContact(gomock.Any(), "Test@Gmail.com", "", 100).Return(found, nil)
+		mockService.EXPECT().FindOrdersByContact(gomock.Any(), "", "+972 000-0000", 5).Return(nil, nil)
+
+		find := func(query, key string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/by-contact?"+query, nil)
+			if key != "" {
+				req.Header.Set(AdminKeyHeader, key)
+			}
+			rec := httptest.NewRecorder()
+			routes.ServeHTTP(rec, req)
+			return rec
+		}
+
+		assert.Equal(t, http.StatusUnauthorized, find("email=a@b.c", "").Code)
+
+		rec := find("email=Test%40Gmail.com&fields=order_uid", "secret")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `[{"order_uid":"order-2"},{"order_uid":"order-1"}]`, rec.Body.String())
+
+		rec = find("phone=%2B972+000-0000&limit=5", "secret")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `[]`, rec.Body.String(), "ничего не найдено — пустой массив")
+
+		assert.Equal(t, http.StatusBadRequest, find("", "secret").Code)
+		assert.Equal(t, http.StatusBadRequest, find("email=+&phone=()-", "secret").Code, "пусто после нормализации")
+		assert.Equal(t, http.StatusBadRequest, find("email=a@b.c&limit=0", "secret").Code)
+		assert.Equal(t, http.StatusBadRequest, find("email=a@b.c&limit=1001", "secret").Code)
+	})
+
+	t.Run("UpdateOrderStatus", func(t *testing.T) {
+		routes, mockService := newRoutes(t)
+		missingUID := strings.Repeat("0", models.OrderUIDLength)
+		mockService.EXPECT().UpdateOrderStatus(gomock.Any(), testOrderUID, models.StatusPacked).
+			Return(&models.Order{OrderUID: testOrderUID, Status: models.StatusPacked}, nil)
+		mockService.EXPECT().UpdateOrderStatus(gomock.Any(), testOrderUID, models.StatusAccepted).
+			Return(nil, &models.StatusTransitionError{OrderUID: testOrderUID, From: models.StatusShipped, To: models.StatusAccepted})
+		mockService.EXPECT().UpdateOrderStatus(gomock.Any(), missingUID, models.StatusPacked).Return(nil, models.ErrOrderNotFound)
+
+		patch := func(uid, body, key string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPatch, "/api/v1/orders/"+uid+"/status", strings.NewReader(body))
+			if key != "" {
+				req.Header.Set(AdminKeyHeader, key)
+			}
+			rec := httptest.NewRecorder()
+			routes.ServeHTTP(rec, req)
+			return rec
+		}
+
+		assert.Equal(t, http.StatusUnauthorized, patch(testOrderUID, `{"status":"packed"}`, "").Code)
+
+		rec := patch(testOrderUID, `{"status":"packed"}`, "secret")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"status":"packed"`)
+
+		assert.Equal(t, http.StatusConflict, patch(testOrderUID, `{"status":"accepted"}`, "secret").Code)
+		assert.Equal(t, http.StatusNotFound, patch(missingUID, `{"status":"packed"}`, "secret").Code)
+		assert.Equal(t, http.StatusBadRequest, patch("bad-uid", `{"status":"packed"}`, "secret").Code)
+		assert.Equal(t, http.StatusBadRequest, patch(testOrderUID, `{"status":"returned"}`, "secret").Code)
+		assert.Equal(t, http.StatusBadRequest, patch(testOrderUID, `status=packed`, "secret").Code)
+	})
+
+	t.Run("OtherPathsGoToFallback", func(t *testing.T) {
+		routes, _ := newRoutes(t)
+
+		rec := serve(routes, http.MethodGet, "/index.html")
+		assert.Equal(t, "fallback", rec.Body.String())
+	})
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// apiPrefixes пути API, к которым SPA-фоллбэк на index.html никогда не применяется
+var apiPrefixes = []string{"/api", "/order", "/orders", "/stats", "/health", "/readyz", "/admin"}
+
+// isAPIPath проверяет, относится ли путь к API
+func isAPIPath(p string) bool {
+	for _, prefix := range apiPrefixes {
+		if p == prefix || strings.HasPrefix(p, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateStaticDir проверяет, что каталог статики существует, доступен для чтения и содержит index.html
+func ValidateStaticDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("каталог статики %s недоступен: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("путь статики %s не является каталогом", dir)
+	}
+	if err := ValidateStatic(os.DirFS(dir)); err != nil {
+		return fmt.Errorf("каталог статики %s: %w", dir, err)
+	}
+	return nil
+}
+
+// ValidateStatic проверяет, что файловая система статики читается и содержит index.html
+func ValidateStatic(fsys fs.FS) error {
+	if _, err := fs.ReadDir(fsys, "."); err != nil {
+		return fmt.Errorf("статика недоступна для чтения: %w", err)
+	}
+	index, err := fsys.Open("index.html")
+	if err != nil {
+		return fmt.Errorf("нет читаемого index.html: %w", err)
+	}
+	_ = index.Close()
+	return nil
+}
+
+// SPA обслуживает файлы статики с фоллбэком на index.html.
+// Путь запроса очищается и разрешается только внутри корня fsys, поэтому
+// попытки выхода за его пределы (/..%2f..%2fetc/passwd) получают index.html.
+// Для путей API фоллбэк не применяется — возвращается JSON 404.
+func SPA(fsys fs.FS) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isAPIPath(r.URL.Path) {
+			NotFound(w, r)
+			return
+		}
+		// Очищенный путь без ведущего слеша; корень и каталоги отдают index.html
+		name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+		if name != "" && serveStaticFile(w, r, fsys, name) {
+			return
+		}
+		if !serveStaticFile(w, r, fsys, "index.html") {
+			NotFound(w, r)
+		}
+	})
+}
+
+// serveStaticFile отдает обычный файл из fsys; false, если файла нет или это каталог
+func serveStaticFile(w http.ResponseWriter, r *http.Request, fsys fs.FS, name string) bool {
+	if !fs.ValidPath(name) {
+		return false
+	}
+	f, err := fsys.Open(name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		return false
+	}
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		return false
+	}
+	// ServeContent определяет Content-Type по расширению и поддерживает Range и If-Modified-Since
+	http.ServeContent(w, r, name, info.ModTime(), content)
+	return true
+}
+
+// NotFound возвращает JSON 404 (используется, когда SPA отключена или путь не найден)
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	writeJSONError(w, r, http.StatusNotFound, "Ресурс не найден")
+}
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newStaticDir создает временный каталог статики с index.html и app.js
+func newStaticDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "index.html"), []byte("<html>index</html>"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app.js"), []byte("console.log('app')"), 0o644))
+	return dir
+}
+
+func TestValidateStaticDir(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		assert.NoError(t, ValidateStaticDir(newStaticDir(t)))
+	})
+
+	t.Run("MissingDir", func(t *testing.T) {
+		err := ValidateStaticDir(filepath.Join(t.TempDir(), "missing"))
+		assert.Error(t, err)
+	})
+
+	t.Run("NotADir", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "file.txt")
+		require.NoError(t, os.WriteFile(file, []byte("x"), 0o644))
+		assert.Error(t, ValidateStaticDir(file))
+	})
+
+	t.Run("MissingIndex", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "app.js"), []byte("x"), 0o644))
+		err := ValidateStaticDir(dir)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "index.html")
+	})
+}
+
+func TestSPA(t *testing.T) {
+	// Файл рядом с каталогом статики не должен быть доступен через SPA
+	dir := newStaticDir(t)
+	require.NoError(t, os.WriteFile(filepath.Join(filepath.Dir(dir), "secret.txt"), []byte("top secret"), 0o644))
+	spa := SPA(os.DirFS(dir))
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		spa.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	t.Run("RootServesIndex", func(t *testing.T) {
+		rec := serve("/")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "index")
+	})
+
+	t.Run("ExistingFile", func(t *testing.T) {
+		rec := serve("/app.js")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "console.log")
+	})
+
+	t.Run("UnknownPathFallsBackToIndex", func(t *testing.T) {
+		rec := serve("/some/client/route")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "index")
+	})
+
+	t.Run("APIPathsAreExcluded", func(t *testing.T) {
+		for _, path := range []string{"/order", "/orders", "/orders/export", "/stats/extra", "/health/live", "/admin/dlq"} {
+			rec := serve(path)
+			assert.Equal(t, http.StatusNotFound, rec.Code, "путь %s не должен получать index.html", path)
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		}
+	})
+
+	t.Run("SimilarPrefixIsNotAPI", func(t *testing.T) {
+		rec := serve("/ordering")
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("TraversalStaysInsideRoot", func(t *testing.T) {
+		for _, path := range []string{
+			"/..%2f..%2fetc/passwd",
+			"/..%2fsecret.txt",
+			"/../secret.txt",
+			"/static/..%2f..%2fsecret.txt",
+			"/%2e%2e/secret.txt",
+		} {
+			rec := serve(path)
+			assert.Equal(t, http.StatusOK, rec.Code, path)
+			assert.Contains(t, rec.Body.String(), "index", "путь %s получает index.html", path)
+			assert.NotContains(t, rec.Body.String(), "top secret", path)
+			assert.NotContains(t, rec.Body.String(), "root:", path)
+		}
+	})
+
+	t.Run("DirectoryFallsBackToIndex", func(t *testing.T) {
+		require.NoError(t, os.Mkdir(filepath.Join(dir, "assets"), 0o755))
+		rec := serve("/assets/")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "index")
+	})
+
+	t.Run("ContentType", func(t *testing.T) {
+		assert.Contains(t, serve("/app.js").Header().Get("Content-Type"), "javascript")
+		assert.Contains(t, serve("/").Header().Get("Content-Type"), "text/html")
+	})
+}
+
+func TestSPA_MissingIndex(t *testing.T) {
+	spa := SPA(fstest.MapFS{"app.js": {Data: []byte("x")}})
+
+	rec := httptest.NewRecorder()
+	spa.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/client/route", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+}
+
+func TestValidateStatic(t *testing.T) {
+	assert.NoError(t, ValidateStatic(fstest.MapFS{"index.html": {Data: []byte("<html>")}}))
+
+	err := ValidateStatic(fstest.MapFS{"app.js": {Data: []byte("x")}})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "index.html")
+}
+
+func TestNotFound(t *testing.T) {
+	// Режим STATIC_OPTIONAL: все не-API пути получают JSON 404
+	rec := httptest.NewRecorder()
+	NotFound(rec, httptest.NewRequest(http.MethodGet, "/index.html", nil))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "error")
+}
@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"test_service/internal/events"
+
+	"github.com/gorilla/websocket"
+)
+
+// Параметры WebSocket соединения
+const (
+	wsWriteTimeout     = 10 * time.Second       // Ограничение времени одной записи
+	wsPongTimeout      = 60 * time.Second       // Соединение закрывается, если клиент молчит дольше
+	wsPingPeriod       = wsPongTimeout * 9 / 10 // Ping отправляется раньше, чем истечет ожидание pong
+	wsMaxMessageSize   = 4096                   // Ограничение размера сообщения клиента
+	wsMaxSubscriptions = 100                    // Ограничение числа customer_id на соединение
+)
+
+// wsUpgrader переводит запрос в WebSocket; проверка Origin по умолчанию пропускает только свой хост
+var wsUpgrader = websocket.Upgrader{ReadBufferSize: 1024, WriteBufferSize: 1024}
+
+// wsRequest сообщение клиента: {"subscribe": {"customer_id": "..."}} или {"unsubscribe": {...}}
+type wsRequest struct {
+	Subscribe   *wsCustomer `json:"subscribe"`
+	Unsubscribe *wsCustomer `json:"unsubscribe"`
+}
+
+// wsCustomer фильтр подписки
+type wsCustomer struct {
+	CustomerID string `json:"customer_id"`
+}
+
+// wsReply ответ на сообщение клиента
+type wsReply struct {
+	Type       string `json:"type"` // subscribed, unsubscribed или error
+	CustomerID string `json:"customer_id,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// wsHandler отдает события обработанных заказов по WebSocket с фильтром по customer_id
+type wsHandler struct {
+	hub     *events.Hub
+	metrics *HandlerMetrics
+}
+
+// ServeHTTP обслуживает одно WebSocket соединение. Запись выполняется только в этой горутине,
+// чтение — в отдельной; остановка шины событий закрывает соединение с кодом 1001.
+func (h *wsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrader уже ответил клиенту ошибкой
+		log.Printf("Ошибка WebSocket рукопожатия: %v", err)
+		return
+	}
+	h.metrics.WebSocketConnections.Inc()
+	defer h.metrics.WebSocketConnections.Dec()
+
+	sub := h.hub.Subscribe()
+	defer sub.Close()
+
+	messages := make(chan []byte)
+	done := make(chan struct{})
+	readDone := make(chan struct{})
+	go wsRead(conn, messages, done, readDone)
+	defer func() {
+		close(done)
+		_ = conn.Close()
+		<-readDone
+	}()
+
+	customers := make(map[string]struct{})
+	ping := time.NewTicker(wsPingPeriod)
+	defer ping.Stop()
+
+	for {
+		select {
+		case e, ok := <-sub.Events():
+			if !ok {
+				// Сервис останавливается: клиент переподключится к другому экземпляру
+				_ = conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"),
+					time.Now().Add(wsWriteTimeout))
+				return
+			}
+			if e.Order == nil {
+				continue
+			}
+			if _, ok := customers[e.Order.CustomerID]; !ok {
+				continue
+			}
+			if err := wsWrite(conn, e); err != nil {
+				return
+			}
+		case data := <-messages:
+			if err := wsWrite(conn, handleWSMessage(data, customers)); err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				return
+			}
+		case <-readDone:
+			// Клиент закрыл соединение или не ответил на ping
+			return
+		}
+	}
+}
+
+// wsRead читает сообщения клиента до ошибки чтения; pong продлевает дедлайн чтения
+func wsRead(conn *websocket.Conn, messages chan<- []byte, done <-chan struct{}, readDone chan<- struct{}) {
+	defer close(readDone)
+
+	conn.SetReadLimit(wsMaxMessageSize)
+	_ = conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		select {
+		case messages <- data:
+		case <-done:
+			return
+		}
+	}
+}
+
+// wsWrite отправляет JSON сообщение с дедлайном записи
+func wsWrite(conn *websocket.Conn, v interface{}) error {
+	if err := conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout)); err != nil {
+		return err
+	}
+	return conn.WriteJSON(v)
+}
+
+// handleWSMessage применяет сообщение клиента к набору подписок и возвращает ответ
+func handleWSMessage(data []byte, customers map[string]struct{}) wsReply {
+	var req wsRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return wsReply{Type: "error", Error: "Неверный формат сообщения"}
+	}
+
+	switch {
+	case req.Subscribe != nil:
+		id := req.Subscribe.CustomerID
+		if id == "" {
+			return wsReply{Type: "error", Error: "Требуется customer_id"}
+		}
+		if _, ok := customers[id]; !ok && len(customers) >= wsMaxSubscriptions {
+			return wsReply{Type: "error", CustomerID: id, Error: "Превышено число подписок на соединение"}
+		}
+		customers[id] = struct{}{}
+		return wsReply{Type: "subscribed", CustomerID: id}
+	case req.Unsubscribe != nil:
+		delete(customers, req.Unsubscribe.CustomerID)
+		return wsReply{Type: "unsubscribed", CustomerID: req.Unsubscribe.CustomerID}
+	default:
+		return wsReply{Type: "error", Error: "Ожидается subscribe или unsubscribe"}
+	}
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"test_service/internal/events"
+	"test_service/internal/mocks"
+	"test_service/internal/models"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newWSServer поднимает маршруты с шиной событий за access log (проверяет Hijack через обертку)
+func newWSServer(t *testing.T) (*httptest.Server, *events.Hub) {
+	ctrl := gomock.NewController(t)
+	hub := events.NewHub()
+	server := httptest.NewServer(AccessLog(Routes(mocks.NewMockOrderService(ctrl), Options{Events: hub})))
+	t.Cleanup(func() {
+		hub.Close()
+		server.Close()
+	})
+	return server, hub
+}
+
+// dialWS открывает соединение с /api/v1/ws
+func dialWS(t *testing.T, server *httptest.Server) *websocket.Conn {
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/v1/ws"
+	conn, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	t.Cleanup(func() { conn.Close() })
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	return conn
+}
+
+func TestWebSocket_Subscribe(t *testing.T) {
+	server, hub := newWSServer(t)
+	conn := dialWS(t, server)
+
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{"subscribe": map[string]string{"customer_id": "cust-1"}}))
+	var reply wsReply
+	require.NoError(t, conn.ReadJSON(&reply))
+	assert.Equal(t, wsReply{Type: "subscribed", CustomerID: "cust-1"}, reply)
+
+	// Событие другого покупателя отфильтровано, следующее приходит
+	hub.Publish(events.Event{Type: events.OrderProcessed, Order: &models.Order{OrderUID: "order-2", CustomerID: "cust-2"}})
+	hub.Publish(events.Event{Type: events.OrderProcessed, Order: &models.Order{OrderUID: "order-1", CustomerID: "cust-1"}})
+
+	var event events.Event
+	require.NoError(t, conn.ReadJSON(&event))
+	assert.Equal(t, events.OrderProcessed, event.Type)
+	assert.Equal(t, "order-1", event.Order.OrderUID)
+
+	// После отписки события не приходят: следующим сообщением будет ответ на неверный запрос
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{"unsubscribe": map[string]string{"customer_id": "cust-1"}}))
+	require.NoError(t, conn.ReadJSON(&reply))
+	assert.Equal(t, "unsubscribed", reply.Type)
+
+	hub.Publish(events.Event{Type: events.OrderProcessed, Order: &models.Order{OrderUID: "order-3", CustomerID: "cust-1"}})
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("not json")))
+	require.NoError(t, conn.ReadJSON(&reply))
+	assert.Equal(t, "error", reply.Type)
+}
+
+func TestWebSocket_ShutdownClosesConnections(t *testing.T) {
+	server, hub := newWSServer(t)
+	conn := dialWS(t, server)
+
+	// Соединение установлено и подписано на шину
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{"subscribe": map[string]string{"customer_id": "cust-1"}}))
+	var reply wsReply
+	require.NoError(t, conn.ReadJSON(&reply))
+
+	hub.Close()
+
+	_, _, err := conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "ожидался код 1001, получено %v", err)
+
+	// Обработчик завершился: закрытие сервера не зависает
+	closed := make(chan struct{})
+	go func() {
+		server.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("server.Close завис на WebSocket соединении")
+	}
+}
+
+func TestHandleWSMessage(t *testing.T) {
+	customers := map[string]struct{}{}
+
+	assert.Equal(t, "error", handleWSMessage([]byte(`{}`), customers).Type)
+	assert.Equal(t, "error", handleWSMessage([]byte(`{"subscribe":{}}`), customers).Type)
+
+	for i := 0; i < wsMaxSubscriptions; i++ {
+		reply := handleWSMessage([]byte(`{"subscribe":{"customer_id":"c`+strings.Repeat("x", i)+`"}}`), customers)
+		require.Equal(t, "subscribed", reply.Type)
+	}
+	assert.Equal(t, "error", handleWSMessage([]byte(`{"subscribe":{"customer_id":"extra"}}`), customers).Type)
+	assert.Len(t, customers, wsMaxSubscriptions)
+}
+
+func TestWebSocket_NotRegisteredWithoutEvents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	rec := httptest.NewRecorder()
+	Routes(mocks.NewMockOrderService(ctrl), Options{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/ws", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -3,63 +3,300 @@ package interfaces
 
 import (
 	"context"
+	"io"
+	"time"
 
 	"test_service/internal/models"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Source источник, из которого сервис получил заказ
+type Source string
+
+// Источники заказа
+const (
+	SourceCache    Source = "cache"    // Заказ найден в кэше
+	SourceDatabase Source = "database" // Промах кэша, заказ прочитан из БД
 )
 
+// ReadOptions параметры выборки заказов из БД
+type ReadOptions struct {
+	IncludeDeleted bool // Включать мягко удаленные заказы (SoftDeleteOrder)
+	Primary        bool // Читать с основного сервера, минуя реплику
+}
+
+// ReadOption настраивает выборку заказов из БД; без опций мягко удаленные заказы не возвращаются
+type ReadOption func(*ReadOptions)
+
+// IncludeDeleted включает в выборку мягко удаленные заказы; для административных запросов
+func IncludeDeleted() ReadOption {
+	return func(o *ReadOptions) { o.IncludeDeleted = true }
+}
+
+// FromPrimary направляет чтение на основной сервер, даже если реплика исправна;
+// для чтения, которое должно видеть только что записанные данные
+func FromPrimary() ReadOption {
+	return func(o *ReadOptions) { o.Primary = true }
+}
+
+// ApplyReadOptions собирает параметры выборки из опций
+func ApplyReadOptions(opts []ReadOption) ReadOptions {
+	var o ReadOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
 // Database интерфейс для работы с базой данных
 type Database interface {
 	// Init инициализирует базу данных (создает таблицы и т.д.)
 	Init(ctx context.Context) error
-	
+
 	// SaveOrder сохраняет заказ в базу данных
 	SaveOrder(ctx context.Context, order *models.Order) error
-	
-	// GetOrder получает заказ по его UID из базы данных
-	GetOrder(ctx context.Context, orderUID string) (*models.Order, error)
-	
-	// GetAllOrders получает все заказы из базы данных
-	GetAllOrders(ctx context.Context) ([]models.Order, error)
-	
+
+	// SaveOrders сохраняет заказы одной транзакцией: либо все, либо ни одного
+	SaveOrders(ctx context.Context, orders []*models.Order) error
+
+	// SaveOrdersPartial сохраняет заказы одной транзакцией, откатывая только заказы с ошибкой;
+	// возвращает ошибки по заказам (nil — сохранен) и ошибку всей транзакции
+	SaveOrdersPartial(ctx context.Context, orders []*models.Order) ([]error, error)
+
+	// GetOrder получает заказ по его UID из базы данных.
+	// Здесь и в выборках ниже мягко удаленные заказы пропускаются, если не передан IncludeDeleted.
+	GetOrder(ctx context.Context, orderUID string, opts ...ReadOption) (*models.Order, error)
+
+	// GetAllOrders получает все заказы из базы данных, целиком загружая их в память.
+	// Сохранен для совместимости; для больших таблиц предпочтителен StreamAllOrders.
+	GetAllOrders(ctx context.Context, opts ...ReadOption) ([]models.Order, error)
+
+	// StreamAllOrders последовательно передает все заказы с товарами в fn пакетами
+	// с повторными попытками; в памяти находится только текущий пакет. Предпочтительный
+	// способ перебрать все заказы (прогрев кэша).
+	StreamAllOrders(ctx context.Context, fn func(*models.Order) error, opts ...ReadOption) error
+
+	// GetOrdersSince возвращает заказы, созданные начиная с since (включительно), от старых к новым
+	GetOrdersSince(ctx context.Context, since time.Time, opts ...ReadOption) ([]models.Order, error)
+
+	// GetOrdersPage возвращает страницу заказов от новых к старым и курсор следующей страницы
+	// (пустой — страница последняя); пустой cursor — первая страница
+	GetOrdersPage(ctx context.Context, cursor string, limit int, opts ...ReadOption) ([]models.Order, string, error)
+
+	// GetOrdersByCustomerID возвращает заказы покупателя от новых к старым, пропуская первые offset
+	GetOrdersByCustomerID(ctx context.Context, customerID string, limit, offset int, opts ...ReadOption) ([]models.Order, error)
+
+	// FindOrdersByContact возвращает заказы, в доставке которых указан email или телефон, от новых к старым
+	FindOrdersByContact(ctx context.Context, email, phone string, limit int, opts ...ReadOption) ([]models.Order, error)
+
+	// GetOrderByTrackNumber возвращает все заказы с трек-номером; models.ErrOrderNotFound, если их нет
+	GetOrderByTrackNumber(ctx context.Context, trackNumber string, opts ...ReadOption) ([]models.Order, error)
+
+	// StreamOrders последовательно передает все заказы в fn, не загружая их в память целиком
+	StreamOrders(ctx context.Context, fn func(*models.Order) error, opts ...ReadOption) error
+
+	// ListOrderUIDs последовательно передает в fn UID всех заказов, от старых к новым
+	ListOrderUIDs(ctx context.Context, fn func(uid string) error, opts ...ReadOption) error
+
+	// CountOrders возвращает количество сохраненных заказов
+	CountOrders(ctx context.Context) (int64, error)
+
+	// CountOrdersSince возвращает количество заказов, созданных начиная с since
+	CountOrdersSince(ctx context.Context, since time.Time) (int64, error)
+
+	// OrderExists проверяет, сохранен ли заказ, не читая его данные
+	OrderExists(ctx context.Context, orderUID string) (bool, error)
+
+	// DeleteOrder удаляет заказ и связанные записи; models.ErrOrderNotFound, если заказа нет
+	DeleteOrder(ctx context.Context, orderUID string) error
+
+	// SoftDeleteOrder отмечает заказ удаленным, не удаляя данные; models.ErrOrderNotFound,
+	// если заказа нет или он уже удален
+	SoftDeleteOrder(ctx context.Context, orderUID string) error
+
+	// UpdateOrderStatus устанавливает статус заказа без проверки перехода;
+	// models.ErrOrderNotFound, если заказа нет или он удален
+	UpdateOrderStatus(ctx context.Context, orderUID string, status models.OrderStatus) error
+
+	// ArchiveOrdersBefore переносит заказы, созданные раньше cutoff, в архивные таблицы пакетами
+	// по batchSize; возвращает число перенесенных заказов
+	ArchiveOrdersBefore(ctx context.Context, cutoff time.Time, batchSize int) (int64, error)
+
+	// Ping проверяет соединение с БД; ошибки соединения оборачиваются в database.ErrUnavailable
+	Ping(ctx context.Context) error
+
 	// Close закрывает соединение с базой данных
 	Close()
 }
 
+// PoolStats снимок статистики пула соединений с БД
+type PoolStats struct {
+	AcquiredConns     int32 `json:"acquired_conns"`      // Соединения, занятые запросами
+	IdleConns         int32 `json:"idle_conns"`          // Открытые простаивающие соединения
+	TotalConns        int32 `json:"total_conns"`         // Все открытые соединения
+	MaxConns          int32 `json:"max_conns"`           // Предел пула
+	AcquireCount      int64 `json:"acquire_count"`       // Получений соединения с запуска
+	EmptyAcquireCount int64 `json:"empty_acquire_count"` // Получений, ждавших освобождения или открытия соединения
+}
+
+// PoolStatsProvider необязательное расширение Database: статистика пула соединений.
+// Сервис проверяет его приведением типа, поэтому реализации без пула (моки) его не реализуют.
+type PoolStatsProvider interface {
+	Stats() PoolStats
+}
+
 // Cache интерфейс для работы с кэшем
 type Cache interface {
 	// Set добавляет или обновляет заказ в кэше
 	Set(order *models.Order)
-	
+
+	// SetWithTTL добавляет или обновляет заказ с собственным временем жизни (<= 0 — не истекает)
+	SetWithTTL(order *models.Order, ttl time.Duration)
+
+	// KeepAlive заново отсчитывает время жизни заказа и сообщает, был ли он в кэше
+	KeepAlive(orderUID string) bool
+
 	// Get получает заказ из кэша по его UID
 	Get(orderUID string) (*models.Order, bool)
-	
+
+	// Lookup как Get, но в режиме stale-while-revalidate отдает недавно истекший заказ с stale = true
+	Lookup(orderUID string) (order *models.Order, stale, ok bool)
+
+	// Revalidate заменяет истекший заказ свежей версией; свежие и удаленные записи не трогает
+	Revalidate(order *models.Order) bool
+
+	// GetMulti получает несколько заказов за один проход; missing — UID, которых нет в кэше
+	GetMulti(uids []string) (found map[string]*models.Order, missing []string)
+
+	// GetOrSet получает заказ из кэша, а при отсутствии загружает его через loader (один раз на UID)
+	GetOrSet(orderUID string, loader func() (*models.Order, error)) (*models.Order, bool, error)
+
+	// GetByTrackNumber получает неистекшие заказы с заданным трек-номером
+	GetByTrackNumber(trackNumber string) []*models.Order
+
+	// Delete удаляет заказ из кэша по его UID; возвращает true, если заказ был в кэше
+	Delete(orderUID string) bool
+
+	// DeleteCreatedBefore удаляет заказы, созданные раньше cutoff, и возвращает число удаленных
+	DeleteCreatedBefore(cutoff time.Time) int
+
 	// GetAll возвращает все заказы из кэша
 	GetAll() []*models.Order
-	
+
+	// NewestDateCreated возвращает наибольшее время создания среди заказов кэша
+	NewestDateCreated() time.Time
+
 	// LoadFromSlice загружает заказы из слайса в кэш
 	LoadFromSlice(orders []models.Order)
-	
+
+	// ReplaceAll атомарно заменяет содержимое кэша заказами из слайса
+	ReplaceAll(orders []models.Order)
+
+	// ReplaceStream атомарно заменяет содержимое кэша заказами, которые fill передает в add;
+	// при ошибке fill кэш не меняется
+	ReplaceStream(fill func(add func(order *models.Order)) error) error
+
 	// Size возвращает количество заказов в кэше
 	Size() int
-	
+
+	// Evicted возвращает количество заказов, вытесненных из-за ограничения размера
+	Evicted() uint64
+
+	// MemoryUsage возвращает оценку занятой заказами памяти и бюджет в байтах (0 — без ограничения)
+	MemoryUsage() (bytes, budget int64)
+
 	// Cleanup удаляет истекшие элементы из кэша
 	Cleanup()
+
+	// WriteSnapshot сохраняет неистекшие заказы с оставшимся временем жизни
+	WriteSnapshot(w io.Writer) error
+
+	// ReadSnapshot загружает заказы из снимка, отбрасывая истекшие; снимок старше maxAge не загружается
+	ReadSnapshot(r io.Reader, maxAge time.Duration) (int, error)
+
+	// StopJanitor останавливает фоновую очистку кэша, если она запущена
+	StopJanitor()
+
+	// Clear атомарно удаляет все заказы из кэша и возвращает их количество
+	Clear() int
 }
 
 // OrderService интерфейс для сервиса работы с заказами
 type OrderService interface {
 	// WarmUpCache загружает все заказы из БД в кэш
 	WarmUpCache(ctx context.Context) error
-	
+
+	// WarmUpCacheSince догружает заказы, созданные начиная с since; нулевое since — полный прогрев
+	WarmUpCacheSince(ctx context.Context, since time.Time) error
+
 	// ProcessOrder обрабатывает новый заказ: сохраняет в БД и добавляет в кэш
-	ProcessOrder(order *models.Order) error
-	
-	// GetOrder получает заказ по его UID с использованием кэша и БД
-	GetOrder(orderUID string) (*models.Order, error)
-	
+	ProcessOrder(ctx context.Context, order *models.Order) error
+
+	// ProcessOrders обрабатывает пакет заказов: сохраняет их одной транзакцией (все или ни одного)
+	// и добавляет в кэш
+	ProcessOrders(ctx context.Context, orders []*models.Order) error
+
+	// GetOrder получает заказ по его UID с использованием кэша и БД; отмена ctx прерывает запрос к БД
+	GetOrder(ctx context.Context, orderUID string) (*models.Order, error)
+
+	// GetOrderWithSource как GetOrder, но дополнительно сообщает, откуда получен заказ
+	GetOrderWithSource(ctx context.Context, orderUID string) (*models.Order, Source, error)
+
+	// GetOrders получает несколько заказов: из кэша одним проходом, недостающие — из БД.
+	// Ненайденные UID в результат не попадают.
+	GetOrders(ctx context.Context, orderUIDs []string) (map[string]*models.Order, error)
+
+	// GetOrdersByTrackNumber ищет заказы по трек-номеру: сначала в кэше, затем в БД
+	GetOrdersByTrackNumber(ctx context.Context, trackNumber string) ([]*models.Order, Source, error)
+
+	// FindOrdersByContact ищет в БД заказы по email или телефону покупателя (регистр email
+	// и оформление телефона не учитываются)
+	FindOrdersByContact(ctx context.Context, email, phone string, limit int) ([]models.Order, error)
+
+	// DeleteOrder удаляет заказ из БД и кэша
+	DeleteOrder(ctx context.Context, orderUID string) error
+
+	// SoftDeleteOrder скрывает заказ из API, сохраняя данные в БД, и удаляет его из кэша
+	SoftDeleteOrder(ctx context.Context, orderUID string) error
+
+	// UpdateOrderStatus меняет статус заказа с проверкой перехода и обновляет его в кэше;
+	// *models.StatusTransitionError, если переход недопустим
+	UpdateOrderStatus(ctx context.Context, orderUID string, status models.OrderStatus) (*models.Order, error)
+
+	// ClearCache удаляет все заказы из кэша, не затрагивая БД; возвращает их количество
+	ClearCache() int
+
 	// GetCacheStats возвращает статистику работы сервиса
 	GetCacheStats() map[string]interface{}
-	
+
+	// StreamOrders последовательно передает все заказы из БД в fn
+	StreamOrders(ctx context.Context, fn func(*models.Order) error) error
+
+	// HealthStatus проверяет доступность БД для проверки готовности
+	HealthStatus(ctx context.Context) error
+
 	// Close закрывает соединение с базой данных
 	Close()
-}
\ No newline at end of file
+}
+
+// MessageConsumer читатель топика заказов: передает каждый заказ в processFunc до отмены ctx
+type MessageConsumer interface {
+	// Consume обрабатывает сообщения до отмены ctx
+	Consume(ctx context.Context, processFunc func(context.Context, *models.Order) error) error
+
+	// SetMaxRetry задает число попыток обработки заказа перед отправкой в DLQ
+	SetMaxRetry(maxRetry int)
+
+	// Close закрывает читатель
+	Close() error
+}
+
+// DeadLetterSink получатель сообщений, которые не удалось обработать (DLQ)
+type DeadLetterSink interface {
+	// SendToDLQ отправляет исходное сообщение с ошибкой обработки и числом попыток
+	SendToDLQ(originalMsg kafka.Message, err error, attempts int) error
+
+	// Close закрывает отправку
+	Close() error
+}
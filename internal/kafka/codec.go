@@ -0,0 +1,108 @@
+package kafka
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"test_service/internal/models"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// ContentTypeProtobuf формат тела сообщений с заказами в protobuf (схема orderpb/order.proto)
+const ContentTypeProtobuf = "application/x-protobuf"
+
+// ErrUnsupportedContentType сообщение с неизвестным content_type: уходит в DLQ с причиной DLQReasonSchema
+var ErrUnsupportedContentType = errors.New("неподдерживаемый формат сообщения")
+
+// Codec формат тела сообщений с заказами. Producer кодирует заказы своим форматом (SetCodec)
+// и пишет его в заголовок content_type; consumer выбирает формат по заголовку сообщения.
+type Codec interface {
+	// Name короткое имя формата для настроек, логов и DLQ: json или protobuf
+	Name() string
+
+	// ContentType значение заголовка content_type
+	ContentType() string
+
+	// Marshal кодирует заказ
+	Marshal(order *models.Order) ([]byte, error)
+
+	// Unmarshal декодирует заказ
+	Unmarshal(data []byte, order *models.Order) error
+}
+
+// JSONCodec заказ в JSON (формат по умолчанию)
+type JSONCodec struct{}
+
+// Name реализует Codec
+func (JSONCodec) Name() string { return "json" }
+
+// ContentType реализует Codec
+func (JSONCodec) ContentType() string { return ContentTypeJSON }
+
+// Marshal реализует Codec
+func (JSONCodec) Marshal(order *models.Order) ([]byte, error) { return json.Marshal(order) }
+
+// Unmarshal реализует Codec
+func (JSONCodec) Unmarshal(data []byte, order *models.Order) error {
+	return json.Unmarshal(data, order)
+}
+
+// codecs поддерживаемые форматы
+var codecs = []Codec{JSONCodec{}, ProtobufCodec{}}
+
+// CodecByName возвращает формат по имени (KAFKA_CODEC): json или protobuf
+func CodecByName(name string) (Codec, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, codec := range codecs {
+		if codec.Name() == name {
+			return codec, nil
+		}
+	}
+	return nil, fmt.Errorf("неизвестный формат сообщений %q: поддерживаются json и protobuf", name)
+}
+
+// codecFor возвращает формат сообщения по заголовку content_type; сообщения без заголовка
+// декодируются форматом fallback
+func codecFor(headers []kafka.Header, fallback Codec) (Codec, error) {
+	contentType := headerValue(headers, HeaderContentType)
+	if contentType == "" {
+		return fallback, nil
+	}
+	for _, codec := range codecs {
+		if codec.ContentType() == contentType {
+			return codec, nil
+		}
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnsupportedContentType, contentType)
+}
+
+// DecodeError ошибка декодирования тела сообщения форматом Codec: ошибка данных (DLQReasonBadData)
+type DecodeError struct {
+	Codec string // Имя формата (Codec.Name)
+	Err   error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("ошибка декодирования %s: %v", e.Codec, e.Err)
+}
+
+// Unwrap возвращает исходную ошибку формата
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// decodeOrder декодирует заказ сообщения форматом из заголовка content_type (без заголовка — fallback)
+func decodeOrder(headers []kafka.Header, value []byte, fallback Codec) (*models.Order, error) {
+	codec, err := codecFor(headers, fallback)
+	if err != nil {
+		return nil, err
+	}
+	var order models.Order
+	if err := codec.Unmarshal(value, &order); err != nil {
+		return nil, &DecodeError{Codec: codec.Name(), Err: err}
+	}
+	return &order, nil
+}
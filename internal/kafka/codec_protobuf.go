@@ -0,0 +1,180 @@
+package kafka
+
+import (
+	"time"
+
+	"test_service/internal/kafka/orderpb"
+	"test_service/internal/models"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ProtobufCodec заказ в protobuf (orderpb.Order). Время передается в UTC: часовой пояс
+// исходного времени не сохраняется, сам момент — с точностью до наносекунды.
+type ProtobufCodec struct{}
+
+// Name реализует Codec
+func (ProtobufCodec) Name() string { return "protobuf" }
+
+// ContentType реализует Codec
+func (ProtobufCodec) ContentType() string { return ContentTypeProtobuf }
+
+// Marshal реализует Codec
+func (ProtobufCodec) Marshal(order *models.Order) ([]byte, error) {
+	return proto.Marshal(orderToProto(order))
+}
+
+// Unmarshal реализует Codec
+func (ProtobufCodec) Unmarshal(data []byte, order *models.Order) error {
+	var pb orderpb.Order
+	if err := proto.Unmarshal(data, &pb); err != nil {
+		return err
+	}
+	*order = *orderFromProto(&pb)
+	return nil
+}
+
+// orderToProto преобразует заказ в сообщение protobuf
+func orderToProto(order *models.Order) *orderpb.Order {
+	pb := &orderpb.Order{
+		OrderUid:    order.OrderUID,
+		TrackNumber: order.TrackNumber,
+		Entry:       order.Entry,
+		Delivery: &orderpb.Delivery{
+			Name:    order.Delivery.Name,
+			Phone:   order.Delivery.Phone,
+			Zip:     order.Delivery.Zip,
+			City:    order.Delivery.City,
+			Address: order.Delivery.Address,
+			Region:  order.Delivery.Region,
+			Email:   order.Delivery.Email,
+		},
+		Payment: &orderpb.Payment{
+			Transaction:  order.Payment.Transaction,
+			RequestId:    order.Payment.RequestID,
+			Currency:     order.Payment.Currency,
+			Provider:     order.Payment.Provider,
+			Amount:       int64(order.Payment.Amount),
+			PaymentDt:    order.Payment.PaymentDT,
+			Bank:         order.Payment.Bank,
+			DeliveryCost: int64(order.Payment.DeliveryCost),
+			GoodsTotal:   int64(order.Payment.GoodsTotal),
+			CustomFee:    int64(order.Payment.CustomFee),
+		},
+		Locale:            order.Locale,
+		InternalSignature: order.InternalSignature,
+		CustomerId:        order.CustomerID,
+		DeliveryService:   order.DeliveryService,
+		Shardkey:          order.ShardKey,
+		SmId:              int64(order.SMID),
+		DateCreated:       timestampToProto(order.DateCreated),
+		OofShard:          order.OOFShard,
+		UpdatedAt:         timestampToProto(order.UpdatedAt),
+		Status:            string(order.Status),
+	}
+	if order.DeletedAt != nil {
+		pb.DeletedAt = timestamppb.New(*order.DeletedAt)
+	}
+	if order.Items != nil {
+		pb.Items = make([]*orderpb.Item, 0, len(order.Items))
+	}
+	for _, item := range order.Items {
+		pb.Items = append(pb.Items, &orderpb.Item{
+			ChrtId:      int64(item.ChrtID),
+			TrackNumber: item.TrackNumber,
+			Price:       int64(item.Price),
+			Rid:         item.RID,
+			Name:        item.Name,
+			Sale:        int64(item.Sale),
+			Size:        item.Size,
+			TotalPrice:  int64(item.TotalPrice),
+			NmId:        int64(item.NMID),
+			Brand:       item.Brand,
+			Status:      int64(item.Status),
+		})
+	}
+	return pb
+}
+
+// orderFromProto преобразует сообщение protobuf в заказ; отсутствующие доставка и оплата —
+// пустые (их отклонит валидация заказа)
+func orderFromProto(pb *orderpb.Order) *models.Order {
+	delivery := pb.GetDelivery()
+	payment := pb.GetPayment()
+	order := &models.Order{
+		OrderUID:    pb.GetOrderUid(),
+		TrackNumber: pb.GetTrackNumber(),
+		Entry:       pb.GetEntry(),
+		Delivery: models.Delivery{
+			Name:    delivery.GetName(),
+			Phone:   delivery.GetPhone(),
+			Zip:     delivery.GetZip(),
+			City:    delivery.GetCity(),
+			Address: delivery.GetAddress(),
+			Region:  delivery.GetRegion(),
+			Email:   delivery.GetEmail(),
+		},
+		Payment: models.Payment{
+			Transaction:  payment.GetTransaction(),
+			RequestID:    payment.GetRequestId(),
+			Currency:     payment.GetCurrency(),
+			Provider:     payment.GetProvider(),
+			Amount:       int(payment.GetAmount()),
+			PaymentDT:    payment.GetPaymentDt(),
+			Bank:         payment.GetBank(),
+			DeliveryCost: int(payment.GetDeliveryCost()),
+			GoodsTotal:   int(payment.GetGoodsTotal()),
+			CustomFee:    int(payment.GetCustomFee()),
+		},
+		Locale:            pb.GetLocale(),
+		InternalSignature: pb.GetInternalSignature(),
+		CustomerID:        pb.GetCustomerId(),
+		DeliveryService:   pb.GetDeliveryService(),
+		ShardKey:          pb.GetShardkey(),
+		SMID:              int(pb.GetSmId()),
+		DateCreated:       timestampFromProto(pb.GetDateCreated()),
+		OOFShard:          pb.GetOofShard(),
+		UpdatedAt:         timestampFromProto(pb.GetUpdatedAt()),
+		Status:            models.OrderStatus(pb.GetStatus()),
+	}
+	if pb.GetDeletedAt() != nil {
+		deletedAt := pb.GetDeletedAt().AsTime()
+		order.DeletedAt = &deletedAt
+	}
+	if pb.GetItems() != nil {
+		order.Items = make([]models.Item, 0, len(pb.GetItems()))
+	}
+	for _, item := range pb.GetItems() {
+		order.Items = append(order.Items, models.Item{
+			ChrtID:      int(item.GetChrtId()),
+			TrackNumber: item.GetTrackNumber(),
+			Price:       int(item.GetPrice()),
+			RID:         item.GetRid(),
+			Name:        item.GetName(),
+			Sale:        int(item.GetSale()),
+			Size:        item.GetSize(),
+			TotalPrice:  int(item.GetTotalPrice()),
+			NMID:        int(item.GetNmId()),
+			Brand:       item.GetBrand(),
+			Status:      int(item.GetStatus()),
+		})
+	}
+	return order
+}
+
+// timestampToProto время в protobuf; нулевое время не передается
+func timestampToProto(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// timestampFromProto время из protobuf в UTC; отсутствующее — нулевое время
+func timestampFromProto(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
@@ -0,0 +1,358 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"test_service/internal/database"
+	"test_service/internal/kafka/orderpb"
+	"test_service/internal/models"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// fullOrder заказ, в котором заполнены все поля, включая время удаления и несколько товаров
+func fullOrder() *models.Order {
+	moscow := time.FixedZone("MSK", 3*60*60)
+	deletedAt := time.Date(2024, 5, 3, 9, 30, 0, 123456789, moscow)
+	return &models.Order{
+		OrderUID:    "b563feb7b2b84b6test000000000000a",
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: models.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: models.Payment{
+			Transaction:  "b563feb7b2b84b6test000000000000a",
+			RequestID:    "req-1",
+			Currency:     "USD",
+			Provider:     "wbpay",
+			Amount:       1817,
+			PaymentDT:    1637907727,
+			Bank:         "alpha",
+			DeliveryCost: 1500,
+			GoodsTotal:   317,
+			CustomFee:    7,
+		},
+		Items: []models.Item{
+			{ChrtID: 9934930, TrackNumber: "WBILMTESTTRACK", Price: 453, RID: "ab4219087a764ae0btest", Name: "Mascaras",
+				Sale: 30, Size: "0", TotalPrice: 317, NMID: 2389212, Brand: "Vivienne Sabo", Status: 202},
+			{ChrtID: 9934931, TrackNumber: "WBILMTESTTRACK", Price: 100, RID: "ab4219087a764ae0btest2", Name: "Brush",
+				Sale: 0, Size: "M", TotalPrice: 100, NMID: 2389213, Brand: "Sabo", Status: 200},
+		},
+		Locale:            "en",
+		InternalSignature: "sig",
+		CustomerID:        "test",
+		DeliveryService:   "meest",
+		ShardKey:          "9",
+		SMID:              99,
+		DateCreated:       time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		OOFShard:          "1",
+		UpdatedAt:         time.Date(2024, 5, 2, 12, 0, 0, 500, moscow),
+		DeletedAt:         &deletedAt,
+		Status:            models.StatusShipped,
+	}
+}
+
+// assertSameOrder сравнивает заказы с точностью до часового пояса времени
+func assertSameOrder(t *testing.T, want, got *models.Order) {
+	t.Helper()
+	assert.True(t, want.DateCreated.Equal(got.DateCreated), "date_created: %v != %v", want.DateCreated, got.DateCreated)
+	assert.True(t, want.UpdatedAt.Equal(got.UpdatedAt), "updated_at: %v != %v", want.UpdatedAt, got.UpdatedAt)
+	if want.DeletedAt == nil {
+		assert.Nil(t, got.DeletedAt)
+	} else if assert.NotNil(t, got.DeletedAt) {
+		assert.True(t, want.DeletedAt.Equal(*got.DeletedAt), "deleted_at: %v != %v", *want.DeletedAt, *got.DeletedAt)
+	}
+
+	wantCopy, gotCopy := *want, *got
+	wantCopy.DateCreated, gotCopy.DateCreated = time.Time{}, time.Time{}
+	wantCopy.UpdatedAt, gotCopy.UpdatedAt = time.Time{}, time.Time{}
+	wantCopy.DeletedAt, gotCopy.DeletedAt = nil, nil
+	assert.Equal(t, wantCopy, gotCopy)
+}
+
+func TestOrderProtoConversion(t *testing.T) {
+	t.Run("AllFields", func(t *testing.T) {
+		order := fullOrder()
+		pb := orderToProto(order)
+
+		assert.Equal(t, order.OrderUID, pb.GetOrderUid())
+		assert.Equal(t, "Kiryat Mozkin", pb.GetDelivery().GetCity())
+		assert.Equal(t, int64(1817), pb.GetPayment().GetAmount())
+		assert.Equal(t, int64(99), pb.GetSmId())
+		assert.Equal(t, "shipped", pb.GetStatus())
+		require.Len(t, pb.GetItems(), 2)
+		assert.Equal(t, "Brush", pb.GetItems()[1].GetName())
+		assert.Equal(t, int64(202), pb.GetItems()[0].GetStatus())
+		assert.Equal(t, int32(123456789), pb.GetDeletedAt().GetNanos(), "время передается с наносекундами")
+
+		got := orderFromProto(pb)
+		assertSameOrder(t, order, got)
+		assert.Equal(t, time.UTC, got.UpdatedAt.Location(), "время возвращается в UTC")
+		assert.Equal(t, time.UTC, got.DeletedAt.Location())
+	})
+
+	t.Run("ZeroTimesAndNotDeleted", func(t *testing.T) {
+		order := fullOrder()
+		order.DateCreated = time.Time{}
+		order.UpdatedAt = time.Time{}
+		order.DeletedAt = nil
+		pb := orderToProto(order)
+
+		assert.Nil(t, pb.GetDateCreated(), "нулевое время не передается")
+		assert.Nil(t, pb.GetUpdatedAt())
+		assert.Nil(t, pb.GetDeletedAt())
+
+		got := orderFromProto(pb)
+		assert.True(t, got.DateCreated.IsZero())
+		assert.True(t, got.UpdatedAt.IsZero())
+		assertSameOrder(t, order, got)
+	})
+
+	t.Run("Items", func(t *testing.T) {
+		order := fullOrder()
+		order.Items = nil
+		assert.Nil(t, orderFromProto(orderToProto(order)).Items, "заказ без товаров остается без товаров")
+
+		order.Items = []models.Item{}
+		got := orderFromProto(orderToProto(order))
+		assert.NotNil(t, got.Items)
+		assert.Empty(t, got.Items)
+	})
+
+	t.Run("MissingNestedMessages", func(t *testing.T) {
+		got := orderFromProto(&orderpb.Order{
+			OrderUid:  "order-1",
+			DeletedAt: timestamppb.New(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)),
+		})
+		assert.Equal(t, "order-1", got.OrderUID)
+		assert.Equal(t, models.Delivery{}, got.Delivery)
+		assert.Equal(t, models.Payment{}, got.Payment)
+		require.NotNil(t, got.DeletedAt)
+		assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), *got.DeletedAt)
+	})
+}
+
+func TestCodecs(t *testing.T) {
+	for _, codec := range []Codec{JSONCodec{}, ProtobufCodec{}} {
+		t.Run(codec.Name(), func(t *testing.T) {
+			order := fullOrder()
+			data, err := codec.Marshal(order)
+			require.NoError(t, err)
+
+			var got models.Order
+			require.NoError(t, codec.Unmarshal(data, &got))
+			assertSameOrder(t, order, &got)
+
+			byName, err := CodecByName(" " + codec.Name())
+			require.NoError(t, err)
+			assert.Equal(t, codec, byName)
+		})
+	}
+
+	t.Run("ProtobufWireFormat", func(t *testing.T) {
+		data, err := ProtobufCodec{}.Marshal(fullOrder())
+		require.NoError(t, err)
+		var pb orderpb.Order
+		require.NoError(t, proto.Unmarshal(data, &pb))
+		assert.Equal(t, "WBILMTESTTRACK", pb.GetTrackNumber())
+	})
+
+	t.Run("UnknownName", func(t *testing.T) {
+		_, err := CodecByName("avro")
+		assert.ErrorContains(t, err, "avro")
+	})
+}
+
+func TestDecodeOrder(t *testing.T) {
+	order := fullOrder()
+	pbValue, err := ProtobufCodec{}.Marshal(order)
+	require.NoError(t, err)
+	jsonValue := mustJSON(t, order)
+	protobufHeader := []kafka.Header{{Key: HeaderContentType, Value: []byte(ContentTypeProtobuf)}}
+
+	t.Run("ByHeader", func(t *testing.T) {
+		got, err := decodeOrder(protobufHeader, pbValue, JSONCodec{})
+		require.NoError(t, err)
+		assertSameOrder(t, order, got)
+
+		got, err = decodeOrder([]kafka.Header{{Key: HeaderContentType, Value: []byte(ContentTypeJSON)}}, jsonValue, ProtobufCodec{})
+		require.NoError(t, err)
+		assertSameOrder(t, order, got)
+	})
+
+	t.Run("FallbackWithoutHeader", func(t *testing.T) {
+		got, err := decodeOrder(nil, pbValue, ProtobufCodec{})
+		require.NoError(t, err)
+		assertSameOrder(t, order, got)
+	})
+
+	t.Run("UnsupportedContentType", func(t *testing.T) {
+		_, err := decodeOrder([]kafka.Header{{Key: HeaderContentType, Value: []byte("application/avro")}}, pbValue, JSONCodec{})
+		assert.ErrorIs(t, err, ErrUnsupportedContentType)
+		assert.Equal(t, DLQReasonSchema, dlqReason(err))
+		assert.Empty(t, failedCodec(err))
+	})
+
+	t.Run("DecodeError", func(t *testing.T) {
+		for codec, value := range map[string][]byte{"json": pbValue, "protobuf": []byte("{not protobuf")} {
+			headers := protobufHeader
+			if codec == "json" {
+				headers = nil
+			}
+			_, err := decodeOrder(headers, value, JSONCodec{})
+			var decodeErr *DecodeError
+			require.ErrorAs(t, err, &decodeErr, codec)
+			assert.Equal(t, codec, decodeErr.Codec)
+			assert.Equal(t, DLQReasonBadData, dlqReason(err))
+			assert.Equal(t, codec, failedCodec(err))
+		}
+	})
+}
+
+func TestProducer_SetCodec(t *testing.T) {
+	writer := &fakeProducerWriter{}
+	p := newProducer(writer, "orders")
+	p.SetCodec(ProtobufCodec{})
+	order := fullOrder()
+	require.NoError(t, p.SendOrderWithContext(context.Background(), order))
+	require.NoError(t, p.SendOrders(context.Background(), []*models.Order{order}))
+
+	require.Len(t, writer.batches, 2)
+	for _, batch := range writer.batches {
+		require.Len(t, batch, 1)
+		assert.Equal(t, ContentTypeProtobuf, headerValue(batch[0].Headers, HeaderContentType))
+		got, err := decodeOrder(batch[0].Headers, batch[0].Value, JSONCodec{})
+		require.NoError(t, err)
+		assertSameOrder(t, order, got)
+	}
+
+	p.SetCodec(nil)
+	require.NoError(t, p.SendOrder(order))
+	assert.Equal(t, ContentTypeJSON, headerValue(writer.batches[2][0].Headers, HeaderContentType))
+}
+
+func TestConsumer_Codec(t *testing.T) {
+	// protobufMessage сообщение с заказом GenerateTestOrder(index) в protobuf
+	protobufMessage := func(t *testing.T, index int, offset int64, headers []kafka.Header) kafka.Message {
+		order := GenerateTestOrder(index)
+		value, err := ProtobufCodec{}.Marshal(order)
+		require.NoError(t, err)
+		return kafka.Message{Key: []byte(order.OrderUID), Value: value, Offset: offset, Headers: headers}
+	}
+	protobufHeader := []kafka.Header{{Key: HeaderContentType, Value: []byte(ContentTypeProtobuf)}}
+
+	t.Run("MixedFormatsByHeader", func(t *testing.T) {
+		reader := newFakeConsumerReader([]kafka.Message{
+			orderMessage(t, 1, 0, 0),
+			protobufMessage(t, 2, 1, protobufHeader),
+		})
+		c := newConsumer(reader, "orders")
+
+		var processed processedOrders
+		consumeAll(t, c, reader, 1, processed.process)
+		assert.Equal(t, []string{GenerateTestOrder(1).OrderUID, GenerateTestOrder(2).OrderUID}, processed.list())
+	})
+
+	t.Run("FallbackCodec", func(t *testing.T) {
+		reader := newFakeConsumerReader([]kafka.Message{protobufMessage(t, 3, 0, nil)})
+		c := newConsumer(reader, "orders")
+		c.SetCodec(ProtobufCodec{})
+
+		var processed processedOrders
+		consumeAll(t, c, reader, 0, processed.process)
+		assert.Equal(t, []string{GenerateTestOrder(3).OrderUID}, processed.list())
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		var batches orderBatches
+		c, reader := newBatchConsumer([]kafka.Message{
+			protobufMessage(t, 4, 0, protobufHeader),
+			orderMessage(t, 5, 0, 1),
+		}, 2, time.Minute, &batches)
+
+		runBatchConsumer(t, c, func(_ context.Context, order *models.Order) error {
+			t.Errorf("заказ %s не должен обрабатываться по одному", order.OrderUID)
+			return nil
+		}, func() bool { return len(reader.commitCalls()) == 1 })
+		assert.Equal(t, [][]string{{GenerateTestOrder(4).OrderUID, GenerateTestOrder(5).OrderUID}}, batches.list())
+	})
+
+	t.Run("InvalidProtobufToDLQ", func(t *testing.T) {
+		msg := kafka.Message{Key: []byte("order-1"), Value: []byte{0x0a, 0xff}, Headers: protobufHeader}
+		dlq, dlqWriter := newTestDLQProducer()
+		reader := newFakeConsumerReader([]kafka.Message{msg})
+		c := newConsumer(reader, "orders")
+		c.dlq = dlq
+
+		consumeAll(t, c, reader, 0, func(context.Context, *models.Order) error {
+			t.Error("неразобранный заказ не обрабатывается")
+			return nil
+		})
+
+		require.Len(t, dlqWriter.batches, 1)
+		var envelope DLQMessage
+		require.NoError(t, json.Unmarshal(dlqWriter.batches[0][0].Value, &envelope))
+		assert.Equal(t, "protobuf", envelope.Codec)
+		assert.Equal(t, DLQReasonBadData, envelope.Reason)
+		assert.Equal(t, msg.Value, envelope.OriginalPayload, "тело не в JSON хранится в original_payload")
+		assert.Nil(t, envelope.OriginalMessage)
+	})
+
+	t.Run("FallbackCodecRecordedForRetryAndDLQ", func(t *testing.T) {
+		dlq, dlqWriter := newTestDLQProducer()
+		reader := newFakeConsumerReader([]kafka.Message{protobufMessage(t, 6, 0, nil)})
+		c := newConsumer(reader, "orders")
+		c.SetCodec(ProtobufCodec{})
+		c.dlq = dlq
+
+		consumeAll(t, c, reader, 0, func(context.Context, *models.Order) error {
+			return database.ErrConstraintViolation
+		})
+
+		require.Len(t, dlqWriter.batches, 1)
+		dlqMsg := dlqWriter.batches[0][0]
+		assert.Equal(t, ContentTypeProtobuf, headerValue(dlqMsg.Headers, HeaderContentType),
+			"формат сообщения без заголовка записывается для разбора в DLQ")
+		var envelope DLQMessage
+		require.NoError(t, json.Unmarshal(dlqMsg.Value, &envelope))
+		assert.Empty(t, envelope.Codec, "заказ разобран, ошибка не декодирования")
+		assert.NotEmpty(t, envelope.OriginalPayload)
+	})
+}
+
+func TestDLQConsumer_ReplayProtobuf(t *testing.T) {
+	order := GenerateTestOrder(7)
+	value, err := ProtobufCodec{}.Marshal(order)
+	require.NoError(t, err)
+	dlq, dlqWriter := newTestDLQProducer()
+	require.NoError(t, dlq.SendToDLQ(kafka.Message{
+		Topic:   "orders",
+		Key:     []byte(order.OrderUID),
+		Value:   value,
+		Headers: []kafka.Header{{Key: HeaderContentType, Value: []byte(ContentTypeProtobuf)}},
+	}, database.ErrConstraintViolation, 1))
+	envelope := dlqWriter.batches[0][0]
+
+	reader := &fakeDLQReader{messages: []kafka.Message{envelope}}
+	d := newTestDLQConsumer(reader, &fakeDLQRequeuer{})
+	var processed processedOrders
+	summary, err := d.Replay(context.Background(), DLQReplayOptions{Max: 1}, processed.process)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, summary.Replayed)
+	assert.Equal(t, []string{order.OrderUID}, processed.list())
+}
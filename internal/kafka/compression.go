@@ -0,0 +1,26 @@
+package kafka
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// ParseCompression возвращает кодек сжатия по имени (KAFKA_COMPRESSION): none, gzip, snappy, lz4 или zstd
+func ParseCompression(name string) (kafka.Compression, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "none":
+		return 0, nil
+	case "gzip":
+		return kafka.Gzip, nil
+	case "snappy":
+		return kafka.Snappy, nil
+	case "lz4":
+		return kafka.Lz4, nil
+	case "zstd":
+		return kafka.Zstd, nil
+	default:
+		return 0, fmt.Errorf("неизвестное сжатие %q: поддерживаются none, gzip, snappy, lz4 и zstd", name)
+	}
+}
@@ -0,0 +1,57 @@
+//go:build integration
+
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"test_service/internal/models"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCompression_RoundTrip отправляет заказ каждым кодеком и читает его обычным читателем
+func TestCompression_RoundTrip(t *testing.T) {
+	brokers := os.Getenv("KAFKA_BROKERS")
+	if brokers == "" {
+		t.Skip("KAFKA_BROKERS не задан")
+	}
+	for _, name := range []string{"none", "gzip", "snappy", "lz4", "zstd"} {
+		t.Run(name, func(t *testing.T) {
+			codec, err := ParseCompression(name)
+			require.NoError(t, err)
+			opts := DefaultWriterOptions()
+			opts.Compression = codec
+
+			topic := fmt.Sprintf("compression-test-%s-%d", name, time.Now().UnixNano())
+			producer := NewProducer(strings.Split(brokers, ","), topic, opts)
+			defer producer.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			order := GenerateTestOrder(1)
+			require.NoError(t, producer.SendOrderWithContext(ctx, order))
+
+			reader := kafka.NewReader(kafka.ReaderConfig{
+				Brokers: strings.Split(brokers, ","),
+				Topic:   topic,
+			})
+			defer reader.Close()
+			msg, err := reader.ReadMessage(ctx)
+			require.NoError(t, err)
+
+			var got models.Order
+			require.NoError(t, json.Unmarshal(msg.Value, &got))
+			assert.Equal(t, order.OrderUID, got.OrderUID)
+			assert.Len(t, got.Items, len(order.Items))
+		})
+	}
+}
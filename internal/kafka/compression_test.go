@@ -0,0 +1,47 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCompression(t *testing.T) {
+	tests := []struct {
+		name string
+		want kafka.Compression
+	}{
+		{name: "none", want: 0},
+		{name: "gzip", want: kafka.Gzip},
+		{name: "snappy", want: kafka.Snappy},
+		{name: "lz4", want: kafka.Lz4},
+		{name: "zstd", want: kafka.Zstd},
+		{name: " ZSTD ", want: kafka.Zstd},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codec, err := ParseCompression(tt.name)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, codec)
+		})
+	}
+
+	for _, name := range []string{"", "brotli"} {
+		_, err := ParseCompression(name)
+		assert.ErrorContains(t, err, "неизвестное сжатие", name)
+	}
+}
+
+func TestWriterCompression(t *testing.T) {
+	opts := DefaultWriterOptions()
+	opts.Compression = kafka.Zstd
+	producer := NewProducer([]string{"localhost:9092"}, "orders", opts)
+	dlqProducer := NewDLQProducer([]string{"localhost:9092"}, "orders-dlq", opts)
+	retryProducer := NewRetryProducer([]string{"localhost:9092"}, "orders-retry", nil, opts)
+
+	assert.Equal(t, kafka.Zstd, producer.writer.(*kafka.Writer).Compression)
+	assert.Equal(t, kafka.Zstd, dlqProducer.writer.(*kafka.Writer).Compression)
+	assert.Equal(t, kafka.Zstd, retryProducer.writer.Compression)
+}
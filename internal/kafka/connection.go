@@ -0,0 +1,45 @@
+package kafka
+
+import (
+	"crypto/tls"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Connection параметры подключения к брокерам (SASL и TLS), общие для читателей и писателей.
+// Создается один раз при запуске (NewConnection) и передается в конструкторы через WriterOptions,
+// ConsumerOptions и конфигурации читателей; нулевое значение — без аутентификации и шифрования.
+type Connection struct {
+	transport kafka.RoundTripper // Transport писателей (nil — kafka.DefaultTransport)
+	dialer    *kafka.Dialer      // Dialer читателей и прямых соединений (nil — kafka.DefaultDialer)
+}
+
+// NewConnection создает параметры подключения с SASL-аутентификацией saslCfg и TLS tlsCfg
+// (nil — без шифрования). Неизвестный механизм SASL или незаданные имя пользователя и пароль — ошибка.
+func NewConnection(saslCfg SASLConfig, tlsCfg *tls.Config) (Connection, error) {
+	mechanism, err := newSASLMechanism(saslCfg)
+	if err != nil {
+		return Connection{}, err
+	}
+	if mechanism == nil && tlsCfg == nil {
+		return Connection{}, nil
+	}
+	return Connection{
+		transport: &kafka.Transport{SASL: mechanism, TLS: tlsCfg},
+		dialer: &kafka.Dialer{
+			Timeout:       10 * time.Second, // Как у kafka.DefaultDialer
+			DualStack:     true,
+			SASLMechanism: mechanism,
+			TLS:           tlsCfg,
+		},
+	}, nil
+}
+
+// directDialer возвращает Dialer прямых соединений с брокерами
+func (c Connection) directDialer() *kafka.Dialer {
+	if c.dialer != nil {
+		return c.dialer
+	}
+	return kafka.DefaultDialer
+}
@@ -3,172 +3,453 @@ package kafka
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"hash/fnv"
 	"log"
+	"strconv"
+	"sync"
 	"time"
 
+	"test_service/internal/interfaces"
 	"test_service/internal/models"
+	"test_service/internal/retry"
 
 	"github.com/segmentio/kafka-go"
 )
 
+// workerQueueSize размер очереди сообщений одного обработчика при SetConcurrency(n > 1)
+const workerQueueSize = 16
+
+// Consumer используется в main через interfaces.MessageConsumer
+var _ interfaces.MessageConsumer = (*Consumer)(nil)
+
+// consumerReader минимальный набор методов читателя топика заказов; в тестах Consume
+// подменяется фейком с заданной последовательностью сообщений
+type consumerReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
 // Consumer для обработки сообщений
 type Consumer struct {
-	reader   *kafka.Reader // Kafka reader для чтения сообщений
-	dlq      *DLQProducer  // DLQ producer для отправки неудачных сообщений
-	maxRetry int           // Максимальное количество попыток обработки
-	metrics  *KafkaMetrics // Метрики для мониторинга
-}
-
-// NewConsumer создает новый Kafka consumer
-func NewConsumer(brokers []string, topic string, groupID string) *Consumer {
-	// Создаем конфигурацию для Kafka reader
-	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:        brokers,     // Список брокеров Kafka
-		GroupID:        groupID,     // ID группы потребителей
-		Topic:          topic,       // Топик для чтения
-		CommitInterval: time.Second, // Интервал коммита сообщений
-	})
-	return &Consumer{
-		reader:   reader,
-		maxRetry: 3,                 // Максимальное количество попыток
-		metrics:  NewKafkaMetrics(), // Инициализировать метрики
+	reader      consumerReader // Kafka reader для чтения сообщений
+	topic       string         // Топик, из которого читаются сообщения
+	dlq         dlqSender      // DLQ producer для отправки неудачных сообщений (nil — без DLQ)
+	retry       retrySender    // Топик повторов для заказов, не обработанных из-за сбоя (nil — сразу в DLQ)
+	retryPolicy retry.Policy   // Повторы обработки заказа (число попыток — SetMaxRetry)
+	concurrency int            // Количество параллельных обработчиков сообщений
+	metrics     *KafkaMetrics  // Метрики для мониторинга
+
+	messageContext func(context.Context, kafka.Message) context.Context // Контекст обработки сообщения (SetMessageContext)
+
+	delivery            DeliveryMode  // Гарантия доставки (SetDelivery)
+	deliveryBackoff     time.Duration // Задержка перед повторной доставкой в режиме at_least_once
+	maxDeliveryFailures int           // Неудачных доставок до пропуска сообщения (0 — без ограничения)
+
+	batchSize    int                                          // Сообщений в пакете (SetBatch; 0 — без пакетов)
+	batchTimeout time.Duration                                // Ожидание пакета с первого сообщения
+	processBatch func(context.Context, []*models.Order) error // Обработка заказов пакета
+
+	dedup *dedupWindow // Подавление повторов (SetDedup; nil — выключено)
+	codec Codec        // Формат сообщений без заголовка content_type (SetCodec)
+
+	lagSource lagSource    // Отставание группы по партициям (nil — недоступно)
+	lag       *lagReporter // Публикация отставания (StartLagReporter)
+}
+
+// NewConsumer создает новый Kafka consumer с настройками читателя opts
+func NewConsumer(brokers []string, topic string, groupID string, opts ConsumerOptions) *Consumer {
+	return NewConsumerWithDLQ(brokers, topic, groupID, opts, nil)
+}
+
+// NewConsumerWithDLQ создает новый Kafka consumer с настройками читателя opts и DLQ (nil — без DLQ)
+func NewConsumerWithDLQ(brokers []string, topic string, groupID string, opts ConsumerOptions, dlq interfaces.DeadLetterSink) *Consumer {
+	reader := kafka.NewReader(consumerReaderConfig(brokers, topic, groupID, opts))
+	c := newConsumer(reader, topic)
+	c.lagSource = newBrokerLag(brokers, topic, groupID, opts.Connection)
+	c.metrics.Stats.addReader(statsClientConsumer, topic, reader)
+	if dlq != nil {
+		c.dlq = dlq
 	}
+	return c
 }
 
-// NewConsumerWithDLQ создает новый Kafka consumer с DLQ
-func NewConsumerWithDLQ(brokers []string, topic string, groupID string, dlqProducer *DLQProducer) *Consumer {
-	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:        brokers,     // Список брокеров Kafka
-		GroupID:        groupID,     // ID группы потребителей
-		Topic:          topic,       // Топик для чтения
-		CommitInterval: time.Second, // Интервал коммита сообщений
-	})
+// newConsumer создает consumer с готовым читателем
+func newConsumer(reader consumerReader, topic string) *Consumer {
 	return &Consumer{
-		reader:   reader,
-		dlq:      dlqProducer,
-		maxRetry: 3,                 // Максимальное количество попыток по умолчанию
-		metrics:  NewKafkaMetrics(), // Инициализировать метрики
+		reader:      reader,
+		topic:       topic,
+		retryPolicy: retry.DefaultPolicy(), // 3 попытки обработки по умолчанию
+		concurrency: 1,                     // Сообщения обрабатываются по одному
+		metrics:     NewKafkaMetrics(),     // Инициализировать метрики
+		codec:       JSONCodec{},           // Сообщения без content_type — JSON
+
+		delivery:        DeliveryAtMostOnce, // Сообщение коммитится после обработки в любом случае
+		deliveryBackoff: DefaultDeliveryBackoff,
 	}
 }
 
-// SetMaxRetry устанавливает максимальное количество попыток обработки
+// SetMaxRetry устанавливает максимальное количество попыток обработки заказа перед отправкой в DLQ
+// (maxRetry < 1 — одна попытка). Между попытками — задержка retry.DefaultPolicy.
 func (c *Consumer) SetMaxRetry(maxRetry int) {
-	c.maxRetry = maxRetry
+	c.retryPolicy.MaxAttempts = maxRetry
+}
+
+// SetRetry включает отправку заказов, не обработанных из-за сбоя (БД, инфраструктура), в топик
+// повторов вместо DLQ; их повторно обрабатывает RetryReader. Вызывается до Consume.
+func (c *Consumer) SetRetry(retryProducer *RetryProducer) {
+	if retryProducer != nil {
+		c.retry = retryProducer
+	}
+}
+
+// SetCodec задает формат сообщений без заголовка content_type (nil — JSON). Сообщения с
+// заголовком декодируются форматом из заголовка. Вызывается до Consume.
+func (c *Consumer) SetCodec(codec Codec) {
+	if codec == nil {
+		codec = JSONCodec{}
+	}
+	c.codec = codec
+}
+
+// SetMessageContext задает построение контекста обработки сообщения из контекста Consume,
+// например чтобы извлечь из заголовков сообщения trace или request ID: контекст передается
+// в processFunc и дальше в запросы к БД. Вызывается до Consume.
+func (c *Consumer) SetMessageContext(fn func(ctx context.Context, msg kafka.Message) context.Context) {
+	c.messageContext = fn
+}
+
+// SetConcurrency задает количество параллельных обработчиков сообщений (n < 1 — один).
+// Сообщение направляется обработчику по хешу ключа (UID заказа), поэтому сообщения одного
+// заказа обрабатываются по порядку. Смещение партиции коммитится, только когда обработаны
+// все полученные до него сообщения этой партиции. Вызывается до Consume.
+func (c *Consumer) SetConcurrency(n int) {
+	if n < 1 {
+		n = 1
+	}
+	c.concurrency = n
+}
+
+// StartLagReporter запускает публикацию отставания группы по партициям в kafka_consumer_lag:
+// сразу и затем раз в interval до Close (interval <= 0 — выключено). Ошибки брокера только
+// логируются. Вызывается один раз.
+func (c *Consumer) StartLagReporter(interval time.Duration) {
+	if interval <= 0 || c.lagSource == nil || c.lag != nil {
+		return
+	}
+	c.lag = newLagReporter(c.lagSource, c.topic, interval, c.metrics)
+	c.lag.start()
 }
 
-// Consume запускает бесконечный цикл обработки сообщений из Kafka
-func (c *Consumer) Consume(ctx context.Context, processFunc func(*models.Order) error) error {
+// Consume запускает бесконечный цикл обработки сообщений из Kafka. ctx передается в processFunc:
+// его отмена прерывает обработку, и прерванное сообщение не коммитится.
+func (c *Consumer) Consume(ctx context.Context, processFunc func(context.Context, *models.Order) error) error {
+	if c.processBatch != nil {
+		return c.consumeBatches(ctx, processFunc)
+	}
+	if c.concurrency > 1 {
+		return c.consumeConcurrently(ctx, processFunc)
+	}
+	// Коммит сообщения, обработанного до остановки, не должен прерываться отменой ctx
+	commitCtx := context.WithoutCancel(ctx)
 	for {
 		select {
 		case <-ctx.Done():
 			// Контекст выполнен, закрываем reader
 			return c.reader.Close()
 		default:
-			// Получаем сообщение из Kafka
-			msg, err := c.reader.FetchMessage(ctx)
-			if err != nil {
-				// Если контекст отменен, выходим
-				select {
-				case <-ctx.Done():
-					return nil
-				default:
-					c.metrics.FailedReceivesTotal.Inc()
-					log.Printf("Ошибка при получении сообщения: %v", err)
-					continue
+			msg, ok := c.fetch(ctx)
+			if !ok {
+				if ctx.Err() != nil {
+					// Остановка во время ожидания сообщения: reader закрывается так же, как выше
+					return c.reader.Close()
 				}
+				continue
 			}
 
-			c.metrics.MessagesReceivedTotal.Inc()
-
-			// Декодируем JSON сообщение в структуру заказа
-			var order models.Order
-			if err := json.Unmarshal(msg.Value, &order); err != nil {
-				c.metrics.ProcessingErrorsTotal.Inc()
-				log.Printf("Ошибка дешифровки сообщения: %v", err)
-				// Отправляем сообщение в DLQ, если DLQ настроена
-				if c.dlq != nil {
-					dlqMsg := kafka.Message{
-						Topic: c.reader.Config().Topic,
-						Key:   msg.Key,
-						Value: msg.Value,
-					}
-					if dlqErr := c.dlq.SendToDLQ(dlqMsg, err, 1); dlqErr != nil {
-						log.Printf("Ошибка отправки в DLQ: %v", dlqErr)
-					} else {
-						c.metrics.DLQMessagesSentTotal.Inc()
-						log.Printf("Сообщение отправлено в DLQ из-за ошибки JSON: %s", order.OrderUID)
-					}
-				}
-				// Подтверждаем сообщение, чтобы не зациклиться
-				if err := c.reader.CommitMessages(ctx, msg); err != nil {
-					log.Printf("Ошибка commit невалидного сообщения: %v", err)
-				}
-				continue
+			if !c.deliver(ctx, msg, processFunc) {
+				// Остановка до доставки: сообщение не закоммичено и будет прочитано снова
+				return c.reader.Close()
 			}
 
-			// Валидация полезной нагрузки
-			if err := order.Validate(); err != nil {
-				c.metrics.ProcessingErrorsTotal.Inc()
-				log.Printf("Невалидный заказ %v: %v", order.OrderUID, err)
-				// Отправляем сообщение в DLQ
-				if c.dlq != nil {
-					dlqMsg := kafka.Message{
-						Topic: c.reader.Config().Topic,
-						Key:   msg.Key,
-						Value: msg.Value,
-					}
-					if dlqErr := c.dlq.SendToDLQ(dlqMsg, err, 1); dlqErr != nil {
-						log.Printf("Ошибка отправки в DLQ: %v", dlqErr)
-					} else {
-						c.metrics.DLQMessagesSentTotal.Inc()
-						log.Printf("Сообщение отправлено в DLQ из-за ошибки валидации: %s", order.OrderUID)
-					}
-				}
-				// Подтверждаем сообщение, чтобы не зациклиться
-				if err := c.reader.CommitMessages(ctx, msg); err != nil {
-					log.Printf("Ошибка commit невалидного сообщения: %v", err)
-				}
-				continue
+			// Подтверждаем сообщение, в том числе отправленное в DLQ, чтобы не зациклиться
+			if err := c.reader.CommitMessages(commitCtx, msg); err != nil {
+				log.Printf("Ошибка commit сообщения: %v", err)
 			}
+		}
+	}
+}
 
-			// Обрабатываем заказ через переданную функцию
-			startTime := time.Now()
-			if err := processFunc(&order); err != nil {
-				c.metrics.ProcessingErrorsTotal.Inc()
-				c.metrics.MessageProcessingTime.Observe(time.Since(startTime).Seconds())
-				log.Printf("Ошибка обработки заказа %s: %v", order.OrderUID, err)
-				// Отправляем сообщение в DLQ
-				if c.dlq != nil {
-					dlqMsg := kafka.Message{
-						Topic: c.reader.Config().Topic,
-						Key:   msg.Key,
-						Value: msg.Value,
-					}
-					if dlqErr := c.dlq.SendToDLQ(dlqMsg, err, 1); dlqErr != nil {
-						log.Printf("Ошибка отправки в DLQ: %v", dlqErr)
-					} else {
-						c.metrics.DLQMessagesSentTotal.Inc()
-						log.Printf("Сообщение отправлено в DLQ из-за ошибки обработки: %s", order.OrderUID)
-					}
-				}
-				// Подтверждаем сообщение, чтобы не зациклиться
-				if err := c.reader.CommitMessages(ctx, msg); err != nil {
-					log.Printf("Ошибка commit сообщения: %v", err)
+// consumeConcurrently обрабатывает сообщения c.concurrency обработчиками. При отмене ctx
+// новые сообщения не читаются, обработка прерывается, обработанные до остановки сообщения
+// коммитятся, и только затем reader закрывается.
+func (c *Consumer) consumeConcurrently(ctx context.Context, processFunc func(context.Context, *models.Order) error) error {
+	offsets := newOffsetTracker()
+	// Коммит сообщений, обработанных во время остановки, не должен прерываться отменой ctx
+	commitCtx := context.WithoutCancel(ctx)
+
+	queues := make([]chan kafka.Message, c.concurrency)
+	var wg sync.WaitGroup
+	for i := range queues {
+		queues[i] = make(chan kafka.Message, workerQueueSize)
+		wg.Add(1)
+		go func(worker string, queue <-chan kafka.Message) {
+			defer wg.Done()
+			for msg := range queue {
+				startTime := time.Now()
+				delivered := c.deliver(ctx, msg, processFunc)
+				c.metrics.WorkerProcessingTime.WithLabelValues(worker).Observe(time.Since(startTime).Seconds())
+				c.metrics.ConsumerInFlight.Dec()
+				if !delivered {
+					// Остановка до доставки: граница партиции не сдвигается, сообщение будет прочитано снова
+					continue
 				}
-				continue
+
+				offsets.done(msg, func(commit kafka.Message) {
+					if err := c.reader.CommitMessages(commitCtx, commit); err != nil {
+						log.Printf("Ошибка commit сообщения: %v", err)
+					}
+				})
 			}
-			c.metrics.MessageProcessingTime.Observe(time.Since(startTime).Seconds())
+		}(strconv.Itoa(i), queues[i])
+	}
 
-			// Подтверждаем обработку сообщения
-			if err := c.reader.CommitMessages(ctx, msg); err != nil {
-				log.Printf("Ошибка commit сообщения: %v", err)
+	for ctx.Err() == nil {
+		msg, ok := c.fetch(ctx)
+		if !ok {
+			continue
+		}
+		offsets.track(msg)
+		c.metrics.ConsumerInFlight.Inc()
+		select {
+		case queues[workerFor(msg, len(queues))] <- msg:
+		case <-ctx.Done():
+			// Сообщение не обработано и не закоммичено: после перезапуска оно будет прочитано снова
+			c.metrics.ConsumerInFlight.Dec()
+		}
+	}
+
+	for _, queue := range queues {
+		close(queue)
+	}
+	wg.Wait()
+	return c.reader.Close()
+}
+
+// fetch получает следующее сообщение; false — ошибка получения или отмена ctx
+func (c *Consumer) fetch(ctx context.Context) (kafka.Message, bool) {
+	msg, err := c.reader.FetchMessage(ctx)
+	if err != nil {
+		// Если контекст отменен, это не ошибка получения
+		if ctx.Err() == nil {
+			c.metrics.FailedReceivesTotal.Inc()
+			log.Printf("Ошибка при получении сообщения: %v", err)
+		}
+		return kafka.Message{}, false
+	}
+	c.metrics.MessagesReceivedTotal.Inc()
+	c.metrics.TopicMessagesTotal.WithLabelValues(c.topic, "received").Inc()
+	return msg, true
+}
+
+// messageResult итог обработки сообщения с заказом
+type messageResult struct {
+	orderUID string
+	attempts int    // Попытки обработки заказа (0 — сообщение не разобрано или не прошло валидацию)
+	err      error  // Ошибка последней попытки; nil — заказ обработан
+	cause    string // Причина ошибки для лога
+}
+
+// processMessage декодирует, валидирует и обрабатывает сообщение. Обработка заказа повторяется
+// по policy; ошибки версии схемы, формата, декодирования, валидации и данных (dlqReason — bad_data)
+// не повторяются. Формат тела — по заголовку content_type, без заголовка — codec.
+// trace_id сообщения передается processFunc в контексте (TraceIDFromContext) и пишется в логи.
+// Отмена ctx прерывает обработку и повторы.
+func processMessage(ctx context.Context, msg kafka.Message, codec Codec, processFunc func(context.Context, *models.Order) error, policy retry.Policy, metrics *KafkaMetrics) messageResult {
+	// Сообщения неизвестной major-версии схемы не разбираем
+	if err := checkSchema(msg.Headers); err != nil {
+		metrics.ProcessingErrorsTotal.Inc()
+		log.Printf("Сообщение %s пропущено: %v", messageRef(string(msg.Key), msg.Headers), err)
+		return messageResult{orderUID: string(msg.Key), err: err, cause: "неподдерживаемой версии схемы"}
+	}
+	ctx = WithTraceID(ctx, headerValue(msg.Headers, HeaderTraceID))
+
+	// Декодируем сообщение в структуру заказа
+	order, err := decodeOrder(msg.Headers, msg.Value, codec)
+	if err != nil {
+		metrics.ProcessingErrorsTotal.Inc()
+		log.Printf("Ошибка дешифровки сообщения %s: %v", messageRef(string(msg.Key), msg.Headers), err)
+		cause := "ошибки декодирования"
+		if errors.Is(err, ErrUnsupportedContentType) {
+			cause = "неподдерживаемого формата"
+		}
+		return messageResult{orderUID: string(msg.Key), err: err, cause: cause}
+	}
+
+	// Валидация полезной нагрузки
+	if err := order.Validate(); err != nil {
+		metrics.ProcessingErrorsTotal.Inc()
+		log.Printf("Невалидный заказ %v: %v", messageRef(order.OrderUID, msg.Headers), err)
+		return messageResult{orderUID: order.OrderUID, err: err, cause: "ошибки валидации"}
+	}
+
+	// Обрабатываем заказ через переданную функцию
+	res := messageResult{orderUID: order.OrderUID, cause: "ошибки обработки"}
+	res.err = retry.DoWithContext(ctx, policy, func(ctx context.Context) error {
+		res.attempts++
+		if res.attempts > 1 {
+			metrics.RetryAttemptsTotal.Inc()
+		}
+		startTime := time.Now()
+		err := processFunc(ctx, order)
+		metrics.MessageProcessingTime.Observe(time.Since(startTime).Seconds())
+		if err == nil {
+			return nil
+		}
+		metrics.ProcessingErrorsTotal.Inc()
+		log.Printf("Ошибка обработки заказа %s (попытка %d): %v", messageRef(order.OrderUID, msg.Headers), res.attempts, err)
+		if dlqReason(err) == DLQReasonBadData {
+			return retry.Permanent(err)
+		}
+		return err
+	})
+	return res
+}
+
+// handleMessage обрабатывает сообщение (processMessage); повтор обработанного сообщения
+// (SetDedup) пропускается. Заказ, не обработанный из-за сбоя,
+// отправляется в топик повторов (SetRetry), если он задан; остальные ошибки — в DLQ с числом
+// попыток. Возвращает true, если заказ обработан или записан в топик повторов либо DLQ;
+// обработка, прерванная отменой ctx, никуда не отправляется. Коммит сообщения остается вызывающему.
+func (c *Consumer) handleMessage(ctx context.Context, msg kafka.Message, processFunc func(context.Context, *models.Order) error) bool {
+	if c.dedup.seen(msg) {
+		c.skipDuplicate(msg)
+		return true
+	}
+	res := processMessage(ctx, msg, c.codec, processFunc, c.retryPolicy, c.metrics)
+	if res.err == nil {
+		c.dedup.remember(msg)
+		c.metrics.TopicMessagesTotal.WithLabelValues(c.topic, "processed").Inc()
+		return true
+	}
+	if ctx.Err() != nil {
+		log.Printf("Обработка заказа %s прервана остановкой: %v", res.orderUID, res.err)
+		return false
+	}
+	// Топик повторов и DLQ декодируют сообщение по заголовку, а не по настройке этого consumer
+	msg = withContentType(msg, c.codec)
+	if res.attempts > 0 {
+		c.metrics.FirstPassFailuresTotal.Inc()
+		if c.retry != nil && dlqReason(res.err) == DLQReasonProcessing {
+			err := c.retry.SendToRetry(msg, res.err, 1, res.attempts)
+			if err == nil {
+				log.Printf("Заказ %s отправлен в топик повторов", res.orderUID)
+				c.metrics.TopicMessagesTotal.WithLabelValues(c.topic, "retry").Inc()
+				return true
 			}
+			log.Printf("Ошибка отправки в топик повторов: %v", err)
 		}
 	}
+	if !sendToDLQ(c.dlq, c.topic, msg, res, c.metrics) {
+		return false
+	}
+	c.metrics.TopicMessagesTotal.WithLabelValues(c.topic, "dlq").Inc()
+	return true
 }
 
-// Close закрывает Kafka reader
+// sendToDLQ отправляет сообщение топика topic в DLQ, если DLQ настроена (dlq не nil).
+// Возвращает true, если запись в DLQ подтверждена.
+func sendToDLQ(dlq dlqSender, topic string, msg kafka.Message, res messageResult, metrics *KafkaMetrics) bool {
+	if dlq == nil {
+		return false
+	}
+	attempts := res.attempts
+	if attempts == 0 {
+		attempts = 1 // Сообщение не дошло до обработки заказа
+	}
+	// Партиция, смещение и заголовки исходного сообщения сохраняются для разбора DLQ
+	dlqMsg := msg
+	dlqMsg.Topic = topic
+	if dlqErr := dlq.SendToDLQ(dlqMsg, res.err, attempts); dlqErr != nil {
+		log.Printf("Ошибка отправки в DLQ: %v", dlqErr)
+		return false
+	}
+	metrics.DLQMessagesSentTotal.Inc()
+	log.Printf("Сообщение отправлено в DLQ из-за %s: %s", res.cause, res.orderUID)
+	return true
+}
+
+// Close останавливает публикацию отставания и статистики и закрывает Kafka reader
 func (c *Consumer) Close() error {
+	if c.lag != nil {
+		c.lag.stop()
+	}
+	c.metrics.Stats.remove(statsClientConsumer, c.topic, c.reader)
 	return c.reader.Close()
 }
+
+// workerFor возвращает номер обработчика сообщения: по хешу ключа, без ключа — по партиции,
+// чтобы сообщения одного ключа (и партиции без ключей) обрабатывались по порядку
+func workerFor(msg kafka.Message, workers int) int {
+	if len(msg.Key) == 0 {
+		return msg.Partition % workers
+	}
+	h := fnv.New32a()
+	_, _ = h.Write(msg.Key)
+	return int(h.Sum32() % uint32(workers))
+}
+
+// offsetTracker отслеживает обработку полученных сообщений по партициям: смещение партиции
+// коммитится, только когда обработаны все полученные до него сообщения этой партиции
+type offsetTracker struct {
+	mu         sync.Mutex
+	partitions map[int]*partitionOffsets
+}
+
+// partitionOffsets сообщения партиции, полученные, но еще не закоммиченные
+type partitionOffsets struct {
+	pending []kafka.Message // В порядке получения (возрастания смещений)
+	done    map[int64]bool  // Обработанные смещения из pending
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{partitions: make(map[int]*partitionOffsets)}
+}
+
+// track запоминает полученное сообщение; вызывается в порядке получения до передачи обработчику
+func (t *offsetTracker) track(msg kafka.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.partitions[msg.Partition]
+	if !ok {
+		p = &partitionOffsets{done: make(map[int64]bool)}
+		t.partitions[msg.Partition] = p
+	}
+	p.pending = append(p.pending, msg)
+}
+
+// done отмечает сообщение обработанным и, если граница обработанных сообщений партиции
+// сдвинулась, вызывает commit с последним сообщением до границы. commit вызывается под
+// блокировкой, поэтому смещения партиции коммитятся только по возрастанию.
+func (t *offsetTracker) done(msg kafka.Message, commit func(kafka.Message)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.partitions[msg.Partition]
+	if !ok {
+		return
+	}
+	p.done[msg.Offset] = true
+
+	n := 0
+	for n < len(p.pending) && p.done[p.pending[n].Offset] {
+		delete(p.done, p.pending[n].Offset)
+		n++
+	}
+	if n == 0 {
+		return
+	}
+	last := p.pending[n-1]
+	p.pending = p.pending[n:]
+	commit(last)
+}
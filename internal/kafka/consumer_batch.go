@@ -0,0 +1,158 @@
+package kafka
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"test_service/internal/models"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Причины сброса пакета (метка reason kafka_consumer_batch_flushes_total)
+const (
+	batchFlushSize    = "size"    // Набрано SetBatch size сообщений
+	batchFlushTimeout = "timeout" // Истек SetBatch timeout с первого сообщения пакета
+)
+
+// SetBatch включает пакетный режим: сообщения накапливаются, пока их не станет size или не
+// пройдет timeout с первого сообщения пакета. Заказы пакета передаются processBatch одним
+// вызовом (Service.ProcessOrders), и при успехе смещения всех сообщений пакета коммитятся
+// одним CommitMessages. Если processBatch вернул ошибку, сообщения пакета обрабатываются по
+// одному, как без пакетного режима (processFunc Consume, топик повторов, DLQ, SetDelivery),
+// чтобы один плохой заказ не мешал остальным. Сообщения, не прошедшие разбор или валидацию,
+// в пакет не попадают и сразу обрабатываются по одному. size < 2, timeout <= 0 или nil
+// processBatch — пакетный режим выключен. SetConcurrency в пакетном режиме не действует.
+// Вызывается до Consume.
+func (c *Consumer) SetBatch(size int, timeout time.Duration, processBatch func(context.Context, []*models.Order) error) {
+	if size < 2 || timeout <= 0 || processBatch == nil {
+		c.batchSize, c.batchTimeout, c.processBatch = 0, 0, nil
+		return
+	}
+	c.batchSize = size
+	c.batchTimeout = timeout
+	c.processBatch = processBatch
+}
+
+// consumeBatches цикл Consume в пакетном режиме. Пакет, не обработанный до отмены ctx,
+// не коммитится: после перезапуска его сообщения будут прочитаны снова.
+func (c *Consumer) consumeBatches(ctx context.Context, processFunc func(context.Context, *models.Order) error) error {
+	// Коммит пакета, обработанного до остановки, не должен прерываться отменой ctx
+	commitCtx := context.WithoutCancel(ctx)
+	for {
+		batch, reason := c.fetchBatch(ctx)
+		if ctx.Err() != nil {
+			return c.reader.Close()
+		}
+		c.metrics.ConsumerBatchSize.Observe(float64(len(batch)))
+		c.metrics.ConsumerBatchFlushesTotal.WithLabelValues(reason).Inc()
+
+		if !c.handleBatch(ctx, batch, processFunc) {
+			return c.reader.Close()
+		}
+
+		// Смещения всего пакета подтверждаются одним запросом
+		if err := c.reader.CommitMessages(commitCtx, batch...); err != nil {
+			log.Printf("Ошибка commit пакета из %d сообщений: %v", len(batch), err)
+		}
+	}
+}
+
+// fetchBatch получает пакет: первое сообщение ждется без ограничения, следующие — пока пакет
+// не наберет c.batchSize сообщений или не истечет c.batchTimeout. Возвращает пакет и причину
+// сброса; при отмене ctx пакет не нужен вызывающему.
+func (c *Consumer) fetchBatch(ctx context.Context) ([]kafka.Message, string) {
+	var batch []kafka.Message
+	for len(batch) == 0 {
+		msg, ok := c.fetch(ctx)
+		if ctx.Err() != nil {
+			return nil, ""
+		}
+		if ok {
+			batch = append(batch, msg)
+		}
+	}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, c.batchTimeout)
+	defer cancel()
+	for len(batch) < c.batchSize {
+		msg, ok := c.fetch(fetchCtx)
+		if !ok {
+			if fetchCtx.Err() != nil {
+				return batch, batchFlushTimeout
+			}
+			continue
+		}
+		batch = append(batch, msg)
+	}
+	return batch, batchFlushSize
+}
+
+// handleBatch обрабатывает пакет: разобранные заказы — одним вызовом c.processBatch, остальные
+// сообщения (и все сообщения пакета, если processBatch вернул ошибку) — по одному через deliver,
+// в порядке пакета. Повторы (SetDedup) пропускаются. false — ctx отменен раньше, чем пакет доставлен.
+func (c *Consumer) handleBatch(ctx context.Context, batch []kafka.Message, processFunc func(context.Context, *models.Order) error) bool {
+	orders := make([]*models.Order, 0, len(batch))
+	batched := make([]int, 0, len(batch)) // Позиции сообщений заказов orders в batch
+	single := make([]bool, len(batch))    // Сообщения, обрабатываемые по одному
+	for i, msg := range batch {
+		if c.dedup.seen(msg) {
+			c.skipDuplicate(msg)
+			continue
+		}
+		order, ok := decodeBatchOrder(msg, c.codec)
+		if !ok {
+			single[i] = true
+			continue
+		}
+		orders = append(orders, order)
+		batched = append(batched, i)
+	}
+
+	if len(orders) > 0 {
+		err := c.processBatch(ctx, orders)
+		switch {
+		case err == nil:
+			for _, i := range batched {
+				c.dedup.remember(batch[i])
+			}
+			c.metrics.TopicMessagesTotal.WithLabelValues(c.topic, "processed").Add(float64(len(orders)))
+			log.Printf("Пакет из %d заказов обработан", len(orders))
+		case ctx.Err() != nil:
+			log.Printf("Обработка пакета из %d заказов прервана остановкой: %v", len(orders), err)
+			return false
+		default:
+			c.metrics.ProcessingErrorsTotal.Inc()
+			c.metrics.ConsumerBatchFallbacksTotal.Inc()
+			log.Printf("Ошибка обработки пакета из %d заказов, обработка по одному: %v", len(orders), err)
+			for _, i := range batched {
+				single[i] = true
+			}
+		}
+	}
+
+	for i, msg := range batch {
+		if single[i] && !c.deliver(ctx, msg, processFunc) {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeBatchOrder разбирает заказ сообщения для пакета; false — сообщение с неподдерживаемой
+// версией схемы, ошибкой декодирования или невалидным заказом: его ошибку зафиксирует обработка
+// по одному. codec — формат сообщений без заголовка content_type.
+func decodeBatchOrder(msg kafka.Message, codec Codec) (*models.Order, bool) {
+	if checkSchema(msg.Headers) != nil {
+		return nil, false
+	}
+	order, err := decodeOrder(msg.Headers, msg.Value, codec)
+	if err != nil {
+		return nil, false
+	}
+	if err := order.Validate(); err != nil {
+		return nil, false
+	}
+	return order, true
+}
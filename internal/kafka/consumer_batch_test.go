@@ -0,0 +1,192 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"test_service/internal/models"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// batchCommitReader запоминает смещения каждого вызова CommitMessages
+type batchCommitReader struct {
+	*fakeConsumerReader
+	commitsMu sync.Mutex
+	commits   [][]int64
+}
+
+func (r *batchCommitReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	offsets := make([]int64, 0, len(msgs))
+	for _, msg := range msgs {
+		offsets = append(offsets, msg.Offset)
+	}
+	r.commitsMu.Lock()
+	r.commits = append(r.commits, offsets)
+	r.commitsMu.Unlock()
+	return r.fakeConsumerReader.CommitMessages(ctx, msgs...)
+}
+
+func (r *batchCommitReader) commitCalls() [][]int64 {
+	r.commitsMu.Lock()
+	defer r.commitsMu.Unlock()
+	return append([][]int64(nil), r.commits...)
+}
+
+// orderBatches потокобезопасный список UID заказов каждого вызова пакетной обработки
+type orderBatches struct {
+	mu      sync.Mutex
+	batches [][]string
+	err     error // Ошибка каждого вызова
+}
+
+func (b *orderBatches) process(_ context.Context, orders []*models.Order) error {
+	uids := make([]string, 0, len(orders))
+	for _, order := range orders {
+		uids = append(uids, order.OrderUID)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.batches = append(b.batches, uids)
+	return b.err
+}
+
+func (b *orderBatches) list() [][]string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([][]string(nil), b.batches...)
+}
+
+// newBatchConsumer consumer топика messages в пакетном режиме
+func newBatchConsumer(messages []kafka.Message, size int, timeout time.Duration, batches *orderBatches) (*Consumer, *batchCommitReader) {
+	reader := &batchCommitReader{fakeConsumerReader: newFakeConsumerReader(messages)}
+	c := newConsumer(reader, "orders-batch")
+	c.SetMaxRetry(1)
+	c.SetBatch(size, timeout, batches.process)
+	return c, reader
+}
+
+// runBatchConsumer запускает Consume до выполнения until и останавливает consumer
+func runBatchConsumer(t *testing.T, c *Consumer, process func(context.Context, *models.Order) error, until func() bool) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- c.Consume(ctx, process) }()
+	require.Eventually(t, until, time.Second, time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestConsumer_Batch(t *testing.T) {
+	metrics := NewKafkaMetrics()
+	uid := func(index int) string { return GenerateTestOrder(index).OrderUID }
+	unexpected := func(_ context.Context, order *models.Order) error {
+		t.Errorf("заказ %s не должен обрабатываться по одному", order.OrderUID)
+		return nil
+	}
+
+	t.Run("FlushOnSize", func(t *testing.T) {
+		var batches orderBatches
+		c, reader := newBatchConsumer([]kafka.Message{
+			orderMessage(t, 1, 0, 0), orderMessage(t, 2, 0, 1), orderMessage(t, 3, 0, 2), orderMessage(t, 4, 0, 3),
+		}, 2, time.Minute, &batches)
+		flushesBefore := testutil.ToFloat64(metrics.ConsumerBatchFlushesTotal.WithLabelValues(batchFlushSize))
+		sizesBefore := histogramCount(t, metrics.ConsumerBatchSize)
+
+		runBatchConsumer(t, c, unexpected, func() bool { return len(reader.commitCalls()) == 2 })
+
+		assert.Equal(t, [][]string{{uid(1), uid(2)}, {uid(3), uid(4)}}, batches.list())
+		assert.Equal(t, [][]int64{{0, 1}, {2, 3}}, reader.commitCalls(), "пакет коммитится одним вызовом")
+		assert.Equal(t, flushesBefore+2, testutil.ToFloat64(metrics.ConsumerBatchFlushesTotal.WithLabelValues(batchFlushSize)))
+		assert.Equal(t, sizesBefore+2, histogramCount(t, metrics.ConsumerBatchSize))
+	})
+
+	t.Run("FlushOnTimeout", func(t *testing.T) {
+		var batches orderBatches
+		c, reader := newBatchConsumer([]kafka.Message{
+			orderMessage(t, 1, 0, 0), orderMessage(t, 2, 0, 1), orderMessage(t, 3, 0, 2),
+		}, 10, 20*time.Millisecond, &batches)
+		flushesBefore := testutil.ToFloat64(metrics.ConsumerBatchFlushesTotal.WithLabelValues(batchFlushTimeout))
+
+		// Больше сообщений нет: неполный пакет сбрасывается по таймауту
+		runBatchConsumer(t, c, unexpected, func() bool { return len(reader.commitCalls()) == 1 })
+
+		assert.Equal(t, [][]string{{uid(1), uid(2), uid(3)}}, batches.list())
+		assert.Equal(t, [][]int64{{0, 1, 2}}, reader.commitCalls())
+		assert.Equal(t, flushesBefore+1, testutil.ToFloat64(metrics.ConsumerBatchFlushesTotal.WithLabelValues(batchFlushTimeout)))
+	})
+
+	t.Run("FallbackToSingleMessages", func(t *testing.T) {
+		batches := orderBatches{err: errors.New("duplicate chrt_id")}
+		c, reader := newBatchConsumer([]kafka.Message{
+			orderMessage(t, 1, 0, 0), orderMessage(t, 2, 0, 1), orderMessage(t, 3, 0, 2),
+		}, 3, time.Minute, &batches)
+		dlq := &flakyDLQSender{}
+		c.dlq = dlq
+		fallbacksBefore := testutil.ToFloat64(metrics.ConsumerBatchFallbacksTotal)
+
+		// Один плохой заказ не мешает обработать остальные заказы пакета
+		var processed processedOrders
+		process := func(ctx context.Context, order *models.Order) error {
+			if order.OrderUID == uid(2) {
+				return errors.New("bad order")
+			}
+			return processed.process(ctx, order)
+		}
+		runBatchConsumer(t, c, process, func() bool { return len(reader.commitCalls()) == 1 })
+
+		assert.Len(t, batches.list(), 1)
+		assert.Equal(t, []string{uid(1), uid(3)}, processed.list())
+		_, sent := dlq.counts()
+		assert.Equal(t, 1, sent, "плохой заказ уходит в DLQ")
+		assert.Equal(t, [][]int64{{0, 1, 2}}, reader.commitCalls())
+		assert.Equal(t, fallbacksBefore+1, testutil.ToFloat64(metrics.ConsumerBatchFallbacksTotal))
+	})
+
+	t.Run("InvalidMessageNotBatched", func(t *testing.T) {
+		var batches orderBatches
+		c, reader := newBatchConsumer([]kafka.Message{
+			orderMessage(t, 1, 0, 0), {Value: []byte("{broken"), Offset: 1}, orderMessage(t, 2, 0, 2),
+		}, 3, time.Minute, &batches)
+		dlq := &flakyDLQSender{}
+		c.dlq = dlq
+
+		runBatchConsumer(t, c, unexpected, func() bool { return len(reader.commitCalls()) == 1 })
+
+		assert.Equal(t, [][]string{{uid(1), uid(2)}}, batches.list())
+		_, sent := dlq.counts()
+		assert.Equal(t, 1, sent, "неразобранное сообщение уходит в DLQ")
+		assert.Equal(t, [][]int64{{0, 1, 2}}, reader.commitCalls())
+	})
+
+	t.Run("StopDuringBatchNotCommitted", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		reader := &batchCommitReader{fakeConsumerReader: newFakeConsumerReader([]kafka.Message{
+			orderMessage(t, 1, 0, 0), orderMessage(t, 2, 0, 1),
+		})}
+		c := newConsumer(reader, "orders-batch")
+		c.SetBatch(2, time.Minute, func(ctx context.Context, _ []*models.Order) error {
+			cancel()
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+		require.NoError(t, c.Consume(ctx, unexpected))
+		assert.Empty(t, reader.commitCalls(), "прерванный пакет будет прочитан снова")
+		assert.True(t, reader.isClosed())
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		c := newConsumer(newFakeConsumerReader(nil), "orders-batch")
+		c.SetBatch(1, time.Second, func(context.Context, []*models.Order) error { return nil })
+		assert.Nil(t, c.processBatch, "пакет из одного сообщения — обычный режим")
+		c.SetBatch(10, 0, func(context.Context, []*models.Order) error { return nil })
+		assert.Nil(t, c.processBatch)
+	})
+}
@@ -0,0 +1,458 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"test_service/internal/database"
+	"test_service/internal/mocks"
+	"test_service/internal/models"
+
+	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeConsumerReader читатель топика с фиксированным набором сообщений; безопасен для
+// параллельных коммитов из обработчиков
+type fakeConsumerReader struct {
+	mu        sync.Mutex
+	messages  []kafka.Message
+	next      int
+	committed map[int][]int64 // Закоммиченные смещения по партициям в порядке коммитов
+	commitErr error           // Ошибка всех коммитов (смещения не запоминаются)
+	closed    bool
+}
+
+func newFakeConsumerReader(messages []kafka.Message) *fakeConsumerReader {
+	return &fakeConsumerReader{messages: messages, committed: make(map[int][]int64)}
+}
+
+func (f *fakeConsumerReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	f.mu.Lock()
+	if f.next >= len(f.messages) {
+		f.mu.Unlock()
+		<-ctx.Done() // Как настоящий читатель: ждет новых сообщений до отмены
+		return kafka.Message{}, ctx.Err()
+	}
+	msg := f.messages[f.next]
+	f.next++
+	f.mu.Unlock()
+	return msg, nil
+}
+
+func (f *fakeConsumerReader) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return errors.New("reader closed")
+	}
+	if f.commitErr != nil {
+		return f.commitErr
+	}
+	for _, msg := range msgs {
+		f.committed[msg.Partition] = append(f.committed[msg.Partition], msg.Offset)
+	}
+	return nil
+}
+
+func (f *fakeConsumerReader) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	return nil
+}
+
+// fetched количество выданных сообщений
+func (f *fakeConsumerReader) fetched() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.next
+}
+
+// lastCommitted последнее закоммиченное смещение партиции (-1 — коммитов не было)
+func (f *fakeConsumerReader) lastCommitted(partition int) int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	offsets := f.committed[partition]
+	if len(offsets) == 0 {
+		return -1
+	}
+	return offsets[len(offsets)-1]
+}
+
+// orderMessage сообщение с валидным заказом GenerateTestOrder(index); ключ — UID заказа, как у Producer
+func orderMessage(t *testing.T, index, partition int, offset int64) kafka.Message {
+	order := GenerateTestOrder(index)
+	value, err := json.Marshal(order)
+	require.NoError(t, err)
+	return kafka.Message{Key: []byte(order.OrderUID), Value: value, Partition: partition, Offset: offset}
+}
+
+func TestOffsetTracker(t *testing.T) {
+	tracker := newOffsetTracker()
+	msgs := make([]kafka.Message, 4)
+	for i := range msgs {
+		// Смещения партиции могут идти с пропусками (например, после compaction)
+		msgs[i] = kafka.Message{Partition: 1, Offset: int64(10 + 2*i)}
+		tracker.track(msgs[i])
+	}
+	other := kafka.Message{Partition: 2, Offset: 5}
+	tracker.track(other)
+
+	var committed []int64
+	commit := func(msg kafka.Message) { committed = append(committed, msg.Offset) }
+
+	// Обработанные раньше предыдущих сообщения не коммитятся
+	tracker.done(msgs[2], commit)
+	tracker.done(msgs[1], commit)
+	assert.Empty(t, committed)
+
+	// Граница сдвигается сразу через все обработанные сообщения
+	tracker.done(msgs[0], commit)
+	assert.Equal(t, []int64{14}, committed)
+	tracker.done(msgs[3], commit)
+	assert.Equal(t, []int64{14, 16}, committed)
+
+	// Партиции независимы
+	tracker.done(other, commit)
+	assert.Equal(t, []int64{14, 16, 5}, committed)
+}
+
+func TestWorkerFor(t *testing.T) {
+	// Сообщения одного заказа всегда попадают к одному обработчику
+	for i := 0; i < 10; i++ {
+		key := []byte(fmt.Sprintf("order-%d", i))
+		first := workerFor(kafka.Message{Key: key, Partition: 0}, 4)
+		assert.Equal(t, first, workerFor(kafka.Message{Key: key, Partition: 3}, 4))
+		assert.True(t, first >= 0 && first < 4)
+	}
+	// Без ключа — по партиции
+	assert.Equal(t, 2, workerFor(kafka.Message{Partition: 6}, 4))
+}
+
+func TestConsumer_ConsumeConcurrently(t *testing.T) {
+	// Две партиции, по три заказа в каждой и по два сообщения на заказ
+	var messages []kafka.Message
+	for partition := 0; partition < 2; partition++ {
+		for i := 0; i < 6; i++ {
+			messages = append(messages, orderMessage(t, partition*3+i%3, partition, int64(i)))
+		}
+	}
+	reader := newFakeConsumerReader(messages)
+	c := newConsumer(reader, "orders")
+	c.SetConcurrency(4)
+
+	var mu sync.Mutex
+	seen := make(map[string][]int) // Порядок обработки сообщений заказа (номер версии)
+	versions := make(map[string]int)
+	process := func(_ context.Context, order *models.Order) error {
+		mu.Lock()
+		versions[order.OrderUID]++
+		version := versions[order.OrderUID]
+		mu.Unlock()
+		time.Sleep(time.Millisecond) // Обработчики работают одновременно
+		mu.Lock()
+		seen[order.OrderUID] = append(seen[order.OrderUID], version)
+		mu.Unlock()
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- c.Consume(ctx, process) }()
+
+	require.Eventually(t, func() bool {
+		return reader.lastCommitted(0) == 5 && reader.lastCommitted(1) == 5
+	}, time.Second, time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, seen, 6)
+	for uid, order := range seen {
+		assert.Equal(t, []int{1, 2}, order, "сообщения заказа %s обработаны по порядку", uid)
+	}
+	reader.mu.Lock()
+	defer reader.mu.Unlock()
+	for partition, offsets := range reader.committed {
+		assert.IsIncreasing(t, offsets, "смещения партиции %d коммитятся по возрастанию", partition)
+	}
+	assert.True(t, reader.closed)
+}
+
+func TestConsumer_ConsumeConcurrentlyShutdown(t *testing.T) {
+	// runUntilShutdown отменяет Consume во время обработки единственного сообщения
+	runUntilShutdown := func(t *testing.T, process func(ctx context.Context, started chan<- struct{}) error) (*fakeConsumerReader, *fakeDLQSender) {
+		reader := newFakeConsumerReader([]kafka.Message{orderMessage(t, 1, 0, 0)})
+		c := newConsumer(reader, "orders")
+		c.SetConcurrency(2)
+		dlq := &fakeDLQSender{}
+		c.dlq = dlq
+
+		started := make(chan struct{})
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() {
+			done <- c.Consume(ctx, func(ctx context.Context, _ *models.Order) error { return process(ctx, started) })
+		}()
+
+		<-started
+		cancel()
+		require.NoError(t, <-done)
+		assert.True(t, reader.closed)
+		return reader, dlq
+	}
+
+	t.Run("InterruptedNotCommitted", func(t *testing.T) {
+		reader, dlq := runUntilShutdown(t, func(ctx context.Context, started chan<- struct{}) error {
+			close(started)
+			<-ctx.Done() // Как запрос к БД: отмена ctx прерывает сохранение
+			return ctx.Err()
+		})
+
+		// Прерванное сообщение не закоммичено и не отправлено в DLQ: после перезапуска оно будет прочитано снова
+		assert.Equal(t, int64(-1), reader.lastCommitted(0))
+		assert.Empty(t, dlq.sent)
+	})
+
+	t.Run("FinishedCommitted", func(t *testing.T) {
+		reader, _ := runUntilShutdown(t, func(_ context.Context, started chan<- struct{}) error {
+			close(started)
+			time.Sleep(20 * time.Millisecond) // Обработка завершается уже после отмены
+			return nil
+		})
+
+		// Обработанное во время остановки сообщение коммитится до закрытия reader
+		assert.Equal(t, int64(0), reader.lastCommitted(0))
+	})
+}
+
+func TestConsumer_MessageContext(t *testing.T) {
+	type traceKey struct{}
+	reader := newFakeConsumerReader([]kafka.Message{orderMessage(t, 1, 0, 0)})
+	reader.messages[0].Headers = []kafka.Header{{Key: "trace_id", Value: []byte("abc")}}
+	c := newConsumer(reader, "orders")
+	c.SetMessageContext(func(ctx context.Context, msg kafka.Message) context.Context {
+		for _, h := range msg.Headers {
+			if h.Key == "trace_id" {
+				return context.WithValue(ctx, traceKey{}, string(h.Value))
+			}
+		}
+		return ctx
+	})
+
+	var traceID any
+	consumeAll(t, c, reader, 0, func(ctx context.Context, _ *models.Order) error {
+		traceID = ctx.Value(traceKey{})
+		return nil
+	})
+	assert.Equal(t, "abc", traceID, "значения контекста сообщения доходят до processFunc")
+}
+
+func TestConsumer_ConsumeSequential(t *testing.T) {
+	reader := newFakeConsumerReader([]kafka.Message{
+		orderMessage(t, 1, 0, 0),
+		{Value: []byte("not json"), Partition: 0, Offset: 1},
+		orderMessage(t, 2, 0, 2),
+	})
+	c := newConsumer(reader, "orders")
+	sender := &fakeDLQSender{}
+	c.dlq = sender
+
+	var processed []int
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Consume(ctx, func(_ context.Context, order *models.Order) error {
+			processed = append(processed, order.SMID)
+			return nil
+		})
+	}()
+
+	require.Eventually(t, func() bool { return reader.lastCommitted(0) == 2 }, time.Second, time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+
+	assert.Equal(t, []int{GenerateTestOrder(1).SMID, GenerateTestOrder(2).SMID}, processed)
+	reader.mu.Lock()
+	assert.Equal(t, []int64{0, 1, 2}, reader.committed[0])
+	reader.mu.Unlock()
+	require.Len(t, sender.sent, 1)
+	assert.Equal(t, "orders", sender.sent[0].Topic)
+}
+
+// consumeAll обрабатывает сообщения читателя до коммита смещения lastOffset партиции 0
+func consumeAll(t *testing.T, c *Consumer, reader *fakeConsumerReader, lastOffset int64, process func(context.Context, *models.Order) error) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- c.Consume(ctx, process) }()
+
+	require.Eventually(t, func() bool { return reader.lastCommitted(0) == lastOffset }, time.Second, time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestConsumer_ProcessRetries(t *testing.T) {
+	// newRetryConsumer consumer с одним сообщением, быстрыми повторами и DLQ
+	newRetryConsumer := func(t *testing.T) (*Consumer, *fakeConsumerReader, *fakeDLQSender) {
+		reader := newFakeConsumerReader([]kafka.Message{orderMessage(t, 1, 0, 0)})
+		c := newConsumer(reader, "orders")
+		fastRetries(&c.retryPolicy)
+		c.SetMaxRetry(3)
+		sender := &fakeDLQSender{}
+		c.dlq = sender
+		return c, reader, sender
+	}
+
+	t.Run("LaterAttemptSucceeds", func(t *testing.T) {
+		c, reader, sender := newRetryConsumer(t)
+		retriesBefore := testutil.ToFloat64(c.metrics.RetryAttemptsTotal)
+
+		calls := 0
+		consumeAll(t, c, reader, 0, func(context.Context, *models.Order) error {
+			calls++
+			if calls < 2 {
+				return errors.New("db down")
+			}
+			return nil
+		})
+
+		assert.Equal(t, 2, calls)
+		assert.Empty(t, sender.sent, "после успешного повтора сообщение не уходит в DLQ")
+		assert.Equal(t, 1.0, testutil.ToFloat64(c.metrics.RetryAttemptsTotal)-retriesBefore)
+	})
+
+	t.Run("AttemptsExhausted", func(t *testing.T) {
+		c, reader, sender := newRetryConsumer(t)
+
+		calls := 0
+		consumeAll(t, c, reader, 0, func(context.Context, *models.Order) error {
+			calls++
+			return errors.New("db down")
+		})
+
+		assert.Equal(t, 3, calls)
+		require.Len(t, sender.sent, 1)
+		assert.Equal(t, []int{3}, sender.attempts, "в DLQ записано фактическое число попыток")
+	})
+
+	t.Run("BadDataNotRetried", func(t *testing.T) {
+		c, reader, sender := newRetryConsumer(t)
+
+		calls := 0
+		consumeAll(t, c, reader, 0, func(context.Context, *models.Order) error {
+			calls++
+			return fmt.Errorf("save: %w", database.ErrConstraintViolation)
+		})
+
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, []int{1}, sender.attempts)
+	})
+
+	t.Run("InvalidJSONNotProcessed", func(t *testing.T) {
+		reader := newFakeConsumerReader([]kafka.Message{{Value: []byte("{"), Partition: 0, Offset: 0}})
+		c := newConsumer(reader, "orders")
+		sender := &fakeDLQSender{}
+		c.dlq = sender
+
+		consumeAll(t, c, reader, 0, func(context.Context, *models.Order) error {
+			t.Error("неразобранное сообщение не обрабатывается")
+			return nil
+		})
+		assert.Equal(t, []int{1}, sender.attempts)
+	})
+}
+
+func TestConsumer_Consume(t *testing.T) {
+	valid := orderMessage(t, 1, 0, 0)
+	invalidJSON := kafka.Message{Key: []byte("broken"), Value: []byte(`{"order_uid":`)}
+	invalidOrder := kafka.Message{Key: []byte("short"), Value: []byte(`{"order_uid":"short"}`)}
+
+	tests := []struct {
+		name        string
+		msg         kafka.Message
+		processErr  error
+		commitErr   error
+		wantCalls   int    // Вызовы processFunc
+		wantDLQ     int    // Число попыток в DLQ (0 — в DLQ не отправлено)
+		wantReason  string // dlqReason ошибки, отправленной в DLQ
+		wantCommits bool
+	}{
+		{name: "Processed", msg: valid, wantCalls: 1, wantCommits: true},
+		{name: "InvalidJSON", msg: invalidJSON, wantDLQ: 1, wantReason: DLQReasonBadData, wantCommits: true},
+		{name: "ValidationError", msg: invalidOrder, wantDLQ: 1, wantReason: DLQReasonBadData, wantCommits: true},
+		{name: "ProcessError", msg: valid, processErr: errors.New("db down"), wantCalls: 2, wantDLQ: 2, wantReason: DLQReasonProcessing, wantCommits: true},
+		{name: "CommitFailure", msg: valid, commitErr: errors.New("coordinator unavailable"), wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// После сообщения теста — контрольное: его обработка означает, что цикл продолжился
+			reader := newFakeConsumerReader([]kafka.Message{tt.msg, orderMessage(t, 2, 0, 1)})
+			reader.commitErr = tt.commitErr
+
+			dlq := mocks.NewMockDeadLetterSink(ctrl)
+			if tt.wantDLQ > 0 {
+				dlq.EXPECT().SendToDLQ(gomock.Any(), gomock.Any(), tt.wantDLQ).DoAndReturn(
+					func(msg kafka.Message, err error, _ int) error {
+						assert.Equal(t, "orders", msg.Topic)
+						assert.Equal(t, tt.msg.Value, msg.Value)
+						assert.Equal(t, tt.wantReason, dlqReason(err))
+						return nil
+					})
+			}
+
+			c := newConsumer(reader, "orders")
+			fastRetries(&c.retryPolicy)
+			c.SetMaxRetry(2)
+			c.dlq = dlq
+
+			var mu sync.Mutex
+			calls, control := 0, false
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error, 1)
+			go func() {
+				done <- c.Consume(ctx, func(_ context.Context, order *models.Order) error {
+					mu.Lock()
+					defer mu.Unlock()
+					if order.OrderUID == GenerateTestOrder(2).OrderUID {
+						control = true
+						return nil
+					}
+					calls++
+					return tt.processErr
+				})
+			}()
+
+			require.Eventually(t, func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return control
+			}, time.Second, time.Millisecond)
+			cancel()
+			require.NoError(t, <-done)
+
+			assert.Equal(t, tt.wantCalls, calls)
+			if tt.wantCommits {
+				reader.mu.Lock()
+				assert.Equal(t, []int64{0, 1}, reader.committed[0])
+				reader.mu.Unlock()
+			} else {
+				assert.Equal(t, int64(-1), reader.lastCommitted(0), "ошибка коммита не останавливает обработку")
+			}
+			assert.True(t, reader.closed)
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package kafka
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// DefaultDedupMaxEntries сколько обработанных заказов помнит окно подавления повторов по умолчанию
+const DefaultDedupMaxEntries = 100000
+
+// dedupWindow окно подавления повторов: для каждого UID заказа (ключ сообщения, как у Producer)
+// помнит хеш содержимого последнего обработанного сообщения в течение window с момента обработки.
+// При переполнении вытесняются давно обработанные заказы (LRU). nil — окно выключено.
+type dedupWindow struct {
+	mu         sync.Mutex
+	window     time.Duration
+	maxEntries int
+	entries    map[string]*list.Element // Значение элемента — *dedupEntry
+	lru        *list.List               // В начале недавно обработанные
+	now        func() time.Time
+}
+
+// dedupEntry последнее обработанное сообщение заказа
+type dedupEntry struct {
+	orderUID string
+	hash     [sha256.Size]byte
+	expires  time.Time
+}
+
+// newDedupWindow создает окно подавления повторов длительностью window на maxEntries заказов
+func newDedupWindow(window time.Duration, maxEntries int) *dedupWindow {
+	return &dedupWindow{
+		window:     window,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+		now:        time.Now,
+	}
+}
+
+// seen сообщает, что сообщение побайтно повторяет последнее обработанное в окне сообщение
+// того же заказа. Сообщения без ключа повторами не считаются.
+func (d *dedupWindow) seen(msg kafka.Message) bool {
+	if d == nil || len(msg.Key) == 0 {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	elem, ok := d.entries[string(msg.Key)]
+	if !ok {
+		return false
+	}
+	entry := elem.Value.(*dedupEntry)
+	if !d.now().Before(entry.expires) {
+		d.remove(elem)
+		return false
+	}
+	return entry.hash == sha256.Sum256(msg.Value)
+}
+
+// remember запоминает обработанное сообщение; измененное содержимое заказа заменяет прежнее
+func (d *dedupWindow) remember(msg kafka.Message) {
+	if d == nil || len(msg.Key) == 0 {
+		return
+	}
+	now := d.now()
+	hash := sha256.Sum256(msg.Value)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if elem, ok := d.entries[string(msg.Key)]; ok {
+		entry := elem.Value.(*dedupEntry)
+		entry.hash, entry.expires = hash, now.Add(d.window)
+		d.lru.MoveToFront(elem)
+	} else {
+		entry := &dedupEntry{orderUID: string(msg.Key), hash: hash, expires: now.Add(d.window)}
+		d.entries[entry.orderUID] = d.lru.PushFront(entry)
+	}
+
+	// В конце списка — самые старые записи: истекшие и сверх maxEntries удаляются
+	for back := d.lru.Back(); back != nil; back = d.lru.Back() {
+		if d.lru.Len() <= d.maxEntries && now.Before(back.Value.(*dedupEntry).expires) {
+			break
+		}
+		d.remove(back)
+	}
+}
+
+// remove удаляет запись; вызывается под d.mu
+func (d *dedupWindow) remove(elem *list.Element) {
+	delete(d.entries, elem.Value.(*dedupEntry).orderUID)
+	d.lru.Remove(elem)
+}
+
+// SetDedup включает подавление повторов: сообщение, побайтно совпадающее с последним
+// обработанным сообщением того же заказа (ключ — UID заказа) не позже window назад, коммитится
+// без обработки (kafka_duplicate_messages_skipped_total). Измененный заказ обрабатывается как
+// обычно. Окно помнит не больше maxEntries заказов (maxEntries < 1 — DefaultDedupMaxEntries);
+// window <= 0 — подавление выключено. Вызывается до Consume.
+func (c *Consumer) SetDedup(window time.Duration, maxEntries int) {
+	if window <= 0 {
+		c.dedup = nil
+		return
+	}
+	if maxEntries < 1 {
+		maxEntries = DefaultDedupMaxEntries
+	}
+	c.dedup = newDedupWindow(window, maxEntries)
+}
+
+// skipDuplicate учитывает сообщение, пропущенное как повтор
+func (c *Consumer) skipDuplicate(msg kafka.Message) {
+	c.metrics.DuplicatesSkippedTotal.Inc()
+	c.metrics.TopicMessagesTotal.WithLabelValues(c.topic, "duplicate").Inc()
+	log.Printf("Повтор сообщения %s пропущен: заказ с тем же содержимым уже обработан", messageRef(string(msg.Key), msg.Headers))
+}
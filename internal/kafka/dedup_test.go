@@ -0,0 +1,167 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"test_service/internal/models"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// size количество запомненных заказов
+func (d *dedupWindow) size() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.lru.Len()
+}
+
+// newTestDedupWindow окно с часами, которые тест сдвигает через *now
+func newTestDedupWindow(window time.Duration, maxEntries int) (*dedupWindow, *time.Time) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	d := newDedupWindow(window, maxEntries)
+	d.now = func() time.Time { return now }
+	return d, &now
+}
+
+func TestDedupWindow(t *testing.T) {
+	msg := kafka.Message{Key: []byte("order-1"), Value: []byte(`{"order_uid":"order-1","track_number":"A"}`)}
+
+	t.Run("IdenticalDuplicate", func(t *testing.T) {
+		d, _ := newTestDedupWindow(time.Minute, 10)
+		assert.False(t, d.seen(msg), "необработанное сообщение — не повтор")
+		d.remember(msg)
+
+		// Заголовки (trace_id) и смещение повтора могут отличаться — важно только содержимое
+		repeat := msg
+		repeat.Offset = 5
+		repeat.Headers = []kafka.Header{{Key: HeaderTraceID, Value: []byte("trace-2")}}
+		assert.True(t, d.seen(repeat))
+	})
+
+	t.Run("ModifiedDuplicate", func(t *testing.T) {
+		d, _ := newTestDedupWindow(time.Minute, 10)
+		d.remember(msg)
+
+		updated := kafka.Message{Key: msg.Key, Value: []byte(`{"order_uid":"order-1","track_number":"B"}`)}
+		assert.False(t, d.seen(updated), "измененный заказ обрабатывается")
+
+		// После обработки измененного заказа повтором считается уже он
+		d.remember(updated)
+		assert.True(t, d.seen(updated))
+		assert.False(t, d.seen(msg))
+	})
+
+	t.Run("WindowExpiry", func(t *testing.T) {
+		d, now := newTestDedupWindow(time.Minute, 10)
+		d.remember(msg)
+
+		*now = now.Add(59 * time.Second)
+		assert.True(t, d.seen(msg))
+		*now = now.Add(time.Second)
+		assert.False(t, d.seen(msg), "повтор после окна обрабатывается снова")
+		assert.Zero(t, d.size(), "истекшая запись удаляется")
+	})
+
+	t.Run("ExpiredEntriesDroppedOnRemember", func(t *testing.T) {
+		d, now := newTestDedupWindow(time.Minute, 10)
+		d.remember(kafka.Message{Key: []byte("order-old"), Value: []byte("1")})
+		*now = now.Add(2 * time.Minute)
+		d.remember(msg)
+		assert.Equal(t, 1, d.size())
+	})
+
+	t.Run("MaxEntriesEvictsOldest", func(t *testing.T) {
+		d, _ := newTestDedupWindow(time.Minute, 2)
+		first := kafka.Message{Key: []byte("order-a"), Value: []byte("a")}
+		second := kafka.Message{Key: []byte("order-b"), Value: []byte("b")}
+		d.remember(first)
+		d.remember(second)
+		d.remember(msg)
+
+		assert.Equal(t, 2, d.size())
+		assert.False(t, d.seen(first), "давно обработанный заказ вытеснен")
+		assert.True(t, d.seen(second))
+		assert.True(t, d.seen(msg))
+	})
+
+	t.Run("NoKey", func(t *testing.T) {
+		d, _ := newTestDedupWindow(time.Minute, 10)
+		noKey := kafka.Message{Value: msg.Value}
+		d.remember(noKey)
+		assert.False(t, d.seen(noKey), "без UID заказа повтор не определить")
+	})
+}
+
+func TestConsumer_Dedup(t *testing.T) {
+	metrics := NewKafkaMetrics()
+
+	// duplicates заказ, его побайтный повтор и измененная версия заказа
+	duplicates := func(index int) []kafka.Message {
+		order := GenerateTestOrder(index)
+		value := mustJSON(t, order)
+		order.CustomerID += "2"
+		key := []byte(order.OrderUID)
+		return []kafka.Message{
+			{Key: key, Value: value, Offset: 0},
+			{Key: key, Value: value, Offset: 1},
+			{Key: key, Value: mustJSON(t, order), Offset: 2},
+		}
+	}
+
+	t.Run("SkipsIdenticalRepeat", func(t *testing.T) {
+		reader := newFakeConsumerReader(duplicates(1))
+		c := newConsumer(reader, "orders-dedup")
+		c.SetDedup(time.Minute, 0)
+		skippedBefore := testutil.ToFloat64(metrics.DuplicatesSkippedTotal)
+
+		var processed processedOrders
+		consumeAll(t, c, reader, 2, processed.process)
+
+		uid := GenerateTestOrder(1).OrderUID
+		assert.Equal(t, []string{uid, uid}, processed.list(), "повтор пропущен, измененный заказ обработан")
+		assert.Equal(t, skippedBefore+1, testutil.ToFloat64(metrics.DuplicatesSkippedTotal))
+		assert.Equal(t, 1.0, testutil.ToFloat64(metrics.TopicMessagesTotal.WithLabelValues("orders-dedup", "duplicate")))
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		reader := newFakeConsumerReader(duplicates(2))
+		c := newConsumer(reader, "orders-no-dedup")
+		c.SetDedup(0, 10)
+
+		var processed processedOrders
+		consumeAll(t, c, reader, 2, processed.process)
+		assert.Len(t, processed.list(), 3)
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		var batches orderBatches
+		c, reader := newBatchConsumer(duplicates(3), 3, time.Minute, &batches)
+		c.SetDedup(time.Minute, 0)
+		// Заказ уже обработан до пакета
+		c.dedup.remember(reader.messages[0])
+		skippedBefore := testutil.ToFloat64(metrics.DuplicatesSkippedTotal)
+
+		runBatchConsumer(t, c, func(_ context.Context, order *models.Order) error {
+			t.Errorf("заказ %s не должен обрабатываться по одному", order.OrderUID)
+			return nil
+		}, func() bool { return len(reader.commitCalls()) == 1 })
+
+		assert.Equal(t, [][]string{{GenerateTestOrder(3).OrderUID}}, batches.list(), "в пакет попадает только измененный заказ")
+		assert.Equal(t, [][]int64{{0, 1, 2}}, reader.commitCalls(), "повторы коммитятся вместе с пакетом")
+		assert.Equal(t, skippedBefore+2, testutil.ToFloat64(metrics.DuplicatesSkippedTotal))
+	})
+}
+
+// mustJSON сериализует заказ для тела сообщения
+func mustJSON(t *testing.T, order *models.Order) []byte {
+	t.Helper()
+	value, err := json.Marshal(order)
+	require.NoError(t, err)
+	return value
+}
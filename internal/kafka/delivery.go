@@ -0,0 +1,87 @@
+package kafka
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"test_service/internal/models"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// DeliveryMode гарантия доставки сообщений Consumer
+type DeliveryMode string
+
+const (
+	// DeliveryAtMostOnce сообщение коммитится после обработки в любом случае, даже если заказ
+	// не обработан и не записан в DLQ (такое сообщение теряется)
+	DeliveryAtMostOnce DeliveryMode = "at_most_once"
+
+	// DeliveryAtLeastOnce сообщение коммитится, только когда заказ обработан или записан
+	// в топик повторов либо DLQ; иначе обрабатывается снова после задержки
+	DeliveryAtLeastOnce DeliveryMode = "at_least_once"
+)
+
+const (
+	// DefaultDeliveryBackoff задержка перед первой повторной доставкой сообщения
+	DefaultDeliveryBackoff = time.Second
+
+	// maxDeliveryBackoff предел задержки между повторными доставками (задержка удваивается)
+	maxDeliveryBackoff = time.Minute
+)
+
+// SetDelivery задает гарантию доставки сообщений. В режиме DeliveryAtLeastOnce сообщение,
+// которое не удалось ни обработать, ни записать в топик повторов или DLQ, не коммитится
+// и обрабатывается снова через backoff (задержка удваивается до минуты). После maxFailures
+// таких неудач подряд сообщение считается poison pill и коммитится без доставки, чтобы
+// не блокировать партицию (maxFailures <= 0 — без ограничения). Вызывается до Consume.
+func (c *Consumer) SetDelivery(mode DeliveryMode, backoff time.Duration, maxFailures int) {
+	if backoff <= 0 {
+		backoff = DefaultDeliveryBackoff
+	}
+	c.delivery = mode
+	c.deliveryBackoff = backoff
+	c.maxDeliveryFailures = maxFailures
+}
+
+// deliver обрабатывает сообщение (handleMessage) и сообщает, можно ли его коммитить.
+// false — ctx отменен раньше, чем сообщение доставлено: сообщение не коммитится и после
+// перезапуска будет прочитано снова.
+func (c *Consumer) deliver(ctx context.Context, msg kafka.Message, processFunc func(context.Context, *models.Order) error) bool {
+	if c.messageContext != nil {
+		ctx = c.messageContext(ctx, msg)
+	}
+	if ctx.Err() != nil {
+		return false // Остановка до начала обработки
+	}
+	backoff := c.deliveryBackoff
+	for failures := 1; ; failures++ {
+		if c.handleMessage(ctx, msg, processFunc) {
+			return true
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+		if c.delivery != DeliveryAtLeastOnce {
+			return true
+		}
+		if c.maxDeliveryFailures > 0 && failures >= c.maxDeliveryFailures {
+			c.metrics.PoisonMessagesTotal.Inc()
+			log.Printf("Сообщение %d/%d пропущено: не обработано и не записано в DLQ после %d попыток доставки",
+				msg.Partition, msg.Offset, failures)
+			return true
+		}
+
+		c.metrics.RedeliveriesTotal.Inc()
+		log.Printf("Сообщение %d/%d не доставлено (попытка %d), повтор через %s", msg.Partition, msg.Offset, failures, backoff)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		}
+		backoff = min(backoff*2, maxDeliveryBackoff)
+	}
+}
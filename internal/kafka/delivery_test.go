@@ -0,0 +1,172 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"test_service/internal/models"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyDLQSender DLQ, первые fails отправок в которую завершаются ошибкой (fails < 0 — все)
+type flakyDLQSender struct {
+	mu    sync.Mutex
+	fails int
+	calls int
+	sent  int
+}
+
+func (f *flakyDLQSender) SendToDLQ(kafka.Message, error, int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.fails < 0 || f.calls <= f.fails {
+		return errors.New("broker unavailable")
+	}
+	f.sent++
+	return nil
+}
+
+func (f *flakyDLQSender) counts() (calls, sent int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls, f.sent
+}
+
+// newDeliveryConsumer consumer с одной попыткой обработки и DLQ dlq в режиме mode
+func newDeliveryConsumer(messages []kafka.Message, dlq dlqSender, mode DeliveryMode, maxFailures int) (*Consumer, *fakeConsumerReader) {
+	reader := newFakeConsumerReader(messages)
+	c := newConsumer(reader, "orders")
+	c.SetMaxRetry(1)
+	c.SetDelivery(mode, time.Millisecond, maxFailures)
+	c.dlq = dlq
+	return c, reader
+}
+
+func TestConsumer_AtLeastOnce(t *testing.T) {
+	failing := func(context.Context, *models.Order) error { return errors.New("db down") }
+
+	t.Run("NothingDeliveredNotCommitted", func(t *testing.T) {
+		dlq := &flakyDLQSender{fails: -1}
+		c, reader := newDeliveryConsumer([]kafka.Message{orderMessage(t, 1, 0, 0)}, dlq, DeliveryAtLeastOnce, 0)
+		redeliveriesBefore := testutil.ToFloat64(c.metrics.RedeliveriesTotal)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- c.Consume(ctx, failing) }()
+
+		// Сообщение обрабатывается снова, пока ни обработка, ни DLQ не пройдут
+		require.Eventually(t, func() bool {
+			calls, _ := dlq.counts()
+			return calls >= 3
+		}, time.Second, time.Millisecond)
+		cancel()
+		require.NoError(t, <-done)
+
+		assert.Equal(t, int64(-1), reader.lastCommitted(0), "недоставленное сообщение не коммитится")
+		assert.GreaterOrEqual(t, testutil.ToFloat64(c.metrics.RedeliveriesTotal)-redeliveriesBefore, 2.0)
+		assert.True(t, reader.closed)
+	})
+
+	t.Run("CommittedAfterDLQRecovers", func(t *testing.T) {
+		dlq := &flakyDLQSender{fails: 2}
+		c, reader := newDeliveryConsumer([]kafka.Message{orderMessage(t, 1, 0, 0)}, dlq, DeliveryAtLeastOnce, 0)
+
+		consumeAll(t, c, reader, 0, failing)
+
+		calls, sent := dlq.counts()
+		assert.Equal(t, 3, calls)
+		assert.Equal(t, 1, sent, "сообщение записано в DLQ ровно один раз")
+	})
+
+	t.Run("CommittedAfterProcessingRecovers", func(t *testing.T) {
+		dlq := &flakyDLQSender{fails: -1}
+		c, reader := newDeliveryConsumer([]kafka.Message{orderMessage(t, 1, 0, 0)}, dlq, DeliveryAtLeastOnce, 0)
+
+		calls := 0
+		consumeAll(t, c, reader, 0, func(context.Context, *models.Order) error {
+			calls++
+			if calls < 3 {
+				return errors.New("db down")
+			}
+			return nil
+		})
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("PoisonPillSkipped", func(t *testing.T) {
+		dlq := &flakyDLQSender{fails: -1}
+		c, reader := newDeliveryConsumer([]kafka.Message{
+			orderMessage(t, 1, 0, 0),
+			orderMessage(t, 2, 0, 1),
+		}, dlq, DeliveryAtLeastOnce, 3)
+		poisonBefore := testutil.ToFloat64(c.metrics.PoisonMessagesTotal)
+
+		var processed []string
+		consumeAll(t, c, reader, 1, func(_ context.Context, order *models.Order) error {
+			processed = append(processed, order.OrderUID)
+			if order.OrderUID == GenerateTestOrder(1).OrderUID {
+				return errors.New("db down")
+			}
+			return nil
+		})
+
+		assert.Len(t, processed, 4, "три доставки первого сообщения, затем второе")
+		assert.Equal(t, 1.0, testutil.ToFloat64(c.metrics.PoisonMessagesTotal)-poisonBefore)
+	})
+
+	t.Run("ConcurrentHoldsPartition", func(t *testing.T) {
+		dlq := &flakyDLQSender{fails: -1}
+		c, reader := newDeliveryConsumer([]kafka.Message{
+			orderMessage(t, 1, 0, 0),
+			orderMessage(t, 2, 0, 1),
+		}, dlq, DeliveryAtLeastOnce, 0)
+		c.SetConcurrency(2)
+
+		var mu sync.Mutex
+		var second bool
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() {
+			done <- c.Consume(ctx, func(_ context.Context, order *models.Order) error {
+				if order.OrderUID == GenerateTestOrder(2).OrderUID {
+					mu.Lock()
+					second = true
+					mu.Unlock()
+					return nil
+				}
+				return errors.New("db down")
+			})
+		}()
+
+		require.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			calls, _ := dlq.counts()
+			return second && calls >= 2
+		}, time.Second, time.Millisecond)
+		cancel()
+		require.NoError(t, <-done)
+
+		// Обработанное второе сообщение не коммитится раньше недоставленного первого
+		assert.Equal(t, int64(-1), reader.lastCommitted(0))
+	})
+}
+
+func TestConsumer_AtMostOnceCommitsUndelivered(t *testing.T) {
+	dlq := &flakyDLQSender{fails: -1}
+	c, reader := newDeliveryConsumer([]kafka.Message{orderMessage(t, 1, 0, 0)}, dlq, DeliveryAtMostOnce, 0)
+
+	consumeAll(t, c, reader, 0, func(context.Context, *models.Order) error { return errors.New("db down") })
+
+	calls, sent := dlq.counts()
+	assert.Equal(t, 1, calls, "без повторной доставки")
+	assert.Zero(t, sent)
+}
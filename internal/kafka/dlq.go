@@ -4,67 +4,178 @@ package kafka
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 
+	"test_service/internal/database"
+	"test_service/internal/interfaces"
+
+	"github.com/go-playground/validator/v10"
 	"github.com/segmentio/kafka-go"
 )
 
+// Причины отправки в DLQ (DLQMessage.Reason)
+const (
+	DLQReasonBadData    = "bad_data"           // Сообщение не разобрано, не прошло валидацию или нарушило ограничение схемы БД: повтор не поможет без исправления данных
+	DLQReasonProcessing = "processing"         // Сбой обработки (БД, инфраструктура): повторная обработка может пройти
+	DLQReasonSchema     = "unsupported_schema" // Неизвестная major-версия схемы (schema_version): нужна новая версия consumer
+)
+
+// DLQTopic топик DLQ для топика заказов topic
+func DLQTopic(topic string) string {
+	return topic + "-dlq"
+}
+
 // DLQMessage представляет сообщение в DLQ с дополнительной информацией
 type DLQMessage struct {
-	OriginalMessage json.RawMessage `json:"original_message"` // Оригинальное сообщение
-	Error           string          `json:"error"`            // Ошибка, приведшая к отправке в DLQ
-	Timestamp       time.Time       `json:"timestamp"`        // Время отправки в DLQ
-	Topic           string          `json:"topic"`            // Изначальный топик
-	Key             string          `json:"key"`              // Ключ сообщения
-	Attempts        int             `json:"attempts"`         // Количество попыток обработки
+	OriginalMessage json.RawMessage   `json:"original_message,omitempty"`  // Оригинальное сообщение, если это JSON
+	OriginalPayload []byte            `json:"original_payload,omitempty"`  // Оригинальное сообщение в base64, если это не JSON (protobuf или поврежденный JSON)
+	Codec           string            `json:"codec,omitempty"`             // Формат (Codec.Name), которым не удалось декодировать исходное сообщение
+	Error           string            `json:"error"`                       // Ошибка, приведшая к отправке в DLQ
+	Timestamp       time.Time         `json:"timestamp"`                   // Время отправки в DLQ
+	Topic           string            `json:"topic"`                       // Изначальный топик
+	Key             string            `json:"key"`                         // Ключ сообщения
+	Partition       int               `json:"partition"`                   // Партиция прочитанного сообщения (из топика повторов — его партиция); в старых сообщениях DLQ — 0
+	Offset          int64             `json:"offset"`                      // Смещение прочитанного сообщения в той же партиции; в старых сообщениях DLQ — 0
+	Headers         map[string]string `json:"headers,omitempty"`           // Заголовки исходного сообщения; дублируются в заголовках сообщения DLQ
+	ConsumerGroup   string            `json:"consumer_group,omitempty"`    // Группа consumer, отправившего сообщение в DLQ
+	Attempts        int               `json:"attempts"`                    // Количество попыток обработки
+	Reason          string            `json:"reason,omitempty"`            // Причина: DLQReasonBadData, DLQReasonProcessing или DLQReasonSchema
+	LastReplayError string            `json:"last_replay_error,omitempty"` // Ошибка последней повторной обработки из DLQ
+}
+
+// source описание исходного сообщения для логов: топик, партиция/смещение, группа и trace_id
+func (m DLQMessage) source() string {
+	s := fmt.Sprintf("%s %d/%d", m.Topic, m.Partition, m.Offset)
+	if m.ConsumerGroup != "" {
+		s += ", группа " + m.ConsumerGroup
+	}
+	if traceID := m.Headers[HeaderTraceID]; traceID != "" {
+		s += ", trace_id " + traceID
+	}
+	return s
+}
+
+// original тело исходного сообщения
+func (m DLQMessage) original() []byte {
+	if m.OriginalPayload != nil {
+		return m.OriginalPayload
+	}
+	return m.OriginalMessage
+}
+
+// dlqReason отличает ошибки данных сообщения от сбоев обработки
+func dlqReason(err error) string {
+	var (
+		syntaxErr      *json.SyntaxError
+		typeErr        *json.UnmarshalTypeError
+		decodeErr      *DecodeError
+		validationErrs validator.ValidationErrors
+	)
+	if errors.Is(err, ErrUnsupportedSchema) || errors.Is(err, ErrUnsupportedContentType) {
+		return DLQReasonSchema
+	}
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) || errors.As(err, &decodeErr) || errors.As(err, &validationErrs) ||
+		errors.Is(err, database.ErrConstraintViolation) || errors.Is(err, database.ErrDuplicateItem) {
+		return DLQReasonBadData
+	}
+	return DLQReasonProcessing
+}
+
+// failedCodec формат, которым не удалось декодировать сообщение (пусто — ошибка не декодирования)
+func failedCodec(err error) string {
+	var decodeErr *DecodeError
+	if errors.As(err, &decodeErr) {
+		return decodeErr.Codec
+	}
+	return ""
+}
+
+// DLQProducer используется в main через interfaces.DeadLetterSink
+var _ interfaces.DeadLetterSink = (*DLQProducer)(nil)
+
+// dlqSender отправка сообщения в DLQ
+type dlqSender interface {
+	SendToDLQ(originalMsg kafka.Message, err error, attempts int) error
 }
 
 // DLQProducer для отправки сообщений в DLQ
 type DLQProducer struct {
-	writer  *kafka.Writer
+	writer  producerWriter
 	topic   string
+	group   string // Группа consumer для DLQMessage.ConsumerGroup (SetConsumerGroup)
 	metrics *KafkaMetrics
 }
 
-// NewDLQProducer создает новый DLQ producer
-func NewDLQProducer(brokers []string, dlqTopic string) *DLQProducer {
-	writer := &kafka.Writer{
-		Addr:                   kafka.TCP(brokers...),
-		Topic:                  dlqTopic,
-		Balancer:               &kafka.LeastBytes{},
-		WriteTimeout:           10 * time.Second,
-		ReadTimeout:            10 * time.Second,
-		RequiredAcks:           kafka.RequireAll,
-		MaxAttempts:            3,
-		AllowAutoTopicCreation: true,
-	}
+// NewDLQProducer создает новый DLQ producer с настройками писателя opts
+func NewDLQProducer(brokers []string, dlqTopic string, opts WriterOptions) *DLQProducer {
+	writer := newWriter(brokers, dlqTopic, opts)
+	writer.Balancer = &kafka.LeastBytes{}
+	metrics := NewKafkaMetrics()
+	metrics.Stats.addWriter(statsClientDLQ, dlqTopic, writer)
 	return &DLQProducer{
 		writer:  writer,
 		topic:   dlqTopic,
-		metrics: NewKafkaMetrics(),
+		metrics: metrics,
 	}
 }
 
-// SendToDLQ отправляет сообщение в DLQ
+// SetConsumerGroup задает группу consumer, которая записывается в DLQMessage.ConsumerGroup
+func (d *DLQProducer) SetConsumerGroup(group string) {
+	d.group = group
+}
+
+// SendToDLQ отправляет сообщение в DLQ; партиция, смещение и заголовки исходного сообщения
+// (trace_id, schema_version, content_type и др.) сохраняются. Тело сообщения в JSON попадает
+// в original_message, в другом формате или поврежденное — в original_payload.
 func (d *DLQProducer) SendToDLQ(originalMsg kafka.Message, err error, attempts int) error {
 	dlqMsg := DLQMessage{
-		OriginalMessage: originalMsg.Value,
-		Error:           err.Error(),
-		Timestamp:       time.Now(),
-		Topic:           originalMsg.Topic,
-		Key:             string(originalMsg.Key),
-		Attempts:        attempts,
+		Error:         err.Error(),
+		Timestamp:     time.Now(),
+		Topic:         originalMsg.Topic,
+		Key:           string(originalMsg.Key),
+		Partition:     originalMsg.Partition,
+		Offset:        originalMsg.Offset,
+		Headers:       headerMap(originalMsg.Headers),
+		ConsumerGroup: d.group,
+		Attempts:      attempts,
+		Reason:        dlqReason(err),
+		Codec:         failedCodec(err),
 	}
+	if headerValue(originalMsg.Headers, HeaderContentType) != ContentTypeProtobuf && json.Valid(originalMsg.Value) {
+		dlqMsg.OriginalMessage = originalMsg.Value
+	} else {
+		dlqMsg.OriginalPayload = originalMsg.Value
+	}
+	return d.send(dlqMsg)
+}
 
+// Requeue возвращает в DLQ сообщение, повторная обработка которого не удалась: исходная ошибка
+// сохраняется, ошибка повтора записывается в LastReplayError, счетчик попыток увеличивается
+func (d *DLQProducer) Requeue(dlqMsg DLQMessage, replayErr error) error {
+	dlqMsg.Timestamp = time.Now()
+	dlqMsg.Attempts++
+	dlqMsg.Reason = dlqReason(replayErr)
+	dlqMsg.Codec = failedCodec(replayErr)
+	dlqMsg.LastReplayError = replayErr.Error()
+	return d.send(dlqMsg)
+}
+
+// send публикует сообщение DLQ с ключом и заголовками исходного сообщения
+func (d *DLQProducer) send(dlqMsg DLQMessage) error {
 	msgJSON, jsonErr := json.Marshal(dlqMsg)
 	if jsonErr != nil {
 		return jsonErr
 	}
 
 	dlqKafkaMsg := kafka.Message{
-		Key:   originalMsg.Key,
-		Value: msgJSON,
-		Time:  time.Now(),
+		Value:   msgJSON,
+		Time:    time.Now(),
+		Headers: kafkaHeaders(dlqMsg.Headers),
+	}
+	if dlqMsg.Key != "" {
+		dlqKafkaMsg.Key = []byte(dlqMsg.Key)
 	}
 
 	sendErr := d.writer.WriteMessages(context.Background(), dlqKafkaMsg)
@@ -79,5 +190,6 @@ func (d *DLQProducer) SendToDLQ(originalMsg kafka.Message, err error, attempts i
 
 // Close закрывает DLQ producer
 func (d *DLQProducer) Close() error {
+	d.metrics.Stats.remove(statsClientDLQ, d.topic, d.writer)
 	return d.writer.Close()
 }
@@ -0,0 +1,194 @@
+// Package kafka содержит логику для работы с Apache Kafka, включая чтение и повторную обработку DLQ
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"test_service/internal/models"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// dlqIdleTimeout время ожидания следующего сообщения, после которого DLQ считается вычитанной.
+// Включает время вступления в группу потребителей при первом чтении.
+const dlqIdleTimeout = 10 * time.Second
+
+// dlqMessageReader минимальный набор методов читателя DLQ
+type dlqMessageReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// dlqRequeuer возврат сообщения в DLQ после неудачной повторной обработки
+type dlqRequeuer interface {
+	Requeue(dlqMsg DLQMessage, replayErr error) error
+}
+
+// DLQReplayOptions режим запуска DLQConsumer
+type DLQReplayOptions struct {
+	Max    int       // Не больше Max сообщений за запуск (<= 0 — без ограничения)
+	Until  time.Time // Только сообщения, отправленные в DLQ раньше Until (нулевое — раньше начала запуска)
+	DryRun bool      // Только подсчет: заказы не обрабатываются, смещения не коммитятся
+}
+
+// DLQReplaySummary итоги повторной обработки сообщений из DLQ
+type DLQReplaySummary struct {
+	Replayed int            `json:"replayed"`          // Успешно обработанные заказы
+	Failed   int            `json:"failed"`            // Заказы, снова не прошедшие валидацию или обработку (возвращены в DLQ)
+	Skipped  int            `json:"skipped"`           // Сообщения, которые не удалось разобрать как DLQMessage
+	Pending  int            `json:"pending,omitempty"` // Dry-run: сообщения, которые были бы обработаны
+	Reasons  map[string]int `json:"reasons,omitempty"` // Dry-run: число таких сообщений по причине отправки в DLQ
+}
+
+// DLQConsumer читает DLQ топика (DLQTopic) и передает исходные сообщения на повторную обработку
+type DLQConsumer struct {
+	newReader   func() dlqMessageReader // Создает читателя на время одного запуска
+	dlq         dlqRequeuer             // Возврат в DLQ сообщений, которые снова не удалось обработать
+	idleTimeout time.Duration           // Ожидание следующего сообщения перед завершением
+	metrics     *KafkaMetrics
+}
+
+// NewDLQConsumer создает DLQConsumer для топика topic с подключением conn. Смещения коммитятся
+// в группе groupID+"-dlq-replay", поэтому повторный запуск продолжает с места, где остановился предыдущий.
+func NewDLQConsumer(brokers []string, topic string, groupID string, conn Connection, dlqProducer *DLQProducer) *DLQConsumer {
+	return newDLQConsumer(func() dlqMessageReader {
+		return kafka.NewReader(kafka.ReaderConfig{
+			Brokers: brokers,
+			GroupID: groupID + "-dlq-replay",
+			Topic:   DLQTopic(topic),
+			Dialer:  conn.dialer,
+		})
+	}, dlqProducer)
+}
+
+// newDLQConsumer создает DLQConsumer с заданной фабрикой читателей
+func newDLQConsumer(newReader func() dlqMessageReader, dlq dlqRequeuer) *DLQConsumer {
+	return &DLQConsumer{
+		newReader:   newReader,
+		dlq:         dlq,
+		idleTimeout: dlqIdleTimeout,
+		metrics:     NewKafkaMetrics(),
+	}
+}
+
+// Replay обрабатывает сообщения из DLQ в режиме opts и возвращает итоги.
+// Чтение прекращается, когда DLQ вычитана, достигнут opts.Max, отменен ctx или встречено
+// сообщение, отправленное в DLQ не раньше opts.Until или начала запуска (в том числе
+// возвращенное этим же запуском). Заказы, снова не обработанные, возвращаются в DLQ
+// с увеличенным Attempts и ошибкой в LastReplayError.
+func (d *DLQConsumer) Replay(ctx context.Context, opts DLQReplayOptions, processFunc func(context.Context, *models.Order) error) (DLQReplaySummary, error) {
+	var summary DLQReplaySummary
+	until := time.Now()
+	if !opts.Until.IsZero() && opts.Until.Before(until) {
+		until = opts.Until
+	}
+
+	reader := d.newReader()
+	defer func() {
+		if err := reader.Close(); err != nil {
+			log.Printf("Ошибка при закрытии читателя DLQ: %v", err)
+		}
+	}()
+
+	for handled := 0; opts.Max <= 0 || handled < opts.Max; handled++ {
+		fetchCtx, cancel := context.WithTimeout(ctx, d.idleTimeout)
+		msg, err := reader.FetchMessage(fetchCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+				return summary, nil // Новых сообщений нет
+			}
+			d.metrics.FailedReceivesTotal.Inc()
+			return summary, fmt.Errorf("ошибка при получении сообщения из DLQ: %w", err)
+		}
+		d.metrics.MessagesReceivedTotal.Inc()
+
+		var dlqMsg DLQMessage
+		if err := json.Unmarshal(msg.Value, &dlqMsg); err != nil {
+			log.Printf("Пропущено сообщение DLQ %d/%d: %v", msg.Partition, msg.Offset, err)
+			summary.Skipped++
+		} else {
+			if dlqMsg.Headers == nil {
+				// Сообщения DLQ без поля headers: заголовки исходного сообщения только в заголовках Kafka
+				dlqMsg.Headers = headerMap(msg.Headers)
+			}
+			// Более новые сообщения оставляем следующему запуску
+			if !dlqMsg.Timestamp.Before(until) {
+				return summary, nil
+			}
+
+			if opts.DryRun {
+				log.Printf("DLQ %d/%d: %s, ключ %q, попыток %d, причина %q: %s",
+					msg.Partition, msg.Offset, dlqMsg.source(), dlqMsg.Key, dlqMsg.Attempts, dlqMsg.Reason, dlqMsg.Error)
+				summary.Pending++
+				if summary.Reasons == nil {
+					summary.Reasons = make(map[string]int)
+				}
+				summary.Reasons[dlqMsg.Reason]++
+				continue
+			}
+
+			if err := d.handle(ctx, dlqMsg, processFunc); err != nil {
+				if ctx.Err() != nil {
+					// Обработка прервана: сообщение остается в DLQ без коммита
+					return summary, ctx.Err()
+				}
+				log.Printf("Повторная обработка из DLQ сообщения %s не удалась (попытка %d): %v", dlqMsg.source(), dlqMsg.Attempts+1, err)
+				if dlqErr := d.dlq.Requeue(dlqMsg, err); dlqErr != nil {
+					// Без коммита сообщение остается в DLQ
+					return summary, fmt.Errorf("ошибка возврата сообщения в DLQ: %w", dlqErr)
+				}
+				d.metrics.DLQReplayFailedTotal.Inc()
+				summary.Failed++
+			} else {
+				d.metrics.DLQReplayedTotal.Inc()
+				summary.Replayed++
+			}
+		}
+
+		// В dry-run ничего не коммитится: следующий запуск прочитает те же сообщения
+		if opts.DryRun {
+			continue
+		}
+		// Коммит обработанного сообщения не прерывается отменой ctx, иначе заказ обработается повторно
+		if err := reader.CommitMessages(context.WithoutCancel(ctx), msg); err != nil {
+			return summary, fmt.Errorf("ошибка commit сообщения DLQ: %w", err)
+		}
+	}
+
+	return summary, nil
+}
+
+// handle декодирует, валидирует и обрабатывает исходное сообщение с trace_id из его заголовков
+func (d *DLQConsumer) handle(ctx context.Context, dlqMsg DLQMessage, processFunc func(context.Context, *models.Order) error) error {
+	if err := checkSchema(kafkaHeaders(dlqMsg.Headers)); err != nil {
+		d.metrics.ProcessingErrorsTotal.Inc()
+		return err
+	}
+	ctx = WithTraceID(ctx, dlqMsg.Headers[HeaderTraceID])
+
+	order, err := decodeOrder(kafkaHeaders(dlqMsg.Headers), dlqMsg.original(), JSONCodec{})
+	if err != nil {
+		d.metrics.ProcessingErrorsTotal.Inc()
+		return fmt.Errorf("ошибка дешифровки сообщения: %w", err)
+	}
+	if err := order.Validate(); err != nil {
+		d.metrics.ProcessingErrorsTotal.Inc()
+		return fmt.Errorf("невалидный заказ %s: %w", order.OrderUID, err)
+	}
+
+	startTime := time.Now()
+	err = processFunc(ctx, order)
+	d.metrics.MessageProcessingTime.Observe(time.Since(startTime).Seconds())
+	if err != nil {
+		d.metrics.ProcessingErrorsTotal.Inc()
+		return fmt.Errorf("ошибка обработки заказа %s: %w", order.OrderUID, err)
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"test_service/internal/models"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDLQReader читатель DLQ с фиксированным набором сообщений
+type fakeDLQReader struct {
+	messages  []kafka.Message
+	next      int
+	committed []int64
+	closed    bool
+}
+
+func (f *fakeDLQReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	if f.next >= len(f.messages) {
+		<-ctx.Done() // Как настоящий читатель: ждет новых сообщений до отмены
+		return kafka.Message{}, ctx.Err()
+	}
+	msg := f.messages[f.next]
+	f.next++
+	return msg, nil
+}
+
+func (f *fakeDLQReader) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	for _, msg := range msgs {
+		f.committed = append(f.committed, msg.Offset)
+	}
+	return nil
+}
+
+func (f *fakeDLQReader) Close() error {
+	f.closed = true
+	return nil
+}
+
+// fakeDLQSender запоминает отправленные в DLQ сообщения
+type fakeDLQSender struct {
+	sent     []kafka.Message
+	attempts []int
+	err      error
+}
+
+func (f *fakeDLQSender) SendToDLQ(msg kafka.Message, _ error, attempts int) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.sent = append(f.sent, msg)
+	f.attempts = append(f.attempts, attempts)
+	return nil
+}
+
+func dlqEnvelope(t *testing.T, original []byte, attempts int, ts time.Time) []byte {
+	t.Helper()
+	data, err := json.Marshal(DLQMessage{
+		OriginalMessage: original,
+		Error:           "processing error",
+		Timestamp:       ts,
+		Topic:           "orders",
+		Key:             "key",
+		Attempts:        attempts,
+		Reason:          DLQReasonProcessing,
+	})
+	require.NoError(t, err)
+	return data
+}
+
+// fakeDLQRequeuer запоминает возвращенные в DLQ сообщения
+type fakeDLQRequeuer struct {
+	requeued []DLQMessage
+	errs     []string
+	err      error
+}
+
+func (f *fakeDLQRequeuer) Requeue(dlqMsg DLQMessage, replayErr error) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.requeued = append(f.requeued, dlqMsg)
+	f.errs = append(f.errs, replayErr.Error())
+	return nil
+}
+
+func newTestDLQConsumer(reader *fakeDLQReader, requeuer *fakeDLQRequeuer) *DLQConsumer {
+	d := newDLQConsumer(func() dlqMessageReader { return reader }, requeuer)
+	d.idleTimeout = 10 * time.Millisecond
+	return d
+}
+
+func TestDLQConsumer_Replay(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	all := DLQReplayOptions{Max: 10}
+
+	t.Run("ReplaysFailsAndSkips", func(t *testing.T) {
+		reader := &fakeDLQReader{messages: []kafka.Message{
+			{Offset: 0, Value: dlqEnvelope(t, replayOrderJSON(t, 1), 1, past)},
+			{Offset: 1, Value: dlqEnvelope(t, []byte(`{"order_uid":"broken"}`), 2, past)},
+			{Offset: 2, Value: []byte("not json")},
+			{Offset: 3, Value: dlqEnvelope(t, replayOrderJSON(t, 2), 1, past)},
+		}}
+		requeuer := &fakeDLQRequeuer{}
+		d := newTestDLQConsumer(reader, requeuer)
+		replayedBefore := testutil.ToFloat64(d.metrics.DLQReplayedTotal)
+		failedBefore := testutil.ToFloat64(d.metrics.DLQReplayFailedTotal)
+
+		var processed []string
+		summary, err := d.Replay(context.Background(), all, func(_ context.Context, o *models.Order) error {
+			processed = append(processed, o.OrderUID)
+			return nil
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, DLQReplaySummary{Replayed: 2, Failed: 1, Skipped: 1}, summary)
+		assert.Len(t, processed, 2)
+		assert.Equal(t, []int64{0, 1, 2, 3}, reader.committed)
+		assert.True(t, reader.closed)
+		assert.Equal(t, 2.0, testutil.ToFloat64(d.metrics.DLQReplayedTotal)-replayedBefore)
+		assert.Equal(t, 1.0, testutil.ToFloat64(d.metrics.DLQReplayFailedTotal)-failedBefore)
+
+		// Невалидный заказ возвращен в DLQ вместе с исходными данными и ошибкой повтора
+		require.Len(t, requeuer.requeued, 1)
+		assert.Equal(t, 2, requeuer.requeued[0].Attempts, "счетчик увеличивает Requeue")
+		assert.Equal(t, "orders", requeuer.requeued[0].Topic)
+		assert.JSONEq(t, `{"order_uid":"broken"}`, string(requeuer.requeued[0].OriginalMessage))
+		assert.Contains(t, requeuer.errs[0], "невалидный заказ")
+	})
+
+	t.Run("StopsAtMax", func(t *testing.T) {
+		reader := &fakeDLQReader{messages: []kafka.Message{
+			{Offset: 0, Value: dlqEnvelope(t, replayOrderJSON(t, 1), 1, past)},
+			{Offset: 1, Value: dlqEnvelope(t, replayOrderJSON(t, 2), 1, past)},
+		}}
+
+		summary, err := newTestDLQConsumer(reader, &fakeDLQRequeuer{}).Replay(context.Background(), DLQReplayOptions{Max: 1},
+			func(context.Context, *models.Order) error { return nil })
+		require.NoError(t, err)
+		assert.Equal(t, 1, summary.Replayed)
+		assert.Equal(t, []int64{0}, reader.committed)
+	})
+
+	t.Run("StopsAtUntil", func(t *testing.T) {
+		reader := &fakeDLQReader{messages: []kafka.Message{
+			{Offset: 0, Value: dlqEnvelope(t, replayOrderJSON(t, 1), 1, past)},
+			{Offset: 1, Value: dlqEnvelope(t, replayOrderJSON(t, 2), 1, past.Add(time.Minute))},
+		}}
+
+		summary, err := newTestDLQConsumer(reader, &fakeDLQRequeuer{}).Replay(context.Background(),
+			DLQReplayOptions{Until: past.Add(time.Second)}, func(context.Context, *models.Order) error { return nil })
+		require.NoError(t, err)
+		assert.Equal(t, 1, summary.Replayed)
+		assert.Equal(t, []int64{0}, reader.committed)
+	})
+
+	t.Run("StopsAtMessagesFromCurrentRun", func(t *testing.T) {
+		reader := &fakeDLQReader{messages: []kafka.Message{
+			{Offset: 0, Value: dlqEnvelope(t, replayOrderJSON(t, 1), 1, time.Now().Add(time.Minute))},
+		}}
+
+		// Until в будущем не позволяет читать сообщения, возвращенные этим же запуском
+		summary, err := newTestDLQConsumer(reader, &fakeDLQRequeuer{}).Replay(context.Background(),
+			DLQReplayOptions{Until: time.Now().Add(time.Hour)}, func(context.Context, *models.Order) error { return nil })
+		require.NoError(t, err)
+		assert.Equal(t, DLQReplaySummary{}, summary)
+		assert.Empty(t, reader.committed)
+	})
+
+	t.Run("DryRun", func(t *testing.T) {
+		reader := &fakeDLQReader{messages: []kafka.Message{
+			{Offset: 0, Value: dlqEnvelope(t, replayOrderJSON(t, 1), 1, past)},
+			{Offset: 1, Value: []byte("not json")},
+			{Offset: 2, Value: dlqEnvelope(t, replayOrderJSON(t, 2), 1, past)},
+		}}
+		requeuer := &fakeDLQRequeuer{}
+
+		summary, err := newTestDLQConsumer(reader, requeuer).Replay(context.Background(), DLQReplayOptions{DryRun: true},
+			func(context.Context, *models.Order) error {
+				t.Error("в dry-run заказы не обрабатываются")
+				return nil
+			})
+		require.NoError(t, err)
+		assert.Equal(t, DLQReplaySummary{Skipped: 1, Pending: 2, Reasons: map[string]int{DLQReasonProcessing: 2}}, summary)
+		assert.Empty(t, reader.committed, "в dry-run смещения не коммитятся")
+		assert.Empty(t, requeuer.requeued)
+	})
+
+	t.Run("ProcessingErrorReturnsToDLQ", func(t *testing.T) {
+		reader := &fakeDLQReader{messages: []kafka.Message{
+			{Offset: 0, Value: dlqEnvelope(t, replayOrderJSON(t, 1), 1, past)},
+		}}
+		requeuer := &fakeDLQRequeuer{}
+
+		summary, err := newTestDLQConsumer(reader, requeuer).Replay(context.Background(), all,
+			func(context.Context, *models.Order) error { return errors.New("db down") })
+		require.NoError(t, err)
+		assert.Equal(t, 1, summary.Failed)
+		require.Len(t, requeuer.errs, 1)
+		assert.Contains(t, requeuer.errs[0], "db down")
+	})
+
+	t.Run("RequeueFailureKeepsMessageUncommitted", func(t *testing.T) {
+		reader := &fakeDLQReader{messages: []kafka.Message{
+			{Offset: 0, Value: dlqEnvelope(t, replayOrderJSON(t, 1), 1, past)},
+		}}
+		requeuer := &fakeDLQRequeuer{err: errors.New("broker unavailable")}
+
+		_, err := newTestDLQConsumer(reader, requeuer).Replay(context.Background(), all,
+			func(context.Context, *models.Order) error { return errors.New("db down") })
+		assert.Error(t, err)
+		assert.Empty(t, reader.committed)
+	})
+
+	t.Run("CancelledContext", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := newTestDLQConsumer(&fakeDLQReader{}, &fakeDLQRequeuer{}).Replay(ctx, all,
+			func(context.Context, *models.Order) error { return nil })
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
@@ -2,9 +2,14 @@ package kafka
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"testing"
 	"time"
 
+	"test_service/internal/database"
+	"test_service/internal/models"
+
 	"github.com/segmentio/kafka-go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -42,6 +47,10 @@ func TestDLQMessageStructure(t *testing.T) {
 			Timestamp:       time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC),
 			Topic:           "test-topic",
 			Key:             "test-key",
+			Partition:       3,
+			Offset:          42,
+			Headers:         map[string]string{HeaderTraceID: "trace-1"},
+			ConsumerGroup:   "order-service-group",
 			Attempts:        1,
 		}
 
@@ -59,6 +68,10 @@ func TestDLQMessageStructure(t *testing.T) {
 		assert.Equal(t, dlqMsg.Topic, deserialized.Topic)
 		assert.Equal(t, dlqMsg.Key, deserialized.Key)
 		assert.Equal(t, dlqMsg.Attempts, deserialized.Attempts)
+		assert.Equal(t, dlqMsg.Partition, deserialized.Partition)
+		assert.Equal(t, dlqMsg.Offset, deserialized.Offset)
+		assert.Equal(t, dlqMsg.Headers, deserialized.Headers)
+		assert.Equal(t, dlqMsg.ConsumerGroup, deserialized.ConsumerGroup)
 		assert.Equal(t, dlqMsg.Timestamp.Unix(), deserialized.Timestamp.Unix()) // Сравниваем Unix временные метки, чтобы избежать проблем с точностью
 
 		// Проверяем, что содержимое оригинального сообщения сохранено после обработки
@@ -70,6 +83,21 @@ func TestDLQMessageStructure(t *testing.T) {
 		require.NoError(t, err2)
 		assert.Equal(t, originalData, deserializedOriginalData)
 	})
+
+	t.Run("DecodesMessagesWithoutMetadata", func(t *testing.T) {
+		// Сообщение DLQ, записанное до появления partition, offset, headers и consumer_group
+		old := `{"original_message":{"order_uid":"uid"},"error":"db down","timestamp":"2024-01-01T12:00:00Z",` +
+			`"topic":"orders","key":"uid","attempts":3,"reason":"processing"}`
+
+		var dlqMsg DLQMessage
+		require.NoError(t, json.Unmarshal([]byte(old), &dlqMsg))
+		assert.Equal(t, "orders", dlqMsg.Topic)
+		assert.Equal(t, 3, dlqMsg.Attempts)
+		assert.Zero(t, dlqMsg.Partition)
+		assert.Zero(t, dlqMsg.Offset)
+		assert.Nil(t, dlqMsg.Headers)
+		assert.Empty(t, dlqMsg.ConsumerGroup)
+	})
 }
 
 func TestDLQProducer(t *testing.T) {
@@ -78,7 +106,7 @@ func TestDLQProducer(t *testing.T) {
 		brokers := []string{"localhost:9092"}
 		topic := "test-dlq-topic"
 
-		producer := NewDLQProducer(brokers, topic)
+		producer := NewDLQProducer(brokers, topic, DefaultWriterOptions())
 
 		// Проверяем, что продюсер был создан с правильными значениями
 		assert.NotNil(t, producer)
@@ -154,21 +182,21 @@ func TestConsumerWithDLQConstructor(t *testing.T) {
 		groupID := "test-group"
 		dlqProducer := &DLQProducer{topic: "test-dlq"}
 
-		consumer := NewConsumerWithDLQ(brokers, topic, groupID, dlqProducer)
+		consumer := NewConsumerWithDLQ(brokers, topic, groupID, DefaultConsumerOptions(), dlqProducer)
 
 		// Проверяем, что консьюмер был создан с правильными значениями
 		assert.NotNil(t, consumer)
 		assert.Equal(t, dlqProducer, consumer.dlq)
-		assert.Equal(t, 3, consumer.maxRetry)
+		assert.Equal(t, 3, consumer.retryPolicy.MaxAttempts)
 		assert.NotNil(t, consumer.reader)
 	})
 
 	t.Run("SetMaxRetry", func(t *testing.T) {
-		consumer := &Consumer{maxRetry: 3}
-		assert.Equal(t, 3, consumer.maxRetry)
+		consumer := NewConsumer([]string{"localhost:9092"}, "test-topic", "test-group", DefaultConsumerOptions())
+		assert.Equal(t, 3, consumer.retryPolicy.MaxAttempts)
 
 		consumer.SetMaxRetry(5)
-		assert.Equal(t, 5, consumer.maxRetry)
+		assert.Equal(t, 5, consumer.retryPolicy.MaxAttempts)
 	})
 }
 
@@ -178,12 +206,12 @@ func TestConsumerConstructor(t *testing.T) {
 		topic := "test-topic"
 		groupID := "test-group"
 
-		consumer := NewConsumer(brokers, topic, groupID)
+		consumer := NewConsumer(brokers, topic, groupID, DefaultConsumerOptions())
 
 		// Проверяем, что консьюмер был создан с правильными значениями
 		assert.NotNil(t, consumer)
 		assert.Nil(t, consumer.dlq) // DLQ должен быть nil по умолчанию
-		assert.Equal(t, 3, consumer.maxRetry)
+		assert.Equal(t, 3, consumer.retryPolicy.MaxAttempts)
 		assert.NotNil(t, consumer.reader)
 	})
 }
@@ -288,37 +316,76 @@ func TestDLQMessageEmptyValues(t *testing.T) {
 }
 
 func TestDLQProducerSendToDLQ(t *testing.T) {
-	// Этот тест проверяет, что метод SendToDLQ работает правильно с правильными параметрами
-
-	t.Run("SendToDLQMethodStructure", func(t *testing.T) {
-		// Создаем мок оригинального сообщения
-		originalMsgValue := json.RawMessage(`{"order_uid": "test-order"}`)
-		originalMsg := kafka.Message{
-			Topic: "original-topic",
-			Key:   []byte("test-key"),
-			Value: originalMsgValue,
-		}
-
-		testErr := "test error for DLQ"
-		attempts := 1
-
-		// Создаем DLQProducer - но мы не используем реальное подключение к Kafka для тестов
-		// Вместо этого мы тестируем логику создания DLQ сообщения
-		dlqMsg := DLQMessage{
-			OriginalMessage: originalMsgValue,
-			Error:           testErr,
-			Timestamp:       time.Now(),
-			Topic:           originalMsg.Topic,
-			Key:             string(originalMsg.Key),
-			Attempts:        attempts,
-		}
-
-		// Проверяем, что структура правильная
-		assert.Equal(t, originalMsgValue, dlqMsg.OriginalMessage)
-		assert.Equal(t, testErr, dlqMsg.Error)
-		assert.Equal(t, originalMsg.Topic, dlqMsg.Topic)
-		assert.Equal(t, "test-key", dlqMsg.Key)
-		assert.Equal(t, attempts, dlqMsg.Attempts)
-		assert.WithinDuration(t, time.Now(), dlqMsg.Timestamp, 1*time.Second)
+	dlq, writer := newTestDLQProducer()
+	originalMsg := kafka.Message{
+		Topic:     "orders",
+		Partition: 2,
+		Offset:    17,
+		Key:       []byte("test-key"),
+		Value:     []byte(`{"order_uid": "test-order"}`),
+		Headers: []kafka.Header{
+			{Key: HeaderTraceID, Value: []byte("trace-1")},
+			{Key: HeaderSchemaVersion, Value: []byte(OrderSchemaVersion)},
+		},
+	}
+
+	require.NoError(t, dlq.SendToDLQ(originalMsg, errors.New("test error for DLQ"), 2))
+
+	require.Len(t, writer.batches, 1)
+	sent := writer.batches[0][0]
+	assert.Equal(t, []byte("test-key"), sent.Key)
+	assert.Equal(t, headerMap(originalMsg.Headers), headerMap(sent.Headers), "заголовки дублируются в сообщении DLQ")
+
+	var dlqMsg DLQMessage
+	require.NoError(t, json.Unmarshal(sent.Value, &dlqMsg))
+	assert.JSONEq(t, string(originalMsg.Value), string(dlqMsg.OriginalMessage))
+	assert.Equal(t, "test error for DLQ", dlqMsg.Error)
+	assert.Equal(t, "orders", dlqMsg.Topic)
+	assert.Equal(t, "test-key", dlqMsg.Key)
+	assert.Equal(t, 2, dlqMsg.Partition)
+	assert.Equal(t, int64(17), dlqMsg.Offset)
+	assert.Equal(t, map[string]string{HeaderTraceID: "trace-1", HeaderSchemaVersion: OrderSchemaVersion}, dlqMsg.Headers)
+	assert.Equal(t, "order-service-group", dlqMsg.ConsumerGroup)
+	assert.Equal(t, 2, dlqMsg.Attempts)
+	assert.Equal(t, DLQReasonProcessing, dlqMsg.Reason)
+	assert.WithinDuration(t, time.Now(), dlqMsg.Timestamp, time.Second)
+
+	t.Run("RequeueKeepsMetadata", func(t *testing.T) {
+		require.NoError(t, dlq.Requeue(dlqMsg, errors.New("still down")))
+		require.Len(t, writer.batches, 2)
+		var requeued DLQMessage
+		require.NoError(t, json.Unmarshal(writer.batches[1][0].Value, &requeued))
+		assert.Equal(t, 2, requeued.Partition)
+		assert.Equal(t, int64(17), requeued.Offset)
+		assert.Equal(t, dlqMsg.Headers, requeued.Headers)
+		assert.Equal(t, "order-service-group", requeued.ConsumerGroup)
+		assert.Equal(t, 3, requeued.Attempts)
 	})
 }
+
+func TestDLQReason(t *testing.T) {
+	var order models.Order
+	decodeErr := json.Unmarshal([]byte(`{"order_uid":`), &order)
+	typeErr := json.Unmarshal([]byte(`{"sm_id":"x"}`), &order)
+	validationErr := (&models.Order{}).Validate()
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"Decode", decodeErr, DLQReasonBadData},
+		{"FieldType", typeErr, DLQReasonBadData},
+		{"Validation", validationErr, DLQReasonBadData},
+		{"ConstraintViolation", fmt.Errorf("заказ: %w", database.ErrConstraintViolation), DLQReasonBadData},
+		{"DuplicateItem", database.ErrDuplicateItem, DLQReasonBadData},
+		{"Unavailable", fmt.Errorf("%w: connection refused", database.ErrUnavailable), DLQReasonProcessing},
+		{"Other", errors.New("timeout"), DLQReasonProcessing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Error(t, tt.err)
+			assert.Equal(t, tt.want, dlqReason(tt.err))
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Заголовки сообщений с заказами
+const (
+	HeaderTraceID          = "trace_id"          // Идентификатор трассировки запроса, в котором отправлен заказ
+	HeaderSchemaVersion    = "schema_version"    // Версия схемы тела сообщения: major.minor
+	HeaderProducerInstance = "producer_instance" // Экземпляр сервиса, отправивший сообщение
+	HeaderContentType      = "content_type"      // Формат тела сообщения
+)
+
+const (
+	// OrderSchemaVersion версия схемы заказа в сообщениях. Consumer принимает любую minor-версию
+	// своей major-версии; сообщения без заголовка считаются версией 1.
+	OrderSchemaVersion = "1.0"
+
+	// ContentTypeJSON формат тела сообщений с заказами в JSON (JSONCodec); другие форматы — Codec
+	ContentTypeJSON = "application/json"
+)
+
+// orderSchemaMajor major-версия схемы заказа, которую понимает consumer
+const orderSchemaMajor = 1
+
+// ErrUnsupportedSchema сообщение со схемой неизвестной major-версии: уходит в DLQ с причиной DLQReasonSchema
+var ErrUnsupportedSchema = errors.New("неподдерживаемая версия схемы сообщения")
+
+// producerInstance экземпляр сервиса для заголовка producer_instance (имя хоста)
+var producerInstance = func() string {
+	if host, err := os.Hostname(); err == nil && host != "" {
+		return host
+	}
+	return "unknown"
+}()
+
+// traceIDKey ключ контекста с идентификатором трассировки
+type traceIDKey struct{}
+
+// WithTraceID возвращает контекст с идентификатором трассировки: Producer передает его
+// в заголовке trace_id, а consumer кладет trace_id сообщения в контекст обработки заказа
+func WithTraceID(ctx context.Context, traceID string) context.Context {
+	if traceID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, traceIDKey{}, traceID)
+}
+
+// TraceIDFromContext возвращает идентификатор трассировки из контекста (пусто — нет)
+func TraceIDFromContext(ctx context.Context) string {
+	traceID, _ := ctx.Value(traceIDKey{}).(string)
+	return traceID
+}
+
+// orderHeaders заголовки сообщения с заказом в формате codec; trace_id — только если он есть в ctx
+func orderHeaders(ctx context.Context, codec Codec) []kafka.Header {
+	headers := []kafka.Header{
+		{Key: HeaderSchemaVersion, Value: []byte(OrderSchemaVersion)},
+		{Key: HeaderProducerInstance, Value: []byte(producerInstance)},
+		{Key: HeaderContentType, Value: []byte(codec.ContentType())},
+	}
+	if traceID := TraceIDFromContext(ctx); traceID != "" {
+		headers = append(headers, kafka.Header{Key: HeaderTraceID, Value: []byte(traceID)})
+	}
+	return headers
+}
+
+// withContentType добавляет сообщению без заголовка content_type формат codec, которым оно
+// декодируется, чтобы топик повторов и DLQ разбирали его так же (они считают такие сообщения JSON)
+func withContentType(msg kafka.Message, codec Codec) kafka.Message {
+	if codec.ContentType() == ContentTypeJSON || headerValue(msg.Headers, HeaderContentType) != "" {
+		return msg
+	}
+	msg.Headers = append(slices.Clone(msg.Headers), kafka.Header{Key: HeaderContentType, Value: []byte(codec.ContentType())})
+	return msg
+}
+
+// headerValue возвращает значение заголовка key (пусто — заголовка нет)
+func headerValue(headers []kafka.Header, key string) string {
+	for _, h := range headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// headerMap заголовки сообщения в виде map (повторяющийся ключ — последнее значение); nil — заголовков нет
+func headerMap(headers []kafka.Header) map[string]string {
+	if len(headers) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(headers))
+	for _, h := range headers {
+		m[h.Key] = string(h.Value)
+	}
+	return m
+}
+
+// kafkaHeaders заголовки из map в порядке ключей
+func kafkaHeaders(m map[string]string) []kafka.Header {
+	if len(m) == 0 {
+		return nil
+	}
+	headers := make([]kafka.Header, 0, len(m))
+	for _, key := range slices.Sorted(maps.Keys(m)) {
+		headers = append(headers, kafka.Header{Key: key, Value: []byte(m[key])})
+	}
+	return headers
+}
+
+// checkSchema проверяет major-версию схемы сообщения; сообщения без заголовка — версия 1
+func checkSchema(headers []kafka.Header) error {
+	version := headerValue(headers, HeaderSchemaVersion)
+	if version == "" {
+		return nil
+	}
+	majorPart, _, _ := strings.Cut(version, ".")
+	if major, err := strconv.Atoi(majorPart); err != nil || major != orderSchemaMajor {
+		return fmt.Errorf("%w %q: поддерживается %d.x", ErrUnsupportedSchema, version, orderSchemaMajor)
+	}
+	return nil
+}
+
+// messageRef описание сообщения для логов: UID заказа и trace_id, если он есть
+func messageRef(orderUID string, headers []kafka.Header) string {
+	if traceID := headerValue(headers, HeaderTraceID); traceID != "" {
+		return fmt.Sprintf("%s (trace_id %s)", orderUID, traceID)
+	}
+	return orderUID
+}
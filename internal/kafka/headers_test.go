@@ -0,0 +1,178 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"test_service/internal/database"
+	"test_service/internal/models"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderHeaders(t *testing.T) {
+	t.Run("WithoutTrace", func(t *testing.T) {
+		headers := orderHeaders(context.Background(), JSONCodec{})
+		assert.Equal(t, OrderSchemaVersion, headerValue(headers, HeaderSchemaVersion))
+		assert.Equal(t, producerInstance, headerValue(headers, HeaderProducerInstance))
+		assert.Equal(t, ContentTypeJSON, headerValue(headers, HeaderContentType))
+		assert.Empty(t, headerValue(headers, HeaderTraceID))
+		assert.Len(t, headers, 3)
+	})
+
+	t.Run("WithTrace", func(t *testing.T) {
+		headers := orderHeaders(WithTraceID(context.Background(), "trace-1"), ProtobufCodec{})
+		assert.Equal(t, "trace-1", headerValue(headers, HeaderTraceID))
+		assert.Equal(t, ContentTypeProtobuf, headerValue(headers, HeaderContentType))
+		assert.Len(t, headers, 4)
+	})
+}
+
+func TestCheckSchema(t *testing.T) {
+	tests := []struct {
+		version string
+		wantErr bool
+	}{
+		{version: ""}, // Сообщения без заголовка — версия 1
+		{version: "1"},
+		{version: "1.0"},
+		{version: "1.7"},
+		{version: "2.0", wantErr: true},
+		{version: "0.9", wantErr: true},
+		{version: "v1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			var headers []kafka.Header
+			if tt.version != "" {
+				headers = []kafka.Header{{Key: HeaderSchemaVersion, Value: []byte(tt.version)}}
+			}
+			err := checkSchema(headers)
+			if !tt.wantErr {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrUnsupportedSchema)
+			assert.Equal(t, DLQReasonSchema, dlqReason(err))
+		})
+	}
+}
+
+// producedMessage отправляет заказ Producer с trace_id и возвращает записанное сообщение
+func producedMessage(t *testing.T, index int, traceID string) kafka.Message {
+	t.Helper()
+	writer := &fakeProducerWriter{}
+	p := newProducer(writer, "orders")
+	require.NoError(t, p.SendOrderWithContext(WithTraceID(context.Background(), traceID), GenerateTestOrder(index)))
+	require.Len(t, writer.batches, 1)
+	require.Len(t, writer.batches[0], 1)
+	return writer.batches[0][0]
+}
+
+// newTestDLQProducer DLQProducer группы order-service-group, записывающий сообщения в fakeProducerWriter
+func newTestDLQProducer() (*DLQProducer, *fakeProducerWriter) {
+	writer := &fakeProducerWriter{}
+	d := &DLQProducer{writer: writer, topic: "orders-dlq", metrics: NewKafkaMetrics()}
+	d.SetConsumerGroup("order-service-group")
+	return d, writer
+}
+
+func TestHeaders_ProduceConsumeDLQ(t *testing.T) {
+	produced := producedMessage(t, 1, "trace-1")
+	assert.Equal(t, "trace-1", headerValue(produced.Headers, HeaderTraceID))
+	assert.Equal(t, OrderSchemaVersion, headerValue(produced.Headers, HeaderSchemaVersion))
+	assert.Equal(t, ContentTypeJSON, headerValue(produced.Headers, HeaderContentType))
+	assert.NotEmpty(t, headerValue(produced.Headers, HeaderProducerInstance))
+
+	t.Run("FailedOrderKeepsHeadersInDLQ", func(t *testing.T) {
+		dlq, dlqWriter := newTestDLQProducer()
+		msg := produced
+		msg.Offset = 7
+		reader := newFakeConsumerReader([]kafka.Message{msg})
+		c := newConsumer(reader, "orders")
+		c.dlq = dlq
+
+		var traceID string
+		consumeAll(t, c, reader, 7, func(ctx context.Context, _ *models.Order) error {
+			traceID = TraceIDFromContext(ctx)
+			return database.ErrConstraintViolation
+		})
+
+		assert.Equal(t, "trace-1", traceID, "trace_id сообщения передается в контекст обработки")
+		require.Len(t, dlqWriter.batches, 1)
+		dlqMsg := dlqWriter.batches[0][0]
+		assert.Equal(t, headerMap(produced.Headers), headerMap(dlqMsg.Headers))
+		var envelope DLQMessage
+		require.NoError(t, json.Unmarshal(dlqMsg.Value, &envelope))
+		assert.Equal(t, DLQReasonBadData, envelope.Reason)
+		assert.Equal(t, headerMap(produced.Headers), envelope.Headers)
+		assert.Equal(t, int64(7), envelope.Offset)
+		assert.Equal(t, "order-service-group", envelope.ConsumerGroup)
+	})
+
+	t.Run("UnknownMajorSchemaToDLQ", func(t *testing.T) {
+		msg := produced
+		msg.Headers = []kafka.Header{
+			{Key: HeaderSchemaVersion, Value: []byte("2.0")},
+			{Key: HeaderTraceID, Value: []byte("trace-2")},
+		}
+		dlq, dlqWriter := newTestDLQProducer()
+		reader := newFakeConsumerReader([]kafka.Message{msg})
+		c := newConsumer(reader, "orders")
+		c.dlq = dlq
+
+		called := false
+		consumeAll(t, c, reader, 0, func(context.Context, *models.Order) error {
+			called = true
+			return nil
+		})
+
+		assert.False(t, called, "сообщение неизвестной схемы не обрабатывается")
+		require.Len(t, dlqWriter.batches, 1)
+		dlqMsg := dlqWriter.batches[0][0]
+		assert.Equal(t, headerMap(msg.Headers), headerMap(dlqMsg.Headers))
+		var envelope DLQMessage
+		require.NoError(t, json.Unmarshal(dlqMsg.Value, &envelope))
+		assert.Equal(t, DLQReasonSchema, envelope.Reason)
+		assert.Equal(t, 1, envelope.Attempts)
+	})
+
+	t.Run("DLQReplayRequeueKeepsHeaders", func(t *testing.T) {
+		dlq, dlqWriter := newTestDLQProducer()
+		dlqReader := &fakeDLQReader{messages: []kafka.Message{{
+			Value:   dlqEnvelope(t, produced.Value, 1, time.Now().Add(-time.Minute)),
+			Headers: produced.Headers,
+		}}}
+		d := newDLQConsumer(func() dlqMessageReader { return dlqReader }, dlq)
+
+		var traceID string
+		summary, err := d.Replay(context.Background(), DLQReplayOptions{Max: 1}, func(ctx context.Context, _ *models.Order) error {
+			traceID = TraceIDFromContext(ctx)
+			return errors.New("db down")
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, 1, summary.Failed)
+		assert.Equal(t, "trace-1", traceID)
+		require.Len(t, dlqWriter.batches, 1)
+		assert.Equal(t, headerMap(produced.Headers), headerMap(dlqWriter.batches[0][0].Headers))
+	})
+}
+
+func TestRetryProducer_RetryMessageKeepsOrderHeaders(t *testing.T) {
+	p := &RetryProducer{delays: []time.Duration{time.Second}, now: time.Now}
+	produced := producedMessage(t, 1, "trace-1")
+
+	first := p.retryMessage(produced, errors.New("db down"), 1, 3)
+	second := p.retryMessage(first, errors.New("db down"), 2, 6)
+
+	assert.Equal(t, "trace-1", headerValue(second.Headers, HeaderTraceID))
+	assert.Equal(t, OrderSchemaVersion, headerValue(second.Headers, HeaderSchemaVersion))
+	assert.Equal(t, "2", headerValue(second.Headers, HeaderRetryCycle))
+	assert.Len(t, second.Headers, len(produced.Headers)+4, "заголовки предыдущего цикла не дублируются")
+}
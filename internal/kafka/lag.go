@@ -0,0 +1,184 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/segmentio/kafka-go"
+)
+
+// lagSource отставание группы consumer по партициям топика; в тестах подменяется фейком
+type lagSource interface {
+	PartitionLag(ctx context.Context) (map[int]int64, error)
+}
+
+// brokerLag отставание по смещениям брокера: последнее смещение партиции минус закоммиченное
+// группой. kafka.Reader с GroupID не отдает отставание по партициям (Stats — только сумма
+// назначенных этому экземпляру, ReadLag без группы), поэтому смещения запрашиваются напрямую.
+type brokerLag struct {
+	client *kafka.Client
+	topic  string
+	group  string
+}
+
+// newBrokerLag создает источник отставания группы group по топику topic с подключением conn
+func newBrokerLag(brokers []string, topic, group string, conn Connection) *brokerLag {
+	return &brokerLag{
+		client: &kafka.Client{
+			Addr:      kafka.TCP(brokers...),
+			Timeout:   10 * time.Second,
+			Transport: conn.transport, // SASL и TLS
+		},
+		topic: topic,
+		group: group,
+	}
+}
+
+// PartitionLag возвращает отставание группы по каждой партиции топика. Партиция, в которой
+// группа еще ничего не коммитила, отстает на все хранимые сообщения.
+func (b *brokerLag) PartitionLag(ctx context.Context) (map[int]int64, error) {
+	meta, err := b.client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{b.topic}})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить метаданные топика %s: %w", b.topic, err)
+	}
+	var partitions []int
+	for _, t := range meta.Topics {
+		if t.Name != b.topic {
+			continue
+		}
+		if t.Error != nil {
+			return nil, fmt.Errorf("не удалось получить метаданные топика %s: %w", b.topic, t.Error)
+		}
+		for _, p := range t.Partitions {
+			partitions = append(partitions, p.ID)
+		}
+	}
+
+	requests := make([]kafka.OffsetRequest, 0, 2*len(partitions))
+	for _, p := range partitions {
+		requests = append(requests, kafka.FirstOffsetOf(p), kafka.LastOffsetOf(p))
+	}
+	offsets, err := b.client.ListOffsets(ctx, &kafka.ListOffsetsRequest{
+		Topics: map[string][]kafka.OffsetRequest{b.topic: requests},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить смещения топика %s: %w", b.topic, err)
+	}
+
+	committed, err := b.client.OffsetFetch(ctx, &kafka.OffsetFetchRequest{
+		GroupID: b.group,
+		Topics:  map[string][]int{b.topic: partitions},
+	})
+	if err == nil {
+		err = committed.Error
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить смещения группы %s: %w", b.group, err)
+	}
+	committedOffsets := make(map[int]int64, len(partitions))
+	for _, p := range committed.Topics[b.topic] {
+		if p.Error == nil {
+			committedOffsets[p.Partition] = p.CommittedOffset
+		}
+	}
+
+	lags := make(map[int]int64, len(partitions))
+	for _, p := range offsets.Topics[b.topic] {
+		if p.Error != nil {
+			continue // Партиция без лидера: значение появится при следующем опросе
+		}
+		from, ok := committedOffsets[p.Partition]
+		if !ok || from < 0 {
+			from = p.FirstOffset
+		}
+		lags[p.Partition] = max(p.LastOffset-from, 0)
+	}
+	return lags, nil
+}
+
+// lagReporter периодически публикует отставание группы в kafka_consumer_lag{topic,partition}
+type lagReporter struct {
+	source   lagSource
+	topic    string
+	interval time.Duration
+	gauge    *prometheus.GaugeVec
+
+	partitions map[int]struct{} // Партиции, для которых опубликовано значение
+	cancel     context.CancelFunc
+	done       chan struct{}
+	stopOnce   sync.Once
+}
+
+// newLagReporter создает lagReporter; запускается start
+func newLagReporter(source lagSource, topic string, interval time.Duration, metrics *KafkaMetrics) *lagReporter {
+	return &lagReporter{
+		source:     source,
+		topic:      topic,
+		interval:   interval,
+		gauge:      metrics.ConsumerLag,
+		partitions: make(map[int]struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// start запускает опрос: сразу и затем раз в interval до stop
+func (r *lagReporter) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	go r.run(ctx)
+}
+
+// run опрашивает источник до отмены ctx
+func (r *lagReporter) run(ctx context.Context) {
+	defer close(r.done)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		r.report(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// report обновляет метрики по одному опросу. Ошибка брокера только логируется: остаются
+// значения предыдущего опроса, а следующий опрос пробует снова.
+func (r *lagReporter) report(ctx context.Context) {
+	pollCtx, cancel := context.WithTimeout(ctx, r.interval)
+	defer cancel()
+	lags, err := r.source.PartitionLag(pollCtx)
+	if err != nil {
+		if ctx.Err() == nil { // Прерванный остановкой опрос — не ошибка
+			log.Printf("Ошибка получения отставания consumer по топику %s: %v", r.topic, err)
+		}
+		return
+	}
+	for partition, lag := range lags {
+		r.gauge.WithLabelValues(r.topic, strconv.Itoa(partition)).Set(float64(lag))
+		r.partitions[partition] = struct{}{}
+	}
+	// Партиции, которых больше нет в топике, не должны показывать последнее значение
+	for partition := range r.partitions {
+		if _, ok := lags[partition]; !ok {
+			r.gauge.DeleteLabelValues(r.topic, strconv.Itoa(partition))
+			delete(r.partitions, partition)
+		}
+	}
+}
+
+// stop останавливает опрос и дожидается его завершения; повторный вызов ничего не делает
+func (r *lagReporter) stop() {
+	r.stopOnce.Do(func() {
+		if r.cancel != nil {
+			r.cancel()
+			<-r.done
+		}
+	})
+}
@@ -0,0 +1,121 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLagSource отдает заданные ответы по очереди, последний — для всех следующих опросов
+type fakeLagSource struct {
+	mu        sync.Mutex
+	responses []fakeLagResponse
+	calls     int
+}
+
+type fakeLagResponse struct {
+	lags map[int]int64
+	err  error
+}
+
+func (f *fakeLagSource) PartitionLag(ctx context.Context) (map[int]int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	resp := f.responses[min(f.calls, len(f.responses)-1)]
+	f.calls++
+	return resp.lags, resp.err
+}
+
+func (f *fakeLagSource) callCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+func TestLagReporter_Report(t *testing.T) {
+	metrics := NewKafkaMetrics()
+	lagValue := func(topic, partition string) float64 {
+		return testutil.ToFloat64(metrics.ConsumerLag.WithLabelValues(topic, partition))
+	}
+
+	t.Run("UpdatesGauges", func(t *testing.T) {
+		source := &fakeLagSource{responses: []fakeLagResponse{
+			{lags: map[int]int64{0: 5, 1: 12}},
+			{lags: map[int]int64{0: 0, 1: 3}},
+		}}
+		r := newLagReporter(source, "lag-update", time.Second, metrics)
+
+		r.report(context.Background())
+		assert.Equal(t, 5.0, lagValue("lag-update", "0"))
+		assert.Equal(t, 12.0, lagValue("lag-update", "1"))
+
+		r.report(context.Background())
+		assert.Equal(t, 0.0, lagValue("lag-update", "0"))
+		assert.Equal(t, 3.0, lagValue("lag-update", "1"))
+	})
+
+	t.Run("KeepsGaugesOnBrokerError", func(t *testing.T) {
+		source := &fakeLagSource{responses: []fakeLagResponse{
+			{lags: map[int]int64{0: 7}},
+			{err: errors.New("broker unavailable")},
+			{lags: map[int]int64{0: 2}},
+		}}
+		r := newLagReporter(source, "lag-error", time.Second, metrics)
+
+		r.report(context.Background())
+		r.report(context.Background())
+		assert.Equal(t, 7.0, lagValue("lag-error", "0"), "ошибка брокера не должна сбрасывать значение")
+
+		r.report(context.Background())
+		assert.Equal(t, 2.0, lagValue("lag-error", "0"))
+	})
+
+	t.Run("RemovesMissingPartitions", func(t *testing.T) {
+		source := &fakeLagSource{responses: []fakeLagResponse{
+			{lags: map[int]int64{0: 1, 1: 4}},
+			{lags: map[int]int64{0: 1}},
+		}}
+		r := newLagReporter(source, "lag-remove", time.Second, metrics)
+
+		r.report(context.Background())
+		r.report(context.Background())
+		assert.False(t, metrics.ConsumerLag.DeleteLabelValues("lag-remove", "1"), "партиции 1 больше нет в топике")
+		assert.Equal(t, 1.0, lagValue("lag-remove", "0"))
+	})
+}
+
+func TestConsumer_StartLagReporter(t *testing.T) {
+	metrics := NewKafkaMetrics()
+
+	t.Run("StopsOnClose", func(t *testing.T) {
+		source := &fakeLagSource{responses: []fakeLagResponse{{lags: map[int]int64{0: 9}}}}
+		c := newConsumer(newFakeConsumerReader(nil), "lag-consumer")
+		c.lagSource = source
+
+		c.StartLagReporter(5 * time.Millisecond)
+		require.Eventually(t, func() bool { return source.callCount() >= 2 }, time.Second, time.Millisecond)
+		assert.Equal(t, 9.0, testutil.ToFloat64(metrics.ConsumerLag.WithLabelValues("lag-consumer", "0")))
+
+		require.NoError(t, c.Close())
+		calls := source.callCount()
+		time.Sleep(20 * time.Millisecond)
+		assert.Equal(t, calls, source.callCount(), "после Close опросов быть не должно")
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		source := &fakeLagSource{responses: []fakeLagResponse{{lags: map[int]int64{0: 1}}}}
+		c := newConsumer(newFakeConsumerReader(nil), "lag-disabled")
+		c.lagSource = source
+
+		c.StartLagReporter(0)
+		assert.Nil(t, c.lag)
+		require.NoError(t, c.Close())
+		assert.Zero(t, source.callCount())
+	})
+}
@@ -10,18 +10,51 @@ type KafkaMetrics struct {
 	// Messages
 	MessagesSentTotal     prometheus.Counter
 	MessagesReceivedTotal prometheus.Counter
+	TopicMessagesTotal    *prometheus.CounterVec
 	MessageProcessingTime prometheus.Histogram
 	FailedSendsTotal      prometheus.Counter
 	FailedReceivesTotal   prometheus.Counter
+	ProducerBatchSize     prometheus.Histogram
 
 	// Retries
 	RetryAttemptsTotal prometheus.Counter
 
 	// DLQ
 	DLQMessagesSentTotal prometheus.Counter
+	DLQReplayedTotal     prometheus.Counter
+	DLQReplayFailedTotal prometheus.Counter
+
+	// Retry topic
+	FirstPassFailuresTotal  prometheus.Counter
+	RetrySentTotal          prometheus.Counter
+	RetryPassSuccessesTotal prometheus.Counter
+	DLQEscalationsTotal     prometheus.Counter
 
 	// Errors
 	ProcessingErrorsTotal prometheus.Counter
+
+	// Delivery (SetDelivery)
+	RedeliveriesTotal   prometheus.Counter
+	PoisonMessagesTotal prometheus.Counter
+
+	// Consumer workers (SetConcurrency)
+	ConsumerInFlight     prometheus.Gauge
+	WorkerProcessingTime *prometheus.HistogramVec
+	ConsumerLag          *prometheus.GaugeVec
+
+	// Consumer batches (SetBatch)
+	ConsumerBatchSize           prometheus.Histogram
+	ConsumerBatchFlushesTotal   *prometheus.CounterVec
+	ConsumerBatchFallbacksTotal prometheus.Counter
+
+	// Duplicate suppression (SetDedup)
+	DuplicatesSkippedTotal prometheus.Counter
+
+	// Demo producer
+	DemoProducerLeader prometheus.Gauge
+
+	// kafka-go Reader.Stats() и Writer.Stats()
+	Stats *StatsCollector
 }
 
 // Global registry для предотвращения дублирования метрик
@@ -43,6 +76,10 @@ func NewKafkaMetrics() *KafkaMetrics {
 			Name: "kafka_messages_received_total",
 			Help: "Общее количество полученных сообщений из Kafka",
 		}),
+		TopicMessagesTotal: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "kafka_topic_messages_total",
+			Help: "Сообщения consumer по топику и итогу: received, processed, retry, dlq, duplicate",
+		}, []string{"topic", "result"}),
 		MessageProcessingTime: promauto.NewHistogram(prometheus.HistogramOpts{
 			Name:    "kafka_message_processing_duration_seconds",
 			Help:    "Время обработки сообщения Kafka в секундах",
@@ -56,6 +93,11 @@ func NewKafkaMetrics() *KafkaMetrics {
 			Name: "kafka_failed_receives_total",
 			Help: "Общее количество неудачных попыток получения сообщений из Kafka",
 		}),
+		ProducerBatchSize: promauto.NewHistogram(prometheus.HistogramOpts{
+			Name:    "kafka_producer_batch_size",
+			Help:    "Количество заказов в одном вызове записи SendOrders",
+			Buckets: []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000},
+		}),
 		RetryAttemptsTotal: promauto.NewCounter(prometheus.CounterOpts{
 			Name: "kafka_retry_attempts_total",
 			Help: "Общее количество попыток повторной отправки/получения сообщений",
@@ -64,11 +106,79 @@ func NewKafkaMetrics() *KafkaMetrics {
 			Name: "kafka_dlq_messages_sent_total",
 			Help: "Общее количество сообщений, отправленных в DLQ",
 		}),
+		DLQReplayedTotal: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "kafka_dlq_replayed_total",
+			Help: "Количество заказов, успешно обработанных повторно из DLQ",
+		}),
+		DLQReplayFailedTotal: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "kafka_dlq_replay_failed_total",
+			Help: "Количество сообщений DLQ, повторная обработка которых не удалась (возвращены в DLQ)",
+		}),
+		FirstPassFailuresTotal: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "kafka_first_pass_failures_total",
+			Help: "Количество заказов основного топика, не обработанных после всех попыток",
+		}),
+		RetrySentTotal: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "kafka_retry_messages_sent_total",
+			Help: "Количество сообщений, отправленных в топик повторов",
+		}),
+		RetryPassSuccessesTotal: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "kafka_retry_pass_successes_total",
+			Help: "Количество заказов, обработанных при чтении топика повторов",
+		}),
+		DLQEscalationsTotal: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "kafka_retry_dlq_escalations_total",
+			Help: "Количество сообщений топика повторов, отправленных в DLQ",
+		}),
 		ProcessingErrorsTotal: promauto.NewCounter(prometheus.CounterOpts{
 			Name: "kafka_processing_errors_total",
 			Help: "Общее количество ошибок обработки сообщений",
 		}),
+		RedeliveriesTotal: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "kafka_redeliveries_total",
+			Help: "Количество повторных доставок сообщений, не обработанных и не записанных в DLQ (at_least_once)",
+		}),
+		PoisonMessagesTotal: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "kafka_poison_messages_skipped_total",
+			Help: "Количество сообщений, пропущенных без доставки после исчерпания повторных доставок (at_least_once)",
+		}),
+		ConsumerInFlight: promauto.NewGauge(prometheus.GaugeOpts{
+			Name: "kafka_consumer_in_flight",
+			Help: "Количество полученных сообщений, переданных обработчикам и еще не обработанных",
+		}),
+		WorkerProcessingTime: promauto.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "kafka_consumer_worker_processing_duration_seconds",
+			Help:    "Время обработки сообщения обработчиком consumer в секундах",
+			Buckets: []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1.0, 2.5, 5.0, 10.0},
+		}, []string{"worker"}),
+		ConsumerLag: promauto.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "kafka_consumer_lag",
+			Help: "Отставание группы consumer: сообщения партиции, еще не закоммиченные группой",
+		}, []string{"topic", "partition"}),
+		ConsumerBatchSize: promauto.NewHistogram(prometheus.HistogramOpts{
+			Name:    "kafka_consumer_batch_size",
+			Help:    "Количество сообщений в пакете consumer",
+			Buckets: []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000},
+		}),
+		ConsumerBatchFlushesTotal: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "kafka_consumer_batch_flushes_total",
+			Help: "Пакеты consumer по причине сброса: size — набран размер, timeout — истекло ожидание",
+		}, []string{"reason"}),
+		ConsumerBatchFallbacksTotal: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "kafka_consumer_batch_fallbacks_total",
+			Help: "Пакеты consumer, обработанные по одному сообщению после ошибки пакетной обработки",
+		}),
+		DuplicatesSkippedTotal: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "kafka_duplicate_messages_skipped_total",
+			Help: "Сообщения, пропущенные как повтор уже обработанного заказа с тем же содержимым",
+		}),
+		DemoProducerLeader: promauto.NewGauge(prometheus.GaugeOpts{
+			Name: "demo_producer_leader",
+			Help: "Является ли экземпляр лидером демо-продюсера (1 — да, 0 — нет)",
+		}),
+		Stats: newStatsCollector(),
 	}
+	prometheus.MustRegister(globalKafkaMetrics.Stats)
 
 	return globalKafkaMetrics
 }
@@ -0,0 +1,67 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"test_service/internal/interfaces"
+	"test_service/internal/models"
+)
+
+// MultiConsumer используется в main через interfaces.MessageConsumer
+var _ interfaces.MessageConsumer = (*MultiConsumer)(nil)
+
+// MultiConsumer читает несколько топиков заказов (KAFKA_TOPICS): по Consumer со своим читателем,
+// DLQ и топиком повторов на топик, все передают заказы в один processFunc. Топики читаются
+// независимо: ошибка или остановка читателя одного топика не задерживает остальные.
+type MultiConsumer struct {
+	consumers []*Consumer
+}
+
+// NewMultiConsumer объединяет consumers; каждый настраивается (SetConcurrency, SetDelivery,
+// SetRetry и др.) до Consume
+func NewMultiConsumer(consumers ...*Consumer) *MultiConsumer {
+	return &MultiConsumer{consumers: consumers}
+}
+
+// Consume обрабатывает сообщения всех топиков до отмены ctx и возвращается, когда остановлены
+// все читатели. Ошибки отдельных топиков логируются сразу и возвращаются вместе.
+func (m *MultiConsumer) Consume(ctx context.Context, processFunc func(context.Context, *models.Order) error) error {
+	errs := make([]error, len(m.consumers))
+	var wg sync.WaitGroup
+	for i, c := range m.consumers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.Consume(ctx, processFunc); err != nil {
+				errs[i] = fmt.Errorf("топик %s: %w", c.topic, err)
+				if ctx.Err() == nil {
+					log.Printf("Чтение топика %s остановлено с ошибкой, остальные топики продолжают работу: %v", c.topic, err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// SetMaxRetry задает число попыток обработки заказа для всех топиков
+func (m *MultiConsumer) SetMaxRetry(maxRetry int) {
+	for _, c := range m.consumers {
+		c.SetMaxRetry(maxRetry)
+	}
+}
+
+// Close закрывает читатели всех топиков, даже если закрытие одного из них не удалось
+func (m *MultiConsumer) Close() error {
+	var errs []error
+	for _, c := range m.consumers {
+		if err := c.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("топик %s: %w", c.topic, err))
+		}
+	}
+	return errors.Join(errs...)
+}
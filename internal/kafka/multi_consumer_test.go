@@ -0,0 +1,157 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"test_service/internal/models"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// brokenConsumerReader читатель, который не может получить ни одного сообщения
+type brokenConsumerReader struct {
+	*fakeConsumerReader
+}
+
+func (b *brokenConsumerReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	select {
+	case <-time.After(time.Millisecond):
+		return kafka.Message{}, errors.New("broker unavailable")
+	case <-ctx.Done():
+		return kafka.Message{}, ctx.Err()
+	}
+}
+
+// isClosed true — читатель закрыт
+func (f *fakeConsumerReader) isClosed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closed
+}
+
+// processedOrders потокобезопасный список UID обработанных заказов
+type processedOrders struct {
+	mu   sync.Mutex
+	uids []string
+}
+
+func (p *processedOrders) process(_ context.Context, order *models.Order) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.uids = append(p.uids, order.OrderUID)
+	return nil
+}
+
+func (p *processedOrders) list() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.uids...)
+}
+
+func TestMultiConsumer_Consume(t *testing.T) {
+	metrics := NewKafkaMetrics()
+
+	t.Run("AllTopicsFeedProcessFunc", func(t *testing.T) {
+		ru := newFakeConsumerReader([]kafka.Message{orderMessage(t, 1, 0, 0), orderMessage(t, 2, 0, 1)})
+		kz := newFakeConsumerReader([]kafka.Message{orderMessage(t, 3, 0, 0)})
+		m := NewMultiConsumer(newConsumer(ru, "multi-ru"), newConsumer(kz, "multi-kz"))
+
+		var processed processedOrders
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- m.Consume(ctx, processed.process) }()
+
+		require.Eventually(t, func() bool { return ru.lastCommitted(0) == 1 && kz.lastCommitted(0) == 0 }, time.Second, time.Millisecond)
+		cancel()
+		require.NoError(t, <-done)
+
+		assert.ElementsMatch(t, []string{
+			GenerateTestOrder(1).OrderUID, GenerateTestOrder(2).OrderUID, GenerateTestOrder(3).OrderUID,
+		}, processed.list())
+		assert.True(t, ru.isClosed(), "остановка закрывает читатели всех топиков")
+		assert.True(t, kz.isClosed())
+		assert.Equal(t, 2.0, testutil.ToFloat64(metrics.TopicMessagesTotal.WithLabelValues("multi-ru", "processed")))
+		assert.Equal(t, 1.0, testutil.ToFloat64(metrics.TopicMessagesTotal.WithLabelValues("multi-kz", "processed")))
+	})
+
+	t.Run("BrokenTopicDoesNotStallOthers", func(t *testing.T) {
+		broken := &brokenConsumerReader{fakeConsumerReader: newFakeConsumerReader(nil)}
+		healthy := newFakeConsumerReader([]kafka.Message{orderMessage(t, 1, 0, 0), orderMessage(t, 2, 0, 1)})
+		m := NewMultiConsumer(newConsumer(broken, "multi-broken"), newConsumer(healthy, "multi-healthy"))
+
+		var processed processedOrders
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- m.Consume(ctx, processed.process) }()
+
+		require.Eventually(t, func() bool { return healthy.lastCommitted(0) == 1 }, time.Second, time.Millisecond)
+		cancel()
+		require.NoError(t, <-done)
+
+		assert.Len(t, processed.list(), 2)
+		assert.True(t, broken.isClosed())
+		assert.True(t, healthy.isClosed())
+	})
+
+	t.Run("DLQPerTopic", func(t *testing.T) {
+		ru := newFakeConsumerReader([]kafka.Message{{Value: []byte("not json"), Offset: 0}})
+		kz := newFakeConsumerReader([]kafka.Message{{Value: []byte("not json"), Offset: 0}})
+		ruDLQ, kzDLQ := &fakeDLQSender{}, &fakeDLQSender{}
+		ruConsumer, kzConsumer := newConsumer(ru, "multi-dlq-ru"), newConsumer(kz, "multi-dlq-kz")
+		ruConsumer.dlq, kzConsumer.dlq = ruDLQ, kzDLQ
+		m := NewMultiConsumer(ruConsumer, kzConsumer)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- m.Consume(ctx, (&processedOrders{}).process) }()
+
+		require.Eventually(t, func() bool { return ru.lastCommitted(0) == 0 && kz.lastCommitted(0) == 0 }, time.Second, time.Millisecond)
+		cancel()
+		require.NoError(t, <-done)
+
+		require.Len(t, ruDLQ.sent, 1)
+		require.Len(t, kzDLQ.sent, 1)
+		assert.Equal(t, "multi-dlq-ru", ruDLQ.sent[0].Topic)
+		assert.Equal(t, "multi-dlq-kz", kzDLQ.sent[0].Topic)
+		assert.Equal(t, 1.0, testutil.ToFloat64(metrics.TopicMessagesTotal.WithLabelValues("multi-dlq-kz", "dlq")))
+	})
+}
+
+// failingCloseReader читатель, закрытие которого завершается ошибкой
+type failingCloseReader struct {
+	*fakeConsumerReader
+}
+
+func (f *failingCloseReader) Close() error {
+	_ = f.fakeConsumerReader.Close()
+	return errors.New("close failed")
+}
+
+func TestMultiConsumer_Close(t *testing.T) {
+	failing := &failingCloseReader{fakeConsumerReader: newFakeConsumerReader(nil)}
+	healthy := newFakeConsumerReader(nil)
+	m := NewMultiConsumer(newConsumer(failing, "close-failing"), newConsumer(healthy, "close-healthy"))
+
+	err := m.Close()
+	assert.ErrorContains(t, err, "топик close-failing: close failed")
+	assert.True(t, failing.isClosed())
+	assert.True(t, healthy.isClosed(), "ошибка закрытия одного топика не мешает закрыть остальные")
+}
+
+func TestMultiConsumer_SetMaxRetry(t *testing.T) {
+	first, second := newConsumer(newFakeConsumerReader(nil), "a"), newConsumer(newFakeConsumerReader(nil), "b")
+	NewMultiConsumer(first, second).SetMaxRetry(5)
+	assert.Equal(t, 5, first.retryPolicy.MaxAttempts)
+	assert.Equal(t, 5, second.retryPolicy.MaxAttempts)
+}
+
+func TestDLQTopic(t *testing.T) {
+	assert.Equal(t, "orders-kz-dlq", DLQTopic("orders-kz"))
+}
@@ -0,0 +1,117 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"test_service/internal/models"
+	"test_service/internal/retry"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// InvalidOrder заказ, не отправленный SendOrders из-за ошибки валидации или сериализации
+type InvalidOrder struct {
+	Index    int    // Позиция заказа во входном срезе
+	OrderUID string // UID заказа (может быть пустым, если не прошел валидацию)
+	Err      error
+}
+
+// FailedBatch пакет заказов, запись которого не удалась после всех попыток
+type FailedBatch struct {
+	OrderUIDs []string // UID заказов пакета в порядке отправки
+	Err       error
+}
+
+// OrderBatchError ошибка SendOrders: какие заказы не прошли валидацию и какие пакеты не записаны.
+// Остальные заказы отправлены.
+type OrderBatchError struct {
+	Invalid []InvalidOrder
+	Failed  []FailedBatch
+}
+
+func (e *OrderBatchError) Error() string {
+	failed := 0
+	for _, batch := range e.Failed {
+		failed += len(batch.OrderUIDs)
+	}
+	return fmt.Sprintf("не отправлено заказов: %d не прошли валидацию, %d в %d пакетах не записаны",
+		len(e.Invalid), failed, len(e.Failed))
+}
+
+// Unwrap возвращает ошибки отдельных заказов и пакетов (для errors.Is и errors.As)
+func (e *OrderBatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Invalid)+len(e.Failed))
+	for _, invalid := range e.Invalid {
+		errs = append(errs, invalid.Err)
+	}
+	for _, batch := range e.Failed {
+		errs = append(errs, batch.Err)
+	}
+	return errs
+}
+
+// SendOrders отправляет заказы пакетами не больше SetMaxSendBatch: каждый пакет — один вызов записи
+// с повторными попытками. Заказы, не прошедшие валидацию, пропускаются; ошибка записи пакета
+// не останавливает отправку следующих. Если что-то не отправлено, возвращается *OrderBatchError.
+func (p *Producer) SendOrders(ctx context.Context, orders []*models.Order) error {
+	batchErr := &OrderBatchError{}
+	headers := orderHeaders(ctx, p.codec)
+	msgs := make([]kafka.Message, 0, len(orders))
+	for i, order := range orders {
+		if order == nil {
+			p.metrics.ProcessingErrorsTotal.Inc()
+			batchErr.Invalid = append(batchErr.Invalid, InvalidOrder{Index: i, Err: errors.New("пустой заказ")})
+			continue
+		}
+		if err := order.Validate(); err != nil {
+			p.metrics.ProcessingErrorsTotal.Inc()
+			batchErr.Invalid = append(batchErr.Invalid, InvalidOrder{Index: i, OrderUID: order.OrderUID,
+				Err: fmt.Errorf("ошибка валидации заказа перед отправкой в Kafka: %w", err)})
+			continue
+		}
+		value, err := p.codec.Marshal(order)
+		if err != nil {
+			p.metrics.ProcessingErrorsTotal.Inc()
+			batchErr.Invalid = append(batchErr.Invalid, InvalidOrder{Index: i, OrderUID: order.OrderUID, Err: err})
+			continue
+		}
+		msgs = append(msgs, kafka.Message{
+			Key:     []byte(order.OrderUID), // Сообщения одного заказа — в одну партицию
+			Value:   value,
+			Time:    time.Now(),
+			Headers: headers,
+		})
+	}
+
+	for start := 0; start < len(msgs); start += p.maxSendBatch {
+		batch := msgs[start:min(start+p.maxSendBatch, len(msgs))]
+		p.metrics.ProducerBatchSize.Observe(float64(len(batch)))
+		err := retry.DoWithContext(ctx, retry.DefaultPolicy(), func(ctx context.Context) error {
+			if err := p.writer.WriteMessages(ctx, batch...); err != nil {
+				p.metrics.FailedSendsTotal.Inc()
+				p.metrics.RetryAttemptsTotal.Inc()
+				log.Printf("Ошибка отправки пакета из %d заказов в Kafka (будет повторная попытка): %v", len(batch), err)
+				return err
+			}
+			p.metrics.MessagesSentTotal.Add(float64(len(batch)))
+			return nil
+		})
+		if err != nil {
+			p.metrics.ProcessingErrorsTotal.Inc()
+			uids := make([]string, 0, len(batch))
+			for _, msg := range batch {
+				uids = append(uids, string(msg.Key))
+			}
+			batchErr.Failed = append(batchErr.Failed, FailedBatch{OrderUIDs: uids, Err: err})
+		}
+	}
+
+	if len(batchErr.Invalid) > 0 || len(batchErr.Failed) > 0 {
+		return batchErr
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"test_service/internal/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeProducerWriter запоминает записанные пакеты; пакеты, начинающиеся с ключа из fail, не записываются
+type fakeProducerWriter struct {
+	batches [][]kafka.Message
+	calls   int
+	fail    map[string]bool
+}
+
+func (w *fakeProducerWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	w.calls++
+	if len(msgs) > 0 && w.fail[string(msgs[0].Key)] {
+		return errors.New("broker unavailable")
+	}
+	w.batches = append(w.batches, msgs)
+	return nil
+}
+
+func (w *fakeProducerWriter) Close() error { return nil }
+
+// batchSizes размеры записанных пакетов
+func (w *fakeProducerWriter) batchSizes() []int {
+	sizes := make([]int, 0, len(w.batches))
+	for _, batch := range w.batches {
+		sizes = append(sizes, len(batch))
+	}
+	return sizes
+}
+
+// testOrders n корректных заказов с индексами 1..n
+func testOrders(n int) []*models.Order {
+	orders := make([]*models.Order, 0, n)
+	for i := 1; i <= n; i++ {
+		orders = append(orders, GenerateTestOrder(i))
+	}
+	return orders
+}
+
+// histogramCount число наблюдений гистограммы
+func histogramCount(t *testing.T, h prometheus.Histogram) uint64 {
+	t.Helper()
+	var m dto.Metric
+	require.NoError(t, h.Write(&m))
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestProducer_SendOrders(t *testing.T) {
+	t.Run("Empty", func(t *testing.T) {
+		writer := &fakeProducerWriter{}
+		p := newProducer(writer, "orders")
+
+		require.NoError(t, p.SendOrders(context.Background(), nil))
+		assert.Zero(t, writer.calls)
+	})
+
+	t.Run("ChunkBoundaries", func(t *testing.T) {
+		for _, tt := range []struct {
+			name   string
+			orders int
+			max    int
+			want   []int
+		}{
+			{name: "SingleBatch", orders: 3, max: 5, want: []int{3}},
+			{name: "ExactMultiple", orders: 4, max: 2, want: []int{2, 2}},
+			{name: "Remainder", orders: 5, max: 2, want: []int{2, 2, 1}},
+			{name: "OnePerBatch", orders: 3, max: 1, want: []int{1, 1, 1}},
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				writer := &fakeProducerWriter{}
+				p := newProducer(writer, "orders")
+				p.SetMaxSendBatch(tt.max)
+				observedBefore := histogramCount(t, p.metrics.ProducerBatchSize)
+
+				orders := testOrders(tt.orders)
+				require.NoError(t, p.SendOrders(context.Background(), orders))
+
+				assert.Equal(t, tt.want, writer.batchSizes())
+				assert.Equal(t, uint64(len(tt.want)), histogramCount(t, p.metrics.ProducerBatchSize)-observedBefore)
+				var keys []string
+				for _, batch := range writer.batches {
+					for _, msg := range batch {
+						keys = append(keys, string(msg.Key))
+					}
+				}
+				require.Len(t, keys, tt.orders)
+				for i, order := range orders {
+					assert.Equal(t, order.OrderUID, keys[i], "порядок заказов сохраняется")
+				}
+			})
+		}
+	})
+
+	t.Run("PartialValidationFailures", func(t *testing.T) {
+		writer := &fakeProducerWriter{}
+		p := newProducer(writer, "orders")
+		orders := testOrders(4)
+		orders[1].OrderUID = ""
+		orders[3].Items = nil
+		orders = append(orders, nil)
+
+		err := p.SendOrders(context.Background(), orders)
+
+		var batchErr *OrderBatchError
+		require.ErrorAs(t, err, &batchErr)
+		require.Len(t, batchErr.Invalid, 3)
+		assert.Equal(t, []int{1, 3, 4}, []int{batchErr.Invalid[0].Index, batchErr.Invalid[1].Index, batchErr.Invalid[2].Index})
+		assert.Equal(t, orders[3].OrderUID, batchErr.Invalid[1].OrderUID)
+		assert.Empty(t, batchErr.Failed)
+		assert.Equal(t, []int{2}, writer.batchSizes(), "корректные заказы отправлены одним пакетом")
+		assert.Equal(t, orders[0].OrderUID, string(writer.batches[0][0].Key))
+		assert.Equal(t, orders[2].OrderUID, string(writer.batches[0][1].Key))
+	})
+
+	t.Run("BatchWriteFailure", func(t *testing.T) {
+		orders := testOrders(5)
+		writer := &fakeProducerWriter{fail: map[string]bool{orders[2].OrderUID: true}}
+		p := newProducer(writer, "orders")
+		p.SetMaxSendBatch(2)
+
+		err := p.SendOrders(context.Background(), orders)
+
+		var batchErr *OrderBatchError
+		require.ErrorAs(t, err, &batchErr)
+		assert.Empty(t, batchErr.Invalid)
+		require.Len(t, batchErr.Failed, 1)
+		assert.Equal(t, []string{orders[2].OrderUID, orders[3].OrderUID}, batchErr.Failed[0].OrderUIDs)
+		assert.ErrorContains(t, batchErr.Failed[0].Err, "broker unavailable")
+		assert.Equal(t, []int{2, 1}, writer.batchSizes(), "следующие пакеты отправляются после ошибки")
+		assert.Equal(t, 5, writer.calls, "неудачный пакет повторяется по DefaultPolicy")
+		assert.Contains(t, err.Error(), "2 в 1 пакетах не записаны")
+	})
+}
@@ -10,8 +10,7 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-// Disabled из-за проблемы с тегом валидатора: func TestGenerateTestOrder(t *testing.T) {
-func DisabledTestGenerateTestOrder(t *testing.T) {
+func TestGenerateTestOrder(t *testing.T) {
 	t.Run("GeneratesValidOrder", func(t *testing.T) {
 		for i := 0; i < 10; i++ {
 			order := GenerateTestOrder(i)
@@ -60,6 +59,31 @@ func DisabledTestGenerateTestOrder(t *testing.T) {
 		}
 	})
 
+	t.Run("ItemsAndPaymentAreCoherent", func(t *testing.T) {
+		allowedStatuses := map[int]bool{}
+		for _, status := range testItemStatuses {
+			allowedStatuses[status] = true
+		}
+
+		for i := 0; i < 50; i++ {
+			order := GenerateTestOrder(i)
+			require.NoError(t, order.Validate(), "заказ %d должен проходить валидацию", i)
+
+			goodsTotal := 0
+			for _, item := range order.Items {
+				assert.Equal(t, order.TrackNumber, item.TrackNumber, "товар должен наследовать трек-номер заказа")
+				assert.GreaterOrEqual(t, item.Sale, 0)
+				assert.LessOrEqual(t, item.Sale, 99)
+				assert.True(t, allowedStatuses[item.Status], "недопустимый статус товара %d", item.Status)
+				goodsTotal += item.TotalPrice
+			}
+
+			assert.Equal(t, goodsTotal, order.Payment.GoodsTotal, "goods_total должен быть суммой total_price товаров")
+			assert.Equal(t, order.Payment.GoodsTotal+order.Payment.DeliveryCost+order.Payment.CustomFee,
+				order.Payment.Amount, "amount = goods_total + delivery_cost + custom_fee")
+		}
+	})
+
 	t.Run("GeneratesDifferentOrders", func(t *testing.T) {
 		order1 := GenerateTestOrder(1)
 		order2 := GenerateTestOrder(2)
@@ -0,0 +1,58 @@
+package kafka
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// ConsumerOptions настройки читателя Consumer (KAFKA_COMMIT_INTERVAL, KAFKA_START_OFFSET и др.)
+type ConsumerOptions struct {
+	CommitInterval time.Duration // Интервал коммита смещений (0 — синхронный коммит каждого сообщения)
+	StartOffset    int64         // Начало чтения партиции без закоммиченного смещения: kafka.FirstOffset или kafka.LastOffset
+	MinBytes       int           // Минимум байт в ответе fetch
+	MaxBytes       int           // Максимум байт в ответе fetch
+	MaxWait        time.Duration // Ожидание MinBytes в запросе fetch
+	Connection     Connection    // SASL и TLS подключения к брокерам
+}
+
+// DefaultConsumerOptions настройки читателя по умолчанию, как раньше: коммит раз в секунду,
+// остальное — значения kafka-go
+func DefaultConsumerOptions() ConsumerOptions {
+	return ConsumerOptions{
+		CommitInterval: time.Second,
+		StartOffset:    kafka.FirstOffset,
+		MinBytes:       1,
+		MaxBytes:       1e6,
+		MaxWait:        10 * time.Second,
+	}
+}
+
+// ParseStartOffset возвращает начальное смещение по имени (KAFKA_START_OFFSET): first или last
+func ParseStartOffset(name string) (int64, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "first":
+		return kafka.FirstOffset, nil
+	case "last":
+		return kafka.LastOffset, nil
+	default:
+		return 0, fmt.Errorf("неизвестное начальное смещение %q: поддерживаются first и last", name)
+	}
+}
+
+// consumerReaderConfig конфигурация читателя топика topic группы groupID с настройками opts, SASL и TLS
+func consumerReaderConfig(brokers []string, topic, groupID string, opts ConsumerOptions) kafka.ReaderConfig {
+	return kafka.ReaderConfig{
+		Brokers:        brokers, // Список брокеров Kafka
+		GroupID:        groupID, // ID группы потребителей
+		Topic:          topic,   // Топик для чтения
+		CommitInterval: opts.CommitInterval,
+		StartOffset:    opts.StartOffset,
+		MinBytes:       opts.MinBytes,
+		MaxBytes:       opts.MaxBytes,
+		MaxWait:        opts.MaxWait,
+		Dialer:         opts.Connection.dialer, // SASL и TLS
+	}
+}
@@ -0,0 +1,69 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStartOffset(t *testing.T) {
+	for name, want := range map[string]int64{
+		"first": kafka.FirstOffset,
+		"LAST ": kafka.LastOffset,
+	} {
+		offset, err := ParseStartOffset(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, want, offset, name)
+	}
+
+	_, err := ParseStartOffset("latest")
+	assert.ErrorContains(t, err, "неизвестное начальное смещение")
+}
+
+func TestConsumerReaderConfig(t *testing.T) {
+	brokers := []string{"kafka-1:9092", "kafka-2:9092"}
+
+	t.Run("Options", func(t *testing.T) {
+		cfg := consumerReaderConfig(brokers, "orders", "orders-group", ConsumerOptions{
+			CommitInterval: 0,
+			StartOffset:    kafka.LastOffset,
+			MinBytes:       10e3,
+			MaxBytes:       10e6,
+			MaxWait:        250 * time.Millisecond,
+		})
+
+		assert.Equal(t, brokers, cfg.Brokers)
+		assert.Equal(t, "orders", cfg.Topic)
+		assert.Equal(t, "orders-group", cfg.GroupID)
+		assert.Zero(t, cfg.CommitInterval)
+		assert.Equal(t, kafka.LastOffset, cfg.StartOffset)
+		assert.Equal(t, 10000, cfg.MinBytes)
+		assert.Equal(t, 10000000, cfg.MaxBytes)
+		assert.Equal(t, 250*time.Millisecond, cfg.MaxWait)
+		require.NoError(t, cfg.Validate())
+	})
+
+	t.Run("DefaultsMatchPreviousReader", func(t *testing.T) {
+		// Прежний читатель: коммит раз в секунду, остальное — значения kafka-go по умолчанию
+		previous := kafka.NewReader(kafka.ReaderConfig{
+			Brokers:        brokers,
+			GroupID:        "orders-group",
+			Topic:          "orders",
+			CommitInterval: time.Second,
+		})
+		defer previous.Close()
+		current := kafka.NewReader(consumerReaderConfig(brokers, "orders", "orders-group", DefaultConsumerOptions()))
+		defer current.Close()
+
+		want, got := previous.Config(), current.Config()
+		assert.Equal(t, want.CommitInterval, got.CommitInterval)
+		assert.Equal(t, want.MinBytes, got.MinBytes)
+		assert.Equal(t, want.MaxBytes, got.MaxBytes)
+		assert.Equal(t, want.MaxWait, got.MaxWait)
+		assert.Equal(t, kafka.FirstOffset, got.StartOffset)
+		assert.Contains(t, []int64{0, kafka.FirstOffset}, want.StartOffset, "kafka-go по умолчанию читает с начала")
+	})
+}
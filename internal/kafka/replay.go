@@ -0,0 +1,245 @@
+// Package kafka содержит логику для работы с Apache Kafka, включая повторную обработку топика
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"test_service/internal/interfaces"
+	"test_service/internal/models"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// replayReader минимальный набор методов читателя одной партиции, нужный для повторной обработки
+type replayReader interface {
+	SetOffsetAt(ctx context.Context, t time.Time) error
+	Offset() int64
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	Close() error
+}
+
+// replayPartition партиция топика с читателем и верхней границей чтения
+type replayPartition struct {
+	id     int          // Номер партиции
+	reader replayReader // Читатель без группы потребителей
+	end    int64        // High-water mark на момент старта (смещение следующего сообщения)
+}
+
+// ReplayConfig содержит параметры разовой повторной обработки топика
+type ReplayConfig struct {
+	Brokers    []string                  // Список брокеров Kafka
+	Topic      string                    // Топик для повторной обработки
+	From       time.Time                 // Время, с которого начинается чтение
+	To         time.Time                 // Время, после которого чтение прекращается (нулевое — до high-water mark)
+	DLQ        interfaces.DeadLetterSink // DLQ producer (nil — сообщения с ошибками в DLQ не отправляются)
+	Codec      Codec                     // Формат сообщений без заголовка content_type (nil — JSON)
+	Connection Connection                // SASL и TLS подключения к брокерам
+}
+
+// ReplaySummary итоги повторной обработки
+type ReplaySummary struct {
+	Partitions int           // Количество прочитанных партиций
+	Processed  int           // Количество успешно обработанных заказов
+	Failed     int           // Количество сообщений с ошибками декодирования, валидации или обработки
+	SentToDLQ  int           // Количество сообщений, отправленных в DLQ
+	Duration   time.Duration // Общее время повторной обработки
+}
+
+// Replayer читает топик с заданного момента времени до текущего конца и завершает работу
+type Replayer struct {
+	cfg        ReplayConfig
+	partitions []replayPartition
+	metrics    *KafkaMetrics
+}
+
+// NewReplayer создает Replayer: по одному читателю без группы на каждую партицию топика
+func NewReplayer(ctx context.Context, cfg ReplayConfig) (*Replayer, error) {
+	if len(cfg.Brokers) == 0 {
+		return nil, errors.New("не заданы брокеры Kafka")
+	}
+	if cfg.From.IsZero() {
+		return nil, errors.New("не задано время начала повторной обработки")
+	}
+	if !cfg.To.IsZero() && !cfg.To.After(cfg.From) {
+		return nil, errors.New("время окончания повторной обработки должно быть позже времени начала")
+	}
+
+	partitions, err := readPartitions(cfg.Connection.directDialer(), cfg.Brokers, cfg.Topic)
+	if err != nil {
+		return nil, err
+	}
+
+	replayPartitions := make([]replayPartition, 0, len(partitions))
+	for _, p := range partitions {
+		end, err := readLastOffset(ctx, cfg.Connection.directDialer(), cfg.Brokers, cfg.Topic, p.ID)
+		if err != nil {
+			closePartitions(replayPartitions)
+			return nil, err
+		}
+		reader := kafka.NewReader(kafka.ReaderConfig{
+			Brokers:   cfg.Brokers,
+			Topic:     cfg.Topic,
+			Partition: p.ID, // Без GroupID: смещения не коммитятся и не влияют на основной consumer
+			Dialer:    cfg.Connection.dialer,
+		})
+		replayPartitions = append(replayPartitions, replayPartition{id: p.ID, reader: reader, end: end})
+	}
+
+	return newReplayer(cfg, replayPartitions), nil
+}
+
+// newReplayer создает Replayer с готовыми партициями
+func newReplayer(cfg ReplayConfig, partitions []replayPartition) *Replayer {
+	return &Replayer{
+		cfg:        cfg,
+		partitions: partitions,
+		metrics:    NewKafkaMetrics(),
+	}
+}
+
+// Run обрабатывает сообщения всех партиций начиная с cfg.From и возвращает итоги
+func (r *Replayer) Run(ctx context.Context, processFunc func(context.Context, *models.Order) error) (ReplaySummary, error) {
+	startTime := time.Now()
+	var summary ReplaySummary
+
+	for _, p := range r.partitions {
+		if err := r.replayPartition(ctx, p, processFunc, &summary); err != nil {
+			summary.Duration = time.Since(startTime)
+			return summary, fmt.Errorf("партиция %d: %w", p.id, err)
+		}
+		summary.Partitions++
+	}
+
+	summary.Duration = time.Since(startTime)
+	return summary, nil
+}
+
+// replayPartition обрабатывает одну партицию до high-water mark или до cfg.To
+func (r *Replayer) replayPartition(ctx context.Context, p replayPartition, processFunc func(context.Context, *models.Order) error, summary *ReplaySummary) error {
+	if err := p.reader.SetOffsetAt(ctx, r.cfg.From); err != nil {
+		return fmt.Errorf("ошибка позиционирования по времени %s: %w", r.cfg.From.Format(time.RFC3339), err)
+	}
+
+	for p.reader.Offset() < p.end {
+		msg, err := p.reader.FetchMessage(ctx)
+		if err != nil {
+			r.metrics.FailedReceivesTotal.Inc()
+			return fmt.Errorf("ошибка при получении сообщения: %w", err)
+		}
+		r.metrics.MessagesReceivedTotal.Inc()
+
+		// Сообщения позже границы To не обрабатываем
+		if !r.cfg.To.IsZero() && msg.Time.After(r.cfg.To) {
+			return nil
+		}
+
+		if err := r.handleMessage(ctx, msg, processFunc); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err() // Обработка прервана остановкой
+			}
+			summary.Failed++
+			log.Printf("Ошибка повторной обработки сообщения %d/%d: %v", p.id, msg.Offset, err)
+			if r.cfg.DLQ != nil {
+				if dlqErr := r.cfg.DLQ.SendToDLQ(msg, err, 1); dlqErr != nil {
+					log.Printf("Ошибка отправки в DLQ: %v", dlqErr)
+				} else {
+					summary.SentToDLQ++
+				}
+			}
+		} else {
+			summary.Processed++
+		}
+
+		// Последнее сообщение на момент старта обработано
+		if msg.Offset+1 >= p.end {
+			return nil
+		}
+	}
+	return nil
+}
+
+// handleMessage декодирует, валидирует и обрабатывает одно сообщение
+func (r *Replayer) handleMessage(ctx context.Context, msg kafka.Message, processFunc func(context.Context, *models.Order) error) error {
+	codec := r.cfg.Codec
+	if codec == nil {
+		codec = JSONCodec{}
+	}
+	order, err := decodeOrder(msg.Headers, msg.Value, codec)
+	if err != nil {
+		r.metrics.ProcessingErrorsTotal.Inc()
+		return fmt.Errorf("ошибка дешифровки сообщения: %w", err)
+	}
+	if err := order.Validate(); err != nil {
+		r.metrics.ProcessingErrorsTotal.Inc()
+		return fmt.Errorf("невалидный заказ %s: %w", order.OrderUID, err)
+	}
+
+	startTime := time.Now()
+	err = processFunc(ctx, order)
+	r.metrics.MessageProcessingTime.Observe(time.Since(startTime).Seconds())
+	if err != nil {
+		r.metrics.ProcessingErrorsTotal.Inc()
+		return fmt.Errorf("ошибка обработки заказа %s: %w", order.OrderUID, err)
+	}
+	return nil
+}
+
+// Close закрывает читатели всех партиций
+func (r *Replayer) Close() error {
+	return closePartitions(r.partitions)
+}
+
+// closePartitions закрывает читатели и возвращает первую ошибку
+func closePartitions(partitions []replayPartition) error {
+	var firstErr error
+	for _, p := range partitions {
+		if err := p.reader.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// readPartitions получает список партиций топика у первого доступного брокера
+func readPartitions(dialer *kafka.Dialer, brokers []string, topic string) ([]kafka.Partition, error) {
+	var lastErr error
+	for _, broker := range brokers {
+		conn, err := dialer.Dial("tcp", broker)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		partitions, err := conn.ReadPartitions(topic)
+		_ = conn.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return partitions, nil
+	}
+	return nil, fmt.Errorf("не удалось получить партиции топика %s: %w", topic, lastErr)
+}
+
+// readLastOffset получает high-water mark партиции у ее лидера
+func readLastOffset(ctx context.Context, dialer *kafka.Dialer, brokers []string, topic string, partition int) (int64, error) {
+	var lastErr error
+	for _, broker := range brokers {
+		conn, err := dialer.DialLeader(ctx, "tcp", broker, topic, partition)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		offset, err := conn.ReadLastOffset()
+		_ = conn.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return offset, nil
+	}
+	return 0, fmt.Errorf("не удалось получить последнее смещение партиции %d: %w", partition, lastErr)
+}
@@ -0,0 +1,177 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"test_service/internal/models"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeReplayReader читатель партиции с фиксированным набором сообщений
+type fakeReplayReader struct {
+	messages []kafka.Message
+	offset   int64
+	closed   bool
+}
+
+// SetOffsetAt позиционирует на первое сообщение не раньше t (как ListOffsets по времени)
+func (f *fakeReplayReader) SetOffsetAt(_ context.Context, t time.Time) error {
+	for _, msg := range f.messages {
+		if !msg.Time.Before(t) {
+			f.offset = msg.Offset
+			return nil
+		}
+	}
+	f.offset = int64(len(f.messages))
+	return nil
+}
+
+func (f *fakeReplayReader) Offset() int64 { return f.offset }
+
+func (f *fakeReplayReader) FetchMessage(_ context.Context) (kafka.Message, error) {
+	if f.offset >= int64(len(f.messages)) {
+		return kafka.Message{}, errors.New("fetch beyond high-water mark")
+	}
+	msg := f.messages[f.offset]
+	f.offset++
+	return msg, nil
+}
+
+func (f *fakeReplayReader) Close() error {
+	f.closed = true
+	return nil
+}
+
+// newFakeReplayReader создает партицию из заказов с шагом времени в один час
+func newFakeReplayReader(t *testing.T, start time.Time, values ...[]byte) *fakeReplayReader {
+	t.Helper()
+	reader := &fakeReplayReader{}
+	for i, value := range values {
+		reader.messages = append(reader.messages, kafka.Message{
+			Topic:  "orders",
+			Offset: int64(i),
+			Time:   start.Add(time.Duration(i) * time.Hour),
+			Value:  value,
+		})
+	}
+	return reader
+}
+
+func replayOrderJSON(t *testing.T, index int) []byte {
+	t.Helper()
+	data, err := json.Marshal(GenerateTestOrder(index))
+	require.NoError(t, err)
+	return data
+}
+
+func TestReplayer_Run(t *testing.T) {
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("FromTimestampToHighWaterMark", func(t *testing.T) {
+		reader := newFakeReplayReader(t, start,
+			replayOrderJSON(t, 1), replayOrderJSON(t, 2), replayOrderJSON(t, 3), replayOrderJSON(t, 4))
+		replayer := newReplayer(ReplayConfig{From: start.Add(2 * time.Hour)}, []replayPartition{
+			{id: 0, reader: reader, end: 4},
+		})
+
+		var processed []string
+		summary, err := replayer.Run(context.Background(), func(_ context.Context, order *models.Order) error {
+			processed = append(processed, order.OrderUID)
+			return nil
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, summary.Partitions)
+		assert.Equal(t, 2, summary.Processed)
+		assert.Equal(t, 0, summary.Failed)
+		assert.Equal(t, []string{GenerateTestOrder(3).OrderUID, GenerateTestOrder(4).OrderUID}, processed)
+	})
+
+	t.Run("StopsAtReplayTo", func(t *testing.T) {
+		reader := newFakeReplayReader(t, start,
+			replayOrderJSON(t, 1), replayOrderJSON(t, 2), replayOrderJSON(t, 3))
+		replayer := newReplayer(ReplayConfig{From: start, To: start.Add(90 * time.Minute)}, []replayPartition{
+			{id: 0, reader: reader, end: 3},
+		})
+
+		summary, err := replayer.Run(context.Background(), func(context.Context, *models.Order) error { return nil })
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, summary.Processed)
+	})
+
+	t.Run("IgnoresMessagesWrittenAfterStart", func(t *testing.T) {
+		reader := newFakeReplayReader(t, start,
+			replayOrderJSON(t, 1), replayOrderJSON(t, 2), replayOrderJSON(t, 3))
+		// High-water mark зафиксирован до появления третьего сообщения
+		replayer := newReplayer(ReplayConfig{From: start}, []replayPartition{
+			{id: 0, reader: reader, end: 2},
+		})
+
+		summary, err := replayer.Run(context.Background(), func(context.Context, *models.Order) error { return nil })
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, summary.Processed)
+		assert.Equal(t, int64(2), reader.Offset())
+	})
+
+	t.Run("CountsFailuresWithoutDLQ", func(t *testing.T) {
+		reader := newFakeReplayReader(t, start,
+			[]byte(`{invalid`), replayOrderJSON(t, 2), replayOrderJSON(t, 3))
+		replayer := newReplayer(ReplayConfig{From: start}, []replayPartition{
+			{id: 0, reader: reader, end: 3},
+		})
+
+		summary, err := replayer.Run(context.Background(), func(_ context.Context, order *models.Order) error {
+			if order.OrderUID == GenerateTestOrder(3).OrderUID {
+				return errors.New("database error")
+			}
+			return nil
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, summary.Processed)
+		assert.Equal(t, 2, summary.Failed)
+		assert.Equal(t, 0, summary.SentToDLQ)
+	})
+
+	t.Run("MultiplePartitionsAndEmptyPartition", func(t *testing.T) {
+		first := newFakeReplayReader(t, start, replayOrderJSON(t, 1), replayOrderJSON(t, 2))
+		second := newFakeReplayReader(t, start, replayOrderJSON(t, 3))
+		replayer := newReplayer(ReplayConfig{From: start.Add(time.Hour)}, []replayPartition{
+			{id: 0, reader: first, end: 2},
+			{id: 1, reader: second, end: 1},
+		})
+
+		summary, err := replayer.Run(context.Background(), func(context.Context, *models.Order) error { return nil })
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, summary.Partitions)
+		assert.Equal(t, 1, summary.Processed)
+
+		require.NoError(t, replayer.Close())
+		assert.True(t, first.closed)
+		assert.True(t, second.closed)
+	})
+}
+
+func TestNewReplayerValidation(t *testing.T) {
+	ctx := context.Background()
+	from := time.Date(2024, 5, 1, 3, 0, 0, 0, time.UTC)
+
+	_, err := NewReplayer(ctx, ReplayConfig{Topic: "orders", From: from})
+	assert.Error(t, err, "без брокеров replayer не создается")
+
+	_, err = NewReplayer(ctx, ReplayConfig{Brokers: []string{"localhost:9092"}, Topic: "orders"})
+	assert.Error(t, err, "без времени начала replayer не создается")
+
+	_, err = NewReplayer(ctx, ReplayConfig{Brokers: []string{"localhost:9092"}, Topic: "orders", From: from, To: from})
+	assert.Error(t, err, "время окончания должно быть позже времени начала")
+}
@@ -0,0 +1,265 @@
+// Package kafka содержит логику для работы с Apache Kafka, включая топик отложенных повторов
+package kafka
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"test_service/internal/interfaces"
+	"test_service/internal/models"
+	"test_service/internal/retry"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Заголовки сообщений топика повторов
+const (
+	HeaderRetryAt       = "retry_at"       // Время, не раньше которого сообщение обрабатывается снова (RFC3339Nano)
+	HeaderRetryCycle    = "retry_cycle"    // Номер цикла повтора, начиная с 1
+	HeaderRetryAttempts = "retry_attempts" // Попытки обработки заказа во всех предыдущих циклах
+	HeaderRetryError    = "retry_error"    // Ошибка последней попытки
+)
+
+// retrySender отправка сообщения в топик повторов
+type retrySender interface {
+	SendToRetry(originalMsg kafka.Message, err error, cycle, attempts int) error
+}
+
+// RetryProducer публикует в топик повторов заказы, не обработанные из-за сбоя
+type RetryProducer struct {
+	writer  *kafka.Writer
+	topic   string
+	delays  []time.Duration // Задержка перед циклом повтора n — delays[n-1], дальше — последняя
+	now     func() time.Time
+	metrics *KafkaMetrics
+}
+
+// NewRetryProducer создает RetryProducer для топика retryTopic с задержками циклов delays
+// и настройками писателя opts
+func NewRetryProducer(brokers []string, retryTopic string, delays []time.Duration, opts WriterOptions) *RetryProducer {
+	writer := newWriter(brokers, retryTopic, opts)
+	writer.Balancer = &kafka.Hash{} // Повторы одного заказа — в одну партицию, по порядку
+	return &RetryProducer{
+		writer:  writer,
+		topic:   retryTopic,
+		delays:  delays,
+		now:     time.Now,
+		metrics: NewKafkaMetrics(),
+	}
+}
+
+// delay задержка перед циклом повтора cycle
+func (p *RetryProducer) delay(cycle int) time.Duration {
+	if len(p.delays) == 0 {
+		return 0
+	}
+	i := min(max(cycle, 1), len(p.delays)) - 1
+	return p.delays[i]
+}
+
+// retryMessage сообщение топика повторов: исходные ключ, значение и заголовки заказа (trace_id,
+// schema_version, producer_instance, content_type) с заголовками цикла cycle; прочие заголовки,
+// в том числе предыдущего цикла, не переносятся
+func (p *RetryProducer) retryMessage(originalMsg kafka.Message, err error, cycle, attempts int) kafka.Message {
+	headers := make([]kafka.Header, 0, len(originalMsg.Headers)+4)
+	for _, h := range originalMsg.Headers {
+		switch h.Key {
+		case HeaderTraceID, HeaderSchemaVersion, HeaderProducerInstance, HeaderContentType:
+			headers = append(headers, h)
+		}
+	}
+	headers = append(headers,
+		kafka.Header{Key: HeaderRetryAt, Value: []byte(p.now().Add(p.delay(cycle)).UTC().Format(time.RFC3339Nano))},
+		kafka.Header{Key: HeaderRetryCycle, Value: []byte(strconv.Itoa(cycle))},
+		kafka.Header{Key: HeaderRetryAttempts, Value: []byte(strconv.Itoa(attempts))},
+		kafka.Header{Key: HeaderRetryError, Value: []byte(err.Error())},
+	)
+	return kafka.Message{
+		Key:     originalMsg.Key,
+		Value:   originalMsg.Value,
+		Headers: headers,
+	}
+}
+
+// SendToRetry публикует сообщение для цикла повтора cycle; attempts — попытки обработки заказа до него
+func (p *RetryProducer) SendToRetry(originalMsg kafka.Message, err error, cycle, attempts int) error {
+	if sendErr := p.writer.WriteMessages(context.Background(), p.retryMessage(originalMsg, err, cycle, attempts)); sendErr != nil {
+		p.metrics.FailedSendsTotal.Inc()
+		return sendErr
+	}
+	p.metrics.RetrySentTotal.Inc()
+	return nil
+}
+
+// Close закрывает RetryProducer
+func (p *RetryProducer) Close() error {
+	return p.writer.Close()
+}
+
+// retryMeta заголовки сообщения топика повторов
+type retryMeta struct {
+	retryAt  time.Time // Нулевое — обработать сразу
+	cycle    int
+	attempts int
+}
+
+// parseRetryMeta читает заголовки повтора; отсутствующие или некорректные заголовки —
+// первый цикл без задержки
+func parseRetryMeta(msg kafka.Message) retryMeta {
+	meta := retryMeta{cycle: 1}
+	for _, h := range msg.Headers {
+		switch h.Key {
+		case HeaderRetryAt:
+			if t, err := time.Parse(time.RFC3339Nano, string(h.Value)); err == nil {
+				meta.retryAt = t
+			}
+		case HeaderRetryCycle:
+			if n, err := strconv.Atoi(string(h.Value)); err == nil && n > 0 {
+				meta.cycle = n
+			}
+		case HeaderRetryAttempts:
+			if n, err := strconv.Atoi(string(h.Value)); err == nil && n > 0 {
+				meta.attempts = n
+			}
+		}
+	}
+	return meta
+}
+
+// RetryReaderConfig параметры RetryReader
+type RetryReaderConfig struct {
+	Brokers    []string                  // Список брокеров Kafka
+	Topic      string                    // Исходный топик заказов (указывается в DLQ)
+	RetryTopic string                    // Топик повторов
+	GroupID    string                    // Группа основного consumer; повторы читаются группой GroupID+"-retry"
+	MaxCycles  int                       // Циклов повтора до отправки в DLQ
+	Retry      *RetryProducer            // Публикация следующего цикла
+	DLQ        interfaces.DeadLetterSink // DLQ (nil — сообщения после последнего цикла отбрасываются)
+	Connection Connection                // SASL и TLS подключения к брокерам
+}
+
+// RetryReader читает топик повторов, дожидается retry_at каждого сообщения и обрабатывает заказ
+// снова. Заказ, опять не обработанный из-за сбоя, уходит на следующий цикл, после MaxCycles
+// циклов — в DLQ. Сообщение коммитится только после обработки или отправки дальше, поэтому
+// при остановке ожидающие и обрабатываемые сообщения не теряются: после перезапуска они будут
+// прочитаны снова. Сообщения партиции обрабатываются по порядку: сообщение с более поздним
+// retry_at задерживает следующие за ним.
+type RetryReader struct {
+	reader      consumerReader
+	topic       string
+	retry       retrySender
+	dlq         dlqSender
+	maxCycles   int
+	retryPolicy retry.Policy // Попытки обработки в одном цикле (как у Consumer)
+	now         func() time.Time
+	metrics     *KafkaMetrics
+}
+
+// NewRetryReader создает RetryReader топика cfg.RetryTopic
+func NewRetryReader(cfg RetryReaderConfig) (*RetryReader, error) {
+	if cfg.Retry == nil {
+		return nil, errors.New("не задан RetryProducer топика повторов")
+	}
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:        cfg.Brokers,
+		GroupID:        cfg.GroupID + "-retry",
+		Topic:          cfg.RetryTopic,
+		CommitInterval: time.Second,
+		Dialer:         cfg.Connection.dialer,
+	})
+	r := newRetryReader(reader, cfg.Topic, cfg.Retry, cfg.MaxCycles)
+	if cfg.DLQ != nil {
+		r.dlq = cfg.DLQ
+	}
+	return r, nil
+}
+
+// newRetryReader создает RetryReader с готовым читателем
+func newRetryReader(reader consumerReader, topic string, retrySend retrySender, maxCycles int) *RetryReader {
+	return &RetryReader{
+		reader:      reader,
+		topic:       topic,
+		retry:       retrySend,
+		maxCycles:   maxCycles,
+		retryPolicy: retry.DefaultPolicy(),
+		now:         time.Now,
+		metrics:     NewKafkaMetrics(),
+	}
+}
+
+// Run обрабатывает сообщения топика повторов до отмены ctx, затем закрывает читатель
+func (r *RetryReader) Run(ctx context.Context, processFunc func(context.Context, *models.Order) error) error {
+	// Коммит обработанного во время остановки сообщения не должен прерываться отменой ctx
+	commitCtx := context.WithoutCancel(ctx)
+	for {
+		msg, err := r.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return r.reader.Close()
+			}
+			r.metrics.FailedReceivesTotal.Inc()
+			log.Printf("Ошибка при получении сообщения из топика повторов: %v", err)
+			continue
+		}
+
+		meta := parseRetryMeta(msg)
+		// Остановка во время ожидания: сообщение не закоммичено и будет прочитано снова
+		if !r.waitUntil(ctx, meta.retryAt) {
+			return r.reader.Close()
+		}
+
+		// Остановка во время обработки: сообщение не закоммичено и будет прочитано снова
+		if !r.handleMessage(ctx, msg, meta, processFunc) {
+			return r.reader.Close()
+		}
+		if err := r.reader.CommitMessages(commitCtx, msg); err != nil {
+			log.Printf("Ошибка commit сообщения топика повторов: %v", err)
+		}
+	}
+}
+
+// waitUntil ждет наступления t; false — ctx отменен раньше
+func (r *RetryReader) waitUntil(ctx context.Context, t time.Time) bool {
+	wait := t.Sub(r.now())
+	if wait <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// handleMessage обрабатывает заказ очередного цикла повтора и отправляет неудачный на следующий
+// цикл или в DLQ. false — обработка прервана отменой ctx, сообщение никуда не отправлено.
+func (r *RetryReader) handleMessage(ctx context.Context, msg kafka.Message, meta retryMeta, processFunc func(context.Context, *models.Order) error) bool {
+	res := processMessage(ctx, msg, JSONCodec{}, processFunc, r.retryPolicy, r.metrics)
+	if res.err == nil {
+		r.metrics.RetryPassSuccessesTotal.Inc()
+		log.Printf("Заказ %s обработан в цикле повтора %d", res.orderUID, meta.cycle)
+		return true
+	}
+	if ctx.Err() != nil {
+		log.Printf("Обработка заказа %s прервана остановкой: %v", res.orderUID, res.err)
+		return false
+	}
+
+	res.attempts += meta.attempts
+	if dlqReason(res.err) == DLQReasonProcessing && meta.cycle < r.maxCycles {
+		err := r.retry.SendToRetry(msg, res.err, meta.cycle+1, res.attempts)
+		if err == nil {
+			return true
+		}
+		log.Printf("Ошибка отправки в топик повторов: %v", err)
+	}
+	r.metrics.DLQEscalationsTotal.Inc()
+	sendToDLQ(r.dlq, r.topic, msg, res, r.metrics)
+	return true
+}
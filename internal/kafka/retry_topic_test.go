@@ -0,0 +1,184 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"test_service/internal/database"
+	"test_service/internal/models"
+	"test_service/internal/retry"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRetrySender запоминает сообщения, отправленные в топик повторов
+type fakeRetrySender struct {
+	mu       sync.Mutex
+	sent     []kafka.Message
+	cycles   []int
+	attempts []int
+}
+
+func (f *fakeRetrySender) SendToRetry(msg kafka.Message, _ error, cycle, attempts int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, msg)
+	f.cycles = append(f.cycles, cycle)
+	f.attempts = append(f.attempts, attempts)
+	return nil
+}
+
+// retryMessageAt сообщение топика повторов с заказом GenerateTestOrder(index)
+func retryMessageAt(t *testing.T, index int, offset int64, retryAt time.Time, cycle, attempts int) kafka.Message {
+	msg := orderMessage(t, index, 0, offset)
+	msg.Headers = []kafka.Header{
+		{Key: HeaderRetryAt, Value: []byte(retryAt.Format(time.RFC3339Nano))},
+		{Key: HeaderRetryCycle, Value: []byte(strconv.Itoa(cycle))},
+		{Key: HeaderRetryAttempts, Value: []byte(strconv.Itoa(attempts))},
+	}
+	return msg
+}
+
+// fastRetries сокращает задержки между попытками обработки в тестах
+func fastRetries(policy *retry.Policy) {
+	policy.InitialBackoff = time.Millisecond
+	policy.MaxBackoff = time.Millisecond
+}
+
+func TestRetryProducer_RetryMessage(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	p := &RetryProducer{delays: []time.Duration{time.Second, time.Minute}, now: func() time.Time { return now }}
+	original := kafka.Message{Key: []byte("uid"), Value: []byte(`{"order_uid":"uid"}`), Headers: []kafka.Header{{Key: "old"}}}
+
+	tests := []struct {
+		cycle int
+		delay time.Duration
+	}{
+		{1, time.Second},
+		{2, time.Minute},
+		{5, time.Minute}, // Дальше расписания — последняя задержка
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("Cycle%d", tt.cycle), func(t *testing.T) {
+			msg := p.retryMessage(original, errors.New("db down"), tt.cycle, 4)
+			assert.Equal(t, original.Key, msg.Key)
+			assert.Equal(t, original.Value, msg.Value)
+
+			meta := parseRetryMeta(msg)
+			assert.Equal(t, now.Add(tt.delay), meta.retryAt)
+			assert.Equal(t, tt.cycle, meta.cycle)
+			assert.Equal(t, 4, meta.attempts)
+			assert.Len(t, msg.Headers, 4, "заголовки исходного сообщения не переносятся")
+		})
+	}
+}
+
+func TestParseRetryMeta_Defaults(t *testing.T) {
+	meta := parseRetryMeta(kafka.Message{Headers: []kafka.Header{
+		{Key: HeaderRetryAt, Value: []byte("soon")},
+		{Key: HeaderRetryCycle, Value: []byte("0")},
+	}})
+	assert.Equal(t, retryMeta{cycle: 1}, meta, "некорректные заголовки — первый цикл без задержки")
+}
+
+func TestConsumer_SendsToRetryTopic(t *testing.T) {
+	newRetryTopicConsumer := func(t *testing.T) (*Consumer, *fakeConsumerReader, *fakeRetrySender, *fakeDLQSender) {
+		reader := newFakeConsumerReader([]kafka.Message{orderMessage(t, 1, 0, 0)})
+		c := newConsumer(reader, "orders")
+		fastRetries(&c.retryPolicy)
+		retrySend, dlq := &fakeRetrySender{}, &fakeDLQSender{}
+		c.retry, c.dlq = retrySend, dlq
+		return c, reader, retrySend, dlq
+	}
+
+	t.Run("ProcessingFailure", func(t *testing.T) {
+		c, reader, retrySend, dlq := newRetryTopicConsumer(t)
+		failuresBefore := testutil.ToFloat64(c.metrics.FirstPassFailuresTotal)
+
+		consumeAll(t, c, reader, 0, func(context.Context, *models.Order) error { return errors.New("db down") })
+
+		assert.Equal(t, []int{1}, retrySend.cycles)
+		assert.Equal(t, []int{3}, retrySend.attempts)
+		assert.Empty(t, dlq.sent)
+		assert.Equal(t, 1.0, testutil.ToFloat64(c.metrics.FirstPassFailuresTotal)-failuresBefore)
+	})
+
+	t.Run("BadDataToDLQ", func(t *testing.T) {
+		c, reader, retrySend, dlq := newRetryTopicConsumer(t)
+
+		consumeAll(t, c, reader, 0, func(context.Context, *models.Order) error { return database.ErrConstraintViolation })
+
+		assert.Empty(t, retrySend.sent, "ошибки данных повтор не исправит")
+		assert.Len(t, dlq.sent, 1)
+	})
+}
+
+func TestRetryReader_Run(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	uid := func(index int) string { return GenerateTestOrder(index).OrderUID }
+	reader := newFakeConsumerReader([]kafka.Message{
+		retryMessageAt(t, 1, 0, past, 1, 3), // Обработается
+		retryMessageAt(t, 2, 1, past, 1, 3), // Снова сбой — следующий цикл
+		retryMessageAt(t, 3, 2, past, 2, 6), // Сбой в последнем цикле — DLQ
+	})
+	retrySend, dlq := &fakeRetrySender{}, &fakeDLQSender{}
+	r := newRetryReader(reader, "orders", retrySend, 2)
+	fastRetries(&r.retryPolicy)
+	r.dlq = dlq
+	successesBefore := testutil.ToFloat64(r.metrics.RetryPassSuccessesTotal)
+	escalationsBefore := testutil.ToFloat64(r.metrics.DLQEscalationsTotal)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- r.Run(ctx, func(_ context.Context, order *models.Order) error {
+			if order.OrderUID == uid(1) {
+				return nil
+			}
+			return errors.New("db down")
+		})
+	}()
+	require.Eventually(t, func() bool { return reader.lastCommitted(0) == 2 }, time.Second, time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(r.metrics.RetryPassSuccessesTotal)-successesBefore)
+	require.Len(t, retrySend.sent, 1)
+	assert.Equal(t, []byte(uid(2)), retrySend.sent[0].Key)
+	assert.Equal(t, []int{2}, retrySend.cycles)
+	assert.Equal(t, []int{6}, retrySend.attempts, "попытки всех циклов суммируются")
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(r.metrics.DLQEscalationsTotal)-escalationsBefore)
+	require.Len(t, dlq.sent, 1)
+	assert.Equal(t, "orders", dlq.sent[0].Topic, "в DLQ указывается исходный топик")
+	assert.Equal(t, []int{9}, dlq.attempts)
+}
+
+func TestRetryReader_ShutdownWhileWaiting(t *testing.T) {
+	reader := newFakeConsumerReader([]kafka.Message{retryMessageAt(t, 1, 0, time.Now().Add(time.Hour), 1, 3)})
+	r := newRetryReader(reader, "orders", &fakeRetrySender{}, 3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- r.Run(ctx, func(context.Context, *models.Order) error {
+			t.Error("сообщение обработано раньше retry_at")
+			return nil
+		})
+	}()
+	require.Eventually(t, func() bool { return reader.fetched() == 1 }, time.Second, time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+
+	// Ожидавшее сообщение не закоммичено: после перезапуска оно будет прочитано снова
+	assert.Equal(t, int64(-1), reader.lastCommitted(0))
+	assert.True(t, reader.closed)
+}
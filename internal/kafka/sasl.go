@@ -0,0 +1,53 @@
+package kafka
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// Механизмы SASL-аутентификации на брокерах (KAFKA_SASL_MECHANISM)
+const (
+	SASLPlain       = "PLAIN"
+	SASLScramSHA256 = "SCRAM-SHA-256"
+	SASLScramSHA512 = "SCRAM-SHA-512"
+)
+
+// SASLConfig параметры SASL-аутентификации; пустой Mechanism — подключение без аутентификации
+type SASLConfig struct {
+	Mechanism string // PLAIN, SCRAM-SHA-256 или SCRAM-SHA-512 (регистр не важен)
+	Username  string
+	Password  string
+}
+
+// newSASLMechanism создает механизм SASL по cfg; nil — аутентификация не настроена
+func newSASLMechanism(cfg SASLConfig) (sasl.Mechanism, error) {
+	name := strings.ToUpper(strings.TrimSpace(cfg.Mechanism))
+	if name == "" {
+		return nil, nil
+	}
+	switch name {
+	case SASLPlain, SASLScramSHA256, SASLScramSHA512:
+	default:
+		return nil, fmt.Errorf("неизвестный механизм SASL %q: поддерживаются %s, %s и %s",
+			cfg.Mechanism, SASLPlain, SASLScramSHA256, SASLScramSHA512)
+	}
+	if cfg.Username == "" {
+		return nil, fmt.Errorf("для SASL %s не задано имя пользователя", name)
+	}
+	if cfg.Password == "" {
+		return nil, fmt.Errorf("для SASL %s не задан пароль", name)
+	}
+
+	switch name {
+	case SASLPlain:
+		return plain.Mechanism{Username: cfg.Username, Password: cfg.Password}, nil
+	case SASLScramSHA256:
+		return scram.Mechanism(scram.SHA256, cfg.Username, cfg.Password)
+	default:
+		return scram.Mechanism(scram.SHA512, cfg.Username, cfg.Password)
+	}
+}
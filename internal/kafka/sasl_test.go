@@ -0,0 +1,62 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSASLMechanism(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     SASLConfig
+		want    string // Name() механизма; пусто — без аутентификации
+		wantErr string
+	}{
+		{name: "Disabled", cfg: SASLConfig{}},
+		{name: "Plain", cfg: SASLConfig{Mechanism: "PLAIN", Username: "orders", Password: "secret"}, want: SASLPlain},
+		{name: "ScramSHA256", cfg: SASLConfig{Mechanism: "SCRAM-SHA-256", Username: "orders", Password: "secret"}, want: SASLScramSHA256},
+		{name: "ScramSHA512LowerCase", cfg: SASLConfig{Mechanism: "scram-sha-512", Username: "orders", Password: "secret"}, want: SASLScramSHA512},
+		{name: "Unknown", cfg: SASLConfig{Mechanism: "GSSAPI", Username: "orders", Password: "secret"}, wantErr: "неизвестный механизм SASL"},
+		{name: "MissingUsername", cfg: SASLConfig{Mechanism: "PLAIN", Password: "secret"}, wantErr: "не задано имя пользователя"},
+		{name: "MissingPassword", cfg: SASLConfig{Mechanism: "SCRAM-SHA-256", Username: "orders"}, wantErr: "не задан пароль"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mechanism, err := newSASLMechanism(tt.cfg)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			if tt.want == "" {
+				assert.Nil(t, mechanism)
+				return
+			}
+			require.NotNil(t, mechanism)
+			assert.Equal(t, tt.want, mechanism.Name())
+		})
+	}
+}
+
+func TestNewConnectionSASL(t *testing.T) {
+	conn, err := NewConnection(SASLConfig{Mechanism: SASLScramSHA512, Username: "orders", Password: "secret"}, nil)
+	require.NoError(t, err)
+	require.NotNil(t, conn.dialer)
+	assert.Equal(t, SASLScramSHA512, conn.dialer.SASLMechanism.Name())
+	assert.Same(t, conn.dialer, conn.directDialer())
+	transport, ok := conn.transport.(*kafka.Transport)
+	require.True(t, ok)
+	assert.Equal(t, SASLScramSHA512, transport.SASL.Name())
+
+	_, err = NewConnection(SASLConfig{Mechanism: "GSSAPI"}, nil)
+	assert.ErrorContains(t, err, "неизвестный механизм SASL")
+
+	// Без SASL и TLS — значения kafka-go по умолчанию
+	conn, err = NewConnection(SASLConfig{}, nil)
+	require.NoError(t, err)
+	assert.Nil(t, conn.transport)
+	assert.Same(t, kafka.DefaultDialer, conn.directDialer())
+}
@@ -0,0 +1,233 @@
+package kafka
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/segmentio/kafka-go"
+)
+
+// Клиенты, статистика которых публикуется StatsCollector (метка client)
+const (
+	statsClientProducer = "producer"
+	statsClientConsumer = "consumer"
+	statsClientDLQ      = "dlq"
+)
+
+// readerStatsSource статистика читателя; реализуется kafka.Reader
+type readerStatsSource interface {
+	Stats() kafka.ReaderStats
+}
+
+// writerStatsSource статистика писателя; реализуется kafka.Writer
+type writerStatsSource interface {
+	Stats() kafka.WriterStats
+}
+
+// statsDescs все метрики StatsCollector (для Describe)
+var statsDescs []*prometheus.Desc
+
+// newStatsDesc описание метрики StatsCollector с метками client и topic
+func newStatsDesc(name, help string) *prometheus.Desc {
+	desc := prometheus.NewDesc(name, help, []string{"client", "topic"}, nil)
+	statsDescs = append(statsDescs, desc)
+	return desc
+}
+
+// Метрики kafka.Reader.Stats()
+var (
+	readerDialsDesc      = newStatsDesc("kafka_reader_dials_total", "Подключения читателя к брокерам")
+	readerFetchesDesc    = newStatsDesc("kafka_reader_fetches_total", "Запросы fetch читателя")
+	readerMessagesDesc   = newStatsDesc("kafka_reader_messages_total", "Сообщения, прочитанные читателем")
+	readerBytesDesc      = newStatsDesc("kafka_reader_bytes_total", "Байты сообщений, прочитанных читателем")
+	readerRebalancesDesc = newStatsDesc("kafka_reader_rebalances_total", "Ребалансировки группы читателя")
+	readerTimeoutsDesc   = newStatsDesc("kafka_reader_timeouts_total", "Таймауты читателя")
+	readerErrorsDesc     = newStatsDesc("kafka_reader_errors_total", "Ошибки читателя")
+	readerDialTimeDesc   = newStatsDesc("kafka_reader_dial_seconds", "Время подключения читателя к брокеру в секундах")
+	readerReadTimeDesc   = newStatsDesc("kafka_reader_read_seconds", "Время чтения ответа fetch в секундах")
+	readerWaitTimeDesc   = newStatsDesc("kafka_reader_wait_seconds", "Время ожидания ответа fetch в секундах")
+	readerFetchSizeDesc  = newStatsDesc("kafka_reader_fetch_size", "Сообщений в одном ответе fetch")
+	readerFetchBytesDesc = newStatsDesc("kafka_reader_fetch_bytes", "Байт в одном ответе fetch")
+	readerOffsetDesc     = newStatsDesc("kafka_reader_offset", "Текущее смещение читателя")
+	readerLagDesc        = newStatsDesc("kafka_reader_lag", "Отставание читателя по назначенным ему партициям")
+	readerQueueLenDesc   = newStatsDesc("kafka_reader_queue_length", "Сообщений в очереди читателя")
+	readerQueueCapDesc   = newStatsDesc("kafka_reader_queue_capacity", "Емкость очереди читателя")
+)
+
+// Метрики kafka.Writer.Stats()
+var (
+	writerWritesDesc         = newStatsDesc("kafka_writer_writes_total", "Запросы записи писателя")
+	writerMessagesDesc       = newStatsDesc("kafka_writer_messages_total", "Сообщения, записанные писателем")
+	writerBytesDesc          = newStatsDesc("kafka_writer_bytes_total", "Байты сообщений, записанных писателем")
+	writerErrorsDesc         = newStatsDesc("kafka_writer_errors_total", "Ошибки записи писателя")
+	writerRetriesDesc        = newStatsDesc("kafka_writer_retries_total", "Повторные попытки записи писателя")
+	writerBatchTimeDesc      = newStatsDesc("kafka_writer_batch_seconds", "Время формирования пакета в секундах")
+	writerBatchQueueTimeDesc = newStatsDesc("kafka_writer_batch_queue_seconds", "Время ожидания пакета в очереди в секундах")
+	writerWriteTimeDesc      = newStatsDesc("kafka_writer_write_seconds", "Время записи пакета в секундах")
+	writerWaitTimeDesc       = newStatsDesc("kafka_writer_wait_seconds", "Время ожидания ответа брокера в секундах")
+	writerBatchSizeDesc      = newStatsDesc("kafka_writer_batch_size", "Сообщений в пакете писателя")
+	writerBatchBytesDesc     = newStatsDesc("kafka_writer_batch_bytes", "Байт в пакете писателя")
+)
+
+// StatsCollector публикует статистику kafka-go читателей и писателей (producer, consumer, DLQ)
+// при каждом сборе метрик. Stats() kafka-go возвращает счетчики с момента прошлого вызова и
+// обнуляет их, поэтому счетчики и суммы накапливаются здесь; Stats() источников больше никто
+// не вызывает.
+type StatsCollector struct {
+	mu      sync.Mutex
+	sources map[statsKey]*statsSource
+}
+
+// statsKey клиент и топик источника статистики
+type statsKey struct {
+	client string
+	topic  string
+}
+
+// statsSource источник статистики: читатель или писатель с накопленными значениями
+type statsSource struct {
+	reader readerStatsSource
+	writer writerStatsSource
+
+	counters  map[*prometheus.Desc]float64
+	summaries map[*prometheus.Desc]statsSummary
+}
+
+// statsSummary накопленные количество и сумма наблюдений
+type statsSummary struct {
+	count uint64
+	sum   float64
+}
+
+// newStatsCollector создает пустой StatsCollector; регистрируется в NewKafkaMetrics
+func newStatsCollector() *StatsCollector {
+	return &StatsCollector{sources: make(map[statsKey]*statsSource)}
+}
+
+// addReader добавляет статистику читателя; повторное добавление клиента и топика заменяет
+// источник, сохраняя накопленные счетчики
+func (c *StatsCollector) addReader(client, topic string, reader readerStatsSource) {
+	c.add(client, topic, reader, nil)
+}
+
+// addWriter добавляет статистику писателя так же, как addReader
+func (c *StatsCollector) addWriter(client, topic string, writer writerStatsSource) {
+	c.add(client, topic, nil, writer)
+}
+
+// add задает читатель или писатель источника клиента и топика, создавая источник при необходимости
+func (c *StatsCollector) add(client, topic string, reader readerStatsSource, writer writerStatsSource) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := statsKey{client: client, topic: topic}
+	s, ok := c.sources[key]
+	if !ok {
+		s = &statsSource{
+			counters:  make(map[*prometheus.Desc]float64),
+			summaries: make(map[*prometheus.Desc]statsSummary),
+		}
+		c.sources[key] = s
+	}
+	s.reader, s.writer = reader, writer
+}
+
+// remove убирает источник клиента и топика, если он все еще принадлежит owner (читатель или
+// писатель, переданный в addReader или addWriter)
+func (c *StatsCollector) remove(client, topic string, owner any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := statsKey{client: client, topic: topic}
+	s, ok := c.sources[key]
+	if !ok {
+		return
+	}
+	if (s.reader != nil && s.reader == owner) || (s.writer != nil && s.writer == owner) {
+		delete(c.sources, key)
+	}
+}
+
+// Describe реализует prometheus.Collector
+func (c *StatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range statsDescs {
+		ch <- desc
+	}
+}
+
+// Collect реализует prometheus.Collector: снимает Stats() всех источников
+func (c *StatsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, s := range c.sources {
+		switch {
+		case s.reader != nil:
+			s.collectReader(ch, s.reader.Stats(), key.client, key.topic)
+		case s.writer != nil:
+			s.collectWriter(ch, s.writer.Stats(), key.client, key.topic)
+		}
+	}
+}
+
+// collectReader публикует статистику читателя
+func (s *statsSource) collectReader(ch chan<- prometheus.Metric, st kafka.ReaderStats, labels ...string) {
+	s.counter(ch, readerDialsDesc, st.Dials, labels)
+	s.counter(ch, readerFetchesDesc, st.Fetches, labels)
+	s.counter(ch, readerMessagesDesc, st.Messages, labels)
+	s.counter(ch, readerBytesDesc, st.Bytes, labels)
+	s.counter(ch, readerRebalancesDesc, st.Rebalances, labels)
+	s.counter(ch, readerTimeoutsDesc, st.Timeouts, labels)
+	s.counter(ch, readerErrorsDesc, st.Errors, labels)
+	s.duration(ch, readerDialTimeDesc, st.DialTime, labels)
+	s.duration(ch, readerReadTimeDesc, st.ReadTime, labels)
+	s.duration(ch, readerWaitTimeDesc, st.WaitTime, labels)
+	s.summary(ch, readerFetchSizeDesc, st.FetchSize, labels)
+	s.summary(ch, readerFetchBytesDesc, st.FetchBytes, labels)
+	statsGauge(ch, readerOffsetDesc, st.Offset, labels)
+	statsGauge(ch, readerLagDesc, st.Lag, labels)
+	statsGauge(ch, readerQueueLenDesc, st.QueueLength, labels)
+	statsGauge(ch, readerQueueCapDesc, st.QueueCapacity, labels)
+}
+
+// collectWriter публикует статистику писателя
+func (s *statsSource) collectWriter(ch chan<- prometheus.Metric, st kafka.WriterStats, labels ...string) {
+	s.counter(ch, writerWritesDesc, st.Writes, labels)
+	s.counter(ch, writerMessagesDesc, st.Messages, labels)
+	s.counter(ch, writerBytesDesc, st.Bytes, labels)
+	s.counter(ch, writerErrorsDesc, st.Errors, labels)
+	s.counter(ch, writerRetriesDesc, st.Retries, labels)
+	s.duration(ch, writerBatchTimeDesc, st.BatchTime, labels)
+	s.duration(ch, writerBatchQueueTimeDesc, st.BatchQueueTime, labels)
+	s.duration(ch, writerWriteTimeDesc, st.WriteTime, labels)
+	s.duration(ch, writerWaitTimeDesc, st.WaitTime, labels)
+	s.summary(ch, writerBatchSizeDesc, st.BatchSize, labels)
+	s.summary(ch, writerBatchBytesDesc, st.BatchBytes, labels)
+}
+
+// counter прибавляет прирост delta к счетчику и публикует накопленное значение
+func (s *statsSource) counter(ch chan<- prometheus.Metric, desc *prometheus.Desc, delta int64, labels []string) {
+	s.counters[desc] += float64(delta)
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, s.counters[desc], labels...)
+}
+
+// duration прибавляет наблюдения длительностей к summary в секундах
+func (s *statsSource) duration(ch chan<- prometheus.Metric, desc *prometheus.Desc, st kafka.DurationStats, labels []string) {
+	s.observe(ch, desc, st.Count, st.Sum.Seconds(), labels)
+}
+
+// summary прибавляет наблюдения размеров к summary
+func (s *statsSource) summary(ch chan<- prometheus.Metric, desc *prometheus.Desc, st kafka.SummaryStats, labels []string) {
+	s.observe(ch, desc, st.Count, float64(st.Sum), labels)
+}
+
+// observe накапливает количество и сумму наблюдений и публикует summary без квантилей
+func (s *statsSource) observe(ch chan<- prometheus.Metric, desc *prometheus.Desc, count int64, sum float64, labels []string) {
+	total := s.summaries[desc]
+	total.count += uint64(max(count, 0))
+	total.sum += sum
+	s.summaries[desc] = total
+	ch <- prometheus.MustNewConstSummary(desc, total.count, total.sum, nil, labels...)
+}
+
+// statsGauge публикует текущее значение
+func statsGauge(ch chan<- prometheus.Metric, desc *prometheus.Desc, value int64, labels []string) {
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(value), labels...)
+}
@@ -0,0 +1,177 @@
+package kafka
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeReaderStats отдает заданные снимки по очереди, как kafka.Reader: счетчики — прирост с прошлого вызова
+type fakeReaderStats struct {
+	snapshots []kafka.ReaderStats
+}
+
+func (f *fakeReaderStats) Stats() kafka.ReaderStats {
+	if len(f.snapshots) == 0 {
+		return kafka.ReaderStats{}
+	}
+	st := f.snapshots[0]
+	f.snapshots = f.snapshots[1:]
+	return st
+}
+
+// fakeWriterStats аналог fakeReaderStats для писателя
+type fakeWriterStats struct {
+	snapshots []kafka.WriterStats
+}
+
+func (f *fakeWriterStats) Stats() kafka.WriterStats {
+	if len(f.snapshots) == 0 {
+		return kafka.WriterStats{}
+	}
+	st := f.snapshots[0]
+	f.snapshots = f.snapshots[1:]
+	return st
+}
+
+// statsTopics топики, для которых collector публикует метрику name
+func statsTopics(t *testing.T, c prometheus.Collector, name string) []string {
+	t.Helper()
+	reg := prometheus.NewPedanticRegistry()
+	require.NoError(t, reg.Register(c))
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	var topics []string
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "topic" {
+					topics = append(topics, l.GetValue())
+				}
+			}
+		}
+	}
+	return topics
+}
+
+func TestStatsCollector_Reader(t *testing.T) {
+	c := newStatsCollector()
+	c.addReader(statsClientConsumer, "orders", &fakeReaderStats{snapshots: []kafka.ReaderStats{
+		{Messages: 10, Bytes: 1000, Errors: 1, Lag: 7, ReadTime: kafka.DurationStats{Count: 2, Sum: 500 * time.Millisecond}},
+		{Messages: 5, Bytes: 400, Rebalances: 1, Lag: 3, ReadTime: kafka.DurationStats{Count: 1, Sum: 250 * time.Millisecond}},
+	}})
+
+	// Первый сбор
+	require.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(`
+# HELP kafka_reader_messages_total Сообщения, прочитанные читателем
+# TYPE kafka_reader_messages_total counter
+kafka_reader_messages_total{client="consumer",topic="orders"} 10
+# HELP kafka_reader_lag Отставание читателя по назначенным ему партициям
+# TYPE kafka_reader_lag gauge
+kafka_reader_lag{client="consumer",topic="orders"} 7
+`), "kafka_reader_messages_total", "kafka_reader_lag"))
+
+	// Второй сбор: счетчики накапливаются, gauge — текущее значение
+	require.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(`
+# HELP kafka_reader_bytes_total Байты сообщений, прочитанных читателем
+# TYPE kafka_reader_bytes_total counter
+kafka_reader_bytes_total{client="consumer",topic="orders"} 1400
+# HELP kafka_reader_errors_total Ошибки читателя
+# TYPE kafka_reader_errors_total counter
+kafka_reader_errors_total{client="consumer",topic="orders"} 1
+# HELP kafka_reader_rebalances_total Ребалансировки группы читателя
+# TYPE kafka_reader_rebalances_total counter
+kafka_reader_rebalances_total{client="consumer",topic="orders"} 1
+# HELP kafka_reader_lag Отставание читателя по назначенным ему партициям
+# TYPE kafka_reader_lag gauge
+kafka_reader_lag{client="consumer",topic="orders"} 3
+# HELP kafka_reader_read_seconds Время чтения ответа fetch в секундах
+# TYPE kafka_reader_read_seconds summary
+kafka_reader_read_seconds_sum{client="consumer",topic="orders"} 0.75
+kafka_reader_read_seconds_count{client="consumer",topic="orders"} 3
+`), "kafka_reader_bytes_total", "kafka_reader_errors_total", "kafka_reader_rebalances_total", "kafka_reader_lag", "kafka_reader_read_seconds"))
+}
+
+func TestStatsCollector_Writer(t *testing.T) {
+	c := newStatsCollector()
+	c.addWriter(statsClientProducer, "orders", &fakeWriterStats{snapshots: []kafka.WriterStats{
+		{Writes: 2, Messages: 20, Retries: 1, BatchSize: kafka.SummaryStats{Count: 2, Sum: 20}},
+		{Writes: 1, Messages: 5, Errors: 1, BatchSize: kafka.SummaryStats{Count: 1, Sum: 5}},
+	}})
+	c.addWriter(statsClientDLQ, "orders-dlq", &fakeWriterStats{snapshots: []kafka.WriterStats{{Writes: 1, Messages: 1}}})
+
+	assert.Equal(t, 2, testutil.CollectAndCount(c, "kafka_writer_messages_total"))
+	require.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(`
+# HELP kafka_writer_messages_total Сообщения, записанные писателем
+# TYPE kafka_writer_messages_total counter
+kafka_writer_messages_total{client="dlq",topic="orders-dlq"} 1
+kafka_writer_messages_total{client="producer",topic="orders"} 25
+# HELP kafka_writer_errors_total Ошибки записи писателя
+# TYPE kafka_writer_errors_total counter
+kafka_writer_errors_total{client="dlq",topic="orders-dlq"} 0
+kafka_writer_errors_total{client="producer",topic="orders"} 1
+# HELP kafka_writer_retries_total Повторные попытки записи писателя
+# TYPE kafka_writer_retries_total counter
+kafka_writer_retries_total{client="dlq",topic="orders-dlq"} 0
+kafka_writer_retries_total{client="producer",topic="orders"} 1
+# HELP kafka_writer_batch_size Сообщений в пакете писателя
+# TYPE kafka_writer_batch_size summary
+kafka_writer_batch_size_sum{client="dlq",topic="orders-dlq"} 0
+kafka_writer_batch_size_count{client="dlq",topic="orders-dlq"} 0
+kafka_writer_batch_size_sum{client="producer",topic="orders"} 25
+kafka_writer_batch_size_count{client="producer",topic="orders"} 3
+`), "kafka_writer_messages_total", "kafka_writer_errors_total", "kafka_writer_retries_total", "kafka_writer_batch_size"))
+}
+
+func TestStatsCollector_Remove(t *testing.T) {
+	c := newStatsCollector()
+	first := &fakeWriterStats{snapshots: []kafka.WriterStats{{Messages: 3}}}
+	second := &fakeWriterStats{}
+	c.addWriter(statsClientProducer, "orders", first)
+	assert.Equal(t, 1, testutil.CollectAndCount(c, "kafka_writer_messages_total"))
+
+	// Новый писатель того же клиента и топика продолжает накопленные счетчики
+	c.addWriter(statsClientProducer, "orders", second)
+	require.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(`
+# HELP kafka_writer_messages_total Сообщения, записанные писателем
+# TYPE kafka_writer_messages_total counter
+kafka_writer_messages_total{client="producer",topic="orders"} 3
+`), "kafka_writer_messages_total"))
+
+	// Закрытие старого писателя не убирает новый
+	c.remove(statsClientProducer, "orders", first)
+	assert.Equal(t, 1, testutil.CollectAndCount(c, "kafka_writer_messages_total"))
+
+	c.remove(statsClientProducer, "orders", second)
+	assert.Zero(t, testutil.CollectAndCount(c, "kafka_writer_messages_total"))
+}
+
+func TestKafkaMetrics_StatsRegistered(t *testing.T) {
+	metrics := NewKafkaMetrics()
+	assert.Same(t, metrics.Stats, NewKafkaMetrics().Stats, "collector регистрируется один раз")
+
+	producer := NewProducer([]string{"localhost:9092"}, "stats-producer", DefaultWriterOptions())
+	dlq := NewDLQProducer([]string{"localhost:9092"}, "stats-dlq", DefaultWriterOptions())
+	consumer := NewConsumer([]string{"localhost:9092"}, "stats-consumer", "stats-group", DefaultConsumerOptions())
+
+	writers := statsTopics(t, metrics.Stats, "kafka_writer_messages_total")
+	assert.Contains(t, writers, "stats-producer")
+	assert.Contains(t, writers, "stats-dlq")
+	assert.Contains(t, statsTopics(t, metrics.Stats, "kafka_reader_messages_total"), "stats-consumer")
+
+	require.NoError(t, producer.Close())
+	require.NoError(t, dlq.Close())
+	require.NoError(t, consumer.Close())
+	assert.NotContains(t, statsTopics(t, metrics.Stats, "kafka_writer_messages_total"), "stats-producer")
+	assert.NotContains(t, statsTopics(t, metrics.Stats, "kafka_writer_messages_total"), "stats-dlq")
+	assert.NotContains(t, statsTopics(t, metrics.Stats, "kafka_reader_messages_total"), "stats-consumer")
+}
@@ -0,0 +1,56 @@
+package kafka
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// TLSConfig параметры TLS-подключения к брокерам (KAFKA_TLS_*)
+type TLSConfig struct {
+	Enabled            bool   // false — подключение без шифрования, остальные поля не используются
+	CAFile             string // PEM с сертификатами CA брокеров (пусто — системные CA)
+	CertFile           string // PEM с сертификатом клиента для mutual TLS
+	KeyFile            string // PEM с ключом сертификата клиента
+	InsecureSkipVerify bool   // Не проверять сертификат брокера (только для отладки)
+}
+
+// NewTLSConfig загружает сертификаты из cfg и создает *tls.Config для подключения к брокерам;
+// nil — TLS выключен. Вызывается один раз при запуске: ошибки чтения и разбора PEM
+// и несовпадение сертификата с ключом возвращаются сразу.
+func NewTLSConfig(cfg TLSConfig) (*tls.Config, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	tlsCfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.InsecureSkipVerify, // Явно включается KAFKA_TLS_INSECURE_SKIP_VERIFY
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось прочитать CA %s: %w", cfg.CAFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("в файле CA %s нет сертификатов PEM", cfg.CAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	switch {
+	case cfg.CertFile == "" && cfg.KeyFile == "":
+	case cfg.CertFile == "" || cfg.KeyFile == "":
+		return nil, errors.New("для mutual TLS нужны и сертификат, и ключ клиента")
+	default:
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось загрузить сертификат клиента %s с ключом %s: %w", cfg.CertFile, cfg.KeyFile, err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+	return tlsCfg, nil
+}
@@ -0,0 +1,140 @@
+package kafka
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestCert создает самоподписанный сертификат и ключ в dir и возвращает пути к PEM-файлам
+func writeTestCert(t *testing.T, dir, name string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	caFile, _ := writeTestCert(t, dir, "ca")
+	certFile, keyFile := writeTestCert(t, dir, "client")
+	_, otherKeyFile := writeTestCert(t, dir, "other")
+	notPEM := filepath.Join(dir, "not.pem")
+	require.NoError(t, os.WriteFile(notPEM, []byte("not a certificate"), 0o600))
+	missing := filepath.Join(dir, "missing.pem")
+
+	tests := []struct {
+		name    string
+		cfg     TLSConfig
+		check   func(t *testing.T, tlsCfg *tls.Config)
+		wantErr string
+	}{
+		{
+			name: "Disabled",
+			cfg:  TLSConfig{CAFile: missing},
+			check: func(t *testing.T, tlsCfg *tls.Config) {
+				assert.Nil(t, tlsCfg, "без KAFKA_TLS_ENABLED файлы не читаются")
+			},
+		},
+		{
+			name: "SystemCA",
+			cfg:  TLSConfig{Enabled: true},
+			check: func(t *testing.T, tlsCfg *tls.Config) {
+				require.NotNil(t, tlsCfg)
+				assert.Nil(t, tlsCfg.RootCAs)
+				assert.Empty(t, tlsCfg.Certificates)
+				assert.False(t, tlsCfg.InsecureSkipVerify)
+				assert.Equal(t, uint16(tls.VersionTLS12), tlsCfg.MinVersion)
+			},
+		},
+		{
+			name: "CustomCA",
+			cfg:  TLSConfig{Enabled: true, CAFile: caFile},
+			check: func(t *testing.T, tlsCfg *tls.Config) {
+				require.NotNil(t, tlsCfg.RootCAs)
+				assert.Empty(t, tlsCfg.Certificates)
+			},
+		},
+		{
+			name: "MutualTLS",
+			cfg:  TLSConfig{Enabled: true, CAFile: caFile, CertFile: certFile, KeyFile: keyFile},
+			check: func(t *testing.T, tlsCfg *tls.Config) {
+				require.NotNil(t, tlsCfg.RootCAs)
+				assert.Len(t, tlsCfg.Certificates, 1)
+			},
+		},
+		{
+			name: "InsecureSkipVerify",
+			cfg:  TLSConfig{Enabled: true, InsecureSkipVerify: true},
+			check: func(t *testing.T, tlsCfg *tls.Config) {
+				assert.True(t, tlsCfg.InsecureSkipVerify)
+			},
+		},
+		{name: "MissingCA", cfg: TLSConfig{Enabled: true, CAFile: missing}, wantErr: "не удалось прочитать CA"},
+		{name: "CANotPEM", cfg: TLSConfig{Enabled: true, CAFile: notPEM}, wantErr: "нет сертификатов PEM"},
+		{name: "CertWithoutKey", cfg: TLSConfig{Enabled: true, CertFile: certFile}, wantErr: "и сертификат, и ключ"},
+		{name: "KeyWithoutCert", cfg: TLSConfig{Enabled: true, KeyFile: keyFile}, wantErr: "и сертификат, и ключ"},
+		{name: "MissingCert", cfg: TLSConfig{Enabled: true, CertFile: missing, KeyFile: keyFile}, wantErr: "не удалось загрузить сертификат клиента"},
+		{name: "MismatchedKeyPair", cfg: TLSConfig{Enabled: true, CertFile: certFile, KeyFile: otherKeyFile}, wantErr: "не удалось загрузить сертификат клиента"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tlsCfg, err := NewTLSConfig(tt.cfg)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			tt.check(t, tlsCfg)
+		})
+	}
+}
+
+func TestNewConnectionTLS(t *testing.T) {
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	conn, err := NewConnection(SASLConfig{}, tlsCfg)
+	require.NoError(t, err)
+	require.NotNil(t, conn.dialer)
+	assert.Same(t, tlsCfg, conn.dialer.TLS)
+	assert.Nil(t, conn.dialer.SASLMechanism)
+
+	// SASL и TLS вместе
+	conn, err = NewConnection(SASLConfig{Mechanism: SASLPlain, Username: "orders", Password: "secret"}, tlsCfg)
+	require.NoError(t, err)
+	assert.Same(t, tlsCfg, conn.dialer.TLS)
+	assert.Equal(t, SASLPlain, conn.dialer.SASLMechanism.Name())
+	transport, ok := conn.transport.(*kafka.Transport)
+	require.True(t, ok)
+	assert.Same(t, tlsCfg, transport.TLS)
+}
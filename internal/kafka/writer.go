@@ -0,0 +1,63 @@
+package kafka
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// WriterOptions настройки писателей пакета: Producer, DLQProducer и RetryProducer (KAFKA_REQUIRED_ACKS и др.)
+type WriterOptions struct {
+	RequiredAcks kafka.RequiredAcks // Подтверждения записи: RequireAll, RequireOne или RequireNone
+	WriteTimeout time.Duration      // Таймаут записи
+	MaxAttempts  int                // Попыток записи в kafka-go до ошибки
+	BatchSize    int                // Сообщений в пакете
+	BatchTimeout time.Duration      // Ожидание заполнения пакета перед отправкой
+	Compression  kafka.Compression  // Сжатие сообщений (KAFKA_COMPRESSION); 0 — без сжатия
+	Connection   Connection         // SASL и TLS подключения к брокерам
+}
+
+// DefaultWriterOptions настройки писателей по умолчанию: подтверждение всех реплик, как раньше
+func DefaultWriterOptions() WriterOptions {
+	return WriterOptions{
+		RequiredAcks: kafka.RequireAll,
+		WriteTimeout: 10 * time.Second,
+		MaxAttempts:  3,
+		BatchSize:    100,         // Как в kafka-go по умолчанию
+		BatchTimeout: time.Second, // Как в kafka-go по умолчанию
+	}
+}
+
+// ParseRequiredAcks возвращает уровень подтверждения записи по имени (KAFKA_REQUIRED_ACKS): all, one или none
+func ParseRequiredAcks(name string) (kafka.RequiredAcks, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "all":
+		return kafka.RequireAll, nil
+	case "one":
+		return kafka.RequireOne, nil
+	case "none":
+		return kafka.RequireNone, nil
+	default:
+		return 0, fmt.Errorf("неизвестный уровень подтверждения записи %q: поддерживаются all, one и none", name)
+	}
+}
+
+// newWriter создает писателя топика topic с настройками opts, включая SASL, TLS и сжатие;
+// балансировщик задает вызывающий
+func newWriter(brokers []string, topic string, opts WriterOptions) *kafka.Writer {
+	return &kafka.Writer{
+		Addr:                   kafka.TCP(brokers...),
+		Topic:                  topic,
+		WriteTimeout:           opts.WriteTimeout,
+		ReadTimeout:            10 * time.Second,
+		RequiredAcks:           opts.RequiredAcks,
+		MaxAttempts:            opts.MaxAttempts,
+		BatchSize:              opts.BatchSize,
+		BatchTimeout:           opts.BatchTimeout,
+		AllowAutoTopicCreation: true,
+		Transport:              opts.Connection.transport, // SASL и TLS
+		Compression:            opts.Compression,
+	}
+}
@@ -0,0 +1,71 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRequiredAcks(t *testing.T) {
+	for name, want := range map[string]kafka.RequiredAcks{
+		"all":   kafka.RequireAll,
+		"One":   kafka.RequireOne,
+		"none ": kafka.RequireNone,
+	} {
+		acks, err := ParseRequiredAcks(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, want, acks, name)
+	}
+
+	_, err := ParseRequiredAcks("2")
+	assert.ErrorContains(t, err, "неизвестный уровень подтверждения")
+}
+
+func TestNewWriter(t *testing.T) {
+	opts := WriterOptions{
+		RequiredAcks: kafka.RequireOne,
+		WriteTimeout: 3 * time.Second,
+		MaxAttempts:  7,
+		BatchSize:    500,
+		BatchTimeout: 20 * time.Millisecond,
+	}
+	writer := newWriter([]string{"kafka-1:9092", "kafka-2:9092"}, "orders", opts)
+
+	assert.Equal(t, "kafka-1:9092,kafka-2:9092", writer.Addr.String())
+	assert.Equal(t, "orders", writer.Topic)
+	assert.Equal(t, kafka.RequireOne, writer.RequiredAcks)
+	assert.Equal(t, 3*time.Second, writer.WriteTimeout)
+	assert.Equal(t, 7, writer.MaxAttempts)
+	assert.Equal(t, 500, writer.BatchSize)
+	assert.Equal(t, 20*time.Millisecond, writer.BatchTimeout)
+	assert.True(t, writer.AllowAutoTopicCreation)
+}
+
+func TestWriterOptionsPassedToConstructors(t *testing.T) {
+	conn, err := NewConnection(SASLConfig{Mechanism: SASLPlain, Username: "orders", Password: "secret"}, nil)
+	require.NoError(t, err)
+	opts := DefaultWriterOptions()
+	opts.RequiredAcks = kafka.RequireNone
+	opts.BatchSize = 1
+	opts.Connection = conn
+
+	producer := NewProducer([]string{"localhost:9092"}, "orders", opts)
+	dlqProducer := NewDLQProducer([]string{"localhost:9092"}, "orders-dlq", opts)
+	retryProducer := NewRetryProducer([]string{"localhost:9092"}, "orders-retry", nil, opts)
+	for name, writer := range map[string]*kafka.Writer{
+		"producer": producer.writer.(*kafka.Writer),
+		"dlq":      dlqProducer.writer.(*kafka.Writer),
+		"retry":    retryProducer.writer,
+	} {
+		assert.Equal(t, kafka.RequireNone, writer.RequiredAcks, name)
+		assert.Equal(t, 1, writer.BatchSize, name)
+		assert.Equal(t, 10*time.Second, writer.WriteTimeout, name)
+		assert.Same(t, conn.transport, writer.Transport, name)
+	}
+	assert.IsType(t, &kafka.Hash{}, producer.writer.(*kafka.Writer).Balancer)
+	assert.IsType(t, &kafka.LeastBytes{}, dlqProducer.writer.(*kafka.Writer).Balancer)
+	assert.IsType(t, &kafka.Hash{}, retryProducer.writer.Balancer)
+}
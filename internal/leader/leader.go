@@ -0,0 +1,119 @@
+// Package leader реализует выбор единственного исполнителя среди реплик сервиса
+package leader
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Lock захваченная блокировка лидера
+type Lock interface {
+	// Ping проверяет, что блокировка все еще удерживается
+	Ping(ctx context.Context) error
+	// Unlock освобождает блокировку
+	Unlock(ctx context.Context) error
+}
+
+// Locker пытается без ожидания захватить блокировку лидера
+type Locker func(ctx context.Context) (Lock, bool, error)
+
+// Elector запускает работу только в том экземпляре, который удерживает блокировку.
+// Остальные экземпляры периодически пытаются перехватить лидерство.
+type Elector struct {
+	name     string           // Имя задачи для логов
+	locker   Locker           // Источник блокировки
+	interval time.Duration    // Период попыток захвата и проверки блокировки
+	gauge    prometheus.Gauge // Метрика лидерства (1 — лидер, 0 — нет), может быть nil
+}
+
+// NewElector создает Elector
+func NewElector(name string, locker Locker, interval time.Duration, gauge prometheus.Gauge) *Elector {
+	return &Elector{
+		name:     name,
+		locker:   locker,
+		interval: interval,
+		gauge:    gauge,
+	}
+}
+
+// Run блокируется до отмены ctx. Пока экземпляр лидер, work выполняется с контекстом,
+// который отменяется при потере блокировки или остановке; при остановке блокировка освобождается.
+func (e *Elector) Run(ctx context.Context, work func(ctx context.Context)) {
+	e.setLeader(false)
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		lock, acquired, err := e.locker(ctx)
+		if err != nil {
+			log.Printf("Ошибка захвата лидерства %s: %v", e.name, err)
+		} else if acquired {
+			e.lead(ctx, lock, work)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// lead выполняет работу, пока удерживается блокировка
+func (e *Elector) lead(ctx context.Context, lock Lock, work func(ctx context.Context)) {
+	log.Printf("Экземпляр стал лидером: %s", e.name)
+	e.setLeader(true)
+
+	workCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		work(workCtx)
+	}()
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-done:
+			break loop
+		case <-ticker.C:
+			if err := lock.Ping(ctx); err != nil {
+				log.Printf("Лидерство %s потеряно: %v", e.name, err)
+				break loop
+			}
+		}
+	}
+
+	// Останавливаем работу до освобождения блокировки, чтобы не было двух лидеров одновременно
+	cancel()
+	<-done
+	e.setLeader(false)
+
+	unlockCtx, unlockCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer unlockCancel()
+	if err := lock.Unlock(unlockCtx); err != nil {
+		log.Printf("Ошибка освобождения лидерства %s: %v", e.name, err)
+	}
+	log.Printf("Экземпляр больше не лидер: %s", e.name)
+}
+
+// setLeader обновляет метрику лидерства
+func (e *Elector) setLeader(leader bool) {
+	if e.gauge == nil {
+		return
+	}
+	if leader {
+		e.gauge.Set(1)
+	} else {
+		e.gauge.Set(0)
+	}
+}
@@ -0,0 +1,101 @@
+//go:build integration
+
+package leader_test
+
+import (
+	"context"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"test_service/internal/database"
+	"test_service/internal/leader"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testLockKey ключ, не пересекающийся с ключом демо-продюсера
+const testLockKey int64 = 0x7465737431
+
+// newInstance создает отдельный пул соединений — аналог отдельной реплики сервиса
+func newInstance(t *testing.T, ctx context.Context, dsn string) *database.Postgres {
+	t.Helper()
+	db, err := database.NewPostgres(ctx, dsn, database.PoolConfig{})
+	require.NoError(t, err)
+	return db
+}
+
+func locker(db *database.Postgres) leader.Locker {
+	return func(ctx context.Context) (leader.Lock, bool, error) {
+		lock, acquired, err := db.TryAdvisoryLock(ctx, testLockKey)
+		if err != nil || !acquired {
+			return nil, false, err
+		}
+		return lock, true, nil
+	}
+}
+
+func TestElector_PostgresAdvisoryLock(t *testing.T) {
+	dsn := os.Getenv("POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_DSN не задан")
+	}
+
+	ctx := context.Background()
+	dbA := newInstance(t, ctx, dsn)
+	dbB := newInstance(t, ctx, dsn)
+	defer dbB.Close()
+
+	var running atomic.Int32
+	var overlap atomic.Bool
+	leaders := make(chan string, 10)
+	work := func(name string) func(context.Context) {
+		return func(ctx context.Context) {
+			if running.Add(1) > 1 {
+				overlap.Store(true)
+			}
+			leaders <- name
+			<-ctx.Done()
+			running.Add(-1)
+		}
+	}
+
+	ctxA, cancelA := context.WithCancel(ctx)
+	ctxB, cancelB := context.WithCancel(ctx)
+	defer cancelB()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		leader.NewElector("a", locker(dbA), 100*time.Millisecond, nil).Run(ctxA, work("a"))
+	}()
+	// Второй экземпляр стартует позже, чтобы лидером гарантированно стал первый
+	time.Sleep(300 * time.Millisecond)
+	go func() {
+		defer wg.Done()
+		leader.NewElector("b", locker(dbB), 100*time.Millisecond, nil).Run(ctxB, work("b"))
+	}()
+
+	require.Equal(t, "a", <-leaders)
+	time.Sleep(500 * time.Millisecond)
+	assert.Equal(t, int32(1), running.Load(), "генерировать должен ровно один экземпляр")
+
+	// Остановка лидера освобождает блокировку, второй экземпляр перехватывает лидерство
+	cancelA()
+	dbA.Close()
+
+	select {
+	case name := <-leaders:
+		assert.Equal(t, "b", name)
+	case <-time.After(5 * time.Second):
+		t.Fatal("лидерство не перешло ко второму экземпляру")
+	}
+
+	cancelB()
+	wg.Wait()
+	assert.False(t, overlap.Load(), "два лидера не должны работать одновременно")
+}
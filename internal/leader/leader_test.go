@@ -0,0 +1,122 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLockState общая для всех экземпляров блокировка в памяти
+type fakeLockState struct {
+	mu     sync.Mutex
+	holder string
+}
+
+type fakeLock struct {
+	state *fakeLockState
+	owner string
+	lost  atomic.Bool
+}
+
+func (l *fakeLock) Ping(context.Context) error {
+	if l.lost.Load() {
+		return errors.New("connection lost")
+	}
+	return nil
+}
+
+func (l *fakeLock) Unlock(context.Context) error {
+	l.state.mu.Lock()
+	defer l.state.mu.Unlock()
+	if l.state.holder == l.owner {
+		l.state.holder = ""
+	}
+	return nil
+}
+
+func (s *fakeLockState) locker(owner string, locks chan<- *fakeLock) Locker {
+	return func(context.Context) (Lock, bool, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.holder != "" {
+			return nil, false, nil
+		}
+		s.holder = owner
+		lock := &fakeLock{state: s, owner: owner}
+		if locks != nil {
+			locks <- lock
+		}
+		return lock, true, nil
+	}
+}
+
+func TestElector_SingleLeaderAndRelease(t *testing.T) {
+	state := &fakeLockState{}
+	var running atomic.Int32
+	var maxRunning atomic.Int32
+
+	work := func(ctx context.Context) {
+		n := running.Add(1)
+		if n > maxRunning.Load() {
+			maxRunning.Store(n)
+		}
+		<-ctx.Done()
+		running.Add(-1)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for _, name := range []string{"a", "b"} {
+		elector := NewElector("test", state.locker(name, nil), 10*time.Millisecond, nil)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			elector.Run(ctx, work)
+		}()
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int32(1), running.Load(), "работу должен выполнять ровно один экземпляр")
+
+	cancel()
+	wg.Wait()
+	assert.Equal(t, int32(1), maxRunning.Load())
+	assert.Empty(t, state.holder, "блокировка должна быть освобождена при остановке")
+}
+
+func TestElector_FailoverOnLostLock(t *testing.T) {
+	state := &fakeLockState{}
+	locks := make(chan *fakeLock, 10)
+	leaders := make(chan string, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for _, name := range []string{"a", "b"} {
+		name := name
+		elector := NewElector("test", state.locker(name, locks), 10*time.Millisecond, nil)
+		go elector.Run(ctx, func(ctx context.Context) {
+			leaders <- name
+			<-ctx.Done()
+		})
+	}
+
+	first := <-locks
+	require.Equal(t, first.owner, <-leaders)
+
+	// Лидер теряет соединение: работа останавливается, блокировку перехватывает другой экземпляр
+	first.lost.Store(true)
+
+	select {
+	case second := <-locks:
+		assert.NotNil(t, second)
+	case <-time.After(2 * time.Second):
+		t.Fatal("лидерство не было перехвачено")
+	}
+}
@@ -0,0 +1,156 @@
+// Package lifecycle управляет запуском и остановкой компонентов сервиса
+// и сообщает балансировщику о предстоящей остановке через readiness
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// component зарегистрированный компонент сервиса
+type component struct {
+	name  string
+	start func() error
+	stop  func(ctx context.Context) error
+}
+
+// Lifecycle запускает компоненты в порядке регистрации и останавливает в обратном.
+// При остановке сначала выставляется флаг shuttingDown (readiness начинает отвечать 503),
+// затем выдерживается окно drain, чтобы балансировщик успел исключить экземпляр,
+// и только потом останавливаются компоненты.
+type Lifecycle struct {
+	drain        time.Duration // Окно между сменой readiness и остановкой компонентов
+	shuttingDown atomic.Bool   // Выставляется в начале остановки
+	inFlight     atomic.Int64  // Количество обрабатываемых HTTP запросов
+	metrics      *Metrics
+
+	mu         sync.Mutex
+	components []component
+	started    int // Количество успешно запущенных компонентов
+}
+
+// New создает Lifecycle с окном drain
+func New(drain time.Duration) *Lifecycle {
+	return &Lifecycle{
+		drain:   drain,
+		metrics: NewMetrics(),
+	}
+}
+
+// Append регистрирует компонент. start не должен блокироваться; stop должен
+// вернуть управление не позже отмены ctx.
+func (l *Lifecycle) Append(name string, start func() error, stop func(ctx context.Context) error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.components = append(l.components, component{name: name, start: start, stop: stop})
+}
+
+// Go регистрирует фоновую задачу: run выполняется в отдельной горутине до отмены
+// своего контекста; при остановке контекст отменяется и Lifecycle ждет завершения run.
+func (l *Lifecycle) Go(name string, run func(ctx context.Context)) {
+	var (
+		cancel context.CancelFunc
+		done   chan struct{}
+	)
+	l.Append(name, func() error {
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		done = make(chan struct{})
+		go func() {
+			defer close(done)
+			run(ctx)
+		}()
+		return nil
+	}, func(ctx context.Context) error {
+		cancel()
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return fmt.Errorf("таймаут ожидания остановки: %w", ctx.Err())
+		}
+	})
+}
+
+// Start запускает компоненты в порядке регистрации. При ошибке уже запущенные
+// компоненты остаются запущенными и будут остановлены Shutdown.
+func (l *Lifecycle) Start() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for l.started < len(l.components) {
+		c := l.components[l.started]
+		if err := c.start(); err != nil {
+			return fmt.Errorf("ошибка запуска %s: %w", c.name, err)
+		}
+		l.started++
+	}
+	return nil
+}
+
+// Shutdown выставляет флаг остановки, выдерживает окно drain и останавливает
+// запущенные компоненты в обратном порядке. Ошибки остановки объединяются.
+func (l *Lifecycle) Shutdown(ctx context.Context) error {
+	l.shuttingDown.Store(true)
+	l.metrics.ShuttingDown.Set(1)
+
+	if l.drain > 0 {
+		log.Printf("Readiness снята, ожидание %s перед остановкой (запросов в обработке: %d)", l.drain, l.InFlight())
+		timer := time.NewTimer(l.drain)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var errs []error
+	for ; l.started > 0; l.started-- {
+		c := l.components[l.started-1]
+		if err := c.stop(ctx); err != nil {
+			log.Printf("Ошибка остановки %s: %v", c.name, err)
+			errs = append(errs, fmt.Errorf("%s: %w", c.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ShuttingDown сообщает, что остановка началась
+func (l *Lifecycle) ShuttingDown() bool {
+	return l.shuttingDown.Load()
+}
+
+// Ready сообщает, готов ли экземпляр принимать трафик
+func (l *Lifecycle) Ready() bool {
+	return !l.ShuttingDown()
+}
+
+// InFlight возвращает количество обрабатываемых HTTP запросов
+func (l *Lifecycle) InFlight() int64 {
+	return l.inFlight.Load()
+}
+
+// Track учитывает обрабатываемые запросы. Во время остановки ответы получают
+// Connection: close, чтобы keep-alive клиенты переподключались к другим экземплярам.
+func (l *Lifecycle) Track(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l.metrics.InFlightRequests.Set(float64(l.inFlight.Add(1)))
+		defer func() {
+			l.metrics.InFlightRequests.Set(float64(l.inFlight.Add(-1)))
+		}()
+
+		if l.ShuttingDown() {
+			w.Header().Set("Connection", "close")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
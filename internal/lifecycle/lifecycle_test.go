@@ -0,0 +1,162 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLifecycle_Order(t *testing.T) {
+	lc := New(0)
+
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	record := func(e string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e)
+	}
+
+	for _, name := range []string{"consumer", "producer", "http"} {
+		name := name
+		lc.Append(name, func() error {
+			record("start " + name)
+			return nil
+		}, func(context.Context) error {
+			record("stop " + name)
+			return nil
+		})
+	}
+
+	require.NoError(t, lc.Start())
+	require.NoError(t, lc.Shutdown(context.Background()))
+
+	assert.Equal(t, []string{
+		"start consumer", "start producer", "start http",
+		"stop http", "stop producer", "stop consumer",
+	}, events)
+}
+
+func TestLifecycle_StartErrorStopsOnlyStarted(t *testing.T) {
+	lc := New(0)
+
+	var stopped []string
+	lc.Append("ok", func() error { return nil }, func(context.Context) error {
+		stopped = append(stopped, "ok")
+		return nil
+	})
+	lc.Append("broken", func() error { return errors.New("address in use") }, func(context.Context) error {
+		stopped = append(stopped, "broken")
+		return nil
+	})
+
+	err := lc.Start()
+	assert.ErrorContains(t, err, "broken")
+
+	require.NoError(t, lc.Shutdown(context.Background()))
+	assert.Equal(t, []string{"ok"}, stopped)
+}
+
+func TestLifecycle_Go(t *testing.T) {
+	t.Run("CancelsAndWaits", func(t *testing.T) {
+		lc := New(0)
+
+		finished := false
+		lc.Go("worker", func(ctx context.Context) {
+			<-ctx.Done()
+			time.Sleep(10 * time.Millisecond)
+			finished = true
+		})
+
+		require.NoError(t, lc.Start())
+		require.NoError(t, lc.Shutdown(context.Background()))
+		assert.True(t, finished, "Shutdown должен дождаться завершения задачи")
+	})
+
+	t.Run("StopTimeout", func(t *testing.T) {
+		lc := New(0)
+
+		release := make(chan struct{})
+		defer close(release)
+		lc.Go("stuck", func(context.Context) { <-release })
+
+		require.NoError(t, lc.Start())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		err := lc.Shutdown(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestLifecycle_ReadinessFlipsBeforeDrain(t *testing.T) {
+	lc := New(100 * time.Millisecond)
+
+	stopped := make(chan struct{})
+	lc.Append("http", func() error { return nil }, func(context.Context) error {
+		close(stopped)
+		return nil
+	})
+	require.NoError(t, lc.Start())
+	assert.True(t, lc.Ready())
+
+	done := make(chan error, 1)
+	go func() { done <- lc.Shutdown(context.Background()) }()
+
+	// Readiness снимается сразу, а компоненты останавливаются только после окна drain
+	assert.Eventually(t, lc.ShuttingDown, time.Second, time.Millisecond)
+	assert.False(t, lc.Ready())
+	select {
+	case <-stopped:
+		t.Fatal("компонент остановлен до окончания окна drain")
+	default:
+	}
+
+	require.NoError(t, <-done)
+	<-stopped
+}
+
+func TestLifecycle_DrainEndsWithContext(t *testing.T) {
+	lc := New(time.Hour)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	require.NoError(t, lc.Shutdown(ctx))
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestLifecycle_Track(t *testing.T) {
+	lc := New(0)
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	h := lc.Track(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+	}))
+
+	rec := httptest.NewRecorder()
+	go h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	<-entered
+	assert.Equal(t, int64(1), lc.InFlight())
+
+	close(release)
+	assert.Eventually(t, func() bool { return lc.InFlight() == 0 }, time.Second, time.Millisecond)
+
+	// Во время остановки keep-alive соединения закрываются после ответа
+	require.NoError(t, lc.Shutdown(context.Background()))
+	rec = httptest.NewRecorder()
+	lc.Track(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, "close", rec.Header().Get("Connection"))
+}
@@ -0,0 +1,36 @@
+package lifecycle
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metrics содержит метрики жизненного цикла сервиса
+type Metrics struct {
+	InFlightRequests prometheus.Gauge
+	ShuttingDown     prometheus.Gauge
+}
+
+// Global metrics для предотвращения дублирования метрик
+var globalMetrics *Metrics
+
+// NewMetrics создает и регистрирует метрики жизненного цикла
+func NewMetrics() *Metrics {
+	// Возвращаем глобальный экземпляр, чтобы избежать дублирования метрик
+	if globalMetrics != nil {
+		return globalMetrics
+	}
+
+	globalMetrics = &Metrics{
+		InFlightRequests: promauto.NewGauge(prometheus.GaugeOpts{
+			Name: "http_requests_in_flight",
+			Help: "Количество HTTP запросов в обработке",
+		}),
+		ShuttingDown: promauto.NewGauge(prometheus.GaugeOpts{
+			Name: "service_shutting_down",
+			Help: "Экземпляр останавливается (1) или работает (0)",
+		}),
+	}
+
+	return globalMetrics
+}
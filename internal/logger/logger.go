@@ -0,0 +1,29 @@
+// Package logger настраивает общий для сервиса логгер
+package logger
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Поддерживаемые форматы логов
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Setup устанавливает slog-логгер по умолчанию в заданном формате.
+// Вывод стандартного пакета log также проходит через этот логгер.
+func Setup(format string) *slog.Logger {
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case FormatJSON:
+		handler = slog.NewJSONHandler(os.Stderr, nil)
+	default:
+		handler = slog.NewTextHandler(os.Stderr, nil)
+	}
+	l := slog.New(handler)
+	slog.SetDefault(l)
+	return l
+}
@@ -6,10 +6,14 @@ package mocks
 
 import (
 	context "context"
+	io "io"
 	reflect "reflect"
+	interfaces "test_service/internal/interfaces"
 	models "test_service/internal/models"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
+	kafka "github.com/segmentio/kafka-go"
 )
 
 // MockDatabase is a mock of Database interface.
@@ -35,6 +39,21 @@ func (m *MockDatabase) EXPECT() *MockDatabaseMockRecorder {
 	return m.recorder
 }
 
+// ArchiveOrdersBefore mocks base method.
+func (m *MockDatabase) ArchiveOrdersBefore(ctx context.Context, cutoff time.Time, batchSize int) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ArchiveOrdersBefore", ctx, cutoff, batchSize)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ArchiveOrdersBefore indicates an expected call of ArchiveOrdersBefore.
+func (mr *MockDatabaseMockRecorder) ArchiveOrdersBefore(ctx, cutoff, batchSize interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ArchiveOrdersBefore", reflect.TypeOf((*MockDatabase)(nil).ArchiveOrdersBefore), ctx, cutoff, batchSize)
+}
+
 // Close mocks base method.
 func (m *MockDatabase) Close() {
 	m.ctrl.T.Helper()
@@ -47,34 +66,189 @@ func (mr *MockDatabaseMockRecorder) Close() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockDatabase)(nil).Close))
 }
 
+// CountOrders mocks base method.
+func (m *MockDatabase) CountOrders(ctx context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountOrders", ctx)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountOrders indicates an expected call of CountOrders.
+func (mr *MockDatabaseMockRecorder) CountOrders(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountOrders", reflect.TypeOf((*MockDatabase)(nil).CountOrders), ctx)
+}
+
+// CountOrdersSince mocks base method.
+func (m *MockDatabase) CountOrdersSince(ctx context.Context, since time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountOrdersSince", ctx, since)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountOrdersSince indicates an expected call of CountOrdersSince.
+func (mr *MockDatabaseMockRecorder) CountOrdersSince(ctx, since interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountOrdersSince", reflect.TypeOf((*MockDatabase)(nil).CountOrdersSince), ctx, since)
+}
+
+// DeleteOrder mocks base method.
+func (m *MockDatabase) DeleteOrder(ctx context.Context, orderUID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteOrder", ctx, orderUID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteOrder indicates an expected call of DeleteOrder.
+func (mr *MockDatabaseMockRecorder) DeleteOrder(ctx, orderUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOrder", reflect.TypeOf((*MockDatabase)(nil).DeleteOrder), ctx, orderUID)
+}
+
+// FindOrdersByContact mocks base method.
+func (m *MockDatabase) FindOrdersByContact(ctx context.Context, email, phone string, limit int, opts ...interfaces.ReadOption) ([]models.Order, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, email, phone, limit}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "FindOrdersByContact", varargs...)
+	ret0, _ := ret[0].([]models.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindOrdersByContact indicates an expected call of FindOrdersByContact.
+func (mr *MockDatabaseMockRecorder) FindOrdersByContact(ctx, email, phone, limit interface{}, opts ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, email, phone, limit}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOrdersByContact", reflect.TypeOf((*MockDatabase)(nil).FindOrdersByContact), varargs...)
+}
+
 // GetAllOrders mocks base method.
-func (m *MockDatabase) GetAllOrders(ctx context.Context) ([]models.Order, error) {
+func (m *MockDatabase) GetAllOrders(ctx context.Context, opts ...interfaces.ReadOption) ([]models.Order, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAllOrders", ctx)
+	varargs := []interface{}{ctx}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetAllOrders", varargs...)
 	ret0, _ := ret[0].([]models.Order)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetAllOrders indicates an expected call of GetAllOrders.
-func (mr *MockDatabaseMockRecorder) GetAllOrders(ctx interface{}) *gomock.Call {
+func (mr *MockDatabaseMockRecorder) GetAllOrders(ctx interface{}, opts ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllOrders", reflect.TypeOf((*MockDatabase)(nil).GetAllOrders), ctx)
+	varargs := append([]interface{}{ctx}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllOrders", reflect.TypeOf((*MockDatabase)(nil).GetAllOrders), varargs...)
 }
 
 // GetOrder mocks base method.
-func (m *MockDatabase) GetOrder(ctx context.Context, orderUID string) (*models.Order, error) {
+func (m *MockDatabase) GetOrder(ctx context.Context, orderUID string, opts ...interfaces.ReadOption) (*models.Order, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetOrder", ctx, orderUID)
+	varargs := []interface{}{ctx, orderUID}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetOrder", varargs...)
 	ret0, _ := ret[0].(*models.Order)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetOrder indicates an expected call of GetOrder.
-func (mr *MockDatabaseMockRecorder) GetOrder(ctx, orderUID interface{}) *gomock.Call {
+func (mr *MockDatabaseMockRecorder) GetOrder(ctx, orderUID interface{}, opts ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, orderUID}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrder", reflect.TypeOf((*MockDatabase)(nil).GetOrder), varargs...)
+}
+
+// GetOrderByTrackNumber mocks base method.
+func (m *MockDatabase) GetOrderByTrackNumber(ctx context.Context, trackNumber string, opts ...interfaces.ReadOption) ([]models.Order, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, trackNumber}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetOrderByTrackNumber", varargs...)
+	ret0, _ := ret[0].([]models.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrderByTrackNumber indicates an expected call of GetOrderByTrackNumber.
+func (mr *MockDatabaseMockRecorder) GetOrderByTrackNumber(ctx, trackNumber interface{}, opts ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, trackNumber}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderByTrackNumber", reflect.TypeOf((*MockDatabase)(nil).GetOrderByTrackNumber), varargs...)
+}
+
+// GetOrdersByCustomerID mocks base method.
+func (m *MockDatabase) GetOrdersByCustomerID(ctx context.Context, customerID string, limit, offset int, opts ...interfaces.ReadOption) ([]models.Order, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, customerID, limit, offset}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetOrdersByCustomerID", varargs...)
+	ret0, _ := ret[0].([]models.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrdersByCustomerID indicates an expected call of GetOrdersByCustomerID.
+func (mr *MockDatabaseMockRecorder) GetOrdersByCustomerID(ctx, customerID, limit, offset interface{}, opts ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrder", reflect.TypeOf((*MockDatabase)(nil).GetOrder), ctx, orderUID)
+	varargs := append([]interface{}{ctx, customerID, limit, offset}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrdersByCustomerID", reflect.TypeOf((*MockDatabase)(nil).GetOrdersByCustomerID), varargs...)
+}
+
+// GetOrdersPage mocks base method.
+func (m *MockDatabase) GetOrdersPage(ctx context.Context, cursor string, limit int, opts ...interfaces.ReadOption) ([]models.Order, string, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, cursor, limit}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetOrdersPage", varargs...)
+	ret0, _ := ret[0].([]models.Order)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetOrdersPage indicates an expected call of GetOrdersPage.
+func (mr *MockDatabaseMockRecorder) GetOrdersPage(ctx, cursor, limit interface{}, opts ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, cursor, limit}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrdersPage", reflect.TypeOf((*MockDatabase)(nil).GetOrdersPage), varargs...)
+}
+
+// GetOrdersSince mocks base method.
+func (m *MockDatabase) GetOrdersSince(ctx context.Context, since time.Time, opts ...interfaces.ReadOption) ([]models.Order, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, since}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetOrdersSince", varargs...)
+	ret0, _ := ret[0].([]models.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrdersSince indicates an expected call of GetOrdersSince.
+func (mr *MockDatabaseMockRecorder) GetOrdersSince(ctx, since interface{}, opts ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, since}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrdersSince", reflect.TypeOf((*MockDatabase)(nil).GetOrdersSince), varargs...)
 }
 
 // Init mocks base method.
@@ -91,6 +265,54 @@ func (mr *MockDatabaseMockRecorder) Init(ctx interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Init", reflect.TypeOf((*MockDatabase)(nil).Init), ctx)
 }
 
+// ListOrderUIDs mocks base method.
+func (m *MockDatabase) ListOrderUIDs(ctx context.Context, fn func(string) error, opts ...interfaces.ReadOption) error {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, fn}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ListOrderUIDs", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ListOrderUIDs indicates an expected call of ListOrderUIDs.
+func (mr *MockDatabaseMockRecorder) ListOrderUIDs(ctx, fn interface{}, opts ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, fn}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListOrderUIDs", reflect.TypeOf((*MockDatabase)(nil).ListOrderUIDs), varargs...)
+}
+
+// OrderExists mocks base method.
+func (m *MockDatabase) OrderExists(ctx context.Context, orderUID string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OrderExists", ctx, orderUID)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// OrderExists indicates an expected call of OrderExists.
+func (mr *MockDatabaseMockRecorder) OrderExists(ctx, orderUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OrderExists", reflect.TypeOf((*MockDatabase)(nil).OrderExists), ctx, orderUID)
+}
+
+// Ping mocks base method.
+func (m *MockDatabase) Ping(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockDatabaseMockRecorder) Ping(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockDatabase)(nil).Ping), ctx)
+}
+
 // SaveOrder mocks base method.
 func (m *MockDatabase) SaveOrder(ctx context.Context, order *models.Order) error {
 	m.ctrl.T.Helper()
@@ -105,6 +327,138 @@ func (mr *MockDatabaseMockRecorder) SaveOrder(ctx, order interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveOrder", reflect.TypeOf((*MockDatabase)(nil).SaveOrder), ctx, order)
 }
 
+// SaveOrders mocks base method.
+func (m *MockDatabase) SaveOrders(ctx context.Context, orders []*models.Order) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveOrders", ctx, orders)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveOrders indicates an expected call of SaveOrders.
+func (mr *MockDatabaseMockRecorder) SaveOrders(ctx, orders interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveOrders", reflect.TypeOf((*MockDatabase)(nil).SaveOrders), ctx, orders)
+}
+
+// SaveOrdersPartial mocks base method.
+func (m *MockDatabase) SaveOrdersPartial(ctx context.Context, orders []*models.Order) ([]error, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveOrdersPartial", ctx, orders)
+	ret0, _ := ret[0].([]error)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SaveOrdersPartial indicates an expected call of SaveOrdersPartial.
+func (mr *MockDatabaseMockRecorder) SaveOrdersPartial(ctx, orders interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveOrdersPartial", reflect.TypeOf((*MockDatabase)(nil).SaveOrdersPartial), ctx, orders)
+}
+
+// SoftDeleteOrder mocks base method.
+func (m *MockDatabase) SoftDeleteOrder(ctx context.Context, orderUID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SoftDeleteOrder", ctx, orderUID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SoftDeleteOrder indicates an expected call of SoftDeleteOrder.
+func (mr *MockDatabaseMockRecorder) SoftDeleteOrder(ctx, orderUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SoftDeleteOrder", reflect.TypeOf((*MockDatabase)(nil).SoftDeleteOrder), ctx, orderUID)
+}
+
+// StreamAllOrders mocks base method.
+func (m *MockDatabase) StreamAllOrders(ctx context.Context, fn func(*models.Order) error, opts ...interfaces.ReadOption) error {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, fn}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "StreamAllOrders", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StreamAllOrders indicates an expected call of StreamAllOrders.
+func (mr *MockDatabaseMockRecorder) StreamAllOrders(ctx, fn interface{}, opts ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, fn}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamAllOrders", reflect.TypeOf((*MockDatabase)(nil).StreamAllOrders), varargs...)
+}
+
+// StreamOrders mocks base method.
+func (m *MockDatabase) StreamOrders(ctx context.Context, fn func(*models.Order) error, opts ...interfaces.ReadOption) error {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, fn}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "StreamOrders", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StreamOrders indicates an expected call of StreamOrders.
+func (mr *MockDatabaseMockRecorder) StreamOrders(ctx, fn interface{}, opts ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, fn}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamOrders", reflect.TypeOf((*MockDatabase)(nil).StreamOrders), varargs...)
+}
+
+// UpdateOrderStatus mocks base method.
+func (m *MockDatabase) UpdateOrderStatus(ctx context.Context, orderUID string, status models.OrderStatus) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateOrderStatus", ctx, orderUID, status)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateOrderStatus indicates an expected call of UpdateOrderStatus.
+func (mr *MockDatabaseMockRecorder) UpdateOrderStatus(ctx, orderUID, status interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOrderStatus", reflect.TypeOf((*MockDatabase)(nil).UpdateOrderStatus), ctx, orderUID, status)
+}
+
+// MockPoolStatsProvider is a mock of PoolStatsProvider interface.
+type MockPoolStatsProvider struct {
+	ctrl     *gomock.Controller
+	recorder *MockPoolStatsProviderMockRecorder
+}
+
+// MockPoolStatsProviderMockRecorder is the mock recorder for MockPoolStatsProvider.
+type MockPoolStatsProviderMockRecorder struct {
+	mock *MockPoolStatsProvider
+}
+
+// NewMockPoolStatsProvider creates a new mock instance.
+func NewMockPoolStatsProvider(ctrl *gomock.Controller) *MockPoolStatsProvider {
+	mock := &MockPoolStatsProvider{ctrl: ctrl}
+	mock.recorder = &MockPoolStatsProviderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPoolStatsProvider) EXPECT() *MockPoolStatsProviderMockRecorder {
+	return m.recorder
+}
+
+// Stats mocks base method.
+func (m *MockPoolStatsProvider) Stats() interfaces.PoolStats {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stats")
+	ret0, _ := ret[0].(interfaces.PoolStats)
+	return ret0
+}
+
+// Stats indicates an expected call of Stats.
+func (mr *MockPoolStatsProviderMockRecorder) Stats() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*MockPoolStatsProvider)(nil).Stats))
+}
+
 // MockCache is a mock of Cache interface.
 type MockCache struct {
 	ctrl     *gomock.Controller
@@ -140,6 +494,62 @@ func (mr *MockCacheMockRecorder) Cleanup() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Cleanup", reflect.TypeOf((*MockCache)(nil).Cleanup))
 }
 
+// Clear mocks base method.
+func (m *MockCache) Clear() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Clear")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// Clear indicates an expected call of Clear.
+func (mr *MockCacheMockRecorder) Clear() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Clear", reflect.TypeOf((*MockCache)(nil).Clear))
+}
+
+// Delete mocks base method.
+func (m *MockCache) Delete(orderUID string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", orderUID)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockCacheMockRecorder) Delete(orderUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockCache)(nil).Delete), orderUID)
+}
+
+// DeleteCreatedBefore mocks base method.
+func (m *MockCache) DeleteCreatedBefore(cutoff time.Time) int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteCreatedBefore", cutoff)
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// DeleteCreatedBefore indicates an expected call of DeleteCreatedBefore.
+func (mr *MockCacheMockRecorder) DeleteCreatedBefore(cutoff interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteCreatedBefore", reflect.TypeOf((*MockCache)(nil).DeleteCreatedBefore), cutoff)
+}
+
+// Evicted mocks base method.
+func (m *MockCache) Evicted() uint64 {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Evicted")
+	ret0, _ := ret[0].(uint64)
+	return ret0
+}
+
+// Evicted indicates an expected call of Evicted.
+func (mr *MockCacheMockRecorder) Evicted() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Evicted", reflect.TypeOf((*MockCache)(nil).Evicted))
+}
+
 // Get mocks base method.
 func (m *MockCache) Get(orderUID string) (*models.Order, bool) {
 	m.ctrl.T.Helper()
@@ -169,6 +579,65 @@ func (mr *MockCacheMockRecorder) GetAll() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAll", reflect.TypeOf((*MockCache)(nil).GetAll))
 }
 
+// GetByTrackNumber mocks base method.
+func (m *MockCache) GetByTrackNumber(trackNumber string) []*models.Order {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByTrackNumber", trackNumber)
+	ret0, _ := ret[0].([]*models.Order)
+	return ret0
+}
+
+// GetByTrackNumber indicates an expected call of GetByTrackNumber.
+func (mr *MockCacheMockRecorder) GetByTrackNumber(trackNumber interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByTrackNumber", reflect.TypeOf((*MockCache)(nil).GetByTrackNumber), trackNumber)
+}
+
+// GetMulti mocks base method.
+func (m *MockCache) GetMulti(uids []string) (map[string]*models.Order, []string) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMulti", uids)
+	ret0, _ := ret[0].(map[string]*models.Order)
+	ret1, _ := ret[1].([]string)
+	return ret0, ret1
+}
+
+// GetMulti indicates an expected call of GetMulti.
+func (mr *MockCacheMockRecorder) GetMulti(uids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMulti", reflect.TypeOf((*MockCache)(nil).GetMulti), uids)
+}
+
+// GetOrSet mocks base method.
+func (m *MockCache) GetOrSet(orderUID string, loader func() (*models.Order, error)) (*models.Order, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrSet", orderUID, loader)
+	ret0, _ := ret[0].(*models.Order)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetOrSet indicates an expected call of GetOrSet.
+func (mr *MockCacheMockRecorder) GetOrSet(orderUID, loader interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrSet", reflect.TypeOf((*MockCache)(nil).GetOrSet), orderUID, loader)
+}
+
+// KeepAlive mocks base method.
+func (m *MockCache) KeepAlive(orderUID string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "KeepAlive", orderUID)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// KeepAlive indicates an expected call of KeepAlive.
+func (mr *MockCacheMockRecorder) KeepAlive(orderUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "KeepAlive", reflect.TypeOf((*MockCache)(nil).KeepAlive), orderUID)
+}
+
 // LoadFromSlice mocks base method.
 func (m *MockCache) LoadFromSlice(orders []models.Order) {
 	m.ctrl.T.Helper()
@@ -181,6 +650,106 @@ func (mr *MockCacheMockRecorder) LoadFromSlice(orders interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadFromSlice", reflect.TypeOf((*MockCache)(nil).LoadFromSlice), orders)
 }
 
+// Lookup mocks base method.
+func (m *MockCache) Lookup(orderUID string) (*models.Order, bool, bool) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Lookup", orderUID)
+	ret0, _ := ret[0].(*models.Order)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(bool)
+	return ret0, ret1, ret2
+}
+
+// Lookup indicates an expected call of Lookup.
+func (mr *MockCacheMockRecorder) Lookup(orderUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Lookup", reflect.TypeOf((*MockCache)(nil).Lookup), orderUID)
+}
+
+// MemoryUsage mocks base method.
+func (m *MockCache) MemoryUsage() (int64, int64) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MemoryUsage")
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(int64)
+	return ret0, ret1
+}
+
+// MemoryUsage indicates an expected call of MemoryUsage.
+func (mr *MockCacheMockRecorder) MemoryUsage() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MemoryUsage", reflect.TypeOf((*MockCache)(nil).MemoryUsage))
+}
+
+// NewestDateCreated mocks base method.
+func (m *MockCache) NewestDateCreated() time.Time {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NewestDateCreated")
+	ret0, _ := ret[0].(time.Time)
+	return ret0
+}
+
+// NewestDateCreated indicates an expected call of NewestDateCreated.
+func (mr *MockCacheMockRecorder) NewestDateCreated() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NewestDateCreated", reflect.TypeOf((*MockCache)(nil).NewestDateCreated))
+}
+
+// ReadSnapshot mocks base method.
+func (m *MockCache) ReadSnapshot(r io.Reader, maxAge time.Duration) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadSnapshot", r, maxAge)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReadSnapshot indicates an expected call of ReadSnapshot.
+func (mr *MockCacheMockRecorder) ReadSnapshot(r, maxAge interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadSnapshot", reflect.TypeOf((*MockCache)(nil).ReadSnapshot), r, maxAge)
+}
+
+// ReplaceAll mocks base method.
+func (m *MockCache) ReplaceAll(orders []models.Order) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ReplaceAll", orders)
+}
+
+// ReplaceAll indicates an expected call of ReplaceAll.
+func (mr *MockCacheMockRecorder) ReplaceAll(orders interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplaceAll", reflect.TypeOf((*MockCache)(nil).ReplaceAll), orders)
+}
+
+// ReplaceStream mocks base method.
+func (m *MockCache) ReplaceStream(fill func(func(*models.Order)) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplaceStream", fill)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReplaceStream indicates an expected call of ReplaceStream.
+func (mr *MockCacheMockRecorder) ReplaceStream(fill interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplaceStream", reflect.TypeOf((*MockCache)(nil).ReplaceStream), fill)
+}
+
+// Revalidate mocks base method.
+func (m *MockCache) Revalidate(order *models.Order) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Revalidate", order)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// Revalidate indicates an expected call of Revalidate.
+func (mr *MockCacheMockRecorder) Revalidate(order interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Revalidate", reflect.TypeOf((*MockCache)(nil).Revalidate), order)
+}
+
 // Set mocks base method.
 func (m *MockCache) Set(order *models.Order) {
 	m.ctrl.T.Helper()
@@ -193,6 +762,18 @@ func (mr *MockCacheMockRecorder) Set(order interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockCache)(nil).Set), order)
 }
 
+// SetWithTTL mocks base method.
+func (m *MockCache) SetWithTTL(order *models.Order, ttl time.Duration) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetWithTTL", order, ttl)
+}
+
+// SetWithTTL indicates an expected call of SetWithTTL.
+func (mr *MockCacheMockRecorder) SetWithTTL(order, ttl interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetWithTTL", reflect.TypeOf((*MockCache)(nil).SetWithTTL), order, ttl)
+}
+
 // Size mocks base method.
 func (m *MockCache) Size() int {
 	m.ctrl.T.Helper()
@@ -207,6 +788,32 @@ func (mr *MockCacheMockRecorder) Size() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Size", reflect.TypeOf((*MockCache)(nil).Size))
 }
 
+// StopJanitor mocks base method.
+func (m *MockCache) StopJanitor() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "StopJanitor")
+}
+
+// StopJanitor indicates an expected call of StopJanitor.
+func (mr *MockCacheMockRecorder) StopJanitor() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StopJanitor", reflect.TypeOf((*MockCache)(nil).StopJanitor))
+}
+
+// WriteSnapshot mocks base method.
+func (m *MockCache) WriteSnapshot(w io.Writer) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteSnapshot", w)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WriteSnapshot indicates an expected call of WriteSnapshot.
+func (mr *MockCacheMockRecorder) WriteSnapshot(w interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteSnapshot", reflect.TypeOf((*MockCache)(nil).WriteSnapshot), w)
+}
+
 // MockOrderService is a mock of OrderService interface.
 type MockOrderService struct {
 	ctrl     *gomock.Controller
@@ -230,6 +837,20 @@ func (m *MockOrderService) EXPECT() *MockOrderServiceMockRecorder {
 	return m.recorder
 }
 
+// ClearCache mocks base method.
+func (m *MockOrderService) ClearCache() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClearCache")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// ClearCache indicates an expected call of ClearCache.
+func (mr *MockOrderServiceMockRecorder) ClearCache() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClearCache", reflect.TypeOf((*MockOrderService)(nil).ClearCache))
+}
+
 // Close mocks base method.
 func (m *MockOrderService) Close() {
 	m.ctrl.T.Helper()
@@ -242,6 +863,35 @@ func (mr *MockOrderServiceMockRecorder) Close() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockOrderService)(nil).Close))
 }
 
+// DeleteOrder mocks base method.
+func (m *MockOrderService) DeleteOrder(ctx context.Context, orderUID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteOrder", ctx, orderUID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteOrder indicates an expected call of DeleteOrder.
+func (mr *MockOrderServiceMockRecorder) DeleteOrder(ctx, orderUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOrder", reflect.TypeOf((*MockOrderService)(nil).DeleteOrder), ctx, orderUID)
+}
+
+// FindOrdersByContact mocks base method.
+func (m *MockOrderService) FindOrdersByContact(ctx context.Context, email, phone string, limit int) ([]models.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindOrdersByContact", ctx, email, phone, limit)
+	ret0, _ := ret[0].([]models.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindOrdersByContact indicates an expected call of FindOrdersByContact.
+func (mr *MockOrderServiceMockRecorder) FindOrdersByContact(ctx, email, phone, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOrdersByContact", reflect.TypeOf((*MockOrderService)(nil).FindOrdersByContact), ctx, email, phone, limit)
+}
+
 // GetCacheStats mocks base method.
 func (m *MockOrderService) GetCacheStats() map[string]interface{} {
 	m.ctrl.T.Helper()
@@ -257,32 +907,150 @@ func (mr *MockOrderServiceMockRecorder) GetCacheStats() *gomock.Call {
 }
 
 // GetOrder mocks base method.
-func (m *MockOrderService) GetOrder(orderUID string) (*models.Order, error) {
+func (m *MockOrderService) GetOrder(ctx context.Context, orderUID string) (*models.Order, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetOrder", orderUID)
+	ret := m.ctrl.Call(m, "GetOrder", ctx, orderUID)
 	ret0, _ := ret[0].(*models.Order)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetOrder indicates an expected call of GetOrder.
-func (mr *MockOrderServiceMockRecorder) GetOrder(orderUID interface{}) *gomock.Call {
+func (mr *MockOrderServiceMockRecorder) GetOrder(ctx, orderUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrder", reflect.TypeOf((*MockOrderService)(nil).GetOrder), ctx, orderUID)
+}
+
+// GetOrderWithSource mocks base method.
+func (m *MockOrderService) GetOrderWithSource(ctx context.Context, orderUID string) (*models.Order, interfaces.Source, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrderWithSource", ctx, orderUID)
+	ret0, _ := ret[0].(*models.Order)
+	ret1, _ := ret[1].(interfaces.Source)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetOrderWithSource indicates an expected call of GetOrderWithSource.
+func (mr *MockOrderServiceMockRecorder) GetOrderWithSource(ctx, orderUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderWithSource", reflect.TypeOf((*MockOrderService)(nil).GetOrderWithSource), ctx, orderUID)
+}
+
+// GetOrders mocks base method.
+func (m *MockOrderService) GetOrders(ctx context.Context, orderUIDs []string) (map[string]*models.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrders", ctx, orderUIDs)
+	ret0, _ := ret[0].(map[string]*models.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrders indicates an expected call of GetOrders.
+func (mr *MockOrderServiceMockRecorder) GetOrders(ctx, orderUIDs interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrders", reflect.TypeOf((*MockOrderService)(nil).GetOrders), ctx, orderUIDs)
+}
+
+// GetOrdersByTrackNumber mocks base method.
+func (m *MockOrderService) GetOrdersByTrackNumber(ctx context.Context, trackNumber string) ([]*models.Order, interfaces.Source, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrdersByTrackNumber", ctx, trackNumber)
+	ret0, _ := ret[0].([]*models.Order)
+	ret1, _ := ret[1].(interfaces.Source)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetOrdersByTrackNumber indicates an expected call of GetOrdersByTrackNumber.
+func (mr *MockOrderServiceMockRecorder) GetOrdersByTrackNumber(ctx, trackNumber interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrder", reflect.TypeOf((*MockOrderService)(nil).GetOrder), orderUID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrdersByTrackNumber", reflect.TypeOf((*MockOrderService)(nil).GetOrdersByTrackNumber), ctx, trackNumber)
+}
+
+// HealthStatus mocks base method.
+func (m *MockOrderService) HealthStatus(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HealthStatus", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// HealthStatus indicates an expected call of HealthStatus.
+func (mr *MockOrderServiceMockRecorder) HealthStatus(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HealthStatus", reflect.TypeOf((*MockOrderService)(nil).HealthStatus), ctx)
 }
 
 // ProcessOrder mocks base method.
-func (m *MockOrderService) ProcessOrder(order *models.Order) error {
+func (m *MockOrderService) ProcessOrder(ctx context.Context, order *models.Order) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ProcessOrder", order)
+	ret := m.ctrl.Call(m, "ProcessOrder", ctx, order)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // ProcessOrder indicates an expected call of ProcessOrder.
-func (mr *MockOrderServiceMockRecorder) ProcessOrder(order interface{}) *gomock.Call {
+func (mr *MockOrderServiceMockRecorder) ProcessOrder(ctx, order interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProcessOrder", reflect.TypeOf((*MockOrderService)(nil).ProcessOrder), ctx, order)
+}
+
+// ProcessOrders mocks base method.
+func (m *MockOrderService) ProcessOrders(ctx context.Context, orders []*models.Order) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProcessOrders", ctx, orders)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ProcessOrders indicates an expected call of ProcessOrders.
+func (mr *MockOrderServiceMockRecorder) ProcessOrders(ctx, orders interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProcessOrders", reflect.TypeOf((*MockOrderService)(nil).ProcessOrders), ctx, orders)
+}
+
+// SoftDeleteOrder mocks base method.
+func (m *MockOrderService) SoftDeleteOrder(ctx context.Context, orderUID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SoftDeleteOrder", ctx, orderUID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SoftDeleteOrder indicates an expected call of SoftDeleteOrder.
+func (mr *MockOrderServiceMockRecorder) SoftDeleteOrder(ctx, orderUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SoftDeleteOrder", reflect.TypeOf((*MockOrderService)(nil).SoftDeleteOrder), ctx, orderUID)
+}
+
+// StreamOrders mocks base method.
+func (m *MockOrderService) StreamOrders(ctx context.Context, fn func(*models.Order) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StreamOrders", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StreamOrders indicates an expected call of StreamOrders.
+func (mr *MockOrderServiceMockRecorder) StreamOrders(ctx, fn interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProcessOrder", reflect.TypeOf((*MockOrderService)(nil).ProcessOrder), order)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamOrders", reflect.TypeOf((*MockOrderService)(nil).StreamOrders), ctx, fn)
+}
+
+// UpdateOrderStatus mocks base method.
+func (m *MockOrderService) UpdateOrderStatus(ctx context.Context, orderUID string, status models.OrderStatus) (*models.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateOrderStatus", ctx, orderUID, status)
+	ret0, _ := ret[0].(*models.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateOrderStatus indicates an expected call of UpdateOrderStatus.
+func (mr *MockOrderServiceMockRecorder) UpdateOrderStatus(ctx, orderUID, status interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOrderStatus", reflect.TypeOf((*MockOrderService)(nil).UpdateOrderStatus), ctx, orderUID, status)
 }
 
 // WarmUpCache mocks base method.
@@ -298,3 +1066,131 @@ func (mr *MockOrderServiceMockRecorder) WarmUpCache(ctx interface{}) *gomock.Cal
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WarmUpCache", reflect.TypeOf((*MockOrderService)(nil).WarmUpCache), ctx)
 }
+
+// WarmUpCacheSince mocks base method.
+func (m *MockOrderService) WarmUpCacheSince(ctx context.Context, since time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WarmUpCacheSince", ctx, since)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WarmUpCacheSince indicates an expected call of WarmUpCacheSince.
+func (mr *MockOrderServiceMockRecorder) WarmUpCacheSince(ctx, since interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WarmUpCacheSince", reflect.TypeOf((*MockOrderService)(nil).WarmUpCacheSince), ctx, since)
+}
+
+// MockMessageConsumer is a mock of MessageConsumer interface.
+type MockMessageConsumer struct {
+	ctrl     *gomock.Controller
+	recorder *MockMessageConsumerMockRecorder
+}
+
+// MockMessageConsumerMockRecorder is the mock recorder for MockMessageConsumer.
+type MockMessageConsumerMockRecorder struct {
+	mock *MockMessageConsumer
+}
+
+// NewMockMessageConsumer creates a new mock instance.
+func NewMockMessageConsumer(ctrl *gomock.Controller) *MockMessageConsumer {
+	mock := &MockMessageConsumer{ctrl: ctrl}
+	mock.recorder = &MockMessageConsumerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMessageConsumer) EXPECT() *MockMessageConsumerMockRecorder {
+	return m.recorder
+}
+
+// Close mocks base method.
+func (m *MockMessageConsumer) Close() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close.
+func (mr *MockMessageConsumerMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockMessageConsumer)(nil).Close))
+}
+
+// Consume mocks base method.
+func (m *MockMessageConsumer) Consume(ctx context.Context, processFunc func(context.Context, *models.Order) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Consume", ctx, processFunc)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Consume indicates an expected call of Consume.
+func (mr *MockMessageConsumerMockRecorder) Consume(ctx, processFunc interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Consume", reflect.TypeOf((*MockMessageConsumer)(nil).Consume), ctx, processFunc)
+}
+
+// SetMaxRetry mocks base method.
+func (m *MockMessageConsumer) SetMaxRetry(maxRetry int) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetMaxRetry", maxRetry)
+}
+
+// SetMaxRetry indicates an expected call of SetMaxRetry.
+func (mr *MockMessageConsumerMockRecorder) SetMaxRetry(maxRetry interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetMaxRetry", reflect.TypeOf((*MockMessageConsumer)(nil).SetMaxRetry), maxRetry)
+}
+
+// MockDeadLetterSink is a mock of DeadLetterSink interface.
+type MockDeadLetterSink struct {
+	ctrl     *gomock.Controller
+	recorder *MockDeadLetterSinkMockRecorder
+}
+
+// MockDeadLetterSinkMockRecorder is the mock recorder for MockDeadLetterSink.
+type MockDeadLetterSinkMockRecorder struct {
+	mock *MockDeadLetterSink
+}
+
+// NewMockDeadLetterSink creates a new mock instance.
+func NewMockDeadLetterSink(ctrl *gomock.Controller) *MockDeadLetterSink {
+	mock := &MockDeadLetterSink{ctrl: ctrl}
+	mock.recorder = &MockDeadLetterSinkMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDeadLetterSink) EXPECT() *MockDeadLetterSinkMockRecorder {
+	return m.recorder
+}
+
+// Close mocks base method.
+func (m *MockDeadLetterSink) Close() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close.
+func (mr *MockDeadLetterSinkMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockDeadLetterSink)(nil).Close))
+}
+
+// SendToDLQ mocks base method.
+func (m *MockDeadLetterSink) SendToDLQ(originalMsg kafka.Message, err error, attempts int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendToDLQ", originalMsg, err, attempts)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendToDLQ indicates an expected call of SendToDLQ.
+func (mr *MockDeadLetterSinkMockRecorder) SendToDLQ(originalMsg, err, attempts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendToDLQ", reflect.TypeOf((*MockDeadLetterSink)(nil).SendToDLQ), originalMsg, err, attempts)
+}
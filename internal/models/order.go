@@ -2,7 +2,12 @@
 package models
 
 import (
+	"encoding/xml"
 	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/go-playground/validator/v10"
@@ -11,28 +16,59 @@ import (
 // Экземпляр кастомного валидатора
 var validate *validator.Validate
 
+// OrderUIDLength длина идентификатора заказа
+const OrderUIDLength = 32
+
+// orderUIDPattern формат идентификатора заказа; используется и тегом order_uid, и ValidateOrderUID
+var orderUIDPattern = regexp.MustCompile(fmt.Sprintf(`^[a-zA-Z0-9]{%d}$`, OrderUIDLength))
+
 func init() {
 	validate = validator.New()
+	_ = validate.RegisterValidation("order_uid", func(fl validator.FieldLevel) bool {
+		return orderUIDPattern.MatchString(fl.Field().String())
+	})
 }
 
-// Order представляет структуру заказа
+// OrderUIDPattern возвращает регулярное выражение формата идентификатора заказа (для документации API)
+func OrderUIDPattern() string {
+	return orderUIDPattern.String()
+}
+
+// ValidateOrderUID проверяет формат идентификатора заказа (те же правила, что и при валидации Order)
+func ValidateOrderUID(uid string) error {
+	if !orderUIDPattern.MatchString(uid) {
+		return fmt.Errorf("идентификатор заказа должен состоять из %d латинских букв или цифр", OrderUIDLength)
+	}
+	return nil
+}
+
+// Order представляет структуру заказа.
+// В XML элементы называются так же, как поля JSON: корень <order>,
+// товары — <items><item>...</item></items>.
 type Order struct {
-	OrderUID          string    `json:"order_uid" validate:"required,alphanum,len=32"`
-	TrackNumber       string    `json:"track_number" validate:"required"`
-	Entry             string    `json:"entry" validate:"required"`
-	Delivery          Delivery  `json:"delivery" validate:"required"`
-	Payment           Payment   `json:"payment" validate:"required"`
-	Items             []Item    `json:"items" validate:"required,min=1,dive"`
-	Locale            string    `json:"locale" validate:"required"`
-	InternalSignature string    `json:"internal_signature"`
-	CustomerID        string    `json:"customer_id" validate:"required"`
-	DeliveryService   string    `json:"delivery_service" validate:"required"`
-	ShardKey          string    `json:"shardkey" validate:"required"`
-	SMID              int       `json:"sm_id" validate:"required,gt=0"`
-	DateCreated       time.Time `json:"date_created"`
-	OOFShard          string    `json:"oof_shard" validate:"required"`
+	XMLName           xml.Name    `json:"-" xml:"order"`
+	OrderUID          string      `json:"order_uid" xml:"order_uid" validate:"required,order_uid"`
+	TrackNumber       string      `json:"track_number" xml:"track_number" validate:"required"`
+	Entry             string      `json:"entry" xml:"entry" validate:"required"`
+	Delivery          Delivery    `json:"delivery" xml:"delivery" validate:"required"`
+	Payment           Payment     `json:"payment" xml:"payment" validate:"required"`
+	Items             []Item      `json:"items" xml:"items>item" validate:"required,min=1,dive"`
+	Locale            string      `json:"locale" xml:"locale" validate:"required"`
+	InternalSignature string      `json:"internal_signature" xml:"internal_signature"`
+	CustomerID        string      `json:"customer_id" xml:"customer_id" validate:"required"`
+	DeliveryService   string      `json:"delivery_service" xml:"delivery_service" validate:"required"`
+	ShardKey          string      `json:"shardkey" xml:"shardkey" validate:"required"`
+	SMID              int         `json:"sm_id" xml:"sm_id" validate:"required,gt=0"`
+	DateCreated       time.Time   `json:"date_created" xml:"date_created"`
+	OOFShard          string      `json:"oof_shard" xml:"oof_shard" validate:"required"`
+	UpdatedAt         time.Time   `json:"updated_at" xml:"updated_at"`                     // Время последнего изменения, заполняется БД
+	DeletedAt         *time.Time  `json:"deleted_at,omitempty" xml:"deleted_at,omitempty"` // Время мягкого удаления (nil — заказ не удален)
+	Status            OrderStatus `json:"status,omitempty" xml:"status,omitempty"`         // Статус жизненного цикла, заполняется БД
 }
 
+// ErrOrderNotFound возвращается, когда заказа с указанным UID не существует
+var ErrOrderNotFound = errors.New("заказ не найден")
+
 // Validate выполняет строгую проверку заказа, полученного от брокера.
 func (o *Order) Validate() error {
 	if o == nil {
@@ -41,16 +77,30 @@ func (o *Order) Validate() error {
 	return validate.Struct(o)
 }
 
+// Clone возвращает глубокую копию заказа: изменение копии, включая товары, не затрагивает оригинал
+func (o *Order) Clone() *Order {
+	if o == nil {
+		return nil
+	}
+	c := *o
+	c.Items = slices.Clone(o.Items)
+	if o.DeletedAt != nil {
+		deletedAt := *o.DeletedAt
+		c.DeletedAt = &deletedAt
+	}
+	return &c
+}
+
 // Delivery представляет информацию о доставке
 type Delivery struct {
-	OrderUID string `json:"-"`
-	Name     string `json:"name" validate:"required"`
-	Phone    string `json:"phone" validate:"required"`
-	Zip      string `json:"zip" validate:"required"`
-	City     string `json:"city" validate:"required"`
-	Address  string `json:"address" validate:"required"`
-	Region   string `json:"region" validate:"required"`
-	Email    string `json:"email" validate:"required,email"`
+	OrderUID string `json:"-" xml:"-"`
+	Name     string `json:"name" xml:"name" validate:"required"`
+	Phone    string `json:"phone" xml:"phone" validate:"required"`
+	Zip      string `json:"zip" xml:"zip" validate:"required"`
+	City     string `json:"city" xml:"city" validate:"required"`
+	Address  string `json:"address" xml:"address" validate:"required"`
+	Region   string `json:"region" xml:"region" validate:"required"`
+	Email    string `json:"email" xml:"email" validate:"required,email"`
 }
 
 // Подтверждение деталей доставки.
@@ -58,19 +108,36 @@ func (d *Delivery) Validate() error {
 	return validate.Struct(d)
 }
 
+// NormalizeEmail приводит email к виду для сравнения: без пробелов по краям, в нижнем регистре
+// (как lower(email) в индексе idx_delivery_email_lower)
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// NormalizePhone убирает из телефона оформление — пробелы, скобки, дефисы, точки —
+// оставляя только цифры и «+» (как выражение индекса idx_delivery_phone_digits)
+func NormalizePhone(phone string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= '0' && r <= '9') || r == '+' {
+			return r
+		}
+		return -1
+	}, phone)
+}
+
 // Payment представляет информацию о платеже
 type Payment struct {
-	OrderUID     string `json:"-"`
-	Transaction  string `json:"transaction" validate:"required"`
-	RequestID    string `json:"request_id"`
-	Currency     string `json:"currency" validate:"required"`
-	Provider     string `json:"provider" validate:"required"`
-	Amount       int    `json:"amount" validate:"min=0"`
-	PaymentDT    int64  `json:"payment_dt" validate:"gt=0"`
-	Bank         string `json:"bank" validate:"required"`
-	DeliveryCost int    `json:"delivery_cost" validate:"min=0"`
-	GoodsTotal   int    `json:"goods_total" validate:"min=0"`
-	CustomFee    int    `json:"custom_fee" validate:"min=0"`
+	OrderUID     string `json:"-" xml:"-"`
+	Transaction  string `json:"transaction" xml:"transaction" validate:"required"`
+	RequestID    string `json:"request_id" xml:"request_id"`
+	Currency     string `json:"currency" xml:"currency" validate:"required"`
+	Provider     string `json:"provider" xml:"provider" validate:"required"`
+	Amount       int    `json:"amount" xml:"amount" validate:"min=0"`
+	PaymentDT    int64  `json:"payment_dt" xml:"payment_dt" validate:"gt=0"`
+	Bank         string `json:"bank" xml:"bank" validate:"required"`
+	DeliveryCost int    `json:"delivery_cost" xml:"delivery_cost" validate:"min=0"`
+	GoodsTotal   int    `json:"goods_total" xml:"goods_total" validate:"min=0"`
+	CustomFee    int    `json:"custom_fee" xml:"custom_fee" validate:"min=0"`
 }
 
 // Подтверждение платежа.
@@ -80,18 +147,18 @@ func (p *Payment) Validate() error {
 
 // Item представляет товар в заказе
 type Item struct {
-	OrderUID    string `json:"-"`
-	ChrtID      int    `json:"chrt_id" validate:"gt=0"`
-	TrackNumber string `json:"track_number" validate:"required"`
-	Price       int    `json:"price" validate:"min=0"`
-	RID         string `json:"rid" validate:"required"`
-	Name        string `json:"name" validate:"required"`
-	Sale        int    `json:"sale"`
-	Size        string `json:"size" validate:"required"`
-	TotalPrice  int    `json:"total_price" validate:"min=0"`
-	NMID        int    `json:"nm_id" validate:"gt=0"`
-	Brand       string `json:"brand" validate:"required"`
-	Status      int    `json:"status"`
+	OrderUID    string `json:"-" xml:"-"`
+	ChrtID      int    `json:"chrt_id" xml:"chrt_id" validate:"gt=0"`
+	TrackNumber string `json:"track_number" xml:"track_number" validate:"required"`
+	Price       int    `json:"price" xml:"price" validate:"min=0"`
+	RID         string `json:"rid" xml:"rid" validate:"required"`
+	Name        string `json:"name" xml:"name" validate:"required"`
+	Sale        int    `json:"sale" xml:"sale"`
+	Size        string `json:"size" xml:"size" validate:"required"`
+	TotalPrice  int    `json:"total_price" xml:"total_price" validate:"min=0"`
+	NMID        int    `json:"nm_id" xml:"nm_id" validate:"gt=0"`
+	Brand       string `json:"brand" xml:"brand" validate:"required"`
+	Status      int    `json:"status" xml:"status"`
 }
 
 // Подтверждение отдельного товара.
@@ -1,10 +1,14 @@
 package models
 
 import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestOrder_Validate(t *testing.T) {
@@ -727,3 +731,150 @@ func TestItem_Validate(t *testing.T) {
 		}
 	})
 }
+
+func TestValidateOrderUID(t *testing.T) {
+	assert.NoError(t, ValidateOrderUID("testorderuid1234567890123456abcd"))
+	assert.Error(t, ValidateOrderUID(""))
+	assert.Error(t, ValidateOrderUID("testorderuid1234567890123456abc"))
+	assert.Error(t, ValidateOrderUID("testorderuid1234567890123456abc_"))
+	assert.Error(t, ValidateOrderUID("тестовыйидентификатор1234567890a"))
+
+	// Те же правила применяются при валидации заказа
+	order := Order{OrderUID: "testorderuid1234567890123456abc_"}
+	err := order.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "order_uid")
+}
+
+func TestNormalizeContacts(t *testing.T) {
+	t.Run("Email", func(t *testing.T) {
+		assert.Equal(t, "test@gmail.com", NormalizeEmail("  Test@Gmail.COM "))
+		assert.Equal(t, "test@gmail.com", NormalizeEmail("test@gmail.com"))
+		assert.Empty(t, NormalizeEmail("   "))
+	})
+
+	t.Run("Phone", func(t *testing.T) {
+		for _, phone := range []string{"+9720000000", "+972 000-0000", "+972 (000) 00.00", " +9720000000\t"} {
+			assert.Equal(t, "+9720000000", NormalizePhone(phone), phone)
+		}
+		assert.Equal(t, "89991234567", NormalizePhone("8 (999) 123-45-67"))
+		assert.Empty(t, NormalizePhone("( ) -"))
+	})
+}
+
+func TestOrder_XML(t *testing.T) {
+	created := time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)
+	order := Order{
+		OrderUID:    "testorderuid1234567890123456abcd",
+		TrackNumber: "TRACK123",
+		Locale:      "en",
+		SMID:        99,
+		DateCreated: created,
+		Delivery:    Delivery{OrderUID: "testorderuid1234567890123456abcd", City: "Test City", Email: "test@example.com"},
+		Payment:     Payment{Transaction: "trans123", Amount: 1817, Currency: "USD"},
+		Items: []Item{
+			{ChrtID: 1000, Name: "First <Item> & Co", Price: 500},
+			{ChrtID: 2000, Name: "Second Item", Price: 700},
+		},
+	}
+
+	data, err := xml.Marshal(order)
+	require.NoError(t, err)
+	doc := string(data)
+
+	// Имена элементов совпадают с полями JSON
+	assert.True(t, strings.HasPrefix(doc, "<order><order_uid>testorderuid1234567890123456abcd</order_uid>"), doc)
+	assert.Contains(t, doc, "<sm_id>99</sm_id>")
+	assert.Contains(t, doc, "<delivery><name></name><phone></phone><zip></zip><city>Test City</city>")
+	assert.Contains(t, doc, "<date_created>2021-11-26T06:22:19Z</date_created>")
+	assert.Equal(t, 1, strings.Count(doc, "<items>"), "товары обернуты в один элемент items")
+	assert.Equal(t, 2, strings.Count(doc, "<item>"), "каждый товар — отдельный элемент item")
+	assert.NotContains(t, doc, "OrderUID", "служебные поля не выводятся")
+
+	var decoded Order
+	require.NoError(t, xml.Unmarshal(data, &decoded))
+	assert.Equal(t, order.OrderUID, decoded.OrderUID)
+	assert.Equal(t, order.SMID, decoded.SMID)
+	assert.True(t, created.Equal(decoded.DateCreated))
+	assert.Equal(t, "Test City", decoded.Delivery.City)
+	assert.Equal(t, 1817, decoded.Payment.Amount)
+	require.Len(t, decoded.Items, 2)
+	assert.Equal(t, "First <Item> & Co", decoded.Items[0].Name)
+	assert.Equal(t, 2000, decoded.Items[1].ChrtID)
+
+	// XMLName не попадает в JSON
+	js, err := json.Marshal(order)
+	require.NoError(t, err)
+	assert.NotContains(t, string(js), "XMLName")
+}
+
+func TestOrder_Clone(t *testing.T) {
+	order := &Order{
+		OrderUID: "testorderuid1234567890123456abcd",
+		Delivery: Delivery{City: "Test City"},
+		Payment:  Payment{Amount: 1817},
+		Items:    []Item{{ChrtID: 1000, Name: "First"}, {ChrtID: 1001, Name: "Second"}},
+	}
+
+	clone := order.Clone()
+	require.Equal(t, order, clone)
+	assert.NotSame(t, order, clone)
+
+	clone.Delivery.City = "Other City"
+	clone.Payment.Amount = 0
+	clone.Items[0].Name = "Changed"
+	clone.Items = append(clone.Items, Item{ChrtID: 1002})
+
+	assert.Equal(t, "Test City", order.Delivery.City)
+	assert.Equal(t, 1817, order.Payment.Amount)
+	assert.Equal(t, "First", order.Items[0].Name, "товары копируются, а не разделяются")
+	assert.Len(t, order.Items, 2)
+
+	assert.Nil(t, (*Order)(nil).Clone())
+	assert.Nil(t, (&Order{}).Clone().Items, "nil товары остаются nil")
+
+	deletedAt := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	deleted := (&Order{DeletedAt: &deletedAt}).Clone()
+	require.NotNil(t, deleted.DeletedAt)
+	assert.NotSame(t, &deletedAt, deleted.DeletedAt, "отметка удаления копируется, а не разделяется")
+	assert.Equal(t, deletedAt, *deleted.DeletedAt)
+}
+
+func TestParseOrderStatus(t *testing.T) {
+	for _, status := range OrderStatuses() {
+		parsed, err := ParseOrderStatus(string(status))
+		require.NoError(t, err)
+		assert.Equal(t, status, parsed)
+	}
+
+	for _, s := range []string{"", "NEW", "returned"} {
+		_, err := ParseOrderStatus(s)
+		assert.ErrorIs(t, err, ErrInvalidStatus, s)
+	}
+}
+
+func TestOrderStatus_CanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from, to OrderStatus
+		allowed  bool
+	}{
+		{StatusNew, StatusAccepted, true},
+		{StatusAccepted, StatusPacked, true},
+		{StatusPacked, StatusShipped, true},
+		{StatusShipped, StatusDelivered, true},
+		{StatusNew, StatusCancelled, true},
+		{StatusPacked, StatusCancelled, true},
+		{StatusShipped, StatusShipped, true}, // Повтор того же статуса
+		{StatusNew, StatusShipped, false},    // Этапы не пропускаются
+		{StatusShipped, StatusAccepted, false},
+		{StatusShipped, StatusCancelled, false},
+		{StatusDelivered, StatusCancelled, false},
+		{StatusCancelled, StatusNew, false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.allowed, tt.from.CanTransitionTo(tt.to), "%s → %s", tt.from, tt.to)
+	}
+
+	err := error(&StatusTransitionError{OrderUID: "order-1", From: StatusShipped, To: StatusAccepted})
+	assert.Contains(t, err.Error(), "shipped → accepted")
+}
@@ -0,0 +1,14 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// OutboxEvent событие из таблицы outbox, ожидающее отправки в Kafka
+type OutboxEvent struct {
+	ID          int64           // Порядковый номер события
+	AggregateID string          // UID заказа; ключ сообщения Kafka
+	Payload     json.RawMessage // Тело сообщения (JSON заказа на момент сохранения)
+	CreatedAt   time.Time       // Время записи события
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// OrderStatus этап жизненного цикла заказа (колонка orders.status)
+type OrderStatus string
+
+// Статусы заказа. Новый заказ получает StatusNew; дальше статус меняет фулфилмент
+// через UpdateOrderStatus по переходам orderStatusTransitions.
+const (
+	StatusNew       OrderStatus = "new"       // Заказ сохранен
+	StatusAccepted  OrderStatus = "accepted"  // Принят в работу
+	StatusPacked    OrderStatus = "packed"    // Собран
+	StatusShipped   OrderStatus = "shipped"   // Передан в доставку
+	StatusDelivered OrderStatus = "delivered" // Доставлен (конечный)
+	StatusCancelled OrderStatus = "cancelled" // Отменен (конечный)
+)
+
+// orderStatusTransitions допустимые переходы: отменить можно только еще не отправленный заказ
+var orderStatusTransitions = map[OrderStatus][]OrderStatus{
+	StatusNew:      {StatusAccepted, StatusCancelled},
+	StatusAccepted: {StatusPacked, StatusCancelled},
+	StatusPacked:   {StatusShipped, StatusCancelled},
+	StatusShipped:  {StatusDelivered},
+}
+
+// OrderStatuses все статусы по порядку жизненного цикла
+func OrderStatuses() []OrderStatus {
+	return []OrderStatus{StatusNew, StatusAccepted, StatusPacked, StatusShipped, StatusDelivered, StatusCancelled}
+}
+
+// ErrInvalidStatus неизвестный статус заказа
+var ErrInvalidStatus = errors.New("неизвестный статус заказа")
+
+// ParseOrderStatus проверяет, что s — один из статусов OrderStatuses
+func ParseOrderStatus(s string) (OrderStatus, error) {
+	for _, status := range OrderStatuses() {
+		if string(status) == s {
+			return status, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %q", ErrInvalidStatus, s)
+}
+
+// CanTransitionTo сообщает, допустим ли переход из s в next. Повторная установка того же
+// статуса допустима и ничего не меняет, поэтому повтор запроса безопасен.
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	if s == next {
+		return true
+	}
+	for _, allowed := range orderStatusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusTransitionError недопустимый переход статуса заказа (например, shipped → accepted)
+type StatusTransitionError struct {
+	OrderUID string
+	From     OrderStatus
+	To       OrderStatus
+}
+
+func (e *StatusTransitionError) Error() string {
+	return fmt.Sprintf("недопустимый переход статуса заказа %s: %s → %s", e.OrderUID, e.From, e.To)
+}
@@ -0,0 +1,61 @@
+package outbox
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metrics содержит метрики публикатора outbox
+type Metrics struct {
+	PublishedTotal     prometheus.Counter
+	PublishErrorsTotal prometheus.Counter
+	BatchSize          prometheus.Histogram
+
+	// Отставание публикации
+	PendingEvents    prometheus.Gauge
+	OldestPendingAge prometheus.Gauge
+
+	// Лидерство публикатора среди реплик
+	Leader prometheus.Gauge
+}
+
+// Global metrics для предотвращения дублирования метрик
+var globalMetrics *Metrics
+
+// NewMetrics создает и регистрирует метрики публикатора outbox
+func NewMetrics() *Metrics {
+	// Возвращаем глобальный экземпляр, чтобы избежать дублирования метрик
+	if globalMetrics != nil {
+		return globalMetrics
+	}
+
+	globalMetrics = &Metrics{
+		PublishedTotal: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "outbox_published_total",
+			Help: "Количество событий outbox, отправленных в Kafka и отмеченных отправленными",
+		}),
+		PublishErrorsTotal: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "outbox_publish_errors_total",
+			Help: "Количество неудачных попыток публикации пакета событий outbox",
+		}),
+		BatchSize: promauto.NewHistogram(prometheus.HistogramOpts{
+			Name:    "outbox_publish_batch_size",
+			Help:    "Количество событий outbox, отправленных одним пакетом",
+			Buckets: []float64{1, 2, 5, 10, 25, 50, 100, 250, 500, 1000},
+		}),
+		PendingEvents: promauto.NewGauge(prometheus.GaugeOpts{
+			Name: "outbox_pending_events",
+			Help: "Количество неотправленных событий outbox",
+		}),
+		OldestPendingAge: promauto.NewGauge(prometheus.GaugeOpts{
+			Name: "outbox_oldest_pending_age_seconds",
+			Help: "Возраст самого старого неотправленного события outbox в секундах (0 — отставания нет)",
+		}),
+		Leader: promauto.NewGauge(prometheus.GaugeOpts{
+			Name: "outbox_publisher_leader",
+			Help: "Является ли экземпляр лидером публикатора outbox (1 — да, 0 — нет)",
+		}),
+	}
+
+	return globalMetrics
+}
@@ -0,0 +1,139 @@
+// Package outbox публикует в Kafka события, записанные в таблицу outbox вместе с заказом
+// (transactional outbox): событие не теряется, даже если сервис упал сразу после коммита.
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"test_service/internal/models"
+)
+
+// Параметры публикатора по умолчанию
+const (
+	DefaultBatchSize = 100         // Используется при batchSize <= 0
+	DefaultInterval  = time.Second // Используется при interval <= 0
+)
+
+// Store хранилище событий outbox (*database.Postgres)
+type Store interface {
+	// FetchOutbox возвращает до limit неотправленных событий в порядке id
+	FetchOutbox(ctx context.Context, limit int) ([]models.OutboxEvent, error)
+	// MarkOutboxPublished отмечает события отправленными
+	MarkOutboxPublished(ctx context.Context, ids []int64) error
+	// OutboxLag возвращает число неотправленных событий и время записи самого старого
+	OutboxLag(ctx context.Context) (int64, time.Time, error)
+}
+
+// Sender отправляет события получателям (*kafka.Producer)
+type Sender interface {
+	SendEvents(ctx context.Context, events []models.OutboxEvent) error
+}
+
+// Publisher периодически выбирает неотправленные события outbox, отправляет их пакетами
+// по порядку id и отмечает отправленными. Отметка идет после отправки, поэтому сбой между ними
+// приводит к повторной отправке пакета: доставка «хотя бы раз», получатель дедуплицирует
+// по id события. Публикатор должен быть один на все реплики (запускается под выбором лидера).
+type Publisher struct {
+	store     Store
+	sender    Sender
+	batchSize int           // Наибольшее число событий в пакете
+	interval  time.Duration // Пауза опроса, когда неотправленных событий не осталось
+	metrics   *Metrics
+	now       func() time.Time // Часы для возраста событий; подменяются в тестах
+}
+
+// NewPublisher создает публикатор; batchSize <= 0 и interval <= 0 заменяются значениями по умолчанию
+func NewPublisher(store Store, sender Sender, batchSize int, interval time.Duration) *Publisher {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Publisher{
+		store:     store,
+		sender:    sender,
+		batchSize: batchSize,
+		interval:  interval,
+		metrics:   NewMetrics(),
+		now:       time.Now,
+	}
+}
+
+// PublishBatch отправляет один пакет неотправленных событий и возвращает их число.
+// Ошибка отметки возвращается вместе с числом отправленных событий: они будут отправлены повторно.
+func (p *Publisher) PublishBatch(ctx context.Context) (int, error) {
+	events, err := p.store.FetchOutbox(ctx, p.batchSize)
+	if err != nil {
+		p.metrics.PublishErrorsTotal.Inc()
+		return 0, err
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	if err := p.sender.SendEvents(ctx, events); err != nil {
+		p.metrics.PublishErrorsTotal.Inc()
+		return 0, fmt.Errorf("Ошибка отправки событий outbox: %w", err)
+	}
+
+	ids := make([]int64, len(events))
+	for i, event := range events {
+		ids[i] = event.ID
+	}
+	if err := p.store.MarkOutboxPublished(ctx, ids); err != nil {
+		p.metrics.PublishErrorsTotal.Inc()
+		return len(events), fmt.Errorf("Ошибка отметки событий outbox (будут отправлены повторно): %w", err)
+	}
+
+	p.metrics.PublishedTotal.Add(float64(len(events)))
+	p.metrics.BatchSize.Observe(float64(len(events)))
+	return len(events), nil
+}
+
+// Run публикует события до отмены ctx. Полный пакет означает, что события, скорее всего,
+// еще есть, поэтому следующий выбирается сразу; иначе — после паузы interval.
+func (p *Publisher) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		n, err := p.PublishBatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Ошибка публикации outbox: %v", err)
+		}
+		p.updateLag(ctx)
+
+		if err == nil && n == p.batchSize {
+			timer.Reset(0)
+		} else {
+			timer.Reset(p.interval)
+		}
+	}
+}
+
+// updateLag обновляет метрики отставания публикации
+func (p *Publisher) updateLag(ctx context.Context) {
+	pending, oldest, err := p.store.OutboxLag(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Ошибка подсчета отставания outbox: %v", err)
+		}
+		return
+	}
+	p.metrics.PendingEvents.Set(float64(pending))
+	if pending == 0 || oldest.IsZero() {
+		p.metrics.OldestPendingAge.Set(0)
+		return
+	}
+	p.metrics.OldestPendingAge.Set(p.now().Sub(oldest).Seconds())
+}
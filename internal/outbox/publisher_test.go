@@ -0,0 +1,180 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"test_service/internal/models"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore таблица outbox в памяти
+type memoryStore struct {
+	mu        sync.Mutex
+	events    []models.OutboxEvent
+	published map[int64]bool
+	markErr   error
+}
+
+func newMemoryStore(n int, created time.Time) *memoryStore {
+	s := &memoryStore{published: make(map[int64]bool)}
+	for i := 1; i <= n; i++ {
+		s.events = append(s.events, models.OutboxEvent{ID: int64(i), AggregateID: "order", CreatedAt: created})
+	}
+	return s
+}
+
+func (s *memoryStore) FetchOutbox(_ context.Context, limit int) ([]models.OutboxEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var events []models.OutboxEvent
+	for _, event := range s.events {
+		if !s.published[event.ID] && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (s *memoryStore) MarkOutboxPublished(_ context.Context, ids []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.markErr != nil {
+		return s.markErr
+	}
+	for _, id := range ids {
+		s.published[id] = true
+	}
+	return nil
+}
+
+func (s *memoryStore) OutboxLag(context.Context) (int64, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var pending int64
+	var oldest time.Time
+	for _, event := range s.events {
+		if !s.published[event.ID] {
+			if pending == 0 {
+				oldest = event.CreatedAt
+			}
+			pending++
+		}
+	}
+	return pending, oldest, nil
+}
+
+// recordingSender запоминает id отправленных событий; err возвращается вместо отправки
+type recordingSender struct {
+	mu   sync.Mutex
+	sent []int64
+	err  error
+}
+
+func (s *recordingSender) SendEvents(_ context.Context, events []models.OutboxEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	for _, event := range events {
+		s.sent = append(s.sent, event.ID)
+	}
+	return nil
+}
+
+func (s *recordingSender) ids() []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int64(nil), s.sent...)
+}
+
+func TestPublisher_PublishBatch(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("InOrderByID", func(t *testing.T) {
+		store := newMemoryStore(5, time.Now())
+		sender := &recordingSender{}
+		p := NewPublisher(store, sender, 2, time.Millisecond)
+
+		for _, want := range []int{2, 2, 1, 0} {
+			n, err := p.PublishBatch(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, want, n)
+		}
+		assert.Equal(t, []int64{1, 2, 3, 4, 5}, sender.ids())
+	})
+
+	t.Run("SendErrorKeepsEvents", func(t *testing.T) {
+		store := newMemoryStore(2, time.Now())
+		p := NewPublisher(store, &recordingSender{err: errors.New("kafka down")}, 10, time.Millisecond)
+
+		_, err := p.PublishBatch(ctx)
+		assert.Error(t, err)
+		pending, _, _ := store.OutboxLag(ctx)
+		assert.Equal(t, int64(2), pending)
+	})
+
+	t.Run("MarkErrorResends", func(t *testing.T) {
+		// Отправленный, но не отмеченный пакет уходит повторно: доставка «хотя бы раз»
+		store := newMemoryStore(2, time.Now())
+		store.markErr = errors.New("db down")
+		sender := &recordingSender{}
+		p := NewPublisher(store, sender, 10, time.Millisecond)
+
+		n, err := p.PublishBatch(ctx)
+		assert.Error(t, err)
+		assert.Equal(t, 2, n)
+
+		store.markErr = nil
+		_, err = p.PublishBatch(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{1, 2, 1, 2}, sender.ids())
+	})
+}
+
+func TestPublisher_Run(t *testing.T) {
+	created := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := newMemoryStore(5, created)
+	store.markErr = errors.New("db down")
+	sender := &recordingSender{}
+	p := NewPublisher(store, sender, 2, 5*time.Millisecond)
+	p.now = func() time.Time { return created.Add(time.Minute) }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.Run(ctx)
+	}()
+
+	// Пока отметка не проходит, отставание видно в метриках
+	require.Eventually(t, func() bool { return len(sender.ids()) >= 2 }, time.Second, time.Millisecond)
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(p.metrics.OldestPendingAge) == time.Minute.Seconds()
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, 5.0, testutil.ToFloat64(p.metrics.PendingEvents))
+
+	store.mu.Lock()
+	store.markErr = nil
+	store.mu.Unlock()
+	require.Eventually(t, func() bool {
+		pending, _, _ := store.OutboxLag(ctx)
+		return pending == 0 && testutil.ToFloat64(p.metrics.PendingEvents) == 0
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, 0.0, testutil.ToFloat64(p.metrics.OldestPendingAge))
+
+	cancel()
+	<-done
+
+	// Каждое событие доставлено хотя бы раз, первые — по порядку
+	ids := sender.ids()
+	assert.Subset(t, ids, []int64{1, 2, 3, 4, 5})
+	assert.Equal(t, []int64{1, 2}, ids[:2])
+}
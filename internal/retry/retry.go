@@ -3,6 +3,7 @@ package retry
 
 import (
 	"context"
+	"errors"
 	"math/rand"
 	"time"
 )
@@ -55,6 +56,26 @@ type RetryableFunc func() error
 // ContextRetryableFunc тип функции с контекстом, которую можно повторять
 type ContextRetryableFunc func(context.Context) error
 
+// permanentError ошибка, после которой повторные попытки не выполняются
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent помечает ошибку как неустранимую повтором: DoWithContext сразу возвращает
+// исходную ошибку без оставшихся попыток. Для nil возвращает nil, уже помеченную ошибку — как есть.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*permanentError); ok {
+		return err
+	}
+	return &permanentError{err: err}
+}
+
 // Do выполняет функцию с повторными попытками согласно политике
 func Do(policy Policy, fn RetryableFunc) error {
 	return DoWithContext(context.Background(), policy, func(_ context.Context) error {
@@ -89,6 +110,12 @@ func DoWithContext(ctx context.Context, policy Policy, fn ContextRetryableFunc)
 			return nil
 		}
 
+		// Неустранимая ошибка: повторять бессмысленно
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return permanent.err
+		}
+
 		// Сохраняем последнюю ошибку
 		lastErr = err
 
@@ -90,6 +90,34 @@ func TestFailedRetry(t *testing.T) {
 	assert.Equal(t, "permanent error", err.Error())
 }
 
+func TestPermanentError(t *testing.T) {
+	attempts := 0
+	cause := errors.New("constraint violation")
+
+	fn := func() error {
+		attempts++
+		return Permanent(cause)
+	}
+
+	policy := Policy{
+		MaxAttempts:    3,
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     100 * time.Millisecond,
+		BackoffFactor:  2.0,
+		Jitter:         false,
+	}
+
+	err := Do(policy, fn)
+
+	assert.Equal(t, 1, attempts)
+	assert.Same(t, cause, err, "возвращается исходная ошибка")
+	assert.NoError(t, Permanent(nil))
+
+	// Повторная пометка не оборачивает ошибку еще раз
+	permanent := Permanent(cause)
+	assert.Same(t, permanent, Permanent(permanent))
+}
+
 func TestContextCancellation(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 
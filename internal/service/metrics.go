@@ -0,0 +1,37 @@
+package service
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// ServiceMetrics содержит метрики сервиса заказов
+type ServiceMetrics struct {
+	Degraded prometheus.Gauge
+
+	StatusTransitionsRejectedTotal prometheus.Counter
+}
+
+// Global metrics для предотвращения дублирования метрик
+var globalServiceMetrics *ServiceMetrics
+
+// NewServiceMetrics создает и регистрирует метрики сервиса
+func NewServiceMetrics() *ServiceMetrics {
+	// Возвращаем глобальный экземпляр, чтобы избежать дублирования метрик
+	if globalServiceMetrics != nil {
+		return globalServiceMetrics
+	}
+
+	globalServiceMetrics = &ServiceMetrics{
+		Degraded: promauto.NewGauge(prometheus.GaugeOpts{
+			Name: "service_degraded",
+			Help: "БД недоступна, заказы отдаются только из кэша (1) или сервис работает штатно (0)",
+		}),
+		StatusTransitionsRejectedTotal: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "service_status_transitions_rejected_total",
+			Help: "Количество отклоненных недопустимых переходов статуса заказа",
+		}),
+	}
+
+	return globalServiceMetrics
+}
@@ -3,16 +3,43 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"os"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"test_service/internal/cache"
+	"test_service/internal/database"
+	"test_service/internal/events"
 	"test_service/internal/interfaces"
 	"test_service/internal/models"
-	"test_service/internal/retry"
 )
 
+// getOrderTimeout верхняя граница времени запроса заказа из БД
+const getOrderTimeout = 30 * time.Second
+
+// defaultCleanupInterval период удаления истекших заказов из кэша, если не задан в opts
+const defaultCleanupInterval = 10 * time.Minute
+
+// orderCountsTTL время, в течение которого /stats отдает запомненные количества заказов из БД
+const orderCountsTTL = 30 * time.Second
+
+// orderCountsTimeout ограничение времени подсчета заказов в БД
+const orderCountsTimeout = 5 * time.Second
+
+// refreshWorkers максимум одновременных фоновых обновлений устаревших заказов
+const refreshWorkers = 8
+
+// defaultArchiveInterval период архивации заказов, если не задан в StartArchiving
+const defaultArchiveInterval = time.Hour
+
+// warmUpLogEvery через сколько загруженных заказов полный прогрев кэша пишет прогресс в лог
+const warmUpLogEvery = 10000
+
 // Service представляет основной сервис для работы с заказами
 type Service struct {
 	db    interfaces.Database // Подключение к базе данных PostgreSQL
@@ -21,60 +48,126 @@ type Service struct {
 	stats struct {
 		LastRequestTime     time.Time     // Время последнего запроса
 		LastRequestDuration time.Duration // Длительность обработки последнего запроса
+		CacheHits           uint64        // Заказы, найденные в кэше
+		CacheMisses         uint64        // Заказы, за которыми пришлось идти в БД
+		OrdersProcessed     uint64        // Заказы, успешно обработанные ProcessOrder и ProcessOrders
+		LastProcessedTime   time.Time     // Время обработки последнего сообщения из Kafka
 	}
-	cleanupTicker *time.Ticker  // Тикер для периодической очистки кэша
-	stopCleanup   chan struct{} // Канал для остановки очистки
-}
+	startTime time.Time       // Время запуска сервиса (для uptime)
+	degraded  atomic.Bool     // БД недоступна по последнему обращению
+	metrics   *ServiceMetrics // Метрики сервиса
+	events    *events.Hub     // Шина событий обработанных заказов
 
-// New создает новый экземпляр сервиса с инициализированным кэшем
-func New(db interfaces.Database) *Service {
-	// Создаем конкретный кэш с TTL
-	concreteCache := cache.New(30 * time.Minute) // Создаем новый кэш с TTL 30 минут
+	refreshMu  sync.Mutex          // Защищает refreshing
+	refreshing map[string]struct{} // UID заказов, обновляемых из БД в фоне
+	refreshSem chan struct{}       // Ограничение числа фоновых обновлений
+	refreshWG  sync.WaitGroup      // Ожидание фоновых обновлений при закрытии
 
-	svc := &Service{
-		db:            db,
-		cache:         concreteCache,                    // Присваиваем кэш интерфейсному полю (автоматическое преобразование)
-		cleanupTicker: time.NewTicker(10 * time.Minute), // Очистка каждые 10 минут
-		stopCleanup:   make(chan struct{}),              // Канал для остановки очистки
-	}
+	snapshotPath   string        // Файл снимка кэша (пустой — снимок отключен)
+	snapshotMaxAge time.Duration // Снимок старше не загружается (0 — без ограничения)
+
+	counts orderCounts // Количества заказов из БД для статистики
+
+	stopArchiving context.CancelFunc // Остановка фоновой архивации (nil — архивация не запущена)
+	archiveWG     sync.WaitGroup     // Ожидание текущего запуска архивации при закрытии
+}
+
+// orderCounts запомненные количества заказов; обновляются не чаще раза в orderCountsTTL
+type orderCounts struct {
+	mu        sync.Mutex // Сериализует обновление: одновременные запросы /stats ждут один подсчет
+	fetchedAt time.Time  // Время последней попытки подсчета
+	total     *int64     // Всего заказов (nil, пока подсчет не удался)
+	lastDay   *int64     // Заказы, созданные за последние сутки
+}
 
-	// Запуск фоновой задачи по очистке кэша
-	go svc.runCleanup()
+// New создает новый экземпляр сервиса с инициализированным кэшем;
+// opts настраивают кэш (например, cache.WithMaxEntries или cache.WithCleanupInterval)
+func New(db interfaces.Database, opts ...cache.Option) *Service {
+	// Очистка истекших заказов по умолчанию; opts могут переопределить период
+	opts = append([]cache.Option{cache.WithCleanupInterval(defaultCleanupInterval)}, opts...)
+	concreteCache := cache.New(30*time.Minute, opts...) // Создаем новый кэш с TTL 30 минут
 
-	return svc
+	return NewWithCache(db, concreteCache)
 }
 
 // NewWithCache создает новый экземпляр сервиса с предоставленным кэшем
 func NewWithCache(db interfaces.Database, cache interfaces.Cache) *Service {
-	svc := &Service{
-		db:            db,
-		cache:         cache,
-		cleanupTicker: time.NewTicker(10 * time.Minute), // Очистка каждые 10 минут
-		stopCleanup:   make(chan struct{}),              // Канал для остановки очистки
+	return &Service{
+		db:         db,
+		cache:      cache,
+		startTime:  time.Now(),          // Время запуска для uptime
+		metrics:    NewServiceMetrics(), // Метрики сервиса
+		events:     events.NewHub(),     // Шина событий для живых обновлений
+		refreshing: make(map[string]struct{}),
+		refreshSem: make(chan struct{}, refreshWorkers),
 	}
+}
 
-	// Запуск фоновой задачи по очистке кэша
-	go svc.runCleanup()
-
-	return svc
+// SetSnapshot включает снимок кэша на диске: Close сохраняет кэш в path, а WarmUpCache
+// сначала загружает его и идет в БД, только если снимок отсутствует, поврежден или старше maxAge.
+// Вызывается до WarmUpCache; пустой path отключает снимок.
+func (s *Service) SetSnapshot(path string, maxAge time.Duration) {
+	s.snapshotPath = path
+	s.snapshotMaxAge = maxAge
 }
 
 // WarmUpCache загружает все заказы из БД в кэш при старте сервиса.
+// При включенном снимке (SetSnapshot) кэш загружается из него, а из БД догружаются
+// только заказы, созданные не раньше самого нового заказа снимка (WarmUpCacheSince).
 func (s *Service) WarmUpCache(ctx context.Context) error {
-	orders, err := s.db.GetAllOrders(ctx)
+	if s.snapshotPath != "" {
+		n, err := s.loadSnapshot()
+		if err == nil {
+			log.Printf("Кэш загружен из снимка %s: %d заказов", s.snapshotPath, n)
+			return s.WarmUpCacheSince(ctx, s.cache.NewestDateCreated())
+		}
+		log.Printf("Снимок кэша не загружен, прогрев из БД: %v", err)
+	}
+	return s.WarmUpCacheSince(ctx, time.Time{})
+}
+
+// WarmUpCacheSince догружает в кэш заказы, созданные начиная с since, не трогая остальные.
+// Нулевое since означает отсутствие отметки: содержимое кэша атомарно заменяется всеми заказами
+// из БД, которые читаются потоком (StreamAllOrders) без загрузки таблицы в память целиком.
+// До замены читатели видят прежний кэш, а при ошибке чтения он остается нетронутым.
+// Заказы идут от старых к новым, поэтому при ограничении размера в кэше остаются самые новые.
+func (s *Service) WarmUpCacheSince(ctx context.Context, since time.Time) error {
+	if !since.IsZero() {
+		orders, err := s.db.GetOrdersSince(ctx, since)
+		if err != nil {
+			return err
+		}
+		s.cache.LoadFromSlice(orders)
+		log.Printf("Кэш догружен из БД: %d заказов начиная с %s", len(orders), since.Format(time.RFC3339))
+		return nil
+	}
+
+	// Кэш заменяется целиком: удаленные из БД заказы в нем не остаются
+	loaded := 0
+	err := s.cache.ReplaceStream(func(add func(*models.Order)) error {
+		return s.db.StreamAllOrders(ctx, func(order *models.Order) error {
+			add(order)
+			loaded++
+			if loaded%warmUpLogEvery == 0 {
+				log.Printf("Прогрев кэша: загружено %d заказов", loaded)
+			}
+			return nil
+		})
+	})
 	if err != nil {
 		return err
 	}
-	// Загружаем в кэш целиком
-	s.cache.LoadFromSlice(orders)
 	log.Printf("Кэш прогрет: %d заказов", s.cache.Size())
 	return nil
 }
 
-// ProcessOrder обрабатывает новый заказ: сохраняет в БД и добавляет в кэш
-func (s *Service) ProcessOrder(order *models.Order) error {
-	// Создаем контекст с таймаутом 60 секунд, чтобы учесть возможные повторные попытки
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+// ProcessOrder обрабатывает новый заказ: сохраняет в БД и добавляет в кэш.
+// Повторные попытки выполняет SaveOrder: он повторяет только временные сбои БД, поэтому
+// сервис заказ не пересохраняет. Отмена ctx (например, при остановке consumer)
+// прерывает сохранение и повторные попытки.
+func (s *Service) ProcessOrder(ctx context.Context, order *models.Order) error {
+	// Ограничиваем сохранение 60 секундами, чтобы учесть возможные повторные попытки
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
 	defer cancel()
 
 	// Если дата создания не установлена, устанавливаем текущее время
@@ -82,98 +175,592 @@ func (s *Service) ProcessOrder(order *models.Order) error {
 		order.DateCreated = time.Now()
 	}
 
-	// Используем retry механизм для операции сохранения в БД
-	retryPolicy := retry.HeavyPolicy() // Используем тяжелую политику для критических операций
-	
-	err := retry.DoWithContext(ctx, retryPolicy, func(ctx context.Context) error {
-		// Сохраняем заказ в базу данных
-		return s.db.SaveOrder(ctx, order)
-	})
+	err := s.db.SaveOrder(ctx, order)
+	s.trackDB(err)
 	
 	if err != nil {
 		return err
 	}
 
+	s.orderSaved(order)
+	return nil
+}
+
+// ProcessOrders обрабатывает пакет заказов (пакетный режим consumer): сохраняет их одной
+// транзакцией Database.SaveOrders и добавляет в кэш. Ошибка означает, что не сохранен ни один
+// заказ. Повторов сверх повторов SaveOrders здесь нет: consumer после ошибки обрабатывает
+// заказы пакета по одному через ProcessOrder, чтобы один плохой заказ не задерживал остальные.
+func (s *Service) ProcessOrders(ctx context.Context, orders []*models.Order) error {
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	for _, order := range orders {
+		if order.DateCreated.IsZero() {
+			order.DateCreated = time.Now()
+		}
+	}
+
+	err := s.db.SaveOrders(ctx, orders)
+	s.trackDB(err)
+	if err != nil {
+		return err
+	}
+
+	for _, order := range orders {
+		s.orderSaved(order)
+	}
+	return nil
+}
+
+// orderSaved обновляет кэш, статистику и подписчиков после сохранения заказа в БД
+func (s *Service) orderSaved(order *models.Order) {
+	// Мягко удаленный заказ сохраняется, но остается скрытым: в кэш и подписчикам он не попадает
+	if order.DeletedAt != nil {
+		s.cache.Delete(order.OrderUID)
+		log.Printf("Заказ %s сохранен, но отмечен удаленным и скрыт", order.OrderUID)
+		return
+	}
+
 	// Добавляем заказ в кэш для быстрого доступа
 	s.cache.Set(order)
 
+	s.mu.Lock()
+	s.stats.OrdersProcessed++
+	s.stats.LastProcessedTime = time.Now()
+	s.mu.Unlock()
+
+	// Уведомляем подписчиков (WebSocket) об обработанном заказе
+	s.events.Publish(events.Event{Type: events.OrderProcessed, Order: order})
+
 	log.Printf("Заказ обработан %s", order.OrderUID)
-	return nil
 }
 
-// GetOrder получает заказ по его UID с использованием кэша и БД
-func (s *Service) GetOrder(orderUID string) (*models.Order, error) {
+// Events возвращает шину событий обработанных заказов
+func (s *Service) Events() *events.Hub {
+	return s.events
+}
+
+// GetOrder получает заказ по его UID с использованием кэша и БД.
+// Запрос к БД выполняется в контексте вызывающего, ограниченном сверху таймаутом сервиса.
+func (s *Service) GetOrder(ctx context.Context, orderUID string) (*models.Order, error) {
+	order, _, err := s.GetOrderWithSource(ctx, orderUID)
+	return order, err
+}
+
+// GetOrderWithSource получает заказ так же, как GetOrder, и сообщает источник: кэш или БД.
+// По источнику здесь же обновляются счетчики попаданий и промахов кэша для статистики.
+func (s *Service) GetOrderWithSource(ctx context.Context, orderUID string) (*models.Order, interfaces.Source, error) {
 	// Засекаем время начала обработки запроса
 	start := time.Now()
 
 	// Обновляем время последнего запроса
 	s.mu.Lock()
-	s.stats.LastRequestTime = time.Now()
+	s.stats.LastRequestTime = start
 	s.mu.Unlock()
 
-	// Сначала пытаемся найти заказ в кэше
-	if order, exists := s.cache.Get(orderUID); exists {
-		// Заказ найден в кэше - быстрое получение
-		s.mu.Lock()
-		s.stats.LastRequestDuration = time.Since(start)
-		s.mu.Unlock()
-		return order, nil
+	order, source, err := s.lookupOrder(ctx, orderUID)
+	s.recordLookup(source, time.Since(start))
+	return order, source, err
+}
+
+// lookupOrder ищет заказ в кэше, при промахе — в БД с последующим сохранением в кэш
+func (s *Service) lookupOrder(ctx context.Context, orderUID string) (*models.Order, interfaces.Source, error) {
+	// Сначала пытаемся найти заказ в кэше; устаревший отдаем сразу и обновляем в фоне.
+	// Мягко удаленный заказ считается ненайденным и удаляется из кэша.
+	if order, stale, exists := s.cache.Lookup(orderUID); exists {
+		if order.DeletedAt != nil {
+			s.cache.Delete(orderUID)
+			return nil, interfaces.SourceCache, fmt.Errorf("%w: %s", models.ErrOrderNotFound, orderUID)
+		}
+		if stale {
+			s.revalidate(orderUID)
+		}
+		return order, interfaces.SourceCache, nil
 	}
 
-	// Заказ не найден в кэше, ищем в базе данных
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	// Заказ не найден в кэше, ищем в базе данных. Одновременные промахи по одному UID
+	// ждут один запрос, найденный заказ сохраняется в кэш, ошибка — нет.
+	// Запрос общий для всех ожидающих, поэтому отмена контекста первого вызывающего
+	// его не прерывает: загрузка ограничена только собственным таймаутом
+	order, cached, err := s.cache.GetOrSet(orderUID, func() (*models.Order, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), getOrderTimeout)
+		defer cancel()
 
-	order, err := s.db.GetOrder(ctx, orderUID)
+		order, err := s.db.GetOrder(ctx, orderUID)
+		s.trackDB(err)
+		return order, err
+	})
 	if err != nil {
-		// Ошибка при получении из БД
-		s.mu.Lock()
-		s.stats.LastRequestDuration = time.Since(start)
-		s.mu.Unlock()
-		return nil, err
+		return nil, interfaces.SourceDatabase, err
 	}
+	if cached {
+		// Заказ успели загрузить между Lookup и GetOrSet
+		return order, interfaces.SourceCache, nil
+	}
+	return order, interfaces.SourceDatabase, nil
+}
 
-	// Добавляем заказ в кэш для будущих запросов
-	s.cache.Set(order)
+// revalidate запускает фоновое обновление устаревшего заказа из БД. Для каждого UID
+// выполняется не больше одного обновления; при занятых воркерах обновление пропускается,
+// и его запустит одно из следующих чтений.
+func (s *Service) revalidate(orderUID string) {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	if _, inFlight := s.refreshing[orderUID]; inFlight {
+		return
+	}
+	select {
+	case s.refreshSem <- struct{}{}:
+	default:
+		return
+	}
+	s.refreshing[orderUID] = struct{}{}
+	s.refreshWG.Add(1)
+
+	go func() {
+		defer func() {
+			s.refreshMu.Lock()
+			delete(s.refreshing, orderUID)
+			s.refreshMu.Unlock()
+			<-s.refreshSem
+			s.refreshWG.Done()
+		}()
 
-	// Обновляем статистику времени обработки
+		ctx, cancel := context.WithTimeout(context.Background(), getOrderTimeout)
+		defer cancel()
+
+		order, err := s.db.GetOrder(ctx, orderUID)
+		s.trackDB(err)
+		if errors.Is(err, models.ErrOrderNotFound) {
+			// Заказ удален (в том числе мягко) после попадания в кэш
+			s.cache.Delete(orderUID)
+			return
+		}
+		if err != nil {
+			log.Printf("Ошибка фонового обновления заказа %s: %v", orderUID, err)
+			return
+		}
+		s.cache.Revalidate(order)
+	}()
+}
+
+// recordLookup обновляет счетчики попаданий и промахов кэша и длительность последнего запроса
+func (s *Service) recordLookup(source interfaces.Source, duration time.Duration) {
 	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if source == interfaces.SourceCache {
+		s.stats.CacheHits++
+	} else {
+		s.stats.CacheMisses++
+	}
+	s.stats.LastRequestDuration = duration
+}
+
+// GetOrders получает несколько заказов: найденные в кэше берутся одним проходом по кэшу,
+// остальные читаются из БД и кэшируются. Отсутствующие в БД заказы в результат не попадают;
+// прочие ошибки БД прерывают запрос.
+func (s *Service) GetOrders(ctx context.Context, orderUIDs []string) (map[string]*models.Order, error) {
+	start := time.Now()
+
+	found, missing := s.cache.GetMulti(orderUIDs)
+	hits := len(found)
+
+	ctx, cancel := context.WithTimeout(ctx, getOrderTimeout)
+	defer cancel()
+
+	var err error
+	for _, uid := range missing {
+		order, dbErr := s.db.GetOrder(ctx, uid)
+		s.trackDB(dbErr)
+		if errors.Is(dbErr, models.ErrOrderNotFound) {
+			continue
+		}
+		if dbErr != nil {
+			err = dbErr
+			break
+		}
+		s.cache.Set(order)
+		found[order.OrderUID] = order
+	}
+
+	s.mu.Lock()
+	s.stats.LastRequestTime = start
+	s.stats.CacheHits += uint64(hits)
+	s.stats.CacheMisses += uint64(len(missing))
 	s.stats.LastRequestDuration = time.Since(start)
 	s.mu.Unlock()
 
+	if err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// GetOrdersByTrackNumber ищет заказы по трек-номеру. Сначала проверяется индекс трек-номеров
+// кэша; при промахе заказы читаются из БД и кэшируются. Если заказов нет ни в кэше,
+// ни в БД, возвращает models.ErrOrderNotFound.
+func (s *Service) GetOrdersByTrackNumber(ctx context.Context, trackNumber string) ([]*models.Order, interfaces.Source, error) {
+	start := time.Now()
+	s.mu.Lock()
+	s.stats.LastRequestTime = start
+	s.mu.Unlock()
+
+	if orders := s.cache.GetByTrackNumber(trackNumber); len(orders) > 0 {
+		s.recordLookup(interfaces.SourceCache, time.Since(start))
+		return orders, interfaces.SourceCache, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, getOrderTimeout)
+	defer cancel()
+
+	found, err := s.db.GetOrderByTrackNumber(ctx, trackNumber)
+	s.trackDB(err)
+	s.recordLookup(interfaces.SourceDatabase, time.Since(start))
+	if err != nil {
+		return nil, interfaces.SourceDatabase, err
+	}
+
+	orders := make([]*models.Order, len(found))
+	for i := range found {
+		orders[i] = &found[i]
+		s.cache.Set(orders[i])
+	}
+	return orders, interfaces.SourceDatabase, nil
+}
+
+// FindOrdersByContact ищет заказы по email или телефону покупателя, от новых к старым.
+// Поиск идет в БД в обход кэша: кэш не индексирует контакты. Найденные заказы не кэшируются.
+func (s *Service) FindOrdersByContact(ctx context.Context, email, phone string, limit int) ([]models.Order, error) {
+	ctx, cancel := context.WithTimeout(ctx, getOrderTimeout)
+	defer cancel()
+
+	orders, err := s.db.FindOrdersByContact(ctx, email, phone, limit)
+	s.trackDB(err)
+	return orders, err
+}
+
+// DeleteOrder удаляет заказ из БД и из кэша.
+// Возвращает models.ErrOrderNotFound, если заказа нет в БД.
+func (s *Service) DeleteOrder(ctx context.Context, orderUID string) error {
+	err := s.db.DeleteOrder(ctx, orderUID)
+	s.trackDB(err)
+	// Кэш очищаем и при отсутствии заказа в БД, чтобы не отдавать устаревшую копию
+	if err == nil || errors.Is(err, models.ErrOrderNotFound) {
+		if s.cache.Delete(orderUID) {
+			log.Printf("Заказ %s удален из кэша", orderUID)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Заказ удален %s", orderUID)
+	return nil
+}
+
+// ArchiveOrders переносит в архив БД заказы, созданные раньше cutoff, и удаляет их из кэша.
+// Из кэша удаляются все заказы старше cutoff, в том числе перенесенные другим экземпляром
+// сервиса и оставшиеся в БД из-за ошибки: последние будут прочитаны из БД при обращении.
+func (s *Service) ArchiveOrders(ctx context.Context, cutoff time.Time) (int64, error) {
+	archived, err := s.db.ArchiveOrdersBefore(ctx, cutoff, database.DefaultArchiveBatchSize)
+	s.trackDB(err)
+
+	if evicted := s.cache.DeleteCreatedBefore(cutoff); evicted > 0 {
+		log.Printf("Из кэша удалено архивированных заказов: %d", evicted)
+	}
+	return archived, err
+}
+
+// EvictOrdersCreatedBefore удаляет из кэша заказы, созданные раньше cutoff, после удаления
+// их секций из БД (database.PartitionManager)
+func (s *Service) EvictOrdersCreatedBefore(cutoff time.Time) {
+	if evicted := s.cache.DeleteCreatedBefore(cutoff); evicted > 0 {
+		log.Printf("Из кэша удалено заказов из удаленных секций: %d", evicted)
+	}
+}
+
+// StartArchiving запускает фоновую архивацию: сразу и затем каждые interval заказы старше
+// retention переносятся в архив (ArchiveOrders). retention <= 0 архивацию не запускает,
+// interval <= 0 заменяется на defaultArchiveInterval. Архивация останавливается в Close.
+func (s *Service) StartArchiving(retention, interval time.Duration) {
+	if retention <= 0 || s.stopArchiving != nil {
+		return
+	}
+	if interval <= 0 {
+		interval = defaultArchiveInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.stopArchiving = cancel
+	s.archiveWG.Add(1)
+	go func() {
+		defer s.archiveWG.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if _, err := s.ArchiveOrders(ctx, time.Now().Add(-retention)); err != nil && ctx.Err() == nil {
+				log.Printf("Ошибка архивации заказов: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	log.Printf("Архивация заказов старше %s запущена, период %s", retention, interval)
+}
+
+// SoftDeleteOrder скрывает заказ: в БД он отмечается удаленным, данные сохраняются,
+// а из кэша заказ удаляется. Возвращает models.ErrOrderNotFound, если заказа нет или он уже скрыт.
+func (s *Service) SoftDeleteOrder(ctx context.Context, orderUID string) error {
+	err := s.db.SoftDeleteOrder(ctx, orderUID)
+	s.trackDB(err)
+	// Как и в DeleteOrder, кэш очищаем и при отсутствии заказа в БД
+	if err == nil || errors.Is(err, models.ErrOrderNotFound) {
+		s.cache.Delete(orderUID)
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Заказ скрыт (мягкое удаление) %s", orderUID)
+	return nil
+}
+
+// UpdateOrderStatus меняет статус заказа и возвращает обновленный заказ, который заодно
+// обновляется в кэше. Недопустимый переход (например, shipped → accepted) отклоняется
+// *models.StatusTransitionError без записи в БД; установка текущего статуса ничего не меняет.
+// Переход проверяется по прочитанному из БД статусу, поэтому при одновременных изменениях
+// одного заказа побеждает последняя запись.
+func (s *Service) UpdateOrderStatus(ctx context.Context, orderUID string, status models.OrderStatus) (*models.Order, error) {
+	if _, err := models.ParseOrderStatus(string(status)); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	// Оба чтения идут на основной сервер: реплика может отставать, и проверка перехода
+	// по устаревшему статусу пропустила бы запрещенный переход, а в кэш попала бы
+	// версия без только что записанного статуса
+	current, err := s.db.GetOrder(ctx, orderUID, interfaces.FromPrimary())
+	s.trackDB(err)
+	if err != nil {
+		if errors.Is(err, models.ErrOrderNotFound) {
+			s.cache.Delete(orderUID)
+		}
+		return nil, err
+	}
+	if !current.Status.CanTransitionTo(status) {
+		s.metrics.StatusTransitionsRejectedTotal.Inc()
+		return nil, &models.StatusTransitionError{OrderUID: orderUID, From: current.Status, To: status}
+	}
+	if current.Status == status {
+		s.cache.Set(current)
+		return current, nil
+	}
+
+	err = s.db.UpdateOrderStatus(ctx, orderUID, status)
+	s.trackDB(err)
+	if err != nil {
+		if errors.Is(err, models.ErrOrderNotFound) {
+			s.cache.Delete(orderUID)
+		}
+		return nil, err
+	}
+
+	// Перечитываем заказ, чтобы кэш получил и updated_at, выставленный БД
+	order, err := s.db.GetOrder(ctx, orderUID, interfaces.FromPrimary())
+	s.trackDB(err)
+	if err != nil {
+		// Статус уже изменен: копию со старым статусом из кэша убираем
+		s.cache.Delete(orderUID)
+		return nil, err
+	}
+	s.cache.Set(order)
+
+	log.Printf("Статус заказа %s изменен: %s → %s", orderUID, current.Status, status)
 	return order, nil
 }
 
-// GetCacheStats возвращает статистику работы сервиса
+// ClearCache удаляет все заказы из кэша; следующие запросы читают заказы из БД
+func (s *Service) ClearCache() int {
+	removed := s.cache.Clear()
+	log.Printf("Кэш очищен, удалено заказов: %d", removed)
+	return removed
+}
+
+// GetCacheStats возвращает статистику работы сервиса.
+// Имена ключей стабильны: на них опираются дашборды.
 func (s *Service) GetCacheStats() map[string]interface{} {
+	// Подсчет в БД выполняется до захвата блокировки статистики
+	total, lastDay := s.orderCounts()
+	pool := s.poolStats()
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	// Доля попаданий в кэш среди запросов заказа (0, пока запросов не было)
+	hitRatio := 0.0
+	if lookups := s.stats.CacheHits + s.stats.CacheMisses; lookups > 0 {
+		hitRatio = float64(s.stats.CacheHits) / float64(lookups)
+	}
+
+	// Время последнего сообщения из Kafka (null, пока сообщений не было)
+	var lastProcessed *time.Time
+	if !s.stats.LastProcessedTime.IsZero() {
+		lastProcessed = &s.stats.LastProcessedTime
+	}
+
+	cacheBytes, cacheBudget := s.cache.MemoryUsage()
+
 	return map[string]interface{}{
-		"cache_size":            s.cache.Size(),                             // Количество элементов в кэше
-		"last_request_time":     s.stats.LastRequestTime,                    // Время последнего запроса
-		"last_request_duration": s.stats.LastRequestDuration.Milliseconds(), // Длительность последнего запроса в миллисекундах
-		"timestamp":             time.Now().UTC(),                           // Текущее время
+		"cache_size":             s.cache.Size(),                             // Количество элементов в кэше
+		"cache_evictions":        s.cache.Evicted(),                          // Вытеснено из-за ограничения размера
+		"cache_bytes":            cacheBytes,                                 // Оценка памяти, занятой заказами
+		"cache_bytes_budget":     cacheBudget,                                // Бюджет памяти кэша (0 — без ограничения)
+		"cache_hits":             s.stats.CacheHits,                          // Попадания в кэш
+		"cache_misses":           s.stats.CacheMisses,                        // Промахи кэша (запросы в БД)
+		"cache_hit_ratio":        hitRatio,                                   // Доля попаданий в кэш
+		"orders_processed_total": s.stats.OrdersProcessed,                    // Обработанные заказы
+		"orders_total":           total,                                      // Заказов в БД (null, если подсчет не удался)
+		"orders_last_24h":        lastDay,                                    // Заказов в БД, созданных за последние сутки
+		"last_order_processed":   lastProcessed,                              // Время обработки последнего сообщения
+		"uptime_seconds":         int64(time.Since(s.startTime).Seconds()),   // Время работы сервиса в секундах
+		"started_at":             s.startTime.UTC(),                          // Время запуска сервиса
+		"last_request_time":      s.stats.LastRequestTime,                    // Время последнего запроса
+		"last_request_duration":  s.stats.LastRequestDuration.Milliseconds(), // Длительность последнего запроса в миллисекундах
+		"degraded":               s.degraded.Load(),                          // БД недоступна
+		"database":               pool,                                       // Статистика пула соединений с БД
+		"timestamp":              time.Now().UTC(),                           // Текущее время
 	}
 }
 
-// runCleanup запускает фоновую задачу по очистке кэша
-func (s *Service) runCleanup() {
-	for {
-		select {
-		case <-s.cleanupTicker.C:
-			s.cache.Cleanup() // Очищаем истекшие элементы
-		case <-s.stopCleanup:
-			return
+// poolStats возвращает статистику пула соединений с БД; нули, если БД ее не предоставляет
+func (s *Service) poolStats() interfaces.PoolStats {
+	if provider, ok := s.db.(interfaces.PoolStatsProvider); ok {
+		return provider.Stats()
+	}
+	return interfaces.PoolStats{}
+}
+
+// orderCounts возвращает количество заказов в БД всего и за последние сутки. Значения
+// запоминаются на orderCountsTTL, чтобы частый опрос /stats не порождал запросы count(*);
+// при ошибке подсчета остаются прежние значения, до первого успешного — nil.
+func (s *Service) orderCounts() (total, lastDay *int64) {
+	c := &s.counts
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if !c.fetchedAt.IsZero() && now.Sub(c.fetchedAt) < orderCountsTTL {
+		return c.total, c.lastDay
+	}
+	c.fetchedAt = now // Неудачная попытка тоже откладывает следующую
+
+	ctx, cancel := context.WithTimeout(context.Background(), orderCountsTimeout)
+	defer cancel()
+
+	n, err := s.db.CountOrders(ctx)
+	if err != nil {
+		log.Printf("Ошибка подсчета заказов для статистики: %v", err)
+		return c.total, c.lastDay
+	}
+	day, err := s.db.CountOrdersSince(ctx, now.Add(-24*time.Hour))
+	if err != nil {
+		log.Printf("Ошибка подсчета заказов за сутки для статистики: %v", err)
+		return c.total, c.lastDay
+	}
+	c.total, c.lastDay = &n, &day
+	return c.total, c.lastDay
+}
+
+// StreamOrders последовательно передает все заказы из БД в fn (в обход кэша)
+func (s *Service) StreamOrders(ctx context.Context, fn func(*models.Order) error) error {
+	return s.db.StreamOrders(ctx, fn)
+}
+
+// HealthStatus проверяет соединение с БД для /readyz. Результат проверки обновляет
+// признак деградации так же, как обычные запросы к БД.
+func (s *Service) HealthStatus(ctx context.Context) error {
+	err := s.db.Ping(ctx)
+	s.trackDB(err)
+	return err
+}
+
+// Degraded сообщает, что последнее обращение к БД завершилось ошибкой соединения
+func (s *Service) Degraded() bool {
+	return s.degraded.Load()
+}
+
+// trackDB обновляет признак деградации по результату обращения к БД:
+// ошибка соединения включает его, успешный ответ выключает, прочие ошибки не меняют
+func (s *Service) trackDB(err error) {
+	switch {
+	case err == nil, errors.Is(err, models.ErrOrderNotFound):
+		if s.degraded.Swap(false) {
+			log.Println("Соединение с БД восстановлено")
 		}
+		s.metrics.Degraded.Set(0)
+	case database.IsUnavailable(err):
+		if !s.degraded.Swap(true) {
+			log.Printf("БД недоступна, заказы отдаются только из кэша: %v", err)
+		}
+		s.metrics.Degraded.Set(1)
 	}
 }
 
-// Close закрывает соединение с базой данных и останавливает очистку кэша
-func (s *Service) Close() {
-	// Останавливаем тикер очистки
-	s.cleanupTicker.Stop()
-	close(s.stopCleanup) // Останавливаем фоновую задачу
+// loadSnapshot загружает кэш из файла снимка
+func (s *Service) loadSnapshot() (int, error) {
+	f, err := os.Open(s.snapshotPath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return s.cache.ReadSnapshot(f, s.snapshotMaxAge)
+}
+
+// saveSnapshot сохраняет кэш в файл снимка. Снимок пишется во временный файл рядом
+// и переименовывается, поэтому прерванная запись не портит предыдущий снимок.
+func (s *Service) saveSnapshot() error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.snapshotPath), filepath.Base(s.snapshotPath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("ошибка создания снимка кэша: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // После переименования файла уже нет, ошибка игнорируется
 
+	if err := s.cache.WriteSnapshot(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("ошибка записи снимка кэша: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.snapshotPath); err != nil {
+		return fmt.Errorf("ошибка сохранения снимка кэша: %w", err)
+	}
+	return nil
+}
+
+// Close закрывает соединение с базой данных и останавливает очистку кэша.
+// При включенном снимке (SetSnapshot) кэш сохраняется на диск.
+func (s *Service) Close() {
+	s.cache.StopJanitor() // Останавливаем фоновую очистку кэша
+	s.events.Close()      // Закрываем подписки, чтобы живые соединения завершились
+	s.refreshWG.Wait()    // Дожидаемся фоновых обновлений до закрытия БД
+	if s.stopArchiving != nil {
+		s.stopArchiving() // Прерываем архивацию: зафиксированные пакеты остаются в архиве
+	}
+	s.archiveWG.Wait()
+	if s.snapshotPath != "" {
+		if err := s.saveSnapshot(); err != nil {
+			log.Printf("Снимок кэша не сохранен: %v", err)
+		} else {
+			log.Printf("Снимок кэша сохранен в %s", s.snapshotPath)
+		}
+	}
 	s.db.Close()
 }
@@ -2,16 +2,40 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"test_service/internal/cache"
+	"test_service/internal/database"
+	"test_service/internal/interfaces"
 	"test_service/internal/mocks"
 	"test_service/internal/models"
 
 	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+// streamOrders имитирует StreamAllOrders: передает orders в fn по порядку
+func streamOrders(orders []models.Order) func(context.Context, func(*models.Order) error, ...interfaces.ReadOption) error {
+	return func(_ context.Context, fn func(*models.Order) error, _ ...interfaces.ReadOption) error {
+		for i := range orders {
+			if err := fn(&orders[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
 func TestService_WarmUpCache(t *testing.T) {
 	ctx := context.Background()
 	testOrders := []models.Order{
@@ -29,12 +53,14 @@ func TestService_WarmUpCache(t *testing.T) {
 		svc := NewWithCache(mockDB, mockCache)
 
 		// Ожидаемые вызовы
-		mockDB.EXPECT().GetAllOrders(ctx).Return(testOrders, nil)
-		mockCache.EXPECT().LoadFromSlice(testOrders)
+		var added []*models.Order
+		expectReplace(mockCache, &added)
+		mockDB.EXPECT().StreamAllOrders(ctx, gomock.Any()).DoAndReturn(streamOrders(testOrders))
 		mockCache.EXPECT().Size().Return(len(testOrders))
 
 		err := svc.WarmUpCache(ctx)
 		assert.NoError(t, err, "загрузка кэша не должна возвращать ошибки")
+		assert.Len(t, added, len(testOrders))
 	})
 
 	t.Run("DatabaseError", func(t *testing.T) {
@@ -47,12 +73,33 @@ func TestService_WarmUpCache(t *testing.T) {
 		svc := NewWithCache(mockDB, mockCache)
 
 		// Ожидаемый вызов с возвратом ошибки
-		mockDB.EXPECT().GetAllOrders(ctx).Return(nil, errors.New("database error"))
+		expectReplace(mockCache, new([]*models.Order))
+		mockDB.EXPECT().StreamAllOrders(ctx, gomock.Any()).Return(errors.New("database error"))
 
 		err := svc.WarmUpCache(ctx)
 		assert.Error(t, err, "загрузка кэша при ошибке базы данных должна возвращать ошибку")
 		assert.Contains(t, err.Error(), "database error", "ошибка должна содержать текст 'database error'")
 	})
+
+	t.Run("RewarmDropsDeleted", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		orderCache := cache.New(30 * time.Minute)
+		svc := NewWithCache(mockDB, orderCache)
+
+		mockDB.EXPECT().StreamAllOrders(ctx, gomock.Any()).DoAndReturn(streamOrders(testOrders))
+		require.NoError(t, svc.WarmUpCache(ctx))
+
+		// После восстановления БД order-2 в ней больше нет
+		mockDB.EXPECT().StreamAllOrders(ctx, gomock.Any()).DoAndReturn(streamOrders(testOrders[:1]))
+		require.NoError(t, svc.WarmUpCache(ctx))
+
+		_, exists := orderCache.Get("order-2")
+		assert.False(t, exists, "повторный прогрев удаляет отсутствующие в БД заказы")
+		assert.Equal(t, 1, orderCache.Size())
+	})
 }
 
 func TestService_ProcessOrder(t *testing.T) {
@@ -73,10 +120,30 @@ func TestService_ProcessOrder(t *testing.T) {
 		mockDB.EXPECT().SaveOrder(gomock.Any(), order).Return(nil)
 		mockCache.EXPECT().Set(order)
 
-		err := svc.ProcessOrder(order)
+		err := svc.ProcessOrder(context.Background(), order)
 		assert.NoError(t, err, "обработка заказа не должна возвращать ошибки")
 	})
 
+	t.Run("SoftDeletedStaysHidden", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		mockCache := mocks.NewMockCache(ctrl)
+		svc := NewWithCache(mockDB, mockCache)
+
+		// Повторно сохраненный мягко удаленный заказ не попадает в кэш (вызова Set нет)
+		deleted := &models.Order{OrderUID: "order-deleted", Locale: "en"}
+		mockDB.EXPECT().SaveOrder(gomock.Any(), deleted).DoAndReturn(func(_ context.Context, order *models.Order) error {
+			deletedAt := time.Now()
+			order.DeletedAt = &deletedAt
+			return nil
+		})
+		mockCache.EXPECT().Delete("order-deleted").Return(false)
+
+		assert.NoError(t, svc.ProcessOrder(context.Background(), deleted))
+	})
+
 	t.Run("DatabaseError", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
@@ -85,13 +152,136 @@ func TestService_ProcessOrder(t *testing.T) {
 		mockCache := mocks.NewMockCache(ctrl)
 		svc := NewWithCache(mockDB, mockCache)
 
-		// Ожидаемый вызов с возвратом ошибки для всех попыток (включая retry)
-		mockDB.EXPECT().SaveOrder(gomock.Any(), order).Return(errors.New("database error")).AnyTimes()
+		// Повторы временных сбоев выполняет SaveOrder: сервис сохраняет заказ один раз
+		mockDB.EXPECT().SaveOrder(gomock.Any(), order).Return(errors.New("database error")).Times(1)
 
-		err := svc.ProcessOrder(order)
+		err := svc.ProcessOrder(context.Background(), order)
 		assert.Error(t, err, "обработка заказа при ошибке базы данных должна возвращать ошибку")
 		assert.Contains(t, err.Error(), "database error", "ошибка должна содержать текст 'database error'")
 	})
+
+	t.Run("ConstraintViolationNotRetried", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		mockCache := mocks.NewMockCache(ctrl)
+		svc := NewWithCache(mockDB, mockCache)
+
+		// Ошибка данных возвращается после первой попытки, в кэш заказ не попадает
+		violation := fmt.Errorf("%w: payment_amount_check", database.ErrConstraintViolation)
+		mockDB.EXPECT().SaveOrder(gomock.Any(), order).Return(violation).Times(1)
+
+		err := svc.ProcessOrder(context.Background(), order)
+		assert.ErrorIs(t, err, database.ErrConstraintViolation)
+	})
+
+	t.Run("CancelledDuringSave", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		mockCache := mocks.NewMockCache(ctrl)
+		svc := NewWithCache(mockDB, mockCache)
+
+		// Остановка consumer отменяет ctx: сохранение прерывается без повторных попыток
+		type traceKey struct{}
+		ctx, cancel := context.WithCancel(context.WithValue(context.Background(), traceKey{}, "trace-1"))
+		mockDB.EXPECT().SaveOrder(gomock.Any(), order).DoAndReturn(func(ctx context.Context, _ *models.Order) error {
+			assert.Equal(t, "trace-1", ctx.Value(traceKey{}), "значения контекста доходят до БД")
+			cancel()
+			<-ctx.Done()
+			return ctx.Err()
+		}).Times(1)
+
+		err := svc.ProcessOrder(ctx, order)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestService_ProcessOrders(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		orderCache := cache.New(30 * time.Minute)
+		svc := NewWithCache(mockDB, orderCache)
+
+		// Мягко удаленный заказ пакета сохраняется, но в кэш не попадает
+		orders := []*models.Order{
+			{OrderUID: "batch-1", Locale: "en"},
+			{OrderUID: "batch-2", Locale: "en"},
+			{OrderUID: "batch-deleted", Locale: "en"},
+		}
+		mockDB.EXPECT().SaveOrders(gomock.Any(), orders).DoAndReturn(func(_ context.Context, orders []*models.Order) error {
+			for _, order := range orders {
+				assert.False(t, order.DateCreated.IsZero(), "дата создания задается до сохранения")
+			}
+			deletedAt := time.Now()
+			orders[2].DeletedAt = &deletedAt
+			return nil
+		})
+
+		require.NoError(t, svc.ProcessOrders(context.Background(), orders))
+		for _, uid := range []string{"batch-1", "batch-2"} {
+			_, exists := orderCache.Get(uid)
+			assert.True(t, exists, uid)
+		}
+		_, exists := orderCache.Get("batch-deleted")
+		assert.False(t, exists)
+		assert.Equal(t, uint64(2), svc.stats.OrdersProcessed)
+	})
+
+	t.Run("DatabaseError", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		mockCache := mocks.NewMockCache(ctrl)
+		svc := NewWithCache(mockDB, mockCache)
+
+		// Пакет не сохранен: без повторов на уровне сервиса и без обращений к кэшу
+		orders := []*models.Order{{OrderUID: "batch-1", Locale: "en"}}
+		mockDB.EXPECT().SaveOrders(gomock.Any(), orders).Return(errors.New("database error")).Times(1)
+
+		err := svc.ProcessOrders(context.Background(), orders)
+		assert.ErrorContains(t, err, "database error")
+	})
+}
+
+// expectLoad ожидает GetOrSet, который, как кэш при промахе, загружает заказ через loader
+func expectLoad(mockCache *mocks.MockCache, orderUID interface{}) *gomock.Call {
+	return mockCache.EXPECT().GetOrSet(orderUID, gomock.Any()).DoAndReturn(
+		func(_ string, loader func() (*models.Order, error)) (*models.Order, bool, error) {
+			order, err := loader()
+			return order, false, err
+		})
+}
+
+// primaryRead сопоставляет опцию чтения, направляющую запрос на основной сервер
+type primaryRead struct{}
+
+func (primaryRead) Matches(x interface{}) bool {
+	opt, ok := x.(interfaces.ReadOption)
+	return ok && interfaces.ApplyReadOptions([]interfaces.ReadOption{opt}).Primary
+}
+
+func (primaryRead) String() string { return "is interfaces.FromPrimary()" }
+
+// expectReplace ожидает ReplaceStream, который, как кэш, принимает заказы от fill;
+// переданные в add заказы собираются в added
+func expectReplace(mockCache *mocks.MockCache, added *[]*models.Order) *gomock.Call {
+	return mockCache.EXPECT().ReplaceStream(gomock.Any()).DoAndReturn(
+		func(fill func(add func(*models.Order)) error) error {
+			return fill(func(order *models.Order) { *added = append(*added, order) })
+		})
+}
+
+// expectCounts разрешает подсчет заказов в БД для статистики
+func expectCounts(mockDB *mocks.MockDatabase, total, lastDay int64) {
+	mockDB.EXPECT().CountOrders(gomock.Any()).Return(total, nil).AnyTimes()
+	mockDB.EXPECT().CountOrdersSince(gomock.Any(), gomock.Any()).Return(lastDay, nil).AnyTimes()
 }
 
 func TestService_GetOrder(t *testing.T) {
@@ -110,9 +300,9 @@ func TestService_GetOrder(t *testing.T) {
 		svc := NewWithCache(mockDB, mockCache)
 
 		// Ожидаем, что кэш вернет заказ
-		mockCache.EXPECT().Get("order-123").Return(order, true)
+		mockCache.EXPECT().Lookup("order-123").Return(order, false, true)
 
-		result, err := svc.GetOrder("order-123")
+		result, err := svc.GetOrder(context.Background(), "order-123")
 		assert.NoError(t, err, "получение заказа из кэша не должно возвращать ошибки")
 		assert.Equal(t, order, result, "результат должен совпадать с ожидаемым заказом")
 	})
@@ -127,13 +317,12 @@ func TestService_GetOrder(t *testing.T) {
 		svc := NewWithCache(mockDB, mockCache)
 
 		// Ожидаем, что кэш вернет не найдено
-		mockCache.EXPECT().Get("order-123").Return(nil, false)
+		mockCache.EXPECT().Lookup("order-123").Return(nil, false, false)
+		expectLoad(mockCache, "order-123")
 		// Ожидаем, что база данных вернет заказ
 		mockDB.EXPECT().GetOrder(gomock.Any(), "order-123").Return(order, nil)
-		// Ожидаем, что кэш установит заказ
-		mockCache.EXPECT().Set(order)
 
-		result, err := svc.GetOrder("order-123")
+		result, err := svc.GetOrder(context.Background(), "order-123")
 		assert.NoError(t, err, "получение заказа из БД не должно возвращать ошибки")
 		assert.Equal(t, order, result, "результат должен совпадать с ожидаемым заказом")
 	})
@@ -148,11 +337,12 @@ func TestService_GetOrder(t *testing.T) {
 		svc := NewWithCache(mockDB, mockCache)
 
 		// Ожидаем, что кэш вернет не найдено
-		mockCache.EXPECT().Get("order-123").Return(nil, false)
+		mockCache.EXPECT().Lookup("order-123").Return(nil, false, false)
+		expectLoad(mockCache, "order-123")
 		// Ожидаем, что база данных вернет ошибку
 		mockDB.EXPECT().GetOrder(gomock.Any(), "order-123").Return(nil, errors.New("not found"))
 
-		result, err := svc.GetOrder("order-123")
+		result, err := svc.GetOrder(context.Background(), "order-123")
 		assert.Error(t, err, "получение заказа из БД при ошибке должно возвращать ошибку")
 		assert.Nil(t, result, "результат должен быть nil")
 		assert.Contains(t, err.Error(), "not found", "ошибка должна содержать текст 'not found'")
@@ -171,20 +361,17 @@ func TestService_GetOrder(t *testing.T) {
 		dbOrder := &models.Order{OrderUID: "order-123", Locale: "en"}
 
 		// Ожидаем, что кэш вернет не найдено
-		mockCache.EXPECT().Get("order-123").Return(nil, false)
+		mockCache.EXPECT().Lookup("order-123").Return(nil, false, false)
+		expectLoad(mockCache, "order-123")
 		// Ожидаем, что база данных вернет заказ
 		mockDB.EXPECT().GetOrder(gomock.Any(), "order-123").Return(dbOrder, nil)
-		// Ожидаем, что кэш установит заказ
-		mockCache.EXPECT().Set(dbOrder)
 
-		result, err := svc.GetOrder("order-123")
+		result, err := svc.GetOrder(context.Background(), "order-123")
 		assert.NoError(t, err, "получение заказа из БД не должно возвращать ошибки")
 		assert.Equal(t, dbOrder, result, "результат должен совпадать с полученным из БД заказом")
 	})
-}
 
-func TestService_GetCacheStats(t *testing.T) {
-	t.Run("StatsRetrieved", func(t *testing.T) {
+	t.Run("CallerCancelDoesNotAbortSharedLoad", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
 
@@ -193,18 +380,51 @@ func TestService_GetCacheStats(t *testing.T) {
 
 		svc := NewWithCache(mockDB, mockCache)
 
-		// Ожидаем вызов размера кэша
-		mockCache.EXPECT().Size().Return(5)
+		// Клиент отключился: контекст запроса уже отменен, но загрузку ждут и другие
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		mockCache.EXPECT().Lookup("order-123").Return(nil, false, false)
+		expectLoad(mockCache, "order-123")
+		mockDB.EXPECT().GetOrder(gomock.Any(), "order-123").DoAndReturn(
+			func(ctx context.Context, _ string, _ ...interfaces.ReadOption) (*models.Order, error) {
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+				return order, nil
+			})
+
+		result, err := svc.GetOrder(ctx, "order-123")
+		assert.NoError(t, err, "отмена контекста вызывающего не должна прерывать общую загрузку")
+		assert.Equal(t, order, result)
+	})
 
-		stats := svc.GetCacheStats()
-		assert.NotNil(t, stats, "статистика не должна быть пустой")
-		assert.Equal(t, 5, stats["cache_size"], "размер кэша должен совпадать")
-		assert.NotNil(t, stats["timestamp"], "временная метка должна присутствовать")
+	t.Run("ServiceTimeoutBoundsCallerDeadline", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		mockCache := mocks.NewMockCache(ctrl)
+
+		svc := NewWithCache(mockDB, mockCache)
+
+		mockCache.EXPECT().Lookup("order-123").Return(nil, false, false)
+		expectLoad(mockCache, "order-123")
+		mockDB.EXPECT().GetOrder(gomock.Any(), "order-123").DoAndReturn(
+			func(ctx context.Context, _ string, _ ...interfaces.ReadOption) (*models.Order, error) {
+				deadline, ok := ctx.Deadline()
+				assert.True(t, ok, "запрос к БД должен иметь дедлайн")
+				assert.WithinDuration(t, time.Now().Add(getOrderTimeout), deadline, time.Second)
+				return nil, errors.New("not found")
+			})
+
+		// Контекст без дедлайна получает таймаут сервиса
+		_, _ = svc.GetOrder(context.Background(), "order-123")
 	})
 }
 
-func TestService_Close(t *testing.T) {
-	t.Run("CloseSuccessfully", func(t *testing.T) {
+func TestService_DeleteOrder(t *testing.T) {
+	t.Run("Deleted", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
 
@@ -213,21 +433,70 @@ func TestService_Close(t *testing.T) {
 
 		svc := NewWithCache(mockDB, mockCache)
 
-		// Мок вызова закрытия БД
-		mockDB.EXPECT().Close()
-		mockCache.EXPECT().Size().Return(0).AnyTimes()
+		mockDB.EXPECT().DeleteOrder(gomock.Any(), "order-123").Return(nil)
+		mockCache.EXPECT().Delete("order-123").Return(true)
 
-		// Вызов закрытия
-		svc.Close()
+		assert.NoError(t, svc.DeleteOrder(context.Background(), "order-123"))
+	})
 
-		// Проверяем, что сервис можно использовать после закрытия (очистка должна обрабатываться внутри)
-		stats := svc.GetCacheStats()
-		assert.NotNil(t, stats, "статистика не должна быть пустой после закрытия")
+	t.Run("NotFound", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		mockCache := mocks.NewMockCache(ctrl)
+
+		svc := NewWithCache(mockDB, mockCache)
+
+		// Устаревшая копия в кэше удаляется, даже если заказа нет в БД
+		mockDB.EXPECT().DeleteOrder(gomock.Any(), "order-123").Return(models.ErrOrderNotFound)
+		mockCache.EXPECT().Delete("order-123").Return(true)
+
+		err := svc.DeleteOrder(context.Background(), "order-123")
+		assert.ErrorIs(t, err, models.ErrOrderNotFound)
+	})
+
+	t.Run("DBError", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		mockCache := mocks.NewMockCache(ctrl)
+
+		svc := NewWithCache(mockDB, mockCache)
+
+		// При ошибке БД заказ остается в кэше
+		mockDB.EXPECT().DeleteOrder(gomock.Any(), "order-123").Return(errors.New("connection refused"))
+
+		assert.Error(t, svc.DeleteOrder(context.Background(), "order-123"))
+	})
+
+	t.Run("CallerContext", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		mockCache := mocks.NewMockCache(ctrl)
+
+		svc := NewWithCache(mockDB, mockCache)
+
+		// Отмена запроса клиентом доходит до БД: удаление идет в контексте вызывающего
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		mockDB.EXPECT().DeleteOrder(ctx, "order-123").DoAndReturn(func(ctx context.Context, _ string) error {
+			return ctx.Err()
+		})
+		mockDB.EXPECT().SoftDeleteOrder(ctx, "order-123").DoAndReturn(func(ctx context.Context, _ string) error {
+			return ctx.Err()
+		})
+
+		assert.ErrorIs(t, svc.DeleteOrder(ctx, "order-123"), context.Canceled)
+		assert.ErrorIs(t, svc.SoftDeleteOrder(ctx, "order-123"), context.Canceled)
 	})
 }
 
-func TestService_ProcessOrderWithValidation(t *testing.T) {
-	t.Run("ValidationError", func(t *testing.T) {
+func TestService_SoftDeleteOrder(t *testing.T) {
+	t.Run("Hidden", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
 
@@ -236,22 +505,48 @@ func TestService_ProcessOrderWithValidation(t *testing.T) {
 
 		svc := NewWithCache(mockDB, mockCache)
 
-		// Проверка с недействительным заказом
-		invalidOrder := &models.Order{
-			OrderUID: "", // Обязательное поле отсутствует
-			Locale:   "en",
-		}
+		mockDB.EXPECT().SoftDeleteOrder(gomock.Any(), "order-123").Return(nil)
+		mockCache.EXPECT().Delete("order-123").Return(true)
 
-		mockDB.EXPECT().SaveOrder(gomock.Any(), invalidOrder).Return(errors.New("validation error")).AnyTimes()
+		assert.NoError(t, svc.SoftDeleteOrder(context.Background(), "order-123"))
+	})
 
-		// Проверяем, что если БД отклоняет заказ из-за валидации, это обрабатывается
-		err := svc.ProcessOrder(invalidOrder)
-		assert.Error(t, err, "обработка недействительного заказа должна возвращать ошибку")
+	t.Run("NotFound", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		mockCache := mocks.NewMockCache(ctrl)
+
+		svc := NewWithCache(mockDB, mockCache)
+
+		mockDB.EXPECT().SoftDeleteOrder(gomock.Any(), "order-123").Return(models.ErrOrderNotFound)
+		mockCache.EXPECT().Delete("order-123").Return(false)
+
+		assert.ErrorIs(t, svc.SoftDeleteOrder(context.Background(), "order-123"), models.ErrOrderNotFound)
+	})
+
+	t.Run("CachedSoftDeletedIsNotFound", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		mockCache := mocks.NewMockCache(ctrl)
+
+		svc := NewWithCache(mockDB, mockCache)
+
+		// Заказ в кэше отмечен удаленным: он удаляется из кэша, БД не запрашивается
+		deletedAt := time.Now()
+		mockCache.EXPECT().Lookup("order-123").Return(&models.Order{OrderUID: "order-123", DeletedAt: &deletedAt}, false, true)
+		mockCache.EXPECT().Delete("order-123").Return(true)
+
+		_, err := svc.GetOrder(context.Background(), "order-123")
+		assert.ErrorIs(t, err, models.ErrOrderNotFound)
 	})
 }
 
-func TestService_GetOrderConcurrency(t *testing.T) {
-	t.Run("ConcurrencyTest", func(t *testing.T) {
+func TestService_UpdateOrderStatus(t *testing.T) {
+	t.Run("RefreshesCache", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
 
@@ -260,34 +555,104 @@ func TestService_GetOrderConcurrency(t *testing.T) {
 
 		svc := NewWithCache(mockDB, mockCache)
 
-		// Проверяем, что одновременный доступ не вызывает гонки
-		done := make(chan bool, 2)
+		updated := &models.Order{OrderUID: "order-123", Status: models.StatusPacked}
+		gomock.InOrder(
+			mockDB.EXPECT().GetOrder(gomock.Any(), "order-123", primaryRead{}).Return(&models.Order{OrderUID: "order-123", Status: models.StatusAccepted}, nil),
+			mockDB.EXPECT().UpdateOrderStatus(gomock.Any(), "order-123", models.StatusPacked).Return(nil),
+			mockDB.EXPECT().GetOrder(gomock.Any(), "order-123", primaryRead{}).Return(updated, nil),
+		)
+		mockCache.EXPECT().Set(updated)
+
+		order, err := svc.UpdateOrderStatus(context.Background(), "order-123", models.StatusPacked)
+		require.NoError(t, err)
+		assert.Equal(t, models.StatusPacked, order.Status)
+	})
 
-		// Горутина 1: Получение заказа из кэша
-		go func() {
-			order := &models.Order{OrderUID: "order-1", Locale: "en"}
-			mockCache.EXPECT().Get("order-1").Return(order, true).AnyTimes()
-			_, _ = svc.GetOrder("order-1")
-			done <- true
-		}()
+	t.Run("InvalidTransition", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
 
-		// Горутина 2: Обработка заказа
-		go func() {
-			order := &models.Order{OrderUID: "order-2", Locale: "en"}
-			mockDB.EXPECT().SaveOrder(gomock.Any(), order).Return(nil).AnyTimes()
-			mockCache.EXPECT().Set(order).AnyTimes()
-			_ = svc.ProcessOrder(order)
-			done <- true
-		}()
+		mockDB := mocks.NewMockDatabase(ctrl)
+		mockCache := mocks.NewMockCache(ctrl)
 
-		// Ждем завершения обеих горутин
-		<-done
-		<-done
+		svc := NewWithCache(mockDB, mockCache)
+		rejected := testutil.ToFloat64(svc.metrics.StatusTransitionsRejectedTotal)
+
+		// Статус в БД не меняется, кэш не трогается
+		mockDB.EXPECT().GetOrder(gomock.Any(), "order-123", primaryRead{}).Return(&models.Order{OrderUID: "order-123", Status: models.StatusShipped}, nil)
+
+		_, err := svc.UpdateOrderStatus(context.Background(), "order-123", models.StatusAccepted)
+		var transitionErr *models.StatusTransitionError
+		require.ErrorAs(t, err, &transitionErr)
+		assert.Equal(t, models.StatusShipped, transitionErr.From)
+		assert.Equal(t, models.StatusAccepted, transitionErr.To)
+		assert.Equal(t, rejected+1, testutil.ToFloat64(svc.metrics.StatusTransitionsRejectedTotal))
+	})
+
+	t.Run("SameStatusIsNoop", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		mockCache := mocks.NewMockCache(ctrl)
+
+		svc := NewWithCache(mockDB, mockCache)
+
+		current := &models.Order{OrderUID: "order-123", Status: models.StatusShipped}
+		mockDB.EXPECT().GetOrder(gomock.Any(), "order-123", primaryRead{}).Return(current, nil)
+		mockCache.EXPECT().Set(current)
+
+		order, err := svc.UpdateOrderStatus(context.Background(), "order-123", models.StatusShipped)
+		require.NoError(t, err)
+		assert.Same(t, current, order)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		mockCache := mocks.NewMockCache(ctrl)
+
+		svc := NewWithCache(mockDB, mockCache)
+
+		mockDB.EXPECT().GetOrder(gomock.Any(), "order-123", primaryRead{}).Return(nil, models.ErrOrderNotFound)
+		mockCache.EXPECT().Delete("order-123").Return(false)
+
+		_, err := svc.UpdateOrderStatus(context.Background(), "order-123", models.StatusAccepted)
+		assert.ErrorIs(t, err, models.ErrOrderNotFound)
+	})
+
+	t.Run("UnknownStatus", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		svc := NewWithCache(mocks.NewMockDatabase(ctrl), mocks.NewMockCache(ctrl))
+
+		_, err := svc.UpdateOrderStatus(context.Background(), "order-123", "returned")
+		assert.ErrorIs(t, err, models.ErrInvalidStatus)
 	})
 }
 
-func TestService_WarmUpCacheWithEmptyDB(t *testing.T) {
-	t.Run("EmptyDatabase", func(t *testing.T) {
+func TestService_ClearCache(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := mocks.NewMockDatabase(ctrl)
+	mockCache := mocks.NewMockCache(ctrl)
+
+	svc := NewWithCache(mockDB, mockCache)
+
+	// БД не затрагивается: вызовов mockDB нет
+	mockCache.EXPECT().Clear().Return(3)
+
+	assert.Equal(t, 3, svc.ClearCache())
+}
+
+func TestService_ArchiveOrders(t *testing.T) {
+	cutoff := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("EvictsArchived", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
 
@@ -296,12 +661,1004 @@ func TestService_WarmUpCacheWithEmptyDB(t *testing.T) {
 
 		svc := NewWithCache(mockDB, mockCache)
 
-		// Ожидаемые вызовы
-		mockDB.EXPECT().GetAllOrders(gomock.Any()).Return([]models.Order{}, nil)
-		mockCache.EXPECT().LoadFromSlice([]models.Order{})
-		mockCache.EXPECT().Size().Return(0)
+		mockDB.EXPECT().ArchiveOrdersBefore(gomock.Any(), cutoff, database.DefaultArchiveBatchSize).Return(int64(3), nil)
+		mockCache.EXPECT().DeleteCreatedBefore(cutoff).Return(1)
 
-		err := svc.WarmUpCache(context.Background())
-		assert.NoError(t, err, "загрузка кэша из пустой БД не должна возвращать ошибки")
+		archived, err := svc.ArchiveOrders(context.Background(), cutoff)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), archived)
+	})
+
+	t.Run("PartialFailureStillEvicts", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		mockCache := mocks.NewMockCache(ctrl)
+
+		svc := NewWithCache(mockDB, mockCache)
+
+		// Зафиксированные до ошибки пакеты уже удалены из БД
+		mockDB.EXPECT().ArchiveOrdersBefore(gomock.Any(), cutoff, database.DefaultArchiveBatchSize).Return(int64(1), errors.New("deadlock"))
+		mockCache.EXPECT().DeleteCreatedBefore(cutoff).Return(1)
+
+		archived, err := svc.ArchiveOrders(context.Background(), cutoff)
+		assert.Error(t, err)
+		assert.Equal(t, int64(1), archived)
+	})
+}
+
+func TestService_EvictOrdersCreatedBefore(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := mocks.NewMockDatabase(ctrl)
+	mockCache := mocks.NewMockCache(ctrl)
+
+	svc := NewWithCache(mockDB, mockCache)
+
+	// Секции уже удалены, к БД обращений нет
+	cutoff := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mockCache.EXPECT().DeleteCreatedBefore(cutoff).Return(2)
+
+	svc.EvictOrdersCreatedBefore(cutoff)
+}
+
+func TestService_StartArchiving(t *testing.T) {
+	t.Run("Disabled", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		svc := New(mockDB)
+
+		// Без срока хранения архивация не запускается: вызовов ArchiveOrdersBefore нет
+		svc.StartArchiving(0, time.Millisecond)
+		mockDB.EXPECT().Close()
+		svc.Close()
+	})
+
+	t.Run("RunsUntilClose", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		svc := New(mockDB)
+
+		const retention = 24 * time.Hour
+		runs := make(chan time.Time, 10)
+		mockDB.EXPECT().ArchiveOrdersBefore(gomock.Any(), gomock.Any(), database.DefaultArchiveBatchSize).
+			DoAndReturn(func(_ context.Context, cutoff time.Time, _ int) (int64, error) {
+				select {
+				case runs <- cutoff:
+				default:
+				}
+				return 0, nil
+			}).MinTimes(2)
+
+		start := time.Now()
+		svc.StartArchiving(retention, 10*time.Millisecond)
+		for i := 0; i < 2; i++ {
+			select {
+			case cutoff := <-runs:
+				assert.WithinDuration(t, start.Add(-retention), cutoff, time.Second)
+			case <-time.After(time.Second):
+				t.Fatal("архивация не запущена")
+			}
+		}
+
+		// Close останавливает архивацию до закрытия БД
+		mockDB.EXPECT().Close()
+		svc.Close()
+	})
+}
+
+func TestService_GetCacheStats(t *testing.T) {
+	t.Run("StatsRetrieved", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		mockCache := mocks.NewMockCache(ctrl)
+
+		svc := NewWithCache(mockDB, mockCache)
+
+		// Ожидаем вызов размера кэша
+		mockCache.EXPECT().Size().Return(5)
+		mockCache.EXPECT().Evicted().Return(uint64(3))
+		mockCache.EXPECT().MemoryUsage().Return(int64(2048), int64(4096))
+		expectCounts(mockDB, 0, 0)
+
+		stats := svc.GetCacheStats()
+		assert.NotNil(t, stats, "статистика не должна быть пустой")
+		assert.Equal(t, 5, stats["cache_size"], "размер кэша должен совпадать")
+		assert.Equal(t, uint64(3), stats["cache_evictions"], "счетчик вытеснений из кэша")
+		assert.Equal(t, int64(2048), stats["cache_bytes"])
+		assert.Equal(t, int64(4096), stats["cache_bytes_budget"])
+		assert.NotNil(t, stats["timestamp"], "временная метка должна присутствовать")
+		assert.Equal(t, 0.0, stats["cache_hit_ratio"], "без запросов доля попаданий равна нулю")
+		assert.Nil(t, stats["last_order_processed"], "без обработанных сообщений время отсутствует")
+		assert.Contains(t, stats, "uptime_seconds")
+	})
+
+	t.Run("HitsAndMisses", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		mockCache := mocks.NewMockCache(ctrl)
+
+		svc := NewWithCache(mockDB, mockCache)
+		order := &models.Order{OrderUID: "order-123"}
+
+		// Два попадания в кэш
+		mockCache.EXPECT().Lookup("order-123").Return(order, false, true).Times(2)
+		// Один промах с запросом в БД
+		mockCache.EXPECT().Lookup("order-456").Return(nil, false, false)
+		expectLoad(mockCache, "order-456")
+		mockDB.EXPECT().GetOrder(gomock.Any(), "order-456").Return(order, nil)
+		mockCache.EXPECT().Size().Return(1)
+		mockCache.EXPECT().Evicted().Return(uint64(0))
+		mockCache.EXPECT().MemoryUsage().Return(int64(0), int64(0))
+		expectCounts(mockDB, 0, 0)
+
+		_, _ = svc.GetOrder(context.Background(), "order-123")
+		_, _ = svc.GetOrder(context.Background(), "order-123")
+		_, _ = svc.GetOrder(context.Background(), "order-456")
+
+		stats := svc.GetCacheStats()
+		assert.Equal(t, uint64(2), stats["cache_hits"])
+		assert.Equal(t, uint64(1), stats["cache_misses"])
+		assert.InDelta(t, 2.0/3.0, stats["cache_hit_ratio"], 1e-9)
+	})
+
+	t.Run("OrdersProcessed", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		mockCache := mocks.NewMockCache(ctrl)
+
+		svc := NewWithCache(mockDB, mockCache)
+		order := &models.Order{OrderUID: "order-123", DateCreated: time.Now()}
+
+		mockDB.EXPECT().SaveOrder(gomock.Any(), order).Return(nil)
+		mockCache.EXPECT().Set(order)
+		mockCache.EXPECT().Size().Return(1)
+		mockCache.EXPECT().Evicted().Return(uint64(0))
+		mockCache.EXPECT().MemoryUsage().Return(int64(0), int64(0))
+		expectCounts(mockDB, 0, 0)
+
+		require.NoError(t, svc.ProcessOrder(context.Background(), order))
+
+		stats := svc.GetCacheStats()
+		assert.Equal(t, uint64(1), stats["orders_processed_total"])
+		assert.NotNil(t, stats["last_order_processed"])
+		assert.Equal(t, uint64(0), stats["cache_hits"], "обработка заказа не считается запросом к кэшу")
+	})
+
+	t.Run("OrderCountsCached", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		mockCache := mocks.NewMockCache(ctrl)
+		mockCache.EXPECT().Size().Return(0).AnyTimes()
+		mockCache.EXPECT().Evicted().Return(uint64(0)).AnyTimes()
+		mockCache.EXPECT().MemoryUsage().Return(int64(0), int64(0)).AnyTimes()
+
+		svc := NewWithCache(mockDB, mockCache)
+
+		// Повторный опрос в пределах orderCountsTTL не обращается к БД
+		mockDB.EXPECT().CountOrders(gomock.Any()).Return(int64(42), nil)
+		mockDB.EXPECT().CountOrdersSince(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ context.Context, since time.Time) (int64, error) {
+				assert.WithinDuration(t, time.Now().Add(-24*time.Hour), since, time.Minute, "окно — последние сутки")
+				return 7, nil
+			})
+
+		for i := 0; i < 3; i++ {
+			stats := svc.GetCacheStats()
+			assert.Equal(t, int64(42), *stats["orders_total"].(*int64))
+			assert.Equal(t, int64(7), *stats["orders_last_24h"].(*int64))
+		}
+
+		// По истечении TTL количества подсчитываются заново
+		svc.counts.fetchedAt = time.Now().Add(-orderCountsTTL)
+		mockDB.EXPECT().CountOrders(gomock.Any()).Return(int64(43), nil)
+		mockDB.EXPECT().CountOrdersSince(gomock.Any(), gomock.Any()).Return(int64(8), nil)
+
+		stats := svc.GetCacheStats()
+		assert.Equal(t, int64(43), *stats["orders_total"].(*int64))
+		assert.Equal(t, int64(8), *stats["orders_last_24h"].(*int64))
+	})
+
+	t.Run("OrderCountsError", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		mockCache := mocks.NewMockCache(ctrl)
+		mockCache.EXPECT().Size().Return(0).AnyTimes()
+		mockCache.EXPECT().Evicted().Return(uint64(0)).AnyTimes()
+		mockCache.EXPECT().MemoryUsage().Return(int64(0), int64(0)).AnyTimes()
+
+		svc := NewWithCache(mockDB, mockCache)
+
+		// До первого успешного подсчета значений нет; неудачная попытка тоже запоминается
+		mockDB.EXPECT().CountOrders(gomock.Any()).Return(int64(0), database.ErrUnavailable)
+
+		stats := svc.GetCacheStats()
+		assert.Nil(t, stats["orders_total"])
+		assert.Nil(t, stats["orders_last_24h"])
+		assert.Nil(t, svc.GetCacheStats()["orders_total"])
+
+		// После успешного подсчета ошибка оставляет прежние значения
+		svc.counts.fetchedAt = time.Time{}
+		mockDB.EXPECT().CountOrders(gomock.Any()).Return(int64(5), nil)
+		mockDB.EXPECT().CountOrdersSince(gomock.Any(), gomock.Any()).Return(int64(1), nil)
+		require.Equal(t, int64(5), *svc.GetCacheStats()["orders_total"].(*int64))
+
+		svc.counts.fetchedAt = time.Time{}
+		mockDB.EXPECT().CountOrders(gomock.Any()).Return(int64(0), errors.New("timeout"))
+		assert.Equal(t, int64(5), *svc.GetCacheStats()["orders_total"].(*int64))
+	})
+
+	t.Run("DatabasePool", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		mockCache := mocks.NewMockCache(ctrl)
+		mockCache.EXPECT().Size().Return(0).AnyTimes()
+		mockCache.EXPECT().Evicted().Return(uint64(0)).AnyTimes()
+		mockCache.EXPECT().MemoryUsage().Return(int64(0), int64(0)).AnyTimes()
+		expectCounts(mockDB, 0, 0)
+
+		// poolJSON статистика пула из JSON ответа /stats
+		poolJSON := func(t *testing.T, svc *Service) map[string]interface{} {
+			t.Helper()
+			body, err := json.Marshal(svc.GetCacheStats())
+			require.NoError(t, err)
+			var stats map[string]interface{}
+			require.NoError(t, json.Unmarshal(body, &stats))
+			pool, ok := stats["database"].(map[string]interface{})
+			require.True(t, ok, "database — объект: %s", body)
+			return pool
+		}
+
+		// БД без статистики пула (мок) — нули с теми же ключами
+		assert.Equal(t, map[string]interface{}{
+			"acquired_conns": 0.0, "idle_conns": 0.0, "total_conns": 0.0, "max_conns": 0.0,
+			"acquire_count": 0.0, "empty_acquire_count": 0.0,
+		}, poolJSON(t, NewWithCache(mockDB, mockCache)))
+
+		withPool := &poolStatsDB{MockDatabase: mockDB, stats: interfaces.PoolStats{
+			AcquiredConns: 3, IdleConns: 2, TotalConns: 5, MaxConns: 10, AcquireCount: 120, EmptyAcquireCount: 4,
+		}}
+		assert.Equal(t, map[string]interface{}{
+			"acquired_conns": 3.0, "idle_conns": 2.0, "total_conns": 5.0, "max_conns": 10.0,
+			"acquire_count": 120.0, "empty_acquire_count": 4.0,
+		}, poolJSON(t, NewWithCache(withPool, mockCache)))
+	})
+}
+
+// poolStatsDB мок БД со статистикой пула (interfaces.PoolStatsProvider)
+type poolStatsDB struct {
+	*mocks.MockDatabase
+	stats interfaces.PoolStats
+}
+
+func (db *poolStatsDB) Stats() interfaces.PoolStats { return db.stats }
+
+func TestService_Close(t *testing.T) {
+	t.Run("CloseSuccessfully", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		mockCache := mocks.NewMockCache(ctrl)
+
+		svc := NewWithCache(mockDB, mockCache)
+
+		// Мок вызова закрытия БД; фоновая очистка кэша останавливается
+		mockDB.EXPECT().Close()
+		mockCache.EXPECT().StopJanitor()
+		mockCache.EXPECT().Size().Return(0).AnyTimes()
+		mockCache.EXPECT().Evicted().Return(uint64(0)).AnyTimes()
+		mockCache.EXPECT().MemoryUsage().Return(int64(0), int64(0)).AnyTimes()
+		expectCounts(mockDB, 0, 0)
+
+		// Вызов закрытия
+		svc.Close()
+
+		// Проверяем, что сервис можно использовать после закрытия (очистка должна обрабатываться внутри)
+		stats := svc.GetCacheStats()
+		assert.NotNil(t, stats, "статистика не должна быть пустой после закрытия")
+	})
+	t.Run("StopsJanitor", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		mockDB.EXPECT().Close()
+
+		// Сервис с собственным кэшем останавливает его очистку при закрытии
+		svc := New(mockDB, cache.WithCleanupInterval(time.Millisecond))
+		svc.Close()
+	})
+}
+
+func TestService_CacheSnapshot(t *testing.T) {
+	ctx := context.Background()
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	testOrders := []models.Order{
+		{OrderUID: "order-1", Locale: "en", DateCreated: created.Add(-time.Hour)},
+		{OrderUID: "order-2", Locale: "ru", DateCreated: created},
+	}
+
+	t.Run("RestoresOnStartup", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		path := filepath.Join(t.TempDir(), "cache.gob")
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		mockDB.EXPECT().StreamAllOrders(ctx, gomock.Any()).DoAndReturn(streamOrders(testOrders))
+		mockDB.EXPECT().Close().Times(2)
+
+		// После перезапуска догружаются только заказы не старше самого нового из снимка;
+		// граница включена: заказ с тем же временем создания мог не попасть в снимок
+		sameSecond := models.Order{OrderUID: "order-3", DateCreated: created}
+		mockDB.EXPECT().GetOrdersSince(ctx, created).Return([]models.Order{testOrders[1], sameSecond}, nil)
+
+		svc := NewWithCache(mockDB, cache.New(30*time.Minute))
+		svc.SetSnapshot(path, time.Hour)
+		require.NoError(t, svc.WarmUpCache(ctx), "снимка еще нет — прогрев из БД")
+		svc.Close()
+		assert.FileExists(t, path)
+
+		// После перезапуска вся таблица не читается: StreamAllOrders больше не ожидается
+		restoredCache := cache.New(30 * time.Minute)
+		restored := NewWithCache(mockDB, restoredCache)
+		restored.SetSnapshot(path, time.Hour)
+		require.NoError(t, restored.WarmUpCache(ctx))
+		assert.Equal(t, 3, restoredCache.Size())
+		order, exists := restoredCache.Get("order-2")
+		require.True(t, exists)
+		assert.Equal(t, "ru", order.Locale)
+		restored.Close()
+	})
+
+	t.Run("CorruptFallsBackToDB", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		path := filepath.Join(t.TempDir(), "cache.gob")
+		require.NoError(t, os.WriteFile(path, []byte("corrupt"), 0o600))
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		mockDB.EXPECT().StreamAllOrders(ctx, gomock.Any()).DoAndReturn(streamOrders(testOrders))
+
+		orderCache := cache.New(30 * time.Minute)
+		svc := NewWithCache(mockDB, orderCache)
+		svc.SetSnapshot(path, time.Hour)
+		require.NoError(t, svc.WarmUpCache(ctx))
+		assert.Equal(t, 2, orderCache.Size())
+	})
+}
+
+func TestService_WarmUpCacheSince(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("ZeroSinceLoadsEverything", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		mockCache := mocks.NewMockCache(ctrl)
+		svc := NewWithCache(mockDB, mockCache)
+
+		orders := []models.Order{{OrderUID: "order-1"}}
+		var added []*models.Order
+		expectReplace(mockCache, &added)
+		mockDB.EXPECT().StreamAllOrders(ctx, gomock.Any()).DoAndReturn(streamOrders(orders))
+		mockCache.EXPECT().Size().Return(1)
+
+		require.NoError(t, svc.WarmUpCacheSince(ctx, time.Time{}))
+		assert.Equal(t, []*models.Order{&orders[0]}, added)
+	})
+
+	t.Run("KeepsCachedOrders", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		orderCache := cache.New(30 * time.Minute)
+		svc := NewWithCache(mockDB, orderCache)
+
+		since := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+		orderCache.Set(&models.Order{OrderUID: "cached", DateCreated: since})
+		mockDB.EXPECT().GetOrdersSince(ctx, since).Return([]models.Order{{OrderUID: "new", DateCreated: since}}, nil)
+
+		require.NoError(t, svc.WarmUpCacheSince(ctx, orderCache.NewestDateCreated()))
+		assert.Equal(t, 2, orderCache.Size(), "догрузка не заменяет содержимое кэша")
+	})
+
+	t.Run("ReplacesAtomically", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		orderCache := cache.New(30 * time.Minute)
+		orderCache.Set(&models.Order{OrderUID: "deleted"})
+		svc := NewWithCache(mockDB, orderCache)
+
+		// Пока заказы читаются, читатели видят прежнее содержимое кэша
+		mockDB.EXPECT().StreamAllOrders(ctx, gomock.Any()).DoAndReturn(
+			func(_ context.Context, fn func(*models.Order) error, _ ...interfaces.ReadOption) error {
+				for i := 0; i < 3; i++ {
+					_, exists := orderCache.Get("deleted")
+					assert.True(t, exists, "кэш не очищается до замены")
+					assert.Equal(t, 1, orderCache.Size())
+					if err := fn(&models.Order{OrderUID: fmt.Sprintf("order-%d", i)}); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+
+		require.NoError(t, svc.WarmUpCacheSince(ctx, time.Time{}))
+		assert.Equal(t, 3, orderCache.Size())
+		_, exists := orderCache.Get("deleted")
+		assert.False(t, exists, "полный прогрев не оставляет отсутствующие в БД заказы")
+	})
+
+	t.Run("StreamErrorKeepsCache", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		orderCache := cache.New(30 * time.Minute)
+		orderCache.Set(&models.Order{OrderUID: "cached"})
+		svc := NewWithCache(mockDB, orderCache)
+
+		// Соединение оборвалось после первого заказа
+		mockDB.EXPECT().StreamAllOrders(ctx, gomock.Any()).DoAndReturn(
+			func(_ context.Context, fn func(*models.Order) error, _ ...interfaces.ReadOption) error {
+				if err := fn(&models.Order{OrderUID: "order-1"}); err != nil {
+					return err
+				}
+				return database.ErrUnavailable
+			})
+
+		assert.ErrorIs(t, svc.WarmUpCacheSince(ctx, time.Time{}), database.ErrUnavailable)
+		_, exists := orderCache.Get("cached")
+		assert.True(t, exists, "прерванный прогрев не меняет кэш")
+		_, exists = orderCache.Get("order-1")
+		assert.False(t, exists)
+	})
+
+	t.Run("DBError", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		mockCache := mocks.NewMockCache(ctrl)
+		svc := NewWithCache(mockDB, mockCache)
+
+		mockDB.EXPECT().GetOrdersSince(ctx, gomock.Any()).Return(nil, database.ErrUnavailable)
+
+		assert.ErrorIs(t, svc.WarmUpCacheSince(ctx, time.Now()), database.ErrUnavailable)
+	})
+}
+
+func TestService_ProcessOrderWithValidation(t *testing.T) {
+	t.Run("ValidationError", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		mockCache := mocks.NewMockCache(ctrl)
+
+		svc := NewWithCache(mockDB, mockCache)
+
+		// Проверка с недействительным заказом
+		invalidOrder := &models.Order{
+			OrderUID: "", // Обязательное поле отсутствует
+			Locale:   "en",
+		}
+
+		mockDB.EXPECT().SaveOrder(gomock.Any(), invalidOrder).Return(errors.New("validation error")).AnyTimes()
+
+		// Проверяем, что если БД отклоняет заказ из-за валидации, это обрабатывается
+		err := svc.ProcessOrder(context.Background(), invalidOrder)
+		assert.Error(t, err, "обработка недействительного заказа должна возвращать ошибку")
+	})
+}
+
+func TestService_GetOrderConcurrency(t *testing.T) {
+	t.Run("ConcurrencyTest", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		mockCache := mocks.NewMockCache(ctrl)
+
+		svc := NewWithCache(mockDB, mockCache)
+
+		// Проверяем, что одновременный доступ не вызывает гонки
+		done := make(chan bool, 2)
+
+		// Горутина 1: Получение заказа из кэша
+		go func() {
+			order := &models.Order{OrderUID: "order-1", Locale: "en"}
+			mockCache.EXPECT().Lookup("order-1").Return(order, false, true).AnyTimes()
+			_, _ = svc.GetOrder(context.Background(), "order-1")
+			done <- true
+		}()
+
+		// Горутина 2: Обработка заказа
+		go func() {
+			order := &models.Order{OrderUID: "order-2", Locale: "en"}
+			mockDB.EXPECT().SaveOrder(gomock.Any(), order).Return(nil).AnyTimes()
+			mockCache.EXPECT().Set(order).AnyTimes()
+			_ = svc.ProcessOrder(context.Background(), order)
+			done <- true
+		}()
+
+		// Ждем завершения обеих горутин
+		<-done
+		<-done
+	})
+
+	t.Run("ConcurrentMissesQueryDBOnce", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		svc := NewWithCache(mockDB, cache.New(30*time.Minute))
+
+		// Одновременные промахи по одному заказу ждут один запрос к БД
+		release := make(chan struct{})
+		mockDB.EXPECT().GetOrder(gomock.Any(), "order-1").DoAndReturn(func(context.Context, string, ...interfaces.ReadOption) (*models.Order, error) {
+			<-release
+			return &models.Order{OrderUID: "order-1", Locale: "en"}, nil
+		})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				order, err := svc.GetOrder(context.Background(), "order-1")
+				assert.NoError(t, err)
+				assert.Equal(t, "en", order.Locale)
+			}()
+		}
+		time.Sleep(10 * time.Millisecond) // Даем горутинам дойти до ожидания загрузки
+		close(release)
+		wg.Wait()
+	})
+
+	t.Run("FirstCallerCancelDoesNotFailWaiters", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		svc := NewWithCache(mockDB, cache.New(30*time.Minute))
+
+		// Первый вызывающий запускает загрузку и отключается, пока она идет
+		started := make(chan struct{})
+		release := make(chan struct{})
+		mockDB.EXPECT().GetOrder(gomock.Any(), "order-1").DoAndReturn(func(ctx context.Context, _ string, _ ...interfaces.ReadOption) (*models.Order, error) {
+			close(started)
+			<-release
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			return &models.Order{OrderUID: "order-1", Locale: "en"}, nil
+		})
+
+		firstCtx, cancelFirst := context.WithCancel(context.Background())
+		firstDone := make(chan struct{})
+		go func() {
+			defer close(firstDone)
+			_, _ = svc.GetOrder(firstCtx, "order-1")
+		}()
+		<-started
+
+		waiterDone := make(chan struct{})
+		go func() {
+			defer close(waiterDone)
+			order, err := svc.GetOrder(context.Background(), "order-1")
+			assert.NoError(t, err, "ожидающий не должен получать отмену чужого контекста")
+			if assert.NotNil(t, order) {
+				assert.Equal(t, "en", order.Locale)
+			}
+		}()
+		time.Sleep(10 * time.Millisecond) // Даем ожидающему дойти до ожидания загрузки
+
+		cancelFirst()
+		close(release)
+		<-firstDone
+		<-waiterDone
+	})
+}
+
+func TestService_WarmUpCacheWithEmptyDB(t *testing.T) {
+	t.Run("EmptyDatabase", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		mockCache := mocks.NewMockCache(ctrl)
+
+		svc := NewWithCache(mockDB, mockCache)
+
+		// Ожидаемые вызовы
+		expectReplace(mockCache, new([]*models.Order))
+		mockDB.EXPECT().StreamAllOrders(gomock.Any(), gomock.Any()).Return(nil)
+		mockCache.EXPECT().Size().Return(0)
+
+		err := svc.WarmUpCache(context.Background())
+		assert.NoError(t, err, "загрузка кэша из пустой БД не должна возвращать ошибки")
+	})
+}
+
+func TestService_HealthStatus(t *testing.T) {
+	t.Run("Healthy", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		svc := NewWithCache(mockDB, mocks.NewMockCache(ctrl))
+		svc.degraded.Store(true)
+
+		mockDB.EXPECT().Ping(gomock.Any()).Return(nil)
+
+		assert.NoError(t, svc.HealthStatus(context.Background()))
+		assert.False(t, svc.Degraded(), "успешная проверка снимает деградацию")
+	})
+
+	t.Run("Failing", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		svc := NewWithCache(mockDB, mocks.NewMockCache(ctrl))
+
+		mockDB.EXPECT().Ping(gomock.Any()).Return(fmt.Errorf("%w: connection refused", database.ErrUnavailable))
+
+		err := svc.HealthStatus(context.Background())
+		assert.True(t, database.IsUnavailable(err))
+		assert.True(t, svc.Degraded())
+	})
+}
+
+func TestService_Degraded(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := mocks.NewMockDatabase(ctrl)
+	mockCache := mocks.NewMockCache(ctrl)
+
+	svc := NewWithCache(mockDB, mockCache)
+	mockCache.EXPECT().Lookup(gomock.Any()).Return(nil, false, false).AnyTimes()
+	expectLoad(mockCache, gomock.Any()).AnyTimes()
+	mockCache.EXPECT().Size().Return(0).AnyTimes()
+	mockCache.EXPECT().Evicted().Return(uint64(0)).AnyTimes()
+	mockCache.EXPECT().MemoryUsage().Return(int64(0), int64(0)).AnyTimes()
+	expectCounts(mockDB, 0, 0)
+
+	// Ошибка соединения включает деградацию
+	mockDB.EXPECT().GetOrder(gomock.Any(), "order-123").Return(nil, fmt.Errorf("%w: connection refused", database.ErrUnavailable))
+	_, err := svc.GetOrder(context.Background(), "order-123")
+	assert.True(t, database.IsUnavailable(err), "классификация ошибки доходит до вызывающего")
+	assert.True(t, svc.Degraded())
+	assert.Equal(t, float64(1), testutil.ToFloat64(svc.metrics.Degraded))
+	assert.Equal(t, true, svc.GetCacheStats()["degraded"])
+
+	// Ошибка запроса не меняет состояние
+	mockDB.EXPECT().GetOrder(gomock.Any(), "order-456").Return(nil, errors.New("scan failed"))
+	_, _ = svc.GetOrder(context.Background(), "order-456")
+	assert.True(t, svc.Degraded())
+
+	// Ответ БД, даже «не найдено», означает восстановление
+	mockDB.EXPECT().GetOrder(gomock.Any(), "order-789").Return(nil, models.ErrOrderNotFound)
+	_, _ = svc.GetOrder(context.Background(), "order-789")
+	assert.False(t, svc.Degraded())
+	assert.Equal(t, float64(0), testutil.ToFloat64(svc.metrics.Degraded))
+}
+
+func TestService_GetOrderWithSource(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := mocks.NewMockDatabase(ctrl)
+	mockCache := mocks.NewMockCache(ctrl)
+	mockCache.EXPECT().Size().Return(1).AnyTimes()
+	mockCache.EXPECT().Evicted().Return(uint64(0)).AnyTimes()
+	mockCache.EXPECT().MemoryUsage().Return(int64(0), int64(0)).AnyTimes()
+	expectCounts(mockDB, 0, 0)
+
+	svc := NewWithCache(mockDB, mockCache)
+	order := &models.Order{OrderUID: "order-123"}
+
+	// Промах кэша: заказ читается из БД и кладется в кэш через GetOrSet
+	mockCache.EXPECT().Lookup("order-123").Return(nil, false, false)
+	expectLoad(mockCache, "order-123")
+	mockDB.EXPECT().GetOrder(gomock.Any(), "order-123").Return(order, nil)
+
+	result, source, err := svc.GetOrderWithSource(context.Background(), "order-123")
+	require.NoError(t, err)
+	assert.Equal(t, order, result)
+	assert.Equal(t, interfaces.SourceDatabase, source)
+
+	// Попадание: БД не вызывается
+	mockCache.EXPECT().Lookup("order-123").Return(order, false, true)
+
+	result, source, err = svc.GetOrderWithSource(context.Background(), "order-123")
+	require.NoError(t, err)
+	assert.Equal(t, order, result)
+	assert.Equal(t, interfaces.SourceCache, source)
+
+	// Ошибка БД считается промахом
+	mockCache.EXPECT().Lookup("missing").Return(nil, false, false)
+	expectLoad(mockCache, "missing")
+	mockDB.EXPECT().GetOrder(gomock.Any(), "missing").Return(nil, models.ErrOrderNotFound)
+
+	_, source, err = svc.GetOrderWithSource(context.Background(), "missing")
+	assert.ErrorIs(t, err, models.ErrOrderNotFound)
+	assert.Equal(t, interfaces.SourceDatabase, source)
+
+	// Счетчики статистики обновляются по источнику
+	stats := svc.GetCacheStats()
+	assert.Equal(t, uint64(1), stats["cache_hits"])
+	assert.Equal(t, uint64(2), stats["cache_misses"])
+}
+
+func TestService_GetOrders(t *testing.T) {
+	t.Run("CacheAndDatabase", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		mockCache := mocks.NewMockCache(ctrl)
+		mockCache.EXPECT().Size().Return(1).AnyTimes()
+		mockCache.EXPECT().Evicted().Return(uint64(0)).AnyTimes()
+		mockCache.EXPECT().MemoryUsage().Return(int64(0), int64(0)).AnyTimes()
+		expectCounts(mockDB, 0, 0)
+
+		svc := NewWithCache(mockDB, mockCache)
+		cached := &models.Order{OrderUID: "order-1"}
+		stored := &models.Order{OrderUID: "order-2"}
+
+		uids := []string{"order-1", "order-2", "missing"}
+		mockCache.EXPECT().GetMulti(uids).Return(map[string]*models.Order{"order-1": cached}, []string{"order-2", "missing"})
+		mockDB.EXPECT().GetOrder(gomock.Any(), "order-2").Return(stored, nil)
+		mockCache.EXPECT().Set(stored)
+		mockDB.EXPECT().GetOrder(gomock.Any(), "missing").Return(nil, models.ErrOrderNotFound)
+
+		orders, err := svc.GetOrders(context.Background(), uids)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]*models.Order{"order-1": cached, "order-2": stored}, orders)
+
+		stats := svc.GetCacheStats()
+		assert.Equal(t, uint64(1), stats["cache_hits"])
+		assert.Equal(t, uint64(2), stats["cache_misses"])
+	})
+
+	t.Run("AllCached", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		mockCache := mocks.NewMockCache(ctrl)
+
+		svc := NewWithCache(mockDB, mockCache)
+		order := &models.Order{OrderUID: "order-1"}
+
+		// БД не вызывается
+		mockCache.EXPECT().GetMulti([]string{"order-1"}).Return(map[string]*models.Order{"order-1": order}, nil)
+
+		orders, err := svc.GetOrders(context.Background(), []string{"order-1"})
+		require.NoError(t, err)
+		assert.Len(t, orders, 1)
+	})
+
+	t.Run("DBError", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		mockCache := mocks.NewMockCache(ctrl)
+
+		svc := NewWithCache(mockDB, mockCache)
+
+		// После ошибки БД оставшиеся заказы не запрашиваются
+		mockCache.EXPECT().GetMulti([]string{"order-1", "order-2"}).Return(map[string]*models.Order{}, []string{"order-1", "order-2"})
+		mockDB.EXPECT().GetOrder(gomock.Any(), "order-1").Return(nil, errors.New("connection refused"))
+
+		orders, err := svc.GetOrders(context.Background(), []string{"order-1", "order-2"})
+		assert.Error(t, err)
+		assert.Nil(t, orders)
+	})
+}
+
+func TestService_GetOrdersByTrackNumber(t *testing.T) {
+	t.Run("CacheHit", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		mockCache := mocks.NewMockCache(ctrl)
+
+		svc := NewWithCache(mockDB, mockCache)
+		cached := []*models.Order{{OrderUID: "order-1", TrackNumber: "WBILTRACK"}}
+
+		// БД не вызывается
+		mockCache.EXPECT().GetByTrackNumber("WBILTRACK").Return(cached)
+
+		orders, source, err := svc.GetOrdersByTrackNumber(context.Background(), "WBILTRACK")
+		require.NoError(t, err)
+		assert.Equal(t, cached, orders)
+		assert.Equal(t, interfaces.SourceCache, source)
+	})
+
+	t.Run("DatabaseFallbackCaches", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		mockCache := mocks.NewMockCache(ctrl)
+
+		svc := NewWithCache(mockDB, mockCache)
+		stored := []models.Order{
+			{OrderUID: "order-2", TrackNumber: "WBILTRACK"},
+			{OrderUID: "order-1", TrackNumber: "WBILTRACK"},
+		}
+
+		mockCache.EXPECT().GetByTrackNumber("WBILTRACK").Return(nil)
+		mockDB.EXPECT().GetOrderByTrackNumber(gomock.Any(), "WBILTRACK").Return(stored, nil)
+		mockCache.EXPECT().Set(&stored[0])
+		mockCache.EXPECT().Set(&stored[1])
+
+		orders, source, err := svc.GetOrdersByTrackNumber(context.Background(), "WBILTRACK")
+		require.NoError(t, err)
+		assert.Equal(t, interfaces.SourceDatabase, source)
+		require.Len(t, orders, 2)
+		assert.Equal(t, "order-2", orders[0].OrderUID, "порядок БД сохраняется")
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		mockCache := mocks.NewMockCache(ctrl)
+
+		svc := NewWithCache(mockDB, mockCache)
+
+		mockCache.EXPECT().GetByTrackNumber("UNKNOWN").Return(nil)
+		mockDB.EXPECT().GetOrderByTrackNumber(gomock.Any(), "UNKNOWN").Return(nil, models.ErrOrderNotFound)
+
+		orders, _, err := svc.GetOrdersByTrackNumber(context.Background(), "UNKNOWN")
+		assert.ErrorIs(t, err, models.ErrOrderNotFound)
+		assert.Nil(t, orders)
+	})
+}
+
+func TestService_FindOrdersByContact(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := mocks.NewMockDatabase(ctrl)
+	mockCache := mocks.NewMockCache(ctrl)
+	svc := NewWithCache(mockDB, mockCache)
+
+	// Поиск идет в БД, кэш не используется и не заполняется
+	found := []models.Order{{OrderUID: "order-1"}}
+	mockDB.EXPECT().FindOrdersByContact(gomock.Any(), "test@gmail.com", "+9720000000", 10).Return(found, nil)
+
+	orders, err := svc.FindOrdersByContact(context.Background(), "test@gmail.com", "+9720000000", 10)
+	require.NoError(t, err)
+	assert.Equal(t, found, orders)
+}
+
+func TestService_StaleWhileRevalidate(t *testing.T) {
+	t.Run("ServesStaleAndRefreshesOnce", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		mockCache := mocks.NewMockCache(ctrl)
+
+		svc := NewWithCache(mockDB, mockCache)
+		stale := &models.Order{OrderUID: "order-123", Locale: "en"}
+		fresh := &models.Order{OrderUID: "order-123", Locale: "ru"}
+
+		release := make(chan struct{})
+		mockCache.EXPECT().Lookup("order-123").Return(stale, true, true).Times(3)
+		// Несколько чтений устаревшего заказа запускают одно обновление
+		mockDB.EXPECT().GetOrder(gomock.Any(), "order-123").DoAndReturn(func(context.Context, string, ...interfaces.ReadOption) (*models.Order, error) {
+			<-release
+			return fresh, nil
+		})
+		mockCache.EXPECT().Revalidate(fresh).Return(true)
+
+		for i := 0; i < 3; i++ {
+			order, source, err := svc.GetOrderWithSource(context.Background(), "order-123")
+			require.NoError(t, err)
+			assert.Equal(t, stale, order, "устаревший заказ отдается без ожидания БД")
+			assert.Equal(t, interfaces.SourceCache, source)
+		}
+
+		close(release)
+		svc.refreshWG.Wait()
+	})
+
+	t.Run("WorkerLimit", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		mockCache := mocks.NewMockCache(ctrl)
+
+		svc := NewWithCache(mockDB, mockCache)
+
+		release := make(chan struct{})
+		var calls atomic.Int32
+		mockCache.EXPECT().Lookup(gomock.Any()).DoAndReturn(func(uid string) (*models.Order, bool, bool) {
+			return &models.Order{OrderUID: uid}, true, true
+		}).AnyTimes()
+		mockDB.EXPECT().GetOrder(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, uid string, _ ...interfaces.ReadOption) (*models.Order, error) {
+			calls.Add(1)
+			<-release
+			return &models.Order{OrderUID: uid}, nil
+		}).AnyTimes()
+		mockCache.EXPECT().Revalidate(gomock.Any()).Return(true).AnyTimes()
+
+		// Массовое истечение не порождает горутину на каждый заказ
+		for i := 0; i < 10*refreshWorkers; i++ {
+			_, err := svc.GetOrder(context.Background(), fmt.Sprintf("order-%d", i))
+			require.NoError(t, err)
+		}
+		assert.Eventually(t, func() bool { return calls.Load() == refreshWorkers }, time.Second, time.Millisecond)
+
+		close(release)
+		svc.refreshWG.Wait()
+		assert.Equal(t, int32(refreshWorkers), calls.Load())
+	})
+
+	t.Run("RefreshDoesNotOverwriteConcurrentSet", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockDB := mocks.NewMockDatabase(ctrl)
+		clock := cache.NewFakeClock(time.Now())
+		orderCache := cache.New(time.Minute, cache.WithStaleWhileRevalidate(time.Minute), cache.WithClock(clock))
+		svc := NewWithCache(mockDB, orderCache)
+
+		orderCache.Set(&models.Order{OrderUID: "order-123", Locale: "old"})
+		clock.Advance(2 * time.Minute)
+
+		release := make(chan struct{})
+		mockDB.EXPECT().GetOrder(gomock.Any(), "order-123").DoAndReturn(func(context.Context, string, ...interfaces.ReadOption) (*models.Order, error) {
+			<-release
+			return &models.Order{OrderUID: "order-123", Locale: "db"}, nil
+		})
+		mockDB.EXPECT().SaveOrder(gomock.Any(), gomock.Any()).Return(nil)
+
+		order, err := svc.GetOrder(context.Background(), "order-123")
+		require.NoError(t, err)
+		assert.Equal(t, "old", order.Locale)
+
+		// Новая версия из Kafka приходит, пока обновление ждет БД
+		require.NoError(t, svc.ProcessOrder(context.Background(), &models.Order{OrderUID: "order-123", Locale: "kafka"}))
+		close(release)
+		svc.refreshWG.Wait()
+
+		order, exists := orderCache.Get("order-123")
+		require.True(t, exists)
+		assert.Equal(t, "kafka", order.Locale, "результат обновления не затирает более новую запись")
 	})
 }
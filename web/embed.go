@@ -0,0 +1,20 @@
+// Package web содержит фронтенд, встроенный в бинарник через go:embed
+package web
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed static
+var files embed.FS
+
+// Static возвращает встроенный каталог web/static; корень файловой системы — сам каталог
+func Static() fs.FS {
+	static, err := fs.Sub(files, "static")
+	if err != nil {
+		// Каталог встроен при сборке, ошибка означает поломку директивы go:embed
+		panic(err)
+	}
+	return static
+}
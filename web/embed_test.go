@@ -0,0 +1,23 @@
+package web
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatic(t *testing.T) {
+	static := Static()
+
+	// Корень встроенной файловой системы — каталог static, как у STATIC_DIR
+	for _, name := range []string{"index.html", "script.js", "swagger.html"} {
+		data, err := fs.ReadFile(static, name)
+		require.NoError(t, err, name)
+		assert.NotEmpty(t, data, name)
+	}
+
+	_, err := fs.Stat(static, "embed.go")
+	assert.Error(t, err, "исходники пакета не встраиваются")
+}
@@ -13,13 +13,18 @@ function getOrder() {
     hideOrderInfo();
 
     // Отправляем запрос к API для получения заказа
-    fetch(`/order/${orderId}`)
+    fetch(`/api/v1/orders/${encodeURIComponent(orderId)}`)
         .then(response => {
             console.log('Order response status:', response.status);
             if (!response.ok) {
                 if (response.status === 404) {
                     throw new Error('Order not found');
                 }
+                if (response.status === 503) {
+                    // БД недоступна, а заказа нет в кэше: отсутствие заказа не подтверждено
+                    const retryAfter = response.headers.get('Retry-After') || '5';
+                    throw new Error(`Service temporarily unavailable, please retry in ${retryAfter} seconds`);
+                }
                 throw new Error('Failed to fetch order');
             }
             return response.json();
@@ -132,7 +137,7 @@ function hideOrderInfo() {
 // Функция для обновления статистики сервера
 function refreshStats() {
     console.log('Refreshing stats...');
-    fetch('/stats')
+    fetch('/api/v1/stats')
         .then(response => {
             console.log('Stats response status:', response.status);
             return response.json();
@@ -141,6 +146,7 @@ function refreshStats() {
             console.log('Received stats:', stats);
             // Обновляем размер кэша
             document.getElementById('cacheSize').textContent = stats.cache_size;
+            document.getElementById('cacheHitRatio').textContent = (stats.cache_hit_ratio * 100).toFixed(1) + '%';
             
             // Обновляем время последнего запроса
             if (stats.last_request_time) {
@@ -169,4 +175,51 @@ document.getElementById('orderId').addEventListener('keypress', function(e) {
     if (e.key === 'Enter') {
         getOrder();
     }
-});
\ No newline at end of file
+});
+
+// Живые обновления заказов по WebSocket
+let liveSocket = null;
+const liveCustomers = new Set();
+
+// Открывает соединение и повторно подписывается на выбранных покупателей после переподключения
+function connectLive() {
+    const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
+    liveSocket = new WebSocket(`${protocol}//${window.location.host}/api/v1/ws`);
+
+    liveSocket.onopen = function() {
+        document.getElementById('liveStatus').textContent = 'Connected';
+        liveCustomers.forEach(id => liveSocket.send(JSON.stringify({subscribe: {customer_id: id}})));
+    };
+    liveSocket.onmessage = function(message) {
+        const data = JSON.parse(message.data);
+        if (data.type === 'order.processed') {
+            const item = document.createElement('div');
+            item.className = 'stat-item';
+            item.textContent = `${new Date(data.time).toLocaleTimeString()} ${data.order.customer_id}: ${data.order.order_uid}`;
+            document.getElementById('liveEvents').prepend(item);
+        } else if (data.type === 'error') {
+            showError(data.error);
+        }
+    };
+    liveSocket.onclose = function() {
+        // Сервер мог остановиться: переподключаемся с задержкой
+        document.getElementById('liveStatus').textContent = 'Disconnected';
+        liveSocket = null;
+        setTimeout(connectLive, 5000);
+    };
+}
+
+// Подписка на заказы покупателя из поля ввода
+function subscribeCustomer() {
+    const customerId = document.getElementById('customerId').value.trim();
+    if (!customerId || liveCustomers.has(customerId)) {
+        return;
+    }
+    liveCustomers.add(customerId);
+    if (liveSocket && liveSocket.readyState === WebSocket.OPEN) {
+        liveSocket.send(JSON.stringify({subscribe: {customer_id: customerId}}));
+    } else if (!liveSocket) {
+        connectLive();
+    }
+}
+